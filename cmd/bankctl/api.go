@@ -0,0 +1,16 @@
+package main
+
+import (
+	errors "bank_test/internal/api_errors"
+	stderrors "errors"
+)
+
+// apiError returns the API errors returned by the client unchanged, and reports the others as failures to reach the
+// API or to read its response.
+func apiError(err error) error {
+	var e *errors.APIError
+	if err == nil || stderrors.As(err, &e) {
+		return err
+	}
+	return &unavailableError{err: err}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/pkg/client"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// cli holds the state of a run of bankctl.
+type cli struct {
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	client         *http.Client
+	g              options
+}
+
+// run runs the command with its positional arguments.
+func (c *cli) run(command string, args []string) (*result, error) {
+	if !validFormat(c.g.output) {
+		return nil, &usageError{message: fmt.Sprintf("invalid output format '%s'. Must be table, json or csv", c.g.output)}
+	}
+
+	switch command {
+	case "accounts":
+		if len(args) == 0 {
+			return nil, &usageError{message: "accounts requires a subcommand: create, get or list"}
+		}
+		switch args[0] {
+		case "create":
+			return c.createAccount(args[1:])
+		case "get":
+			return c.getAccount(args[1:])
+		case "list":
+			return c.listAccounts(args[1:])
+		default:
+			return nil, &usageError{message: fmt.Sprintf("unknown accounts subcommand '%s'", args[0])}
+		}
+	case "deposit":
+		return c.createTransaction(enum.Deposit, args)
+	case "withdraw":
+		return c.createTransaction(enum.Withdrawal, args)
+	case "transfer":
+		return c.transfer(args)
+	case "history":
+		return c.history(args)
+	case "profiles":
+		return c.profiles(args)
+	default:
+		return nil, &usageError{message: fmt.Sprintf("unknown command '%s'", command)}
+	}
+}
+
+// createAccount opens an account for the owner given with --owner.
+func (c *cli) createAccount(args []string) (*result, error) {
+	if err := expectArgs(args, "accounts create"); err != nil {
+		return nil, err
+	}
+	if c.g.owner == "" {
+		return nil, &usageError{message: "accounts create requires --owner"}
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	acc, err := a.CreateAccount(c.context(), c.g.owner, c.g.initialBalance)
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return accountsResult(acc, *acc), nil
+}
+
+// getAccount shows an account given by its id or by its IBAN.
+func (c *cli) getAccount(args []string) (*result, error) {
+	if err := expectArgs(args, "accounts get", "account id or IBAN"); err != nil {
+		return nil, err
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	var acc *models.Account
+	if uuid.Validate(args[0]) == nil {
+		acc, err = a.GetAccount(c.context(), args[0])
+	} else {
+		acc, err = a.GetAccountByIBAN(c.context(), args[0])
+	}
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return accountsResult(acc, *acc), nil
+}
+
+// listAccounts lists all accounts.
+func (c *cli) listAccounts(args []string) (*result, error) {
+	if err := expectArgs(args, "accounts list"); err != nil {
+		return nil, err
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	accs, err := a.ListAccounts(c.context())
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return accountsResult(accs, accs...), nil
+}
+
+// createTransaction deposits money into or withdraws it from an account.
+func (c *cli) createTransaction(txType enum.TransactionType, args []string) (*result, error) {
+	name := map[enum.TransactionType]string{enum.Deposit: "deposit", enum.Withdrawal: "withdraw"}[txType]
+	if err := expectArgs(args, name, "account id", "amount"); err != nil {
+		return nil, err
+	}
+	amount, err := parseAmount(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := a.CreateTransaction(c.context(), args[0], txType, amount)
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return transactionsResult(tx, *tx), nil
+}
+
+// transfer transfers money from one account to another.
+func (c *cli) transfer(args []string) (*result, error) {
+	if err := expectArgs(args, "transfer", "from account", "to account", "amount"); err != nil {
+		return nil, err
+	}
+	amount, err := parseAmount(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Transfer(c.context(), args[0], args[1], amount); err != nil {
+		return nil, apiError(err)
+	}
+	return messageResult("money transferred successfully"), nil
+}
+
+// history lists the transactions of an account.
+func (c *cli) history(args []string) (*result, error) {
+	if err := expectArgs(args, "history", "account id"); err != nil {
+		return nil, err
+	}
+
+	a, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	txs, err := a.GetTransactions(c.context(), args[0])
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return transactionsResult(txs, txs...), nil
+}
+
+// profileSummary is a profile as listed by the profiles command. Tokens are never printed.
+type profileSummary struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Actor   string `json:"actor"`
+	Current bool   `json:"current"`
+}
+
+// profiles lists the profiles of the configuration file.
+func (c *cli) profiles(args []string) (*result, error) {
+	if err := expectArgs(args, "profiles"); err != nil {
+		return nil, err
+	}
+
+	config, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	current := strings.ToLower(firstNonEmpty(c.g.profile, c.getenv("BANKCTL_PROFILE"), config.CurrentProfile, defaultProfile))
+
+	summaries := make([]profileSummary, 0, len(config.Profiles))
+	for name, p := range config.Profiles {
+		summaries = append(summaries, profileSummary{Name: name, URL: p.URL, Actor: p.Actor, Current: name == current})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+	r := &result{value: summaries, headers: []string{"NAME", "URL", "ACTOR", "CURRENT"}}
+	for _, s := range summaries {
+		r.rows = append(r.rows, []string{s.Name, s.URL, s.Actor, strconv.FormatBool(s.Current)})
+	}
+	return r, nil
+}
+
+// config loads the configuration file.
+func (c *cli) config() (*Config, error) {
+	return loadConfig(configPath(c.g.config, c.getenv), c.g.config != "" || c.getenv("BANKCTL_CONFIG") != "")
+}
+
+// api creates the client of the API with the selected profile.
+func (c *cli) api() (*client.Client, error) {
+	config, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	_, profile, err := resolveProfile(config, &c.g, c.getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: c.g.timeout}
+	}
+	retries := c.g.retries
+	if retries <= 0 {
+		retries = -1
+	}
+	api, err := client.New(profile.URL, client.Options{HTTPClient: httpClient, Actor: profile.Actor, Token: profile.Token, MaxRetries: retries})
+	if err != nil {
+		return nil, &usageError{message: err.Error()}
+	}
+	return api, nil
+}
+
+// context returns the context of the requests, which carries the idempotency key given with --idempotency-key.
+func (c *cli) context() context.Context {
+	ctx := context.Background()
+	if c.g.idempotencyKey != "" {
+		ctx = client.WithIdempotencyKey(ctx, c.g.idempotencyKey)
+	}
+	return ctx
+}
+
+// expectArgs checks that the command got exactly the named positional arguments.
+func expectArgs(args []string, command string, names ...string) error {
+	if len(args) == len(names) {
+		return nil
+	}
+	if len(names) == 0 {
+		return &usageError{message: fmt.Sprintf("%s takes no arguments", command)}
+	}
+	return &usageError{message: fmt.Sprintf("%s requires %d arguments: <%s>", command, len(names), strings.Join(names, "> <"))}
+}
+
+// parseAmount parses an amount given as an argument.
+func parseAmount(value string) (float64, error) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &usageError{message: fmt.Sprintf("invalid amount '%s'", value)}
+	}
+	return amount, nil
+}
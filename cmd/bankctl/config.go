@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultProfile is the profile used when none is selected.
+	defaultProfile = "default"
+
+	// defaultURL is the base URL of the API when the profile does not define one.
+	defaultURL = "http://localhost:3000"
+)
+
+// Profile holds the base URL of an API and the credentials used to call it.
+type Profile struct {
+	URL   string `mapstructure:"url"`   // base URL of the API
+	Actor string `mapstructure:"actor"` // sent in the X-Actor header and recorded in the audit log
+	Token string `mapstructure:"token"` // sent as a bearer token in the Authorization header
+}
+
+// Config is the configuration file of bankctl. Profile names are case-insensitive.
+type Config struct {
+	CurrentProfile string             `mapstructure:"current_profile"` // profile used when none is selected with --profile or BANKCTL_PROFILE
+	Profiles       map[string]Profile `mapstructure:"profiles"`
+}
+
+// configPath returns the path of the configuration file: the --config flag, BANKCTL_CONFIG, or config.yaml in the
+// bankctl folder of the user configuration directory.
+func configPath(flag string, getenv func(string) string) string {
+	if flag != "" {
+		return flag
+	}
+	if path := getenv("BANKCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bankctl", "config.yaml")
+}
+
+// loadConfig reads the configuration file. A missing file is an empty configuration, unless the path was given
+// explicitly.
+func loadConfig(path string, explicit bool) (*Config, error) {
+	config := &Config{Profiles: make(map[string]Profile)}
+	if path == "" {
+		return config, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !explicit {
+		return config, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, &usageError{message: fmt.Sprintf("failed to read config file: %v", err)}
+	}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, &usageError{message: fmt.Sprintf("failed to parse config file: %v", err)}
+	}
+	return config, nil
+}
+
+// resolveProfile selects the profile and applies the overrides of the flags and the environment to it. The profile
+// is selected with the --profile flag, BANKCTL_PROFILE or the current profile of the configuration, in that order.
+// Selecting a profile that is not defined is an error, except for the default one.
+func resolveProfile(config *Config, g *options, getenv func(string) string) (string, Profile, error) {
+	name := firstNonEmpty(g.profile, getenv("BANKCTL_PROFILE"), config.CurrentProfile, defaultProfile)
+	profile, ok := config.Profiles[strings.ToLower(name)]
+	if !ok && name != defaultProfile {
+		return "", Profile{}, &usageError{message: fmt.Sprintf("profile '%s' is not defined", name)}
+	}
+
+	profile.URL = strings.TrimSuffix(firstNonEmpty(g.url, getenv("BANKCTL_URL"), profile.URL, defaultURL), "/")
+	profile.Actor = firstNonEmpty(g.actor, getenv("BANKCTL_ACTOR"), profile.Actor)
+	profile.Token = firstNonEmpty(getenv("BANKCTL_TOKEN"), profile.Token)
+	return name, profile, nil
+}
+
+// firstNonEmpty returns the first value that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
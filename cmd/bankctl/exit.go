@@ -0,0 +1,78 @@
+package main
+
+import (
+	errors "bank_test/internal/api_errors"
+	stderrors "errors"
+)
+
+// Exit codes that are not related to an API error.
+const (
+	exitOK          = 0 // the command succeeded
+	exitError       = 1 // unexpected errors, and API errors without an exit code of their own
+	exitUsage       = 2 // invalid command, flags, arguments or configuration
+	exitUnavailable = 3 // the API could not be reached or returned a response that is not an API error
+)
+
+// exitCodes maps the codes of the API errors to exit codes, so that scripts can tell them apart. The codes are part
+// of the interface of bankctl: new errors get new codes, and existing codes never change.
+var exitCodes = map[string]int{
+	errors.ErrInvalidBody.Code:           10,
+	errors.ErrAccountIdIsMissing.Code:    11,
+	errors.ErrInvalidAccountID.Code:      12,
+	errors.ErrInvalidIBAN.Code:           13,
+	errors.ErrAccountNotFound.Code:       14,
+	errors.ErrInsufficientBalance.Code:   15,
+	errors.INVALID_AMOUNT.Code:           16,
+	errors.ErrInvalidIdempotencyKey.Code: 17,
+	errors.ErrIdempotencyKeyReused.Code:  18,
+	errors.ErrIdempotencyKeyInUse.Code:   19,
+	errors.ErrTimeout.Code:               20,
+	errors.ErrRequestCanceled.Code:       21,
+	errors.ErrUnauthenticated.Code:       22,
+	errors.ErrInsufficientScope.Code:     23,
+	errors.ErrAccountAccessDenied.Code:   24,
+	errors.ErrForbidden.Code:             25,
+	errors.ErrAccountFrozen.Code:         26,
+}
+
+// usageError is an error in the way the command was called.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// unavailableError is an error reaching the API or reading its response.
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return e.err.Error()
+}
+
+// exitCode returns the exit code of the error returned by a command.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var apiError *errors.APIError
+	var usage *usageError
+	var unavailable *unavailableError
+	switch {
+	case stderrors.As(err, &apiError):
+		if code, ok := exitCodes[apiError.Code]; ok {
+			return code
+		}
+		return exitError
+	case stderrors.As(err, &usage):
+		return exitUsage
+	case stderrors.As(err, &unavailable):
+		return exitUnavailable
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,148 @@
+// Command bankctl is a command-line client of the bank API. It calls the HTTP API with the base URL and the
+// credentials of a profile, prints the results as a table, JSON or CSV, and reports API errors as exit codes so that
+// it can be used from scripts.
+package main
+
+import (
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	errors "bank_test/internal/api_errors"
+	"bank_test/pkg/client"
+)
+
+const usage = `Usage: bankctl [flags] <command> [arguments]
+
+Commands:
+  accounts create --owner <owner> [--initial-balance <amount>]   open an account
+  accounts get <account id or IBAN>                              show an account
+  accounts list                                                  list all accounts
+  deposit <account id> <amount>                                  deposit money into an account
+  withdraw <account id> <amount>                                 withdraw money from an account
+  transfer <from account> <to account> <amount>                  transfer money, accounts given by id or IBAN
+  history <account id>                                           list the transactions of an account
+  profiles                                                       list the profiles of the configuration file
+
+Flags, accepted anywhere in the command line:
+`
+
+// options are the flags of bankctl. Every command accepts every flag, so that they can be given anywhere in the
+// command line.
+type options struct {
+	config  string
+	profile string
+	url     string
+	actor   string
+	output  string
+	timeout time.Duration
+	retries int
+
+	// idempotency key of the command, so that running it again does not repeat it
+	idempotencyKey string
+
+	// flags of the accounts create command
+	owner          string
+	initialBalance float64
+}
+
+// register registers the flags in the flag set.
+func (g *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", g.config, "path of the configuration file. Defaults to BANKCTL_CONFIG or bankctl/config.yaml in the user configuration directory")
+	fs.StringVar(&g.profile, "profile", g.profile, "profile of the configuration file. Defaults to BANKCTL_PROFILE or the current profile")
+	fs.StringVar(&g.url, "url", g.url, "base URL of the API, overriding the profile. Defaults to BANKCTL_URL")
+	fs.StringVar(&g.actor, "actor", g.actor, "actor recorded in the audit log, overriding the profile. Defaults to BANKCTL_ACTOR")
+	fs.StringVar(&g.output, "o", g.output, "output format: table, json or csv")
+	fs.DurationVar(&g.timeout, "timeout", g.timeout, "maximum time to wait for every attempt of a request")
+	fs.IntVar(&g.retries, "retries", g.retries, "number of retries of the requests that fail with a temporary error")
+	fs.StringVar(&g.idempotencyKey, "idempotency-key", g.idempotencyKey, "idempotency key of the command. Running a command again with the same key returns the result of the first run instead of repeating it")
+	fs.StringVar(&g.owner, "owner", g.owner, "owner of the account to create")
+	fs.Float64Var(&g.initialBalance, "initial-balance", g.initialBalance, "initial balance of the account to create")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, os.Getenv, nil))
+}
+
+// run runs the command given by the arguments and returns its exit code. httpClient is the HTTP client used to call
+// the API; if it is nil, a client bounded by the --timeout flag is used.
+func run(args []string, stdout, stderr io.Writer, getenv func(string) string, httpClient *http.Client) int {
+	c := &cli{stdout: stdout, stderr: stderr, getenv: getenv, client: httpClient, g: options{output: tableFormat, timeout: 30 * time.Second, retries: client.DefaultMaxRetries}}
+
+	fs := c.flagSet("bankctl")
+	positional, err := parse(fs, args)
+	if err == nil && len(positional) == 0 {
+		err = &usageError{message: "a command is required"}
+	}
+	if err == nil {
+		var res *result
+		if res, err = c.run(positional[0], positional[1:]); err == nil {
+			err = res.print(stdout, c.g.output)
+		}
+	}
+
+	if stderrors.Is(err, flag.ErrHelp) {
+		printUsage(stdout, fs)
+		return exitOK
+	}
+	if err != nil {
+		c.printError(fs, err)
+	}
+	return exitCode(err)
+}
+
+// printError writes the error to the standard error. API errors are printed with their code, and usage errors with
+// the usage of the command.
+func (c *cli) printError(fs *flag.FlagSet, err error) {
+	var apiError *errors.APIError
+	var usage *usageError
+	switch {
+	case stderrors.As(err, &apiError):
+		fmt.Fprintf(c.stderr, "Error: %s (%s)\n", apiError.Message, apiError.Code)
+	case stderrors.As(err, &usage):
+		fmt.Fprintf(c.stderr, "Error: %s\n\n", usage.message)
+		printUsage(c.stderr, fs)
+	default:
+		fmt.Fprintf(c.stderr, "Error: %v\n", err)
+	}
+}
+
+// flagSet creates a flag set with the flags of every command. Errors and usage are printed by the caller.
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	c.g.register(fs)
+	return fs
+}
+
+// printUsage writes the usage of bankctl.
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprint(w, usage)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+}
+
+// parse parses the flags of the arguments, which may appear before, between or after the positional arguments, and
+// returns the positional arguments. Invalid flags are usage errors.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			if stderrors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &usageError{message: err.Error()}
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
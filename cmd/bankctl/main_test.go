@@ -0,0 +1,287 @@
+package main
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/transport/http/schemas"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const accountID = "7b1b3ba8-9d4f-4b1a-9d9e-111111111111"
+
+// Define the test suite
+type BankctlTestSuite struct {
+	suite.Suite
+
+	server   *httptest.Server
+	requests []*http.Request
+	bodies   []map[string]any
+	env      map[string]string
+}
+
+func (s *BankctlTestSuite) SetupTest() {
+	s.requests = nil
+	s.bodies = nil
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	config := filepath.Join(s.T().TempDir(), "config.yaml")
+	s.Require().NoError(os.WriteFile(config, []byte("profiles: {}\n"), 0o600))
+	s.env = map[string]string{"BANKCTL_URL": s.server.URL, "BANKCTL_CONFIG": config}
+}
+
+func (s *BankctlTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// handle answers the requests of bankctl like the API does, and records them.
+func (s *BankctlTestSuite) handle(w http.ResponseWriter, r *http.Request) {
+	body := make(map[string]any)
+	json.NewDecoder(r.Body).Decode(&body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+
+	account := map[string]any{"id": accountID, "iban": "ES9121000418450200051332", "owner": "Alice", "balance": 100.5, "initial_balance": 100}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/accounts":
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(account)
+			return
+		}
+		json.NewEncoder(w).Encode([]any{account})
+	case "/accounts/" + accountID, "/accounts/by-number/ES9121000418450200051332":
+		json.NewEncoder(w).Encode(account)
+	case "/accounts/" + accountID + "/transactions":
+		if body["amount"] == 1000.0 {
+			w.WriteHeader(errors.ErrInsufficientBalance.HTTPStatus)
+			json.NewEncoder(w).Encode(errors.ErrInsufficientBalance)
+			return
+		}
+		tx := map[string]any{"id": "tx", "account_id": accountID, "type": "deposit", "amount": 10, "timestamp": "2024-01-02T03:04:05Z"}
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(tx)
+			return
+		}
+		json.NewEncoder(w).Encode([]any{tx})
+	case "/transfer":
+		json.NewEncoder(w).Encode(schemas.OkResponse{Message: "money transferred successfully"})
+	case "/teapot/transfer":
+		w.WriteHeader(http.StatusTeapot)
+		json.NewEncoder(w).Encode(errors.NewAPIError("TEAPOT", "i'm a teapot", http.StatusTeapot))
+	default:
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// run runs bankctl with the arguments and returns its exit code and outputs.
+func (s *BankctlTestSuite) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr, func(key string) string { return s.env[key] }, s.server.Client())
+	return code, stdout.String(), stderr.String()
+}
+
+// TestCommands tests the requests sent by every command.
+func (s *BankctlTestSuite) TestCommands() {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		method string
+		path   string
+		body   map[string]any
+	}{
+		{name: "ok: create account", args: []string{"accounts", "create", "--owner", "Alice", "--initial-balance", "100"}, method: http.MethodPost, path: "/accounts", body: map[string]any{"owner": "Alice", "initial_balance": 100.0}},
+		{name: "ok: get account by id", args: []string{"accounts", "get", accountID}, method: http.MethodGet, path: "/accounts/" + accountID},
+		{name: "ok: get account by iban", args: []string{"accounts", "get", "ES9121000418450200051332"}, method: http.MethodGet, path: "/accounts/by-number/ES9121000418450200051332"},
+		{name: "ok: list accounts", args: []string{"accounts", "list"}, method: http.MethodGet, path: "/accounts"},
+		{name: "ok: deposit", args: []string{"deposit", accountID, "10"}, method: http.MethodPost, path: "/accounts/" + accountID + "/transactions", body: map[string]any{"type": "deposit", "amount": 10.0}},
+		{name: "ok: withdraw", args: []string{"withdraw", accountID, "2.5"}, method: http.MethodPost, path: "/accounts/" + accountID + "/transactions", body: map[string]any{"type": "withdrawal", "amount": 2.5}},
+		{name: "ok: transfer", args: []string{"transfer", accountID, "ES9121000418450200051332", "5"}, method: http.MethodPost, path: "/transfer", body: map[string]any{"from_account_id": accountID, "to_account_id": "ES9121000418450200051332", "amount": 5.0}},
+		{name: "ok: history", args: []string{"history", accountID}, method: http.MethodGet, path: "/accounts/" + accountID + "/transactions"},
+	} {
+		s.Run(tc.name, func() {
+			s.requests, s.bodies = nil, nil
+			code, _, stderr := s.run(tc.args...)
+			s.Equal(exitOK, code, stderr)
+			s.Require().Len(s.requests, 1)
+			s.Equal(tc.method, s.requests[0].Method)
+			s.Equal(tc.path, s.requests[0].URL.Path)
+			if tc.body != nil {
+				s.Equal(tc.body, s.bodies[0])
+			}
+		})
+	}
+}
+
+// TestIdempotencyKey tests sending the idempotency key of a command.
+func (s *BankctlTestSuite) TestIdempotencyKey() {
+	s.Run("ok: given key", func() {
+		code, _, _ := s.run("deposit", accountID, "10", "--idempotency-key", "payroll-2024-01")
+		s.Equal(exitOK, code)
+		s.Equal("payroll-2024-01", s.requests[0].Header.Get("Idempotency-Key"))
+	})
+
+	s.Run("ok: generated key", func() {
+		s.requests = nil
+		code, _, _ := s.run("transfer", accountID, accountID, "1")
+		s.Equal(exitOK, code)
+		s.NotEmpty(s.requests[0].Header.Get("Idempotency-Key"))
+	})
+}
+
+// TestOutput tests the output formats.
+func (s *BankctlTestSuite) TestOutput() {
+	s.Run("ok: table", func() {
+		code, stdout, _ := s.run("accounts", "list")
+		s.Equal(exitOK, code)
+		s.Equal("ID                                    IBAN                      OWNER  BALANCE  INITIAL BALANCE\n"+
+			accountID+"  ES9121000418450200051332  Alice  100.5    100\n", stdout)
+	})
+
+	s.Run("ok: csv", func() {
+		code, stdout, _ := s.run("history", accountID, "-o", "csv")
+		s.Equal(exitOK, code)
+		s.Equal("ID,ACCOUNT ID,TYPE,AMOUNT,TIMESTAMP\ntx,"+accountID+",deposit,10,2024-01-02T03:04:05Z\n", stdout)
+	})
+
+	s.Run("ok: json", func() {
+		code, stdout, _ := s.run("-o", "json", "accounts", "get", accountID)
+		s.Equal(exitOK, code)
+		var acc map[string]any
+		s.Require().NoError(json.Unmarshal([]byte(stdout), &acc))
+		s.Equal(accountID, acc["id"])
+		s.Equal(100.5, acc["balance"])
+	})
+
+	s.Run("error: unknown format", func() {
+		code, _, _ := s.run("accounts", "list", "-o", "xml")
+		s.Equal(exitUsage, code)
+	})
+}
+
+// TestExitCodes tests the exit codes of the errors.
+func (s *BankctlTestSuite) TestExitCodes() {
+	s.Run("ok: api error", func() {
+		code, stdout, stderr := s.run("withdraw", accountID, "1000")
+		s.Equal(exitCodes[errors.ErrInsufficientBalance.Code], code)
+		s.Empty(stdout)
+		s.Equal("Error: insufficient balance (INSUFFICIENT_BALANCE)\n", stderr)
+	})
+
+	s.Run("ok: api error without exit code", func() {
+		s.env["BANKCTL_URL"] = s.server.URL + "/teapot"
+		defer func() { s.env["BANKCTL_URL"] = s.server.URL }()
+		code, _, _ := s.run("transfer", accountID, accountID, "1")
+		s.Equal(exitError, code)
+	})
+
+	s.Run("ok: unexpected response", func() {
+		s.env["BANKCTL_URL"] = s.server.URL + "/unknown"
+		defer func() { s.env["BANKCTL_URL"] = s.server.URL }()
+		s.requests = nil
+		code, _, _ := s.run("accounts", "list")
+		s.Equal(exitUnavailable, code)
+		s.Len(s.requests, 4)
+
+		s.requests = nil
+		code, _, _ = s.run("accounts", "list", "--retries", "0")
+		s.Equal(exitUnavailable, code)
+		s.Len(s.requests, 1)
+	})
+
+	s.Run("ok: usage errors", func() {
+		s.requests = nil
+		for _, args := range [][]string{{}, {"unknown"}, {"accounts"}, {"accounts", "create"}, {"deposit", accountID}, {"deposit", accountID, "ten"}, {"--unknown", "history"}} {
+			code, _, _ := s.run(args...)
+			s.Equal(exitUsage, code, args)
+		}
+		s.Empty(s.requests)
+	})
+
+	s.Run("ok: help", func() {
+		code, stdout, _ := s.run("-h")
+		s.Equal(exitOK, code)
+		s.Contains(stdout, "Usage: bankctl")
+	})
+
+	s.Run("ok: exit codes are unique", func() {
+		seen := make(map[int]bool)
+		for _, code := range exitCodes {
+			s.False(seen[code])
+			s.Greater(code, exitUnavailable)
+			seen[code] = true
+		}
+	})
+}
+
+// TestProfiles tests selecting the profiles of the configuration file.
+func (s *BankctlTestSuite) TestProfiles() {
+	path := filepath.Join(s.T().TempDir(), "config.yaml")
+	config := "current_profile: local\n" +
+		"profiles:\n" +
+		"  local:\n" +
+		"    url: " + s.server.URL + "\n" +
+		"    actor: alice\n" +
+		"  Staging:\n" +
+		"    url: " + s.server.URL + "/\n" +
+		"    actor: ops\n" +
+		"    token: secret\n"
+	s.Require().NoError(os.WriteFile(path, []byte(config), 0o600))
+	s.env = map[string]string{"BANKCTL_CONFIG": path}
+
+	s.Run("ok: current profile", func() {
+		s.requests = nil
+		code, _, _ := s.run("accounts", "list")
+		s.Equal(exitOK, code)
+		s.Equal("alice", s.requests[0].Header.Get("X-Actor"))
+		s.Empty(s.requests[0].Header.Get("Authorization"))
+	})
+
+	s.Run("ok: selected profile", func() {
+		s.requests = nil
+		code, _, _ := s.run("accounts", "list", "--profile", "staging")
+		s.Equal(exitOK, code)
+		s.Equal("/accounts", s.requests[0].URL.Path)
+		s.Equal("ops", s.requests[0].Header.Get("X-Actor"))
+		s.Equal("Bearer secret", s.requests[0].Header.Get("Authorization"))
+	})
+
+	s.Run("ok: overrides", func() {
+		s.requests = nil
+		s.env["BANKCTL_PROFILE"] = "staging"
+		s.env["BANKCTL_TOKEN"] = "other"
+		defer func() { delete(s.env, "BANKCTL_PROFILE"); delete(s.env, "BANKCTL_TOKEN") }()
+		code, _, _ := s.run("accounts", "list", "--actor", "bob")
+		s.Equal(exitOK, code)
+		s.Equal("bob", s.requests[0].Header.Get("X-Actor"))
+		s.Equal("Bearer other", s.requests[0].Header.Get("Authorization"))
+	})
+
+	s.Run("ok: list profiles", func() {
+		code, stdout, _ := s.run("profiles", "-o", "csv")
+		s.Equal(exitOK, code)
+		s.Equal("NAME,URL,ACTOR,CURRENT\nlocal,"+s.server.URL+",alice,true\nstaging,"+s.server.URL+"/,ops,false\n", stdout)
+		s.NotContains(stdout, "secret")
+	})
+
+	s.Run("error: undefined profile", func() {
+		code, _, stderr := s.run("accounts", "list", "--profile", "prod")
+		s.Equal(exitUsage, code)
+		s.Contains(stderr, "profile 'prod' is not defined")
+	})
+
+	s.Run("error: missing config file", func() {
+		code, _, _ := s.run("accounts", "list", "--config", filepath.Join(s.T().TempDir(), "missing.yaml"))
+		s.Equal(exitUsage, code)
+	})
+}
+
+func TestBankctlTestSuite(t *testing.T) {
+	suite.Run(t, new(BankctlTestSuite))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"bank_test/internal/db/models"
+)
+
+// Output formats
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+	csvFormat   = "csv"
+)
+
+// result is the result of a command. It is printed as a table or CSV from its rows, or as JSON from its value.
+type result struct {
+	value   any
+	headers []string
+	rows    [][]string
+}
+
+// print writes the result in the given format.
+func (r *result) print(w io.Writer, format string) error {
+	switch format {
+	case jsonFormat:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.value)
+	case csvFormat:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.headers); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(r.rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.headers, "\t"))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// validFormat checks that the output format is supported.
+func validFormat(format string) bool {
+	switch format {
+	case tableFormat, jsonFormat, csvFormat:
+		return true
+	default:
+		return false
+	}
+}
+
+// accountsResult creates the result of a list of accounts.
+func accountsResult(value any, accounts ...models.Account) *result {
+	r := &result{value: value, headers: []string{"ID", "IBAN", "OWNER", "BALANCE", "INITIAL BALANCE"}}
+	for _, acc := range accounts {
+		r.rows = append(r.rows, []string{acc.ID, acc.IBAN, acc.Owner, formatAmount(acc.Balance), formatAmount(acc.InitialBalance)})
+	}
+	return r
+}
+
+// transactionsResult creates the result of a list of transactions.
+func transactionsResult(value any, transactions ...models.Transaction) *result {
+	r := &result{value: value, headers: []string{"ID", "ACCOUNT ID", "TYPE", "AMOUNT", "TIMESTAMP"}}
+	for _, tx := range transactions {
+		r.rows = append(r.rows, []string{tx.ID, tx.AccountID, tx.Type.String(), formatAmount(tx.Amount), tx.Timestamp.Format(time.RFC3339)})
+	}
+	return r
+}
+
+// messageResult creates the result of a command that only returns a message.
+func messageResult(message string) *result {
+	return &result{value: map[string]string{"message": message}, headers: []string{"MESSAGE"}, rows: [][]string{{message}}}
+}
+
+// formatAmount formats an amount with the minimum number of decimals needed to represent it.
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
@@ -1,10 +1,17 @@
 package bootstrap
 
 import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
 	"bank_test/internal/conf"
 	"bank_test/internal/db"
+	"bank_test/internal/freeze"
 	"bank_test/internal/helpers"
+	"bank_test/internal/reconciliation"
 	"bank_test/internal/transport"
+	"bank_test/internal/webhook"
+	"io"
 	"log"
 )
 
@@ -24,11 +31,66 @@ func Run() error {
 	logger.Debugf("starting with config: %s", helpers.PrettyPrintStructResponse(conf.GlobalConfig))
 
 	logger.Debugf("setting up database connection")
-	db := db.NewDatabaseAdapter(logger)
+	db, err := db.NewDatabaseAdapter(logger)
+	if err != nil {
+		return err
+	}
+	if closer, ok := db.(io.Closer); ok {
+		defer closer.Close()
+	}
 	logger.Debugf("database connection established")
 
+	// Setup the frozen accounts. Their transactions are rejected below the audit log, which records them as failures
+	frozen, err := freeze.NewStore(logger, conf.GlobalConfig.FrozenAccountsPath)
+	if err != nil {
+		return err
+	}
+	db = freeze.NewDatabase(logger, db, frozen)
+
+	// Setup the audit log. Every mutation of the database is recorded in it
+	logger.Debugf("setting up audit log")
+	auditLog, err := audit.NewLog(logger, conf.GlobalConfig.AuditLogPath)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+	db = audit.NewDatabase(logger, db, auditLog)
+	logger.Debugf("audit log set up")
+
+	// Setup the activity feed. Every committed mutation is pushed to the subscribers of its account
+	feed := activity.NewFeed(logger, conf.GlobalConfig.ActivityBufferSize)
+	db = activity.NewDatabase(logger, db, feed)
+
+	// Setup the reconciliation job that checks the consistency of the ledger
+	reconciler := reconciliation.NewReconciler(logger, db)
+	reconciler.Start(conf.GlobalConfig.ReconciliationInterval)
+	defer reconciler.Stop()
+
+	// Setup the webhooks. The events stored in the outbox of the database are delivered to the subscriptions
+	logger.Debugf("setting up webhooks")
+	webhooks, err := webhook.NewStore(logger, conf.GlobalConfig.WebhooksPath)
+	if err != nil {
+		return err
+	}
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{
+		PollInterval: conf.GlobalConfig.WebhookPollInterval,
+		Timeout:      conf.GlobalConfig.WebhookTimeout,
+		MaxAttempts:  conf.GlobalConfig.WebhookMaxAttempts,
+		Backoff:      conf.GlobalConfig.WebhookBackoff,
+		MaxBackoff:   conf.GlobalConfig.WebhookMaxBackoff,
+	})
+	dispatcher.Start()
+	defer dispatcher.Stop()
+	logger.Debugf("webhooks set up")
+
+	// Setup the authentication of the callers. The api keys and the jwts are accepted by every transport layer
+	authenticator, err := auth.NewAuthenticator(logger)
+	if err != nil {
+		return err
+	}
+
 	// Setup the transport layer and start the server
-	server := transport.NewTransporter(logger, db)
+	server := transport.NewTransporter(logger, db, reconciler, auditLog, webhooks, dispatcher, feed, authenticator)
 
 	go func() {
 		if err := server.HealthCheck(); err != nil {
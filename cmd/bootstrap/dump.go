@@ -0,0 +1,156 @@
+package bootstrap
+
+import (
+	"bank_test/internal/conf"
+	"bank_test/internal/db"
+	"bank_test/internal/dump"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Export writes every account and transaction of the database configured with DB_DRIVER to a dump that can be loaded
+// into another instance with Import.
+func Export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(enum.JSONLinesFormat), "format of the dump: jsonl or csv")
+	output := flags.String("output", "-", "file the dump is written to, or - for the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	logger, database, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	if closer, ok := database.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if !persistent() {
+		logger.Warnf("database driver '%s' keeps its data in memory, the dump will be empty", conf.GlobalConfig.DBDriver)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if _, err := dump.NewDumper(logger, database).Export(context.Background(), w, enum.DumpFormat(*format)); err != nil {
+		return err
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		return f.Sync()
+	}
+	return nil
+}
+
+// Import loads a dump written by Export into the database configured with DB_DRIVER, and prints a report of what was
+// imported. The events produced by the import are removed from the outbox unless --keep-events is set, so that the
+// webhooks are not sent the history of the other instance.
+func Import(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the dump: jsonl or csv. Defaults to csv for .csv files and jsonl otherwise")
+	dryRun := flags.Bool("dry-run", false, "validate the dump and report what would be imported without storing anything")
+	conflicts := flags.String("on-conflict", string(enum.ConflictFail), "policy for the accounts that already exist: fail, skip or remap")
+	keepEvents := flags.Bool("keep-events", false, "keep the events of the imported records in the outbox to deliver them to the webhooks")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: import [flags] <file>, or - for the standard input")
+	}
+	input := flags.Arg(0)
+	if *format == "" {
+		*format = string(enum.JSONLinesFormat)
+		if strings.EqualFold(filepath.Ext(input), ".csv") {
+			*format = string(enum.CSVFormat)
+		}
+	}
+
+	logger, database, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	if closer, ok := database.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if !*dryRun && !persistent() {
+		return fmt.Errorf("database driver '%s' keeps its data in memory, the import would be lost", conf.GlobalConfig.DBDriver)
+	}
+
+	r := io.Reader(os.Stdin)
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ibans, err := iban.NewGenerator(conf.GlobalConfig.IBANCountryCode, conf.GlobalConfig.IBANBankCode)
+	if err != nil {
+		return err
+	}
+	report, err := dump.NewDumper(logger, database).Import(context.Background(), r, dump.Options{
+		Format:     enum.DumpFormat(*format),
+		Conflicts:  enum.ConflictPolicy(*conflicts),
+		DryRun:     *dryRun,
+		KeepEvents: *keepEvents,
+		IBANs:      ibans,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// openDatabase sets up the configuration and opens the database. The logger writes to the standard error, which
+// leaves the standard output to the dumps and the reports.
+func openDatabase() (*zap.SugaredLogger, db.DatabaseAdapter, error) {
+	// Setup the configuration
+	if err := conf.SetupConfig(); err != nil {
+		return nil, nil, err
+	}
+
+	// Setup the logger
+	logger, err := newZapLogger(os.Stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database, err := db.NewDatabaseAdapter(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return logger, database, nil
+}
+
+// persistent reports whether the configured database keeps its data after the process exits.
+func persistent() bool {
+	switch conf.GlobalConfig.DBDriver {
+	case enum.EventStoreDriver:
+		return conf.GlobalConfig.EventStoreDir != ""
+	case enum.SQLiteDriver, enum.BoltDriver:
+		return true
+	default:
+		return conf.GlobalConfig.DataDir != ""
+	}
+}
@@ -3,6 +3,7 @@ package bootstrap
 import (
 	"bank_test/internal/conf"
 	"bank_test/internal/enum"
+	"io"
 	"os"
 
 	"go.uber.org/zap"
@@ -11,6 +12,11 @@ import (
 
 // NewZapLogger creates a new zap logger with the specified log level.
 func NewZapLogger() (*zap.SugaredLogger, error) {
+	return newZapLogger(os.Stdout)
+}
+
+// newZapLogger creates a new zap logger with the specified log level that writes to w.
+func newZapLogger(w io.Writer) (*zap.SugaredLogger, error) {
 	pe := zap.NewProductionEncoderConfig()
 
 	pe.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -22,7 +28,7 @@ func NewZapLogger() (*zap.SugaredLogger, error) {
 	}
 
 	core := zapcore.NewTee(
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level),
+		zapcore.NewCore(consoleEncoder, zapcore.AddSync(w), level),
 	)
 
 	return zap.New(core, zap.AddCaller()).Sugar(), nil
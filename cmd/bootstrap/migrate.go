@@ -0,0 +1,39 @@
+package bootstrap
+
+import (
+	"bank_test/internal/conf"
+	"bank_test/internal/db/sqlite"
+	"bank_test/internal/enum"
+	"fmt"
+)
+
+// Migrate applies the pending migrations of the SQL database configured with DB_DRIVER.
+func Migrate() error {
+	// Setup the configuration
+	if err := conf.SetupConfig(); err != nil {
+		return err
+	}
+
+	// Setup the logger
+	logger, err := NewZapLogger()
+	if err != nil {
+		return err
+	}
+
+	if conf.GlobalConfig.DBDriver != enum.SQLiteDriver {
+		return fmt.Errorf("database driver '%s' does not support migrations", conf.GlobalConfig.DBDriver)
+	}
+
+	db, err := sqlite.Open(conf.GlobalConfig.SQLitePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := sqlite.Migrate(logger, db)
+	if err != nil {
+		return err
+	}
+	logger.Infof("%d migrations applied to '%s'", applied, conf.GlobalConfig.SQLitePath)
+	return nil
+}
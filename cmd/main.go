@@ -3,10 +3,31 @@ package main
 import (
 	"bank_test/cmd/bootstrap"
 	"log"
+	"os"
 )
 
 func main() {
-	if err := bootstrap.Run(); err != nil {
+	// The API is started when no command is given
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = bootstrap.Run()
+	case "migrate":
+		err = bootstrap.Migrate()
+	case "export":
+		err = bootstrap.Export(os.Args[2:])
+	case "import":
+		err = bootstrap.Import(os.Args[2:])
+	default:
+		log.Fatalf("Error: unknown command '%s'. Available commands: serve, migrate, export, import", command)
+	}
+
+	if err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 }
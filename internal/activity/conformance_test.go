@@ -0,0 +1,19 @@
+package activity_test
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/memory"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestPublishingDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		logger := zap.NewNop().Sugar()
+		return activity.NewDatabase(logger, memory.NewInMemoryDatabase(logger), activity.NewFeed(logger, 16))
+	}})
+}
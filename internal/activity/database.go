@@ -0,0 +1,126 @@
+package activity
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// publishingDatabase is a database adapter that publishes the committed mutations of the underlying adapter to the feed.
+// Every mutation holds the lock of its accounts until it is published, so that the events of an account are published
+// in the order of its mutations and every balance event carries the balance left by its mutation.
+type publishingDatabase struct {
+	db.Wrapper
+
+	logger *zap.SugaredLogger
+	feed   *Feed
+}
+
+// NewDatabase wraps the database adapter so that every committed mutation is published to the feed: a transaction
+// event for every transaction, followed by a balance event with the balance of its account. Mutations that fail are
+// not published.
+func NewDatabase(logger *zap.SugaredLogger, database db.DatabaseAdapter, feed *Feed) db.DatabaseAdapter {
+	return &publishingDatabase{Wrapper: db.NewWrapper(database), logger: logger, feed: feed}
+}
+
+// CreateAccount creates the account in the underlying database and publishes its initial balance.
+func (d *publishingDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	ctx, unlock, err := d.Lock(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := d.DatabaseAdapter.CreateAccount(ctx, account); err != nil {
+		return err
+	}
+	d.publish(ctx, account.ID, nil)
+	return nil
+}
+
+// CreateTransaction creates the transaction in the underlying database and publishes it with the new balance of its
+// account.
+func (d *publishingDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, transaction.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := d.DatabaseAdapter.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	d.publish(ctx, transaction.AccountID, transaction)
+	return nil
+}
+
+// Transfer stores both legs of the transfer in the underlying database and publishes each of them with the new
+// balance of its account.
+func (d *publishingDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, withdrawal.AccountID, deposit.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := d.DatabaseAdapter.Transfer(ctx, withdrawal, deposit); err != nil {
+		return err
+	}
+	d.publish(ctx, withdrawal.AccountID, withdrawal)
+	d.publish(ctx, deposit.AccountID, deposit)
+	return nil
+}
+
+// TransferBatch stores the transfers in the underlying database and publishes their legs, in order, with the new
+// balance of their account.
+func (d *publishingDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	accountIDs := make([]string, 0, 2*len(transfers))
+	for _, t := range transfers {
+		accountIDs = append(accountIDs, t.Withdrawal.AccountID, t.Deposit.AccountID)
+	}
+	ctx, unlock, err := d.Lock(ctx, accountIDs...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := d.DatabaseAdapter.TransferBatch(ctx, transfers); err != nil {
+		return err
+	}
+	for i := range transfers {
+		d.publish(ctx, transfers[i].Withdrawal.AccountID, &transfers[i].Withdrawal)
+		d.publish(ctx, transfers[i].Deposit.AccountID, &transfers[i].Deposit)
+	}
+	return nil
+}
+
+// publish publishes the transaction, if any, and the current balance of the account. The caller holds the lock of the
+// account. The mutation is already committed, so the balance is read without the context of the request, which may be
+// done by now. Failing to publish does not revert the mutation, but it is logged as an error.
+func (d *publishingDatabase) publish(ctx context.Context, accountID string, transaction *models.Transaction) {
+	now := time.Now().UTC()
+	events := make([]Event, 0, 2)
+	if transaction != nil {
+		data, err := json.Marshal(transaction)
+		if err != nil {
+			d.logger.Errorf("failed to publish transaction '%s': %v", transaction.ID, err)
+			return
+		}
+		events = append(events, Event{Type: enum.TransactionActivity, AccountID: accountID, Data: data, Timestamp: now})
+	}
+
+	account, err := d.DatabaseAdapter.GetAccountByID(context.WithoutCancel(ctx), accountID)
+	if err != nil {
+		d.logger.Errorf("failed to read the balance of account '%s' to publish it: %v", accountID, err)
+	} else {
+		data, _ := json.Marshal(Balance{AccountID: accountID, Balance: account.Balance})
+		events = append(events, Event{Type: enum.BalanceActivity, AccountID: accountID, Data: data, Timestamp: now})
+	}
+
+	d.feed.Publish(events...)
+}
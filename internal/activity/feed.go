@@ -0,0 +1,172 @@
+package activity
+
+import (
+	"bank_test/internal/enum"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// subscriberBuffer is the number of events that can wait to be read by a subscriber before it is dropped.
+const subscriberBuffer = 64
+
+// Event is a change of an account pushed to the subscribers of its activity.
+type Event struct {
+	ID        uint64            `json:"id"` // position of the event in the feed. Subscribers resume from it
+	Type      enum.ActivityType `json:"type"`
+	AccountID string            `json:"account_id"`
+	Data      json.RawMessage   `json:"data"` // the transaction or the balance, depending on the type
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Balance is the data of a balance event.
+type Balance struct {
+	AccountID string  `json:"account_id"`
+	Balance   float64 `json:"balance"`
+}
+
+// history keeps the latest events of an account in a ring buffer.
+type history struct {
+	events  []Event
+	next    int    // position of the next event in the ring
+	evicted uint64 // id of the newest event that was evicted from the ring
+}
+
+// Subscription receives the events of an account as they are published.
+type Subscription struct {
+	Backlog []Event      // buffered events published after the one the subscription resumes from, oldest first
+	Reset   bool         // whether the events after the one the subscription resumes from are no longer buffered
+	Events  <-chan Event // new events. It is closed when the subscription is cancelled or falls behind
+
+	feed      *Feed
+	accountID string
+	ch        chan Event
+}
+
+// Feed keeps the latest events of every account in memory and pushes the new ones to their subscribers. Event ids
+// increase monotonically across all accounts, so subscribers can resume from the last event they received as long as
+// it is still buffered.
+type Feed struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+
+	size        int    // number of events buffered per account
+	seq         uint64 // id of the last published event
+	histories   map[string]*history
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+// NewFeed creates a new feed that buffers the latest size events of every account.
+func NewFeed(logger *zap.SugaredLogger, size int) *Feed {
+	if size < 1 {
+		size = 1
+	}
+	return &Feed{
+		logger:      logger,
+		size:        size,
+		histories:   make(map[string]*history),
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns an id to every event, buffers them and pushes them to the subscribers of their accounts. Subscribers
+// that cannot keep up are dropped, so publishing never blocks; they can resume from the buffer.
+func (f *Feed) Publish(events ...Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, e := range events {
+		f.seq++
+		e.ID = f.seq
+		f.store(e)
+
+		for sub := range f.subscribers[e.AccountID] {
+			select {
+			case sub.ch <- e:
+			default:
+				f.logger.Warnf("dropping slow subscriber of account '%s'", e.AccountID)
+				f.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe subscribes to the events of the account. If lastID is not zero, the buffered events published after it
+// are returned in the backlog. If some of them were already evicted, or lastID belongs to a previous run of the feed,
+// the subscription is marked as Reset instead and the subscriber must reload the state of the account. No event is
+// lost between the backlog and the channel.
+func (f *Feed) Subscribe(accountID string, lastID uint64) *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: ch, feed: f, accountID: accountID, ch: ch}
+
+	if lastID != 0 {
+		h := f.histories[accountID]
+		switch {
+		case lastID > f.seq || (h != nil && lastID < h.evicted):
+			sub.Reset = true
+		case h != nil:
+			for _, e := range h.ordered() {
+				if e.ID > lastID {
+					sub.Backlog = append(sub.Backlog, e)
+				}
+			}
+		}
+	}
+
+	if f.subscribers[accountID] == nil {
+		f.subscribers[accountID] = make(map[*Subscription]struct{})
+	}
+	f.subscribers[accountID][sub] = struct{}{}
+	return sub
+}
+
+// Cancel stops the subscription and closes its channel. It can be called more than once.
+func (s *Subscription) Cancel() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.feed.remove(s)
+}
+
+// store appends the event to the history of its account, evicting the oldest one if the buffer is full. It must be
+// called with the lock held.
+func (f *Feed) store(e Event) {
+	h, ok := f.histories[e.AccountID]
+	if !ok {
+		h = &history{events: make([]Event, 0, f.size)}
+		f.histories[e.AccountID] = h
+	}
+
+	if len(h.events) < f.size {
+		h.events = append(h.events, e)
+		return
+	}
+	h.evicted = h.events[h.next].ID
+	h.events[h.next] = e
+	h.next = (h.next + 1) % f.size
+}
+
+// remove unregisters the subscription and closes its channel, if it is still registered. It must be called with the
+// lock held.
+func (f *Feed) remove(sub *Subscription) {
+	subs := f.subscribers[sub.accountID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(f.subscribers, sub.accountID)
+	}
+	close(sub.ch)
+}
+
+// ordered returns the events of the history, oldest first.
+func (h *history) ordered() []Event {
+	events := make([]Event, 0, len(h.events))
+	events = append(events, h.events[h.next:]...)
+	return append(events, h.events[:h.next]...)
+}
@@ -0,0 +1,205 @@
+package activity
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type FeedTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	ctx    context.Context
+
+	feed *Feed
+	db   db.DatabaseAdapter
+}
+
+func (s *FeedTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.ctx = context.Background()
+	s.feed = NewFeed(s.logger, 4)
+	s.db = NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), s.feed)
+}
+
+// createAccount creates an account with the given balance and returns its id.
+func (s *FeedTestSuite) createAccount(balance float64) string {
+	id := uuid.NewString()
+	s.Require().NoError(s.db.CreateAccount(s.ctx, &models.Account{ID: id, Owner: "Alice", Balance: balance, InitialBalance: balance}))
+	return id
+}
+
+// createTransaction stores a transaction of the account.
+func (s *FeedTestSuite) createTransaction(accountID string, txType enum.TransactionType, amount float64) error {
+	return s.db.CreateTransaction(s.ctx, &models.Transaction{ID: uuid.NewString(), AccountID: accountID, Type: txType, Amount: amount, Timestamp: time.Now()})
+}
+
+// receive reads the next event of the subscription.
+func (s *FeedTestSuite) receive(sub *Subscription) Event {
+	select {
+	case e, ok := <-sub.Events:
+		s.Require().True(ok, "subscription closed")
+		return e
+	case <-time.After(time.Second):
+		s.FailNow("no event received")
+		return Event{}
+	}
+}
+
+// balanceOf decodes the balance carried by a balance event.
+func (s *FeedTestSuite) balanceOf(e Event) float64 {
+	s.Require().Equal(enum.BalanceActivity, e.Type)
+	var b Balance
+	s.Require().NoError(json.Unmarshal(e.Data, &b))
+	return b.Balance
+}
+
+// TestPublish tests that committed transactions are published with the new balance of their account, and that
+// rejected ones are not.
+func (s *FeedTestSuite) TestPublish() {
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+	sub := s.feed.Subscribe(from, 0)
+	defer sub.Cancel()
+
+	s.Require().NoError(s.createTransaction(from, enum.Deposit, 50))
+	e := s.receive(sub)
+	s.Equal(enum.TransactionActivity, e.Type)
+	s.Equal(from, e.AccountID)
+	var tx models.Transaction
+	s.Require().NoError(json.Unmarshal(e.Data, &tx))
+	s.Equal(50.0, tx.Amount)
+	s.Equal(150.0, s.balanceOf(s.receive(sub)))
+
+	s.Equal(errors.ErrInsufficientBalance, s.createTransaction(from, enum.Withdrawal, 1000))
+
+	s.Require().NoError(s.db.Transfer(s.ctx,
+		&models.Transaction{ID: uuid.NewString(), AccountID: from, Type: enum.Withdrawal, Amount: 30, Timestamp: time.Now()},
+		&models.Transaction{ID: uuid.NewString(), AccountID: to, Type: enum.Deposit, Amount: 30, Timestamp: time.Now()},
+	))
+	s.Equal(enum.TransactionActivity, s.receive(sub).Type)
+	s.Equal(120.0, s.balanceOf(s.receive(sub)))
+	s.Empty(sub.Events)
+}
+
+// blockingDatabase is a database adapter whose reads of an account wait until they are released.
+type blockingDatabase struct {
+	db.DatabaseAdapter
+	blocked  string
+	started  chan struct{}
+	released chan struct{}
+}
+
+// GetAccountByID waits until the read is released when the account is the blocked one.
+func (d *blockingDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	if id == d.blocked {
+		d.started <- struct{}{}
+		<-d.released
+	}
+	return d.DatabaseAdapter.GetAccountByID(ctx, id)
+}
+
+// TestConcurrentPublish tests that publishing the balance of an account does not wait for the mutations of the
+// other accounts.
+func (s *FeedTestSuite) TestConcurrentPublish() {
+	blocked, other := "blocked", "other" // in different lock stripes
+	blocking := &blockingDatabase{DatabaseAdapter: memory.NewInMemoryDatabase(s.logger), blocked: blocked, started: make(chan struct{}), released: make(chan struct{})}
+	for _, id := range []string{blocked, other} {
+		s.Require().NoError(blocking.CreateAccount(s.ctx, &models.Account{ID: id, Owner: "Alice"}))
+	}
+	database := NewDatabase(s.logger, blocking, s.feed)
+	sub := s.feed.Subscribe(other, 0)
+	defer sub.Cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- database.CreateTransaction(s.ctx, &models.Transaction{ID: uuid.NewString(), AccountID: blocked, Type: enum.Deposit, Amount: 10, Timestamp: time.Now()})
+	}()
+	<-blocking.started
+	defer func() {
+		close(blocking.released)
+		s.NoError(<-done)
+	}()
+
+	s.Require().NoError(database.CreateTransaction(s.ctx, &models.Transaction{ID: uuid.NewString(), AccountID: other, Type: enum.Deposit, Amount: 10, Timestamp: time.Now()}))
+	s.Equal(enum.TransactionActivity, s.receive(sub).Type)
+	s.Equal(10.0, s.balanceOf(s.receive(sub)))
+}
+
+// TestResume tests that subscribers resume from the buffered events after the last one they received, and that they
+// are reset when those events are no longer buffered.
+func (s *FeedTestSuite) TestResume() {
+	id := s.createAccount(0) // publishes the initial balance
+	s.Require().NoError(s.createTransaction(id, enum.Deposit, 10))
+
+	s.Run("ok: resume from the buffer", func() {
+		sub := s.feed.Subscribe(id, 1)
+		defer sub.Cancel()
+		s.False(sub.Reset)
+		s.Require().Len(sub.Backlog, 2)
+		s.Equal(uint64(2), sub.Backlog[0].ID)
+		s.Equal(10.0, s.balanceOf(sub.Backlog[1]))
+	})
+
+	s.Run("ok: nothing missed", func() {
+		sub := s.feed.Subscribe(id, 3)
+		defer sub.Cancel()
+		s.False(sub.Reset)
+		s.Empty(sub.Backlog)
+	})
+
+	s.Run("ok: events of other accounts are not replayed", func() {
+		other := s.createAccount(0)
+		sub := s.feed.Subscribe(other, 1)
+		defer sub.Cancel()
+		s.False(sub.Reset)
+		s.Require().Len(sub.Backlog, 1)
+		s.Equal(other, sub.Backlog[0].AccountID)
+	})
+
+	s.Run("not ok: evicted events", func() {
+		s.Require().NoError(s.createTransaction(id, enum.Deposit, 10))
+		s.Require().NoError(s.createTransaction(id, enum.Deposit, 10))
+		sub := s.feed.Subscribe(id, 1)
+		defer sub.Cancel()
+		s.True(sub.Reset)
+		s.Empty(sub.Backlog)
+	})
+
+	s.Run("not ok: event of a previous run", func() {
+		sub := s.feed.Subscribe(id, 1000)
+		defer sub.Cancel()
+		s.True(sub.Reset)
+	})
+}
+
+// TestSlowSubscriber tests that subscribers that fall behind are dropped instead of blocking the publishers.
+func (s *FeedTestSuite) TestSlowSubscriber() {
+	id := uuid.NewString()
+	sub := s.feed.Subscribe(id, 0)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		s.feed.Publish(Event{Type: enum.BalanceActivity, AccountID: id})
+	}
+	for range sub.Events {
+	}
+	s.Empty(s.feed.subscribers)
+
+	// cancelling a dropped subscription is harmless
+	sub.Cancel()
+}
+
+func TestFeedTestSuite(t *testing.T) {
+	suite.Run(t, new(FeedTestSuite))
+}
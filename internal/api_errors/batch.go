@@ -0,0 +1,20 @@
+package errors
+
+import "fmt"
+
+// BatchError is returned when a transfer of a batch fails, in which case none of the transfers of the batch is stored.
+// It wraps the error of the transfer, so that it can still be compared with the API errors declared here.
+type BatchError struct {
+	Index int   // position of the failed transfer in the batch
+	Err   error // error of the failed transfer
+}
+
+// Error returns the message of the error of the transfer, prefixed with its position.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("transfer %d of the batch: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the failed transfer.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
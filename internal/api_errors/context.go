@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+)
+
+// statusClientClosedRequest is the non-standard status used when the client closes the connection before the
+// response is sent. It is never received by the client, but it is recorded in the logs.
+const statusClientClosedRequest = 499
+
+// FromContext translates the error of a context that is done into an API error. Other errors are returned unchanged.
+func FromContext(err error) error {
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case stderrors.Is(err, context.Canceled):
+		return ErrRequestCanceled
+	default:
+		return err
+	}
+}
@@ -23,6 +23,13 @@ func (e *APIError) Error() string {
 	return e.Message
 }
 
+// Is reports whether the target is an APIError with the same code, so that errors decoded from a response can be
+// compared with the ones declared here.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
 var (
 
 	// ErrInvalidBody is returned when the request body is invalid.
@@ -37,12 +44,97 @@ var (
 	// ErrInvalidAccountID is returned when an account id is invalid.
 	ErrInvalidAccountID = NewAPIError("INVALID_ACCOUNT_ID", "invalid account id. Must be UUID format", http.StatusBadRequest)
 
+	// ErrInvalidIBAN is returned when an account number is not a valid IBAN.
+	ErrInvalidIBAN = NewAPIError("INVALID_IBAN", "invalid account number. Must be a valid IBAN", http.StatusBadRequest)
+
 	// ErrInsufficientBalance is returned when an account has insufficient balance.
 	ErrInsufficientBalance = NewAPIError("INSUFFICIENT_BALANCE", "insufficient balance", http.StatusBadRequest)
 
 	// ErrInvalidAmount is returned when an amount is invalid.
 	INVALID_AMOUNT = NewAPIError("INVALID_AMOUNT", "invalid amount", http.StatusBadRequest)
 
+	// ErrReportNotFound is returned when a reconciliation report is not found.
+	ErrReportNotFound = NewAPIError("REPORT_NOT_FOUND", "reconciliation report not found", http.StatusNotFound)
+
+	// ErrInvalidTimeRange is returned when a time filter is not in RFC3339 format.
+	ErrInvalidTimeRange = NewAPIError("INVALID_TIME_RANGE", "invalid time range. Must be RFC3339 format", http.StatusBadRequest)
+
+	// ErrBackupNotSupported is returned when the database does not support online backups.
+	ErrBackupNotSupported = NewAPIError("BACKUP_NOT_SUPPORTED", "the database does not support online backups", http.StatusNotImplemented)
+
+	// ErrOutboxNotSupported is returned when the database does not store the events of its mutations in an outbox.
+	ErrOutboxNotSupported = NewAPIError("OUTBOX_NOT_SUPPORTED", "the database does not support the outbox", http.StatusNotImplemented)
+
+	// ErrSubscriptionNotFound is returned when a webhook subscription is not found.
+	ErrSubscriptionNotFound = NewAPIError("SUBSCRIPTION_NOT_FOUND", "webhook subscription not found", http.StatusNotFound)
+
+	// ErrDeadLetterNotFound is returned when a failed webhook delivery is not found in the dead-letter queue.
+	ErrDeadLetterNotFound = NewAPIError("DEAD_LETTER_NOT_FOUND", "dead letter not found", http.StatusNotFound)
+
+	// ErrInvalidLastEventID is returned when the Last-Event-ID header of an event stream is not an event id.
+	ErrInvalidLastEventID = NewAPIError("INVALID_LAST_EVENT_ID", "invalid Last-Event-ID header. Must be a positive integer", http.StatusBadRequest)
+
+	// ErrStreamingNotSupported is returned when the connection cannot stream events to the client.
+	ErrStreamingNotSupported = NewAPIError("STREAMING_NOT_SUPPORTED", "the connection does not support streaming", http.StatusInternalServerError)
+
+	// ErrInvalidCursor is returned when a pagination cursor was not returned by the API or its item no longer exists.
+	ErrInvalidCursor = NewAPIError("INVALID_CURSOR", "invalid pagination cursor", http.StatusBadRequest)
+
+	// ErrInvalidPageSize is returned when the number of items requested in a page is out of range.
+	ErrInvalidPageSize = NewAPIError("INVALID_PAGE_SIZE", "invalid page size. Must be between 0 and 100", http.StatusBadRequest)
+
+	// ErrInvalidIdempotencyKey is returned when an idempotency key is too long.
+	ErrInvalidIdempotencyKey = NewAPIError("INVALID_IDEMPOTENCY_KEY", "invalid idempotency key. Must be at most 255 characters", http.StatusBadRequest)
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key is sent with a request different from the first one that used it.
+	ErrIdempotencyKeyReused = NewAPIError("IDEMPOTENCY_KEY_REUSED", "the idempotency key was already used for a different request", http.StatusUnprocessableEntity)
+
+	// ErrIdempotencyKeyInUse is returned when an idempotency key is sent while the first request that used it is still being processed.
+	ErrIdempotencyKeyInUse = NewAPIError("IDEMPOTENCY_KEY_IN_USE", "a request with the same idempotency key is being processed", http.StatusConflict)
+
+	// ErrTimeout is returned when a request is not processed before its deadline.
+	ErrTimeout = NewAPIError("TIMEOUT", "the request took too long to be processed", http.StatusGatewayTimeout)
+
+	// ErrRequestCanceled is returned when the client cancels a request before it is processed.
+	ErrRequestCanceled = NewAPIError("REQUEST_CANCELED", "the request was canceled by the client", statusClientClosedRequest)
+
+	// ErrInvalidStatementFormat is returned when a statement is requested in a format that is not supported.
+	ErrInvalidStatementFormat = NewAPIError("INVALID_STATEMENT_FORMAT", "invalid statement format. Must be csv, ofx or camt053", http.StatusBadRequest)
+
+	// ErrInvalidPaymentDocument is returned when a payment file is not a pain.001 document.
+	ErrInvalidPaymentDocument = NewAPIError("INVALID_PAYMENT_DOCUMENT", "invalid payment document. Must be an ISO 20022 pain.001 document", http.StatusBadRequest)
+
+	// ErrInvalidExecutionMode is returned when the credit transfers of a payment file are requested to be executed in a mode that is not supported.
+	ErrInvalidExecutionMode = NewAPIError("INVALID_EXECUTION_MODE", "invalid execution mode. Must be atomic or per_item", http.StatusBadRequest)
+
+	// ErrUnsupportedAPIVersion is returned when the Accept header of a request asks for a version of the API that does not exist.
+	ErrUnsupportedAPIVersion = NewAPIError("UNSUPPORTED_API_VERSION", "unsupported api version. Must be v1 or v2", http.StatusNotAcceptable)
+
+	// ErrUnauthenticated is returned when authentication is enabled and a request is sent without a valid api key or jwt.
+	ErrUnauthenticated = NewAPIError("UNAUTHENTICATED", "missing, invalid or revoked credentials. Send an api key or a jwt in an 'Authorization: Bearer' header", http.StatusUnauthorized)
+
+	// ErrInsufficientScope is returned when the caller of a request is not granted the scope of the operation.
+	ErrInsufficientScope = NewAPIError("INSUFFICIENT_SCOPE", "the caller is not granted the scope of the operation", http.StatusForbidden)
+
+	// ErrForbidden is returned when the role of the caller of a request is not allowed to perform the operation.
+	ErrForbidden = NewAPIError("FORBIDDEN", "the role of the caller is not allowed to perform the operation", http.StatusForbidden)
+
+	// ErrAccountAccessDenied is returned when a customer accesses an account that belongs to another owner.
+	ErrAccountAccessDenied = NewAPIError("ACCOUNT_ACCESS_DENIED", "the account belongs to another owner", http.StatusForbidden)
+
+	// ErrAPIKeyNotFound is returned when an api key does not exist.
+	ErrAPIKeyNotFound = NewAPIError("API_KEY_NOT_FOUND", "api key not found", http.StatusNotFound)
+
+	// ErrAccountFrozen is returned when money is moved into or out of a frozen account.
+	ErrAccountFrozen = NewAPIError("ACCOUNT_FROZEN", "the account is frozen", http.StatusConflict)
+
+	// ErrFreezeNotSupported is returned when the database is not wrapped with the store of the frozen accounts.
+	ErrFreezeNotSupported = NewAPIError("FREEZE_NOT_SUPPORTED", "the database does not support freezing accounts", http.StatusNotImplemented)
+
+	// ErrSpecViolation is returned when the validation of the API against its OpenAPI specification is enabled, and a
+	// request is accepted or answered in a way that the specification does not document.
+	ErrSpecViolation = NewAPIError("SPEC_VIOLATION", "the request or the response does not match the OpenAPI specification", http.StatusInternalServerError)
+
 	// ErrUkwnown is returned when an unknown error occurs.
 	ErrUnknown = NewAPIError("UNKNOWN", "unknown error", http.StatusInternalServerError)
 )
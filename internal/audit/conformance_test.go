@@ -0,0 +1,23 @@
+package audit_test
+
+import (
+	"bank_test/internal/audit"
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/memory"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestAuditedDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		logger := zap.NewNop().Sugar()
+		log, err := audit.NewLog(logger, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return audit.NewDatabase(logger, memory.NewInMemoryDatabase(logger), log)
+	}})
+}
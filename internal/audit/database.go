@@ -0,0 +1,218 @@
+package audit
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Anonymous is the actor recorded when the caller could not be identified.
+const Anonymous = "anonymous"
+
+// Metadata holds the request-scoped information recorded with every mutation.
+type Metadata struct {
+	Actor     string
+	RequestID string
+}
+
+// metadataKey is the key of the metadata in the context.
+type metadataKey struct{}
+
+// WithMetadata returns a copy of the context that carries the metadata recorded with every audited mutation.
+func WithMetadata(ctx context.Context, meta Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, meta)
+}
+
+// MetadataFrom returns the metadata carried by the context. The actor is Anonymous if it is not set.
+func MetadataFrom(ctx context.Context) Metadata {
+	meta, _ := ctx.Value(metadataKey{}).(Metadata)
+	if meta.Actor == "" {
+		meta.Actor = Anonymous
+	}
+	return meta
+}
+
+// auditedDatabase is a database adapter that records every mutation performed on the underlying adapter in the audit log.
+// The mutations of every account hold its lock, so that the state recorded before and after a mutation is not affected
+// by concurrent mutations on the same account. Backups and the outbox do not modify the ledger, so they are not audited.
+type auditedDatabase struct {
+	db.Wrapper
+
+	logger *zap.SugaredLogger
+	log    *Log
+}
+
+// NewDatabase wraps the database adapter so that every mutation is audited. The actor and the request id of every
+// record are taken from the metadata carried by the context of the mutation.
+func NewDatabase(logger *zap.SugaredLogger, database db.DatabaseAdapter, log *Log) db.DatabaseAdapter {
+	return &auditedDatabase{Wrapper: db.NewWrapper(database), logger: logger, log: log}
+}
+
+// CreateAccount creates the account in the underlying database and records it in the audit log.
+func (d *auditedDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	ctx, unlock, err := d.Lock(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = d.DatabaseAdapter.CreateAccount(ctx, account)
+	after, _ := d.DatabaseAdapter.GetAccountByID(ctx, account.ID)
+
+	d.record(ctx, CreateAccount, account.ID, account, nil, after, err)
+	return err
+}
+
+// CreateTransaction creates the transaction in the underlying database and records it in the audit log
+// together with the state of the account before and after the transaction.
+func (d *auditedDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, transaction.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, _ := d.DatabaseAdapter.GetAccountByID(ctx, transaction.AccountID)
+	err = d.DatabaseAdapter.CreateTransaction(ctx, transaction)
+	after, _ := d.DatabaseAdapter.GetAccountByID(ctx, transaction.AccountID)
+
+	d.record(ctx, CreateTransaction, transaction.AccountID, transaction, before, after, err)
+	return err
+}
+
+// Transfer stores both legs of the transfer in the underlying database and records each of them in the audit log
+// together with the state of its account before and after the transfer.
+func (d *auditedDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, withdrawal.AccountID, deposit.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fromBefore, _ := d.DatabaseAdapter.GetAccountByID(ctx, withdrawal.AccountID)
+	toBefore, _ := d.DatabaseAdapter.GetAccountByID(ctx, deposit.AccountID)
+	err = d.DatabaseAdapter.Transfer(ctx, withdrawal, deposit)
+	fromAfter, _ := d.DatabaseAdapter.GetAccountByID(ctx, withdrawal.AccountID)
+	toAfter, _ := d.DatabaseAdapter.GetAccountByID(ctx, deposit.AccountID)
+
+	d.record(ctx, CreateTransaction, withdrawal.AccountID, withdrawal, fromBefore, fromAfter, err)
+	d.record(ctx, CreateTransaction, deposit.AccountID, deposit, toBefore, toAfter, err)
+	return err
+}
+
+// TransferBatch stores the transfers in the underlying database and records every leg in the audit log together with
+// the state of its account before and after it. The batch is stored at once, so the states between its legs are
+// derived from the states read before the batch.
+func (d *auditedDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	ids := make([]string, 0, 2*len(transfers))
+	for _, t := range transfers {
+		ids = append(ids, t.Withdrawal.AccountID, t.Deposit.AccountID)
+	}
+	ctx, unlock, err := d.Lock(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accounts := make(map[string]*models.Account, len(ids))
+	for _, id := range ids {
+		if _, ok := accounts[id]; !ok {
+			accounts[id], _ = d.DatabaseAdapter.GetAccountByID(ctx, id)
+		}
+	}
+	err = d.DatabaseAdapter.TransferBatch(ctx, transfers)
+
+	for i := range transfers {
+		for _, leg := range []*models.Transaction{&transfers[i].Withdrawal, &transfers[i].Deposit} {
+			before, after := accounts[leg.AccountID], accounts[leg.AccountID]
+			if err == nil && before != nil {
+				next := *before
+				if leg.Type == enum.Withdrawal {
+					next.Balance -= leg.Amount
+				} else {
+					next.Balance += leg.Amount
+				}
+				after = &next
+				accounts[leg.AccountID] = after
+			}
+			d.record(ctx, CreateTransaction, leg.AccountID, leg, before, after, err)
+		}
+	}
+	return err
+}
+
+// FreezeAccount freezes the account in the underlying database and records it in the audit log together with the
+// state of the account before and after it is frozen.
+func (d *auditedDatabase) FreezeAccount(ctx context.Context, id string) error {
+	return d.freeze(ctx, FreezeAccount, id, func(ctx context.Context, freezer db.Freezer) error {
+		return freezer.FreezeAccount(ctx, id)
+	})
+}
+
+// UnfreezeAccount unfreezes the account in the underlying database and records it in the audit log together with the
+// state of the account before and after it is unfrozen.
+func (d *auditedDatabase) UnfreezeAccount(ctx context.Context, id string) error {
+	return d.freeze(ctx, UnfreezeAccount, id, func(ctx context.Context, freezer db.Freezer) error {
+		return freezer.UnfreezeAccount(ctx, id)
+	})
+}
+
+// freeze performs the freeze or unfreeze of the account on the underlying database and records it. The account is
+// locked, so that no transaction is recorded between the state before and after.
+func (d *auditedDatabase) freeze(ctx context.Context, action Action, id string, fn func(context.Context, db.Freezer) error) error {
+	freezer, ok := d.DatabaseAdapter.(db.Freezer)
+	if !ok {
+		return errors.ErrFreezeNotSupported
+	}
+	ctx, unlock, err := d.Lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, _ := d.DatabaseAdapter.GetAccountByID(ctx, id)
+	err = fn(ctx, freezer)
+	after, _ := d.DatabaseAdapter.GetAccountByID(ctx, id)
+
+	d.record(ctx, action, id, nil, before, after, err)
+	return err
+}
+
+// record appends a new record to the audit log. Failing to audit a mutation does not revert it, but it is logged as an error.
+func (d *auditedDatabase) record(ctx context.Context, action Action, entityID string, payload any, before *models.Account, after *models.Account, opErr error) {
+	meta := MetadataFrom(ctx)
+	record := Record{
+		Timestamp: time.Now().UTC(),
+		Actor:     meta.Actor,
+		RequestID: meta.RequestID,
+		Action:    action,
+		EntityID:  entityID,
+		Payload:   marshal(payload),
+		Before:    marshal(before),
+		After:     marshal(after),
+		Outcome:   Success,
+	}
+	if opErr != nil {
+		record.Outcome = Failure
+		record.Error = opErr.Error()
+	}
+
+	if _, err := d.log.Append(record); err != nil {
+		d.logger.Errorf("failed to audit %s on '%s': %v", action, entityID, err)
+	}
+}
+
+// marshal encodes v as JSON. Nil pointers are encoded as null.
+func marshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
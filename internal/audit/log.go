@@ -0,0 +1,264 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// genesisHash is the previous hash of the first record of the chain.
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Action is the type of mutation that has been audited.
+type Action string
+
+const (
+	// CreateAccount is the action recorded when an account is created.
+	CreateAccount Action = "account.create"
+
+	// CreateTransaction is the action recorded when a transaction is created.
+	CreateTransaction Action = "transaction.create"
+
+	// FreezeAccount is the action recorded when an account is frozen.
+	FreezeAccount Action = "account.freeze"
+
+	// UnfreezeAccount is the action recorded when an account is unfrozen.
+	UnfreezeAccount Action = "account.unfreeze"
+)
+
+// Outcome is the result of an audited mutation.
+type Outcome string
+
+const (
+	Success Outcome = "success"
+	Failure Outcome = "failure"
+)
+
+// Record is an entry of the audit log. Records are chained by including the hash of the previous record
+// in the hash of the current one, so any modification of the history breaks the chain.
+type Record struct {
+	Sequence  uint64          `json:"sequence"` // position of the record in the chain, starting at 1
+	ID        string          `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Actor     string          `json:"actor"`      // who performed the mutation
+	RequestID string          `json:"request_id"` // request that triggered the mutation
+	Action    Action          `json:"action"`
+	EntityID  string          `json:"entity_id"` // id of the account affected by the mutation
+	Payload   json.RawMessage `json:"payload"`   // input of the mutation
+	Before    json.RawMessage `json:"before"`    // state of the account before the mutation
+	After     json.RawMessage `json:"after"`     // state of the account after the mutation
+	Outcome   Outcome         `json:"outcome"`
+	Error     string          `json:"error,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// computeHash computes the SHA-256 hash of the record. The hash covers every field of the record but the hash itself.
+func (r Record) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Filter defines the criteria used to list audit records. Empty fields are ignored.
+type Filter struct {
+	Actor     string
+	RequestID string
+	Action    Action
+	EntityID  string
+	From      time.Time
+	To        time.Time
+}
+
+// matches checks whether the record matches the filter.
+func (f Filter) matches(r Record) bool {
+	switch {
+	case f.Actor != "" && f.Actor != r.Actor:
+		return false
+	case f.RequestID != "" && f.RequestID != r.RequestID:
+		return false
+	case f.Action != "" && f.Action != r.Action:
+		return false
+	case f.EntityID != "" && f.EntityID != r.EntityID:
+		return false
+	case !f.From.IsZero() && r.Timestamp.Before(f.From):
+		return false
+	case !f.To.IsZero() && r.Timestamp.After(f.To):
+		return false
+	}
+	return true
+}
+
+// Verification is the result of recomputing the hash chain.
+type Verification struct {
+	Valid          bool   `json:"valid"`
+	RecordsChecked int    `json:"records_checked"`
+	BrokenSequence uint64 `json:"broken_sequence,omitempty"` // sequence of the first record whose hash does not match
+	Reason         string `json:"reason,omitempty"`
+}
+
+// Log is an append-only, hash-chained audit log. Records are kept in memory and, optionally, appended to a
+// file in JSON Lines format so that the history survives restarts.
+type Log struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+
+	records []Record
+	file    *os.File
+}
+
+// NewLog creates a new audit log. If path is not empty, the records stored in the file are loaded and every new
+// record is appended to it.
+func NewLog(logger *zap.SugaredLogger, path string) (*Log, error) {
+	l := &Log{logger: logger, records: make([]Record, 0)}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %v", err)
+	}
+	l.file = f
+
+	if v := l.Verify(); !v.Valid {
+		logger.Errorf("audit log loaded from '%s' is broken at record %d: %s", path, v.BrokenSequence, v.Reason)
+	}
+	return l, nil
+}
+
+// Append adds a new record at the end of the chain. The sequence, id, previous hash and hash of the record are set by the log.
+func (l *Log) Append(record Record) (*Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Sequence = uint64(len(l.records)) + 1
+	record.ID = uuid.NewString()
+	record.PrevHash = genesisHash
+	if len(l.records) > 0 {
+		record.PrevHash = l.records[len(l.records)-1].Hash
+	}
+
+	hash, err := record.computeHash()
+	if err != nil {
+		return nil, err
+	}
+	record.Hash = hash
+
+	if l.file != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal audit record: %v", err)
+		}
+		if _, err := l.file.Write(append(data, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write audit record: %v", err)
+		}
+		if err := l.file.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync audit log file: %v", err)
+		}
+	}
+
+	l.records = append(l.records, record)
+	l.logger.Debugf("audit record %d stored: %s %s by %s", record.Sequence, record.Action, record.EntityID, record.Actor)
+	return &record, nil
+}
+
+// List retrieves the records that match the filter in the order they were appended.
+func (l *Log) List(filter Filter) []Record {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	records := make([]Record, 0)
+	for _, r := range l.records {
+		if filter.matches(r) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Verify recomputes the hash chain and reports the first broken link, if any.
+func (l *Log) Verify() Verification {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	prevHash := genesisHash
+	for i, r := range l.records {
+		broken := func(reason string) Verification {
+			return Verification{Valid: false, RecordsChecked: i + 1, BrokenSequence: r.Sequence, Reason: reason}
+		}
+
+		if r.Sequence != uint64(i)+1 {
+			return broken(fmt.Sprintf("expected sequence %d, found %d", i+1, r.Sequence))
+		}
+		if r.PrevHash != prevHash {
+			return broken("previous hash does not match the hash of the previous record")
+		}
+		hash, err := r.computeHash()
+		if err != nil {
+			return broken(err.Error())
+		}
+		if hash != r.Hash {
+			return broken("record hash does not match its content")
+		}
+		prevHash = r.Hash
+	}
+
+	return Verification{Valid: true, RecordsChecked: len(l.records)}
+}
+
+// Close closes the audit log file, if any.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// load reads the records stored in the file. A missing file is not an error.
+func (l *Log) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("failed to decode audit record %d: %v", len(l.records)+1, err)
+		}
+		l.records = append(l.records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log file: %v", err)
+	}
+
+	l.logger.Infof("%d audit records loaded from '%s'", len(l.records), path)
+	return nil
+}
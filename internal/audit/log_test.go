@@ -0,0 +1,147 @@
+package audit
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type auditSuite struct {
+	logger *zap.SugaredLogger
+	log    *Log
+	db     db.DatabaseAdapter
+	suite.Suite
+}
+
+func (s *auditSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+
+	log, err := NewLog(s.logger, "")
+	s.Require().NoError(err)
+
+	s.log = log
+	s.db = NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), s.log)
+}
+
+// populate performs some mutations with different actors.
+func (s *auditSuite) populate() {
+	alice := WithMetadata(context.Background(), Metadata{Actor: "alice", RequestID: "req-1"})
+	s.db.CreateAccount(alice, &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100})
+	s.Require().NoError(s.db.CreateTransaction(alice, &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Withdrawal, Amount: 30}))
+
+	bob := WithMetadata(context.Background(), Metadata{Actor: "bob", RequestID: "req-2"})
+	s.Require().Error(s.db.CreateTransaction(bob, &models.Transaction{ID: "tx2", AccountID: "1", Type: enum.Withdrawal, Amount: 500}))
+}
+
+// TestAudit tests that every mutation is recorded with its metadata and state.
+func (s *auditSuite) TestAudit() {
+	s.populate()
+
+	records := s.log.List(Filter{})
+	s.Require().Len(records, 3)
+
+	s.Equal(CreateAccount, records[0].Action)
+	s.Equal("alice", records[0].Actor)
+	s.Equal("req-1", records[0].RequestID)
+	s.JSONEq("null", string(records[0].Before))
+	s.Equal(genesisHash, records[0].PrevHash)
+
+	var before, after models.Account
+	s.Require().NoError(json.Unmarshal(records[1].Before, &before))
+	s.Require().NoError(json.Unmarshal(records[1].After, &after))
+	s.Equal(CreateTransaction, records[1].Action)
+	s.Equal(float64(100), before.Balance)
+	s.Equal(float64(70), after.Balance)
+	s.Equal(Success, records[1].Outcome)
+	s.Equal(records[0].Hash, records[1].PrevHash)
+
+	s.Equal("bob", records[2].Actor)
+	s.Equal(Failure, records[2].Outcome)
+	s.NotEmpty(records[2].Error)
+
+	s.Run("ok: filters", func() {
+		s.Len(s.log.List(Filter{Actor: "alice"}), 2)
+		s.Len(s.log.List(Filter{RequestID: "req-2"}), 1)
+		s.Len(s.log.List(Filter{Action: CreateAccount}), 1)
+		s.Len(s.log.List(Filter{EntityID: "2"}), 0)
+		s.Contains(s.log.List(Filter{From: records[2].Timestamp}), records[2])
+		s.Empty(s.log.List(Filter{To: records[0].Timestamp.Add(-time.Second)}))
+	})
+}
+
+// TestVerify tests the detection of edited records.
+func (s *auditSuite) TestVerify() {
+	s.populate()
+
+	s.Run("ok: untouched chain", func() {
+		v := s.log.Verify()
+		s.True(v.Valid)
+		s.Equal(3, v.RecordsChecked)
+	})
+
+	s.Run("not ok: edited record", func() {
+		s.log.records[1].Actor = "mallory"
+
+		v := s.log.Verify()
+		s.False(v.Valid)
+		s.Equal(uint64(2), v.BrokenSequence)
+	})
+
+	s.Run("not ok: edited and rehashed record", func() {
+		hash, err := s.log.records[1].computeHash()
+		s.Require().NoError(err)
+		s.log.records[1].Hash = hash
+
+		v := s.log.Verify()
+		s.False(v.Valid)
+		s.Equal(uint64(3), v.BrokenSequence)
+	})
+}
+
+// TestPersistence tests that the chain is restored from the audit log file.
+func (s *auditSuite) TestPersistence() {
+	path := filepath.Join(s.T().TempDir(), "audit.log")
+
+	log, err := NewLog(s.logger, path)
+	s.Require().NoError(err)
+	s.log = log
+	s.db = NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), s.log)
+	s.populate()
+	s.Require().NoError(log.Close())
+
+	restored, err := NewLog(s.logger, path)
+	s.Require().NoError(err)
+	defer restored.Close()
+
+	s.Equal(log.List(Filter{}), restored.List(Filter{}))
+	s.True(restored.Verify().Valid)
+
+	record, err := restored.Append(Record{Actor: "alice", Action: CreateAccount, EntityID: "2"})
+	s.Require().NoError(err)
+	s.Equal(uint64(4), record.Sequence)
+	s.True(restored.Verify().Valid)
+}
+
+// TestBackup tests that backups are forwarded to the underlying database.
+func (s *auditSuite) TestBackup() {
+	backuper, ok := s.db.(db.Backuper)
+	s.Require().True(ok)
+
+	_, err := backuper.Backup(context.Background(), io.Discard)
+	s.Equal(errors.ErrBackupNotSupported, err)
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(auditSuite))
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/conf"
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// credentialsCheckInterval is the interval at which the credentials of the long-lived connections are checked, so that
+// revoked api keys stop being served.
+const credentialsCheckInterval = 10 * time.Second
+
+// Authenticator authenticates the bearer tokens sent to every transport: the api keys, and the jwts of the customers
+// and the staff if a key set is configured. The transports share it, so that a key created or revoked through one of
+// them applies to all.
+type Authenticator struct {
+	keys   *KeyStore
+	tokens *JWTVerifier // nil if jwts are not accepted
+
+	checkInterval time.Duration // interval between two checks of the credentials of a watched principal
+}
+
+// NewAuthenticator creates the authenticator described by the configuration. JWTs are only accepted when
+// authentication is enabled and a key set is configured.
+func NewAuthenticator(logger *zap.SugaredLogger) (*Authenticator, error) {
+	keys, err := NewKeyStore(logger, conf.GlobalConfig.APIKeysPath, conf.GlobalConfig.AdminAPIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{keys: keys, checkInterval: credentialsCheckInterval}
+	if conf.GlobalConfig.AuthEnabled && conf.GlobalConfig.JWKS != "" {
+		a.tokens, err = NewJWTVerifier(logger, conf.GlobalConfig.JWKS, conf.GlobalConfig.JWTIssuer, conf.GlobalConfig.JWTAudience, conf.GlobalConfig.JWTOwnerClaim, conf.GlobalConfig.JWTRoleClaim)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Keys returns the store of the api keys.
+func (a *Authenticator) Keys() *KeyStore {
+	return a.keys
+}
+
+// Authenticate returns the principal of a bearer token: a jwt if jwts are accepted and the token has the three
+// segments of one, and an api key otherwise. Invalid tokens fail with ErrUnauthenticated.
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if a.tokens != nil && strings.Count(token, ".") == 2 {
+		return a.tokens.Verify(token)
+	}
+	return a.keys.Authenticate(token)
+}
+
+// Check checks that the credentials of a principal authenticated earlier are still valid: that its api key is not
+// revoked, or that its jwt has not expired. Invalid credentials fail with ErrUnauthenticated.
+func (a *Authenticator) Check(p *Principal) error {
+	switch p.Method {
+	case APIKeyMethod:
+		if !a.keys.Active(p.ID) {
+			e := *errors.ErrUnauthenticated
+			e.Message = "the api key was revoked"
+			return &e
+		}
+	case JWTMethod:
+		if !p.ExpiresAt.IsZero() && time.Now().After(p.ExpiresAt) {
+			return invalidToken("expired token")
+		}
+	}
+	return nil
+}
+
+// Watch returns a copy of the context that is canceled when the credentials of the principal are no longer valid:
+// when its jwt expires, or when its api key is revoked, which is checked periodically. The cause of the cancellation
+// is the error of Check. Long-lived connections are served with it, so that they do not outlive their credentials.
+func (a *Authenticator) Watch(ctx context.Context, p *Principal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		ticker := time.NewTicker(a.checkInterval)
+		defer ticker.Stop()
+
+		var expired <-chan time.Time
+		if !p.ExpiresAt.IsZero() {
+			timer := time.NewTimer(time.Until(p.ExpiresAt))
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-expired:
+				cancel(invalidToken("expired token"))
+				return
+			case <-ticker.C:
+				if err := a.Check(p); err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type authenticatorSuite struct {
+	suite.Suite
+	auth *Authenticator
+}
+
+func (s *authenticatorSuite) SetupTest() {
+	keys, err := NewKeyStore(zap.NewExample().Sugar(), "", "admin-key-of-the-configuration-0123456789")
+	s.Require().NoError(err)
+	s.auth = &Authenticator{keys: keys, checkInterval: 10 * time.Millisecond}
+}
+
+// TestCheck tests telling whether the credentials of a principal are still valid.
+func (s *authenticatorSuite) TestCheck() {
+	key, token, err := s.auth.Keys().Create("payments", enum.TellerRole, []enum.Scope{enum.TransfersWrite})
+	s.Require().NoError(err)
+	p, err := s.auth.Authenticate(token)
+	s.Require().NoError(err)
+
+	s.Run("ok: active key", func() {
+		s.NoError(s.auth.Check(p))
+	})
+
+	s.Run("ok: jwt not expired", func() {
+		s.NoError(s.auth.Check(&Principal{Method: JWTMethod, ID: "alice", ExpiresAt: time.Now().Add(time.Minute)}))
+	})
+
+	s.Run("error: expired jwt", func() {
+		err := s.auth.Check(&Principal{Method: JWTMethod, ID: "alice", ExpiresAt: time.Now().Add(-time.Second)})
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+	})
+
+	s.Run("error: revoked key", func() {
+		_, err := s.auth.Keys().Revoke(key.ID)
+		s.Require().NoError(err)
+		s.ErrorIs(s.auth.Check(p), errors.ErrUnauthenticated)
+	})
+}
+
+// TestWatch tests canceling the context of a connection when its credentials are no longer valid.
+func (s *authenticatorSuite) TestWatch() {
+	s.Run("ok: jwt expires", func() {
+		ctx, cancel := s.auth.Watch(context.Background(), &Principal{Method: JWTMethod, ID: "alice", ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+		defer cancel()
+
+		select {
+		case <-ctx.Done():
+			s.ErrorIs(context.Cause(ctx), errors.ErrUnauthenticated)
+		case <-time.After(time.Second):
+			s.Fail("the context was not canceled")
+		}
+	})
+
+	s.Run("ok: key revoked", func() {
+		key, token, err := s.auth.Keys().Create("payments", enum.TellerRole, []enum.Scope{enum.TransfersWrite})
+		s.Require().NoError(err)
+		p, err := s.auth.Authenticate(token)
+		s.Require().NoError(err)
+		ctx, cancel := s.auth.Watch(context.Background(), p)
+		defer cancel()
+
+		_, err = s.auth.Keys().Revoke(key.ID)
+		s.Require().NoError(err)
+		select {
+		case <-ctx.Done():
+			s.ErrorIs(context.Cause(ctx), errors.ErrUnauthenticated)
+		case <-time.After(time.Second):
+			s.Fail("the context was not canceled")
+		}
+	})
+
+	s.Run("ok: canceled", func() {
+		ctx, cancel := s.auth.Watch(context.Background(), &Principal{Method: APIKeyMethod, ID: AdminKeyID})
+		cancel()
+		s.ErrorIs(context.Cause(ctx), context.Canceled)
+	})
+}
+
+func TestAuthenticatorSuite(t *testing.T) {
+	suite.Run(t, new(authenticatorSuite))
+}
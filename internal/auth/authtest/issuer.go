@@ -0,0 +1,104 @@
+// Package authtest provides a local stand-in for the identity provider that issues the JWTs of the customers, so
+// that the packages that authenticate them can be tested without one.
+package authtest
+
+import (
+	"bank_test/internal/auth"
+	"bank_test/internal/enum"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// The ids of the keys of the issuer.
+const (
+	RSAKeyID = "rsa-1"
+	ECKeyID  = "ec-1"
+)
+
+// Issuer signs tokens with an RSA key (RS256) and a P-256 key (ES256), and publishes them in a key set.
+type Issuer struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+// NewIssuer creates a new issuer with random keys.
+func NewIssuer() (*Issuer, error) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{rsa: rsaKey, ec: ecKey}, nil
+}
+
+// JWKS returns the key set of the public keys of the issuer.
+func (i *Issuer) JWKS() []byte {
+	encode := base64.RawURLEncoding.EncodeToString
+	set := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": RSAKeyID, "use": "sig", "alg": auth.RS256, "n": encode(i.rsa.N.Bytes()), "e": encode(big.NewInt(int64(i.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": ECKeyID, "use": "sig", "alg": auth.ES256, "crv": "P-256", "x": encode(i.ec.X.FillBytes(make([]byte, 32))), "y": encode(i.ec.Y.FillBytes(make([]byte, 32)))},
+	}}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+// Token signs the claims with the algorithm, RS256 or ES256.
+func (i *Issuer) Token(alg string, claims map[string]any) (string, error) {
+	kid := RSAKeyID
+	if alg == auth.ES256 {
+		kid = ECKeyID
+	}
+	return i.Sign(map[string]any{"alg": alg, "typ": "JWT", "kid": kid}, claims)
+}
+
+// CustomerToken signs an RS256 token of the owner, valid for an hour.
+func (i *Issuer) CustomerToken(owner string) (string, error) {
+	return i.Token(auth.RS256, map[string]any{"sub": owner, "exp": time.Now().Add(time.Hour).Unix()})
+}
+
+// StaffToken signs an RS256 token of a member of the staff with the role, valid for an hour.
+func (i *Issuer) StaffToken(sub string, role enum.Role) (string, error) {
+	return i.Token(auth.RS256, map[string]any{"sub": sub, "role": role, "exp": time.Now().Add(time.Hour).Unix()})
+}
+
+// Sign signs the claims with the header as is, so that tests can build invalid tokens. The key is selected by the alg
+// header: ES256 signs with the P-256 key, and anything else with the RSA key.
+func (i *Issuer) Sign(header, claims map[string]any) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	if header["alg"] == auth.ES256 {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ec, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("failed to sign token: %v", err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The signature algorithms accepted in the JWTs.
+const (
+	RS256 = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256
+	ES256 = "ES256" // ECDSA with P-256 and SHA-256
+)
+
+const (
+	// clockSkew is the leeway given to the expiration and not before times of the tokens, to tolerate clocks that
+	// drift between the identity provider and the API.
+	clockSkew = time.Minute
+
+	// jwksRefreshInterval is the minimum time between two fetches of a remote key set. Tokens signed with an unknown
+	// key trigger a fetch, so that rotated keys are picked up, but cannot be used to flood the identity provider.
+	jwksRefreshInterval = time.Minute
+
+	// jwksFetchTimeout is the maximum time to fetch a remote key set.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWTVerifier authenticates the customers and the staff with the JWTs issued by an identity provider. The role of the
+// caller is held by a claim of the token, and customers are restricted to the accounts of the owner held by another.
+type JWTVerifier struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+	client *http.Client
+
+	source     string // file or http(s) URL of the key set
+	issuer     string // required iss claim. Empty accepts any issuer
+	audience   string // required aud claim. Empty accepts any audience
+	ownerClaim string // claim that holds the owner of the accounts of the customer
+	roleClaim  string // claim that holds the role of the caller. Tokens without it are customers
+
+	keys      map[string]crypto.PublicKey // keys of the key set by id
+	fetchedAt time.Time                   // last time the key set was loaded
+}
+
+// NewJWTVerifier creates a new verifier and loads its key set from source, a file or an http(s) URL. Remote key sets
+// are fetched again when a token is signed with a key they do not have.
+func NewJWTVerifier(logger *zap.SugaredLogger, source, issuer, audience, ownerClaim, roleClaim string) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		logger:     logger,
+		client:     &http.Client{Timeout: jwksFetchTimeout},
+		source:     source,
+		issuer:     issuer,
+		audience:   audience,
+		ownerClaim: ownerClaim,
+		roleClaim:  roleClaim,
+	}
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and the claims of a token, and returns the caller it authenticates. Invalid tokens
+// fail with ErrUnauthenticated, telling what is wrong with them.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+	if h.Alg != RS256 && h.Alg != ES256 {
+		return nil, invalidToken(fmt.Sprintf("unsupported algorithm '%s'. Must be RS256 or ES256", h.Alg))
+	}
+	key, ok := v.key(h.Kid)
+	if !ok {
+		return nil, invalidToken(fmt.Sprintf("unknown key '%s'", h.Kid))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+	if !verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, invalidToken("invalid signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+	return v.principal(claims, time.Now())
+}
+
+// principal checks the claims of a token whose signature is valid, and returns the caller they describe.
+func (v *JWTVerifier) principal(claims map[string]any, now time.Time) (*Principal, error) {
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return nil, invalidToken("missing exp claim")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return nil, invalidToken("expired token")
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(clockSkew).Before(nbf) {
+		return nil, invalidToken("token not valid yet")
+	}
+	if iss, _ := claims["iss"].(string); v.issuer != "" && iss != v.issuer {
+		return nil, invalidToken(fmt.Sprintf("unexpected issuer '%s'", iss))
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return nil, invalidToken("unexpected audience")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, invalidToken("missing sub claim")
+	}
+	role := enum.CustomerRole
+	if value, ok := claims[v.roleClaim]; ok {
+		name, _ := value.(string)
+		if role = enum.Role(name); !role.IsValid() {
+			return nil, invalidToken(fmt.Sprintf("unknown role '%v'", value))
+		}
+	}
+
+	// only customers are restricted to the accounts of an owner: the staff operate the accounts of every customer
+	var owner string
+	if role == enum.CustomerRole {
+		if owner, _ = claims[v.ownerClaim].(string); owner == "" {
+			return nil, invalidToken(fmt.Sprintf("missing %s claim", v.ownerClaim))
+		}
+	}
+
+	// tokens without a scope claim are granted every scope: the policy of their role tells what they can do
+	scopes := enum.Scopes()
+	if value, ok := claims["scope"].(string); ok {
+		scopes = make([]enum.Scope, 0)
+		for _, s := range strings.Fields(value) {
+			if scope := enum.Scope(s); scope.IsValid() {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return &Principal{Method: JWTMethod, ID: sub, Name: sub, Role: role, Scopes: scopes, Owner: owner, ExpiresAt: exp.Add(clockSkew)}, nil
+}
+
+// key returns the key of the key set with the id. Tokens without key id can be used with key sets of a single key.
+// Remote key sets are fetched again if they do not have the key, at most once every jwksRefreshInterval.
+func (v *JWTVerifier) key(kid string) (crypto.PublicKey, bool) {
+	v.mu.RLock()
+	key, ok := v.lookup(kid)
+	stale := time.Since(v.fetchedAt) >= jwksRefreshInterval
+	v.mu.RUnlock()
+	if ok || !stale || !isURL(v.source) {
+		return key, ok
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if time.Since(v.fetchedAt) >= jwksRefreshInterval {
+		if err := v.fetch(); err != nil {
+			v.logger.Errorf("failed to refresh jwks: %v", err)
+		}
+	}
+	return v.lookup(kid)
+}
+
+// lookup returns the key with the id. It must be called with the lock held.
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// load loads the key set for the first time.
+func (v *JWTVerifier) load() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.fetch()
+}
+
+// fetch reads the key set from its source and replaces the keys. The previous keys are kept if it fails. It must be
+// called with the lock held.
+func (v *JWTVerifier) fetch() error {
+	v.fetchedAt = time.Now()
+
+	var data []byte
+	var err error
+	if isURL(v.source) {
+		data, err = v.download()
+	} else {
+		data, err = os.ReadFile(v.source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read jwks: %v", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	v.logger.Infof("jwks loaded: %d keys", len(keys))
+	return nil
+}
+
+// download fetches a remote key set.
+func (v *JWTVerifier) download() ([]byte, error) {
+	resp, err := v.client.Get(v.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// verifySignature checks the signature of the signing input of a token with the key.
+func verifySignature(alg string, key crypto.PublicKey, input, signature []byte) bool {
+	digest := sha256.Sum256(input)
+	switch alg {
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		// the signature is the concatenation of r and s, 32 bytes each (RFC 7518, section 3.4)
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	default:
+		return false
+	}
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// numericClaim returns a claim that holds a time as seconds since the epoch.
+func numericClaim(claims map[string]any, name string) (time.Time, bool) {
+	n, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(seconds * 1000)), true
+}
+
+// hasAudience checks whether the aud claim, a string or an array of strings, contains the audience.
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isURL checks whether the source of a key set is an http(s) URL rather than a file.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// invalidToken returns ErrUnauthenticated with the reason why the token is rejected.
+func invalidToken(reason string) error {
+	e := *errors.ErrUnauthenticated
+	e.Message = "invalid jwt: " + reason
+	return &e
+}
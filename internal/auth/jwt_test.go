@@ -0,0 +1,215 @@
+package auth_test
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
+	"bank_test/internal/auth/authtest"
+	"bank_test/internal/enum"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type jwtSuite struct {
+	logger   *zap.SugaredLogger
+	issuer   *authtest.Issuer
+	verifier *auth.JWTVerifier
+	suite.Suite
+}
+
+func (s *jwtSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+
+	issuer, err := authtest.NewIssuer()
+	s.Require().NoError(err)
+	s.issuer = issuer
+
+	path := filepath.Join(s.T().TempDir(), "jwks.json")
+	s.Require().NoError(os.WriteFile(path, issuer.JWKS(), 0o600))
+	verifier, err := auth.NewJWTVerifier(s.logger, path, "https://idp.example.com", "bank", "customer_id", "role")
+	s.Require().NoError(err)
+	s.verifier = verifier
+}
+
+// claims returns valid claims, overridden by the ones given.
+func (s *jwtSuite) claims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":         "https://idp.example.com",
+		"aud":         []string{"bank", "other"},
+		"sub":         "user-1",
+		"customer_id": "Alice",
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+// TestVerify tests authenticating customers and staff with their tokens.
+func (s *jwtSuite) TestVerify() {
+	for _, alg := range []string{auth.RS256, auth.ES256} {
+		s.Run("ok: "+alg, func() {
+			token, err := s.issuer.Token(alg, s.claims(nil))
+			s.Require().NoError(err)
+
+			p, err := s.verifier.Verify(token)
+			s.Require().NoError(err)
+			s.Equal("jwt:user-1", p.Actor())
+			s.Equal("Alice", p.Owner)
+			s.True(p.Owns("Alice"))
+			s.False(p.Owns("Bob"))
+			s.Equal(enum.CustomerRole, p.Role)
+			s.ElementsMatch(enum.Scopes(), p.Scopes)
+		})
+	}
+
+	s.Run("ok: scope claim", func() {
+		token, err := s.issuer.Token(auth.ES256, s.claims(map[string]any{"scope": "accounts:read unknown"}))
+		s.Require().NoError(err)
+
+		p, err := s.verifier.Verify(token)
+		s.Require().NoError(err)
+		s.Equal([]enum.Scope{enum.AccountsRead}, p.Scopes)
+	})
+
+	s.Run("ok: staff role", func() {
+		token, err := s.issuer.Token(auth.RS256, s.claims(map[string]any{"role": "teller", "customer_id": nil}))
+		s.Require().NoError(err)
+
+		p, err := s.verifier.Verify(token)
+		s.Require().NoError(err)
+		s.Equal(enum.TellerRole, p.Role)
+		s.Empty(p.Owner)
+		s.True(p.Owns("Alice"))
+	})
+
+	s.Run("ok: staff role ignores the owner claim", func() {
+		token, err := s.issuer.Token(auth.RS256, s.claims(map[string]any{"role": "auditor"}))
+		s.Require().NoError(err)
+
+		p, err := s.verifier.Verify(token)
+		s.Require().NoError(err)
+		s.Equal(enum.AuditorRole, p.Role)
+		s.Empty(p.Owner)
+	})
+
+	for name, data := range map[string]struct {
+		claims  map[string]any
+		message string
+	}{
+		"expired":        {map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, "expired token"},
+		"no expiration":  {map[string]any{"exp": nil}, "missing exp claim"},
+		"not valid yet":  {map[string]any{"nbf": time.Now().Add(time.Hour).Unix()}, "token not valid yet"},
+		"wrong issuer":   {map[string]any{"iss": "https://evil.example.com"}, "unexpected issuer"},
+		"wrong audience": {map[string]any{"aud": "other"}, "unexpected audience"},
+		"no subject":     {map[string]any{"sub": nil}, "missing sub claim"},
+		"no owner":       {map[string]any{"customer_id": nil}, "missing customer_id claim"},
+		"unknown role":   {map[string]any{"role": "root"}, "unknown role 'root'"},
+		"invalid role":   {map[string]any{"role": 1}, "unknown role '1'"},
+	} {
+		s.Run("error: "+name, func() {
+			token, err := s.issuer.Token(auth.RS256, s.claims(data.claims))
+			s.Require().NoError(err)
+
+			_, err = s.verifier.Verify(token)
+			s.ErrorIs(err, errors.ErrUnauthenticated)
+			s.ErrorContains(err, data.message)
+		})
+	}
+
+	for name, header := range map[string]map[string]any{
+		"none algorithm":    {"alg": "none", "kid": authtest.RSAKeyID},
+		"hmac algorithm":    {"alg": "HS256", "kid": authtest.RSAKeyID},
+		"unknown key":       {"alg": auth.RS256, "kid": "unknown"},
+		"key of other type": {"alg": auth.RS256, "kid": authtest.ECKeyID},
+	} {
+		s.Run("error: "+name, func() {
+			token, err := s.issuer.Sign(header, s.claims(nil))
+			s.Require().NoError(err)
+
+			_, err = s.verifier.Verify(token)
+			s.ErrorIs(err, errors.ErrUnauthenticated)
+		})
+	}
+
+	s.Run("error: tampered claims", func() {
+		token, err := s.issuer.Token(auth.RS256, s.claims(nil))
+		s.Require().NoError(err)
+		forged, err := s.issuer.Token(auth.RS256, s.claims(map[string]any{"customer_id": "Bob"}))
+		s.Require().NoError(err)
+
+		parts, forgedParts := strings.Split(token, "."), strings.Split(forged, ".")
+		_, err = s.verifier.Verify(parts[0] + "." + forgedParts[1] + "." + parts[2])
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+		s.ErrorContains(err, "invalid signature")
+	})
+
+	s.Run("error: signed by another issuer", func() {
+		other, err := authtest.NewIssuer()
+		s.Require().NoError(err)
+		token, err := other.Token(auth.ES256, s.claims(nil))
+		s.Require().NoError(err)
+
+		_, err = s.verifier.Verify(token)
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+	})
+}
+
+// TestRemoteJWKS tests fetching the key set from a URL, and not fetching it again for every unknown key.
+func (s *jwtSuite) TestRemoteJWKS() {
+	var jwks atomic.Value
+	jwks.Store(s.issuer.JWKS())
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(jwks.Load().([]byte))
+	}))
+	defer server.Close()
+
+	verifier, err := auth.NewJWTVerifier(s.logger, server.URL, "", "", "sub", "role")
+	s.Require().NoError(err)
+	s.Equal(int32(1), fetches.Load())
+
+	s.Run("ok: remote key", func() {
+		token, err := s.issuer.CustomerToken("Alice")
+		s.Require().NoError(err)
+
+		p, err := verifier.Verify(token)
+		s.Require().NoError(err)
+		s.Equal("Alice", p.Owner)
+	})
+
+	s.Run("error: rotated keys are not fetched again right away", func() {
+		rotated, err := authtest.NewIssuer()
+		s.Require().NoError(err)
+		jwks.Store(rotated.JWKS())
+
+		token, err := rotated.CustomerToken("Alice")
+		s.Require().NoError(err)
+		_, err = verifier.Verify(token)
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+		s.Equal(int32(1), fetches.Load())
+	})
+
+	s.Run("error: missing key set", func() {
+		_, err := auth.NewJWTVerifier(s.logger, filepath.Join(s.T().TempDir(), "missing.json"), "", "", "sub", "role")
+		s.Error(err)
+	})
+}
+
+func TestJWT(t *testing.T) {
+	suite.Run(t, new(jwtSuite))
+}
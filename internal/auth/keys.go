@@ -0,0 +1,230 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyPrefix starts every api key, so that leaked keys are easy to find in code and logs.
+	keyPrefix = "bank_"
+
+	// displayedPrefixLength is the number of characters of a key kept to tell keys apart once it is created.
+	displayedPrefixLength = 12
+
+	// AdminKeyID is the id of the principal authenticated with the admin key of the configuration.
+	AdminKeyID = "admin"
+)
+
+// APIKey is a key that authenticates the requests of a client. Only the hash of the key is stored: the key itself is
+// returned once, when it is created.
+type APIKey struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Prefix    string       `json:"prefix"` // first characters of the key, to tell keys apart
+	Role      enum.Role    `json:"role"`   // role of the clients of the key. Keys created before roles existed are admins
+	Scopes    []enum.Scope `json:"scopes"`
+	CreatedAt time.Time    `json:"created_at"`
+	RevokedAt *time.Time   `json:"revoked_at,omitempty"` // revoked keys are kept, but no longer authenticate
+}
+
+// storedKey is an api key as it is stored, with the hash of the key.
+type storedKey struct {
+	APIKey
+	Hash string `json:"hash"` // hex encoded SHA-256 hash of the key
+}
+
+// KeyStore keeps the api keys. If it has a file, it is rewritten after every change, so that the keys survive
+// restarts.
+type KeyStore struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+
+	path   string
+	keys   map[string]storedKey // keys by id
+	hashes map[string]string    // ids of the keys by hash
+
+	adminHash string // hash of the admin key of the configuration. Empty if there is none
+}
+
+// NewKeyStore creates a new store. If path is not empty, the keys stored in the file are loaded and every change is
+// written to it. If adminKey is not empty, it authenticates as an admin with every scope, so that the first keys can be
+// created.
+func NewKeyStore(logger *zap.SugaredLogger, path, adminKey string) (*KeyStore, error) {
+	s := &KeyStore{
+		logger: logger,
+		path:   path,
+		keys:   make(map[string]storedKey),
+		hashes: make(map[string]string),
+	}
+	if adminKey != "" {
+		s.adminHash = hashKey(adminKey)
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api keys file: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("failed to decode api keys file: %v", err)
+	}
+	for id, key := range s.keys {
+		if key.Role == "" {
+			key.Role = enum.AdminRole
+			s.keys[id] = key
+		}
+		s.hashes[key.Hash] = id
+	}
+	logger.Infof("api keys loaded: %d keys", len(s.keys))
+	return s, nil
+}
+
+// Create creates a new api key of the role with the scopes, and returns it together with the key itself, which cannot
+// be retrieved afterwards.
+func (s *KeyStore) Create(name string, role enum.Role, scopes []enum.Scope) (*APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %v", err)
+	}
+	token := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := storedKey{
+		APIKey: APIKey{
+			ID:        uuid.NewString(),
+			Name:      name,
+			Prefix:    token[:displayedPrefixLength],
+			Role:      role,
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC(),
+		},
+		Hash: hashKey(token),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	s.hashes[key.Hash] = key.ID
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.hashes, key.Hash)
+		return nil, "", err
+	}
+	return &key.APIKey, token, nil
+}
+
+// List retrieves every api key, revoked ones included, sorted by creation time.
+func (s *KeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Revoke revokes an api key, which no longer authenticates. Revoking a key twice keeps the time of the first
+// revocation.
+func (s *KeyStore) Revoke(id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return &key.APIKey, nil
+	}
+
+	previous := key
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		s.keys[id] = previous
+		return nil, err
+	}
+	return &key.APIKey, nil
+}
+
+// Authenticate returns the principal of an api key. Unknown and revoked keys fail with ErrUnauthenticated.
+func (s *KeyStore) Authenticate(token string) (*Principal, error) {
+	hash := hashKey(token)
+	if s.adminHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.adminHash)) == 1 {
+		return &Principal{Method: APIKeyMethod, ID: AdminKeyID, Name: AdminKeyID, Role: enum.AdminRole, Scopes: enum.Scopes()}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[s.hashes[hash]]
+	if !ok || key.RevokedAt != nil {
+		return nil, errors.ErrUnauthenticated
+	}
+	return &Principal{Method: APIKeyMethod, ID: key.ID, Name: key.Name, Role: key.Role, Scopes: key.Scopes}, nil
+}
+
+// Active checks whether the api key with the id can still authenticate: it is the admin key of the configuration, or
+// a key of the store that is not revoked.
+func (s *KeyStore) Active(id string) bool {
+	if id == AdminKeyID {
+		return s.adminHash != ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	return ok && key.RevokedAt == nil
+}
+
+// save writes the keys to the file, if any. It is written to a temporary file first, so a crash while writing it
+// never corrupts the previous keys. It must be called with the lock held.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.keys)
+	if err != nil {
+		return fmt.Errorf("failed to marshal api keys: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write api keys file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to store api keys file: %v", err)
+	}
+	return nil
+}
+
+// hashKey returns the hex encoded SHA-256 hash of a key. Keys are random, so a fast hash is enough to protect them.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
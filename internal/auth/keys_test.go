@@ -0,0 +1,124 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type keysSuite struct {
+	logger *zap.SugaredLogger
+	path   string
+	store  *KeyStore
+	suite.Suite
+}
+
+func (s *keysSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.path = filepath.Join(s.T().TempDir(), "keys.json")
+
+	store, err := NewKeyStore(s.logger, s.path, "admin-key-of-the-configuration-0123456789")
+	s.Require().NoError(err)
+	s.store = store
+}
+
+// TestAuthenticate tests authenticating the created keys and the admin key.
+func (s *keysSuite) TestAuthenticate() {
+	key, token, err := s.store.Create("payments", enum.TellerRole, []enum.Scope{enum.TransfersWrite})
+	s.Require().NoError(err)
+	s.True(strings.HasPrefix(token, keyPrefix))
+	s.Equal(token[:displayedPrefixLength], key.Prefix)
+
+	s.Run("ok: created key", func() {
+		p, err := s.store.Authenticate(token)
+		s.Require().NoError(err)
+		s.Equal(key.ID, p.ID)
+		s.Equal("apikey:"+key.ID, p.Actor())
+		s.Equal(enum.TellerRole, p.Role)
+		s.True(p.HasScope(enum.TransfersWrite))
+		s.False(p.HasScope(enum.AccountsRead))
+	})
+
+	s.Run("ok: admin key", func() {
+		p, err := s.store.Authenticate("admin-key-of-the-configuration-0123456789")
+		s.Require().NoError(err)
+		s.Equal(AdminKeyID, p.ID)
+		s.Equal(enum.AdminRole, p.Role)
+		s.ElementsMatch(enum.Scopes(), p.Scopes)
+	})
+
+	s.Run("error: unknown key", func() {
+		_, err := s.store.Authenticate(token + "x")
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+	})
+
+	s.Run("error: revoked key", func() {
+		revoked, err := s.store.Revoke(key.ID)
+		s.Require().NoError(err)
+		s.NotNil(revoked.RevokedAt)
+
+		_, err = s.store.Authenticate(token)
+		s.ErrorIs(err, errors.ErrUnauthenticated)
+	})
+
+	s.Run("error: revoke unknown key", func() {
+		_, err := s.store.Revoke("unknown")
+		s.ErrorIs(err, errors.ErrAPIKeyNotFound)
+	})
+}
+
+// TestPersistence tests that the keys survive restarts, and that only their hash is stored.
+func (s *keysSuite) TestPersistence() {
+	first, token, err := s.store.Create("first", enum.AuditorRole, []enum.Scope{enum.AccountsRead})
+	s.Require().NoError(err)
+	second, _, err := s.store.Create("second", enum.AdminRole, []enum.Scope{enum.AccountsWrite})
+	s.Require().NoError(err)
+	_, err = s.store.Revoke(second.ID)
+	s.Require().NoError(err)
+
+	data, err := os.ReadFile(s.path)
+	s.Require().NoError(err)
+	s.NotContains(string(data), token)
+	s.Contains(string(data), hashKey(token))
+
+	reopened, err := NewKeyStore(s.logger, s.path, "")
+	s.Require().NoError(err)
+	keys := reopened.List()
+	s.Require().Len(keys, 2)
+	s.Equal(first.ID, keys[0].ID)
+	s.Equal(second.ID, keys[1].ID)
+	s.NotNil(keys[1].RevokedAt)
+
+	p, err := reopened.Authenticate(token)
+	s.Require().NoError(err)
+	s.Equal(first.ID, p.ID)
+	s.Equal(enum.AuditorRole, p.Role)
+
+	_, err = reopened.Authenticate("admin-key-of-the-configuration-0123456789")
+	s.ErrorIs(err, errors.ErrUnauthenticated)
+}
+
+// TestKeysWithoutRole tests that the keys stored before roles existed keep every operation they were allowed.
+func (s *keysSuite) TestKeysWithoutRole() {
+	token := keyPrefix + "created-before-roles"
+	data := fmt.Sprintf(`{"1":{"id":"1","name":"legacy","prefix":"bank_created","scopes":["admin"],"hash":"%s"}}`, hashKey(token))
+	s.Require().NoError(os.WriteFile(s.path, []byte(data), 0o600))
+
+	store, err := NewKeyStore(s.logger, s.path, "")
+	s.Require().NoError(err)
+	p, err := store.Authenticate(token)
+	s.Require().NoError(err)
+	s.Equal(enum.AdminRole, p.Role)
+	s.Equal(enum.AdminRole, store.List()[0].Role)
+}
+
+func TestKeys(t *testing.T) {
+	suite.Run(t, new(keysSuite))
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Operation is an operation of the API that the callers are authorized to perform.
+type Operation string
+
+// Operations
+const (
+	ReadAccounts        Operation = "read_accounts"        // read accounts and stream their balances
+	OpenAccount         Operation = "open_account"         // open accounts
+	FundAccount         Operation = "fund_account"         // open accounts with a non-zero initial balance
+	ReadTransactions    Operation = "read_transactions"    // read the transactions, events and statements of accounts
+	Deposit             Operation = "deposit"              // deposit money into accounts
+	Withdraw            Operation = "withdraw"             // withdraw money from accounts
+	Transfer            Operation = "transfer"             // transfer money and submit payment files
+	FreezeAccount       Operation = "freeze_account"       // freeze and unfreeze accounts
+	ReadAuditLog        Operation = "read_audit_log"       // read and verify the audit log
+	ReadReconciliations Operation = "read_reconciliations" // read the reports of the reconciliations
+	Reconcile           Operation = "reconcile"            // run reconciliations
+	Backup              Operation = "backup"               // download backups of the database
+	ReadAPIKeys         Operation = "read_api_keys"        // list the api keys
+	ManageAPIKeys       Operation = "manage_api_keys"      // create and revoke api keys
+	ReadWebhooks        Operation = "read_webhooks"        // read webhook subscriptions and dead letters
+	ManageWebhooks      Operation = "manage_webhooks"      // manage webhook subscriptions and redeliver dead letters
+)
+
+// scopes are the scopes that the credentials of a caller must be granted to perform every operation, on top of the
+// policy of its role.
+var scopes = map[Operation]enum.Scope{
+	ReadAccounts:        enum.AccountsRead,
+	OpenAccount:         enum.AccountsWrite,
+	FundAccount:         enum.AccountsWrite,
+	ReadTransactions:    enum.TransactionsRead,
+	Deposit:             enum.TransactionsWrite,
+	Withdraw:            enum.TransactionsWrite,
+	Transfer:            enum.TransfersWrite,
+	FreezeAccount:       enum.AdminScope,
+	ReadAuditLog:        enum.AdminScope,
+	ReadReconciliations: enum.AdminScope,
+	Reconcile:           enum.AdminScope,
+	Backup:              enum.AdminScope,
+	ReadAPIKeys:         enum.AdminScope,
+	ManageAPIKeys:       enum.AdminScope,
+	ReadWebhooks:        enum.WebhooksRead,
+	ManageWebhooks:      enum.WebhooksWrite,
+}
+
+// policies are the operations that every role can perform. Customers are also restricted to their own accounts, and
+// can neither deposit nor open them with an initial balance: both create money, which only happens at the counter.
+var policies = map[enum.Role][]Operation{
+	enum.CustomerRole: {ReadAccounts, OpenAccount, ReadTransactions, Withdraw, Transfer},
+	enum.TellerRole:   {ReadAccounts, OpenAccount, FundAccount, ReadTransactions, Deposit},
+	enum.AuditorRole:  {ReadAccounts, ReadTransactions, ReadAuditLog, ReadReconciliations, Backup, ReadAPIKeys, ReadWebhooks},
+	enum.AdminRole: {
+		ReadAccounts, OpenAccount, FundAccount, ReadTransactions, Deposit, Withdraw, Transfer, FreezeAccount, ReadAuditLog,
+		ReadReconciliations, Reconcile, Backup, ReadAPIKeys, ManageAPIKeys, ReadWebhooks, ManageWebhooks,
+	},
+}
+
+// Scope returns the scope that the credentials of a caller must be granted to perform the operation.
+func (o Operation) Scope() enum.Scope {
+	return scopes[o]
+}
+
+// Allows checks whether the policy of the role allows the operation.
+func Allows(role enum.Role, op Operation) bool {
+	return slices.Contains(policies[role], op)
+}
+
+// Authorize checks that the caller carried by the context can perform the operation: the policy of its role must
+// allow it, and its credentials must be granted its scope. Contexts without a caller are allowed: either
+// authentication is disabled, or the operation was already authorized by the transport that received the request.
+func Authorize(ctx context.Context, op Operation) error {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if !Allows(p.Role, op) {
+		e := *errors.ErrForbidden
+		e.Message = fmt.Sprintf("the role %s is not allowed to %s", p.Role, op)
+		return &e
+	}
+	if !p.HasScope(op.Scope()) {
+		e := *errors.ErrInsufficientScope
+		e.Message = "the caller is not granted the scope " + op.Scope().String()
+		return &e
+	}
+	return nil
+}
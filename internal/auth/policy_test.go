@@ -0,0 +1,72 @@
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type policySuite struct {
+	suite.Suite
+}
+
+// TestAllows tests the operations allowed by the policy of every role.
+func (s *policySuite) TestAllows() {
+	for name, data := range map[string]struct {
+		role    enum.Role
+		allowed []Operation
+		denied  []Operation
+	}{
+		"customer": {enum.CustomerRole, []Operation{ReadAccounts, OpenAccount, Withdraw, Transfer}, []Operation{Deposit, FundAccount, FreezeAccount, ReadAuditLog}},
+		"teller":   {enum.TellerRole, []Operation{ReadAccounts, OpenAccount, FundAccount, Deposit}, []Operation{Withdraw, Transfer, FreezeAccount, ReadAuditLog}},
+		"auditor":  {enum.AuditorRole, []Operation{ReadAccounts, ReadTransactions, ReadAuditLog, ReadReconciliations, ReadWebhooks}, []Operation{OpenAccount, FundAccount, Deposit, Reconcile, ManageAPIKeys, ManageWebhooks, FreezeAccount}},
+		"admin":    {enum.AdminRole, []Operation{FreezeAccount, ManageAPIKeys, Deposit, Withdraw, ReadAuditLog}, nil},
+		"unknown":  {enum.Role("unknown"), nil, []Operation{ReadAccounts}},
+	} {
+		s.Run("ok: "+name, func() {
+			for _, op := range data.allowed {
+				s.True(Allows(data.role, op), op)
+			}
+			for _, op := range data.denied {
+				s.False(Allows(data.role, op), op)
+			}
+		})
+	}
+
+	s.Run("ok: every operation has a scope", func() {
+		for _, op := range policies[enum.AdminRole] {
+			s.True(op.Scope().IsValid(), op)
+		}
+	})
+}
+
+// TestAuthorize tests checking the role and the scopes of the caller of a request.
+func (s *policySuite) TestAuthorize() {
+	s.Run("ok: no caller", func() {
+		s.NoError(Authorize(context.Background(), FreezeAccount))
+	})
+
+	ctx := WithPrincipal(context.Background(), &Principal{ID: "1", Role: enum.TellerRole, Scopes: []enum.Scope{enum.AccountsRead, enum.TransactionsWrite}})
+	s.Run("ok: allowed operation", func() {
+		s.NoError(Authorize(ctx, Deposit))
+	})
+
+	s.Run("error: operation not allowed to the role", func() {
+		err := Authorize(ctx, Withdraw)
+		s.ErrorIs(err, errors.ErrForbidden)
+		s.Contains(err.Error(), "teller")
+	})
+
+	s.Run("error: missing scope", func() {
+		err := Authorize(ctx, OpenAccount)
+		s.ErrorIs(err, errors.ErrInsufficientScope)
+		s.Contains(err.Error(), "accounts:write")
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	suite.Run(t, new(policySuite))
+}
@@ -0,0 +1,76 @@
+// Package auth authenticates the callers of the API and tells which operations they are allowed to perform.
+package auth
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"context"
+	"slices"
+	"time"
+)
+
+// The ways in which a principal is authenticated. They prefix the actor recorded in the audit log.
+const (
+	APIKeyMethod = "apikey"
+	JWTMethod    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Method string       // how the principal was authenticated: APIKeyMethod or JWTMethod
+	ID     string       // id of the api key, or subject of the jwt
+	Name   string       // name given to the api key when it was created, or subject of the jwt
+	Role   enum.Role    // role of the principal, whose policy tells the operations it can perform
+	Scopes []enum.Scope // scopes granted to the credentials of the principal
+	Owner  string       // owner of the accounts the principal is restricted to. Only set for customers
+
+	ExpiresAt time.Time // time after which the jwt is no longer accepted. Zero for api keys, which do not expire
+}
+
+// Actor returns the identity of the principal recorded in the audit log.
+func (p *Principal) Actor() string {
+	return p.Method + ":" + p.ID
+}
+
+// HasScope checks whether the principal is granted the scope.
+func (p *Principal) HasScope(scope enum.Scope) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Owns checks whether the principal can access the accounts of the owner.
+func (p *Principal) Owns(owner string) bool {
+	return p.Owner == "" || p.Owner == owner
+}
+
+// principalKey is the key of the principal in the context.
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context that carries the authenticated caller of the request.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the authenticated caller carried by the context, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// OwnerFrom returns the owner of the accounts the caller carried by the context is restricted to, if any.
+func OwnerFrom(ctx context.Context) (string, bool) {
+	p, ok := PrincipalFrom(ctx)
+	if !ok || p.Owner == "" {
+		return "", false
+	}
+	return p.Owner, true
+}
+
+// AuthorizeOwner checks that the caller carried by the context can access the accounts of the owner. Like Authorize,
+// contexts without a caller are allowed.
+func AuthorizeOwner(ctx context.Context, owner string) error {
+	p, ok := PrincipalFrom(ctx)
+	if !ok || p.Owns(owner) {
+		return nil
+	}
+	return errors.ErrAccountAccessDenied
+}
@@ -3,6 +3,8 @@ package conf
 import (
 	"bank_test/internal/enum"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -14,6 +16,69 @@ type Config struct {
 	Port       string        `mapstructure:"PORT" validate:"required"`        // Port in which the API will listen
 	HealthPort string        `mapstructure:"HEALTH_PORT" validate:"required"` // Health port in which the API will listen
 	LogLevel   enum.LogLevel `mapstructure:"LOG_LEVEL" validate:"required"`   // Log level for the API: debug, info
+
+	Transports  string `mapstructure:"TRANSPORTS" validate:"required"` // Transport layers that serve the API, separated by commas: http, grpc, graphql
+	GRPCPort    string `mapstructure:"GRPC_PORT"`                      // Port in which the gRPC server will listen
+	GraphQLPort string `mapstructure:"GRAPHQL_PORT"`                   // Port in which the GraphQL server will listen
+
+	DBDriver              enum.DatabaseDriver `mapstructure:"DB_DRIVER" validate:"required"` // Database implementation: memory, eventstore, sqlite, bolt
+	EventStoreDir         string              `mapstructure:"EVENT_STORE_DIR"`               // Directory in which the event store persists its events and snapshots. Empty keeps them in memory
+	EventSnapshotInterval int                 `mapstructure:"EVENT_SNAPSHOT_INTERVAL"`       // Number of events between two snapshots of the event store. 0 disables them
+	SQLitePath            string              `mapstructure:"SQLITE_PATH"`                   // File of the SQLite database
+	DBMigrateOnStartup    bool                `mapstructure:"DB_MIGRATE_ON_STARTUP"`         // Apply the pending migrations of the SQL database on startup
+	BoltPath              string              `mapstructure:"BOLT_PATH"`                     // File of the bolt database
+
+	DataDir          string          `mapstructure:"DATA_DIR"`          // Directory in which the memory database stores its write-ahead log and snapshots. Empty disables durability
+	WALSyncPolicy    enum.SyncPolicy `mapstructure:"WAL_SYNC_POLICY"`   // When the write-ahead log is synced to disk: always, interval, never
+	WALSyncInterval  time.Duration   `mapstructure:"WAL_SYNC_INTERVAL"` // Interval between syncs of the write-ahead log when the policy is interval
+	SnapshotInterval time.Duration   `mapstructure:"SNAPSHOT_INTERVAL"` // Interval between snapshots of the memory database. 0 disables them
+
+	IBANCountryCode string `mapstructure:"IBAN_COUNTRY_CODE" validate:"required,len=2,alpha"` // Country code used to generate account numbers
+	IBANBankCode    string `mapstructure:"IBAN_BANK_CODE" validate:"required,alphanum"`       // Bank code used to generate account numbers
+	Currency        string `mapstructure:"CURRENCY" validate:"required,len=3,alpha"`          // ISO 4217 code of the currency of the accounts, used in the statements
+
+	ReconciliationInterval time.Duration `mapstructure:"RECONCILIATION_INTERVAL"` // Interval between scheduled reconciliations. 0 disables them
+	AuditLogPath           string        `mapstructure:"AUDIT_LOG_PATH"`          // File in which the audit log is persisted. Empty keeps it in memory
+
+	WebhooksPath        string        `mapstructure:"WEBHOOKS_PATH"`                         // File in which the webhook subscriptions and dead letters are persisted. Empty keeps them in memory
+	WebhookPollInterval time.Duration `mapstructure:"WEBHOOK_POLL_INTERVAL"`                 // Interval between two rounds of webhook deliveries. 0 disables them
+	WebhookTimeout      time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`                       // Maximum time to wait for the response of a webhook delivery
+	WebhookMaxAttempts  int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS" validate:"min=1"` // Number of attempts before a webhook delivery is moved to the dead-letter queue
+	WebhookBackoff      time.Duration `mapstructure:"WEBHOOK_BACKOFF"`                       // Delay before the first retry of a webhook delivery, doubled after every failed attempt
+	WebhookMaxBackoff   time.Duration `mapstructure:"WEBHOOK_MAX_BACKOFF"`                   // Maximum delay between two attempts of a webhook delivery
+
+	ActivityBufferSize        int           `mapstructure:"ACTIVITY_BUFFER_SIZE" validate:"min=1"` // Number of events of every account kept in memory to resume their streams
+	ActivityHeartbeatInterval time.Duration `mapstructure:"ACTIVITY_HEARTBEAT_INTERVAL"`           // Interval between two heartbeats of the account event streams
+
+	WebSocketAllowedOrigins string `mapstructure:"WEBSOCKET_ALLOWED_ORIGINS"` // Origins allowed to open WebSocket connections besides the API itself, separated by commas. '*' allows any origin
+
+	RequestTimeout time.Duration `mapstructure:"REQUEST_TIMEOUT"` // Maximum time to process a request. 0 disables it
+	RouteTimeouts  string        `mapstructure:"ROUTE_TIMEOUTS"`  // Timeouts of specific routes: 'METHOD /pattern=duration', separated by commas
+
+	DeprecatedRoutes string `mapstructure:"DEPRECATED_ROUTES"` // Deprecated versions and routes: 'v1=date[/sunset]' or 'METHOD /v1/pattern=date[/sunset]', separated by commas
+
+	IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_TTL"` // Time during which the responses of the requests sent with an Idempotency-Key header are replayed. 0 disables it
+
+	OpenAPIValidation bool `mapstructure:"OPENAPI_VALIDATION"` // Validate the requests and the responses against the OpenAPI specification. Meant for tests
+
+	AuthEnabled bool   `mapstructure:"AUTH_ENABLED"`                                       // Require an api key or a jwt in the Authorization header of the requests of every transport
+	APIKeysPath string `mapstructure:"API_KEYS_PATH"`                                      // File in which the hashes of the api keys are persisted. Empty keeps them in memory
+	AdminAPIKey string `mapstructure:"ADMIN_API_KEY" validate:"omitempty,min=32" json:"-"` // Api key granted every scope, used to create the first api keys. Empty disables it. Never logged
+
+	JWKS          string `mapstructure:"JWKS"`            // File or http(s) URL of the JSON Web Key Set that signs the JWTs. Empty disables JWTs
+	JWTIssuer     string `mapstructure:"JWT_ISSUER"`      // Required iss claim of the JWTs. Empty accepts any issuer
+	JWTAudience   string `mapstructure:"JWT_AUDIENCE"`    // Required aud claim of the JWTs. Empty accepts any audience
+	JWTOwnerClaim string `mapstructure:"JWT_OWNER_CLAIM"` // Claim of the JWTs that holds the owner of the accounts of the customer
+	JWTRoleClaim  string `mapstructure:"JWT_ROLE_CLAIM"`  // Claim of the JWTs that holds the role of the caller. Tokens without it are customers
+
+	FrozenAccountsPath string `mapstructure:"FROZEN_ACCOUNTS_PATH"` // File in which the frozen accounts are persisted. Empty keeps them in memory
+
+	PaymentMessagesPath string        `mapstructure:"PAYMENT_MESSAGES_PATH"` // File in which the ids of the processed payment messages are persisted. Empty keeps them in memory
+	PaymentMessageTTL   time.Duration `mapstructure:"PAYMENT_MESSAGE_TTL"`   // Time during which the ids of the processed payment messages are kept to reject duplicates. 0 keeps them forever
+
+	routeTimeouts map[string]time.Duration // parsed ROUTE_TIMEOUTS
+	deprecations  map[string]Deprecation   // parsed DEPRECATED_ROUTES
+	transports    []enum.Transport         // parsed TRANSPORTS
 }
 
 // NewConfig returns a new Config instance
@@ -28,6 +93,77 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s", c.LogLevel)
 	}
 
+	if !c.DBDriver.IsValid() {
+		return fmt.Errorf("invalid database driver: %s", c.DBDriver)
+	}
+
+	transports, err := parseTransports(c.Transports)
+	if err != nil {
+		return err
+	}
+	c.transports = transports
+
+	if !c.WALSyncPolicy.IsValid() {
+		return fmt.Errorf("invalid write-ahead log sync policy: %s", c.WALSyncPolicy)
+	}
+
+	if c.WebhookBackoff < 0 || c.WebhookMaxBackoff < c.WebhookBackoff {
+		return fmt.Errorf("invalid webhook backoff: %s, maximum %s", c.WebhookBackoff, c.WebhookMaxBackoff)
+	}
+
+	if c.ActivityHeartbeatInterval <= 0 {
+		return fmt.Errorf("invalid activity heartbeat interval: %s", c.ActivityHeartbeatInterval)
+	}
+
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("invalid request timeout: %s", c.RequestTimeout)
+	}
+
+	if c.IdempotencyTTL < 0 {
+		return fmt.Errorf("invalid idempotency ttl: %s", c.IdempotencyTTL)
+	}
+
+	if c.PaymentMessageTTL < 0 {
+		return fmt.Errorf("invalid payment message ttl: %s", c.PaymentMessageTTL)
+	}
+
+	routeTimeouts, err := parseRouteTimeouts(c.RouteTimeouts)
+	if err != nil {
+		return err
+	}
+	c.routeTimeouts = routeTimeouts
+
+	deprecations, err := parseDeprecations(c.DeprecatedRoutes)
+	if err != nil {
+		return err
+	}
+	c.deprecations = deprecations
+
+	if c.AuthEnabled && c.AdminAPIKey == "" && c.APIKeysPath == "" && c.JWKS == "" {
+		return fmt.Errorf("an admin api key, an api keys file or a jwks is required to enable authentication")
+	}
+	if c.JWKS != "" && (c.JWTOwnerClaim == "" || c.JWTRoleClaim == "") {
+		return fmt.Errorf("the jwt owner and role claims are required to accept jwts")
+	}
+
+	if c.HasTransport(enum.GRPCTransport) && c.GRPCPort == "" {
+		return fmt.Errorf("the grpc port is required to serve the grpc transport")
+	}
+	if c.HasTransport(enum.GraphQLTransport) && c.GraphQLPort == "" {
+		return fmt.Errorf("the graphql port is required to serve the graphql transport")
+	}
+
 	v := validator.New()
 	return v.Struct(c)
 }
+
+// AllowedOrigins returns the origins allowed to open WebSocket connections.
+func (c *Config) AllowedOrigins() []string {
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(c.WebSocketAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,76 @@
+package conf
+
+import (
+	"bank_test/internal/enum"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayout is the layout of the dates of the deprecations.
+const dateLayout = "2006-01-02"
+
+// Deprecation tells when a version of the API or a route was deprecated, and when it will be removed.
+type Deprecation struct {
+	Date   time.Time // date from which the route is deprecated
+	Sunset time.Time // date from which the route may stop responding. Zero when it is not scheduled
+}
+
+// parseDeprecations parses a comma separated list of deprecations in the format 'selector=date[/sunset]', where the
+// selector is either a version of the API or a route of a version ('METHOD /version/pattern', with the pattern used
+// to register the route), and the dates are in the YYYY-MM-DD format.
+//
+//	v1=2026-01-01/2026-12-31,GET /v2/accounts=2026-06-01
+func parseDeprecations(value string) (map[string]Deprecation, error) {
+	deprecations := make(map[string]Deprecation)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid deprecation '%s': expected 'selector=date[/sunset]'", entry)
+		}
+		selector, dates := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+
+		key := strings.ToLower(selector)
+		if method, pattern, ok := strings.Cut(selector, " "); ok {
+			version, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/"), "/")
+			if !isHTTPMethod(method) || !strings.HasPrefix(pattern, "/") || !enum.APIVersion(version).IsValid() {
+				return nil, fmt.Errorf("invalid deprecated route '%s': expected 'METHOD /version/pattern'", selector)
+			}
+			key = routeKey(method, pattern)
+		} else if !enum.APIVersion(key).IsValid() {
+			return nil, fmt.Errorf("invalid deprecated version '%s'", selector)
+		}
+
+		rawDate, rawSunset, hasSunset := strings.Cut(dates, "/")
+		date, err := time.Parse(dateLayout, rawDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deprecation date '%s' for '%s'", rawDate, selector)
+		}
+		deprecation := Deprecation{Date: date}
+		if hasSunset {
+			sunset, err := time.Parse(dateLayout, rawSunset)
+			if err != nil || !sunset.After(date) {
+				return nil, fmt.Errorf("invalid sunset date '%s' for '%s'", rawSunset, selector)
+			}
+			deprecation.Sunset = sunset
+		}
+		deprecations[key] = deprecation
+	}
+	return deprecations, nil
+}
+
+// RouteDeprecation returns the deprecation of the route registered with the method and pattern in a version of the
+// API. The deprecation of the route takes precedence over the one of its version. The second value is false when the
+// route is not deprecated.
+func (c *Config) RouteDeprecation(version enum.APIVersion, method, pattern string) (Deprecation, bool) {
+	if deprecation, ok := c.deprecations[routeKey(method, "/"+version.String()+pattern)]; ok {
+		return deprecation, true
+	}
+	deprecation, ok := c.deprecations[version.String()]
+	return deprecation, ok
+}
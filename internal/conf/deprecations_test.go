@@ -0,0 +1,67 @@
+package conf
+
+import (
+	"bank_test/internal/enum"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DeprecationsTestSuite struct {
+	suite.Suite
+}
+
+// TestParseDeprecations tests parsing the DEPRECATED_ROUTES configuration value.
+func (suite *DeprecationsTestSuite) TestParseDeprecations() {
+	date := func(value string) time.Time {
+		t, err := time.Parse(dateLayout, value)
+		suite.Require().NoError(err)
+		return t
+	}
+
+	suite.Run("ok: versions and routes", func() {
+		deprecations, err := parseDeprecations("V1=2026-01-01/2026-12-31, get /v2/accounts/{id}=2026-06-01,")
+		suite.Require().NoError(err)
+		suite.Equal(map[string]Deprecation{
+			"v1":                    {Date: date("2026-01-01"), Sunset: date("2026-12-31")},
+			"GET /v2/accounts/{id}": {Date: date("2026-06-01")},
+		}, deprecations)
+	})
+
+	suite.Run("ok: empty value", func() {
+		deprecations, err := parseDeprecations("")
+		suite.Require().NoError(err)
+		suite.Empty(deprecations)
+	})
+
+	for _, value := range []string{"v1", "v3=2026-01-01", "GET /accounts=2026-01-01", "FETCH /v1/accounts=2026-01-01", "v1=tomorrow", "v1=2026-01-01/never", "v1=2026-01-01/2025-01-01"} {
+		suite.Run("error: "+value, func() {
+			_, err := parseDeprecations(value)
+			suite.Error(err)
+		})
+	}
+}
+
+// TestRouteDeprecation tests that the deprecation of a route takes precedence over the one of its version.
+func (suite *DeprecationsTestSuite) TestRouteDeprecation() {
+	c := &Config{DeprecatedRoutes: "v1=2026-01-01/2026-12-31,POST /v1/transfer=2025-06-01/2025-12-31"}
+	deprecations, err := parseDeprecations(c.DeprecatedRoutes)
+	suite.Require().NoError(err)
+	c.deprecations = deprecations
+
+	deprecation, ok := c.RouteDeprecation(enum.V1, "POST", "/transfer")
+	suite.True(ok)
+	suite.Equal(2025, deprecation.Date.Year())
+
+	deprecation, ok = c.RouteDeprecation(enum.V1, "GET", "/accounts")
+	suite.True(ok)
+	suite.Equal(2026, deprecation.Date.Year())
+
+	_, ok = c.RouteDeprecation(enum.V2, "POST", "/transfer")
+	suite.False(ok)
+}
+
+func TestDeprecationsTestSuite(t *testing.T) {
+	suite.Run(t, new(DeprecationsTestSuite))
+}
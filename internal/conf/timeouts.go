@@ -0,0 +1,64 @@
+package conf
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// parseRouteTimeouts parses a comma separated list of route timeouts in the format 'METHOD /pattern=duration', where
+// the pattern is the one used to register the route. A zero duration disables the timeout of the route.
+//
+//	POST /admin/reconciliations=1m,GET /admin/backup=0
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid route timeout '%s': expected 'METHOD /pattern=duration'", entry)
+		}
+		route, rawTimeout := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+
+		method, pattern, ok := strings.Cut(route, " ")
+		if !ok || !isHTTPMethod(method) || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid route '%s': expected 'METHOD /pattern'", route)
+		}
+
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout '%s' for route '%s'", rawTimeout, route)
+		}
+		timeouts[routeKey(method, pattern)] = timeout
+	}
+	return timeouts, nil
+}
+
+// RouteTimeout returns the timeout of the route registered with the method and pattern. Routes without a specific
+// timeout use REQUEST_TIMEOUT. A zero timeout means that the requests of the route never time out.
+func (c *Config) RouteTimeout(method, pattern string) time.Duration {
+	if timeout, ok := c.routeTimeouts[routeKey(method, pattern)]; ok {
+		return timeout
+	}
+	return c.RequestTimeout
+}
+
+// routeKey builds the key of a route in the timeouts map.
+func routeKey(method, pattern string) string {
+	return strings.ToUpper(method) + " " + strings.TrimSpace(pattern)
+}
+
+// isHTTPMethod checks whether the method is one of the methods used by the routes.
+func isHTTPMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RouteTimeoutsTestSuite struct {
+	suite.Suite
+}
+
+// TestParseRouteTimeouts tests parsing the ROUTE_TIMEOUTS configuration value.
+func (suite *RouteTimeoutsTestSuite) TestParseRouteTimeouts() {
+	suite.Run("ok: routes with timeouts", func() {
+		timeouts, err := parseRouteTimeouts("POST /admin/reconciliations=1m, get /accounts/{id}=500ms,GET /admin/backup=0,")
+		suite.Require().NoError(err)
+		suite.Equal(map[string]time.Duration{
+			"POST /admin/reconciliations": time.Minute,
+			"GET /accounts/{id}":          500 * time.Millisecond,
+			"GET /admin/backup":           0,
+		}, timeouts)
+	})
+
+	suite.Run("ok: empty value", func() {
+		timeouts, err := parseRouteTimeouts("")
+		suite.Require().NoError(err)
+		suite.Empty(timeouts)
+	})
+
+	for _, value := range []string{"POST /transfer", "/transfer=1s", "FETCH /transfer=1s", "POST transfer=1s", "POST /transfer=soon", "POST /transfer=-1s"} {
+		suite.Run("error: "+value, func() {
+			_, err := parseRouteTimeouts(value)
+			suite.Error(err)
+		})
+	}
+}
+
+// TestRouteTimeout tests that routes without a specific timeout use the default one.
+func (suite *RouteTimeoutsTestSuite) TestRouteTimeout() {
+	c := &Config{RequestTimeout: 10 * time.Second, RouteTimeouts: "POST /transfer=2s,GET /admin/backup=0"}
+	timeouts, err := parseRouteTimeouts(c.RouteTimeouts)
+	suite.Require().NoError(err)
+	c.routeTimeouts = timeouts
+
+	suite.Equal(2*time.Second, c.RouteTimeout("POST", "/transfer"))
+	suite.Equal(time.Duration(0), c.RouteTimeout("GET", "/admin/backup"))
+	suite.Equal(10*time.Second, c.RouteTimeout("GET", "/accounts"))
+}
+
+func TestRouteTimeoutsTestSuite(t *testing.T) {
+	suite.Run(t, new(RouteTimeoutsTestSuite))
+}
@@ -0,0 +1,44 @@
+package conf
+
+import (
+	"bank_test/internal/enum"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EnabledTransports returns the transport layers that serve the API, in the order they are configured.
+func (c *Config) EnabledTransports() []enum.Transport {
+	return c.transports
+}
+
+// HasTransport checks whether the transport layer serves the API.
+func (c *Config) HasTransport(transport enum.Transport) bool {
+	for _, t := range c.transports {
+		if t == transport {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTransports parses a comma separated list of transports. Duplicates are ignored.
+func parseTransports(value string) ([]enum.Transport, error) {
+	transports := make([]enum.Transport, 0)
+	for _, entry := range strings.Split(value, ",") {
+		t := enum.Transport(strings.ToLower(strings.TrimSpace(entry)))
+		if t == "" {
+			continue
+		}
+		if !t.IsValid() {
+			return nil, fmt.Errorf("invalid transport: %s", t)
+		}
+		if !slices.Contains(transports, t) {
+			transports = append(transports, t)
+		}
+	}
+	if len(transports) == 0 {
+		return nil, fmt.Errorf("at least one transport is required")
+	}
+	return transports, nil
+}
@@ -0,0 +1,45 @@
+package conf
+
+import (
+	"bank_test/internal/enum"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportsTestSuite struct {
+	suite.Suite
+}
+
+// TestParseTransports tests parsing the TRANSPORTS configuration value.
+func (suite *TransportsTestSuite) TestParseTransports() {
+	suite.Run("ok: single transport", func() {
+		transports, err := parseTransports("http")
+		suite.Require().NoError(err)
+		suite.Equal([]enum.Transport{enum.HTTPTransport}, transports)
+	})
+
+	suite.Run("ok: several transports", func() {
+		transports, err := parseTransports(" GRPC, http,grpc,graphql")
+		suite.Require().NoError(err)
+		suite.Equal([]enum.Transport{enum.GRPCTransport, enum.HTTPTransport, enum.GraphQLTransport}, transports)
+	})
+
+	for _, value := range []string{"", " , ", "http,soap"} {
+		suite.Run("error: "+value, func() {
+			_, err := parseTransports(value)
+			suite.Error(err)
+		})
+	}
+}
+
+// TestHasTransport tests checking whether a transport is enabled.
+func (suite *TransportsTestSuite) TestHasTransport() {
+	c := &Config{transports: []enum.Transport{enum.GRPCTransport}}
+	suite.True(c.HasTransport(enum.GRPCTransport))
+	suite.False(c.HasTransport(enum.HTTPTransport))
+}
+
+func TestTransportsTestSuite(t *testing.T) {
+	suite.Run(t, new(TransportsTestSuite))
+}
@@ -27,4 +27,47 @@ func setDefaults() {
 	viper.SetDefault("LOG_LEVEL", "info")
 	viper.SetDefault("HEALTH_PORT", "8081")
 	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("TRANSPORTS", "http")
+	viper.SetDefault("GRPC_PORT", "9090")
+	viper.SetDefault("GRAPHQL_PORT", "8082")
+	viper.SetDefault("DB_DRIVER", "memory")
+	viper.SetDefault("EVENT_STORE_DIR", "")
+	viper.SetDefault("EVENT_SNAPSHOT_INTERVAL", 1000)
+	viper.SetDefault("SQLITE_PATH", "bank.db")
+	viper.SetDefault("DB_MIGRATE_ON_STARTUP", true)
+	viper.SetDefault("BOLT_PATH", "bank.bolt")
+	viper.SetDefault("DATA_DIR", "")
+	viper.SetDefault("WAL_SYNC_POLICY", "always")
+	viper.SetDefault("WAL_SYNC_INTERVAL", "1s")
+	viper.SetDefault("SNAPSHOT_INTERVAL", "5m")
+	viper.SetDefault("IBAN_COUNTRY_CODE", "ES")
+	viper.SetDefault("IBAN_BANK_CODE", "01820001")
+	viper.SetDefault("CURRENCY", "EUR")
+	viper.SetDefault("RECONCILIATION_INTERVAL", "1h")
+	viper.SetDefault("AUDIT_LOG_PATH", "")
+	viper.SetDefault("WEBHOOKS_PATH", "")
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "1s")
+	viper.SetDefault("WEBHOOK_TIMEOUT", "5s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_BACKOFF", "1s")
+	viper.SetDefault("WEBHOOK_MAX_BACKOFF", "10m")
+	viper.SetDefault("ACTIVITY_BUFFER_SIZE", 256)
+	viper.SetDefault("ACTIVITY_HEARTBEAT_INTERVAL", "15s")
+	viper.SetDefault("WEBSOCKET_ALLOWED_ORIGINS", "")
+	viper.SetDefault("REQUEST_TIMEOUT", "10s")
+	viper.SetDefault("ROUTE_TIMEOUTS", "POST /admin/reconciliations=1m,GET /admin/backup=0,GET /accounts/{id}/events=0,GET /ws=0,GET /accounts/{id}/statements/export=5m,POST /payments/pain001=1m")
+	viper.SetDefault("DEPRECATED_ROUTES", "")
+	viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+	viper.SetDefault("OPENAPI_VALIDATION", false)
+	viper.SetDefault("AUTH_ENABLED", false)
+	viper.SetDefault("API_KEYS_PATH", "")
+	viper.SetDefault("ADMIN_API_KEY", "")
+	viper.SetDefault("JWKS", "")
+	viper.SetDefault("JWT_ISSUER", "")
+	viper.SetDefault("JWT_AUDIENCE", "")
+	viper.SetDefault("JWT_OWNER_CLAIM", "sub")
+	viper.SetDefault("JWT_ROLE_CLAIM", "role")
+	viper.SetDefault("FROZEN_ACCOUNTS_PATH", "")
+	viper.SetDefault("PAYMENT_MESSAGES_PATH", "")
+	viper.SetDefault("PAYMENT_MESSAGE_TTL", "2160h")
 }
@@ -0,0 +1,22 @@
+package boltdb_test
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/boltdb"
+	"bank_test/internal/db/dbtest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestBoltDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		database, err := boltdb.NewBoltDatabase(zap.NewNop().Sugar(), filepath.Join(t.TempDir(), "bank.bolt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database
+	}})
+}
@@ -0,0 +1,600 @@
+package boltdb
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// openTimeout is the time to wait for the lock of the database file, which is held by the process that has it open.
+const openTimeout = time.Second
+
+// scanPageSize is the number of transactions read at once by ScanTransactions.
+const scanPageSize = 500
+
+// Buckets of the database.
+var (
+	accountsBucket     = []byte("accounts")     // account id -> account
+	ibansBucket        = []byte("ibans")        // iban -> account id
+	transactionsBucket = []byte("transactions") // account id -> bucket of transactions of the account
+	outboxBucket       = []byte("outbox")       // sequence number -> event waiting to be dispatched
+)
+
+// boltDatabase is a database stored in a bbolt file. Every mutation runs in a bolt update transaction, which are
+// serialized and fsynced before they are committed.
+//
+// Accounts are stored as JSON in the accounts bucket. The transactions of every account are stored in a nested bucket
+// of the transactions bucket, keyed by their timestamp and a sequence number so that a cursor iterates them in time order.
+type boltDatabase struct {
+	logger *zap.SugaredLogger
+	db     *bolt.DB
+}
+
+// NewBoltDatabase opens the bbolt database stored in path, creating it if it does not exist.
+func NewBoltDatabase(logger *zap.SugaredLogger, path string) (*boltDatabase, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %v", err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{accountsBucket, ibansBucket, transactionsBucket, outboxBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bolt buckets: %v", err)
+	}
+
+	logger.Infof("bolt database opened at '%s'", path)
+	return &boltDatabase{logger: logger, db: db}, nil
+}
+
+// CreateAccount creates a new account in the database.
+func (d *boltDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	d.logger.Debugf("storing account with id '%s' in bolt database: %s", account.ID, helpers.PrettyPrintStructResponse(account))
+	err := d.update(ctx, func(tx *bolt.Tx) error {
+		return createAccount(tx, account)
+	})
+	if err != nil {
+		return err
+	}
+	d.logger.Debugf("account with id '%s' stored in bolt database", account.ID)
+	return nil
+}
+
+// GetAccountByID retrieves an account from the database by its id.
+func (d *boltDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	d.logger.Debugf("getting account with id '%s' from bolt database", id)
+
+	var acc *models.Account
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		var err error
+		acc, err = d.getAccount(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetAccountByIBAN retrieves an account from the database by its iban.
+func (d *boltDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	d.logger.Debugf("getting account with iban '%s' from bolt database", iban)
+
+	var acc *models.Account
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		id := tx.Bucket(ibansBucket).Get([]byte(iban))
+		if id == nil {
+			d.logger.Debugf("account with iban '%s' not found", iban)
+			return errors.ErrAccountNotFound
+		}
+
+		var err error
+		acc, err = d.getAccount(tx, string(id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetAllAccounts retrieves all accounts from the database, sorted by id.
+func (d *boltDatabase) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
+	d.logger.Debugf("getting all accounts from bolt database")
+
+	accounts := make([]models.Account, 0)
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket(accountsBucket).ForEach(func(_, v []byte) error {
+			var acc models.Account
+			if err := json.Unmarshal(v, &acc); err != nil {
+				return err
+			}
+			accounts = append(accounts, acc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// CreateTransaction stores the transaction and updates the balance of its account in a single bolt transaction.
+func (d *boltDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	d.logger.Debugf("storing transaction with id '%s' in bolt database: %s", transaction.ID, helpers.PrettyPrintStructResponse(transaction))
+	if err := d.update(ctx, func(tx *bolt.Tx) error {
+		return d.createTransaction(tx, transaction)
+	}); err != nil {
+		return err
+	}
+	d.logger.Debugf("transaction with id '%s' stored in bolt database", transaction.ID)
+	return nil
+}
+
+// Import creates the accounts and stores the transactions, in order, in a single bolt transaction, which is rolled
+// back if any of them fails.
+func (d *boltDatabase) Import(ctx context.Context, accounts []models.Account, transactions []models.Transaction) error {
+	d.logger.Debugf("importing %d accounts and %d transactions in bolt database", len(accounts), len(transactions))
+	failed := "" // record that failed, if any
+	err := d.update(ctx, func(tx *bolt.Tx) error {
+		for i := range accounts {
+			if err := createAccount(tx, &accounts[i]); err != nil {
+				failed = fmt.Sprintf("account '%s'", accounts[i].ID)
+				return err
+			}
+		}
+		for i := range transactions {
+			if err := d.createTransaction(tx, &transactions[i]); err != nil {
+				failed = fmt.Sprintf("transaction '%s'", transactions[i].ID)
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed != "" {
+			return fmt.Errorf("failed to import %s: %w", failed, err)
+		}
+		return err
+	}
+	d.logger.Debugf("%d accounts and %d transactions imported in bolt database", len(accounts), len(transactions))
+	return nil
+}
+
+// Transfer stores the withdrawal and the deposit of a transfer in a single bolt transaction.
+func (d *boltDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	d.logger.Debugf("transferring %f from account '%s' to account '%s' in bolt database", withdrawal.Amount, withdrawal.AccountID, deposit.AccountID)
+	err := d.update(ctx, func(tx *bolt.Tx) error {
+		return d.transfer(tx, withdrawal, deposit)
+	})
+	if err != nil {
+		return err
+	}
+	d.logger.Debugf("transfer stored in bolt database: withdrawal '%s', deposit '%s'", withdrawal.ID, deposit.ID)
+	return nil
+}
+
+// TransferBatch stores the legs of the transfers, in order, in a single bolt transaction, which is rolled back if any
+// of them fails.
+func (d *boltDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	d.logger.Debugf("storing a batch of %d transfers in bolt database", len(transfers))
+	failed := -1
+	err := d.update(ctx, func(tx *bolt.Tx) error {
+		for i := range transfers {
+			if err := d.transfer(tx, &transfers[i].Withdrawal, &transfers[i].Deposit); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed >= 0 {
+			return &errors.BatchError{Index: failed, Err: err}
+		}
+		return err
+	}
+	d.logger.Debugf("batch of %d transfers stored in bolt database", len(transfers))
+	return nil
+}
+
+// transfer applies both legs of a transfer and stores its event in the bolt transaction.
+func (d *boltDatabase) transfer(tx *bolt.Tx, withdrawal, deposit *models.Transaction) error {
+	// the destination is checked first so that a missing account is reported before an insufficient balance
+	if _, err := d.getAccount(tx, deposit.AccountID); err != nil {
+		return err
+	}
+	if err := d.applyTransaction(tx, withdrawal); err != nil {
+		return err
+	}
+	if err := d.applyTransaction(tx, deposit); err != nil {
+		return err
+	}
+	transfer := models.Transfer{Withdrawal: *withdrawal, Deposit: *deposit}
+	return putEvent(tx, models.NewOutboxEvent(enum.TransferCompleted, withdrawal.AccountID, transfer))
+}
+
+// GetTransactionsByAccountID retrieves all transactions for an account from the database, sorted by timestamp.
+func (d *boltDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	d.logger.Debugf("getting all transactions for account with id '%s' from bolt database", id)
+
+	var txs []models.Transaction
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket(transactionsBucket).Bucket([]byte(id))
+		if b == nil {
+			return errors.ErrAccountNotFound
+		}
+
+		txs = make([]models.Transaction, 0, b.Stats().KeyN)
+		return b.ForEach(func(_, v []byte) error {
+			var t models.Transaction
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			txs = append(txs, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// ScanTransactions calls fn for every transaction of an account in the period [from, to), in time order. The keys of
+// the transactions start with their timestamp, so the cursor seeks the start of the period and stops at its end. The
+// transactions are read by pages of scanPageSize, each one in its own read transaction, so that none is kept open while
+// fn runs.
+func (d *boltDatabase) ScanTransactions(ctx context.Context, id string, from, to time.Time, fn func(models.Transaction) error) error {
+	d.logger.Debugf("scanning the transactions of account with id '%s' from bolt database", id)
+
+	var end []byte
+	if !to.IsZero() {
+		end = transactionKey(to, 0)
+	}
+	var last []byte // key of the last transaction read
+	for {
+		page := make([]models.Transaction, 0, scanPageSize)
+		err := d.view(ctx, func(tx *bolt.Tx) error {
+			b := tx.Bucket(transactionsBucket).Bucket([]byte(id))
+			if b == nil {
+				return errors.ErrAccountNotFound
+			}
+
+			c := b.Cursor()
+			var k, v []byte
+			if last == nil {
+				k, v = c.Seek(transactionKey(from, 0))
+			} else if k, v = c.Seek(last); bytes.Equal(k, last) {
+				k, v = c.Next()
+			}
+			for ; k != nil && len(page) < scanPageSize; k, v = c.Next() {
+				if end != nil && bytes.Compare(k, end) >= 0 {
+					break
+				}
+				var t models.Transaction
+				if err := json.Unmarshal(v, &t); err != nil {
+					return err
+				}
+				page = append(page, t)
+				// the keys are only valid during the read transaction
+				last = append(last[:0], k...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, t := range page {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+	}
+}
+
+// GetTransactionsByAccountIDs retrieves the transactions of several accounts in a single read transaction.
+func (d *boltDatabase) GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error) {
+	d.logger.Debugf("getting all transactions for %d accounts from bolt database", len(ids))
+
+	result := make(map[string][]models.Transaction, len(ids))
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			b := tx.Bucket(transactionsBucket).Bucket([]byte(id))
+			if b == nil {
+				continue
+			}
+
+			txs := make([]models.Transaction, 0, b.Stats().KeyN)
+			err := b.ForEach(func(_, v []byte) error {
+				var t models.Transaction
+				if err := json.Unmarshal(v, &t); err != nil {
+					return err
+				}
+				txs = append(txs, t)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			result[id] = txs
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PendingEvents retrieves the oldest events of the outbox, in the order they were stored.
+func (d *boltDatabase) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	events := make([]models.OutboxEvent, 0)
+	err := d.view(ctx, func(tx *bolt.Tx) error {
+		c := tx.Bucket(outboxBucket).Cursor()
+		for k, v := c.First(); k != nil && (limit <= 0 || len(events) < limit); k, v = c.Next() {
+			var e models.OutboxEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteEvents removes the events from the outbox once they are dispatched. The outbox only holds the events that
+// were not dispatched yet, so it is scanned instead of keeping an index of the events by id.
+func (d *boltDatabase) DeleteEvents(ctx context.Context, ids ...string) error {
+	d.logger.Debugf("deleting %d events from the outbox of the bolt database", len(ids))
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	return d.update(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+
+		// keys are collected first because deleting while iterating with a cursor skips entries
+		var keys [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var e models.OutboxEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if remove[e.ID] {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Backup writes a consistent copy of the database to w without blocking the mutations. It returns the number of
+// bytes written.
+func (d *boltDatabase) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	d.logger.Debugf("backing up bolt database")
+
+	var n int64
+	err := d.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(&contextWriter{ctx: ctx, w: w})
+		return err
+	})
+	if err != nil {
+		d.logger.Errorf("failed to back up bolt database: %v", err)
+		return n, errors.FromContext(err)
+	}
+	d.logger.Debugf("bolt database backed up: %d bytes written", n)
+	return n, nil
+}
+
+// Close closes the database.
+func (d *boltDatabase) Close() error {
+	return d.db.Close()
+}
+
+// createTransaction applies the transaction and stores its event in the bolt transaction.
+func (d *boltDatabase) createTransaction(tx *bolt.Tx, transaction *models.Transaction) error {
+	if err := d.applyTransaction(tx, transaction); err != nil {
+		return err
+	}
+	return putEvent(tx, models.NewOutboxEvent(enum.TransactionCreated, transaction.AccountID, transaction))
+}
+
+// applyTransaction updates the balance of the account and stores the transaction.
+func (d *boltDatabase) applyTransaction(tx *bolt.Tx, transaction *models.Transaction) error {
+	account, err := d.getAccount(tx, transaction.AccountID)
+	if err != nil {
+		return err
+	}
+
+	d.logger.Debugf("updating account balance")
+	switch transaction.Type {
+	case enum.Deposit:
+		account.Balance += transaction.Amount
+	case enum.Withdrawal:
+		if account.Balance < transaction.Amount {
+			d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", transaction.AccountID))
+			return errors.ErrInsufficientBalance
+		}
+		account.Balance -= transaction.Amount
+	default:
+		d.logger.Error(fmt.Sprintf("invalid transaction type '%s'", transaction.Type))
+		return errors.ErrUnknown
+	}
+
+	if err := putAccount(tx, account); err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket(transactionsBucket).CreateBucketIfNotExists([]byte(transaction.AccountID))
+	if err != nil {
+		return err
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	return b.Put(transactionKey(transaction.Timestamp, seq), data)
+}
+
+// getAccount reads an account in the bolt transaction.
+func (d *boltDatabase) getAccount(tx *bolt.Tx, id string) (*models.Account, error) {
+	data := tx.Bucket(accountsBucket).Get([]byte(id))
+	if data == nil {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", id))
+		return nil, errors.ErrAccountNotFound
+	}
+
+	var acc models.Account
+	if err := json.Unmarshal(data, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// update runs fn in a bolt update transaction. Errors that are not API errors are logged and reported as unknown errors.
+// Bolt transactions cannot be interrupted, so the context is checked before waiting for the writer lock and before
+// committing.
+func (d *boltDatabase) update(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	return d.wrapError(d.db.Update(func(tx *bolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errors.FromContext(ctx.Err())
+	}))
+}
+
+// view runs fn in a bolt read-only transaction. Errors that are not API errors are logged and reported as unknown errors.
+func (d *boltDatabase) view(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	return d.wrapError(d.db.View(fn))
+}
+
+// wrapError logs the errors that are not API errors and replaces them with an unknown error.
+func (d *boltDatabase) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*errors.APIError); ok {
+		return err
+	}
+	d.logger.Errorf("bolt transaction failed: %v", err)
+	return errors.ErrUnknown
+}
+
+// contextWriter is a writer that stops writing when the context is done, which aborts a backup whose client is gone.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *contextWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// createAccount stores the account, its iban and its event in the bolt transaction.
+func createAccount(tx *bolt.Tx, account *models.Account) error {
+	if account.IBAN != "" {
+		if tx.Bucket(ibansBucket).Get([]byte(account.IBAN)) != nil {
+			return fmt.Errorf("iban '%s' already exists", account.IBAN)
+		}
+		if err := tx.Bucket(ibansBucket).Put([]byte(account.IBAN), []byte(account.ID)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Bucket(transactionsBucket).CreateBucketIfNotExists([]byte(account.ID)); err != nil {
+		return err
+	}
+	if err := putAccount(tx, account); err != nil {
+		return err
+	}
+	return putEvent(tx, models.NewOutboxEvent(enum.AccountCreated, account.ID, account))
+}
+
+// putAccount stores the account in the accounts bucket.
+func putAccount(tx *bolt.Tx, account *models.Account) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(accountsBucket).Put([]byte(account.ID), data)
+}
+
+// putEvent stores the event at the end of the outbox bucket.
+func putEvent(tx *bolt.Tx, event *models.OutboxEvent) error {
+	b := tx.Bucket(outboxBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return b.Put(key, data)
+}
+
+// transactionKey builds the key of a transaction from its timestamp and a sequence number that breaks ties between
+// transactions with the same timestamp. Both are encoded in big endian so that keys are sorted in time order.
+func transactionKey(timestamp time.Time, seq uint64) []byte {
+	var nanos uint64
+	if !timestamp.IsZero() {
+		nanos = uint64(timestamp.UnixNano())
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], nanos)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
@@ -0,0 +1,181 @@
+package boltdb
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type BoltDatabaseTestSuite struct {
+	suite.Suite
+	db     *boltDatabase
+	logger *zap.SugaredLogger
+	path   string
+}
+
+func (suite *BoltDatabaseTestSuite) SetupTest() {
+	suite.logger = zap.NewExample().Sugar()
+	suite.path = filepath.Join(suite.T().TempDir(), "bank.bolt")
+
+	db, err := NewBoltDatabase(suite.logger, suite.path)
+	suite.Require().NoError(err)
+	suite.db = db
+}
+
+func (suite *BoltDatabaseTestSuite) TearDownTest() {
+	suite.db.Close()
+}
+
+// populate creates an account with a deposit and a withdrawal.
+func (suite *BoltDatabaseTestSuite) populate(id string) {
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: id, IBAN: "IBAN" + id, Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx1", AccountID: id, Type: enum.Deposit, Amount: 50, Timestamp: time.Now()}))
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx2", AccountID: id, Type: enum.Withdrawal, Amount: 30, Timestamp: time.Now()}))
+}
+
+// assertAccount checks the account created by populate.
+func (suite *BoltDatabaseTestSuite) assertAccount(db *boltDatabase, id string) {
+	account, err := db.GetAccountByID(context.Background(), id)
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+
+	account, err = db.GetAccountByIBAN(context.Background(), "IBAN"+id)
+	suite.Require().NoError(err)
+	suite.Equal(id, account.ID)
+
+	txs, err := db.GetTransactionsByAccountID(context.Background(), id)
+	suite.Require().NoError(err)
+	suite.Require().Len(txs, 2)
+	suite.Equal(id+"-tx1", txs[0].ID)
+	suite.Equal(id+"-tx2", txs[1].ID)
+}
+
+// TestAccountsAndTransactions tests storing and retrieving accounts and transactions.
+func (suite *BoltDatabaseTestSuite) TestAccountsAndTransactions() {
+	suite.populate("1")
+	suite.assertAccount(suite.db, "1")
+
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: "2", Owner: "Bob"}))
+	accounts, err := suite.db.GetAllAccounts(context.Background())
+	suite.Require().NoError(err)
+	suite.Len(accounts, 2)
+
+	txs, err := suite.db.GetTransactionsByAccountID(context.Background(), "2")
+	suite.Require().NoError(err)
+	suite.Empty(txs)
+}
+
+// TestTimeOrder tests that transactions are iterated by timestamp, not by insertion order.
+func (suite *BoltDatabaseTestSuite) TestTimeOrder() {
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: "1", Owner: "Alice"}))
+
+	now := time.Now()
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "late", AccountID: "1", Type: enum.Deposit, Amount: 10, Timestamp: now}))
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "early", AccountID: "1", Type: enum.Deposit, Amount: 10, Timestamp: now.Add(-time.Minute)}))
+
+	txs, err := suite.db.GetTransactionsByAccountID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Require().Len(txs, 2)
+	suite.Equal("early", txs[0].ID)
+	suite.Equal("late", txs[1].ID)
+}
+
+// TestErrors tests that invalid operations are rejected without modifying the database.
+func (suite *BoltDatabaseTestSuite) TestErrors() {
+	suite.populate("1")
+
+	err := suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx3", AccountID: "1", Type: enum.Withdrawal, Amount: 500})
+	suite.Equal(errors.ErrInsufficientBalance, err)
+
+	err = suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx4", AccountID: "nonexistent", Type: enum.Deposit, Amount: 10})
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetAccountByID(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetAccountByIBAN(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetTransactionsByAccountID(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	// duplicated ibans are rejected
+	suite.Error(suite.db.CreateAccount(context.Background(), &models.Account{ID: "2", IBAN: "IBAN1", Owner: "Bob"}))
+	_, err = suite.db.GetAccountByID(context.Background(), "2")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	suite.assertAccount(suite.db, "1")
+}
+
+// TestTransfer tests that both legs of a transfer are stored or none of them.
+func (suite *BoltDatabaseTestSuite) TestTransfer() {
+	suite.populate("1")
+	suite.populate("2")
+
+	suite.Require().NoError(suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w1", AccountID: "1", Type: enum.Withdrawal, Amount: 20},
+		&models.Transaction{ID: "d1", AccountID: "2", Type: enum.Deposit, Amount: 20},
+	))
+
+	err := suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w2", AccountID: "1", Type: enum.Withdrawal, Amount: 20},
+		&models.Transaction{ID: "d2", AccountID: "nonexistent", Type: enum.Deposit, Amount: 20},
+	)
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	err = suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w3", AccountID: "1", Type: enum.Withdrawal, Amount: 500},
+		&models.Transaction{ID: "d3", AccountID: "2", Type: enum.Deposit, Amount: 500},
+	)
+	suite.Equal(errors.ErrInsufficientBalance, err)
+
+	from, err := suite.db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(100), from.Balance)
+	to, err := suite.db.GetAccountByID(context.Background(), "2")
+	suite.Require().NoError(err)
+	suite.Equal(float64(140), to.Balance)
+}
+
+// TestPersistenceAndBackup tests that the data survives reopening the database and that a backup can be opened as a database.
+func (suite *BoltDatabaseTestSuite) TestPersistenceAndBackup() {
+	suite.populate("1")
+
+	backupPath := filepath.Join(suite.T().TempDir(), "backup.bolt")
+	f, err := os.Create(backupPath)
+	suite.Require().NoError(err)
+	n, err := suite.db.Backup(context.Background(), f)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+	suite.Positive(n)
+
+	// mutations after the backup are not included in it
+	suite.populate("2")
+	suite.Require().NoError(suite.db.Close())
+
+	reopened, err := NewBoltDatabase(suite.logger, suite.path)
+	suite.Require().NoError(err)
+	suite.db = reopened
+	suite.assertAccount(reopened, "1")
+	suite.assertAccount(reopened, "2")
+
+	restored, err := NewBoltDatabase(suite.logger, backupPath)
+	suite.Require().NoError(err)
+	defer restored.Close()
+	suite.assertAccount(restored, "1")
+	_, err = restored.GetAccountByID(context.Background(), "2")
+	suite.Equal(errors.ErrAccountNotFound, err)
+}
+
+func TestBoltDatabaseTestSuite(t *testing.T) {
+	suite.Run(t, new(BoltDatabaseTestSuite))
+}
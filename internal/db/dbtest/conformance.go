@@ -0,0 +1,570 @@
+// Package dbtest provides a conformance suite that checks the behavior every DatabaseAdapter must guarantee.
+//
+// Adapters run it from an external test package, so that importing the db package does not create an import cycle:
+//
+//	func TestConformance(t *testing.T) {
+//		suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+//			return memory.NewInMemoryDatabase(zap.NewNop().Sugar())
+//		}})
+//	}
+//
+// The concurrency tests are meant to be run with the race detector enabled (go test -race).
+package dbtest
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+// workers is the number of goroutines used by the concurrency tests.
+const workers = 50
+
+// ConformanceSuite is the test suite that every DatabaseAdapter implementation must pass.
+type ConformanceSuite struct {
+	suite.Suite
+
+	// NewDatabase creates an empty database for every test. Databases that implement io.Closer are closed after the test.
+	NewDatabase func(t *testing.T) db.DatabaseAdapter
+
+	db  db.DatabaseAdapter
+	ctx context.Context
+}
+
+func (s *ConformanceSuite) SetupTest() {
+	s.Require().NotNil(s.NewDatabase, "NewDatabase must be set")
+	s.db = s.NewDatabase(s.T())
+	s.ctx = context.Background()
+}
+
+func (s *ConformanceSuite) TearDownTest() {
+	if closer, ok := s.db.(io.Closer); ok {
+		s.NoError(closer.Close())
+	}
+}
+
+// createAccount creates an account with the given balance and returns it.
+func (s *ConformanceSuite) createAccount(balance float64) *models.Account {
+	account := &models.Account{
+		ID:             uuid.NewString(),
+		IBAN:           "IBAN" + uuid.NewString(),
+		Owner:          "Alice",
+		Balance:        balance,
+		InitialBalance: balance,
+	}
+	s.Require().NoError(s.db.CreateAccount(s.ctx, account))
+	return account
+}
+
+// newTransaction builds a transaction of the given type for the account.
+func newTransaction(accountID string, txType enum.TransactionType, amount float64) *models.Transaction {
+	return &models.Transaction{
+		ID:        uuid.NewString(),
+		AccountID: accountID,
+		Type:      txType,
+		Amount:    amount,
+		Timestamp: time.Now(),
+	}
+}
+
+// assertBalance checks the stored balance of the account.
+func (s *ConformanceSuite) assertBalance(id string, expected float64) {
+	account, err := s.db.GetAccountByID(s.ctx, id)
+	s.Require().NoError(err)
+	s.InDelta(expected, account.Balance, 1e-9)
+}
+
+// assertTransactions checks the number of transactions stored for the account.
+func (s *ConformanceSuite) assertTransactions(id string, expected int) {
+	txs, err := s.db.GetTransactionsByAccountID(s.ctx, id)
+	s.Require().NoError(err)
+	s.Len(txs, expected)
+}
+
+// TestAccounts tests creating and retrieving accounts.
+func (s *ConformanceSuite) TestAccounts() {
+	account := s.createAccount(100)
+
+	s.Run("ok: get account by id", func() {
+		retrieved, err := s.db.GetAccountByID(s.ctx, account.ID)
+		s.Require().NoError(err)
+		s.Equal(*account, *retrieved)
+	})
+
+	s.Run("ok: get account by iban", func() {
+		retrieved, err := s.db.GetAccountByIBAN(s.ctx, account.IBAN)
+		s.Require().NoError(err)
+		s.Equal(*account, *retrieved)
+	})
+
+	s.Run("ok: get all accounts", func() {
+		other := s.createAccount(50)
+
+		accounts, err := s.db.GetAllAccounts(s.ctx)
+		s.Require().NoError(err)
+		s.ElementsMatch([]models.Account{*account, *other}, accounts)
+	})
+
+	s.Run("ok: new account has no transactions", func() {
+		s.assertTransactions(account.ID, 0)
+	})
+}
+
+// TestTransactions tests that deposits and withdrawals update the balance and are stored in order.
+func (s *ConformanceSuite) TestTransactions() {
+	account := s.createAccount(100)
+
+	deposit := newTransaction(account.ID, enum.Deposit, 50)
+	s.Require().NoError(s.db.CreateTransaction(s.ctx, deposit))
+	s.assertBalance(account.ID, 150)
+
+	withdrawal := newTransaction(account.ID, enum.Withdrawal, 150)
+	s.Require().NoError(s.db.CreateTransaction(s.ctx, withdrawal))
+	s.assertBalance(account.ID, 0)
+
+	txs, err := s.db.GetTransactionsByAccountID(s.ctx, account.ID)
+	s.Require().NoError(err)
+	s.Require().Len(txs, 2)
+	s.Equal(deposit.ID, txs[0].ID)
+	s.Equal(enum.Deposit, txs[0].Type)
+	s.Equal(float64(50), txs[0].Amount)
+	s.Equal(withdrawal.ID, txs[1].ID)
+	s.Equal(enum.Withdrawal, txs[1].Type)
+	s.True(withdrawal.Timestamp.Equal(txs[1].Timestamp))
+}
+
+// TestTransactionsByAccountIDs tests retrieving the transactions of several accounts at once.
+func (s *ConformanceSuite) TestTransactionsByAccountIDs() {
+	first := s.createAccount(100)
+	second := s.createAccount(100)
+	empty := s.createAccount(0)
+	for _, tx := range []*models.Transaction{
+		newTransaction(first.ID, enum.Deposit, 10),
+		newTransaction(second.ID, enum.Withdrawal, 20),
+		newTransaction(first.ID, enum.Withdrawal, 30),
+	} {
+		s.Require().NoError(s.db.CreateTransaction(s.ctx, tx))
+	}
+
+	s.Run("ok: transactions keyed by account", func() {
+		txs, err := s.db.GetTransactionsByAccountIDs(s.ctx, []string{first.ID, second.ID, empty.ID})
+		s.Require().NoError(err)
+		s.Require().Len(txs, 3)
+		s.Require().Len(txs[first.ID], 2)
+		s.Equal(float64(10), txs[first.ID][0].Amount)
+		s.Equal(float64(30), txs[first.ID][1].Amount)
+		s.Len(txs[second.ID], 1)
+		s.Empty(txs[empty.ID])
+	})
+
+	s.Run("ok: unknown accounts are left out", func() {
+		txs, err := s.db.GetTransactionsByAccountIDs(s.ctx, []string{uuid.NewString(), second.ID})
+		s.Require().NoError(err)
+		s.Len(txs, 1)
+		s.Contains(txs, second.ID)
+	})
+
+	s.Run("ok: no accounts", func() {
+		txs, err := s.db.GetTransactionsByAccountIDs(s.ctx, nil)
+		s.Require().NoError(err)
+		s.Empty(txs)
+	})
+}
+
+// TestScanTransactions tests reading the transactions of an account in a period, over several pages.
+func (s *ConformanceSuite) TestScanTransactions() {
+	from, to := s.createAccount(600), s.createAccount(0)
+	start := time.Now().Truncate(time.Millisecond)
+	transfers := make([]models.Transfer, 600)
+	for i := range transfers {
+		transfers[i] = models.Transfer{
+			Withdrawal: *newTransaction(from.ID, enum.Withdrawal, 1),
+			Deposit:    *newTransaction(to.ID, enum.Deposit, 1),
+		}
+		transfers[i].Withdrawal.Timestamp = start.Add(time.Duration(i) * time.Millisecond)
+		transfers[i].Deposit.Timestamp = transfers[i].Withdrawal.Timestamp
+	}
+	s.Require().NoError(s.db.TransferBatch(s.ctx, transfers))
+
+	// scan returns the ids of the transactions of the account in the period
+	scan := func(id string, from, to time.Time) []string {
+		var ids []string
+		s.Require().NoError(s.db.ScanTransactions(s.ctx, id, from, to, func(t models.Transaction) error {
+			ids = append(ids, t.ID)
+			return nil
+		}))
+		return ids
+	}
+
+	s.Run("ok: whole history", func() {
+		ids := scan(to.ID, time.Time{}, time.Time{})
+		s.Require().Len(ids, len(transfers))
+		for i, id := range ids {
+			s.Equal(transfers[i].Deposit.ID, id)
+		}
+	})
+
+	s.Run("ok: period", func() {
+		ids := scan(from.ID, start.Add(100*time.Millisecond), start.Add(550*time.Millisecond))
+		s.Require().Len(ids, 450)
+		s.Equal(transfers[100].Withdrawal.ID, ids[0])
+		s.Equal(transfers[549].Withdrawal.ID, ids[449])
+	})
+
+	s.Run("ok: stops at the first error", func() {
+		stop := stderrors.New("stop")
+		calls := 0
+		err := s.db.ScanTransactions(s.ctx, to.ID, time.Time{}, time.Time{}, func(models.Transaction) error {
+			calls++
+			if calls == 3 {
+				return stop
+			}
+			return nil
+		})
+		s.Equal(stop, err)
+		s.Equal(3, calls)
+	})
+
+	s.Run("error: unknown account", func() {
+		err := s.db.ScanTransactions(s.ctx, uuid.NewString(), time.Time{}, time.Time{}, func(models.Transaction) error { return nil })
+		s.Equal(errors.ErrAccountNotFound, err)
+	})
+}
+
+// TestNotFound tests that every operation on an unknown account fails with ErrAccountNotFound.
+func (s *ConformanceSuite) TestNotFound() {
+	account := s.createAccount(100)
+	unknown := uuid.NewString()
+
+	_, err := s.db.GetAccountByID(s.ctx, unknown)
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = s.db.GetAccountByIBAN(s.ctx, "IBAN"+unknown)
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = s.db.GetTransactionsByAccountID(s.ctx, unknown)
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	err = s.db.CreateTransaction(s.ctx, newTransaction(unknown, enum.Deposit, 10))
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	err = s.db.Transfer(s.ctx, newTransaction(unknown, enum.Withdrawal, 10), newTransaction(account.ID, enum.Deposit, 10))
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	err = s.db.Transfer(s.ctx, newTransaction(account.ID, enum.Withdrawal, 10), newTransaction(unknown, enum.Deposit, 10))
+	s.Equal(errors.ErrAccountNotFound, err)
+
+	// failed operations do not modify the existing account
+	s.assertBalance(account.ID, 100)
+	s.assertTransactions(account.ID, 0)
+}
+
+// TestInsufficientBalance tests that withdrawals and transfers above the balance are rejected without side effects.
+func (s *ConformanceSuite) TestInsufficientBalance() {
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+
+	err := s.db.CreateTransaction(s.ctx, newTransaction(from.ID, enum.Withdrawal, 100.01))
+	s.Equal(errors.ErrInsufficientBalance, err)
+
+	err = s.db.Transfer(s.ctx, newTransaction(from.ID, enum.Withdrawal, 200), newTransaction(to.ID, enum.Deposit, 200))
+	s.Equal(errors.ErrInsufficientBalance, err)
+
+	s.assertBalance(from.ID, 100)
+	s.assertBalance(to.ID, 0)
+	s.assertTransactions(from.ID, 0)
+	s.assertTransactions(to.ID, 0)
+}
+
+// TestTransfer tests that a transfer stores both legs, with the id of the transfer.
+func (s *ConformanceSuite) TestTransfer() {
+	from := s.createAccount(100)
+	to := s.createAccount(10)
+
+	withdrawal := newTransaction(from.ID, enum.Withdrawal, 40)
+	deposit := newTransaction(to.ID, enum.Deposit, 40)
+	withdrawal.TransferID = uuid.NewString()
+	deposit.TransferID = withdrawal.TransferID
+	s.Require().NoError(s.db.Transfer(s.ctx, withdrawal, deposit))
+
+	s.assertBalance(from.ID, 60)
+	s.assertBalance(to.ID, 50)
+
+	txs, err := s.db.GetTransactionsByAccountID(s.ctx, from.ID)
+	s.Require().NoError(err)
+	s.Require().Len(txs, 1)
+	s.Equal(withdrawal.ID, txs[0].ID)
+	s.Equal(withdrawal.TransferID, txs[0].TransferID)
+
+	txs, err = s.db.GetTransactionsByAccountID(s.ctx, to.ID)
+	s.Require().NoError(err)
+	s.Require().Len(txs, 1)
+	s.Equal(deposit.ID, txs[0].ID)
+	s.Equal(deposit.TransferID, txs[0].TransferID)
+}
+
+// TestTransferBatch tests that a batch of transfers is stored in order, as a whole or not at all.
+func (s *ConformanceSuite) TestTransferBatch() {
+	transfer := func(from, to *models.Account, amount float64) models.Transfer {
+		return models.Transfer{
+			Withdrawal: *newTransaction(from.ID, enum.Withdrawal, amount),
+			Deposit:    *newTransaction(to.ID, enum.Deposit, amount),
+		}
+	}
+
+	s.Run("ok", func() {
+		a, b, c := s.createAccount(100), s.createAccount(0), s.createAccount(0)
+
+		// the second transfer is covered by the first one
+		s.Require().NoError(s.db.TransferBatch(s.ctx, []models.Transfer{transfer(a, b, 60), transfer(b, c, 50)}))
+		s.assertBalance(a.ID, 40)
+		s.assertBalance(b.ID, 10)
+		s.assertBalance(c.ID, 50)
+		s.assertTransactions(b.ID, 2)
+	})
+
+	s.Run("error: insufficient balance", func() {
+		a, b := s.createAccount(100), s.createAccount(0)
+
+		err := s.db.TransferBatch(s.ctx, []models.Transfer{transfer(a, b, 60), transfer(a, b, 50)})
+		var batchErr *errors.BatchError
+		s.Require().ErrorAs(err, &batchErr)
+		s.Equal(1, batchErr.Index)
+		s.ErrorIs(err, errors.ErrInsufficientBalance)
+		s.assertBalance(a.ID, 100)
+		s.assertBalance(b.ID, 0)
+		s.assertTransactions(a.ID, 0)
+		s.assertTransactions(b.ID, 0)
+	})
+
+	s.Run("error: account not found", func() {
+		a, b := s.createAccount(100), s.createAccount(0)
+		missing := &models.Account{ID: uuid.NewString()}
+
+		err := s.db.TransferBatch(s.ctx, []models.Transfer{transfer(a, b, 10), transfer(a, missing, 10)})
+		var batchErr *errors.BatchError
+		s.Require().ErrorAs(err, &batchErr)
+		s.Equal(1, batchErr.Index)
+		s.ErrorIs(err, errors.ErrAccountNotFound)
+		s.assertBalance(a.ID, 100)
+		s.assertTransactions(b.ID, 0)
+	})
+}
+
+// TestImport tests importing accounts with their transactions in a single atomic step.
+func (s *ConformanceSuite) TestImport() {
+	importer, ok := s.db.(db.Importer)
+	if !ok {
+		s.T().Skip("the database does not implement db.Importer")
+	}
+
+	newAccount := func(balance float64) models.Account {
+		return models.Account{ID: uuid.NewString(), IBAN: "IBAN" + uuid.NewString(), Owner: "Alice", Balance: balance, InitialBalance: balance}
+	}
+
+	s.Run("ok", func() {
+		a, b := newAccount(100), newAccount(0)
+		s.Require().NoError(importer.Import(s.ctx, []models.Account{a, b}, []models.Transaction{
+			*newTransaction(a.ID, enum.Withdrawal, 30),
+			*newTransaction(b.ID, enum.Deposit, 30),
+			*newTransaction(b.ID, enum.Withdrawal, 10),
+		}))
+		s.assertBalance(a.ID, 70)
+		s.assertBalance(b.ID, 20)
+		s.assertTransactions(b.ID, 2)
+	})
+
+	s.Run("error: nothing stored", func() {
+		a, b := newAccount(100), newAccount(0)
+		err := importer.Import(s.ctx, []models.Account{a, b}, []models.Transaction{
+			*newTransaction(a.ID, enum.Deposit, 10),
+			*newTransaction(b.ID, enum.Withdrawal, 10),
+		})
+		s.ErrorIs(err, errors.ErrInsufficientBalance)
+		for _, id := range []string{a.ID, b.ID} {
+			_, err := s.db.GetAccountByID(s.ctx, id)
+			s.ErrorIs(err, errors.ErrAccountNotFound)
+		}
+	})
+}
+
+// TestConcurrentTransactions tests that concurrent deposits and withdrawals are neither lost nor allowed to overdraw
+// the account.
+func (s *ConformanceSuite) TestConcurrentTransactions() {
+	account := s.createAccount(float64(workers))
+
+	// every worker deposits 1 and tries to withdraw 2, so exactly half of the total withdrawals can succeed
+	// once all the deposits have been stored
+	var (
+		wg          sync.WaitGroup
+		withdrawals atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(s.db.CreateTransaction(s.ctx, newTransaction(account.ID, enum.Deposit, 1)))
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.db.CreateTransaction(s.ctx, newTransaction(account.ID, enum.Withdrawal, 2))
+			if err == nil {
+				withdrawals.Add(1)
+				return
+			}
+			s.Equal(errors.ErrInsufficientBalance, err)
+		}()
+	}
+	wg.Wait()
+
+	expected := float64(2*workers) - 2*float64(withdrawals.Load())
+	s.GreaterOrEqual(expected, float64(0))
+	s.assertBalance(account.ID, expected)
+	s.assertTransactions(account.ID, workers+int(withdrawals.Load()))
+}
+
+// TestConcurrentTransfers tests that concurrent transfers in both directions never create nor destroy money and
+// always store both legs or none.
+func (s *ConformanceSuite) TestConcurrentTransfers() {
+	a := s.createAccount(10)
+	b := s.createAccount(10)
+
+	var (
+		wg        sync.WaitGroup
+		transfers atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		from, to := a, b
+		if i%2 == 1 {
+			from, to = b, a
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.db.Transfer(s.ctx, newTransaction(from.ID, enum.Withdrawal, 3), newTransaction(to.ID, enum.Deposit, 3))
+			if err == nil {
+				transfers.Add(1)
+				return
+			}
+			s.Equal(errors.ErrInsufficientBalance, err)
+		}()
+	}
+	wg.Wait()
+
+	first, err := s.db.GetAccountByID(s.ctx, a.ID)
+	s.Require().NoError(err)
+	second, err := s.db.GetAccountByID(s.ctx, b.ID)
+	s.Require().NoError(err)
+	s.InDelta(float64(20), first.Balance+second.Balance, 1e-9)
+	s.GreaterOrEqual(first.Balance, float64(0))
+	s.GreaterOrEqual(second.Balance, float64(0))
+
+	firstTxs, err := s.db.GetTransactionsByAccountID(s.ctx, a.ID)
+	s.Require().NoError(err)
+	secondTxs, err := s.db.GetTransactionsByAccountID(s.ctx, b.ID)
+	s.Require().NoError(err)
+	s.Len(append(firstTxs, secondTxs...), 2*int(transfers.Load()))
+}
+
+// TestCanceledContext tests that operations whose context is done fail without modifying the database.
+func (s *ConformanceSuite) TestCanceledContext() {
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s.Run("error: canceled context", func() {
+		err := s.db.CreateAccount(ctx, &models.Account{ID: uuid.NewString(), Owner: "Bob"})
+		s.Equal(errors.ErrRequestCanceled, err)
+
+		err = s.db.CreateTransaction(ctx, newTransaction(from.ID, enum.Deposit, 10))
+		s.Equal(errors.ErrRequestCanceled, err)
+
+		err = s.db.Transfer(ctx, newTransaction(from.ID, enum.Withdrawal, 10), newTransaction(to.ID, enum.Deposit, 10))
+		s.Equal(errors.ErrRequestCanceled, err)
+
+		_, err = s.db.GetAccountByID(ctx, from.ID)
+		s.Equal(errors.ErrRequestCanceled, err)
+	})
+
+	s.Run("error: expired deadline", func() {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := s.db.GetTransactionsByAccountID(ctx, from.ID)
+		s.Equal(errors.ErrTimeout, err)
+	})
+
+	accounts, err := s.db.GetAllAccounts(s.ctx)
+	s.Require().NoError(err)
+	s.Len(accounts, 2)
+	s.assertBalance(from.ID, 100)
+	s.assertBalance(to.ID, 0)
+	s.assertTransactions(from.ID, 0)
+}
+
+// TestOutbox tests that every stored mutation adds one event to the outbox, and that rejected mutations add none.
+// It is skipped for the databases that do not implement db.Outbox.
+func (s *ConformanceSuite) TestOutbox() {
+	outbox, ok := s.db.(db.Outbox)
+	if !ok {
+		s.T().Skip("the database does not implement db.Outbox")
+	}
+
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+	deposit := newTransaction(from.ID, enum.Deposit, 10)
+	s.Require().NoError(s.db.CreateTransaction(s.ctx, deposit))
+	s.Require().NoError(s.db.Transfer(s.ctx, newTransaction(from.ID, enum.Withdrawal, 30), newTransaction(to.ID, enum.Deposit, 30)))
+
+	// rejected mutations
+	s.Require().Error(s.db.CreateTransaction(s.ctx, newTransaction(from.ID, enum.Withdrawal, 1000)))
+	s.Require().Error(s.db.Transfer(s.ctx, newTransaction(to.ID, enum.Withdrawal, 1000), newTransaction(from.ID, enum.Deposit, 1000)))
+
+	events, err := outbox.PendingEvents(s.ctx, 0)
+	s.Require().NoError(err)
+	s.Require().Len(events, 4)
+	s.Equal(enum.AccountCreated, events[0].Type)
+	s.Equal(from.ID, events[0].AccountID)
+	s.Equal(enum.AccountCreated, events[1].Type)
+	s.Equal(enum.TransactionCreated, events[2].Type)
+	s.Equal(enum.TransferCompleted, events[3].Type)
+	s.Equal(from.ID, events[3].AccountID)
+
+	var tx models.Transaction
+	s.Require().NoError(json.Unmarshal(events[2].Payload, &tx))
+	s.Equal(deposit.ID, tx.ID)
+
+	var transfer models.Transfer
+	s.Require().NoError(json.Unmarshal(events[3].Payload, &transfer))
+	s.Equal(to.ID, transfer.Deposit.AccountID)
+
+	s.Run("ok: limit", func() {
+		limited, err := outbox.PendingEvents(s.ctx, 2)
+		s.Require().NoError(err)
+		s.Equal(events[:2], limited)
+	})
+
+	s.Run("ok: delete", func() {
+		s.Require().NoError(outbox.DeleteEvents(s.ctx, events[0].ID, events[2].ID))
+		pending, err := outbox.PendingEvents(s.ctx, 0)
+		s.Require().NoError(err)
+		s.Equal([]models.OutboxEvent{events[1], events[3]}, pending)
+	})
+}
@@ -0,0 +1,21 @@
+package eventstore_test
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/eventstore"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestEventStoreDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		database, err := eventstore.NewEventStoreDatabase(zap.NewNop().Sugar(), eventstore.NewMemoryStream(), eventstore.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database
+	}})
+}
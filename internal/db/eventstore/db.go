@@ -0,0 +1,421 @@
+package eventstore
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Options configures the snapshots of the event store.
+type Options struct {
+	SnapshotDir      string // directory in which snapshots are stored. Empty disables snapshots
+	SnapshotInterval int    // number of events between two snapshots. 0 disables snapshots
+}
+
+// eventStoreDatabase is a database whose source of truth is an append-only stream of domain events. Accounts and
+// transactions are projections of the stream that are rebuilt when the database is created, starting from the
+// latest snapshot if there is one. Requests whose context is done are rejected before taking the lock.
+type eventStoreDatabase struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+
+	stream Stream
+	state  *state
+	opts   Options
+
+	eventsSinceSnapshot int
+
+	// broken is set when an event was appended to the stream but could not be applied to the projections. The
+	// projections no longer match the stream, so every later event is rejected with it.
+	broken error
+}
+
+// NewEventStoreDatabase creates a new event-sourced database on top of the stream. The projections are rebuilt
+// from the latest snapshot and the events appended after it.
+func NewEventStoreDatabase(logger *zap.SugaredLogger, stream Stream, opts Options) (*eventStoreDatabase, error) {
+	d := &eventStoreDatabase{logger: logger, stream: stream, state: newState(), opts: opts}
+
+	if d.snapshotsEnabled() {
+		s, err := loadSnapshot(opts.SnapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		d.state = s
+		logger.Infof("event store snapshot loaded at sequence %d", s.Sequence)
+	}
+
+	replayed := 0
+	if err := stream.Read(d.state.Sequence, func(e Event) error {
+		replayed++
+		return d.state.Apply(e)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to rebuild event store projections: %v", err)
+	}
+	d.eventsSinceSnapshot = replayed
+	logger.Infof("event store projections rebuilt: %d events replayed, last sequence %d", replayed, d.state.Sequence)
+
+	return d, nil
+}
+
+// CreateAccount creates a new account by appending an AccountOpened event.
+func (d *eventStoreDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger.Debugf("opening account with id '%s' in event store: %s", account.ID, helpers.PrettyPrintStructResponse(account))
+	acc := *account
+	event := Event{Type: AccountOpened, AccountID: account.ID, Account: &acc, Outbox: models.NewOutboxEvent(enum.AccountCreated, account.ID, account)}
+	if err := d.emit(event); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	d.logger.Debugf("account with id '%s' opened in event store", account.ID)
+	return nil
+}
+
+// GetAccountByID retrieves an account by its id from the accounts projection.
+func (d *eventStoreDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.logger.Debugf("getting account with id '%s' from event store", id)
+	acc, ok := d.state.Accounts[id]
+	if !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", id))
+		return nil, errors.ErrAccountNotFound
+	}
+	return &acc, nil
+}
+
+// GetAccountByIBAN retrieves an account by its iban from the accounts projection.
+func (d *eventStoreDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.logger.Debugf("getting account with iban '%s' from event store", iban)
+	id, ok := d.state.IBANs[iban]
+	if !ok {
+		d.logger.Debugf("account with iban '%s' not found", iban)
+		return nil, errors.ErrAccountNotFound
+	}
+	acc := d.state.Accounts[id]
+	return &acc, nil
+}
+
+// GetAllAccounts retrieves all accounts from the accounts projection.
+func (d *eventStoreDatabase) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.logger.Debugf("getting all accounts from event store")
+	accounts := make([]models.Account, 0, len(d.state.Accounts))
+	for _, acc := range d.state.Accounts {
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+// CreateTransaction validates the transaction against the current state of the account and appends a
+// FundsDeposited or FundsWithdrawn event.
+func (d *eventStoreDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger.Debugf("getting account with id '%s' from event store", transaction.AccountID)
+	account, ok := d.state.Accounts[transaction.AccountID]
+	if !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", transaction.AccountID))
+		return errors.ErrAccountNotFound
+	}
+
+	var eventType EventType
+	switch transaction.Type {
+	case enum.Deposit:
+		eventType = FundsDeposited
+	case enum.Withdrawal:
+		if account.Balance < transaction.Amount {
+			d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", transaction.AccountID))
+			return errors.ErrInsufficientBalance
+		}
+		eventType = FundsWithdrawn
+	default:
+		d.logger.Error(fmt.Sprintf("invalid transaction type '%s'", transaction.Type))
+		return errors.ErrUnknown
+	}
+
+	d.logger.Debugf("storing %s event for transaction with id '%s': %s", eventType, transaction.ID, helpers.PrettyPrintStructResponse(transaction))
+	tx := *transaction
+	event := Event{Type: eventType, AccountID: transaction.AccountID, Transaction: &tx, Outbox: models.NewOutboxEvent(enum.TransactionCreated, transaction.AccountID, transaction)}
+	if err := d.emit(event); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	d.logger.Debugf("transaction with id '%s' stored in event store", transaction.ID)
+	return nil
+}
+
+// Transfer validates the transfer against the current state of the accounts and appends a FundsTransferred event
+// containing both legs of the transfer.
+func (d *eventStoreDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger.Debugf("transferring %f from account '%s' to account '%s' in event store", withdrawal.Amount, withdrawal.AccountID, deposit.AccountID)
+	from, ok := d.state.Accounts[withdrawal.AccountID]
+	if !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", withdrawal.AccountID))
+		return errors.ErrAccountNotFound
+	}
+	if _, ok := d.state.Accounts[deposit.AccountID]; !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", deposit.AccountID))
+		return errors.ErrAccountNotFound
+	}
+	if from.Balance < withdrawal.Amount {
+		d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", withdrawal.AccountID))
+		return errors.ErrInsufficientBalance
+	}
+
+	w, dep := *withdrawal, *deposit
+	event := Event{
+		Type:        FundsTransferred,
+		AccountID:   withdrawal.AccountID,
+		Transaction: &w,
+		Deposit:     &dep,
+		Outbox:      models.NewOutboxEvent(enum.TransferCompleted, withdrawal.AccountID, models.Transfer{Withdrawal: w, Deposit: dep}),
+	}
+	if err := d.emit(event); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	d.logger.Debugf("transfer stored in event store: withdrawal '%s', deposit '%s'", withdrawal.ID, deposit.ID)
+	return nil
+}
+
+// TransferBatch validates every transfer against the state left by the transfers before it and appends a single
+// TransfersBatched event containing all of them, so that the batch is stored as a whole or not at all.
+func (d *eventStoreDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	if len(transfers) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger.Debugf("storing a batch of %d transfers in event store", len(transfers))
+	balances := make(map[string]float64)
+	balance := func(id string) (float64, bool) {
+		if b, ok := balances[id]; ok {
+			return b, true
+		}
+		account, ok := d.state.Accounts[id]
+		return account.Balance, ok
+	}
+
+	batch := make([]models.Transfer, len(transfers))
+	outboxes := make([]models.OutboxEvent, 0, len(transfers))
+	for i, t := range transfers {
+		from, ok := balance(t.Withdrawal.AccountID)
+		if !ok {
+			d.logger.Error(fmt.Sprintf("account with id '%s' not found", t.Withdrawal.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrAccountNotFound}
+		}
+		if _, ok := balance(t.Deposit.AccountID); !ok {
+			d.logger.Error(fmt.Sprintf("account with id '%s' not found", t.Deposit.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrAccountNotFound}
+		}
+		if from < t.Withdrawal.Amount {
+			d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", t.Withdrawal.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrInsufficientBalance}
+		}
+		balances[t.Withdrawal.AccountID] = from - t.Withdrawal.Amount
+
+		// the destination is read after storing the withdrawal in case both accounts are the same
+		to, _ := balance(t.Deposit.AccountID)
+		balances[t.Deposit.AccountID] = to + t.Deposit.Amount
+		batch[i] = t
+		outboxes = append(outboxes, *models.NewOutboxEvent(enum.TransferCompleted, t.Withdrawal.AccountID, t))
+	}
+
+	event := Event{
+		Type:      TransfersBatched,
+		AccountID: batch[0].Withdrawal.AccountID,
+		Transfers: batch,
+		Outboxes:  outboxes,
+	}
+	if err := d.emit(event); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	d.logger.Debugf("batch of %d transfers stored in event store", len(transfers))
+	return nil
+}
+
+// GetTransactionsByAccountID retrieves all transactions for an account from the transactions projection.
+func (d *eventStoreDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.logger.Debugf("getting all transactions for account with id '%s' from event store", id)
+	txs, ok := d.state.Transactions[id]
+	if !ok {
+		return nil, errors.ErrAccountNotFound
+	}
+	return append([]models.Transaction(nil), txs...), nil
+}
+
+// ScanTransactions calls fn for every transaction of an account in the period [from, to). The transactions of the
+// projection are only appended, so the ones projected when the scan starts are read without holding the lock.
+func (d *eventStoreDatabase) ScanTransactions(ctx context.Context, id string, from, to time.Time, fn func(models.Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	d.mu.RLock()
+	txs, ok := d.state.Transactions[id]
+	d.mu.RUnlock()
+	if !ok {
+		return errors.ErrAccountNotFound
+	}
+
+	d.logger.Debugf("scanning %d transactions of account with id '%s' from event store", len(txs), id)
+	for i := range txs {
+		if err := ctx.Err(); err != nil {
+			return errors.FromContext(err)
+		}
+		if !txs[i].InPeriod(from, to) {
+			continue
+		}
+		if err := fn(txs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTransactionsByAccountIDs retrieves the transactions of several accounts from the projected state.
+func (d *eventStoreDatabase) GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.logger.Debugf("getting all transactions for %d accounts from event store", len(ids))
+	result := make(map[string][]models.Transaction, len(ids))
+	for _, id := range ids {
+		if txs, ok := d.state.Transactions[id]; ok {
+			result[id] = append([]models.Transaction(nil), txs...)
+		}
+	}
+	return result, nil
+}
+
+// PendingEvents retrieves the oldest events of the outbox projection, in the order they were stored.
+func (d *eventStoreDatabase) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.FromContext(err)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if limit <= 0 || limit > len(d.state.Outbox) {
+		limit = len(d.state.Outbox)
+	}
+	return append([]models.OutboxEvent(nil), d.state.Outbox[:limit]...), nil
+}
+
+// DeleteEvents removes the events from the outbox projection by appending an OutboxDispatched event.
+func (d *eventStoreDatabase) DeleteEvents(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger.Debugf("deleting %d events from the outbox of the event store", len(ids))
+	if err := d.emit(Event{Type: OutboxDispatched, EventIDs: ids}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+// Replay calls fn for every event whose sequence is greater than from, in the order they were stored. It can be
+// used to step through the history of the database while debugging an incident.
+func (d *eventStoreDatabase) Replay(from uint64, fn func(event Event) error) error {
+	return d.stream.Read(from, fn)
+}
+
+// Project builds a new read model by applying every event of the stream to the projection.
+func (d *eventStoreDatabase) Project(p Projection) error {
+	return d.stream.Read(0, p.Apply)
+}
+
+// Close releases the resources used by the event stream.
+func (d *eventStoreDatabase) Close() error {
+	return d.stream.Close()
+}
+
+// emit appends the event to the stream and applies it to the projections. It must be called with the lock held.
+func (d *eventStoreDatabase) emit(e Event) error {
+	if d.broken != nil {
+		return d.broken
+	}
+	e.Sequence = d.state.Sequence + 1
+	e.Timestamp = time.Now().UTC()
+
+	// the stream leaves no trace of an event that fails to be appended, so its sequence is reused by the next one
+	if err := d.stream.Append(e); err != nil {
+		return err
+	}
+	if err := d.state.Apply(e); err != nil {
+		d.broken = fmt.Errorf("event store is broken: event %d was stored but could not be applied: %v", e.Sequence, err)
+		return d.broken
+	}
+
+	d.eventsSinceSnapshot++
+	if d.snapshotsEnabled() && d.eventsSinceSnapshot >= d.opts.SnapshotInterval {
+		if err := saveSnapshot(d.opts.SnapshotDir, d.state); err != nil {
+			// the event is already stored, so failing to take a snapshot only makes the next startup slower
+			d.logger.Errorf("failed to take event store snapshot: %v", err)
+			return nil
+		}
+		d.eventsSinceSnapshot = 0
+		d.logger.Debugf("event store snapshot taken at sequence %d", d.state.Sequence)
+	}
+	return nil
+}
+
+// snapshotsEnabled checks whether the snapshots are enabled.
+func (d *eventStoreDatabase) snapshotsEnabled() bool {
+	return d.opts.SnapshotDir != "" && d.opts.SnapshotInterval > 0
+}
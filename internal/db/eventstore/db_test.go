@@ -0,0 +1,209 @@
+package eventstore
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type EventStoreDatabaseTestSuite struct {
+	suite.Suite
+	db     *eventStoreDatabase
+	logger *zap.SugaredLogger
+}
+
+func (suite *EventStoreDatabaseTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+	suite.logger = logger
+
+	db, err := NewEventStoreDatabase(logger, NewMemoryStream(), Options{})
+	suite.Require().NoError(err)
+	suite.db = db
+}
+
+// populate opens an account and stores a deposit and a withdrawal in the database.
+func (suite *EventStoreDatabaseTestSuite) populate(db *eventStoreDatabase) {
+	suite.Require().NoError(db.CreateAccount(context.Background(), &models.Account{ID: "1", IBAN: "GB82WEST12345698765432", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Deposit, Amount: 50}))
+	suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx2", AccountID: "1", Type: enum.Withdrawal, Amount: 30}))
+}
+
+// TestCreateAccountAndTransactions tests that accounts and transactions are projected from the events.
+func (suite *EventStoreDatabaseTestSuite) TestCreateAccountAndTransactions() {
+	suite.populate(suite.db)
+
+	account, err := suite.db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+
+	account, err = suite.db.GetAccountByIBAN(context.Background(), "GB82WEST12345698765432")
+	suite.Require().NoError(err)
+	suite.Equal("1", account.ID)
+
+	transactions, err := suite.db.GetTransactionsByAccountID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Len(transactions, 2)
+	suite.Equal("tx1", transactions[0].ID)
+	suite.Equal("tx2", transactions[1].ID)
+
+	accounts, err := suite.db.GetAllAccounts(context.Background())
+	suite.Require().NoError(err)
+	suite.Len(accounts, 1)
+}
+
+// TestCreateTransactionErrors tests that invalid transactions are rejected without emitting events.
+func (suite *EventStoreDatabaseTestSuite) TestCreateTransactionErrors() {
+	suite.populate(suite.db)
+
+	err := suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx3", AccountID: "1", Type: enum.Withdrawal, Amount: 500})
+	suite.Equal(errors.ErrInsufficientBalance, err)
+
+	err = suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx4", AccountID: "nonexistent", Type: enum.Deposit, Amount: 10})
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetAccountByID(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	var events int
+	suite.Require().NoError(suite.db.Replay(0, func(Event) error {
+		events++
+		return nil
+	}))
+	suite.Equal(3, events)
+}
+
+// TestReplay tests stepping through the history of the database.
+func (suite *EventStoreDatabaseTestSuite) TestReplay() {
+	suite.populate(suite.db)
+
+	var types []EventType
+	suite.Require().NoError(suite.db.Replay(1, func(e Event) error {
+		types = append(types, e.Type)
+		return nil
+	}))
+	suite.Equal([]EventType{FundsDeposited, FundsWithdrawn}, types)
+
+	// a new read model built from the same events
+	p := &depositsProjection{}
+	suite.Require().NoError(suite.db.Project(p))
+	suite.Equal(float64(50), p.total)
+}
+
+// TestRebuild tests that the projections are rebuilt from the persisted events and snapshots.
+func (suite *EventStoreDatabaseTestSuite) TestRebuild() {
+	for _, interval := range []int{0, 2} {
+		dir := suite.T().TempDir()
+		opts := Options{SnapshotDir: dir, SnapshotInterval: interval}
+
+		stream, err := NewFileStream(filepath.Join(dir, "events.jsonl"))
+		suite.Require().NoError(err)
+		db, err := NewEventStoreDatabase(suite.logger, stream, opts)
+		suite.Require().NoError(err)
+		suite.populate(db)
+		suite.Require().NoError(db.Close())
+
+		_, err = os.Stat(filepath.Join(dir, snapshotFile))
+		suite.Equal(interval > 0, err == nil)
+
+		stream, err = NewFileStream(filepath.Join(dir, "events.jsonl"))
+		suite.Require().NoError(err)
+		restored, err := NewEventStoreDatabase(suite.logger, stream, opts)
+		suite.Require().NoError(err)
+
+		account, err := restored.GetAccountByID(context.Background(), "1")
+		suite.Require().NoError(err)
+		suite.Equal(float64(120), account.Balance)
+
+		transactions, err := restored.GetTransactionsByAccountID(context.Background(), "1")
+		suite.Require().NoError(err)
+		suite.Len(transactions, 2)
+
+		// new events continue the sequence of the stream
+		suite.Require().NoError(restored.CreateTransaction(context.Background(), &models.Transaction{ID: "tx5", AccountID: "1", Type: enum.Deposit, Amount: 10}))
+		suite.Equal(uint64(4), restored.state.Sequence)
+		suite.Require().NoError(restored.Close())
+	}
+}
+
+// failingFile is a stream file whose writes and syncs fail on demand. A failed write stores half of the event, as a
+// disk that runs out of space would.
+type failingFile struct {
+	streamFile
+	failWrite bool
+	failSync  bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.streamFile.Write(p[:len(p)/2])
+		return n, stderrors.New("no space left on device")
+	}
+	return f.streamFile.Write(p)
+}
+
+func (f *failingFile) Sync() error {
+	if f.failSync {
+		return stderrors.New("input/output error")
+	}
+	return f.streamFile.Sync()
+}
+
+// TestFailedAppend tests that an event that fails to be written or synced is removed from the stream, so that it is
+// not replayed and the next event reuses its sequence.
+func (suite *EventStoreDatabaseTestSuite) TestFailedAppend() {
+	for name, file := range map[string]*failingFile{"write": {failWrite: true}, "sync": {failSync: true}} {
+		suite.Run(name, func() {
+			path := filepath.Join(suite.T().TempDir(), "events.jsonl")
+			stream, err := NewFileStream(path)
+			suite.Require().NoError(err)
+			db, err := NewEventStoreDatabase(suite.logger, stream, Options{})
+			suite.Require().NoError(err)
+			suite.populate(db)
+
+			fs := stream.(*fileStream)
+			file.streamFile = fs.file
+			fs.file = file
+			suite.Require().Error(db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx3", AccountID: "1", Type: enum.Deposit, Amount: 1000}))
+			suite.Equal(uint64(3), db.state.Sequence)
+
+			file.failWrite, file.failSync = false, false
+			suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx4", AccountID: "1", Type: enum.Deposit, Amount: 10}))
+			suite.Require().NoError(db.Close())
+
+			stream, err = NewFileStream(path)
+			suite.Require().NoError(err)
+			restored, err := NewEventStoreDatabase(suite.logger, stream, Options{})
+			suite.Require().NoError(err)
+			account, err := restored.GetAccountByID(context.Background(), "1")
+			suite.Require().NoError(err)
+			suite.Equal(float64(130), account.Balance)
+			suite.Equal(uint64(4), restored.state.Sequence)
+			suite.Require().NoError(restored.Close())
+		})
+	}
+}
+
+// depositsProjection is a read model that sums all deposits.
+type depositsProjection struct {
+	total float64
+}
+
+func (p *depositsProjection) Apply(e Event) error {
+	if e.Type == FundsDeposited {
+		p.total += e.Transaction.Amount
+	}
+	return nil
+}
+
+func TestEventStoreDatabaseTestSuite(t *testing.T) {
+	suite.Run(t, new(EventStoreDatabaseTestSuite))
+}
@@ -0,0 +1,55 @@
+package eventstore
+
+import (
+	"bank_test/internal/db/models"
+	"time"
+)
+
+// EventType is the type of a domain event.
+type EventType string
+
+const (
+	// AccountOpened is emitted when a new account is created.
+	AccountOpened EventType = "AccountOpened"
+
+	// FundsDeposited is emitted when money is deposited into an account.
+	FundsDeposited EventType = "FundsDeposited"
+
+	// FundsWithdrawn is emitted when money is withdrawn from an account.
+	FundsWithdrawn EventType = "FundsWithdrawn"
+
+	// FundsTransferred is emitted when money is transferred between two accounts. Both legs of the transfer
+	// are stored in a single event so that they are applied atomically.
+	FundsTransferred EventType = "FundsTransferred"
+
+	// TransfersBatched is emitted when a batch of transfers is stored. All the legs of the batch are stored in a
+	// single event so that they are applied atomically, in order.
+	TransfersBatched EventType = "TransfersBatched"
+
+	// OutboxDispatched is emitted when events of the outbox are dispatched to the webhooks and can be removed from it.
+	OutboxDispatched EventType = "OutboxDispatched"
+)
+
+// Event is a domain event stored in the event stream. Events are immutable and the state of the database is
+// derived from them.
+type Event struct {
+	Sequence  uint64    `json:"sequence"` // position of the event in the stream, starting at 1
+	Type      EventType `json:"type"`
+	AccountID string    `json:"account_id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Account     *models.Account     `json:"account,omitempty"`     // set for AccountOpened events
+	Transaction *models.Transaction `json:"transaction,omitempty"` // set for FundsDeposited and FundsWithdrawn events, and withdrawal leg of FundsTransferred events
+	Deposit     *models.Transaction `json:"deposit,omitempty"`     // deposit leg of FundsTransferred events
+	Transfers   []models.Transfer   `json:"transfers,omitempty"`   // set for TransfersBatched events
+
+	Outbox   *models.OutboxEvent  `json:"outbox,omitempty"`    // event published to the webhooks, set for every event but OutboxDispatched and TransfersBatched
+	Outboxes []models.OutboxEvent `json:"outboxes,omitempty"`  // events published to the webhooks, one per transfer, set for TransfersBatched events
+	EventIDs []string             `json:"event_ids,omitempty"` // events removed from the outbox, set for OutboxDispatched events
+}
+
+// Projection is a read model built from the event stream. Projections must be deterministic so that
+// replaying the same events always produces the same state.
+type Projection interface {
+	Apply(event Event) error // Apply updates the read model with the event
+}
@@ -0,0 +1,151 @@
+package eventstore
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotFile is the name of the file in which the snapshots are stored.
+const snapshotFile = "snapshot.json"
+
+// state is the projection of the event stream used to serve the queries of the database adapter.
+type state struct {
+	Sequence     uint64                          `json:"sequence"` // sequence of the last event applied
+	Accounts     map[string]models.Account       `json:"accounts"`
+	IBANs        map[string]string               `json:"ibans"` // index of account ids by iban
+	Transactions map[string][]models.Transaction `json:"transactions"`
+	Outbox       []models.OutboxEvent            `json:"outbox"` // events waiting to be dispatched to the webhooks
+}
+
+// newState creates an empty state.
+func newState() *state {
+	return &state{
+		Accounts:     make(map[string]models.Account),
+		IBANs:        make(map[string]string),
+		Transactions: make(map[string][]models.Transaction),
+		Outbox:       make([]models.OutboxEvent, 0),
+	}
+}
+
+// Apply updates the state with the event.
+func (s *state) Apply(e Event) error {
+	if e.Sequence != s.Sequence+1 {
+		return fmt.Errorf("unexpected event sequence %d, expected %d", e.Sequence, s.Sequence+1)
+	}
+
+	switch e.Type {
+	case AccountOpened:
+		if e.Account == nil {
+			return fmt.Errorf("event %d: %s without account", e.Sequence, e.Type)
+		}
+		s.Accounts[e.AccountID] = *e.Account
+		if e.Account.IBAN != "" {
+			s.IBANs[e.Account.IBAN] = e.AccountID
+		}
+		s.Transactions[e.AccountID] = make([]models.Transaction, 0)
+	case FundsDeposited, FundsWithdrawn:
+		if err := s.applyTransaction(e.Transaction); err != nil {
+			return fmt.Errorf("event %d: %s: %v", e.Sequence, e.Type, err)
+		}
+	case FundsTransferred:
+		if err := s.applyTransaction(e.Transaction); err != nil {
+			return fmt.Errorf("event %d: %s: %v", e.Sequence, e.Type, err)
+		}
+		if err := s.applyTransaction(e.Deposit); err != nil {
+			return fmt.Errorf("event %d: %s: %v", e.Sequence, e.Type, err)
+		}
+	case TransfersBatched:
+		for i := range e.Transfers {
+			if err := s.applyTransaction(&e.Transfers[i].Withdrawal); err != nil {
+				return fmt.Errorf("event %d: %s: %v", e.Sequence, e.Type, err)
+			}
+			if err := s.applyTransaction(&e.Transfers[i].Deposit); err != nil {
+				return fmt.Errorf("event %d: %s: %v", e.Sequence, e.Type, err)
+			}
+		}
+		s.Outbox = append(s.Outbox, e.Outboxes...)
+	case OutboxDispatched:
+		s.removeOutboxEvents(e.EventIDs)
+	default:
+		return fmt.Errorf("event %d: unknown event type '%s'", e.Sequence, e.Type)
+	}
+
+	if e.Outbox != nil {
+		s.Outbox = append(s.Outbox, *e.Outbox)
+	}
+	s.Sequence = e.Sequence
+	return nil
+}
+
+// applyTransaction updates the balance of the account of the transaction and appends it to its transactions.
+func (s *state) applyTransaction(tx *models.Transaction) error {
+	if tx == nil {
+		return fmt.Errorf("missing transaction")
+	}
+
+	acc, ok := s.Accounts[tx.AccountID]
+	if !ok {
+		return fmt.Errorf("unknown account '%s'", tx.AccountID)
+	}
+	if tx.Type == enum.Withdrawal {
+		acc.Balance -= tx.Amount
+	} else {
+		acc.Balance += tx.Amount
+	}
+	s.Accounts[tx.AccountID] = acc
+	s.Transactions[tx.AccountID] = append(s.Transactions[tx.AccountID], *tx)
+	return nil
+}
+
+// removeOutboxEvents removes the events from the outbox.
+func (s *state) removeOutboxEvents(ids []string) {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := make([]models.OutboxEvent, 0, len(s.Outbox))
+	for _, e := range s.Outbox {
+		if !remove[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+	s.Outbox = kept
+}
+
+// saveSnapshot stores the state in the directory. The snapshot is written to a temporary file and synced before it
+// replaces the previous one, so a crash while writing it never leaves an empty or truncated snapshot behind the events
+// that it replaces.
+func saveSnapshot(dir string, s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %v", err)
+	}
+
+	if err := helpers.WriteFileSync(filepath.Join(dir, snapshotFile), data); err != nil {
+		return fmt.Errorf("failed to store snapshot: %v", err)
+	}
+	return nil
+}
+
+// loadSnapshot loads the latest snapshot stored in the directory. If there is no snapshot, an empty state is returned.
+func loadSnapshot(dir string) (*state, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return newState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+
+	s := newState()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %v", err)
+	}
+	return s, nil
+}
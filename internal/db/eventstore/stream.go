@@ -0,0 +1,161 @@
+package eventstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Stream is an append-only stream of events.
+type Stream interface {
+	Append(event Event) error                           // Append adds an event at the end of the stream
+	Read(from uint64, fn func(event Event) error) error // Read calls fn for every event whose sequence is greater than from
+	Close() error                                       // Close releases the resources used by the stream
+}
+
+// memoryStream is a stream that keeps the events in memory.
+type memoryStream struct {
+	mu     sync.RWMutex
+	events []Event
+}
+
+// NewMemoryStream creates a new stream that keeps the events in memory.
+func NewMemoryStream() Stream {
+	return &memoryStream{events: make([]Event, 0)}
+}
+
+// Append adds an event at the end of the stream.
+func (s *memoryStream) Append(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Read calls fn for every event whose sequence is greater than from.
+func (s *memoryStream) Read(from uint64, fn func(event Event) error) error {
+	s.mu.RLock()
+	events := s.events
+	s.mu.RUnlock()
+
+	for _, e := range events {
+		if e.Sequence <= from {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the resources used by the stream.
+func (s *memoryStream) Close() error {
+	return nil
+}
+
+// streamFile is the file in which a file stream appends its events. *os.File implements it, and the tests replace it
+// to simulate a failing disk.
+type streamFile interface {
+	io.WriteCloser
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// fileStream is a stream that persists the events in a file in JSON Lines format.
+type fileStream struct {
+	mu   sync.Mutex
+	path string
+	file streamFile
+
+	// broken is set when an event that failed to be written could not be removed from the file. The event may or may
+	// not be read after a restart, so every later append fails with it.
+	broken error
+}
+
+// NewFileStream creates a new stream that persists the events in the file. Every event is synced to disk
+// before Append returns.
+func NewFileStream(path string) (Stream, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event stream file: %v", err)
+	}
+	return &fileStream{path: path, file: f}, nil
+}
+
+// Append adds an event at the end of the stream. If the event cannot be written or synced, the file is truncated to
+// its size before the write, so that the event is not read after a restart.
+func (s *fileStream) Append(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broken != nil {
+		return s.broken
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to write event: %v", err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return s.rollback(info.Size(), fmt.Errorf("failed to write event: %v", err))
+	}
+	if err := s.file.Sync(); err != nil {
+		return s.rollback(info.Size(), fmt.Errorf("failed to sync event stream file: %v", err))
+	}
+	return nil
+}
+
+// rollback truncates the file to size, removing the event that failed to be written, and returns the error of the
+// write. If the file cannot be truncated, the stream is marked as broken. It must be called with the lock held.
+func (s *fileStream) rollback(size int64, err error) error {
+	if truncErr := s.file.Truncate(size); truncErr != nil {
+		s.broken = fmt.Errorf("event stream is broken: failed to remove an event that failed to be written: %v", truncErr)
+	}
+	return err
+}
+
+// Read calls fn for every event whose sequence is greater than from.
+func (s *fileStream) Read(from uint64, fn func(event Event) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open event stream file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("failed to decode event at line %d: %v", line, err)
+		}
+		if e.Sequence <= from {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream file: %v", err)
+	}
+	return nil
+}
+
+// Close releases the resources used by the stream.
+func (s *fileStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
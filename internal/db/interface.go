@@ -1,29 +1,122 @@
 package db
 
 import (
+	"bank_test/internal/conf"
+	"bank_test/internal/db/boltdb"
+	"bank_test/internal/db/eventstore"
 	"bank_test/internal/db/memory"
 	"bank_test/internal/db/models"
+	"bank_test/internal/db/sqlite"
+	"bank_test/internal/enum"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 // Database is the interface for the database layer.
 //
-// By using this interface, we can easily swap out the underlying database implementation.
+// By using this interface, we can easily swap out the underlying database implementation. Every method receives the
+// context of the request: implementations stop waiting and return an API error as soon as the context is done.
 type DatabaseAdapter interface {
 	// Account methods
-	CreateAccount(account *models.Account)             // CreateAccount creates a new account
-	GetAccountByID(id string) (*models.Account, error) // GetAccountByID retrieves an account by its ID
-	GetAllAccounts() []models.Account                  // GetAllAccounts retrieves all accounts
+	CreateAccount(ctx context.Context, account *models.Account) error           // CreateAccount creates a new account
+	GetAccountByID(ctx context.Context, id string) (*models.Account, error)     // GetAccountByID retrieves an account by its ID
+	GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) // GetAccountByIBAN retrieves an account by its IBAN
+	GetAllAccounts(ctx context.Context) ([]models.Account, error)               // GetAllAccounts retrieves all accounts
 
 	// Transaction methods
-	CreateTransaction(transaction *models.Transaction) error            // CreateTransaction creates a new transaction
-	GetTransactionsByAccountID(id string) ([]models.Transaction, error) // GetTransactionsByAccountID retrieves all transactions for an account
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error                                 // CreateTransaction creates a new transaction
+	Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error                                  // Transfer stores both legs of a transfer atomically: either both are stored or none
+	TransferBatch(ctx context.Context, transfers []models.Transfer) error                                         // TransferBatch stores the legs of several transfers atomically, in order: either all are stored or none. A failed transfer is reported with an *errors.BatchError
+	GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error)                      // GetTransactionsByAccountID retrieves all transactions for an account
+	GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error)       // GetTransactionsByAccountIDs retrieves the transactions of several accounts at once, keyed by account. Unknown accounts are left out
+	ScanTransactions(ctx context.Context, id string, from, to time.Time, fn func(models.Transaction) error) error // ScanTransactions calls fn for every transaction of an account in the period [from, to), in the order of GetTransactionsByAccountID, and stops at the first error. A zero from or to leaves the period open. Transactions are read by pages, so that long histories are not held in memory
 }
 
-// NewDatabaseAdapter creates a new database adapter. In this case there is only one implementation: an in-memory database.
-// However, in the future we could add other implementations such as a SQL database. By modifying this function, we can easily
-// switch between different database implementations.
-func NewDatabaseAdapter(logger *zap.SugaredLogger) DatabaseAdapter {
-	return memory.NewInMemoryDatabase(logger)
+// Backuper is implemented by the databases that can write a consistent copy of their data while they are being used.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) (int64, error) // Backup writes a copy of the database to w and returns the number of bytes written
+}
+
+// Outbox is implemented by the databases that store the event produced by every mutation in an outbox, in the same
+// atomic step as the mutation, so that events are never lost nor published for mutations that were not stored.
+type Outbox interface {
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) // PendingEvents retrieves the oldest events of the outbox, in the order they were stored
+	DeleteEvents(ctx context.Context, ids ...string) error                      // DeleteEvents removes the events from the outbox once they are dispatched
+}
+
+// Freezer is implemented by the databases that can freeze accounts: the transactions and transfers of a frozen account
+// are rejected until it is unfrozen.
+type Freezer interface {
+	FreezeAccount(ctx context.Context, id string) error   // FreezeAccount freezes the account. Freezing a frozen account does nothing
+	UnfreezeAccount(ctx context.Context, id string) error // UnfreezeAccount unfreezes the account. Unfreezing an account that is not frozen does nothing
+}
+
+// Importer is implemented by the databases that can load the accounts of another instance in a single atomic step, so
+// that a failed import does not leave part of them behind.
+type Importer interface {
+	Import(ctx context.Context, accounts []models.Account, transactions []models.Transaction) error // Import creates the accounts and stores the transactions, in order, atomically: either all are stored or none. Every mutation produces the same event as CreateAccount and CreateTransaction
+}
+
+// NewDatabaseAdapter creates a new database adapter. The implementation is selected with the DB_DRIVER configuration value:
+//   - memory: an in-memory database. If DATA_DIR is set, its mutations are stored in a write-ahead log and snapshots.
+//   - eventstore: an event-sourced database whose source of truth is an append-only stream of domain events.
+//   - sqlite: a database stored in the SQLITE_PATH file. Its schema is migrated on startup if DB_MIGRATE_ON_STARTUP is set.
+//   - bolt: an embedded key-value database stored in the BOLT_PATH file.
+func NewDatabaseAdapter(logger *zap.SugaredLogger) (DatabaseAdapter, error) {
+	switch conf.GlobalConfig.DBDriver {
+	case enum.EventStoreDriver:
+		return newEventStoreDatabase(logger)
+	case enum.SQLiteDriver:
+		return sqlite.NewSQLiteDatabase(logger, sqlite.Options{
+			Path:    conf.GlobalConfig.SQLitePath,
+			Migrate: conf.GlobalConfig.DBMigrateOnStartup,
+		})
+	case enum.BoltDriver:
+		return boltdb.NewBoltDatabase(logger, conf.GlobalConfig.BoltPath)
+	default:
+		return newMemoryDatabase(logger)
+	}
+}
+
+// newMemoryDatabase creates the in-memory database. If DATA_DIR is set, the database survives restarts.
+func newMemoryDatabase(logger *zap.SugaredLogger) (DatabaseAdapter, error) {
+	if conf.GlobalConfig.DataDir == "" {
+		return memory.NewInMemoryDatabase(logger), nil
+	}
+
+	return memory.NewDurableInMemoryDatabase(logger, memory.Options{
+		Dir:              conf.GlobalConfig.DataDir,
+		SyncPolicy:       conf.GlobalConfig.WALSyncPolicy,
+		SyncInterval:     conf.GlobalConfig.WALSyncInterval,
+		SnapshotInterval: conf.GlobalConfig.SnapshotInterval,
+	})
+}
+
+// newEventStoreDatabase creates the event-sourced database. Events are persisted in EVENT_STORE_DIR, or kept in memory if it is empty.
+func newEventStoreDatabase(logger *zap.SugaredLogger) (DatabaseAdapter, error) {
+	dir := conf.GlobalConfig.EventStoreDir
+	if dir == "" {
+		logger.Warn("EVENT_STORE_DIR is not set, events will be kept in memory")
+		return eventstore.NewEventStoreDatabase(logger, eventstore.NewMemoryStream(), eventstore.Options{})
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create event store directory: %v", err)
+	}
+
+	stream, err := eventstore.NewFileStream(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	return eventstore.NewEventStoreDatabase(logger, stream, eventstore.Options{
+		SnapshotDir:      dir,
+		SnapshotInterval: conf.GlobalConfig.EventSnapshotInterval,
+	})
 }
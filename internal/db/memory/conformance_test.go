@@ -0,0 +1,28 @@
+package memory_test
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/enum"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestInMemoryDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		return memory.NewInMemoryDatabase(zap.NewNop().Sugar())
+	}})
+}
+
+func TestDurableInMemoryDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		database, err := memory.NewDurableInMemoryDatabase(zap.NewNop().Sugar(), memory.Options{Dir: t.TempDir(), SyncPolicy: enum.SyncNever})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database
+	}})
+}
@@ -5,52 +5,116 @@ import (
 	"bank_test/internal/db/models"
 	"bank_test/internal/enum"
 	"bank_test/internal/helpers"
+	"context"
 	"fmt"
-	"sync"
+	"hash/fnv"
+	"sort"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// defaultShards is the number of shards in which the accounts are distributed.
+const defaultShards = 64
+
+// shard holds a subset of the accounts and their transactions, guarded by its own lock.
+type shard struct {
+	mu           rwLock
+	accounts     map[string]models.Account
+	transactions map[string][]models.Transaction
+}
+
 // inMemoryDatabase is an in-memory implementation of the database.
 //
 // We could use another packages such as go-memdb, but for the sake of simplicity I have
 // decided to implement a simple in-memory database.
+//
+// Accounts are distributed in shards by the hash of their id, so operations on accounts of different shards do
+// not block each other. Operations that involve two accounts lock their shards in ascending order to avoid deadlocks.
+// The iban index and the outbox have their own locks, which are never held while waiting for a shard.
 type inMemoryDatabase struct {
-	mu     sync.RWMutex
 	logger *zap.SugaredLogger
 
-	accounts     map[string]models.Account
-	transactions map[string][]models.Transaction
+	shards  []*shard
+	ibansMu rwLock
+	ibans   map[string]string // index of account ids by iban
+
+	outboxMu rwLock
+	outbox   []models.OutboxEvent // events waiting to be dispatched, in the order they were stored
+
+	// durability. The write-ahead log is nil when the database is not durable
+	dir  string
+	wal  *wal
+	stop chan struct{}
+	done chan struct{}
 }
 
 // NewInMemoryDatabase creates a new in-memory database.
 func NewInMemoryDatabase(logger *zap.SugaredLogger) *inMemoryDatabase {
-	return &inMemoryDatabase{
+	return newInMemoryDatabase(logger, defaultShards)
+}
+
+// newInMemoryDatabase creates a new in-memory database with the given number of shards.
+func newInMemoryDatabase(logger *zap.SugaredLogger, shards int) *inMemoryDatabase {
+	d := &inMemoryDatabase{
 		logger: logger,
 
-		accounts:     make(map[string]models.Account),
-		transactions: make(map[string][]models.Transaction),
+		shards:  make([]*shard, shards),
+		ibansMu: newRWLock(),
+		ibans:   make(map[string]string),
+
+		outboxMu: newRWLock(),
+		outbox:   make([]models.OutboxEvent, 0),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
 	}
+	for i := range d.shards {
+		d.shards[i] = &shard{
+			mu:           newRWLock(),
+			accounts:     make(map[string]models.Account),
+			transactions: make(map[string][]models.Transaction),
+		}
+	}
+	return d
 }
 
 // CreateAccount creates a new account in the database.
-func (d *inMemoryDatabase) CreateAccount(account *models.Account) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+func (d *inMemoryDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	s := d.shardFor(account.ID)
+	if err := s.mu.Lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
 
 	d.logger.Debugf("storing account with id '%s' in memory database: %s", account.ID, helpers.PrettyPrintStructResponse(account))
-	d.accounts[account.ID] = *account
-	d.transactions[account.ID] = make([]models.Transaction, 0)
+	event := models.NewOutboxEvent(enum.AccountCreated, account.ID, account)
+	if err := d.log(walRecord{Op: opCreateAccount, Account: account, Event: event}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	s.storeAccount(account)
+
+	// the account is already stored, so the index and the outbox must be updated even if the context is done
+	d.ibansMu.Lock(context.Background())
+	d.indexIBAN(account)
+	d.ibansMu.Unlock()
+	d.addEvent(event)
+
 	d.logger.Debugf("account with id '%s' stored in memory database", account.ID)
+	return nil
 }
 
 // GetAccountByID retrieves an account from the database by its id.
-func (d *inMemoryDatabase) GetAccountByID(id string) (*models.Account, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+func (d *inMemoryDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	s := d.shardFor(id)
+	if err := s.mu.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
 
 	d.logger.Debugf("getting account with id '%s' from memory database", id)
-	acc, ok := d.accounts[id]
+	acc, ok := s.accounts[id]
 	if !ok {
 		d.logger.Error(fmt.Sprintf("account with id '%s' not found", id))
 		return nil, errors.ErrAccountNotFound
@@ -59,27 +123,58 @@ func (d *inMemoryDatabase) GetAccountByID(id string) (*models.Account, error) {
 	return &acc, nil
 }
 
-// GetAllAccounts retrieves all accounts from the database.
-func (d *inMemoryDatabase) GetAllAccounts() []models.Account {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+// GetAccountByIBAN retrieves an account from the database by its iban.
+func (d *inMemoryDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	d.logger.Debugf("getting account with iban '%s' from memory database", iban)
+	if err := d.ibansMu.RLock(ctx); err != nil {
+		return nil, err
+	}
+	id, ok := d.ibans[iban]
+	d.ibansMu.RUnlock()
+	if !ok {
+		d.logger.Debugf("account with iban '%s' not found", iban)
+		return nil, errors.ErrAccountNotFound
+	}
+
+	s := d.shardFor(id)
+	if err := s.mu.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+
+	acc := s.accounts[id]
+	d.logger.Debugf("account with iban '%s' retrieved from memory database: %s", iban, helpers.PrettyPrintStructResponse(acc))
+	return &acc, nil
+}
 
+// GetAllAccounts retrieves all accounts from the database. Shards are copied one at a time, so concurrent writers are
+// only blocked while the shard of their account is being copied.
+func (d *inMemoryDatabase) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
 	d.logger.Debugf("getting all accounts from memory database")
-	accounts := make([]models.Account, 0, len(d.accounts))
-	for _, acc := range d.accounts {
-		accounts = append(accounts, acc)
+	accounts := make([]models.Account, 0)
+	for _, s := range d.shards {
+		if err := s.mu.RLock(ctx); err != nil {
+			return nil, err
+		}
+		for _, acc := range s.accounts {
+			accounts = append(accounts, acc)
+		}
+		s.mu.RUnlock()
 	}
 	d.logger.Debugf("all accounts retrieved from memory database: %s", helpers.PrettyPrintStructResponse(accounts))
-	return accounts
+	return accounts, nil
 }
 
 // CreateTransaction creates a new transaction in the database.
-func (d *inMemoryDatabase) CreateTransaction(transaction *models.Transaction) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+func (d *inMemoryDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	s := d.shardFor(transaction.AccountID)
+	if err := s.mu.Lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
 
 	d.logger.Debugf("getting account with id '%s' from memory database", transaction.AccountID)
-	account, ok := d.accounts[transaction.AccountID]
+	account, ok := s.accounts[transaction.AccountID]
 	if !ok {
 		d.logger.Error(fmt.Sprintf("account with id '%s' not found", transaction.AccountID))
 		return errors.ErrAccountNotFound
@@ -101,25 +196,351 @@ func (d *inMemoryDatabase) CreateTransaction(transaction *models.Transaction) er
 		return errors.ErrUnknown
 	}
 
-	d.accounts[transaction.AccountID] = account
-	d.logger.Debugf("account balance updated: %f", account.Balance)
-
 	d.logger.Debugf("storing transaction with id '%s' in memory database: %s", transaction.ID, helpers.PrettyPrintStructResponse(transaction))
-	d.transactions[transaction.AccountID] = append(d.transactions[transaction.AccountID], *transaction)
+	event := models.NewOutboxEvent(enum.TransactionCreated, transaction.AccountID, transaction)
+	if err := d.log(walRecord{Op: opCreateTransaction, Transaction: transaction, Event: event}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	s.storeTransaction(account, transaction)
+	d.addEvent(event)
+	d.logger.Debugf("account balance updated: %f", account.Balance)
 	d.logger.Debugf("transaction with id '%s' stored in memory database", transaction.ID)
 	return nil
 }
 
+// Transfer stores the withdrawal and the deposit of a transfer atomically. The shards of both accounts are locked
+// in ascending order, so concurrent transfers in opposite directions cannot deadlock.
+func (d *inMemoryDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	unlock, err := d.lockShards(ctx, withdrawal.AccountID, deposit.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	d.logger.Debugf("transferring %f from account '%s' to account '%s' in memory database", withdrawal.Amount, withdrawal.AccountID, deposit.AccountID)
+	fromShard, toShard := d.shardFor(withdrawal.AccountID), d.shardFor(deposit.AccountID)
+	from, ok := fromShard.accounts[withdrawal.AccountID]
+	if !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", withdrawal.AccountID))
+		return errors.ErrAccountNotFound
+	}
+	if _, ok := toShard.accounts[deposit.AccountID]; !ok {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", deposit.AccountID))
+		return errors.ErrAccountNotFound
+	}
+	if from.Balance < withdrawal.Amount {
+		d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", withdrawal.AccountID))
+		return errors.ErrInsufficientBalance
+	}
+
+	event := models.NewOutboxEvent(enum.TransferCompleted, withdrawal.AccountID, models.Transfer{Withdrawal: *withdrawal, Deposit: *deposit})
+	if err := d.log(walRecord{Op: opTransfer, Transaction: withdrawal, Deposit: deposit, Event: event}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+
+	from.Balance -= withdrawal.Amount
+	fromShard.storeTransaction(from, withdrawal)
+
+	// the destination is read after storing the withdrawal in case both accounts are the same
+	to := toShard.accounts[deposit.AccountID]
+	to.Balance += deposit.Amount
+	toShard.storeTransaction(to, deposit)
+	d.addEvent(event)
+	d.logger.Debugf("transfer stored in memory database: withdrawal '%s', deposit '%s'", withdrawal.ID, deposit.ID)
+	return nil
+}
+
+// TransferBatch stores the legs of the transfers atomically, in order. The shards of all the accounts are locked in
+// ascending order, and every transfer is checked against the balances left by the transfers before it, so that the
+// batch is logged and applied as a whole or not at all.
+func (d *inMemoryDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, 2*len(transfers))
+	for _, t := range transfers {
+		ids = append(ids, t.Withdrawal.AccountID, t.Deposit.AccountID)
+	}
+	unlock, err := d.lockShards(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	d.logger.Debugf("storing a batch of %d transfers in memory database", len(transfers))
+	balances := make(map[string]float64, len(ids))
+	balance := func(id string) (float64, bool) {
+		if b, ok := balances[id]; ok {
+			return b, true
+		}
+		account, ok := d.shardFor(id).accounts[id]
+		return account.Balance, ok
+	}
+
+	events := make([]models.OutboxEvent, 0, len(transfers))
+	for i, t := range transfers {
+		from, ok := balance(t.Withdrawal.AccountID)
+		if !ok {
+			d.logger.Error(fmt.Sprintf("account with id '%s' not found", t.Withdrawal.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrAccountNotFound}
+		}
+		if _, ok := balance(t.Deposit.AccountID); !ok {
+			d.logger.Error(fmt.Sprintf("account with id '%s' not found", t.Deposit.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrAccountNotFound}
+		}
+		if from < t.Withdrawal.Amount {
+			d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", t.Withdrawal.AccountID))
+			return &errors.BatchError{Index: i, Err: errors.ErrInsufficientBalance}
+		}
+		balances[t.Withdrawal.AccountID] = from - t.Withdrawal.Amount
+
+		// the destination is read after storing the withdrawal in case both accounts are the same
+		to, _ := balance(t.Deposit.AccountID)
+		balances[t.Deposit.AccountID] = to + t.Deposit.Amount
+		events = append(events, *models.NewOutboxEvent(enum.TransferCompleted, t.Withdrawal.AccountID, t))
+	}
+
+	if err := d.log(walRecord{Op: opTransferBatch, Transfers: transfers, Events: events}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+
+	// the accounts were checked above, so applying the legs cannot fail
+	for i := range transfers {
+		_ = d.replayTransaction(&transfers[i].Withdrawal)
+		_ = d.replayTransaction(&transfers[i].Deposit)
+		d.addEvent(&events[i])
+	}
+	d.logger.Debugf("batch of %d transfers stored in memory database", len(transfers))
+	return nil
+}
+
 // GetTransactionsByAccountID retrieves all transactions for an account from the database.
-func (d *inMemoryDatabase) GetTransactionsByAccountID(id string) ([]models.Transaction, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+func (d *inMemoryDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	s := d.shardFor(id)
+	if err := s.mu.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
 
 	d.logger.Debugf("getting all transactions for account with id '%s' from memory database", id)
-	txs, ok := d.transactions[id]
+	txs, ok := s.transactions[id]
 	if !ok {
 		return nil, errors.ErrAccountNotFound
 	}
 	d.logger.Debugf("all transactions for account with id '%s' retrieved from memory database: %s", id, helpers.PrettyPrintStructResponse(txs))
 	return txs, nil
 }
+
+// ScanTransactions calls fn for every transaction of an account in the period [from, to). The transactions of the
+// account are only appended, so the ones stored when the scan starts are read without holding the lock of its shard.
+func (d *inMemoryDatabase) ScanTransactions(ctx context.Context, id string, from, to time.Time, fn func(models.Transaction) error) error {
+	s := d.shardFor(id)
+	if err := s.mu.RLock(ctx); err != nil {
+		return err
+	}
+	txs, ok := s.transactions[id]
+	s.mu.RUnlock()
+	if !ok {
+		return errors.ErrAccountNotFound
+	}
+
+	d.logger.Debugf("scanning %d transactions of account with id '%s' from memory database", len(txs), id)
+	for i := range txs {
+		if err := ctx.Err(); err != nil {
+			return errors.FromContext(err)
+		}
+		if !txs[i].InPeriod(from, to) {
+			continue
+		}
+		if err := fn(txs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTransactionsByAccountIDs retrieves the transactions of several accounts. Each shard is locked once for all of its
+// accounts.
+func (d *inMemoryDatabase) GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error) {
+	d.logger.Debugf("getting all transactions for %d accounts from memory database", len(ids))
+	byShard := make(map[int][]string)
+	for _, id := range ids {
+		i := d.shardIndex(id)
+		byShard[i] = append(byShard[i], id)
+	}
+
+	result := make(map[string][]models.Transaction, len(ids))
+	for i, shardIDs := range byShard {
+		s := d.shards[i]
+		if err := s.mu.RLock(ctx); err != nil {
+			return nil, err
+		}
+		for _, id := range shardIDs {
+			if txs, ok := s.transactions[id]; ok {
+				result[id] = txs
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return result, nil
+}
+
+// PendingEvents retrieves the oldest events of the outbox, in the order they were stored.
+func (d *inMemoryDatabase) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	if err := d.outboxMu.RLock(ctx); err != nil {
+		return nil, err
+	}
+	defer d.outboxMu.RUnlock()
+
+	if limit <= 0 || limit > len(d.outbox) {
+		limit = len(d.outbox)
+	}
+	return append([]models.OutboxEvent(nil), d.outbox[:limit]...), nil
+}
+
+// DeleteEvents removes the events from the outbox once they are dispatched.
+func (d *inMemoryDatabase) DeleteEvents(ctx context.Context, ids ...string) error {
+	if err := d.outboxMu.Lock(ctx); err != nil {
+		return err
+	}
+	defer d.outboxMu.Unlock()
+
+	d.logger.Debugf("deleting %d events from the outbox of the memory database", len(ids))
+	if err := d.log(walRecord{Op: opDeleteEvents, EventIDs: ids}); err != nil {
+		d.logger.Error(err)
+		return err
+	}
+	d.removeEvents(ids)
+	return nil
+}
+
+// log stores the mutation in the write-ahead log, if the database is durable. It must be called with the locks of the
+// shards of the mutated accounts held and before the mutation is applied in memory, so that the records of every
+// account are stored in the order they are applied.
+func (d *inMemoryDatabase) log(r walRecord) error {
+	if d.wal == nil {
+		return nil
+	}
+	return d.wal.append(r)
+}
+
+// shardFor returns the shard that holds the account.
+func (d *inMemoryDatabase) shardFor(id string) *shard {
+	return d.shards[d.shardIndex(id)]
+}
+
+// shardIndex returns the index of the shard that holds the account.
+func (d *inMemoryDatabase) shardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(d.shards)))
+}
+
+// lockShards locks the shards of the accounts for writing in ascending order and returns the function that unlocks
+// them. If the context is done while waiting, the shards already locked are released and an API error is returned.
+func (d *inMemoryDatabase) lockShards(ctx context.Context, accountIDs ...string) (func(), error) {
+	indexes := make([]int, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		indexes = append(indexes, d.shardIndex(id))
+	}
+	sort.Ints(indexes)
+
+	locked := make([]*shard, 0, len(indexes))
+	unlock := func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].mu.Unlock()
+		}
+	}
+
+	for i, index := range indexes {
+		if i > 0 && index == indexes[i-1] {
+			continue
+		}
+		if err := d.shards[index].mu.Lock(ctx); err != nil {
+			unlock()
+			return nil, err
+		}
+		locked = append(locked, d.shards[index])
+	}
+	return unlock, nil
+}
+
+// lockAll locks every shard, the iban index and the outbox for writing and returns the function that unlocks them.
+func (d *inMemoryDatabase) lockAll(ctx context.Context) (func(), error) {
+	locked := 0
+	unlock := func() {
+		for i := locked - 1; i >= 0; i-- {
+			d.shards[i].mu.Unlock()
+		}
+	}
+
+	for _, s := range d.shards {
+		if err := s.mu.Lock(ctx); err != nil {
+			unlock()
+			return nil, err
+		}
+		locked++
+	}
+	if err := d.ibansMu.Lock(ctx); err != nil {
+		unlock()
+		return nil, err
+	}
+	if err := d.outboxMu.Lock(ctx); err != nil {
+		d.ibansMu.Unlock()
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		d.outboxMu.Unlock()
+		d.ibansMu.Unlock()
+		unlock()
+	}, nil
+}
+
+// indexIBAN adds the account to the iban index. It must be called with the lock of the index held.
+func (d *inMemoryDatabase) indexIBAN(account *models.Account) {
+	if account.IBAN != "" {
+		d.ibans[account.IBAN] = account.ID
+	}
+}
+
+// addEvent appends the event to the outbox. The mutation that produced it is already stored, so the event is added
+// even if the context is done.
+func (d *inMemoryDatabase) addEvent(event *models.OutboxEvent) {
+	d.outboxMu.Lock(context.Background())
+	d.outbox = append(d.outbox, *event)
+	d.outboxMu.Unlock()
+}
+
+// removeEvents removes the events from the outbox. It must be called with the lock of the outbox held.
+func (d *inMemoryDatabase) removeEvents(ids []string) {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := d.outbox[:0]
+	for _, e := range d.outbox {
+		if !remove[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+	d.outbox = kept
+}
+
+// storeAccount stores the account in the shard. It must be called with the lock of the shard held.
+func (s *shard) storeAccount(account *models.Account) {
+	s.accounts[account.ID] = *account
+	s.transactions[account.ID] = make([]models.Transaction, 0)
+}
+
+// storeTransaction stores the transaction and the updated account in the shard. It must be called with the lock of
+// the shard held.
+func (s *shard) storeTransaction(account models.Account, transaction *models.Transaction) {
+	s.accounts[transaction.AccountID] = account
+	s.transactions[transaction.AccountID] = append(s.transactions[transaction.AccountID], *transaction)
+}
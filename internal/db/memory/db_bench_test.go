@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// benchmarkAccounts is the number of accounts created before every benchmark.
+const benchmarkAccounts = 1024
+
+// benchmarkShards compares a single shard, which behaves like a database guarded by one lock, with the default sharding.
+var benchmarkShards = []int{1, defaultShards}
+
+// newBenchmarkDatabase creates a database with the given number of shards and benchmarkAccounts accounts.
+func newBenchmarkDatabase(b *testing.B, shards int) *inMemoryDatabase {
+	db := newInMemoryDatabase(zap.NewNop().Sugar(), shards)
+	for i := 0; i < benchmarkAccounts; i++ {
+		id := strconv.Itoa(i)
+		account := &models.Account{ID: id, IBAN: "IBAN" + id, Owner: "Alice", Balance: 1e12, InitialBalance: 1e12}
+		if err := db.CreateAccount(context.Background(), account); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return db
+}
+
+// runParallel runs fn in parallel for every number of shards. Every goroutine gets its own random source.
+func runParallel(b *testing.B, fn func(db *inMemoryDatabase, rnd *rand.Rand) error) {
+	for _, shards := range benchmarkShards {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			db := newBenchmarkDatabase(b, shards)
+			var seed int64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rnd := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+				for pb.Next() {
+					if err := fn(db, rnd); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// randomAccount returns the id of a random account.
+func randomAccount(rnd *rand.Rand) string {
+	return strconv.Itoa(rnd.Intn(benchmarkAccounts))
+}
+
+// BenchmarkDeposits measures deposits on random accounts.
+func BenchmarkDeposits(b *testing.B) {
+	runParallel(b, func(db *inMemoryDatabase, rnd *rand.Rand) error {
+		return db.CreateTransaction(context.Background(), &models.Transaction{AccountID: randomAccount(rnd), Type: enum.Deposit, Amount: 1})
+	})
+}
+
+// BenchmarkMixed measures a workload of 80% reads and 20% deposits on random accounts.
+func BenchmarkMixed(b *testing.B) {
+	runParallel(b, func(db *inMemoryDatabase, rnd *rand.Rand) error {
+		if rnd.Intn(5) == 0 {
+			return db.CreateTransaction(context.Background(), &models.Transaction{AccountID: randomAccount(rnd), Type: enum.Deposit, Amount: 1})
+		}
+		_, err := db.GetAccountByID(context.Background(), randomAccount(rnd))
+		return err
+	})
+}
+
+// BenchmarkTransfers measures transfers between random pairs of accounts.
+func BenchmarkTransfers(b *testing.B) {
+	runParallel(b, func(db *inMemoryDatabase, rnd *rand.Rand) error {
+		from, to := randomAccount(rnd), randomAccount(rnd)
+		return db.Transfer(context.Background(),
+			&models.Transaction{AccountID: from, Type: enum.Withdrawal, Amount: 1},
+			&models.Transaction{AccountID: to, Type: enum.Deposit, Amount: 1})
+	})
+}
+
+// BenchmarkGetAllAccountsWithWrites measures deposits on random accounts while one in every hundred operations
+// lists all the accounts.
+func BenchmarkGetAllAccountsWithWrites(b *testing.B) {
+	runParallel(b, func(db *inMemoryDatabase, rnd *rand.Rand) error {
+		if rnd.Intn(100) == 0 {
+			_, err := db.GetAllAccounts(context.Background())
+			return err
+		}
+		return db.CreateTransaction(context.Background(), &models.Transaction{AccountID: randomAccount(rnd), Type: enum.Deposit, Amount: 1})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	errors "bank_test/internal/api_errors"
 	"bank_test/internal/db/models"
 	"bank_test/internal/enum"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -32,16 +33,39 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateAccountAndGetAccountByID() {
 	}
 
 	// Create account
-	suite.db.CreateAccount(account)
+	suite.db.CreateAccount(context.Background(), account)
 
 	// Retrieve the account by ID
-	retrievedAccount, err := suite.db.GetAccountByID(account.ID)
+	retrievedAccount, err := suite.db.GetAccountByID(context.Background(), account.ID)
 	suite.Require().NoError(err)
 	suite.Equal(account.ID, retrievedAccount.ID)
 	suite.Equal(account.Owner, retrievedAccount.Owner)
 	suite.Equal(account.Balance, retrievedAccount.Balance)
 }
 
+// TestGetAccountByIBAN tests account retrieval by iban.
+func (suite *InMemoryDatabaseTestSuite) TestGetAccountByIBAN() {
+	account := &models.Account{
+		ID:      "8",
+		IBAN:    "GB82WEST12345698765432",
+		Owner:   "Heidi",
+		Balance: 100.0,
+	}
+
+	// Create account
+	suite.db.CreateAccount(context.Background(), account)
+
+	// Retrieve the account by iban
+	retrievedAccount, err := suite.db.GetAccountByIBAN(context.Background(), account.IBAN)
+	suite.Require().NoError(err)
+	suite.Equal(account.ID, retrievedAccount.ID)
+	suite.Equal(account.IBAN, retrievedAccount.IBAN)
+
+	// Retrieve an unknown iban
+	_, err = suite.db.GetAccountByIBAN(context.Background(), "DE89370400440532013000")
+	suite.Equal(errors.ErrAccountNotFound, err)
+}
+
 // TestCreateTransactionDeposit tests deposit transaction.
 func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionDeposit() {
 	account := &models.Account{
@@ -51,7 +75,7 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionDeposit() {
 	}
 
 	// Create account
-	suite.db.CreateAccount(account)
+	suite.db.CreateAccount(context.Background(), account)
 
 	// Create deposit transaction
 	transaction := &models.Transaction{
@@ -62,11 +86,11 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionDeposit() {
 	}
 
 	// Execute transaction
-	err := suite.db.CreateTransaction(transaction)
+	err := suite.db.CreateTransaction(context.Background(), transaction)
 	suite.Require().NoError(err)
 
 	// Verify account balance after deposit
-	retrievedAccount, err := suite.db.GetAccountByID(account.ID)
+	retrievedAccount, err := suite.db.GetAccountByID(context.Background(), account.ID)
 	suite.Require().NoError(err)
 	suite.Equal(70.0, retrievedAccount.Balance)
 }
@@ -80,7 +104,7 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionWithdrawal() {
 	}
 
 	// Create account
-	suite.db.CreateAccount(account)
+	suite.db.CreateAccount(context.Background(), account)
 
 	// Create withdrawal transaction
 	transaction := &models.Transaction{
@@ -91,11 +115,11 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionWithdrawal() {
 	}
 
 	// Execute transaction
-	err := suite.db.CreateTransaction(transaction)
+	err := suite.db.CreateTransaction(context.Background(), transaction)
 	suite.Require().NoError(err)
 
 	// Verify account balance after withdrawal
-	retrievedAccount, err := suite.db.GetAccountByID(account.ID)
+	retrievedAccount, err := suite.db.GetAccountByID(context.Background(), account.ID)
 	suite.Require().NoError(err)
 	suite.Equal(70.0, retrievedAccount.Balance)
 }
@@ -109,7 +133,7 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionInsufficientBalance
 	}
 
 	// Create account
-	suite.db.CreateAccount(account)
+	suite.db.CreateAccount(context.Background(), account)
 
 	// Create withdrawal transaction with insufficient balance
 	transaction := &models.Transaction{
@@ -120,7 +144,7 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionInsufficientBalance
 	}
 
 	// Try to execute the transaction and expect an error
-	err := suite.db.CreateTransaction(transaction)
+	err := suite.db.CreateTransaction(context.Background(), transaction)
 	suite.Require().Error(err)
 	suite.Equal(errors.ErrInsufficientBalance, err)
 }
@@ -136,7 +160,7 @@ func (suite *InMemoryDatabaseTestSuite) TestCreateTransactionInvalidAccount() {
 	}
 
 	// Try to execute the transaction and expect an error
-	err := suite.db.CreateTransaction(transaction)
+	err := suite.db.CreateTransaction(context.Background(), transaction)
 	suite.Require().Error(err)
 	suite.Equal(errors.ErrAccountNotFound, err)
 }
@@ -150,7 +174,7 @@ func (suite *InMemoryDatabaseTestSuite) TestGetTransactionsByAccountID() {
 	}
 
 	// Create account
-	suite.db.CreateAccount(account)
+	suite.db.CreateAccount(context.Background(), account)
 
 	// Create deposit and withdrawal transactions
 	depositTransaction := &models.Transaction{
@@ -167,13 +191,13 @@ func (suite *InMemoryDatabaseTestSuite) TestGetTransactionsByAccountID() {
 	}
 
 	// Execute transactions
-	err := suite.db.CreateTransaction(depositTransaction)
+	err := suite.db.CreateTransaction(context.Background(), depositTransaction)
 	suite.Require().NoError(err)
-	err = suite.db.CreateTransaction(withdrawalTransaction)
+	err = suite.db.CreateTransaction(context.Background(), withdrawalTransaction)
 	suite.Require().NoError(err)
 
 	// Retrieve transactions for account
-	transactions, err := suite.db.GetTransactionsByAccountID(account.ID)
+	transactions, err := suite.db.GetTransactionsByAccountID(context.Background(), account.ID)
 	suite.Require().NoError(err)
 	suite.Len(transactions, 2)
 	suite.Equal(depositTransaction.ID, transactions[0].ID)
@@ -194,11 +218,12 @@ func (suite *InMemoryDatabaseTestSuite) TestGetAllAccounts() {
 	}
 
 	// Create accounts
-	suite.db.CreateAccount(account1)
-	suite.db.CreateAccount(account2)
+	suite.db.CreateAccount(context.Background(), account1)
+	suite.db.CreateAccount(context.Background(), account2)
 
 	// Get all accounts
-	accounts := suite.db.GetAllAccounts()
+	accounts, err := suite.db.GetAllAccounts(context.Background())
+	suite.Require().NoError(err)
 	suite.Len(accounts, 2)
 }
 
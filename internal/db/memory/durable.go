@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+)
+
+// Options configures the durability of the in-memory database.
+type Options struct {
+	Dir              string          // directory in which the write-ahead log and the snapshots are stored
+	SyncPolicy       enum.SyncPolicy // when the write-ahead log is synced to disk
+	SyncInterval     time.Duration   // interval between syncs when the policy is 'interval'
+	SnapshotInterval time.Duration   // interval between snapshots. 0 disables the periodic snapshots
+}
+
+// snapshot is the state of the database stored on disk.
+type snapshot struct {
+	LSN          uint64                          `json:"lsn"` // sequence number of the last record included in the snapshot
+	Accounts     map[string]models.Account       `json:"accounts"`
+	Transactions map[string][]models.Transaction `json:"transactions"`
+	Outbox       []models.OutboxEvent            `json:"outbox"`
+}
+
+// NewDurableInMemoryDatabase creates a new in-memory database whose mutations are stored in a write-ahead log
+// before they are acknowledged. The state is periodically stored in snapshots. On creation, the database is
+// recovered by loading the latest snapshot and replaying the records of the write-ahead log.
+func NewDurableInMemoryDatabase(logger *zap.SugaredLogger, opts Options) (*inMemoryDatabase, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	d := NewInMemoryDatabase(logger)
+	d.dir = opts.Dir
+
+	lsn, err := d.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := openWAL(logger, filepath.Join(opts.Dir, walFile), opts.SyncPolicy, opts.SyncInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed, err := w.replay(lsn, d.apply)
+	if err != nil {
+		w.close()
+		return nil, fmt.Errorf("failed to recover memory database: %v", err)
+	}
+	d.wal = w
+
+	accounts := 0
+	for _, s := range d.shards {
+		accounts += len(s.accounts)
+	}
+	logger.Infof("memory database recovered: %d accounts, %d write-ahead log records replayed", accounts, replayed)
+
+	if opts.SnapshotInterval > 0 {
+		go d.snapshotPeriodically(opts.SnapshotInterval)
+	} else {
+		close(d.done)
+	}
+
+	return d, nil
+}
+
+// Snapshot stores the state of the database on disk and truncates the write-ahead log.
+func (d *inMemoryDatabase) Snapshot() error {
+	if d.wal == nil {
+		return nil
+	}
+
+	// writers are blocked while the snapshot is taken so that it matches the write-ahead log exactly
+	unlock, err := d.lockAll(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s := snapshot{LSN: d.wal.lastLSN(), Accounts: make(map[string]models.Account), Transactions: make(map[string][]models.Transaction), Outbox: d.outbox}
+	for _, sh := range d.shards {
+		for id, acc := range sh.accounts {
+			s.Accounts[id] = acc
+		}
+		for id, txs := range sh.transactions {
+			s.Transactions[id] = txs
+		}
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %v", err)
+	}
+
+	if err := helpers.WriteFileSync(filepath.Join(d.dir, snapshotFile), data); err != nil {
+		return fmt.Errorf("failed to store snapshot: %v", err)
+	}
+	if err := d.wal.truncate(); err != nil {
+		return err
+	}
+
+	d.logger.Debugf("memory database snapshot taken at lsn %d", s.LSN)
+	return nil
+}
+
+// Close takes a last snapshot and closes the write-ahead log.
+func (d *inMemoryDatabase) Close() error {
+	if d.wal == nil {
+		return nil
+	}
+
+	close(d.stop)
+	<-d.done
+
+	if err := d.Snapshot(); err != nil {
+		d.logger.Errorf("failed to take memory database snapshot: %v", err)
+	}
+	return d.wal.close()
+}
+
+// loadSnapshot loads the latest snapshot stored in the data directory, if any, and returns the sequence number of
+// the last record included in it.
+func (d *inMemoryDatabase) loadSnapshot() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, fmt.Errorf("failed to decode snapshot: %v", err)
+	}
+
+	for id, acc := range s.Accounts {
+		d.shardFor(id).accounts[id] = acc
+		d.indexIBAN(&acc)
+	}
+	for id, txs := range s.Transactions {
+		d.shardFor(id).transactions[id] = txs
+	}
+	if s.Outbox != nil {
+		d.outbox = s.Outbox
+	}
+	d.logger.Infof("memory database snapshot loaded at lsn %d", s.LSN)
+	return s.LSN, nil
+}
+
+// apply applies a record of the write-ahead log to the database without validating it, since it was already
+// validated before being stored. It is only called during the recovery, so no locks are needed.
+func (d *inMemoryDatabase) apply(r walRecord) error {
+	switch r.Op {
+	case opCreateAccount:
+		if r.Account == nil {
+			return fmt.Errorf("record %d: %s without account", r.LSN, r.Op)
+		}
+		d.shardFor(r.Account.ID).storeAccount(r.Account)
+		d.indexIBAN(r.Account)
+	case opCreateTransaction:
+		if r.Transaction == nil {
+			return fmt.Errorf("record %d: %s without transaction", r.LSN, r.Op)
+		}
+		if err := d.replayTransaction(r.Transaction); err != nil {
+			return fmt.Errorf("record %d: %v", r.LSN, err)
+		}
+	case opTransfer:
+		if r.Transaction == nil || r.Deposit == nil {
+			return fmt.Errorf("record %d: %s without both legs", r.LSN, r.Op)
+		}
+		if err := d.replayTransaction(r.Transaction); err != nil {
+			return fmt.Errorf("record %d: %v", r.LSN, err)
+		}
+		if err := d.replayTransaction(r.Deposit); err != nil {
+			return fmt.Errorf("record %d: %v", r.LSN, err)
+		}
+	case opTransferBatch:
+		for i := range r.Transfers {
+			if err := d.replayTransaction(&r.Transfers[i].Withdrawal); err != nil {
+				return fmt.Errorf("record %d: %v", r.LSN, err)
+			}
+			if err := d.replayTransaction(&r.Transfers[i].Deposit); err != nil {
+				return fmt.Errorf("record %d: %v", r.LSN, err)
+			}
+		}
+		d.outbox = append(d.outbox, r.Events...)
+	case opDeleteEvents:
+		d.removeEvents(r.EventIDs)
+		return nil
+	default:
+		return fmt.Errorf("record %d: unknown operation '%s'", r.LSN, r.Op)
+	}
+
+	if r.Event != nil {
+		d.outbox = append(d.outbox, *r.Event)
+	}
+	return nil
+}
+
+// replayTransaction applies the transaction to its account.
+func (d *inMemoryDatabase) replayTransaction(transaction *models.Transaction) error {
+	s := d.shardFor(transaction.AccountID)
+	account, ok := s.accounts[transaction.AccountID]
+	if !ok {
+		return fmt.Errorf("transaction for unknown account '%s'", transaction.AccountID)
+	}
+	if transaction.Type == enum.Withdrawal {
+		account.Balance -= transaction.Amount
+	} else {
+		account.Balance += transaction.Amount
+	}
+	s.storeTransaction(account, transaction)
+	return nil
+}
+
+// snapshotPeriodically takes a snapshot every interval until the database is closed.
+func (d *inMemoryDatabase) snapshotPeriodically(interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Snapshot(); err != nil {
+				d.logger.Errorf("failed to take memory database snapshot: %v", err)
+			}
+		case <-d.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type DurableDatabaseTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	opts   Options
+}
+
+func (suite *DurableDatabaseTestSuite) SetupTest() {
+	suite.logger = zap.NewExample().Sugar()
+	suite.opts = Options{Dir: suite.T().TempDir(), SyncPolicy: enum.SyncAlways}
+}
+
+// open creates a durable database on the test directory.
+func (suite *DurableDatabaseTestSuite) open() *inMemoryDatabase {
+	db, err := NewDurableInMemoryDatabase(suite.logger, suite.opts)
+	suite.Require().NoError(err)
+	return db
+}
+
+// populate creates an account with a deposit and a withdrawal.
+func (suite *DurableDatabaseTestSuite) populate(db *inMemoryDatabase, id string) {
+	suite.Require().NoError(db.CreateAccount(context.Background(), &models.Account{ID: id, IBAN: "IBAN" + id, Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx1", AccountID: id, Type: enum.Deposit, Amount: 50}))
+	suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx2", AccountID: id, Type: enum.Withdrawal, Amount: 30}))
+}
+
+// assertAccount checks that the account was recovered with its transactions.
+func (suite *DurableDatabaseTestSuite) assertAccount(db *inMemoryDatabase, id string) {
+	account, err := db.GetAccountByID(context.Background(), id)
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+
+	account, err = db.GetAccountByIBAN(context.Background(), "IBAN"+id)
+	suite.Require().NoError(err)
+	suite.Equal(id, account.ID)
+
+	txs, err := db.GetTransactionsByAccountID(context.Background(), id)
+	suite.Require().NoError(err)
+	suite.Len(txs, 2)
+}
+
+// TestRecoverFromWAL tests the recovery of a database that crashed before taking any snapshot.
+func (suite *DurableDatabaseTestSuite) TestRecoverFromWAL() {
+	db := suite.open()
+	suite.populate(db, "1")
+
+	// the database is not closed to simulate a crash
+	recovered := suite.open()
+	suite.assertAccount(recovered, "1")
+	suite.Equal(uint64(3), recovered.wal.lastLSN())
+}
+
+// TestRecoverFromSnapshotAndWAL tests the recovery from a snapshot and the tail of the write-ahead log.
+func (suite *DurableDatabaseTestSuite) TestRecoverFromSnapshotAndWAL() {
+	db := suite.open()
+	suite.populate(db, "1")
+	suite.Require().NoError(db.Snapshot())
+
+	info, err := os.Stat(filepath.Join(suite.opts.Dir, walFile))
+	suite.Require().NoError(err)
+	suite.Zero(info.Size())
+
+	suite.populate(db, "2")
+
+	recovered := suite.open()
+	suite.assertAccount(recovered, "1")
+	suite.assertAccount(recovered, "2")
+	suite.Equal(uint64(6), recovered.wal.lastLSN())
+	accounts, err := recovered.GetAllAccounts(context.Background())
+	suite.Require().NoError(err)
+	suite.Len(accounts, 2)
+	suite.Require().NoError(recovered.Close())
+
+	// closing the database takes a last snapshot
+	reopened := suite.open()
+	suite.assertAccount(reopened, "2")
+	suite.Require().NoError(reopened.Close())
+}
+
+// TestTornRecord tests that a record partially written during a crash is discarded.
+func (suite *DurableDatabaseTestSuite) TestTornRecord() {
+	db := suite.open()
+	suite.populate(db, "1")
+
+	f, err := os.OpenFile(filepath.Join(suite.opts.Dir, walFile), os.O_APPEND|os.O_WRONLY, 0o600)
+	suite.Require().NoError(err)
+	_, err = f.WriteString(`{"lsn":4,"op":"create_transaction","transa`)
+	suite.Require().NoError(err)
+	suite.Require().NoError(f.Close())
+
+	recovered := suite.open()
+	suite.assertAccount(recovered, "1")
+
+	// new records are written after the last valid one
+	suite.Require().NoError(recovered.CreateTransaction(context.Background(), &models.Transaction{ID: "1-tx3", AccountID: "1", Type: enum.Deposit, Amount: 10}))
+
+	reopened := suite.open()
+	account, err := reopened.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(130), account.Balance)
+}
+
+// TestRejectedMutationsAreNotLogged tests that invalid transactions are not stored in the write-ahead log.
+func (suite *DurableDatabaseTestSuite) TestRejectedMutationsAreNotLogged() {
+	suite.opts.SyncPolicy = enum.SyncNever
+	db := suite.open()
+	suite.populate(db, "1")
+	suite.Require().Error(db.CreateTransaction(context.Background(), &models.Transaction{ID: "1-tx3", AccountID: "1", Type: enum.Withdrawal, Amount: 500}))
+	suite.Require().NoError(db.Close())
+
+	reopened := suite.open()
+	suite.assertAccount(reopened, "1")
+	suite.Equal(uint64(3), reopened.wal.lastLSN())
+}
+
+// TestRecoverOutbox tests that the events of the outbox and their deletions survive a crash and a snapshot.
+func (suite *DurableDatabaseTestSuite) TestRecoverOutbox() {
+	db := suite.open()
+	suite.populate(db, "1")
+	events, err := db.PendingEvents(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(events, 3)
+	suite.Require().NoError(db.DeleteEvents(context.Background(), events[0].ID))
+
+	recovered := suite.open()
+	pending, err := recovered.PendingEvents(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Equal(events[1:], pending)
+
+	suite.Require().NoError(recovered.Snapshot())
+	suite.Require().NoError(recovered.DeleteEvents(context.Background(), events[1].ID))
+
+	reopened := suite.open()
+	pending, err = reopened.PendingEvents(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Equal(events[2:], pending)
+}
+
+// TestRecoverTransferBatch tests that a batch of transfers is recovered as a whole with its events.
+func (suite *DurableDatabaseTestSuite) TestRecoverTransferBatch() {
+	db := suite.open()
+	suite.populate(db, "1")
+	suite.populate(db, "2")
+	suite.Require().NoError(db.TransferBatch(context.Background(), []models.Transfer{
+		{
+			Withdrawal: models.Transaction{ID: "b1-w", AccountID: "1", Type: enum.Withdrawal, Amount: 20},
+			Deposit:    models.Transaction{ID: "b1-d", AccountID: "2", Type: enum.Deposit, Amount: 20},
+		},
+		{
+			Withdrawal: models.Transaction{ID: "b2-w", AccountID: "2", Type: enum.Withdrawal, Amount: 140},
+			Deposit:    models.Transaction{ID: "b2-d", AccountID: "1", Type: enum.Deposit, Amount: 140},
+		},
+	}))
+
+	recovered := suite.open()
+	first, err := recovered.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(240), first.Balance)
+	second, err := recovered.GetAccountByID(context.Background(), "2")
+	suite.Require().NoError(err)
+	suite.Equal(float64(0), second.Balance)
+	suite.Equal(uint64(7), recovered.wal.lastLSN())
+
+	events, err := recovered.PendingEvents(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.Len(events, 8)
+}
+
+// failingFile is a log file whose writes and syncs fail on demand. A failed write stores half of the record, as a
+// disk that runs out of space would.
+type failingFile struct {
+	logFile
+	failWrite bool
+	failSync  bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.logFile.Write(p[:len(p)/2])
+		return n, errors.New("no space left on device")
+	}
+	return f.logFile.Write(p)
+}
+
+func (f *failingFile) Sync() error {
+	if f.failSync {
+		return errors.New("input/output error")
+	}
+	return f.logFile.Sync()
+}
+
+// TestFailedAppend tests that a record that fails to be written or synced is removed from the log, so that the
+// rejected mutation is not replayed and later records keep their sequence numbers.
+func (suite *DurableDatabaseTestSuite) TestFailedAppend() {
+	for name, file := range map[string]*failingFile{"write": {failWrite: true}, "sync": {failSync: true}} {
+		suite.Run(name, func() {
+			suite.SetupTest()
+			db := suite.open()
+			suite.populate(db, "1")
+
+			file.logFile = db.wal.file
+			db.wal.file = file
+			suite.Require().Error(db.CreateTransaction(context.Background(), &models.Transaction{ID: "1-tx3", AccountID: "1", Type: enum.Deposit, Amount: 1000}))
+			suite.Equal(uint64(3), db.wal.lastLSN())
+			suite.assertAccount(db, "1")
+
+			file.failWrite, file.failSync = false, false
+			suite.Require().NoError(db.CreateTransaction(context.Background(), &models.Transaction{ID: "1-tx4", AccountID: "1", Type: enum.Deposit, Amount: 10}))
+			suite.Equal(uint64(4), db.wal.lastLSN())
+
+			recovered := suite.open()
+			account, err := recovered.GetAccountByID(context.Background(), "1")
+			suite.Require().NoError(err)
+			suite.Equal(float64(130), account.Balance)
+			suite.Equal(uint64(4), recovered.wal.lastLSN())
+		})
+	}
+}
+
+func TestDurableDatabaseTestSuite(t *testing.T) {
+	suite.Run(t, new(DurableDatabaseTestSuite))
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	errors "bank_test/internal/api_errors"
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// maxReaders is the maximum number of readers that can hold the lock at the same time.
+const maxReaders = 1 << 30
+
+// rwLock is a readers-writer lock whose acquisition can be abandoned when the context is done. Waiters are served in
+// order, so a writer is not starved by a continuous flow of readers. A context that is already done never acquires it.
+type rwLock struct {
+	sem *semaphore.Weighted
+}
+
+// newRWLock creates a new unlocked readers-writer lock.
+func newRWLock() rwLock {
+	return rwLock{sem: semaphore.NewWeighted(maxReaders)}
+}
+
+// Lock locks for writing. It returns an API error if the context is done before the lock is acquired.
+func (l rwLock) Lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	if err := l.sem.Acquire(ctx, maxReaders); err != nil {
+		return errors.FromContext(err)
+	}
+	return nil
+}
+
+// Unlock unlocks for writing.
+func (l rwLock) Unlock() {
+	l.sem.Release(maxReaders)
+}
+
+// RLock locks for reading. It returns an API error if the context is done before the lock is acquired.
+func (l rwLock) RLock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.FromContext(err)
+	}
+	if err := l.sem.Acquire(ctx, 1); err != nil {
+		return errors.FromContext(err)
+	}
+	return nil
+}
+
+// RUnlock unlocks for reading.
+func (l rwLock) RUnlock() {
+	l.sem.Release(1)
+}
@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// operation is the type of mutation stored in the write-ahead log.
+type operation string
+
+const (
+	opCreateAccount     operation = "create_account"
+	opCreateTransaction operation = "create_transaction"
+	opTransfer          operation = "transfer"
+	opTransferBatch     operation = "transfer_batch"
+	opDeleteEvents      operation = "delete_events"
+)
+
+// walRecord is an entry of the write-ahead log.
+type walRecord struct {
+	LSN         uint64               `json:"lsn"` // log sequence number, starting at 1
+	Op          operation            `json:"op"`
+	Account     *models.Account      `json:"account,omitempty"`
+	Transaction *models.Transaction  `json:"transaction,omitempty"` // transaction, or withdrawal leg of a transfer
+	Deposit     *models.Transaction  `json:"deposit,omitempty"`     // deposit leg of a transfer
+	Transfers   []models.Transfer    `json:"transfers,omitempty"`   // transfers of a batch
+	Event       *models.OutboxEvent  `json:"event,omitempty"`       // event stored in the outbox by the mutation
+	Events      []models.OutboxEvent `json:"events,omitempty"`      // events stored in the outbox by a batch, one per transfer
+	EventIDs    []string             `json:"event_ids,omitempty"`   // events removed from the outbox
+}
+
+// logFile is the file in which the log is stored. *os.File implements it, and the tests replace it to simulate a
+// failing disk.
+type logFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// wal is a write-ahead log that stores every mutation of the database in a file in JSON Lines format before it
+// is applied in memory.
+type wal struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+
+	file   logFile
+	policy enum.SyncPolicy
+	dirty  bool   // whether there are writes that have not been synced yet
+	lsn    uint64 // sequence number of the last record
+
+	// broken is set when a record that failed to be written could not be removed from the log. The record may or may
+	// not be replayed after a restart, so every later append fails with it rather than being acknowledged on top.
+	broken error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// openWAL opens the write-ahead log stored in path, creating it if it does not exist.
+func openWAL(logger *zap.SugaredLogger, path string, policy enum.SyncPolicy, interval time.Duration) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+
+	w := &wal{logger: logger, file: f, policy: policy, stop: make(chan struct{}), done: make(chan struct{})}
+	if policy == enum.SyncInterval && interval > 0 {
+		go w.syncPeriodically(interval)
+	} else {
+		close(w.done)
+	}
+	return w, nil
+}
+
+// replay calls fn for every record of the log whose sequence number is greater than from, which is the sequence
+// number of the last record included in a snapshot. New records are numbered after the last record of the log.
+// A record that cannot be decoded at the end of the log is the result of a crash while it was being written, so it
+// is discarded and the log is truncated before it.
+func (w *wal) replay(from uint64, fn func(r walRecord) error) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read write-ahead log: %v", err)
+	}
+
+	w.lsn = from
+	reader := bufio.NewReader(w.file)
+	var (
+		offset   int64
+		replayed int
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return replayed, fmt.Errorf("failed to read write-ahead log: %v", err)
+		}
+
+		var r walRecord
+		if err == io.EOF || json.Unmarshal(line, &r) != nil {
+			// only the last record can be torn, anything else means that the log is corrupted
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				return replayed, fmt.Errorf("write-ahead log is corrupted at offset %d", offset)
+			}
+			w.logger.Warnf("discarding torn write-ahead log record at offset %d", offset)
+			if err := w.file.Truncate(offset); err != nil {
+				return replayed, fmt.Errorf("failed to truncate write-ahead log: %v", err)
+			}
+			break
+		}
+		offset += int64(len(line))
+
+		if r.LSN <= w.lsn {
+			// the record is already included in the snapshot
+			continue
+		}
+		if err := fn(r); err != nil {
+			return replayed, err
+		}
+		w.lsn = r.LSN
+		replayed++
+	}
+
+	_, err := w.file.Seek(0, io.SeekEnd)
+	return replayed, err
+}
+
+// append numbers the record and writes it at the end of the log. With the 'always' policy, the record is synced to
+// disk before returning. If the record cannot be written or synced, the log is truncated to its size before the write
+// and the sequence number is kept, so that a failed mutation is never replayed.
+func (w *wal) append(r walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.broken != nil {
+		return w.broken
+	}
+
+	r.LSN = w.lsn + 1
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal write-ahead log record: %v", err)
+	}
+
+	offset, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to write write-ahead log record: %v", err)
+	}
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return w.rollback(offset, fmt.Errorf("failed to write write-ahead log record: %v", err))
+	}
+	if w.policy == enum.SyncAlways {
+		if err := w.file.Sync(); err != nil {
+			return w.rollback(offset, fmt.Errorf("failed to sync write-ahead log: %v", err))
+		}
+	} else {
+		w.dirty = true
+	}
+	w.lsn = r.LSN
+	return nil
+}
+
+// rollback removes the record that failed to be written at offset from the log, and returns the error of the write.
+// If the record cannot be removed, the log is marked as broken. It must be called with the lock held.
+func (w *wal) rollback(offset int64, err error) error {
+	if truncErr := w.file.Truncate(offset); truncErr != nil {
+		w.broken = fmt.Errorf("write-ahead log is broken: failed to remove a record that failed to be written: %v", truncErr)
+		w.logger.Error(w.broken)
+		return err
+	}
+	if _, seekErr := w.file.Seek(offset, io.SeekStart); seekErr != nil {
+		w.broken = fmt.Errorf("write-ahead log is broken: failed to remove a record that failed to be written: %v", seekErr)
+		w.logger.Error(w.broken)
+	}
+	return err
+}
+
+// lastLSN returns the sequence number of the last record of the log.
+func (w *wal) lastLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lsn
+}
+
+// truncate removes every record from the log. It is called once the records are included in a snapshot.
+func (w *wal) truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %v", err)
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %v", err)
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// sync flushes the pending writes to disk.
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// syncPeriodically syncs the log every interval until the log is closed.
+func (w *wal) syncPeriodically(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.sync(); err != nil {
+				w.logger.Errorf("failed to sync write-ahead log: %v", err)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// close syncs the pending writes and closes the log.
+func (w *wal) close() error {
+	close(w.stop)
+	<-w.done
+
+	if err := w.sync(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
@@ -2,14 +2,21 @@ package models
 
 import (
 	"bank_test/internal/enum"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Account is the model for the account table
 type Account struct {
 	ID      string  `json:"id"`
+	IBAN    string  `json:"iban"` // human-facing account number
 	Owner   string  `json:"owner"`
 	Balance float64 `json:"balance"`
+
+	InitialBalance float64 `json:"initial_balance"`  // balance when the account was opened, used to reconcile the ledger
+	Frozen         bool    `json:"frozen,omitempty"` // frozen accounts reject transactions. It is set by the freeze layer, not stored by the databases
 }
 
 // Transaction is the model for the transaction table
@@ -19,4 +26,40 @@ type Transaction struct {
 	Type      enum.TransactionType `json:"type"` // desposit or withdrawal
 	Amount    float64              `json:"amount"`
 	Timestamp time.Time            `json:"timestamp"` // timestamp in RFC3339 format
+
+	TransferID string `json:"transfer_id,omitempty"` // id shared by both legs of a transfer, empty for the other transactions
+}
+
+// InPeriod tells whether the transaction was made in the period [from, to). A zero from or to leaves the period open.
+func (t *Transaction) InPeriod(from, to time.Time) bool {
+	return (from.IsZero() || !t.Timestamp.Before(from)) && (to.IsZero() || t.Timestamp.Before(to))
+}
+
+// Transfer is the payload of the events produced by a transfer
+type Transfer struct {
+	Withdrawal Transaction `json:"withdrawal"`
+	Deposit    Transaction `json:"deposit"`
+}
+
+// OutboxEvent is the model for the outbox table. It is a domain event stored in the same atomic step as the mutation
+// that produced it, waiting to be dispatched to the webhooks
+type OutboxEvent struct {
+	ID        string          `json:"id"`
+	Type      enum.EventType  `json:"type"`
+	AccountID string          `json:"account_id"` // account that was mutated. For transfers, the account the money was withdrawn from
+	Payload   json.RawMessage `json:"payload"`    // account, transaction or transfer, depending on the type
+	Timestamp time.Time       `json:"timestamp"`  // timestamp in RFC3339 format
+}
+
+// NewOutboxEvent creates a new event with a random id. The payload must be one of the models, which are always encoded
+// without errors.
+func NewOutboxEvent(eventType enum.EventType, accountID string, payload any) *OutboxEvent {
+	data, _ := json.Marshal(payload)
+	return &OutboxEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		AccountID: accountID,
+		Payload:   data,
+		Timestamp: time.Now().UTC(),
+	}
 }
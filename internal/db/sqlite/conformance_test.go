@@ -0,0 +1,22 @@
+package sqlite_test
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/sqlite"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestSQLiteDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		database, err := sqlite.NewSQLiteDatabase(zap.NewNop().Sugar(), sqlite.Options{Path: filepath.Join(t.TempDir(), "bank.db"), Migrate: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database
+	}})
+}
@@ -0,0 +1,569 @@
+package sqlite
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+// busyTimeout is the time, in milliseconds, a connection waits for a lock held by another process before failing.
+const busyTimeout = 5000
+
+// scanPageSize is the number of transactions read at once by ScanTransactions.
+const scanPageSize = 500
+
+// timestampLayout is the layout of the timestamps of the transactions, which are stored in UTC with a fixed width, so
+// that their text sorts in time order.
+const timestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Options configures the SQLite database.
+type Options struct {
+	Path    string // path of the database file
+	Migrate bool   // apply the pending migrations when the database is opened
+}
+
+// sqliteDatabase is a database stored in a SQLite file. Every mutation runs in a SQL transaction, so the balance of an
+// account and its transactions are always updated together.
+type sqliteDatabase struct {
+	logger *zap.SugaredLogger
+	db     *sql.DB
+}
+
+// Open opens the SQLite database file, creating it if it does not exist.
+func Open(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)", url.PathEscape(path), busyTimeout)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+
+	// SQLite allows a single writer, so a single connection avoids SQLITE_BUSY errors between connections of the pool
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+	return db, nil
+}
+
+// NewSQLiteDatabase opens the SQLite database and, if enabled, applies the pending migrations.
+func NewSQLiteDatabase(logger *zap.SugaredLogger, opts Options) (*sqliteDatabase, error) {
+	db, err := Open(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Migrate {
+		if _, err := Migrate(logger, db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	logger.Infof("sqlite database opened at '%s'", opts.Path)
+	return &sqliteDatabase{logger: logger, db: db}, nil
+}
+
+// CreateAccount creates a new account in the database.
+func (d *sqliteDatabase) CreateAccount(ctx context.Context, account *models.Account) error {
+	d.logger.Debugf("storing account with id '%s' in sqlite database: %s", account.ID, helpers.PrettyPrintStructResponse(account))
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		return createAccount(ctx, tx, account)
+	})
+	if err != nil {
+		return err
+	}
+	d.logger.Debugf("account with id '%s' stored in sqlite database", account.ID)
+	return nil
+}
+
+// GetAccountByID retrieves an account from the database by its id.
+func (d *sqliteDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	d.logger.Debugf("getting account with id '%s' from sqlite database", id)
+	acc, err := scanAccount(d.db.QueryRowContext(ctx, `SELECT id, COALESCE(iban, ''), owner, balance, initial_balance FROM accounts WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", id))
+		return nil, errors.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	return acc, nil
+}
+
+// GetAccountByIBAN retrieves an account from the database by its iban.
+func (d *sqliteDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	d.logger.Debugf("getting account with iban '%s' from sqlite database", iban)
+	acc, err := scanAccount(d.db.QueryRowContext(ctx, `SELECT id, COALESCE(iban, ''), owner, balance, initial_balance FROM accounts WHERE iban = ?`, iban))
+	if err == sql.ErrNoRows {
+		d.logger.Debugf("account with iban '%s' not found", iban)
+		return nil, errors.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	return acc, nil
+}
+
+// GetAllAccounts retrieves all accounts from the database.
+func (d *sqliteDatabase) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
+	d.logger.Debugf("getting all accounts from sqlite database")
+	rows, err := d.db.QueryContext(ctx, `SELECT id, COALESCE(iban, ''), owner, balance, initial_balance FROM accounts ORDER BY id`)
+	if err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	defer rows.Close()
+
+	accounts := make([]models.Account, 0)
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, d.wrapError(ctx, err)
+		}
+		accounts = append(accounts, *acc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	return accounts, nil
+}
+
+// CreateTransaction stores the transaction and updates the balance of its account in a single SQL transaction.
+func (d *sqliteDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	d.logger.Debugf("storing transaction with id '%s' in sqlite database: %s", transaction.ID, helpers.PrettyPrintStructResponse(transaction))
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		return d.createTransaction(ctx, tx, transaction)
+	})
+	if err != nil {
+		return err
+	}
+	d.logger.Debugf("transaction with id '%s' stored in sqlite database", transaction.ID)
+	return nil
+}
+
+// Import creates the accounts and stores the transactions, in order, in a single SQL transaction, which is rolled back
+// if any of them fails.
+func (d *sqliteDatabase) Import(ctx context.Context, accounts []models.Account, transactions []models.Transaction) error {
+	d.logger.Debugf("importing %d accounts and %d transactions in sqlite database", len(accounts), len(transactions))
+	failed := "" // record that failed, if any
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		for i := range accounts {
+			if err := createAccount(ctx, tx, &accounts[i]); err != nil {
+				failed = fmt.Sprintf("account '%s'", accounts[i].ID)
+				return err
+			}
+		}
+		for i := range transactions {
+			if err := d.createTransaction(ctx, tx, &transactions[i]); err != nil {
+				failed = fmt.Sprintf("transaction '%s'", transactions[i].ID)
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed != "" {
+			return fmt.Errorf("failed to import %s: %w", failed, err)
+		}
+		return err
+	}
+	d.logger.Debugf("%d accounts and %d transactions imported in sqlite database", len(accounts), len(transactions))
+	return nil
+}
+
+// Transfer stores the withdrawal and the deposit of a transfer in a single SQL transaction.
+func (d *sqliteDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	d.logger.Debugf("transferring %f from account '%s' to account '%s' in sqlite database", withdrawal.Amount, withdrawal.AccountID, deposit.AccountID)
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		return d.transfer(ctx, tx, withdrawal, deposit)
+	})
+	if err != nil {
+		return err
+	}
+	d.logger.Debugf("transfer stored in sqlite database: withdrawal '%s', deposit '%s'", withdrawal.ID, deposit.ID)
+	return nil
+}
+
+// TransferBatch stores the legs of the transfers, in order, in a single SQL transaction, which is rolled back if any
+// of them fails.
+func (d *sqliteDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	d.logger.Debugf("storing a batch of %d transfers in sqlite database", len(transfers))
+	failed := -1
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		for i := range transfers {
+			if err := d.transfer(ctx, tx, &transfers[i].Withdrawal, &transfers[i].Deposit); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if failed >= 0 {
+			return &errors.BatchError{Index: failed, Err: err}
+		}
+		return err
+	}
+	d.logger.Debugf("batch of %d transfers stored in sqlite database", len(transfers))
+	return nil
+}
+
+// transfer applies both legs of a transfer and stores its event in the SQL transaction.
+func (d *sqliteDatabase) transfer(ctx context.Context, tx *sql.Tx, withdrawal, deposit *models.Transaction) error {
+	// the destination is checked first so that a missing account is reported before an insufficient balance
+	if err := d.checkAccount(ctx, tx, deposit.AccountID); err != nil {
+		return err
+	}
+	if err := d.applyTransaction(ctx, tx, withdrawal); err != nil {
+		return err
+	}
+	if err := d.applyTransaction(ctx, tx, deposit); err != nil {
+		return err
+	}
+	transfer := models.Transfer{Withdrawal: *withdrawal, Deposit: *deposit}
+	return insertEvent(ctx, tx, models.NewOutboxEvent(enum.TransferCompleted, withdrawal.AccountID, transfer))
+}
+
+// GetTransactionsByAccountID retrieves all transactions for an account from the database, in the order they were stored.
+func (d *sqliteDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	d.logger.Debugf("getting all transactions for account with id '%s' from sqlite database", id)
+
+	var txs []models.Transaction
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		if err := d.checkAccount(ctx, tx, id); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT id, account_id, type, amount, timestamp, transfer_id FROM transactions WHERE account_id = ? ORDER BY seq`, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		txs = make([]models.Transaction, 0)
+		for rows.Next() {
+			var (
+				t         models.Transaction
+				timestamp string
+			)
+			if err := rows.Scan(&t.ID, &t.AccountID, &t.Type, &t.Amount, &timestamp, &t.TransferID); err != nil {
+				return err
+			}
+			if t.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+				return err
+			}
+			txs = append(txs, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// ScanTransactions calls fn for every transaction of an account in the period [from, to), in the order they were
+// stored. The transactions are read by pages of scanPageSize, each one in its own SQL transaction, so that none is kept
+// open while fn runs. The timestamps are stored with a fixed width, so the period is filtered by the query with the
+// index of the timestamps of the account.
+func (d *sqliteDatabase) ScanTransactions(ctx context.Context, id string, from, to time.Time, fn func(models.Transaction) error) error {
+	d.logger.Debugf("scanning the transactions of account with id '%s' from sqlite database", id)
+
+	query, args := `SELECT seq, id, account_id, type, amount, timestamp, transfer_id FROM transactions`, []any{id}
+	if !from.IsZero() || !to.IsZero() {
+		// without statistics, sqlite prefers the index of the sequence numbers, which reads the whole history
+		query += ` INDEXED BY idx_transactions_account_timestamp`
+	}
+	query += ` WHERE account_id = ?`
+	if !from.IsZero() {
+		query, args = query+` AND timestamp >= ?`, append(args, from.UTC().Format(timestampLayout))
+	}
+	if !to.IsZero() {
+		query, args = query+` AND timestamp < ?`, append(args, to.UTC().Format(timestampLayout))
+	}
+	query += ` AND seq > ? ORDER BY seq LIMIT ?`
+
+	var last int64 // sequence number of the last transaction read
+	for first := true; ; first = false {
+		page := make([]models.Transaction, 0, scanPageSize)
+		err := d.inTx(ctx, func(tx *sql.Tx) error {
+			if first {
+				if err := d.checkAccount(ctx, tx, id); err != nil {
+					return err
+				}
+			}
+
+			rows, err := tx.QueryContext(ctx, query, append(args, last, scanPageSize)...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var (
+					t         models.Transaction
+					timestamp string
+				)
+				if err := rows.Scan(&last, &t.ID, &t.AccountID, &t.Type, &t.Amount, &timestamp, &t.TransferID); err != nil {
+					return err
+				}
+				if t.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+					return err
+				}
+				page = append(page, t)
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range page {
+			if err := fn(page[i]); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+	}
+}
+
+// GetTransactionsByAccountIDs retrieves the transactions of several accounts with one query for the accounts and one
+// for their transactions, in a single SQL transaction.
+func (d *sqliteDatabase) GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error) {
+	d.logger.Debugf("getting all transactions for %d accounts from sqlite database", len(ids))
+
+	result := make(map[string][]models.Transaction, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM accounts WHERE id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			result[id] = make([]models.Transaction, 0)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, account_id, type, amount, timestamp, transfer_id FROM transactions WHERE account_id IN (`+placeholders+`) ORDER BY seq`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				t         models.Transaction
+				timestamp string
+			)
+			if err := rows.Scan(&t.ID, &t.AccountID, &t.Type, &t.Amount, &timestamp, &t.TransferID); err != nil {
+				return err
+			}
+			if t.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+				return err
+			}
+			result[t.AccountID] = append(result[t.AccountID], t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PendingEvents retrieves the oldest events of the outbox, in the order they were stored.
+func (d *sqliteDatabase) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT id, type, account_id, payload, timestamp FROM outbox ORDER BY seq LIMIT ?`, limit)
+	if err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	defer rows.Close()
+
+	events := make([]models.OutboxEvent, 0)
+	for rows.Next() {
+		var (
+			e                  models.OutboxEvent
+			payload, timestamp string
+		)
+		if err := rows.Scan(&e.ID, &e.Type, &e.AccountID, &payload, &timestamp); err != nil {
+			return nil, d.wrapError(ctx, err)
+		}
+		if e.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+			return nil, d.wrapError(ctx, err)
+		}
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, d.wrapError(ctx, err)
+	}
+	return events, nil
+}
+
+// DeleteEvents removes the events from the outbox once they are dispatched.
+func (d *sqliteDatabase) DeleteEvents(ctx context.Context, ids ...string) error {
+	d.logger.Debugf("deleting %d events from the outbox of the sqlite database", len(ids))
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database.
+func (d *sqliteDatabase) Close() error {
+	return d.db.Close()
+}
+
+// createAccount stores the account and its event in the SQL transaction.
+func createAccount(ctx context.Context, tx *sql.Tx, account *models.Account) error {
+	if _, err := tx.ExecContext(ctx, `INSERT INTO accounts (id, iban, owner, balance, initial_balance) VALUES (?, NULLIF(?, ''), ?, ?, ?)`,
+		account.ID, account.IBAN, account.Owner, account.Balance, account.InitialBalance); err != nil {
+		return err
+	}
+	return insertEvent(ctx, tx, models.NewOutboxEvent(enum.AccountCreated, account.ID, account))
+}
+
+// createTransaction applies the transaction and stores its event in the SQL transaction.
+func (d *sqliteDatabase) createTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) error {
+	if err := d.applyTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+	return insertEvent(ctx, tx, models.NewOutboxEvent(enum.TransactionCreated, transaction.AccountID, transaction))
+}
+
+// applyTransaction updates the balance of the account and stores the transaction. Withdrawals only update the balance
+// if it covers the amount, so the check and the update cannot be interleaved with another withdrawal.
+func (d *sqliteDatabase) applyTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) error {
+	var (
+		res sql.Result
+		err error
+	)
+	switch transaction.Type {
+	case enum.Deposit:
+		res, err = tx.ExecContext(ctx, `UPDATE accounts SET balance = balance + ? WHERE id = ?`, transaction.Amount, transaction.AccountID)
+	case enum.Withdrawal:
+		res, err = tx.ExecContext(ctx, `UPDATE accounts SET balance = balance - ? WHERE id = ? AND balance >= ?`,
+			transaction.Amount, transaction.AccountID, transaction.Amount)
+	default:
+		d.logger.Error(fmt.Sprintf("invalid transaction type '%s'", transaction.Type))
+		return errors.ErrUnknown
+	}
+	if err != nil {
+		return err
+	}
+
+	if updated, err := res.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		if err := d.checkAccount(ctx, tx, transaction.AccountID); err != nil {
+			return err
+		}
+		d.logger.Error(fmt.Sprintf("insufficient balance for account with id '%s'", transaction.AccountID))
+		return errors.ErrInsufficientBalance
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO transactions (id, account_id, type, amount, timestamp, transfer_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		transaction.ID, transaction.AccountID, transaction.Type, transaction.Amount, transaction.Timestamp.UTC().Format(timestampLayout), transaction.TransferID)
+	return err
+}
+
+// insertEvent stores the event in the outbox.
+func insertEvent(ctx context.Context, tx *sql.Tx, event *models.OutboxEvent) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO outbox (id, type, account_id, payload, timestamp) VALUES (?, ?, ?, ?, ?)`,
+		event.ID, event.Type, event.AccountID, string(event.Payload), event.Timestamp.Format(time.RFC3339Nano))
+	return err
+}
+
+// checkAccount checks that the account exists.
+func (d *sqliteDatabase) checkAccount(ctx context.Context, tx *sql.Tx, id string) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		d.logger.Error(fmt.Sprintf("account with id '%s' not found", id))
+		return errors.ErrAccountNotFound
+	}
+	return nil
+}
+
+// inTx runs fn in a SQL transaction that is committed if fn succeeds and rolled back otherwise. The transaction is
+// rolled back as well if the context is done before it is committed.
+func (d *sqliteDatabase) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return d.wrapError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return d.wrapError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return d.wrapError(ctx, err)
+	}
+	return nil
+}
+
+// wrapError translates the errors of the SQL driver into API errors. Errors caused by the context are reported as
+// such, and other errors that are not API errors are logged and reported as unknown errors.
+func (d *sqliteDatabase) wrapError(ctx context.Context, err error) error {
+	if _, ok := err.(*errors.APIError); ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return errors.FromContext(ctx.Err())
+	}
+	d.logger.Errorf("sqlite query failed: %v", err)
+	return errors.ErrUnknown
+}
+
+// scanner is implemented by sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAccount reads an account from a row.
+func scanAccount(row scanner) (*models.Account, error) {
+	var acc models.Account
+	if err := row.Scan(&acc.ID, &acc.IBAN, &acc.Owner, &acc.Balance, &acc.InitialBalance); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
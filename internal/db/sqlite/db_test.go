@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type SQLiteDatabaseTestSuite struct {
+	suite.Suite
+	db     *sqliteDatabase
+	logger *zap.SugaredLogger
+	path   string
+}
+
+func (suite *SQLiteDatabaseTestSuite) SetupTest() {
+	suite.logger = zap.NewExample().Sugar()
+	suite.path = filepath.Join(suite.T().TempDir(), "bank.db")
+
+	db, err := NewSQLiteDatabase(suite.logger, Options{Path: suite.path, Migrate: true})
+	suite.Require().NoError(err)
+	suite.db = db
+}
+
+func (suite *SQLiteDatabaseTestSuite) TearDownTest() {
+	suite.db.Close()
+}
+
+// populate creates an account with a deposit and a withdrawal.
+func (suite *SQLiteDatabaseTestSuite) populate(id string) {
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: id, IBAN: "IBAN" + id, Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx1", AccountID: id, Type: enum.Deposit, Amount: 50, Timestamp: time.Now()}))
+	suite.Require().NoError(suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: id + "-tx2", AccountID: id, Type: enum.Withdrawal, Amount: 30, Timestamp: time.Now()}))
+}
+
+// TestAccountsAndTransactions tests storing and retrieving accounts and transactions.
+func (suite *SQLiteDatabaseTestSuite) TestAccountsAndTransactions() {
+	suite.populate("1")
+
+	account, err := suite.db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+	suite.Equal(float64(100), account.InitialBalance)
+
+	account, err = suite.db.GetAccountByIBAN(context.Background(), "IBAN1")
+	suite.Require().NoError(err)
+	suite.Equal("1", account.ID)
+
+	txs, err := suite.db.GetTransactionsByAccountID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Require().Len(txs, 2)
+	suite.Equal("1-tx1", txs[0].ID)
+	suite.Equal(enum.Withdrawal, txs[1].Type)
+	suite.False(txs[1].Timestamp.IsZero())
+
+	// accounts without an iban do not collide in the unique index
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: "2", Owner: "Bob"}))
+	suite.Require().NoError(suite.db.CreateAccount(context.Background(), &models.Account{ID: "3", Owner: "Carol"}))
+	accounts, err := suite.db.GetAllAccounts(context.Background())
+	suite.Require().NoError(err)
+	suite.Len(accounts, 3)
+
+	txs, err = suite.db.GetTransactionsByAccountID(context.Background(), "2")
+	suite.Require().NoError(err)
+	suite.Empty(txs)
+}
+
+// TestErrors tests that invalid operations are rejected without modifying the database.
+func (suite *SQLiteDatabaseTestSuite) TestErrors() {
+	suite.populate("1")
+
+	err := suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx3", AccountID: "1", Type: enum.Withdrawal, Amount: 500})
+	suite.Equal(errors.ErrInsufficientBalance, err)
+
+	err = suite.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx4", AccountID: "nonexistent", Type: enum.Deposit, Amount: 10})
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetAccountByID(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetAccountByIBAN(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	_, err = suite.db.GetTransactionsByAccountID(context.Background(), "nonexistent")
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	// duplicated ibans are rejected by the unique constraint
+	suite.Error(suite.db.CreateAccount(context.Background(), &models.Account{ID: "2", IBAN: "IBAN1", Owner: "Bob"}))
+
+	account, err := suite.db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+}
+
+// TestTransfer tests that both legs of a transfer are stored or none of them.
+func (suite *SQLiteDatabaseTestSuite) TestTransfer() {
+	suite.populate("1")
+	suite.populate("2")
+
+	suite.Require().NoError(suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w1", AccountID: "1", Type: enum.Withdrawal, Amount: 20},
+		&models.Transaction{ID: "d1", AccountID: "2", Type: enum.Deposit, Amount: 20},
+	))
+
+	err := suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w2", AccountID: "1", Type: enum.Withdrawal, Amount: 20},
+		&models.Transaction{ID: "d2", AccountID: "nonexistent", Type: enum.Deposit, Amount: 20},
+	)
+	suite.Equal(errors.ErrAccountNotFound, err)
+
+	err = suite.db.Transfer(context.Background(),
+		&models.Transaction{ID: "w3", AccountID: "1", Type: enum.Withdrawal, Amount: 500},
+		&models.Transaction{ID: "d3", AccountID: "2", Type: enum.Deposit, Amount: 500},
+	)
+	suite.Equal(errors.ErrInsufficientBalance, err)
+
+	from, err := suite.db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(100), from.Balance)
+	to, err := suite.db.GetAccountByID(context.Background(), "2")
+	suite.Require().NoError(err)
+	suite.Equal(float64(140), to.Balance)
+
+	txs, err := suite.db.GetTransactionsByAccountID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Len(txs, 3)
+}
+
+// TestSortableTimestamps tests that the timestamps stored before they had a fixed width are migrated, so that the
+// periods are filtered in time order.
+func (suite *SQLiteDatabaseTestSuite) TestSortableTimestamps() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.db.CreateAccount(ctx, &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	for id, timestamp := range map[string]string{
+		"tx1": "2024-01-01T10:00:01Z",
+		"tx2": "2024-01-01T10:00:01.5Z",
+		"tx3": "2024-01-01T10:00:02.25Z",
+	} {
+		_, err := suite.db.db.Exec(`INSERT INTO transactions (id, account_id, type, amount, timestamp) VALUES (?, '1', 'deposit', 10, ?)`, id, timestamp)
+		suite.Require().NoError(err)
+	}
+
+	migrations, err := loadMigrations()
+	suite.Require().NoError(err)
+	m := migrations[len(migrations)-1]
+	suite.Require().Equal("0005_sortable_timestamps", m.name)
+	// the index already exists, so only the update of the timestamps is applied again
+	_, err = suite.db.db.Exec(strings.SplitN(m.sql, "CREATE INDEX", 2)[0])
+	suite.Require().NoError(err)
+
+	var timestamp string
+	suite.Require().NoError(suite.db.db.QueryRow(`SELECT timestamp FROM transactions WHERE id = 'tx1'`).Scan(&timestamp))
+	suite.Equal("2024-01-01T10:00:01.000000000Z", timestamp)
+
+	start := time.Date(2024, 1, 1, 10, 0, 1, 200_000_000, time.UTC)
+	var ids []string
+	suite.Require().NoError(suite.db.ScanTransactions(ctx, "1", start, start.Add(time.Second+50*time.Millisecond), func(t models.Transaction) error {
+		ids = append(ids, t.ID)
+		return nil
+	}))
+	suite.Equal([]string{"tx2"}, ids)
+
+	txs, err := suite.db.GetTransactionsByAccountID(ctx, "1")
+	suite.Require().NoError(err)
+	suite.Require().Len(txs, 3)
+	suite.Equal(start.Add(1050*time.Millisecond), txs[2].Timestamp)
+}
+
+// TestPersistence tests that the data survives reopening the database and that migrations are only applied once.
+func (suite *SQLiteDatabaseTestSuite) TestPersistence() {
+	suite.populate("1")
+	suite.Require().NoError(suite.db.Close())
+
+	db, err := NewSQLiteDatabase(suite.logger, Options{Path: suite.path, Migrate: true})
+	suite.Require().NoError(err)
+	suite.db = db
+
+	account, err := db.GetAccountByID(context.Background(), "1")
+	suite.Require().NoError(err)
+	suite.Equal(float64(120), account.Balance)
+
+	applied, err := Migrate(suite.logger, db.db)
+	suite.Require().NoError(err)
+	suite.Zero(applied)
+}
+
+// TestMigrate tests applying the migrations to an empty database.
+func (suite *SQLiteDatabaseTestSuite) TestMigrate() {
+	migrations, err := loadMigrations()
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(migrations)
+	for i := 1; i < len(migrations); i++ {
+		suite.Less(migrations[i-1].version, migrations[i].version)
+	}
+
+	db, err := Open(filepath.Join(suite.T().TempDir(), "empty.db"))
+	suite.Require().NoError(err)
+	defer db.Close()
+
+	applied, err := Migrate(suite.logger, db)
+	suite.Require().NoError(err)
+	suite.Equal(len(migrations), applied)
+
+	var version int
+	suite.Require().NoError(db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	suite.Equal(migrations[len(migrations)-1].version, version)
+}
+
+func TestSQLiteDatabaseTestSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteDatabaseTestSuite))
+}
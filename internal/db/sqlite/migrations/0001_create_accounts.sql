@@ -0,0 +1,7 @@
+CREATE TABLE accounts (
+    id              TEXT PRIMARY KEY,
+    iban            TEXT UNIQUE,
+    owner           TEXT NOT NULL,
+    balance         REAL NOT NULL,
+    initial_balance REAL NOT NULL
+);
@@ -0,0 +1,10 @@
+CREATE TABLE transactions (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    id         TEXT NOT NULL UNIQUE,
+    account_id TEXT NOT NULL REFERENCES accounts (id),
+    type       TEXT NOT NULL CHECK (type IN ('deposit', 'withdrawal')),
+    amount     REAL NOT NULL CHECK (amount > 0),
+    timestamp  TEXT NOT NULL
+);
+
+CREATE INDEX idx_transactions_account_id ON transactions (account_id, seq);
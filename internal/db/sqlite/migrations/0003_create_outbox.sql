@@ -0,0 +1,8 @@
+CREATE TABLE outbox (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    id         TEXT NOT NULL UNIQUE,
+    type       TEXT NOT NULL,
+    account_id TEXT NOT NULL,
+    payload    TEXT NOT NULL,
+    timestamp  TEXT NOT NULL
+);
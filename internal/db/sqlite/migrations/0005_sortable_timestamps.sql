@@ -0,0 +1,8 @@
+-- timestamps were stored in RFC 3339 with the trailing zeros of the fraction removed, which does not sort in time
+-- order: they are rewritten with a fraction of nine digits, so that periods can be filtered with the index
+UPDATE transactions
+SET timestamp = substr(timestamp, 1, 19) || '.' ||
+                substr(CASE WHEN substr(timestamp, 20, 1) = '.' THEN substr(timestamp, 21, length(timestamp) - 21) ELSE '' END || '000000000', 1, 9) ||
+                'Z';
+
+CREATE INDEX idx_transactions_account_timestamp ON transactions (account_id, timestamp);
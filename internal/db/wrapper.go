@@ -0,0 +1,178 @@
+package db
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"context"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// lockStripes is the number of locks in which the accounts are distributed.
+const lockStripes = 1024
+
+// Wrapper is embedded by the database adapters that wrap another adapter to act on its mutations. It forwards the
+// optional interfaces to the wrapped adapter, and holds the locks of the accounts, which are shared by every wrapper of
+// a stack so that a mutation only waits for them once.
+type Wrapper struct {
+	DatabaseAdapter
+
+	locks *accountLocks
+}
+
+// NewWrapper wraps the database adapter. If it is a wrapper itself, the locks of its accounts are shared.
+func NewWrapper(database DatabaseAdapter) Wrapper {
+	if w, ok := database.(interface{ accountLocks() *accountLocks }); ok {
+		return Wrapper{DatabaseAdapter: database, locks: w.accountLocks()}
+	}
+	return Wrapper{DatabaseAdapter: database, locks: newAccountLocks(lockStripes)}
+}
+
+// Lock locks the mutations of the accounts and returns a copy of the context that carries the locks, with the function
+// that unlocks them. Wrappers of the stack that lock the same accounts with the returned context do not wait for them
+// again, so the lock is only taken by the outermost wrapper. If the context is done while waiting, the locks already
+// taken are released and an API error is returned.
+func (w Wrapper) Lock(ctx context.Context, accountIDs ...string) (context.Context, func(), error) {
+	return w.locks.lock(ctx, accountIDs...)
+}
+
+// FreezeAccount freezes the account in the wrapped database.
+func (w Wrapper) FreezeAccount(ctx context.Context, id string) error {
+	freezer, ok := w.DatabaseAdapter.(Freezer)
+	if !ok {
+		return errors.ErrFreezeNotSupported
+	}
+	return freezer.FreezeAccount(ctx, id)
+}
+
+// UnfreezeAccount unfreezes the account in the wrapped database.
+func (w Wrapper) UnfreezeAccount(ctx context.Context, id string) error {
+	freezer, ok := w.DatabaseAdapter.(Freezer)
+	if !ok {
+		return errors.ErrFreezeNotSupported
+	}
+	return freezer.UnfreezeAccount(ctx, id)
+}
+
+// Backup writes a copy of the wrapped database to w.
+func (w Wrapper) Backup(ctx context.Context, dst io.Writer) (int64, error) {
+	backuper, ok := w.DatabaseAdapter.(Backuper)
+	if !ok {
+		return 0, errors.ErrBackupNotSupported
+	}
+	return backuper.Backup(ctx, dst)
+}
+
+// PendingEvents retrieves the oldest events of the outbox of the wrapped database.
+func (w Wrapper) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	outbox, ok := w.DatabaseAdapter.(Outbox)
+	if !ok {
+		return nil, errors.ErrOutboxNotSupported
+	}
+	return outbox.PendingEvents(ctx, limit)
+}
+
+// DeleteEvents removes the events from the outbox of the wrapped database.
+func (w Wrapper) DeleteEvents(ctx context.Context, ids ...string) error {
+	outbox, ok := w.DatabaseAdapter.(Outbox)
+	if !ok {
+		return errors.ErrOutboxNotSupported
+	}
+	return outbox.DeleteEvents(ctx, ids...)
+}
+
+// accountLocks returns the locks of the accounts, so that the wrappers of this one share them.
+func (w Wrapper) accountLocks() *accountLocks {
+	return w.locks
+}
+
+// accountLocks is a fixed table of locks in which the accounts are distributed by the hash of their id, so that its
+// size does not depend on the number of accounts. Every lock is a channel with capacity one, so that waiting for it
+// can be abandoned when the context is done.
+type accountLocks struct {
+	stripes []chan struct{}
+}
+
+// heldLocks are the locks of a table held by a context, and by the contexts it was derived from.
+type heldLocks struct {
+	stripes  []int       // sorted indexes of the stripes
+	parent   *heldLocks  // locks held by the context the locks were taken with
+	released atomic.Bool // the contexts that carry the locks no longer hold them once they are released
+}
+
+// holds checks whether the stripe is held and not yet released.
+func (h *heldLocks) holds(index int) bool {
+	for ; h != nil; h = h.parent {
+		i := sort.SearchInts(h.stripes, index)
+		if !h.released.Load() && i < len(h.stripes) && h.stripes[i] == index {
+			return true
+		}
+	}
+	return false
+}
+
+// locksKey is the key of the locks of a table held by a context.
+type locksKey struct {
+	locks *accountLocks
+}
+
+// newAccountLocks creates a table of unlocked locks.
+func newAccountLocks(stripes int) *accountLocks {
+	l := &accountLocks{stripes: make([]chan struct{}, stripes)}
+	for i := range l.stripes {
+		l.stripes[i] = make(chan struct{}, 1)
+	}
+	return l
+}
+
+// lock locks the stripes of the accounts in ascending order to avoid deadlocks, skipping the ones already held by the
+// context. A context that holds locks should only lock the accounts it already holds: waiting for others could
+// deadlock.
+func (l *accountLocks) lock(ctx context.Context, accountIDs ...string) (context.Context, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, errors.FromContext(err)
+	}
+
+	held, _ := ctx.Value(locksKey{l}).(*heldLocks)
+	indexes := make([]int, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		if index := l.index(id); !held.holds(index) {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return ctx, func() {}, nil
+	}
+	sort.Ints(indexes)
+
+	locked := &heldLocks{stripes: make([]int, 0, len(indexes)), parent: held}
+	unlock := func() {
+		locked.released.Store(true)
+		for i := len(locked.stripes) - 1; i >= 0; i-- {
+			<-l.stripes[locked.stripes[i]]
+		}
+	}
+
+	for i, index := range indexes {
+		if i > 0 && index == indexes[i-1] {
+			continue
+		}
+		select {
+		case l.stripes[index] <- struct{}{}:
+			locked.stripes = append(locked.stripes, index)
+		case <-ctx.Done():
+			unlock()
+			return nil, nil, errors.FromContext(ctx.Err())
+		}
+	}
+	return context.WithValue(ctx, locksKey{l}, locked), unlock, nil
+}
+
+// index returns the index of the stripe of the account.
+func (l *accountLocks) index(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(l.stripes)))
+}
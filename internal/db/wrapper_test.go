@@ -0,0 +1,93 @@
+package db
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/memory"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type wrapperSuite struct {
+	suite.Suite
+	inner Wrapper
+	outer Wrapper
+}
+
+func (s *wrapperSuite) SetupTest() {
+	s.inner = NewWrapper(memory.NewInMemoryDatabase(zap.NewNop().Sugar()))
+	s.outer = NewWrapper(&s.inner)
+}
+
+// locked checks whether the account is locked, by trying to lock it with a context that is done shortly.
+func (s *wrapperSuite) locked(w Wrapper, id string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, unlock, err := w.Lock(ctx, id)
+	if err != nil {
+		return true
+	}
+	unlock()
+	return false
+}
+
+// TestLock tests locking the accounts of a stack of wrappers.
+func (s *wrapperSuite) TestLock() {
+	s.Run("ok: locks shared by the stack", func() {
+		s.Same(s.inner.locks, s.outer.locks)
+		_, unlock, err := s.outer.Lock(context.Background(), "a", "b")
+		s.Require().NoError(err)
+		s.True(s.locked(s.inner, "a"))
+		s.True(s.locked(s.inner, "b"))
+		s.False(s.locked(s.inner, "1"))
+		unlock()
+		s.False(s.locked(s.inner, "a"))
+	})
+
+	s.Run("ok: locks held by the context", func() {
+		ctx, unlock, err := s.outer.Lock(context.Background(), "a", "b")
+		s.Require().NoError(err)
+		_, innerUnlock, err := s.inner.Lock(ctx, "b", "a")
+		s.Require().NoError(err)
+		innerUnlock()
+		s.True(s.locked(s.inner, "a"))
+		unlock()
+		s.False(s.locked(s.inner, "a"))
+	})
+
+	s.Run("ok: released locks are not held by the context", func() {
+		ctx, unlock, err := s.outer.Lock(context.Background(), "a")
+		s.Require().NoError(err)
+		unlock()
+
+		_, unlock, err = s.outer.Lock(context.Background(), "a")
+		s.Require().NoError(err)
+		defer unlock()
+		canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, _, err = s.inner.Lock(canceled, "a")
+		s.ErrorIs(err, errors.ErrTimeout)
+	})
+
+	s.Run("error: canceled while waiting", func() {
+		_, unlock, err := s.outer.Lock(context.Background(), "b")
+		s.Require().NoError(err)
+		defer unlock()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		_, _, err = s.inner.Lock(ctx, "a", "b")
+		s.ErrorIs(err, errors.ErrRequestCanceled)
+		s.False(s.locked(s.inner, "a"))
+	})
+}
+
+func TestWrapper(t *testing.T) {
+	suite.Run(t, new(wrapperSuite))
+}
@@ -0,0 +1,93 @@
+// Package dump moves the data of the bank between instances. Export writes every account and transaction of a
+// database to a versioned dump in JSON Lines or CSV, and Import loads a dump into another database after validating
+// it.
+package dump
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/enum"
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// batchSize is the number of accounts whose transactions are read at once while exporting.
+const batchSize = 100
+
+// Summary counts the accounts and transactions of a dump.
+type Summary struct {
+	Accounts     int `json:"accounts"`
+	Transactions int `json:"transactions"`
+}
+
+// Dumper exports and imports the data of a database.
+type Dumper struct {
+	logger   *zap.SugaredLogger
+	database db.DatabaseAdapter
+}
+
+// NewDumper creates a new dumper of the database.
+func NewDumper(logger *zap.SugaredLogger, database db.DatabaseAdapter) *Dumper {
+	return &Dumper{logger: logger, database: database}
+}
+
+// Export writes every account of the database, sorted by id, followed by its transactions in the order they were
+// stored. The dump starts with a header with the version of the format and ends with a footer that counts the
+// records, so that Import detects truncated dumps.
+func (d *Dumper) Export(ctx context.Context, w io.Writer, format enum.DumpFormat) (*Summary, error) {
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.Infof("exporting the database in %s format", format)
+	accounts, err := d.database.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+
+	exportedAt := time.Now().UTC()
+	if err := enc.encode(record{Type: headerRecord, Version: Version, ExportedAt: &exportedAt}); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	for start := 0; start < len(accounts); start += batchSize {
+		batch := accounts[start:min(start+batchSize, len(accounts))]
+		ids := make([]string, len(batch))
+		for i, acc := range batch {
+			ids[i] = acc.ID
+		}
+		transactions, err := d.database.GetTransactionsByAccountIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range batch {
+			if err := enc.encode(record{Type: accountRecord, Account: &batch[i]}); err != nil {
+				return nil, err
+			}
+			summary.Accounts++
+			txs := transactions[batch[i].ID]
+			for j := range txs {
+				if err := enc.encode(record{Type: transactionRecord, Transaction: &txs[j]}); err != nil {
+					return nil, err
+				}
+				summary.Transactions++
+			}
+		}
+	}
+
+	if err := enc.encode(record{Type: footerRecord, Count: summary}); err != nil {
+		return nil, err
+	}
+	if err := enc.flush(); err != nil {
+		return nil, err
+	}
+	d.logger.Infof("%d accounts and %d transactions exported", summary.Accounts, summary.Transactions)
+	return summary, nil
+}
@@ -0,0 +1,375 @@
+package dump
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/db/sqlite"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bytes"
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type DumpTestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	logger *zap.SugaredLogger
+	ibans  *iban.Generator
+
+	source   db.DatabaseAdapter
+	accounts []models.Account // accounts of the source database, sorted by id
+}
+
+func (s *DumpTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.logger = zap.NewExample().Sugar()
+
+	var err error
+	s.ibans, err = iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+
+	s.source = memory.NewInMemoryDatabase(s.logger)
+	s.accounts = []models.Account{s.account("Alice", 100), s.account("Bob", 100), s.account("Charlie", 100)}
+	sort.Slice(s.accounts, func(i, j int) bool { return s.accounts[i].ID < s.accounts[j].ID })
+	for i := range s.accounts {
+		s.Require().NoError(s.source.CreateAccount(s.ctx, &s.accounts[i]))
+	}
+	s.transaction(s.accounts[0].ID, enum.Deposit, 50.25)
+	s.transaction(s.accounts[0].ID, enum.Withdrawal, 120)
+	s.transaction(s.accounts[1].ID, enum.Withdrawal, 0.1)
+	s.accounts, err = s.source.GetAllAccounts(s.ctx)
+	s.Require().NoError(err)
+	sort.Slice(s.accounts, func(i, j int) bool { return s.accounts[i].ID < s.accounts[j].ID })
+}
+
+// account creates an account with a random id and IBAN.
+func (s *DumpTestSuite) account(owner string, balance float64) models.Account {
+	generated, err := s.ibans.Generate()
+	s.Require().NoError(err)
+	return models.Account{ID: uuid.NewString(), IBAN: generated, Owner: owner, Balance: balance, InitialBalance: balance}
+}
+
+// transaction stores a transaction of the account in the source database.
+func (s *DumpTestSuite) transaction(accountID string, txType enum.TransactionType, amount float64) {
+	tx := models.Transaction{ID: uuid.NewString(), AccountID: accountID, Type: txType, Amount: amount, Timestamp: time.Now().UTC()}
+	s.Require().NoError(s.source.CreateTransaction(s.ctx, &tx))
+}
+
+// export exports the source database.
+func (s *DumpTestSuite) export(format enum.DumpFormat) string {
+	var buf bytes.Buffer
+	summary, err := NewDumper(s.logger, s.source).Export(s.ctx, &buf, format)
+	s.Require().NoError(err)
+	s.Equal(&Summary{Accounts: 3, Transactions: 3}, summary)
+	return buf.String()
+}
+
+// assertSameData checks that the database has the accounts and transactions of the source database.
+func (s *DumpTestSuite) assertSameData(database db.DatabaseAdapter) {
+	accounts, err := database.GetAllAccounts(s.ctx)
+	s.Require().NoError(err)
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	s.Equal(s.accounts, accounts)
+
+	for _, acc := range s.accounts {
+		expected, err := s.source.GetTransactionsByAccountID(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		got, err := database.GetTransactionsByAccountID(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.Require().Len(got, len(expected))
+		for i := range expected {
+			s.Equal(expected[i].ID, got[i].ID)
+			s.Equal(expected[i].Amount, got[i].Amount)
+			s.True(expected[i].Timestamp.Equal(got[i].Timestamp))
+		}
+	}
+}
+
+// TestRoundTrip tests exporting a database and importing the dump into another one.
+func (s *DumpTestSuite) TestRoundTrip() {
+	for _, format := range []enum.DumpFormat{enum.JSONLinesFormat, enum.CSVFormat} {
+		s.Run("ok: "+format.String(), func() {
+			target := memory.NewInMemoryDatabase(s.logger)
+			report, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(s.export(format)), Options{Format: format})
+			s.Require().NoError(err)
+			s.Equal(&Report{Accounts: 3, Transactions: 3}, report)
+			s.assertSameData(target)
+
+			events, err := target.PendingEvents(s.ctx, 0)
+			s.Require().NoError(err)
+			s.Empty(events)
+		})
+	}
+
+	s.Run("ok: sqlite", func() {
+		target, err := sqlite.NewSQLiteDatabase(s.logger, sqlite.Options{Path: filepath.Join(s.T().TempDir(), "bank.db"), Migrate: true})
+		s.Require().NoError(err)
+		defer target.Close()
+
+		_, err = NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(s.export(enum.CSVFormat)), Options{Format: enum.CSVFormat})
+		s.Require().NoError(err)
+		s.assertSameData(target)
+	})
+
+	s.Run("ok: csv version 1", func() {
+		lines := strings.Split(s.export(enum.CSVFormat), "\n")
+		lines[0] = strings.Replace(lines[0], ",2,", ",1,", 1)
+		for i := 1; i < len(lines)-2; i++ {
+			lines[i] = lines[i][:strings.LastIndex(lines[i], ",")]
+		}
+
+		target := memory.NewInMemoryDatabase(s.logger)
+		_, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(strings.Join(lines, "\n")), Options{Format: enum.CSVFormat})
+		s.Require().NoError(err)
+		s.assertSameData(target)
+	})
+
+	s.Run("error: nothing stored", func() {
+		target, err := sqlite.NewSQLiteDatabase(s.logger, sqlite.Options{Path: filepath.Join(s.T().TempDir(), "bank.db"), Migrate: true})
+		s.Require().NoError(err)
+		defer target.Close()
+		last := s.accounts[2]
+		s.Require().NoError(target.CreateAccount(s.ctx, &last))
+
+		// the last account of the dump is stored after the dump was checked for conflicts
+		_, err = NewDumper(s.logger, &hidingDatabase{DatabaseAdapter: target, hidden: last.ID}).Import(s.ctx, strings.NewReader(s.export(enum.CSVFormat)), Options{Format: enum.CSVFormat})
+		s.ErrorContains(err, "failed to import account '"+last.ID+"'")
+		accounts, err := target.GetAllAccounts(s.ctx)
+		s.Require().NoError(err)
+		s.Equal([]models.Account{last}, accounts)
+	})
+
+	s.Run("ok: keep events", func() {
+		target := memory.NewInMemoryDatabase(s.logger)
+		existing := s.account("Dave", 10)
+		s.Require().NoError(target.CreateAccount(s.ctx, &existing))
+
+		_, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(s.export(enum.JSONLinesFormat)), Options{Format: enum.JSONLinesFormat})
+		s.Require().NoError(err)
+		events, err := target.PendingEvents(s.ctx, 0)
+		s.Require().NoError(err)
+		s.Require().Len(events, 1)
+		s.Equal(existing.ID, events[0].AccountID)
+
+		target = memory.NewInMemoryDatabase(s.logger)
+		_, err = NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(s.export(enum.JSONLinesFormat)), Options{Format: enum.JSONLinesFormat, KeepEvents: true})
+		s.Require().NoError(err)
+		events, err = target.PendingEvents(s.ctx, 0)
+		s.Require().NoError(err)
+		s.Len(events, 6)
+	})
+}
+
+// hidingDatabase is a database that reports that an account does not exist, to store it while the dump is imported.
+type hidingDatabase struct {
+	db.DatabaseAdapter
+	hidden string
+}
+
+// GetAccountByID reports that the hidden account does not exist.
+func (d *hidingDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	if id == d.hidden {
+		return nil, errors.ErrAccountNotFound
+	}
+	return d.DatabaseAdapter.GetAccountByID(ctx, id)
+}
+
+// GetAccountByIBAN reports that the hidden account does not exist.
+func (d *hidingDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	acc, err := d.DatabaseAdapter.GetAccountByIBAN(ctx, iban)
+	if err == nil && acc.ID == d.hidden {
+		return nil, errors.ErrAccountNotFound
+	}
+	return acc, err
+}
+
+// Import imports into the wrapped database.
+func (d *hidingDatabase) Import(ctx context.Context, accounts []models.Account, transactions []models.Transaction) error {
+	return d.DatabaseAdapter.(db.Importer).Import(ctx, accounts, transactions)
+}
+
+// TestDryRun tests validating a dump without importing it.
+func (s *DumpTestSuite) TestDryRun() {
+	target := memory.NewInMemoryDatabase(s.logger)
+	report, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(s.export(enum.JSONLinesFormat)), Options{Format: enum.JSONLinesFormat, DryRun: true})
+	s.Require().NoError(err)
+	s.Equal(&Report{Accounts: 3, Transactions: 3, DryRun: true}, report)
+
+	accounts, err := target.GetAllAccounts(s.ctx)
+	s.Require().NoError(err)
+	s.Empty(accounts)
+}
+
+// TestConflicts tests importing accounts that already exist.
+func (s *DumpTestSuite) TestConflicts() {
+	dump := s.export(enum.JSONLinesFormat)
+	existing := s.accounts[0]
+
+	newTarget := func() db.DatabaseAdapter {
+		target := memory.NewInMemoryDatabase(s.logger)
+		acc := existing
+		acc.Balance = acc.InitialBalance
+		s.Require().NoError(target.CreateAccount(s.ctx, &acc))
+		return target
+	}
+
+	s.Run("ok: skip", func() {
+		target := newTarget()
+		report, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(dump), Options{Format: enum.JSONLinesFormat, Conflicts: enum.ConflictSkip})
+		s.Require().NoError(err)
+		s.Equal([]string{existing.ID}, report.Skipped)
+		s.Equal(2, report.Accounts)
+		s.Equal(1, report.Transactions)
+
+		txs, err := target.GetTransactionsByAccountID(s.ctx, existing.ID)
+		s.Require().NoError(err)
+		s.Empty(txs)
+	})
+
+	s.Run("ok: remap", func() {
+		target := newTarget()
+		report, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(dump), Options{Format: enum.JSONLinesFormat, Conflicts: enum.ConflictRemap, IBANs: s.ibans})
+		s.Require().NoError(err)
+		s.Equal(3, report.Accounts)
+		s.Equal(3, report.Transactions)
+		s.Require().Contains(report.Remapped, existing.ID)
+
+		remapped, err := target.GetAccountByID(s.ctx, report.Remapped[existing.ID])
+		s.Require().NoError(err)
+		s.NotEqual(existing.IBAN, remapped.IBAN)
+		s.Equal(existing.Balance, remapped.Balance)
+		txs, err := target.GetTransactionsByAccountID(s.ctx, remapped.ID)
+		s.Require().NoError(err)
+		s.Len(txs, 2)
+	})
+
+	s.Run("ok: skip orphaned transfer legs", func() {
+		transferID := uuid.NewString()
+		withdrawal := models.Transaction{ID: uuid.NewString(), AccountID: s.accounts[2].ID, Type: enum.Withdrawal, Amount: 40, Timestamp: time.Now().UTC(), TransferID: transferID}
+		deposit := models.Transaction{ID: uuid.NewString(), AccountID: existing.ID, Type: enum.Deposit, Amount: 40, Timestamp: time.Now().UTC(), TransferID: transferID}
+		s.Require().NoError(s.source.Transfer(s.ctx, &withdrawal, &deposit))
+		for _, format := range []enum.DumpFormat{enum.JSONLinesFormat, enum.CSVFormat} {
+			var buf bytes.Buffer
+			_, err := NewDumper(s.logger, s.source).Export(s.ctx, &buf, format)
+			s.Require().NoError(err)
+
+			target := newTarget()
+			report, err := NewDumper(s.logger, target).Import(s.ctx, &buf, Options{Format: format, Conflicts: enum.ConflictSkip})
+			s.Require().NoError(err, format)
+			s.Equal([]string{existing.ID}, report.Skipped)
+			s.Equal([]string{withdrawal.ID}, report.Orphaned)
+			s.Equal(1, report.Transactions)
+
+			// the account keeps the balance of its other transactions
+			acc, err := target.GetAccountByID(s.ctx, s.accounts[2].ID)
+			s.Require().NoError(err)
+			s.Equal(float64(100), acc.Balance)
+		}
+	})
+
+	s.Run("error: orphaned transfer leg needed", func() {
+		// the withdrawal of the second account needs the deposit of a transfer from the skipped account
+		transferID := uuid.NewString()
+		withdrawal := models.Transaction{ID: uuid.NewString(), AccountID: existing.ID, Type: enum.Withdrawal, Amount: 60, Timestamp: time.Now().UTC(), TransferID: transferID}
+		deposit := models.Transaction{ID: uuid.NewString(), AccountID: s.accounts[1].ID, Type: enum.Deposit, Amount: 60, Timestamp: time.Now().UTC(), TransferID: transferID}
+		s.Require().NoError(s.source.Transfer(s.ctx, &withdrawal, &deposit))
+		s.transaction(s.accounts[1].ID, enum.Withdrawal, 150)
+		var buf bytes.Buffer
+		_, err := NewDumper(s.logger, s.source).Export(s.ctx, &buf, enum.JSONLinesFormat)
+		s.Require().NoError(err)
+
+		_, err = NewDumper(s.logger, newTarget()).Import(s.ctx, &buf, Options{Format: enum.JSONLinesFormat, Conflicts: enum.ConflictSkip, DryRun: true})
+		var validationError *ValidationError
+		s.Require().ErrorAs(err, &validationError)
+		s.Contains(err.Error(), "overdraws account '"+s.accounts[1].ID+"' without the transfers of the skipped accounts")
+	})
+
+	s.Run("error: fail", func() {
+		target := newTarget()
+		_, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(dump), Options{Format: enum.JSONLinesFormat})
+		var validationError *ValidationError
+		s.Require().ErrorAs(err, &validationError)
+		s.Len(validationError.Problems, 1)
+		s.Contains(err.Error(), "already exists")
+
+		accounts, err := target.GetAllAccounts(s.ctx)
+		s.Require().NoError(err)
+		s.Len(accounts, 1)
+	})
+
+	s.Run("error: iban conflict", func() {
+		target := memory.NewInMemoryDatabase(s.logger)
+		acc := s.account("Dave", 0)
+		acc.IBAN = s.accounts[1].IBAN
+		s.Require().NoError(target.CreateAccount(s.ctx, &acc))
+
+		_, err := NewDumper(s.logger, target).Import(s.ctx, strings.NewReader(dump), Options{Format: enum.JSONLinesFormat, DryRun: true})
+		s.ErrorContains(err, "iban '"+acc.IBAN+"'")
+	})
+}
+
+// TestValidation tests rejecting invalid dumps.
+func (s *DumpTestSuite) TestValidation() {
+	dump := s.export(enum.CSVFormat)
+	lines := strings.Split(strings.TrimSuffix(dump, "\n"), "\n")
+	// lines: header, columns, account 0, its two transactions, account 1, its transaction, account 2, footer
+	s.Require().Len(lines, 9)
+
+	replace := func(i int, old, new string) string {
+		changed := append([]string(nil), lines...)
+		changed[i] = strings.Replace(changed[i], old, new, 1)
+		return strings.Join(changed, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		dump    string
+		problem string
+	}{
+		{name: "error: empty", dump: "", problem: "missing header"},
+		{name: "error: truncated", dump: strings.Join(lines[:7], "\n"), problem: "missing footer"},
+		{name: "error: unsupported version", dump: replace(0, ",2,", ",3,"), problem: "unsupported version 3"},
+		{name: "error: wrong counts", dump: replace(8, "footer,3,3", "footer,3,4"), problem: "line 9: the footer counts 3 accounts and 4 transactions"},
+		{name: "error: invalid amount", dump: replace(3, "50.25", "abc"), problem: "line 4: invalid amount 'abc'"},
+		{name: "error: inconsistent balance", dump: replace(3, "50.25", "50.5"), problem: "line 3: account '" + s.accounts[0].ID + "' has a balance of 30.25, but its transactions add up to 30.5"},
+		{name: "error: overdraft", dump: replace(3, "deposit", "withdrawal"), problem: "overdraws account"},
+		{name: "error: invalid iban", dump: replace(2, s.accounts[0].IBAN, "ES0000000000000000000000"), problem: "has an invalid iban"},
+		{name: "error: unknown account", dump: replace(6, s.accounts[1].ID, uuid.Nil.String()), problem: "line 7: transaction"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := NewDumper(s.logger, memory.NewInMemoryDatabase(s.logger)).Import(s.ctx, strings.NewReader(tt.dump), Options{Format: enum.CSVFormat, DryRun: true})
+			var validationError *ValidationError
+			s.Require().ErrorAs(err, &validationError)
+			s.Contains(err.Error(), tt.problem)
+		})
+	}
+
+	s.Run("error: invalid json", func() {
+		_, err := NewDumper(s.logger, memory.NewInMemoryDatabase(s.logger)).Import(s.ctx, strings.NewReader(`{"type":"header","version":1}`+"\n{"), Options{Format: enum.JSONLinesFormat})
+		s.ErrorContains(err, "line 2: invalid record")
+	})
+
+	s.Run("error: invalid options", func() {
+		_, err := NewDumper(s.logger, s.source).Import(s.ctx, strings.NewReader(dump), Options{Format: "xml"})
+		s.ErrorContains(err, "invalid dump format")
+		_, err = NewDumper(s.logger, s.source).Import(s.ctx, strings.NewReader(dump), Options{Format: enum.CSVFormat, Conflicts: enum.ConflictRemap})
+		s.ErrorContains(err, "iban generator")
+	})
+}
+
+func TestDumpTestSuite(t *testing.T) {
+	suite.Run(t, new(DumpTestSuite))
+}
@@ -0,0 +1,280 @@
+package dump
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Version is the version of the format of the dumps written by Export. Import reads every version up to it. Version 2
+// added the transfer ids to the CSV dumps.
+const Version = 2
+
+// csvMagic is the first field of a CSV dump, followed by its version and the time it was exported.
+const csvMagic = "bank-export"
+
+// csvColumns are the columns of the account and transaction rows of a CSV dump.
+var csvColumns = []string{"record", "id", "account_id", "iban", "owner", "balance", "initial_balance", "type", "amount", "timestamp", "transfer_id"}
+
+// csvColumnsV1 is the number of columns of the CSV dumps of version 1, which had no transfer ids.
+const csvColumnsV1 = 10
+
+// recordType is a type for the records of a dump.
+type recordType string
+
+// Records of a dump. A dump starts with a header, followed by every account and its transactions, and ends with a
+// footer that counts them, so that truncated dumps are detected.
+const (
+	headerRecord      recordType = "header"
+	accountRecord     recordType = "account"
+	transactionRecord recordType = "transaction"
+	footerRecord      recordType = "footer"
+)
+
+// record is a record of a dump. Only the fields of its type are set.
+type record struct {
+	Type        recordType          `json:"type"`
+	Version     int                 `json:"version,omitempty"`     // header
+	ExportedAt  *time.Time          `json:"exported_at,omitempty"` // header
+	Account     *models.Account     `json:"account,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Count       *Summary            `json:"count,omitempty"` // footer
+
+	line int // line of the record in the dump, used to report its problems
+}
+
+// encoder writes the records of a dump.
+type encoder interface {
+	encode(r record) error // encode writes the record
+	flush() error          // flush writes the buffered records
+}
+
+// decoder reads the records of a dump.
+type decoder interface {
+	decode() (record, error) // decode reads the next record. It returns io.EOF after the last one
+}
+
+// newEncoder creates the encoder of the format.
+func newEncoder(w io.Writer, format enum.DumpFormat) (encoder, error) {
+	switch format {
+	case enum.JSONLinesFormat:
+		bw := bufio.NewWriter(w)
+		return &jsonLinesEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	case enum.CSVFormat:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("invalid dump format: %s", format)
+	}
+}
+
+// newDecoder creates the decoder of the format.
+func newDecoder(r io.Reader, format enum.DumpFormat) (decoder, error) {
+	switch format {
+	case enum.JSONLinesFormat:
+		return &jsonLinesDecoder{s: bufio.NewScanner(r)}, nil
+	case enum.CSVFormat:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		return &csvDecoder{r: cr}, nil
+	default:
+		return nil, fmt.Errorf("invalid dump format: %s", format)
+	}
+}
+
+// jsonLinesEncoder writes every record as a JSON object in its own line.
+type jsonLinesEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonLinesEncoder) encode(r record) error {
+	return e.enc.Encode(r)
+}
+
+func (e *jsonLinesEncoder) flush() error {
+	return e.w.Flush()
+}
+
+// jsonLinesDecoder reads a JSON object from every line. Blank lines are ignored.
+type jsonLinesDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (d *jsonLinesDecoder) decode() (record, error) {
+	for d.s.Scan() {
+		d.line++
+		if len(d.s.Bytes()) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(d.s.Bytes(), &r); err != nil {
+			return record{}, fmt.Errorf("line %d: invalid record: %v", d.line, err)
+		}
+		r.line = d.line
+		return r, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// csvEncoder writes the header and the footer as rows of their own, and the accounts and transactions as rows of the
+// csv columns.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) encode(r record) error {
+	switch r.Type {
+	case headerRecord:
+		if err := e.w.Write([]string{csvMagic, strconv.Itoa(r.Version), r.ExportedAt.Format(time.RFC3339Nano)}); err != nil {
+			return err
+		}
+		return e.w.Write(csvColumns)
+	case accountRecord:
+		a := r.Account
+		return e.w.Write([]string{string(r.Type), a.ID, "", a.IBAN, a.Owner, formatFloat(a.Balance), formatFloat(a.InitialBalance), "", "", "", ""})
+	case transactionRecord:
+		t := r.Transaction
+		return e.w.Write([]string{string(r.Type), t.ID, t.AccountID, "", "", "", "", t.Type.String(), formatFloat(t.Amount), t.Timestamp.Format(time.RFC3339Nano), t.TransferID})
+	case footerRecord:
+		return e.w.Write([]string{string(r.Type), strconv.Itoa(r.Count.Accounts), strconv.Itoa(r.Count.Transactions)})
+	default:
+		return fmt.Errorf("invalid record type: %s", r.Type)
+	}
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvDecoder reads the rows written by csvEncoder.
+type csvDecoder struct {
+	r       *csv.Reader
+	columns int // number of columns of the dump, zero until the row of the columns is read
+}
+
+func (d *csvDecoder) decode() (record, error) {
+	row, err := d.r.Read()
+	if err != nil {
+		return record{}, err
+	}
+	line, _ := d.r.FieldPos(0)
+
+	r, err := d.parse(row)
+	if err != nil {
+		return record{}, fmt.Errorf("line %d: %v", line, err)
+	}
+	r.line = line
+	return r, nil
+}
+
+// parse parses a row of the dump.
+func (d *csvDecoder) parse(row []string) (record, error) {
+	if row[0] == csvMagic {
+		if len(row) != 3 {
+			return record{}, fmt.Errorf("invalid header: expected 3 fields, got %d", len(row))
+		}
+		version, err := strconv.Atoi(row[1])
+		if err != nil {
+			return record{}, fmt.Errorf("invalid version '%s'", row[1])
+		}
+		exportedAt, err := time.Parse(time.RFC3339Nano, row[2])
+		if err != nil {
+			return record{}, fmt.Errorf("invalid export time '%s'", row[2])
+		}
+
+		columns, err := d.r.Read()
+		if err != nil {
+			return record{}, fmt.Errorf("missing columns: %v", err)
+		}
+		expected := len(csvColumns)
+		if version < 2 {
+			expected = csvColumnsV1
+		}
+		if len(columns) != expected || columns[0] != csvColumns[0] {
+			return record{}, fmt.Errorf("invalid columns")
+		}
+		d.columns = expected
+		return record{Type: headerRecord, Version: version, ExportedAt: &exportedAt}, nil
+	}
+
+	r := record{Type: recordType(row[0])}
+	switch r.Type {
+	case footerRecord:
+		if len(row) != 3 {
+			return record{}, fmt.Errorf("invalid footer: expected 3 fields, got %d", len(row))
+		}
+		accounts, err := strconv.Atoi(row[1])
+		if err != nil {
+			return record{}, fmt.Errorf("invalid number of accounts '%s'", row[1])
+		}
+		transactions, err := strconv.Atoi(row[2])
+		if err != nil {
+			return record{}, fmt.Errorf("invalid number of transactions '%s'", row[2])
+		}
+		r.Count = &Summary{Accounts: accounts, Transactions: transactions}
+		return r, nil
+	case accountRecord, transactionRecord:
+	default:
+		return record{}, fmt.Errorf("invalid record type '%s'", row[0])
+	}
+
+	if d.columns == 0 {
+		return record{}, fmt.Errorf("missing header")
+	}
+	if len(row) != d.columns {
+		return record{}, fmt.Errorf("expected %d fields, got %d", d.columns, len(row))
+	}
+	// the fields in the order of csvColumns
+	id, accountID, iban, owner, balance, initialBalance, txType, amount, timestamp := row[1], row[2], row[3], row[4], row[5], row[6], row[7], row[8], row[9]
+
+	var err error
+	if r.Type == accountRecord {
+		a := &models.Account{ID: id, IBAN: iban, Owner: owner}
+		if a.Balance, err = parseFloat("balance", balance); err != nil {
+			return record{}, err
+		}
+		if a.InitialBalance, err = parseFloat("initial balance", initialBalance); err != nil {
+			return record{}, err
+		}
+		r.Account = a
+		return r, nil
+	}
+
+	t := &models.Transaction{ID: id, AccountID: accountID, Type: enum.TransactionType(txType)}
+	if d.columns > csvColumnsV1 {
+		t.TransferID = row[10]
+	}
+	if t.Amount, err = parseFloat("amount", amount); err != nil {
+		return record{}, err
+	}
+	if t.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+		return record{}, fmt.Errorf("invalid timestamp '%s'", timestamp)
+	}
+	r.Transaction = t
+	return r, nil
+}
+
+// formatFloat formats an amount with the digits needed to parse it back exactly.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseFloat parses an amount of the field.
+func parseFloat(name, value string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", name, value)
+	}
+	return v, nil
+}
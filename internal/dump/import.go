@@ -0,0 +1,418 @@
+package dump
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// tolerance is the maximum difference allowed between the balance of an account and the balance derived from its
+// transactions, to absorb the rounding errors of the float arithmetic.
+const tolerance = 1e-6
+
+// Options configures an import.
+type Options struct {
+	Format     enum.DumpFormat     // format of the dump
+	Conflicts  enum.ConflictPolicy // policy applied to the accounts that already exist in the database. Defaults to ConflictFail
+	DryRun     bool                // validate the dump and report what would be imported without storing anything
+	KeepEvents bool                // keep the events produced by the import in the outbox, so that they are delivered to the webhooks
+	IBANs      *iban.Generator     // generates the IBANs of the remapped accounts. Required by ConflictRemap
+}
+
+// Report is the result of an import.
+type Report struct {
+	Accounts     int               `json:"accounts"`           // accounts imported, or that would be imported in a dry run
+	Transactions int               `json:"transactions"`       // transactions imported, or that would be imported in a dry run
+	Skipped      []string          `json:"skipped,omitempty"`  // ids of the accounts that already existed and were skipped
+	Orphaned     []string          `json:"orphaned,omitempty"` // ids of the transfer legs that were skipped because their other leg belongs to a skipped account
+	Remapped     map[string]string `json:"remapped,omitempty"` // new ids of the accounts that already existed, keyed by their id in the dump
+	DryRun       bool              `json:"dry_run"`
+}
+
+// ValidationError is returned when a dump cannot be imported. It lists every problem found, with the line of the dump
+// where it was found.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid dump: %s", strings.Join(e.Problems, "; "))
+}
+
+// entry is an account of the dump with its transactions.
+type entry struct {
+	account      models.Account
+	transactions []models.Transaction
+	line         int
+}
+
+// Import loads a dump written by Export into the database. The whole dump is validated before anything is stored:
+// its structure, every record, and that the balance of every account matches its initial balance plus its
+// transactions. Accounts whose id or IBAN already exist in the database are handled with the conflict policy of the
+// options.
+//
+// Accounts are created with their initial balance and their transactions are replayed in order, so the databases
+// keep their own invariants. Databases that implement db.Importer store them in a single atomic step, so a failed
+// import stores nothing. In the others, the records stored before the one that failed are kept.
+func (d *Dumper) Import(ctx context.Context, r io.Reader, opts Options) (*Report, error) {
+	if opts.Conflicts == "" {
+		opts.Conflicts = enum.ConflictFail
+	}
+	if !opts.Conflicts.IsValid() {
+		return nil, fmt.Errorf("invalid conflict policy: %s", opts.Conflicts)
+	}
+	if opts.Conflicts == enum.ConflictRemap && opts.IBANs == nil {
+		return nil, fmt.Errorf("an iban generator is required to remap the accounts")
+	}
+
+	dec, err := newDecoder(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]record, 0)
+	for {
+		rec, err := dec.decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &ValidationError{Problems: []string{err.Error()}}
+		}
+		records = append(records, rec)
+	}
+
+	entries, err := validate(records)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{DryRun: opts.DryRun}
+	if entries, err = d.resolveConflicts(ctx, entries, opts, report); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		report.Accounts++
+		report.Transactions += len(e.transactions)
+	}
+	if opts.DryRun {
+		d.logger.Infof("dry run: %d accounts and %d transactions would be imported", report.Accounts, report.Transactions)
+		return report, nil
+	}
+
+	// the events already waiting in the outbox are kept, only the ones produced by the import are drained
+	outbox, hasOutbox := d.database.(db.Outbox)
+	pending := make(map[string]bool)
+	if hasOutbox && !opts.KeepEvents {
+		events, err := outbox.PendingEvents(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			pending[e.ID] = true
+		}
+	}
+
+	d.logger.Infof("importing %d accounts and %d transactions", report.Accounts, report.Transactions)
+	if err := d.store(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	if hasOutbox && !opts.KeepEvents {
+		events, err := outbox.PendingEvents(ctx, len(pending)+report.Accounts+report.Transactions)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(events))
+		for _, e := range events {
+			if !pending[e.ID] {
+				ids = append(ids, e.ID)
+			}
+		}
+		if err := outbox.DeleteEvents(ctx, ids...); err != nil {
+			return nil, err
+		}
+		d.logger.Debugf("%d events of the import removed from the outbox", len(ids))
+	}
+
+	d.logger.Infof("%d accounts and %d transactions imported", report.Accounts, report.Transactions)
+	return report, nil
+}
+
+// store creates the accounts with their initial balance and replays their transactions, in a single atomic step if the
+// database implements db.Importer.
+func (d *Dumper) store(ctx context.Context, entries []*entry) error {
+	if importer, ok := d.database.(db.Importer); ok {
+		accounts := make([]models.Account, 0, len(entries))
+		transactions := make([]models.Transaction, 0)
+		for _, e := range entries {
+			acc := e.account
+			acc.Balance = acc.InitialBalance
+			accounts = append(accounts, acc)
+			transactions = append(transactions, e.transactions...)
+		}
+		return importer.Import(ctx, accounts, transactions)
+	}
+
+	d.logger.Warnf("the database cannot import atomically, the records stored before a failure are kept")
+	for _, e := range entries {
+		acc := e.account
+		acc.Balance = acc.InitialBalance
+		if err := d.database.CreateAccount(ctx, &acc); err != nil {
+			return fmt.Errorf("failed to import account '%s': %w", acc.ID, err)
+		}
+		for i := range e.transactions {
+			if err := d.database.CreateTransaction(ctx, &e.transactions[i]); err != nil {
+				return fmt.Errorf("failed to import transaction '%s': %w", e.transactions[i].ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the structure of the dump and every record, and returns its accounts with their transactions in
+// the order of the dump.
+func validate(records []record) ([]*entry, error) {
+	var problems []string
+	problem := func(line int, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+	}
+
+	if len(records) == 0 || records[0].Type != headerRecord {
+		return nil, &ValidationError{Problems: []string{"missing header"}}
+	}
+	if v := records[0].Version; v < 1 || v > Version {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("unsupported version %d, expected up to %d", v, Version)}}
+	}
+	last := records[len(records)-1]
+	if last.Type != footerRecord || last.Count == nil {
+		return nil, &ValidationError{Problems: []string{"missing footer, the dump may be truncated"}}
+	}
+
+	entries := make([]*entry, 0)
+	accounts := make(map[string]*entry)
+	ibans := make(map[string]bool)
+	transactions := make(map[string]bool)
+	txCount := 0
+	for _, rec := range records[1 : len(records)-1] {
+		switch {
+		case rec.Type == accountRecord && rec.Account != nil:
+			a := *rec.Account
+			if uuid.Validate(a.ID) != nil {
+				problem(rec.line, "invalid account id '%s'", a.ID)
+			} else if accounts[a.ID] != nil {
+				problem(rec.line, "duplicated account id '%s'", a.ID)
+			}
+			if err := iban.Validate(a.IBAN); err != nil {
+				problem(rec.line, "account '%s' has an invalid iban: %v", a.ID, err)
+			} else if ibans[a.IBAN] {
+				problem(rec.line, "duplicated iban '%s'", a.IBAN)
+			}
+			if a.Owner == "" {
+				problem(rec.line, "account '%s' has no owner", a.ID)
+			}
+			if a.Balance < 0 || a.InitialBalance < 0 {
+				problem(rec.line, "account '%s' has a negative balance", a.ID)
+			}
+
+			e := &entry{account: a, line: rec.line}
+			entries = append(entries, e)
+			if accounts[a.ID] == nil {
+				accounts[a.ID] = e
+			}
+			ibans[a.IBAN] = true
+		case rec.Type == transactionRecord && rec.Transaction != nil:
+			t := *rec.Transaction
+			txCount++
+			if uuid.Validate(t.ID) != nil {
+				problem(rec.line, "invalid transaction id '%s'", t.ID)
+			} else if transactions[t.ID] {
+				problem(rec.line, "duplicated transaction id '%s'", t.ID)
+			}
+			transactions[t.ID] = true
+			if t.Type != enum.Deposit && t.Type != enum.Withdrawal {
+				problem(rec.line, "transaction '%s' has an invalid type '%s'", t.ID, t.Type)
+			}
+			if t.Amount <= 0 {
+				problem(rec.line, "transaction '%s' has a non-positive amount", t.ID)
+			}
+			if t.Timestamp.IsZero() {
+				problem(rec.line, "transaction '%s' has no timestamp", t.ID)
+			}
+			e := accounts[t.AccountID]
+			if e == nil {
+				problem(rec.line, "transaction '%s' belongs to account '%s', which is not in the dump before it", t.ID, t.AccountID)
+				continue
+			}
+			e.transactions = append(e.transactions, t)
+		default:
+			problem(rec.line, "unexpected %s record", rec.Type)
+		}
+	}
+
+	if last.Count.Accounts != len(entries) || last.Count.Transactions != txCount {
+		problem(last.line, "the footer counts %d accounts and %d transactions, but the dump has %d and %d",
+			last.Count.Accounts, last.Count.Transactions, len(entries), txCount)
+	}
+
+	// the balance of every account must be its initial balance plus its transactions, without overdrafts
+	for _, e := range entries {
+		balance := e.account.InitialBalance
+		for _, t := range e.transactions {
+			switch t.Type {
+			case enum.Deposit:
+				balance += t.Amount
+			case enum.Withdrawal:
+				if balance < t.Amount {
+					problem(e.line, "transaction '%s' overdraws account '%s'", t.ID, e.account.ID)
+				}
+				balance -= t.Amount
+			}
+		}
+		if math.Abs(balance-e.account.Balance) > tolerance {
+			problem(e.line, "account '%s' has a balance of %v, but its transactions add up to %v", e.account.ID, e.account.Balance, balance)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return entries, nil
+}
+
+// resolveConflicts looks for the accounts of the dump whose id or IBAN already exist in the database, and applies the
+// conflict policy to them. It returns the accounts to import.
+func (d *Dumper) resolveConflicts(ctx context.Context, entries []*entry, opts Options, report *Report) ([]*entry, error) {
+	var problems []string
+	resolved := make([]*entry, 0, len(entries))
+	skipped := make(map[string]bool) // ids of the transfers with a leg in a skipped account
+	for _, e := range entries {
+		conflict, err := d.exists(ctx, e.account)
+		if err != nil {
+			return nil, err
+		}
+		if conflict == "" {
+			resolved = append(resolved, e)
+			continue
+		}
+
+		switch opts.Conflicts {
+		case enum.ConflictSkip:
+			d.logger.Infof("skipping account '%s': %s", e.account.ID, conflict)
+			report.Skipped = append(report.Skipped, e.account.ID)
+			for _, t := range e.transactions {
+				if t.TransferID != "" {
+					skipped[t.TransferID] = true
+				}
+			}
+		case enum.ConflictRemap:
+			remapped, err := d.remap(ctx, e, opts.IBANs)
+			if err != nil {
+				return nil, err
+			}
+			d.logger.Infof("remapping account '%s' to '%s': %s", e.account.ID, remapped.account.ID, conflict)
+			if report.Remapped == nil {
+				report.Remapped = make(map[string]string)
+			}
+			report.Remapped[e.account.ID] = remapped.account.ID
+			resolved = append(resolved, remapped)
+		default:
+			problems = append(problems, fmt.Sprintf("line %d: %s", e.line, conflict))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if len(skipped) > 0 {
+		return d.skipOrphans(resolved, skipped, report)
+	}
+	return resolved, nil
+}
+
+// skipOrphans removes the transfer legs whose other leg belongs to a skipped account, which would be imported without
+// it, and lists them in the report. The accounts keep the balance derived from their other transactions, which must
+// not overdraw them.
+func (d *Dumper) skipOrphans(entries []*entry, skipped map[string]bool, report *Report) ([]*entry, error) {
+	var problems []string
+	for _, e := range entries {
+		kept := make([]models.Transaction, 0, len(e.transactions))
+		balance := e.account.InitialBalance
+		for _, t := range e.transactions {
+			if skipped[t.TransferID] {
+				d.logger.Infof("skipping transaction '%s': the other leg of transfer '%s' belongs to a skipped account", t.ID, t.TransferID)
+				report.Orphaned = append(report.Orphaned, t.ID)
+				continue
+			}
+			switch t.Type {
+			case enum.Deposit:
+				balance += t.Amount
+			case enum.Withdrawal:
+				if balance < t.Amount {
+					problems = append(problems, fmt.Sprintf("line %d: transaction '%s' overdraws account '%s' without the transfers of the skipped accounts", e.line, t.ID, e.account.ID))
+				}
+				balance -= t.Amount
+			}
+			kept = append(kept, t)
+		}
+		e.transactions = kept
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return entries, nil
+}
+
+// exists describes why the account conflicts with the accounts of the database, or returns an empty string if it
+// does not.
+func (d *Dumper) exists(ctx context.Context, account models.Account) (string, error) {
+	if _, err := d.database.GetAccountByID(ctx, account.ID); err == nil {
+		return fmt.Sprintf("account '%s' already exists", account.ID), nil
+	} else if !stderrors.Is(err, errors.ErrAccountNotFound) {
+		return "", err
+	}
+	if _, err := d.database.GetAccountByIBAN(ctx, account.IBAN); err == nil {
+		return fmt.Sprintf("iban '%s' of account '%s' already exists", account.IBAN, account.ID), nil
+	} else if !stderrors.Is(err, errors.ErrAccountNotFound) {
+		return "", err
+	}
+	return "", nil
+}
+
+// remap copies the account with a new id and a new IBAN that are not in the database, and its transactions with new
+// ids.
+func (d *Dumper) remap(ctx context.Context, e *entry, ibans *iban.Generator) (*entry, error) {
+	remapped := &entry{account: e.account, line: e.line}
+	remapped.account.ID = uuid.NewString()
+	for {
+		generated, err := ibans.Generate()
+		if err != nil {
+			return nil, err
+		}
+		remapped.account.IBAN = generated
+		conflict, err := d.exists(ctx, remapped.account)
+		if err != nil {
+			return nil, err
+		}
+		if conflict == "" {
+			break
+		}
+	}
+
+	remapped.transactions = make([]models.Transaction, len(e.transactions))
+	for i, t := range e.transactions {
+		t.ID = uuid.NewString()
+		t.AccountID = remapped.account.ID
+		remapped.transactions[i] = t
+	}
+	return remapped, nil
+}
@@ -0,0 +1,25 @@
+package enum
+
+// ActivityType is a type for the changes of an account pushed to the subscribers of its activity
+type ActivityType string
+
+// Activity types
+const (
+	TransactionActivity ActivityType = "transaction" // a transaction of the account was committed
+	BalanceActivity     ActivityType = "balance"     // the balance of the account changed
+)
+
+// String returns the string representation of the activity type
+func (a ActivityType) String() string {
+	return string(a)
+}
+
+// IsValid checks if the activity type is valid
+func (a ActivityType) IsValid() bool {
+	switch a {
+	case TransactionActivity, BalanceActivity:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,27 @@
+package enum
+
+// DatabaseDriver is a type for the database implementations that can be used by the API
+type DatabaseDriver string
+
+// Database drivers
+const (
+	MemoryDriver     DatabaseDriver = "memory"
+	EventStoreDriver DatabaseDriver = "eventstore"
+	SQLiteDriver     DatabaseDriver = "sqlite"
+	BoltDriver       DatabaseDriver = "bolt"
+)
+
+// String returns the string representation of the database driver
+func (e DatabaseDriver) String() string {
+	return string(e)
+}
+
+// IsValid checks if the database driver is valid
+func (e DatabaseDriver) IsValid() bool {
+	switch e {
+	case MemoryDriver, EventStoreDriver, SQLiteDriver, BoltDriver:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+package enum
+
+// DumpFormat is a type for the formats in which the data of the database is exported and imported
+type DumpFormat string
+
+// Dump formats
+const (
+	JSONLinesFormat DumpFormat = "jsonl" // one JSON record per line
+	CSVFormat       DumpFormat = "csv"   // one CSV row per record
+)
+
+// String returns the string representation of the dump format
+func (e DumpFormat) String() string {
+	return string(e)
+}
+
+// IsValid checks if the dump format is valid
+func (e DumpFormat) IsValid() bool {
+	switch e {
+	case JSONLinesFormat, CSVFormat:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConflictPolicy is a type for the policies applied when an imported account already exists in the database
+type ConflictPolicy string
+
+// Conflict policies
+const (
+	ConflictFail  ConflictPolicy = "fail"  // the import is aborted before anything is stored
+	ConflictSkip  ConflictPolicy = "skip"  // the account and its transactions are not imported
+	ConflictRemap ConflictPolicy = "remap" // the account and its transactions are imported with new ids and a new IBAN
+)
+
+// String returns the string representation of the conflict policy
+func (e ConflictPolicy) String() string {
+	return string(e)
+}
+
+// IsValid checks if the conflict policy is valid
+func (e ConflictPolicy) IsValid() bool {
+	switch e {
+	case ConflictFail, ConflictSkip, ConflictRemap:
+		return true
+	default:
+		return false
+	}
+}
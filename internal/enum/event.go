@@ -0,0 +1,26 @@
+package enum
+
+// EventType is a type for the domain events published to the webhooks
+type EventType string
+
+// Event types
+const (
+	AccountCreated     EventType = "account.created"     // a new account was opened
+	TransactionCreated EventType = "transaction.created" // money was deposited into or withdrawn from an account
+	TransferCompleted  EventType = "transfer.completed"  // money was transferred between two accounts
+)
+
+// String returns the string representation of the event type
+func (e EventType) String() string {
+	return string(e)
+}
+
+// IsValid checks if the event type is valid
+func (e EventType) IsValid() bool {
+	switch e {
+	case AccountCreated, TransactionCreated, TransferCompleted:
+		return true
+	default:
+		return false
+	}
+}
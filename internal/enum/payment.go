@@ -0,0 +1,25 @@
+package enum
+
+// ExecutionMode is a type for the ways in which the credit transfers of a payment file are executed
+type ExecutionMode string
+
+// Execution modes
+const (
+	AtomicExecution  ExecutionMode = "atomic"   // every transfer is executed or none is
+	PerItemExecution ExecutionMode = "per_item" // every transfer is executed on its own
+)
+
+// String returns the string representation of the execution mode
+func (e ExecutionMode) String() string {
+	return string(e)
+}
+
+// IsValid checks if the execution mode is valid
+func (e ExecutionMode) IsValid() bool {
+	switch e {
+	case AtomicExecution, PerItemExecution:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,27 @@
+package enum
+
+// Role is a type for the roles of the callers of the API, which decide the operations they can perform
+type Role string
+
+// Roles
+const (
+	CustomerRole Role = "customer" // operates its own accounts
+	TellerRole   Role = "teller"   // opens accounts and deposits money into any account
+	AuditorRole  Role = "auditor"  // reads everything, including the audit log, and changes nothing
+	AdminRole    Role = "admin"    // performs every operation, including freezing accounts
+)
+
+// String returns the string representation of the role
+func (r Role) String() string {
+	return string(r)
+}
+
+// IsValid checks if the role is valid
+func (r Role) IsValid() bool {
+	switch r {
+	case CustomerRole, TellerRole, AuditorRole, AdminRole:
+		return true
+	default:
+		return false
+	}
+}
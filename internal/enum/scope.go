@@ -0,0 +1,36 @@
+package enum
+
+// Scope is a type for the permissions granted to an API key
+type Scope string
+
+// Scopes
+const (
+	AccountsRead      Scope = "accounts:read"      // read accounts and stream their balances
+	AccountsWrite     Scope = "accounts:write"     // open accounts
+	TransactionsRead  Scope = "transactions:read"  // read the transactions, events and statements of accounts
+	TransactionsWrite Scope = "transactions:write" // deposit and withdraw money
+	TransfersWrite    Scope = "transfers:write"    // transfer money and submit payment files
+	WebhooksRead      Scope = "webhooks:read"      // read webhook subscriptions and dead letters
+	WebhooksWrite     Scope = "webhooks:write"     // manage webhook subscriptions and redeliver dead letters
+	AdminScope        Scope = "admin"              // reconciliations, audit log, backups and api keys
+)
+
+// Scopes returns every scope
+func Scopes() []Scope {
+	return []Scope{AccountsRead, AccountsWrite, TransactionsRead, TransactionsWrite, TransfersWrite, WebhooksRead, WebhooksWrite, AdminScope}
+}
+
+// String returns the string representation of the scope
+func (s Scope) String() string {
+	return string(s)
+}
+
+// IsValid checks if the scope is valid
+func (s Scope) IsValid() bool {
+	switch s {
+	case AccountsRead, AccountsWrite, TransactionsRead, TransactionsWrite, TransfersWrite, WebhooksRead, WebhooksWrite, AdminScope:
+		return true
+	default:
+		return false
+	}
+}
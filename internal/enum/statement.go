@@ -0,0 +1,26 @@
+package enum
+
+// StatementFormat is a type for the formats in which the statements of an account are exported
+type StatementFormat string
+
+// Statement formats
+const (
+	CSVStatement     StatementFormat = "csv"     // comma-separated values, one row per transaction
+	OFXStatement     StatementFormat = "ofx"     // Open Financial Exchange 2.2
+	CAMT053Statement StatementFormat = "camt053" // ISO 20022 bank-to-customer statement, camt.053.001.02
+)
+
+// String returns the string representation of the statement format
+func (e StatementFormat) String() string {
+	return string(e)
+}
+
+// IsValid checks if the statement format is valid
+func (e StatementFormat) IsValid() bool {
+	switch e {
+	case CSVStatement, OFXStatement, CAMT053Statement:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+package enum
+
+// SyncPolicy is a type for the policies used to sync the write-ahead log to disk
+type SyncPolicy string
+
+// Sync policies
+const (
+	SyncAlways   SyncPolicy = "always"   // every mutation is synced before it is acknowledged
+	SyncInterval SyncPolicy = "interval" // the log is synced periodically
+	SyncNever    SyncPolicy = "never"    // syncing is left to the operating system
+)
+
+// String returns the string representation of the sync policy
+func (e SyncPolicy) String() string {
+	return string(e)
+}
+
+// IsValid checks if the sync policy is valid
+func (e SyncPolicy) IsValid() bool {
+	switch e {
+	case SyncAlways, SyncInterval, SyncNever:
+		return true
+	default:
+		return false
+	}
+}
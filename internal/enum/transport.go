@@ -0,0 +1,26 @@
+package enum
+
+// Transport is a type for the transport layers that can serve the API
+type Transport string
+
+// Transports
+const (
+	HTTPTransport    Transport = "http"
+	GRPCTransport    Transport = "grpc"
+	GraphQLTransport Transport = "graphql"
+)
+
+// String returns the string representation of the transport
+func (t Transport) String() string {
+	return string(t)
+}
+
+// IsValid checks if the transport is valid
+func (t Transport) IsValid() bool {
+	switch t {
+	case HTTPTransport, GRPCTransport, GraphQLTransport:
+		return true
+	default:
+		return false
+	}
+}
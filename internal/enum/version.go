@@ -0,0 +1,25 @@
+package enum
+
+// APIVersion is a type for the versions of the HTTP API
+type APIVersion string
+
+// API versions
+const (
+	V1 APIVersion = "v1" // amounts are JSON numbers
+	V2 APIVersion = "v2" // amounts are decimal strings
+)
+
+// String returns the string representation of the API version
+func (v APIVersion) String() string {
+	return string(v)
+}
+
+// IsValid checks if the API version is valid
+func (v APIVersion) IsValid() bool {
+	switch v {
+	case V1, V2:
+		return true
+	default:
+		return false
+	}
+}
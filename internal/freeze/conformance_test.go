@@ -0,0 +1,23 @@
+package freeze_test
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/dbtest"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/freeze"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+func TestFreezingDatabaseConformance(t *testing.T) {
+	suite.Run(t, &dbtest.ConformanceSuite{NewDatabase: func(t *testing.T) db.DatabaseAdapter {
+		logger := zap.NewNop().Sugar()
+		store, err := freeze.NewStore(logger, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return freeze.NewDatabase(logger, memory.NewInMemoryDatabase(logger), store)
+	}})
+}
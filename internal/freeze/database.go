@@ -0,0 +1,153 @@
+package freeze
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// freezingDatabase is a database adapter that rejects the transactions and transfers of the frozen accounts, and
+// tells which accounts are frozen when they are read.
+// The mutations and the freezes of every account hold its lock, so that an account cannot be frozen between the check
+// of a mutation and the mutation itself.
+type freezingDatabase struct {
+	db.Wrapper
+
+	logger *zap.SugaredLogger
+	store  *Store
+}
+
+// NewDatabase wraps the database adapter so that the accounts frozen in the store cannot be operated. The returned
+// adapter implements db.Freezer.
+func NewDatabase(logger *zap.SugaredLogger, database db.DatabaseAdapter, store *Store) db.DatabaseAdapter {
+	return &freezingDatabase{Wrapper: db.NewWrapper(database), logger: logger, store: store}
+}
+
+// GetAccountByID retrieves the account from the underlying database, and tells whether it is frozen.
+func (d *freezingDatabase) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	acc, err := d.DatabaseAdapter.GetAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	acc.Frozen = d.store.IsFrozen(acc.ID)
+	return acc, nil
+}
+
+// GetAccountByIBAN retrieves the account from the underlying database, and tells whether it is frozen.
+func (d *freezingDatabase) GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	acc, err := d.DatabaseAdapter.GetAccountByIBAN(ctx, iban)
+	if err != nil {
+		return nil, err
+	}
+	acc.Frozen = d.store.IsFrozen(acc.ID)
+	return acc, nil
+}
+
+// GetAllAccounts retrieves all accounts from the underlying database, and tells which ones are frozen.
+func (d *freezingDatabase) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
+	accounts, err := d.DatabaseAdapter.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		accounts[i].Frozen = d.store.IsFrozen(accounts[i].ID)
+	}
+	return accounts, nil
+}
+
+// CreateTransaction creates the transaction in the underlying database, unless its account is frozen.
+func (d *freezingDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, transaction.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.store.IsFrozen(transaction.AccountID) {
+		d.logger.Warnf("transaction rejected: account '%s' is frozen", transaction.AccountID)
+		return errors.ErrAccountFrozen
+	}
+	return d.DatabaseAdapter.CreateTransaction(ctx, transaction)
+}
+
+// Transfer stores both legs of the transfer in the underlying database, unless one of their accounts is frozen.
+func (d *freezingDatabase) Transfer(ctx context.Context, withdrawal, deposit *models.Transaction) error {
+	ctx, unlock, err := d.Lock(ctx, withdrawal.AccountID, deposit.AccountID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, id := range []string{withdrawal.AccountID, deposit.AccountID} {
+		if d.store.IsFrozen(id) {
+			d.logger.Warnf("transfer rejected: account '%s' is frozen", id)
+			return errors.ErrAccountFrozen
+		}
+	}
+	return d.DatabaseAdapter.Transfer(ctx, withdrawal, deposit)
+}
+
+// TransferBatch stores the transfers in the underlying database, unless one of their accounts is frozen, in which
+// case none of them is stored.
+func (d *freezingDatabase) TransferBatch(ctx context.Context, transfers []models.Transfer) error {
+	accountIDs := make([]string, 0, 2*len(transfers))
+	for _, t := range transfers {
+		accountIDs = append(accountIDs, t.Withdrawal.AccountID, t.Deposit.AccountID)
+	}
+	ctx, unlock, err := d.Lock(ctx, accountIDs...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for i, t := range transfers {
+		for _, id := range []string{t.Withdrawal.AccountID, t.Deposit.AccountID} {
+			if d.store.IsFrozen(id) {
+				d.logger.Warnf("batch of transfers rejected: account '%s' is frozen", id)
+				return &errors.BatchError{Index: i, Err: errors.ErrAccountFrozen}
+			}
+		}
+	}
+	return d.DatabaseAdapter.TransferBatch(ctx, transfers)
+}
+
+// FreezeAccount freezes the account, which must exist in the underlying database, once its pending mutations are done.
+func (d *freezingDatabase) FreezeAccount(ctx context.Context, id string) error {
+	ctx, unlock, err := d.Lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.DatabaseAdapter.GetAccountByID(ctx, id); err != nil {
+		return err
+	}
+	if err := d.store.Freeze(id); err != nil {
+		d.logger.Errorf("failed to freeze account '%s': %v", id, err)
+		return err
+	}
+	d.logger.Infof("account '%s' frozen", id)
+	return nil
+}
+
+// UnfreezeAccount unfreezes the account, which must exist in the underlying database.
+func (d *freezingDatabase) UnfreezeAccount(ctx context.Context, id string) error {
+	ctx, unlock, err := d.Lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.DatabaseAdapter.GetAccountByID(ctx, id); err != nil {
+		return err
+	}
+	if err := d.store.Unfreeze(id); err != nil {
+		d.logger.Errorf("failed to unfreeze account '%s': %v", id, err)
+		return err
+	}
+	d.logger.Infof("account '%s' unfrozen", id)
+	return nil
+}
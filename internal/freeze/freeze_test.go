@@ -0,0 +1,176 @@
+package freeze
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type freezeSuite struct {
+	logger *zap.SugaredLogger
+	path   string
+	store  *Store
+	db     db.DatabaseAdapter
+	suite.Suite
+}
+
+func (s *freezeSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.path = filepath.Join(s.T().TempDir(), "frozen.json")
+
+	store, err := NewStore(s.logger, s.path)
+	s.Require().NoError(err)
+	s.store = store
+	s.db = NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), store)
+
+	ctx := context.Background()
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "1", IBAN: "ES1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "2", IBAN: "ES2", Owner: "Bob", Balance: 100, InitialBalance: 100}))
+}
+
+// TestFreeze tests rejecting the transactions and transfers of the frozen accounts.
+func (s *freezeSuite) TestFreeze() {
+	ctx := context.Background()
+	freezer := s.db.(db.Freezer)
+	s.Require().NoError(freezer.FreezeAccount(ctx, "1"))
+
+	s.Run("ok: read frozen account", func() {
+		acc, err := s.db.GetAccountByID(ctx, "1")
+		s.Require().NoError(err)
+		s.True(acc.Frozen)
+
+		acc, err = s.db.GetAccountByIBAN(ctx, "ES2")
+		s.Require().NoError(err)
+		s.False(acc.Frozen)
+
+		accounts, err := s.db.GetAllAccounts(ctx)
+		s.Require().NoError(err)
+		for _, acc := range accounts {
+			s.Equal(acc.ID == "1", acc.Frozen)
+		}
+	})
+
+	s.Run("error: transaction", func() {
+		err := s.db.CreateTransaction(ctx, &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Deposit, Amount: 10})
+		s.ErrorIs(err, errors.ErrAccountFrozen)
+	})
+
+	s.Run("error: transfer", func() {
+		withdrawal := &models.Transaction{ID: "tx2", AccountID: "2", Type: enum.Withdrawal, Amount: 10}
+		deposit := &models.Transaction{ID: "tx3", AccountID: "1", Type: enum.Deposit, Amount: 10}
+		s.ErrorIs(s.db.Transfer(ctx, withdrawal, deposit), errors.ErrAccountFrozen)
+
+		acc, err := s.db.GetAccountByID(ctx, "2")
+		s.Require().NoError(err)
+		s.Equal(100.0, acc.Balance)
+	})
+
+	s.Run("error: batch of transfers", func() {
+		err := s.db.TransferBatch(ctx, []models.Transfer{
+			{
+				Withdrawal: models.Transaction{ID: "tx5", AccountID: "2", Type: enum.Withdrawal, Amount: 10},
+				Deposit:    models.Transaction{ID: "tx6", AccountID: "2", Type: enum.Deposit, Amount: 10},
+			},
+			{
+				Withdrawal: models.Transaction{ID: "tx7", AccountID: "2", Type: enum.Withdrawal, Amount: 10},
+				Deposit:    models.Transaction{ID: "tx8", AccountID: "1", Type: enum.Deposit, Amount: 10},
+			},
+		})
+		s.ErrorIs(err, errors.ErrAccountFrozen)
+
+		txs, err := s.db.GetTransactionsByAccountID(ctx, "2")
+		s.Require().NoError(err)
+		s.Empty(txs)
+	})
+
+	s.Run("ok: unfreeze", func() {
+		s.Require().NoError(freezer.UnfreezeAccount(ctx, "1"))
+		err := s.db.CreateTransaction(ctx, &models.Transaction{ID: "tx4", AccountID: "1", Type: enum.Deposit, Amount: 10})
+		s.NoError(err)
+	})
+
+	s.Run("error: unknown account", func() {
+		s.ErrorIs(freezer.FreezeAccount(ctx, "unknown"), errors.ErrAccountNotFound)
+		s.False(s.store.IsFrozen("unknown"))
+	})
+}
+
+// blockingDatabase is a database adapter whose transactions wait until they are released.
+type blockingDatabase struct {
+	db.DatabaseAdapter
+	started  chan struct{}
+	released chan struct{}
+}
+
+// CreateTransaction waits until the transaction is released before creating it.
+func (d *blockingDatabase) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	d.started <- struct{}{}
+	<-d.released
+	return d.DatabaseAdapter.CreateTransaction(ctx, transaction)
+}
+
+// TestConcurrentFreeze tests that an account is not frozen while one of its mutations is in progress.
+func (s *freezeSuite) TestConcurrentFreeze() {
+	ctx := context.Background()
+	blocking := &blockingDatabase{DatabaseAdapter: memory.NewInMemoryDatabase(s.logger), started: make(chan struct{}), released: make(chan struct{})}
+	s.Require().NoError(blocking.CreateAccount(ctx, &models.Account{ID: "1", IBAN: "ES1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	database := NewDatabase(s.logger, blocking, s.store)
+
+	created := make(chan error)
+	go func() {
+		created <- database.CreateTransaction(ctx, &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Deposit, Amount: 10})
+	}()
+	<-blocking.started
+
+	frozen := make(chan error)
+	go func() {
+		frozen <- database.(db.Freezer).FreezeAccount(ctx, "1")
+	}()
+
+	s.Run("error: canceled while waiting", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		err := database.CreateTransaction(canceled, &models.Transaction{ID: "tx2", AccountID: "1", Type: enum.Deposit, Amount: 10})
+		s.Error(err)
+		s.NotErrorIs(err, errors.ErrAccountFrozen)
+	})
+
+	s.Run("ok: freeze waits for the mutation", func() {
+		select {
+		case err := <-frozen:
+			s.Failf("account frozen during a mutation", "error: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		s.False(s.store.IsFrozen("1"))
+
+		close(blocking.released)
+		s.NoError(<-created)
+		s.NoError(<-frozen)
+		s.True(s.store.IsFrozen("1"))
+	})
+}
+
+// TestPersistence tests that the frozen accounts stay frozen across restarts.
+func (s *freezeSuite) TestPersistence() {
+	s.Require().NoError(s.store.Freeze("1"))
+	s.Require().NoError(s.store.Freeze("2"))
+	s.Require().NoError(s.store.Unfreeze("2"))
+
+	reopened, err := NewStore(s.logger, s.path)
+	s.Require().NoError(err)
+	s.True(reopened.IsFrozen("1"))
+	s.False(reopened.IsFrozen("2"))
+}
+
+func TestFreeze(t *testing.T) {
+	suite.Run(t, new(freezeSuite))
+}
@@ -0,0 +1,108 @@
+// Package freeze lets the administrators freeze accounts: the transactions and transfers of a frozen account are
+// rejected until it is unfrozen, while the account can still be read.
+package freeze
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Store keeps the frozen accounts and the time they were frozen. If it has a file, it is rewritten after every change,
+// so that the accounts stay frozen across restarts.
+type Store struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+
+	path   string
+	frozen map[string]time.Time // time every frozen account was frozen, by account id
+}
+
+// NewStore creates a new store. If path is not empty, the frozen accounts stored in the file are loaded and every
+// change is written to it.
+func NewStore(logger *zap.SugaredLogger, path string) (*Store, error) {
+	s := &Store{logger: logger, path: path, frozen: make(map[string]time.Time)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frozen accounts file: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.frozen); err != nil {
+		return nil, fmt.Errorf("failed to decode frozen accounts file: %v", err)
+	}
+	logger.Infof("frozen accounts loaded: %d accounts", len(s.frozen))
+	return s, nil
+}
+
+// Freeze freezes the account. Freezing a frozen account keeps the time it was first frozen.
+func (s *Store) Freeze(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.frozen[id]; ok {
+		return nil
+	}
+	s.frozen[id] = time.Now().UTC()
+	if err := s.save(); err != nil {
+		delete(s.frozen, id)
+		return err
+	}
+	return nil
+}
+
+// Unfreeze unfreezes the account.
+func (s *Store) Unfreeze(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frozenAt, ok := s.frozen[id]
+	if !ok {
+		return nil
+	}
+	delete(s.frozen, id)
+	if err := s.save(); err != nil {
+		s.frozen[id] = frozenAt
+		return err
+	}
+	return nil
+}
+
+// IsFrozen checks whether the account is frozen.
+func (s *Store) IsFrozen(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.frozen[id]
+	return ok
+}
+
+// save writes the frozen accounts to the file of the store, if any. The file is replaced atomically, so that it is
+// never left half written. It must be called with the lock held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.frozen)
+	if err != nil {
+		return fmt.Errorf("failed to marshal frozen accounts: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write frozen accounts file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to store frozen accounts file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package iban
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	minLength = 15 // shortest IBAN in use (Norway)
+	maxLength = 34 // longest IBAN allowed by ISO 13616
+)
+
+// countryLengths holds the total IBAN length for the most common countries. Countries that are not
+// listed here are only checked against the generic ISO 13616 limits.
+var countryLengths = map[string]int{
+	"AT": 20, "BE": 16, "CH": 21, "DE": 22, "DK": 18, "ES": 24, "FI": 18, "FR": 27, "GB": 22,
+	"IE": 22, "IT": 27, "LU": 20, "NL": 18, "NO": 15, "PL": 28, "PT": 25, "SE": 24,
+}
+
+// Generator creates new IBANs for the configured country and bank code.
+type Generator struct {
+	countryCode string
+	bankCode    string
+	length      int
+}
+
+// NewGenerator creates a new IBAN generator. The country code must be an ISO 3166-1 alpha-2 code and
+// the bank code must be alphanumeric. The rest of the BBAN is filled with random digits.
+func NewGenerator(countryCode string, bankCode string) (*Generator, error) {
+	countryCode = strings.ToUpper(countryCode)
+	bankCode = strings.ToUpper(bankCode)
+
+	if len(countryCode) != 2 || !isLetters(countryCode) {
+		return nil, fmt.Errorf("invalid iban country code: %s", countryCode)
+	}
+	if bankCode == "" || !isAlphanumeric(bankCode) {
+		return nil, fmt.Errorf("invalid iban bank code: %s", bankCode)
+	}
+
+	length, ok := countryLengths[countryCode]
+	if !ok {
+		length = len(countryCode) + 2 + len(bankCode) + 12
+	}
+	if length > maxLength || length-4-len(bankCode) < 1 {
+		return nil, fmt.Errorf("iban bank code '%s' is too long for country %s", bankCode, countryCode)
+	}
+
+	return &Generator{countryCode: countryCode, bankCode: bankCode, length: length}, nil
+}
+
+// Generate creates a new random IBAN with valid check digits.
+func (g *Generator) Generate() (string, error) {
+	digits := g.length - 4 - len(g.bankCode)
+	account, err := randomDigits(digits)
+	if err != nil {
+		return "", err
+	}
+
+	bban := g.bankCode + account
+	return g.countryCode + checkDigits(g.countryCode, bban) + bban, nil
+}
+
+// Normalize removes the spaces used in the printed format of an IBAN and converts it to upper case.
+func Normalize(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(iban), " ", ""))
+}
+
+// Validate checks that the IBAN has a valid structure and that its check digits are correct
+// according to the ISO 13616 mod-97 algorithm.
+func Validate(iban string) error {
+	iban = Normalize(iban)
+
+	if len(iban) < minLength || len(iban) > maxLength {
+		return fmt.Errorf("invalid iban length: %d", len(iban))
+	}
+	if !isLetters(iban[:2]) {
+		return fmt.Errorf("invalid iban country code: %s", iban[:2])
+	}
+	if !isDigits(iban[2:4]) {
+		return fmt.Errorf("invalid iban check digits: %s", iban[2:4])
+	}
+	if !isAlphanumeric(iban[4:]) {
+		return fmt.Errorf("invalid iban characters")
+	}
+	if length, ok := countryLengths[iban[:2]]; ok && length != len(iban) {
+		return fmt.Errorf("invalid iban length for country %s: %d", iban[:2], len(iban))
+	}
+	if mod97(iban[4:]+iban[:4]) != 1 {
+		return fmt.Errorf("invalid iban checksum")
+	}
+
+	return nil
+}
+
+// checkDigits computes the two check digits of an IBAN given its country code and BBAN.
+func checkDigits(countryCode string, bban string) string {
+	return fmt.Sprintf("%02d", 98-mod97(bban+countryCode+"00"))
+}
+
+// mod97 computes the remainder of the division by 97 of the number obtained by replacing every
+// letter of s by two digits (A = 10, B = 11, ..., Z = 35). The computation is done piecewise so
+// that it never overflows.
+func mod97(s string) int {
+	remainder := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		}
+	}
+	return remainder
+}
+
+// randomDigits returns a string of n random digits.
+func randomDigits(n int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate iban digits: %v", err)
+		}
+		sb.WriteByte(byte('0' + d.Int64()))
+	}
+	return sb.String(), nil
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package iban
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ibanSuite struct {
+	suite.Suite
+}
+
+// TestValidate tests the validation of IBANs.
+func (s *ibanSuite) TestValidate() {
+	s.Run("ok", func() {
+		inputs := []string{
+			"GB82 WEST 1234 5698 7654 32",
+			"DE89370400440532013000",
+			"es9121000418450200051332",
+			"NO9386011117947",
+		}
+
+		for _, in := range inputs {
+			s.NoError(Validate(in), in)
+		}
+	})
+
+	s.Run("not ok", func() {
+		inputs := []string{
+			"",
+			"GB82WEST12345698765433",               // wrong checksum
+			"GB82WEST123456987654",                 // wrong length for country
+			"1282WEST12345698765432",               // invalid country code
+			"GBXXWEST12345698765432",               // invalid check digits
+			"GB82WEST1234569876543$",               // invalid characters
+			"DE8937040044053201300000000000000000", // too long
+		}
+
+		for _, in := range inputs {
+			s.Error(Validate(in), in)
+		}
+	})
+}
+
+// TestGenerate tests that the generated IBANs are valid.
+func (s *ibanSuite) TestGenerate() {
+	inputs := []struct {
+		country string
+		bank    string
+		length  int
+	}{
+		{country: "ES", bank: "01820001", length: 24},
+		{country: "de", bank: "37040044", length: 22},
+		{country: "XK", bank: "1212", length: 20},
+	}
+
+	for _, in := range inputs {
+		g, err := NewGenerator(in.country, in.bank)
+		s.Require().NoError(err)
+
+		for i := 0; i < 10; i++ {
+			iban, err := g.Generate()
+			s.Require().NoError(err)
+			s.Len(iban, in.length)
+			s.NoError(Validate(iban))
+		}
+	}
+
+	s.Run("not ok: invalid configuration", func() {
+		_, err := NewGenerator("E1", "0182")
+		s.Error(err)
+		_, err = NewGenerator("ES", "01-82")
+		s.Error(err)
+		_, err = NewGenerator("NO", "01820001000")
+		s.Error(err)
+	})
+}
+
+func TestIBANSuite(t *testing.T) {
+	suite.Run(t, new(ibanSuite))
+}
@@ -0,0 +1,110 @@
+package idempotency
+
+import (
+	errors "bank_test/internal/api_errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Response is a response recorded for an idempotency key, replayed to the retries of the request.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// entry is the state of an idempotency key. Its response is nil while the first request is being processed.
+type entry struct {
+	fingerprint string // identifies the request that used the key first
+	response    *Response
+	expires     time.Time
+}
+
+// expiry is an idempotency key in the order in which they expire.
+type expiry struct {
+	key     string
+	expires time.Time
+}
+
+// Store keeps the responses of the requests sent with an idempotency key in memory, so that retrying a request
+// returns the response of the first attempt instead of performing it again. Keys expire after the ttl of the store.
+type Store struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+
+	ttl      time.Duration
+	now      func() time.Time
+	entries  map[string]*entry
+	expiries []expiry // keys ordered by expiration, oldest first. Every key expires ttl after it is used first
+}
+
+// NewStore creates a new store that keeps the responses for ttl.
+func NewStore(logger *zap.SugaredLogger, ttl time.Duration) *Store {
+	return &Store{logger: logger, ttl: ttl, now: time.Now, entries: make(map[string]*entry)}
+}
+
+// Begin claims the key for the request identified by the fingerprint. It returns the recorded response if the key was
+// already used by the same request and it finished, and nil if the caller claimed the key and must process the
+// request, followed by Complete or Abort. Using a key for a different request, or while its first request is still
+// being processed, is an error.
+func (s *Store) Begin(key, fingerprint string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+
+	if e, ok := s.entries[key]; ok {
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, errors.ErrIdempotencyKeyReused
+		case e.response == nil:
+			return nil, errors.ErrIdempotencyKeyInUse
+		default:
+			return e.response, nil
+		}
+	}
+
+	expires := s.now().Add(s.ttl)
+	s.entries[key] = &entry{fingerprint: fingerprint, expires: expires}
+	s.expiries = append(s.expiries, expiry{key: key, expires: expires})
+	return nil, nil
+}
+
+// Complete records the response of the request that claimed the key.
+func (s *Store) Complete(key string, response *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.response = response
+	}
+}
+
+// Abort releases the key without recording a response, so that the request can be retried with it.
+func (s *Store) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// expire forgets the keys whose ttl has elapsed.
+func (s *Store) expire() {
+	now := s.now()
+	n := 0
+	for ; n < len(s.expiries) && !now.Before(s.expiries[n].expires); n++ {
+		key := s.expiries[n].key
+		// the key may have been aborted and claimed again, in which case it expires later
+		if e, ok := s.entries[key]; ok && e.expires.Equal(s.expiries[n].expires) {
+			delete(s.entries, key)
+		}
+	}
+	if n > 0 {
+		s.logger.Debugf("%d idempotency keys expired", n)
+		s.expiries = s.expiries[n:]
+	}
+}
@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	errors "bank_test/internal/api_errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type StoreTestSuite struct {
+	suite.Suite
+
+	now   time.Time
+	store *Store
+}
+
+func (s *StoreTestSuite) SetupTest() {
+	s.now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.store = NewStore(zap.NewExample().Sugar(), time.Hour)
+	s.store.now = func() time.Time { return s.now }
+}
+
+// TestBegin tests claiming keys and replaying their responses.
+func (s *StoreTestSuite) TestBegin() {
+	response := &Response{Status: http.StatusCreated, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{}`)}
+
+	s.Run("ok: new key", func() {
+		replay, err := s.store.Begin("key", "POST /accounts a")
+		s.Require().NoError(err)
+		s.Nil(replay)
+	})
+
+	s.Run("error: key in use", func() {
+		_, err := s.store.Begin("key", "POST /accounts a")
+		s.ErrorIs(err, errors.ErrIdempotencyKeyInUse)
+	})
+
+	s.Run("ok: replay", func() {
+		s.store.Complete("key", response)
+		replay, err := s.store.Begin("key", "POST /accounts a")
+		s.Require().NoError(err)
+		s.Equal(response, replay)
+	})
+
+	s.Run("error: key reused", func() {
+		_, err := s.store.Begin("key", "POST /accounts b")
+		s.ErrorIs(err, errors.ErrIdempotencyKeyReused)
+	})
+
+	s.Run("ok: aborted key", func() {
+		_, err := s.store.Begin("aborted", "POST /transfer a")
+		s.Require().NoError(err)
+		s.store.Abort("aborted")
+
+		replay, err := s.store.Begin("aborted", "POST /transfer b")
+		s.Require().NoError(err)
+		s.Nil(replay)
+	})
+
+	s.Run("ok: completed keys are not aborted", func() {
+		s.store.Abort("key")
+		replay, err := s.store.Begin("key", "POST /accounts a")
+		s.Require().NoError(err)
+		s.Equal(response, replay)
+	})
+}
+
+// TestExpire tests forgetting the keys once their ttl has elapsed.
+func (s *StoreTestSuite) TestExpire() {
+	_, err := s.store.Begin("old", "a")
+	s.Require().NoError(err)
+	s.store.Complete("old", &Response{Status: http.StatusOK})
+
+	s.now = s.now.Add(30 * time.Minute)
+	_, err = s.store.Begin("aborted", "a")
+	s.Require().NoError(err)
+	s.store.Abort("aborted")
+	_, err = s.store.Begin("new", "a")
+	s.Require().NoError(err)
+	s.store.Complete("new", &Response{Status: http.StatusOK})
+
+	s.Run("ok: keys are kept during the ttl", func() {
+		replay, err := s.store.Begin("old", "a")
+		s.Require().NoError(err)
+		s.NotNil(replay)
+	})
+
+	s.Run("ok: expired keys are forgotten", func() {
+		s.now = s.now.Add(31 * time.Minute)
+		replay, err := s.store.Begin("old", "b")
+		s.Require().NoError(err)
+		s.Nil(replay)
+
+		replay, err = s.store.Begin("new", "a")
+		s.Require().NoError(err)
+		s.NotNil(replay)
+	})
+
+	s.Run("ok: expiries are dropped", func() {
+		s.now = s.now.Add(2 * time.Hour)
+		_, err := s.store.Begin("other", "a")
+		s.Require().NoError(err)
+		s.Len(s.store.entries, 1)
+		s.Len(s.store.expiries, 1)
+	})
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
@@ -0,0 +1,131 @@
+package payments
+
+import (
+	"bank_test/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// claim is a message id in the order in which they expire.
+type claim struct {
+	messageID string
+	claimedAt time.Time
+}
+
+// MessageStore keeps the ids of the messages claimed for processing and the time they were claimed, so that a message
+// submitted twice is not executed twice. Ids are forgotten ttl after they are claimed, unless the ttl is zero. If the
+// store has a file, it is rewritten after every change, so that duplicates are detected across restarts.
+type MessageStore struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+
+	path    string
+	ttl     time.Duration
+	now     func() time.Time
+	claimed map[string]time.Time // time every message id was claimed
+	claims  []claim              // message ids ordered by the time they were claimed, oldest first
+}
+
+// NewMessageStore creates a new store that keeps the message ids for ttl. If path is not empty, the message ids stored
+// in the file are loaded and every change is written to it.
+func NewMessageStore(logger *zap.SugaredLogger, path string, ttl time.Duration) (*MessageStore, error) {
+	s := &MessageStore{logger: logger, path: path, ttl: ttl, now: func() time.Time { return time.Now().UTC() }, claimed: make(map[string]time.Time)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment messages file: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.claimed); err != nil {
+		return nil, fmt.Errorf("failed to decode payment messages file: %v", err)
+	}
+	for id, claimedAt := range s.claimed {
+		s.claims = append(s.claims, claim{messageID: id, claimedAt: claimedAt})
+	}
+	sort.Slice(s.claims, func(i, j int) bool { return s.claims[i].claimedAt.Before(s.claims[j].claimedAt) })
+	logger.Infof("payment messages loaded: %d message ids", len(s.claimed))
+	return s, nil
+}
+
+// Claim claims the message id for processing. It returns false if the id was already claimed.
+func (s *MessageStore) Claim(messageID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+
+	if _, ok := s.claimed[messageID]; ok {
+		return false, nil
+	}
+	claimedAt := s.now()
+	s.claimed[messageID] = claimedAt
+	if err := s.save(); err != nil {
+		delete(s.claimed, messageID)
+		return false, err
+	}
+	s.claims = append(s.claims, claim{messageID: messageID, claimedAt: claimedAt})
+	return true, nil
+}
+
+// Release releases the message id, so that the message can be submitted again.
+func (s *MessageStore) Release(messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimedAt, ok := s.claimed[messageID]
+	if !ok {
+		return nil
+	}
+	delete(s.claimed, messageID)
+	if err := s.save(); err != nil {
+		s.claimed[messageID] = claimedAt
+		return err
+	}
+	return nil
+}
+
+// expire forgets the message ids whose ttl has elapsed. They are written to the file with the next change. It must be
+// called with the lock held.
+func (s *MessageStore) expire() {
+	if s.ttl <= 0 {
+		return
+	}
+	now := s.now()
+	n := 0
+	for ; n < len(s.claims) && !now.Before(s.claims[n].claimedAt.Add(s.ttl)); n++ {
+		// the id may have been released and claimed again, in which case it expires later
+		if claimedAt, ok := s.claimed[s.claims[n].messageID]; ok && claimedAt.Equal(s.claims[n].claimedAt) {
+			delete(s.claimed, s.claims[n].messageID)
+		}
+	}
+	if n > 0 {
+		s.logger.Debugf("%d payment message ids expired", n)
+		s.claims = s.claims[n:]
+	}
+}
+
+// save writes the message ids to the file of the store, if any. It must be called with the lock held.
+func (s *MessageStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.claimed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment messages: %v", err)
+	}
+	if err := helpers.WriteFileSync(s.path, data); err != nil {
+		return fmt.Errorf("failed to store payment messages file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Package payments ingests the payment files submitted by corporate clients as ISO 20022 pain.001 customer credit
+// transfer initiations. Every credit transfer of a file is executed as a transfer between accounts of the bank, and
+// the outcome is reported as a pain.002 customer payment status report.
+package payments
+
+import (
+	errors "bank_test/internal/api_errors"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// namespacePrefix is the prefix of the namespaces of the ISO 20022 messages, followed by the name of the message.
+const namespacePrefix = "urn:iso:std:iso:20022:tech:xsd:"
+
+// pain001Name is the name of the pain.001 messages, followed by their version.
+const pain001Name = "pain.001.001."
+
+// Document is a pain.001 customer credit transfer initiation. Only the elements needed to execute the transfers are
+// read, so every version of the message is accepted.
+type Document struct {
+	XMLName     xml.Name             `xml:"Document"`
+	GroupHeader GroupHeader          `xml:"CstmrCdtTrfInitn>GrpHdr"`
+	Payments    []PaymentInstruction `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+// GroupHeader identifies the message and counts its credit transfers.
+type GroupHeader struct {
+	MessageID            string `xml:"MsgId"`
+	CreatedAt            string `xml:"CreDtTm"`
+	NumberOfTransactions string `xml:"NbOfTxs"`
+	ControlSum           string `xml:"CtrlSum"` // sum of the amounts of every credit transfer. Optional
+	InitiatingParty      string `xml:"InitgPty>Nm"`
+}
+
+// PaymentInstruction is a set of credit transfers from the same debtor account.
+type PaymentInstruction struct {
+	ID                   string           `xml:"PmtInfId"`
+	Method               string           `xml:"PmtMtd"`  // TRF for credit transfers
+	NumberOfTransactions string           `xml:"NbOfTxs"` // optional
+	ControlSum           string           `xml:"CtrlSum"` // optional
+	Debtor               string           `xml:"Dbtr>Nm"`
+	DebtorIBAN           string           `xml:"DbtrAcct>Id>IBAN"`
+	Transfers            []CreditTransfer `xml:"CdtTrfTxInf"`
+}
+
+// CreditTransfer is a payment to a creditor account.
+type CreditTransfer struct {
+	InstructionID string `xml:"PmtId>InstrId"`
+	EndToEndID    string `xml:"PmtId>EndToEndId"`
+	Amount        Amount `xml:"Amt>InstdAmt"`
+	Creditor      string `xml:"Cdtr>Nm"`
+	CreditorIBAN  string `xml:"CdtrAcct>Id>IBAN"`
+	Remittance    string `xml:"RmtInf>Ustrd"`
+}
+
+// Amount is an amount in a currency.
+type Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Parse reads a pain.001 document. Documents that are not well-formed pain.001 messages are rejected with
+// ErrInvalidPaymentDocument; the content of the message is validated when it is processed.
+func Parse(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, invalidDocument(fmt.Sprintf("malformed xml: %v", err))
+	}
+	if !strings.HasPrefix(doc.XMLName.Space, namespacePrefix+pain001Name) {
+		return nil, invalidDocument(fmt.Sprintf("unexpected namespace '%s'", doc.XMLName.Space))
+	}
+	if doc.GroupHeader.MessageID == "" && len(doc.Payments) == 0 {
+		return nil, invalidDocument("missing customer credit transfer initiation")
+	}
+	return &doc, nil
+}
+
+// MessageName returns the name and version of the message, such as pain.001.001.03.
+func (d *Document) MessageName() string {
+	return strings.TrimPrefix(d.XMLName.Space, namespacePrefix)
+}
+
+// invalidDocument returns ErrInvalidPaymentDocument with the reason.
+func invalidDocument(reason string) error {
+	e := *errors.ErrInvalidPaymentDocument
+	e.Message = fmt.Sprintf("%s: %s", e.Message, reason)
+	return &e
+}
@@ -0,0 +1,158 @@
+package payments
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// pain002Namespace is the namespace of the version of pain.002 written by the reports.
+const pain002Namespace = namespacePrefix + "pain.002.001.03"
+
+// Status is the status of a message, a payment instruction or a credit transfer.
+type Status string
+
+const (
+	// StatusCompleted is used for the credit transfers that were executed, and for the groups whose transfers were
+	// all executed (AcceptedSettlementCompleted).
+	StatusCompleted Status = "ACSC"
+
+	// StatusPartial is used for the groups with executed and rejected transfers (PartiallyAccepted).
+	StatusPartial Status = "PART"
+
+	// StatusRejected is used for the credit transfers that were not executed, and for the groups whose transfers were
+	// all rejected (Rejected).
+	StatusRejected Status = "RJCT"
+)
+
+// Reason codes of the rejections, from the ISO 20022 ExternalStatusReason1Code list.
+const (
+	ReasonInvalidDebtorAccount   = "AC02" // the debtor account is not a valid IBAN of the bank
+	ReasonInvalidCreditorAccount = "AC03" // the creditor account is not a valid IBAN of the bank
+	ReasonZeroAmount             = "AM01" // the amount is zero
+	ReasonNotAllowedAmount       = "AM02" // the amount is negative or not a number
+	ReasonNotAllowedCurrency     = "AM03" // the currency is not the one of the accounts
+	ReasonInsufficientFunds      = "AM04" // the debtor account does not cover the amount
+	ReasonDuplication            = "AM05" // the end-to-end id was already used in the message
+	ReasonInvalidControlSum      = "AM10" // the control sum does not match the amounts
+	ReasonInvalidNumberOfTxs     = "AM18" // the number of transactions does not match the credit transfers
+	ReasonInvalidFileFormat      = "FF01" // a mandatory element is missing or invalid
+	ReasonNarrative              = "NARR" // the reason is given in the additional information
+)
+
+// Reason is the reason of a rejection.
+type Reason struct {
+	Code string
+	Info string // additional information
+
+	retryable bool // the rejection is not caused by the message, which can be submitted again
+}
+
+// Report is a pain.002 customer payment status report of a pain.001 message.
+type Report struct {
+	MessageID string
+	CreatedAt time.Time
+
+	OriginalMessageID    string
+	OriginalMessageName  string
+	OriginalTransactions int
+	Status               Status
+	Reason               *Reason // reason of the rejection of the whole message, if any
+	Payments             []PaymentStatus
+}
+
+// PaymentStatus is the status of a payment instruction of the message.
+type PaymentStatus struct {
+	OriginalID   string
+	Status       Status
+	Reason       *Reason
+	Transactions []TransactionStatus
+}
+
+// TransactionStatus is the status of a credit transfer of the message.
+type TransactionStatus struct {
+	OriginalInstructionID string
+	OriginalEndToEndID    string
+	Status                Status
+	Reason                *Reason
+}
+
+type xmlReason struct {
+	Code string `xml:"Rsn>Cd"`
+	Info string `xml:"AddtlInf,omitempty"`
+}
+
+type xmlTransactionStatus struct {
+	OriginalInstructionID string     `xml:"OrgnlInstrId,omitempty"`
+	OriginalEndToEndID    string     `xml:"OrgnlEndToEndId,omitempty"`
+	Status                Status     `xml:"TxSts"`
+	Reason                *xmlReason `xml:"StsRsnInf,omitempty"`
+}
+
+type xmlPaymentStatus struct {
+	OriginalID   string                 `xml:"OrgnlPmtInfId"`
+	Status       Status                 `xml:"PmtInfSts"`
+	Reason       *xmlReason             `xml:"StsRsnInf,omitempty"`
+	Transactions []xmlTransactionStatus `xml:"TxInfAndSts"`
+}
+
+type xmlReport struct {
+	XMLName   xml.Name `xml:"Document"`
+	Namespace string   `xml:"xmlns,attr"`
+
+	MessageID string `xml:"CstmrPmtStsRpt>GrpHdr>MsgId"`
+	CreatedAt string `xml:"CstmrPmtStsRpt>GrpHdr>CreDtTm"`
+
+	OriginalMessageID    string             `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts>OrgnlMsgId"`
+	OriginalMessageName  string             `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts>OrgnlMsgNmId"`
+	OriginalTransactions string             `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts>OrgnlNbOfTxs"`
+	Status               Status             `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts>GrpSts"`
+	Reason               *xmlReason         `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts>StsRsnInf,omitempty"`
+	Payments             []xmlPaymentStatus `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+// WriteXML writes the report as a pain.002.001.03 document.
+func (r *Report) WriteXML(w io.Writer) error {
+	doc := xmlReport{
+		Namespace:            pain002Namespace,
+		MessageID:            r.MessageID,
+		CreatedAt:            r.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		OriginalMessageID:    r.OriginalMessageID,
+		OriginalMessageName:  r.OriginalMessageName,
+		OriginalTransactions: strconv.Itoa(r.OriginalTransactions),
+		Status:               r.Status,
+		Reason:               toXMLReason(r.Reason),
+	}
+	for _, p := range r.Payments {
+		payment := xmlPaymentStatus{OriginalID: p.OriginalID, Status: p.Status, Reason: toXMLReason(p.Reason)}
+		for _, t := range p.Transactions {
+			payment.Transactions = append(payment.Transactions, xmlTransactionStatus{
+				OriginalInstructionID: t.OriginalInstructionID,
+				OriginalEndToEndID:    t.OriginalEndToEndID,
+				Status:                t.Status,
+				Reason:                toXMLReason(t.Reason),
+			})
+		}
+		doc.Payments = append(doc.Payments, payment)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// toXMLReason converts the reason, if any.
+func toXMLReason(r *Reason) *xmlReason {
+	if r == nil {
+		return nil
+	}
+	return &xmlReason{Code: r.Code, Info: r.Info}
+}
@@ -0,0 +1,391 @@
+package payments
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// tolerance is the maximum difference allowed between a control sum and the sum of the amounts, to absorb the
+// rounding errors of the float arithmetic.
+const tolerance = 1e-6
+
+// Processor executes the credit transfers of the pain.001 messages.
+type Processor struct {
+	logger   *zap.SugaredLogger
+	as       service.AccountService
+	ts       service.TransactionService
+	currency string
+
+	// messages holds the ids of the messages already processed, so that a message submitted twice is not executed
+	// twice. A message id is claimed as soon as the header of the message is valid, before its transfers are executed,
+	// and released if the message is rejected before any of them is executed for a reason that is not its own.
+	messages *MessageStore
+
+	now   func() time.Time
+	newID func() string
+}
+
+// NewProcessor creates a new processor of the payment files of the accounts held in the currency, which records the
+// ids of the processed messages in the store.
+func NewProcessor(logger *zap.SugaredLogger, as service.AccountService, ts service.TransactionService, currency string, messages *MessageStore) *Processor {
+	return &Processor{
+		logger:   logger,
+		as:       as,
+		ts:       ts,
+		currency: currency,
+		messages: messages,
+		now:      func() time.Time { return time.Now().UTC() },
+		newID:    uuid.NewString,
+	}
+}
+
+// item is a credit transfer of the message that passed the validation.
+type item struct {
+	status   *TransactionStatus
+	debtor   string // iban of the debtor account
+	creditor string // iban of the creditor account
+	amount   float64
+}
+
+// Process validates the message and executes its credit transfers, from the debtor account of their payment
+// instruction to their creditor account. Both accounts must be held by the bank. A message whose id was already
+// processed is rejected as a duplicate, unless the previous submission was rejected before any of its credit transfers
+// was executed for a reason that is not its own, such as a timeout.
+//
+// In atomic mode, the credit transfers are executed with TransactionService.TransferBatch, so that either all of them
+// are executed or none: the message is rejected if any credit transfer is invalid or fails. In per-item mode, every
+// credit transfer is executed or rejected on its own with TransactionService.Transfer. The report gives the status of
+// every credit transfer and the reason of every rejection.
+func (p *Processor) Process(ctx context.Context, doc *Document, mode enum.ExecutionMode) *Report {
+	report := &Report{
+		MessageID:           p.newID(),
+		CreatedAt:           p.now(),
+		OriginalMessageID:   doc.GroupHeader.MessageID,
+		OriginalMessageName: doc.MessageName(),
+	}
+	for _, payment := range doc.Payments {
+		status := PaymentStatus{OriginalID: payment.ID}
+		for _, transfer := range payment.Transfers {
+			status.Transactions = append(status.Transactions, TransactionStatus{
+				OriginalInstructionID: transfer.InstructionID,
+				OriginalEndToEndID:    transfer.EndToEndID,
+			})
+		}
+		report.Payments = append(report.Payments, status)
+		report.OriginalTransactions += len(payment.Transfers)
+	}
+	p.logger.Infof("processing payment message '%s' with %d credit transfers in %s mode", report.OriginalMessageID, report.OriginalTransactions, mode)
+
+	reason := validateGroup(doc)
+	if reason == nil {
+		reason = p.claim(doc.GroupHeader.MessageID)
+	}
+	if reason != nil {
+		p.logger.Errorf("payment message '%s' rejected: %s", report.OriginalMessageID, reason.Info)
+		report.Reason = reason
+		reject(report, nil)
+		return report
+	}
+
+	items := p.validateItems(ctx, doc, report)
+	if mode == enum.AtomicExecution {
+		p.executeBatch(ctx, report, items)
+	} else {
+		p.executeItems(ctx, items)
+	}
+
+	summarize(report)
+	if report.Status == StatusRejected && retryable(report) {
+		p.logger.Warnf("payment message '%s' rejected for a reason that is not its own, releasing its id", report.OriginalMessageID)
+		if err := p.messages.Release(doc.GroupHeader.MessageID); err != nil {
+			p.logger.Errorf("failed to release the id of payment message '%s': %v", report.OriginalMessageID, err)
+		}
+	}
+	p.logger.Infof("payment message '%s' processed with status %s", report.OriginalMessageID, report.Status)
+	return report
+}
+
+// claim records the message id as processed. It returns the reason to reject the message if the id was already
+// processed, or could not be recorded.
+func (p *Processor) claim(messageID string) *Reason {
+	claimed, err := p.messages.Claim(messageID)
+	if err != nil {
+		p.logger.Errorf("failed to claim the id of payment message '%s': %v", messageID, err)
+		return &Reason{Code: ReasonNarrative, Info: "the message id could not be recorded", retryable: true}
+	}
+	if !claimed {
+		return &Reason{Code: ReasonDuplication, Info: fmt.Sprintf("the message id '%s' was already processed", messageID)}
+	}
+	return nil
+}
+
+// executeBatch executes the items in a single batch, so that either all of them are executed or none. The message is
+// rejected if any of its credit transfers is invalid or fails.
+func (p *Processor) executeBatch(ctx context.Context, report *Report, items []*item) {
+	if len(items) < report.OriginalTransactions {
+		report.Reason = &Reason{Code: ReasonNarrative, Info: "the message has invalid credit transfers"}
+		reject(report, &Reason{Code: ReasonNarrative, Info: "rejected with the rest of the message"})
+		return
+	}
+
+	transfers := make([]schemas.TransferRequest, 0, len(items))
+	for _, it := range items {
+		amount := it.amount
+		transfers = append(transfers, schemas.TransferRequest{FromAccountId: it.debtor, ToAccountId: it.creditor, Amount: &amount})
+	}
+	err := p.ts.TransferBatch(ctx, transfers)
+	if err == nil {
+		for _, it := range items {
+			it.status.Status = StatusCompleted
+		}
+		return
+	}
+
+	var batchErr *errors.BatchError
+	if stderrors.As(err, &batchErr) && batchErr.Index < len(items) {
+		it := items[batchErr.Index]
+		it.status.Status, it.status.Reason = StatusRejected, reasonFor(batchErr.Err)
+		p.logger.Errorf("credit transfer '%s' of payment message '%s' failed, rejecting the message: %v", it.status.OriginalEndToEndID, report.OriginalMessageID, batchErr.Err)
+		report.Reason = &Reason{Code: ReasonNarrative, Info: fmt.Sprintf("credit transfer '%s' failed", it.status.OriginalEndToEndID)}
+	} else {
+		p.logger.Errorf("payment message '%s' failed: %v", report.OriginalMessageID, err)
+		report.Reason = reasonFor(err)
+	}
+	reject(report, &Reason{Code: ReasonNarrative, Info: "rejected with the rest of the message"})
+}
+
+// executeItems executes every item on its own, rejecting the ones that fail.
+func (p *Processor) executeItems(ctx context.Context, items []*item) {
+	for _, it := range items {
+		if err := p.ts.Transfer(ctx, it.debtor, it.creditor, it.amount); err != nil {
+			it.status.Status, it.status.Reason = StatusRejected, reasonFor(err)
+			continue
+		}
+		it.status.Status = StatusCompleted
+	}
+}
+
+// validateGroup checks the header of the message and the totals of its payment instructions. It returns the reason to
+// reject the whole message, if any.
+func validateGroup(doc *Document) *Reason {
+	header := doc.GroupHeader
+	if header.MessageID == "" {
+		return &Reason{Code: ReasonInvalidFileFormat, Info: "missing message id"}
+	}
+	if len(doc.Payments) == 0 {
+		return &Reason{Code: ReasonInvalidFileFormat, Info: "missing payment instructions"}
+	}
+
+	count, sum := 0, 0.0
+	for _, payment := range doc.Payments {
+		if payment.ID == "" {
+			return &Reason{Code: ReasonInvalidFileFormat, Info: "missing payment instruction id"}
+		}
+		if payment.Method != "TRF" {
+			return &Reason{Code: ReasonInvalidFileFormat, Info: fmt.Sprintf("payment instruction '%s' has payment method '%s', expected TRF", payment.ID, payment.Method)}
+		}
+		if len(payment.Transfers) == 0 {
+			return &Reason{Code: ReasonInvalidFileFormat, Info: fmt.Sprintf("payment instruction '%s' has no credit transfers", payment.ID)}
+		}
+
+		paymentSum := 0.0
+		for _, transfer := range payment.Transfers {
+			amount, _ := strconv.ParseFloat(strings.TrimSpace(transfer.Amount.Value), 64)
+			paymentSum += amount
+		}
+		if reason := checkTotals(payment.NumberOfTransactions, payment.ControlSum, len(payment.Transfers), paymentSum, "payment instruction '"+payment.ID+"'"); reason != nil {
+			return reason
+		}
+		count += len(payment.Transfers)
+		sum += paymentSum
+	}
+
+	if header.NumberOfTransactions == "" {
+		return &Reason{Code: ReasonInvalidFileFormat, Info: "missing number of transactions"}
+	}
+	return checkTotals(header.NumberOfTransactions, header.ControlSum, count, sum, "message")
+}
+
+// checkTotals checks the number of transactions and the control sum declared by a group, if they are set.
+func checkTotals(declaredCount, declaredSum string, count int, sum float64, group string) *Reason {
+	if declaredCount != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(declaredCount)); err != nil || n != count {
+			return &Reason{Code: ReasonInvalidNumberOfTxs, Info: fmt.Sprintf("the %s declares %s transactions, but has %d", group, declaredCount, count)}
+		}
+	}
+	if declaredSum != "" {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(declaredSum), 64); err != nil || math.Abs(v-sum) > tolerance {
+			return &Reason{Code: ReasonInvalidControlSum, Info: fmt.Sprintf("the %s declares a control sum of %s, but its amounts add up to %s", group, declaredSum, strconv.FormatFloat(sum, 'f', -1, 64))}
+		}
+	}
+	return nil
+}
+
+// validateItems checks every credit transfer of the message, rejecting the invalid ones in the report. It returns the
+// valid ones, in the order of the message. The accounts of other owners than the customer that submitted the message
+// are held by the bank: they can be credited, and the transfers debiting them are rejected when they are executed.
+func (p *Processor) validateItems(ctx context.Context, doc *Document, report *Report) []*item {
+	accounts := make(map[string]*Reason) // reason to reject every account of the message, nil if it is valid
+	checkAccount := func(number, code string) (string, *Reason) {
+		number = iban.Normalize(number)
+		reason, ok := accounts[number]
+		if !ok {
+			if err := iban.Validate(number); err != nil {
+				reason = &Reason{Code: code, Info: fmt.Sprintf("invalid iban '%s'", number)}
+			} else if _, err := p.as.GetAccountByIBAN(ctx, number); isRetryable(err) {
+				reason = reasonFor(err)
+			} else if err != nil && !stderrors.Is(err, errors.ErrAccountAccessDenied) {
+				reason = &Reason{Code: code, Info: fmt.Sprintf("account '%s' is not held by the bank", number)}
+			}
+			accounts[number] = reason
+		}
+		return number, reason
+	}
+
+	items := make([]*item, 0)
+	endToEndIDs := make(map[string]bool)
+	for i, payment := range doc.Payments {
+		debtor, debtorReason := checkAccount(payment.DebtorIBAN, ReasonInvalidDebtorAccount)
+		for j, transfer := range payment.Transfers {
+			status := &report.Payments[i].Transactions[j]
+			creditor, creditorReason := checkAccount(transfer.CreditorIBAN, ReasonInvalidCreditorAccount)
+			amount, amountErr := strconv.ParseFloat(strings.TrimSpace(transfer.Amount.Value), 64)
+
+			var reason *Reason
+			switch {
+			case transfer.EndToEndID == "":
+				reason = &Reason{Code: ReasonInvalidFileFormat, Info: "missing end-to-end id"}
+			case endToEndIDs[transfer.EndToEndID]:
+				reason = &Reason{Code: ReasonDuplication, Info: fmt.Sprintf("duplicated end-to-end id '%s'", transfer.EndToEndID)}
+			case amountErr != nil || amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0):
+				reason = &Reason{Code: ReasonNotAllowedAmount, Info: fmt.Sprintf("invalid amount '%s'", transfer.Amount.Value)}
+			case amount == 0:
+				reason = &Reason{Code: ReasonZeroAmount, Info: "the amount is zero"}
+			case !strings.EqualFold(transfer.Amount.Currency, p.currency):
+				reason = &Reason{Code: ReasonNotAllowedCurrency, Info: fmt.Sprintf("currency '%s' is not allowed, expected %s", transfer.Amount.Currency, p.currency)}
+			case debtorReason != nil:
+				reason = debtorReason
+			case creditorReason != nil:
+				reason = creditorReason
+			case debtor == creditor:
+				reason = &Reason{Code: ReasonInvalidCreditorAccount, Info: "the creditor account is the debtor account"}
+			}
+			if transfer.EndToEndID != "" {
+				endToEndIDs[transfer.EndToEndID] = true
+			}
+
+			if reason != nil {
+				status.Status, status.Reason = StatusRejected, reason
+				continue
+			}
+			items = append(items, &item{status: status, debtor: debtor, creditor: creditor, amount: amount})
+		}
+	}
+	return items
+}
+
+// reject rejects every credit transfer of the report that has no status yet, with the reason.
+func reject(report *Report, reason *Reason) {
+	for i := range report.Payments {
+		for j := range report.Payments[i].Transactions {
+			if status := &report.Payments[i].Transactions[j]; status.Status == "" {
+				status.Status, status.Reason = StatusRejected, reason
+			}
+		}
+	}
+	summarize(report)
+}
+
+// summarize sets the status of the payment instructions and of the message from the status of their credit transfers.
+func summarize(report *Report) {
+	statuses := make([]Status, 0, len(report.Payments))
+	for i := range report.Payments {
+		payment := &report.Payments[i]
+		transactions := make([]Status, 0, len(payment.Transactions))
+		for _, t := range payment.Transactions {
+			transactions = append(transactions, t.Status)
+		}
+		payment.Status = combine(transactions)
+		statuses = append(statuses, payment.Status)
+	}
+	report.Status = combine(statuses)
+}
+
+// combine returns the status of a group from the status of its members.
+func combine(statuses []Status) Status {
+	rejected := 0
+	for _, s := range statuses {
+		switch s {
+		case StatusCompleted:
+		case StatusRejected:
+			rejected++
+		default:
+			return StatusPartial
+		}
+	}
+	switch rejected {
+	case 0:
+		return StatusCompleted
+	case len(statuses):
+		return StatusRejected
+	default:
+		return StatusPartial
+	}
+}
+
+// retryable checks whether a rejection of the report is not caused by the message, so that it can be submitted again.
+func retryable(report *Report) bool {
+	if report.Reason != nil && report.Reason.retryable {
+		return true
+	}
+	for _, payment := range report.Payments {
+		for _, t := range payment.Transactions {
+			if t.Reason != nil && t.Reason.retryable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isRetryable checks whether the error is not caused by the request, which can be retried: the request timed out or
+// was canceled, or failed unexpectedly.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiError *errors.APIError
+	return stderrors.Is(err, errors.ErrTimeout) || stderrors.Is(err, errors.ErrRequestCanceled) ||
+		stderrors.Is(err, context.DeadlineExceeded) || stderrors.Is(err, context.Canceled) || !stderrors.As(err, &apiError) ||
+		apiError.HTTPStatus >= http.StatusInternalServerError
+}
+
+// reasonFor returns the reason of a failed transfer.
+func reasonFor(err error) *Reason {
+	switch {
+	case stderrors.Is(err, errors.ErrInsufficientBalance):
+		return &Reason{Code: ReasonInsufficientFunds, Info: "insufficient balance"}
+	case stderrors.Is(err, errors.ErrAccountNotFound):
+		return &Reason{Code: ReasonInvalidCreditorAccount, Info: "account not found"}
+	default:
+		var apiError *errors.APIError
+		if stderrors.As(err, &apiError) {
+			return &Reason{Code: ReasonNarrative, Info: apiError.Message, retryable: isRetryable(err)}
+		}
+		return &Reason{Code: ReasonNarrative, Info: err.Error(), retryable: true}
+	}
+}
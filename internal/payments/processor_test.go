@@ -0,0 +1,338 @@
+package payments
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "bank_test/internal/api_errors"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// update rewrites the golden files with the output of the tests: go test ./internal/payments -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// IBANs of the accounts of the tests, which are the ones used in testdata/pain001.xml.
+const (
+	alice = "ES7201820001000000000001"
+	bob   = "ES4501820001000000000002"
+	carol = "ES1801820001000000000003"
+)
+
+// Define the test suite
+type PaymentsTestSuite struct {
+	suite.Suite
+	db        db.DatabaseAdapter
+	as        service.AccountService
+	messages  *MessageStore
+	processor *Processor
+}
+
+func (s *PaymentsTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+
+	s.db = memory.NewInMemoryDatabase(logger)
+	s.as = service.NewAccountService(logger, s.db, ibans)
+	s.messages, err = NewMessageStore(logger, "", 0)
+	s.Require().NoError(err)
+	s.processor = NewProcessor(logger, s.as, service.NewTransactionService(logger, s.db), "EUR", s.messages)
+	s.processor.now = func() time.Time { return time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC) }
+	s.processor.newID = func() string { return "9f2d7c1e-3b4a-4c5d-8e6f-7a8b9c0d1e2f" }
+}
+
+// createAccounts creates the accounts of the tests, with the balance of alice.
+func (s *PaymentsTestSuite) createAccounts(balance float64) {
+	for number, b := range map[string]float64{alice: balance, bob: 10, carol: 0} {
+		s.Require().NoError(s.db.CreateAccount(context.Background(), &models.Account{
+			ID:             uuid.NewString(),
+			IBAN:           number,
+			Owner:          number,
+			Balance:        b,
+			InitialBalance: b,
+		}))
+	}
+}
+
+// assertBalances checks the balances of alice, bob and carol.
+func (s *PaymentsTestSuite) assertBalances(expected ...float64) {
+	for i, number := range []string{alice, bob, carol} {
+		acc, err := s.as.GetAccountByIBAN(context.Background(), number)
+		s.Require().NoError(err)
+		s.InDelta(expected[i], acc.Balance, 1e-9, number)
+	}
+}
+
+// parse reads testdata/pain001.xml, after applying the replacements to it.
+func (s *PaymentsTestSuite) parse(replacements ...string) *Document {
+	content, err := os.ReadFile(filepath.Join("testdata", "pain001.xml"))
+	s.Require().NoError(err)
+	doc, err := Parse(strings.NewReader(strings.NewReplacer(replacements...).Replace(string(content))))
+	s.Require().NoError(err)
+	return doc
+}
+
+// assertGolden compares the report with the golden file, or rewrites the file if -update is set.
+func (s *PaymentsTestSuite) assertGolden(name string, report *Report) {
+	var buf bytes.Buffer
+	s.Require().NoError(report.WriteXML(&buf))
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		s.Require().NoError(os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	s.Require().NoError(err)
+	s.Equal(string(expected), buf.String())
+}
+
+// statuses returns the status and reason code of every credit transfer of the report, in the order of the message.
+func statuses(report *Report) []string {
+	result := make([]string, 0)
+	for _, p := range report.Payments {
+		for _, t := range p.Transactions {
+			status := string(t.Status)
+			if t.Reason != nil {
+				status += " " + t.Reason.Code
+			}
+			result = append(result, status)
+		}
+	}
+	return result
+}
+
+// TestParse tests reading pain.001 documents.
+func (s *PaymentsTestSuite) TestParse() {
+	s.Run("ok", func() {
+		doc := s.parse()
+		s.Equal("MSG-2026-0001", doc.GroupHeader.MessageID)
+		s.Equal("pain.001.001.03", doc.MessageName())
+		s.Require().Len(doc.Payments, 2)
+		s.Equal("ES72 0182 0001 0000 0000 0001", doc.Payments[0].DebtorIBAN)
+		s.Require().Len(doc.Payments[0].Transfers, 2)
+		s.Equal(Amount{Currency: "EUR", Value: "30.50"}, doc.Payments[0].Transfers[0].Amount)
+		s.Equal(bob, doc.Payments[0].Transfers[0].CreditorIBAN)
+	})
+
+	s.Run("ok: other version", func() {
+		doc := s.parse("pain.001.001.03", "pain.001.001.09")
+		s.Equal("pain.001.001.09", doc.MessageName())
+	})
+
+	for name, content := range map[string]string{
+		"malformed xml":   "<Document><CstmrCdtTrfInitn>",
+		"wrong namespace": `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt/></Document>`,
+		"empty message":   `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn/></Document>`,
+	} {
+		s.Run("error: "+name, func() {
+			_, err := Parse(strings.NewReader(content))
+			s.True(errors.Is(err, apierrors.ErrInvalidPaymentDocument))
+		})
+	}
+}
+
+// TestProcess tests executing the credit transfers of a message.
+func (s *PaymentsTestSuite) TestProcess() {
+	s.Run("ok: atomic", func() {
+		s.SetupTest()
+		s.createAccounts(100)
+
+		report := s.processor.Process(context.Background(), s.parse(), enum.AtomicExecution)
+		s.Equal(StatusCompleted, report.Status)
+		s.assertGolden("pain002.xml", report)
+		s.assertBalances(19.5, 20.5, 70)
+	})
+
+	s.Run("ok: per item", func() {
+		s.SetupTest()
+		s.createAccounts(60)
+
+		report := s.processor.Process(context.Background(), s.parse(), enum.PerItemExecution)
+		s.Equal(StatusPartial, report.Status)
+		s.assertGolden("pain002.partial.xml", report)
+		s.assertBalances(29.5, 20.5, 20)
+	})
+
+	s.Run("ok: per item with invalid transfers", func() {
+		s.SetupTest()
+		s.createAccounts(100)
+
+		doc := s.parse(`<InstdAmt Ccy="EUR">50</InstdAmt>`, `<InstdAmt Ccy="USD">50</InstdAmt>`, "<EndToEndId>E2E-3", "<EndToEndId>E2E-1")
+		report := s.processor.Process(context.Background(), doc, enum.PerItemExecution)
+		s.Equal(StatusPartial, report.Status)
+		s.Equal([]string{"ACSC", "RJCT AM03", "RJCT AM05"}, statuses(report))
+		s.assertBalances(69.5, 40.5, 0)
+	})
+
+	s.Run("error: atomic with a failed transfer", func() {
+		s.SetupTest()
+		s.createAccounts(60)
+
+		report := s.processor.Process(context.Background(), s.parse(), enum.AtomicExecution)
+		s.Equal(StatusRejected, report.Status)
+		s.Require().NotNil(report.Reason)
+		s.Equal([]string{"RJCT NARR", "RJCT AM04", "RJCT NARR"}, statuses(report))
+		s.assertBalances(60, 10, 0)
+	})
+
+	s.Run("error: atomic with an invalid transfer", func() {
+		s.SetupTest()
+		s.createAccounts(100)
+
+		report := s.processor.Process(context.Background(), s.parse("ES1801820001000000000003", "ES0000000000000000000000"), enum.AtomicExecution)
+		s.Equal(StatusRejected, report.Status)
+		s.Equal([]string{"RJCT NARR", "RJCT AC03", "RJCT AC03"}, statuses(report))
+		s.assertBalances(100, 10, 0)
+	})
+
+	s.Run("error: duplicate message", func() {
+		s.SetupTest()
+		s.createAccounts(200)
+
+		s.Equal(StatusCompleted, s.processor.Process(context.Background(), s.parse(), enum.AtomicExecution).Status)
+		report := s.processor.Process(context.Background(), s.parse(), enum.PerItemExecution)
+		s.Equal(StatusRejected, report.Status)
+		s.Require().NotNil(report.Reason)
+		s.Equal(ReasonDuplication, report.Reason.Code)
+		s.assertBalances(119.5, 20.5, 70)
+	})
+
+	s.Run("ok: message rejected for a reason that is not its own submitted again", func() {
+		s.SetupTest()
+		s.createAccounts(100)
+
+		for _, mode := range []enum.ExecutionMode{enum.AtomicExecution, enum.PerItemExecution} {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			report := s.processor.Process(ctx, s.parse(), mode)
+			s.Equal(StatusRejected, report.Status, mode)
+			s.False(report.Reason != nil && report.Reason.Code == ReasonDuplication, mode)
+			s.assertBalances(100, 10, 0)
+		}
+
+		report := s.processor.Process(context.Background(), s.parse(), enum.AtomicExecution)
+		s.Equal(StatusCompleted, report.Status)
+		s.assertBalances(19.5, 20.5, 70)
+	})
+
+	s.Run("error: invalid transfers", func() {
+		for name, data := range map[string]struct {
+			replacements []string
+			expected     string
+		}{
+			"zero amount":           {[]string{"<InstdAmt Ccy=\"EUR\">50<", "<InstdAmt Ccy=\"EUR\">0<", "100.50", "50.50", "80.50", "30.50"}, "RJCT AM01"},
+			"negative amount":       {[]string{"<InstdAmt Ccy=\"EUR\">50<", "<InstdAmt Ccy=\"EUR\">-50<", "<CtrlSum>100.50</CtrlSum>", "", "<CtrlSum>80.50</CtrlSum>", ""}, "RJCT AM02"},
+			"unknown debtor":        {[]string{"ES72 0182 0001 0000 0000 0001", "ES9101820001690123456789"}, "RJCT AC02"},
+			"same account":          {[]string{"ES1801820001000000000003", alice}, "RJCT AC03"},
+			"missing end-to-end id": {[]string{"<EndToEndId>E2E-2</EndToEndId>", ""}, "RJCT FF01"},
+		} {
+			s.Run(name, func() {
+				s.SetupTest()
+				s.createAccounts(100)
+
+				report := s.processor.Process(context.Background(), s.parse(data.replacements...), enum.PerItemExecution)
+				s.Equal(data.expected, statuses(report)[1])
+			})
+		}
+	})
+
+	s.Run("error: invalid message", func() {
+		for name, data := range map[string]struct {
+			replacements []string
+			expected     string
+		}{
+			"number of transactions": {[]string{"<NbOfTxs>3</NbOfTxs>", "<NbOfTxs>4</NbOfTxs>"}, ReasonInvalidNumberOfTxs},
+			"control sum":            {[]string{"<CtrlSum>100.50</CtrlSum>", "<CtrlSum>100.51</CtrlSum>"}, ReasonInvalidControlSum},
+			"payment instruction number of transactions": {[]string{"<NbOfTxs>2</NbOfTxs>", "<NbOfTxs>1</NbOfTxs>"}, ReasonInvalidNumberOfTxs},
+			"payment instruction control sum":            {[]string{"<CtrlSum>80.50</CtrlSum>", "<CtrlSum>80</CtrlSum>"}, ReasonInvalidControlSum},
+			"payment method":                             {[]string{"<PmtMtd>TRF</PmtMtd>", "<PmtMtd>CHK</PmtMtd>"}, ReasonInvalidFileFormat},
+			"missing message id":                         {[]string{"<MsgId>MSG-2026-0001</MsgId>", ""}, ReasonInvalidFileFormat},
+		} {
+			s.Run(name, func() {
+				s.SetupTest()
+				s.createAccounts(100)
+
+				report := s.processor.Process(context.Background(), s.parse(data.replacements...), enum.PerItemExecution)
+				s.Equal(StatusRejected, report.Status)
+				s.Require().NotNil(report.Reason)
+				s.Equal(data.expected, report.Reason.Code)
+				s.assertBalances(100, 10, 0)
+			})
+		}
+	})
+}
+
+// TestMessages tests keeping the ids of the processed messages.
+func (s *PaymentsTestSuite) TestMessages() {
+	logger := zap.NewNop().Sugar()
+	path := filepath.Join(s.T().TempDir(), "messages.json")
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	s.Run("ok: claims survive restarts", func() {
+		messages, err := NewMessageStore(logger, path, 0)
+		s.Require().NoError(err)
+		for _, id := range []string{"MSG-1", "MSG-2"} {
+			claimed, err := messages.Claim(id)
+			s.Require().NoError(err)
+			s.True(claimed)
+		}
+		s.Require().NoError(messages.Release("MSG-2"))
+
+		messages, err = NewMessageStore(logger, path, 0)
+		s.Require().NoError(err)
+		claimed, err := messages.Claim("MSG-1")
+		s.Require().NoError(err)
+		s.False(claimed)
+		claimed, err = messages.Claim("MSG-2")
+		s.Require().NoError(err)
+		s.True(claimed)
+	})
+
+	s.Run("ok: claims expire", func() {
+		messages, err := NewMessageStore(logger, "", time.Hour)
+		s.Require().NoError(err)
+		messages.now = func() time.Time { return now }
+		claimed, err := messages.Claim("MSG-1")
+		s.Require().NoError(err)
+		s.True(claimed)
+
+		messages.now = func() time.Time { return now.Add(59 * time.Minute) }
+		claimed, err = messages.Claim("MSG-1")
+		s.Require().NoError(err)
+		s.False(claimed)
+
+		messages.now = func() time.Time { return now.Add(time.Hour) }
+		claimed, err = messages.Claim("MSG-1")
+		s.Require().NoError(err)
+		s.True(claimed)
+		s.Len(messages.claimed, 1)
+	})
+
+	s.Run("error: invalid file", func() {
+		s.Require().NoError(os.WriteFile(path, []byte("{"), 0o600))
+		_, err := NewMessageStore(logger, path, 0)
+		s.Error(err)
+	})
+}
+
+// TestProcessSuite runs the test suite.
+func TestProcessSuite(t *testing.T) {
+	suite.Run(t, new(PaymentsTestSuite))
+}
@@ -0,0 +1,414 @@
+package reconciliation
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	stderrors "errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// tolerance is the maximum difference allowed between two balances to consider them equal. It absorbs
+	// the rounding errors introduced by the float arithmetic used to store the balances.
+	tolerance = 1e-6
+
+	// maxReadAttempts is the number of times an account is read while looking for a consistent view of
+	// its balance and transactions when it is being modified concurrently.
+	maxReadAttempts = 3
+
+	// maxReports is the number of reports kept in memory. The oldest report is evicted when a new one is stored.
+	maxReports = 100
+)
+
+// errAccountChanged is returned when an account keeps changing on every attempt to read it.
+var errAccountChanged = stderrors.New("account changed during reconciliation")
+
+// Trigger defines what started a reconciliation.
+type Trigger string
+
+const (
+	// Scheduled is used for the reconciliations run periodically by the job.
+	Scheduled Trigger = "scheduled"
+
+	// Manual is used for the reconciliations requested on demand.
+	Manual Trigger = "manual"
+)
+
+// Discrepancy describes an account whose stored balance does not match the balance derived from its ledger.
+type Discrepancy struct {
+	AccountID      string  `json:"account_id"`
+	StoredBalance  float64 `json:"stored_balance"`  // balance stored in the account
+	DerivedBalance float64 `json:"derived_balance"` // initial balance plus the stored transactions
+	Difference     float64 `json:"difference"`      // stored balance minus derived balance
+}
+
+// UnbalancedTransfer describes a transfer whose legs do not net to zero: one of them is missing, duplicated or of a
+// different amount.
+type UnbalancedTransfer struct {
+	TransferID string  `json:"transfer_id"`
+	Legs       int     `json:"legs"`      // number of stored legs, two for a balanced transfer
+	Withdrawn  float64 `json:"withdrawn"` // sum of the withdrawal legs
+	Deposited  float64 `json:"deposited"` // sum of the deposit legs
+
+	skipped bool // a leg belongs to a skipped account, so the transfer is not excluded from the books
+}
+
+// Totals compares the global sum of movements against the bank's books. Balanced transfers only move money between
+// accounts, so the books are derived from the other movements, which move money into and out of the bank,
+// independently of the balances derived for every account.
+type Totals struct {
+	InitialBalances     float64 `json:"initial_balances"`     // sum of the balances when the accounts were opened
+	Deposits            float64 `json:"deposits"`             // sum of all deposits
+	Withdrawals         float64 `json:"withdrawals"`          // sum of all withdrawals
+	TransferDeposits    float64 `json:"transfer_deposits"`    // sum of the deposit legs of the balanced transfers
+	TransferWithdrawals float64 `json:"transfer_withdrawals"` // sum of the withdrawal legs of the balanced transfers
+	DerivedBooks        float64 `json:"derived_books"`        // initial balances plus the deposits minus the withdrawals that are not legs of balanced transfers
+	StoredBooks         float64 `json:"stored_books"`         // sum of the stored balances of every account
+	Difference          float64 `json:"difference"`           // stored books minus derived books
+}
+
+// Report is the result of a reconciliation.
+type Report struct {
+	ID                  string        `json:"id"`
+	Trigger             Trigger       `json:"trigger"`
+	StartedAt           time.Time     `json:"started_at"`
+	FinishedAt          time.Time     `json:"finished_at"`
+	AccountsChecked     int           `json:"accounts_checked"`
+	TransactionsChecked int           `json:"transactions_checked"`
+	TransfersChecked    int           `json:"transfers_checked"`
+	Balanced            bool          `json:"balanced"`        // true when no discrepancies were found
+	Error               string        `json:"error,omitempty"` // set when the ledger could not be read
+	Totals              Totals        `json:"totals"`
+	Discrepancies       []Discrepancy `json:"discrepancies"`
+
+	UnbalancedTransfers []UnbalancedTransfer `json:"unbalanced_transfers"` // transfers whose legs do not net to zero
+	SkippedAccounts     []string             `json:"skipped_accounts"`     // accounts that kept changing while being read, which are not checked
+}
+
+// Reconciler checks that the balance stored in every account matches its initial balance plus the
+// transactions stored in the database, that the legs of every transfer net to zero and that the bank's books match
+// the movements into and out of the bank. The latest reports are kept in memory and can be retrieved by their id.
+type Reconciler struct {
+	logger *zap.SugaredLogger
+	db     db.DatabaseAdapter
+
+	mu      sync.RWMutex
+	reports map[string]*Report
+	order   []string // ids of the reports, from the oldest to the newest
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewReconciler creates a new reconciler for the given database.
+func NewReconciler(logger *zap.SugaredLogger, db db.DatabaseAdapter) *Reconciler {
+	return &Reconciler{
+		logger:  logger,
+		db:      db,
+		reports: make(map[string]*Report),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs a reconciliation every interval in the background until Stop is called. A zero or negative
+// interval disables the scheduled job.
+func (r *Reconciler) Start(interval time.Duration) {
+	if interval <= 0 {
+		r.logger.Infof("scheduled reconciliation is disabled")
+		return
+	}
+
+	r.logger.Infof("scheduling reconciliation every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Reconcile(context.Background(), Scheduled)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduled job.
+func (r *Reconciler) Stop() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+// Reconcile compares the stored and derived balances of every account, checks the transfers and the books and stores
+// the resulting report. If the context is done before every account is checked, the report is stored with an error.
+func (r *Reconciler) Reconcile(ctx context.Context, trigger Trigger) *Report {
+	r.logger.Infof("starting %s reconciliation", trigger)
+
+	report := &Report{
+		ID:                  uuid.NewString(),
+		Trigger:             trigger,
+		StartedAt:           time.Now(),
+		Discrepancies:       make([]Discrepancy, 0),
+		UnbalancedTransfers: make([]UnbalancedTransfer, 0),
+		SkippedAccounts:     make([]string, 0),
+	}
+	transfers := newTransferLegs()
+
+	accounts, err := r.db.GetAllAccounts(ctx)
+	if err != nil {
+		r.logger.Errorf("failed to list accounts during reconciliation: %v", err)
+		report.Error = err.Error()
+		accounts = nil
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+
+	for _, acc := range accounts {
+		stored, txs, err := r.readAccount(ctx, acc.ID)
+		if ctx.Err() != nil {
+			r.logger.Errorf("reconciliation interrupted: %v", ctx.Err())
+			report.Error = ctx.Err().Error()
+			break
+		}
+		if stderrors.Is(err, errAccountChanged) {
+			// its balance cannot be compared to its transactions, but the legs of its transfers are still matched
+			r.logger.Warnf("account '%s' skipped by the reconciliation: %v", acc.ID, err)
+			report.SkippedAccounts = append(report.SkippedAccounts, acc.ID)
+			transfers.add(txs, false, true)
+			continue
+		}
+		if err != nil {
+			// the account was listed but cannot be read anymore, which means that the books are broken
+			r.logger.Errorf("failed to read account '%s' during reconciliation: %v", acc.ID, err)
+			stored, txs = &acc, nil
+		}
+
+		deposits, withdrawals := sumTransactions(txs)
+		derived := stored.InitialBalance + deposits - withdrawals
+		transfers.add(txs, false, false)
+
+		report.AccountsChecked++
+		report.TransactionsChecked += len(txs)
+		report.Totals.InitialBalances += stored.InitialBalance
+		report.Totals.Deposits += deposits
+		report.Totals.Withdrawals += withdrawals
+		report.Totals.StoredBooks += stored.Balance
+
+		if !equal(stored.Balance, derived) {
+			r.logger.Warnf("reconciliation discrepancy in account '%s': stored %f, derived %f", stored.ID, stored.Balance, derived)
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				AccountID:      stored.ID,
+				StoredBalance:  stored.Balance,
+				DerivedBalance: derived,
+				Difference:     stored.Balance - derived,
+			})
+		}
+	}
+
+	report.TransfersChecked = transfers.count
+	report.Totals.TransferDeposits = transfers.deposited
+	report.Totals.TransferWithdrawals = transfers.withdrawn
+	if report.Error == "" {
+		report.UnbalancedTransfers = r.checkTransfers(ctx, accounts, transfers)
+	}
+
+	totals := &report.Totals
+	totals.DerivedBooks = totals.InitialBalances + (totals.Deposits - totals.TransferDeposits) - (totals.Withdrawals - totals.TransferWithdrawals)
+	totals.Difference = totals.StoredBooks - totals.DerivedBooks
+	report.Balanced = report.Error == "" && len(report.Discrepancies) == 0 && len(report.UnbalancedTransfers) == 0 &&
+		equal(totals.StoredBooks, totals.DerivedBooks)
+	report.FinishedAt = time.Now()
+	r.store(report)
+
+	r.logger.Infof("reconciliation '%s' finished: %d accounts checked, %d skipped, %d discrepancies, %d unbalanced transfers",
+		report.ID, report.AccountsChecked, len(report.SkippedAccounts), len(report.Discrepancies), len(report.UnbalancedTransfers))
+	return report
+}
+
+// checkTransfers returns the transfers whose legs do not net to zero. The accounts are not read at the same time, so
+// a transfer stored while they were read may be seen with a single leg: the accounts are read again for the transfers
+// that were unbalanced in the first read, which are reported only if they still are. Since every transfer stored
+// while the accounts were first read is complete by the time they are read again, the transfers that are still
+// unbalanced are broken.
+func (r *Reconciler) checkTransfers(ctx context.Context, accounts []models.Account, transfers *transferLegs) []UnbalancedTransfer {
+	unbalanced := transfers.unbalanced()
+	if len(unbalanced) == 0 {
+		return unbalanced
+	}
+
+	r.logger.Debugf("%d transfers are unbalanced, reading the accounts again", len(unbalanced))
+	again := newTransferLegs(unbalanced...)
+	for _, acc := range accounts {
+		txs, err := r.db.GetTransactionsByAccountID(ctx, acc.ID)
+		if err != nil {
+			// the account cannot be read anymore, so the legs of the first read are reported
+			r.logger.Errorf("failed to read account '%s' while checking transfers: %v", acc.ID, err)
+			return unbalanced
+		}
+		again.add(txs, true, false)
+	}
+
+	result := again.unbalanced()
+	for _, t := range result {
+		r.logger.Warnf("reconciliation discrepancy in transfer '%s': withdrawn %f, deposited %f in %d legs", t.TransferID, t.Withdrawn, t.Deposited, t.Legs)
+	}
+	return result
+}
+
+// store stores the report, evicting the oldest one when there are more than maxReports.
+func (r *Reconciler) store(report *Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports[report.ID] = report
+	r.order = append(r.order, report.ID)
+	if len(r.order) > maxReports {
+		delete(r.reports, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// GetReport retrieves a reconciliation report by its id.
+func (r *Reconciler) GetReport(id string) (*Report, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report, ok := r.reports[id]
+	if !ok {
+		return nil, errors.ErrReportNotFound
+	}
+	return report, nil
+}
+
+// readAccount reads an account together with its transactions. Since both reads are not atomic, the transactions
+// are read again after the account and the process is repeated while they keep changing, so that concurrent
+// transactions are not reported as discrepancies. The transactions are only appended, so the account was not
+// modified between both reads of the transactions when they have the same number of transactions and the same last
+// one, even if its balance was changed and restored in between. If they change on every attempt, errAccountChanged
+// is returned with the transactions of the last attempt.
+func (r *Reconciler) readAccount(ctx context.Context, id string) (*models.Account, []models.Transaction, error) {
+	var (
+		acc *models.Account
+		txs []models.Transaction
+		err error
+	)
+
+	for i := 0; i < maxReadAttempts; i++ {
+		if txs, err = r.db.GetTransactionsByAccountID(ctx, id); err != nil {
+			return nil, nil, err
+		}
+		if acc, err = r.db.GetAccountByID(ctx, id); err != nil {
+			return nil, nil, err
+		}
+
+		after, err := r.db.GetTransactionsByAccountID(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sameTransactions(txs, after) {
+			return acc, txs, nil
+		}
+		r.logger.Debugf("account '%s' changed while being reconciled, reading it again", id)
+	}
+
+	return acc, txs, errAccountChanged
+}
+
+// sameTransactions checks whether two reads of the transactions of an account returned the same transactions.
+func sameTransactions(a, b []models.Transaction) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || a[len(a)-1].ID == b[len(b)-1].ID
+}
+
+// transferLegs matches the legs of the transfers. Only the transfers whose legs do not net to zero yet are kept, so
+// that its size does not grow with the ledger.
+type transferLegs struct {
+	count     int                            // number of transfers seen
+	deposited float64                        // sum of the deposit legs of the balanced transfers
+	withdrawn float64                        // sum of the withdrawal legs of the balanced transfers
+	open      map[string]*UnbalancedTransfer // transfers whose legs do not net to zero yet, by id
+}
+
+// newTransferLegs creates the legs of the transfers, which are open until their legs net to zero.
+func newTransferLegs(open ...UnbalancedTransfer) *transferLegs {
+	t := &transferLegs{open: make(map[string]*UnbalancedTransfer, len(open))}
+	for _, u := range open {
+		t.open[u.TransferID] = &UnbalancedTransfer{TransferID: u.TransferID}
+	}
+	return t
+}
+
+// add adds the transfer legs of the transactions. If onlyOpen is true, the legs of the transfers that are not open
+// are ignored. If skipped is true, the transactions belong to a skipped account, whose movements are not part of the
+// books: the transfers it is part of are not excluded from them once balanced, since their other leg is still counted.
+func (t *transferLegs) add(txs []models.Transaction, onlyOpen, skipped bool) {
+	for _, tx := range txs {
+		if tx.TransferID == "" {
+			continue
+		}
+		transfer, ok := t.open[tx.TransferID]
+		if !ok {
+			if onlyOpen {
+				continue
+			}
+			transfer = &UnbalancedTransfer{TransferID: tx.TransferID}
+			t.open[tx.TransferID] = transfer
+			t.count++
+		}
+
+		transfer.Legs++
+		transfer.skipped = transfer.skipped || skipped
+		switch tx.Type {
+		case enum.Deposit:
+			transfer.Deposited += tx.Amount
+		case enum.Withdrawal:
+			transfer.Withdrawn += tx.Amount
+		}
+		if transfer.Legs == 2 && transfer.Withdrawn > 0 && equal(transfer.Withdrawn, transfer.Deposited) {
+			if !transfer.skipped {
+				t.deposited += transfer.Deposited
+				t.withdrawn += transfer.Withdrawn
+			}
+			delete(t.open, tx.TransferID)
+		}
+	}
+}
+
+// unbalanced returns the open transfers, sorted by id.
+func (t *transferLegs) unbalanced() []UnbalancedTransfer {
+	result := make([]UnbalancedTransfer, 0, len(t.open))
+	for _, transfer := range t.open {
+		result = append(result, *transfer)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].TransferID < result[j].TransferID })
+	return result
+}
+
+// sumTransactions returns the sum of the deposits and the sum of the withdrawals of the transactions.
+func sumTransactions(txs []models.Transaction) (float64, float64) {
+	var deposits, withdrawals float64
+	for _, tx := range txs {
+		switch tx.Type {
+		case enum.Deposit:
+			deposits += tx.Amount
+		case enum.Withdrawal:
+			withdrawals += tx.Amount
+		}
+	}
+	return deposits, withdrawals
+}
+
+// equal checks whether two balances are equal within the tolerance.
+func equal(a, b float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
@@ -0,0 +1,212 @@
+package reconciliation
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type reconciliationSuite struct {
+	db db.DatabaseAdapter
+	r  *Reconciler
+	suite.Suite
+}
+
+func (s *reconciliationSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	s.db = memory.NewInMemoryDatabase(logger)
+	s.r = NewReconciler(logger, s.db)
+}
+
+// TestReconcile tests the reconciliation of the ledger.
+func (s *reconciliationSuite) TestReconcile() {
+	s.db.CreateAccount(context.Background(), &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100})
+	s.db.CreateAccount(context.Background(), &models.Account{ID: "2", Owner: "Bob", Balance: 50, InitialBalance: 50})
+	s.Require().NoError(s.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Withdrawal, Amount: 30}))
+	s.Require().NoError(s.db.CreateTransaction(context.Background(), &models.Transaction{ID: "tx2", AccountID: "2", Type: enum.Deposit, Amount: 30}))
+
+	s.Run("ok: balanced", func() {
+		report := s.r.Reconcile(context.Background(), Manual)
+		s.True(report.Balanced)
+		s.Empty(report.Discrepancies)
+		s.Equal(Manual, report.Trigger)
+		s.Equal(2, report.AccountsChecked)
+		s.Equal(2, report.TransactionsChecked)
+		s.Equal(float64(150), report.Totals.InitialBalances)
+		s.Equal(float64(30), report.Totals.Deposits)
+		s.Equal(float64(30), report.Totals.Withdrawals)
+		s.Equal(float64(150), report.Totals.DerivedBooks)
+		s.Equal(float64(150), report.Totals.StoredBooks)
+	})
+
+	s.Run("ok: discrepancy", func() {
+		// an account whose balance does not match its opening balance and has no transactions
+		s.db.CreateAccount(context.Background(), &models.Account{ID: "3", Owner: "Charlie", Balance: 80, InitialBalance: 60})
+
+		report := s.r.Reconcile(context.Background(), Scheduled)
+		s.False(report.Balanced)
+		s.Require().Len(report.Discrepancies, 1)
+		s.Equal("3", report.Discrepancies[0].AccountID)
+		s.Equal(float64(80), report.Discrepancies[0].StoredBalance)
+		s.Equal(float64(60), report.Discrepancies[0].DerivedBalance)
+		s.Equal(float64(20), report.Discrepancies[0].Difference)
+		s.Equal(float64(20), report.Totals.Difference)
+	})
+}
+
+// TestTransfers tests checking that the legs of the transfers net to zero.
+func (s *reconciliationSuite) TestTransfers() {
+	ctx := context.Background()
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "2", Owner: "Bob", Balance: 50, InitialBalance: 50}))
+	s.Require().NoError(s.db.CreateTransaction(ctx, &models.Transaction{ID: "tx1", AccountID: "2", Type: enum.Deposit, Amount: 10}))
+	s.Require().NoError(s.db.Transfer(ctx,
+		&models.Transaction{ID: "tx2", AccountID: "1", Type: enum.Withdrawal, Amount: 30, TransferID: "t1"},
+		&models.Transaction{ID: "tx3", AccountID: "2", Type: enum.Deposit, Amount: 30, TransferID: "t1"},
+	))
+
+	s.Run("ok: balanced", func() {
+		report := s.r.Reconcile(ctx, Manual)
+		s.True(report.Balanced)
+		s.Empty(report.UnbalancedTransfers)
+		s.Equal(1, report.TransfersChecked)
+		s.Equal(float64(30), report.Totals.TransferDeposits)
+		s.Equal(float64(30), report.Totals.TransferWithdrawals)
+		s.Equal(float64(160), report.Totals.DerivedBooks)
+		s.Equal(float64(160), report.Totals.StoredBooks)
+	})
+
+	s.Run("ok: missing leg", func() {
+		// the balance of the account matches its ledger, but the deposit leg of the transfer is missing
+		s.Require().NoError(s.db.CreateTransaction(ctx, &models.Transaction{ID: "tx4", AccountID: "1", Type: enum.Withdrawal, Amount: 20, TransferID: "t2"}))
+
+		report := s.r.Reconcile(ctx, Manual)
+		s.False(report.Balanced)
+		s.Empty(report.Discrepancies)
+		s.Equal(2, report.TransfersChecked)
+		s.Equal([]UnbalancedTransfer{{TransferID: "t2", Legs: 1, Withdrawn: 20}}, report.UnbalancedTransfers)
+		s.Equal(float64(140), report.Totals.DerivedBooks)
+		s.Equal(float64(140), report.Totals.StoredBooks)
+	})
+}
+
+// transferringDatabase is a database adapter that stores a transfer between two accounts the first time the
+// transactions of its deposit account are read, after its withdrawal account was read.
+type transferringDatabase struct {
+	db.DatabaseAdapter
+	once                sync.Once
+	withdrawal, deposit *models.Transaction
+}
+
+// GetTransactionsByAccountID stores the transfer before the first read of the transactions of its deposit account.
+func (d *transferringDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	var err error
+	if id == d.deposit.AccountID {
+		d.once.Do(func() { err = d.DatabaseAdapter.Transfer(ctx, d.withdrawal, d.deposit) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.DatabaseAdapter.GetTransactionsByAccountID(ctx, id)
+}
+
+// TestConcurrentTransfer tests that a transfer stored while the accounts are read is not reported.
+func (s *reconciliationSuite) TestConcurrentTransfer() {
+	ctx := context.Background()
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "2", Owner: "Bob", Balance: 50, InitialBalance: 50}))
+	r := NewReconciler(zap.NewExample().Sugar(), &transferringDatabase{
+		DatabaseAdapter: s.db,
+		withdrawal:      &models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Withdrawal, Amount: 30, TransferID: "t1"},
+		deposit:         &models.Transaction{ID: "tx2", AccountID: "2", Type: enum.Deposit, Amount: 30, TransferID: "t1"},
+	})
+
+	report := r.Reconcile(ctx, Manual)
+	s.True(report.Balanced, "%+v", report)
+	s.Empty(report.UnbalancedTransfers)
+	s.Equal(1, report.TransfersChecked)
+}
+
+// changingDatabase is a database adapter whose account gets a new transaction every time its transactions are read.
+type changingDatabase struct {
+	db.DatabaseAdapter
+	accountID string
+	reads     int
+}
+
+// GetTransactionsByAccountID appends a new deposit to the transactions of the changing account on every read.
+func (d *changingDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	txs, err := d.DatabaseAdapter.GetTransactionsByAccountID(ctx, id)
+	if err != nil || id != d.accountID {
+		return txs, err
+	}
+	for i := 0; i <= d.reads; i++ {
+		txs = append(txs, models.Transaction{ID: fmt.Sprintf("new%d", i), AccountID: id, Type: enum.Deposit, Amount: 10})
+	}
+	d.reads++
+	return txs, nil
+}
+
+// TestChangingAccount tests that an account that changes on every read is skipped instead of reported.
+func (s *reconciliationSuite) TestChangingAccount() {
+	ctx := context.Background()
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "1", Owner: "Alice", Balance: 100, InitialBalance: 100}))
+	s.Require().NoError(s.db.CreateAccount(ctx, &models.Account{ID: "2", Owner: "Bob", Balance: 50, InitialBalance: 50}))
+	s.Require().NoError(s.db.Transfer(ctx,
+		&models.Transaction{ID: "tx1", AccountID: "1", Type: enum.Withdrawal, Amount: 30, TransferID: "t1"},
+		&models.Transaction{ID: "tx2", AccountID: "2", Type: enum.Deposit, Amount: 30, TransferID: "t1"},
+	))
+	r := NewReconciler(zap.NewExample().Sugar(), &changingDatabase{DatabaseAdapter: s.db, accountID: "2"})
+
+	report := r.Reconcile(ctx, Manual)
+	s.True(report.Balanced, "%+v", report)
+	s.Equal([]string{"2"}, report.SkippedAccounts)
+	s.Empty(report.Discrepancies)
+	s.Empty(report.UnbalancedTransfers)
+	s.Equal(1, report.AccountsChecked)
+	s.Equal(1, report.TransfersChecked)
+	s.Equal(float64(0), report.Totals.TransferWithdrawals)
+	s.Equal(float64(70), report.Totals.DerivedBooks)
+	s.Equal(float64(70), report.Totals.StoredBooks)
+}
+
+// TestGetReport tests the retrieval of reconciliation reports.
+func (s *reconciliationSuite) TestGetReport() {
+	report := s.r.Reconcile(context.Background(), Manual)
+
+	s.Run("ok", func() {
+		stored, err := s.r.GetReport(report.ID)
+		s.Require().NoError(err)
+		s.Equal(report, stored)
+	})
+
+	s.Run("not ok: report not found", func() {
+		stored, err := s.r.GetReport(uuid.NewString())
+		s.Equal(errors.ErrReportNotFound, err)
+		s.Nil(stored)
+	})
+
+	s.Run("ok: oldest report evicted", func() {
+		for i := 0; i < maxReports; i++ {
+			s.r.Reconcile(context.Background(), Scheduled)
+		}
+		_, err := s.r.GetReport(report.ID)
+		s.Equal(errors.ErrReportNotFound, err)
+		s.Len(s.r.reports, maxReports)
+	})
+}
+
+func TestReconciliationSuite(t *testing.T) {
+	suite.Run(t, new(reconciliationSuite))
+}
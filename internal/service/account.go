@@ -1,60 +1,134 @@
 package service
 
 import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
 	"bank_test/internal/db"
 	"bank_test/internal/db/models"
+	"bank_test/internal/iban"
 	"bank_test/internal/transport/http/schemas"
+	"context"
+	"fmt"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// maxIBANAttempts is the number of times the service tries to generate an IBAN that is not in use.
+const maxIBANAttempts = 5
+
 // account handles all the account related operations.
 type account struct {
 	logger *zap.SugaredLogger
 	db     db.DatabaseAdapter
+	ibans  *iban.Generator
 }
 
 // NewAccountService creates a new account service that implements all the business logic for accounts.
-func NewAccountService(logger *zap.SugaredLogger, db db.DatabaseAdapter) AccountService {
-	return &account{logger: logger, db: db}
+func NewAccountService(logger *zap.SugaredLogger, db db.DatabaseAdapter, ibans *iban.Generator) AccountService {
+	return &account{logger: logger, db: db, ibans: ibans}
 }
 
-// CreateAccount creates a new account for the owner.
-func (a *account) CreateAccount(account *schemas.CreateAccountRequest) *models.Account {
+// CreateAccount creates a new account for the owner. Customers can only open empty accounts for themselves.
+func (a *account) CreateAccount(ctx context.Context, account *schemas.CreateAccountRequest) (*models.Account, error) {
 	a.logger.Debugf("creating account for owner %s", account.Owner)
+	if err := auth.AuthorizeOwner(ctx, account.Owner); err != nil {
+		return nil, err
+	}
+	if *account.InitialBalance != 0 {
+		if err := auth.Authorize(ctx, auth.FundAccount); err != nil {
+			return nil, err
+		}
+	}
 
 	a.logger.Debugf("generating account id")
 	id := uuid.New()
 	a.logger.Debugf("account id generated: %s", id.String())
 
+	a.logger.Debugf("generating account number")
+	number, err := a.generateIBAN(ctx)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, err
+	}
+	a.logger.Debugf("account number generated: %s", number)
+
 	acc := models.Account{
 		ID:      id.String(),
+		IBAN:    number,
 		Owner:   account.Owner,
 		Balance: *account.InitialBalance,
+
+		InitialBalance: *account.InitialBalance,
 	}
 
 	a.logger.Debugf("saving account to database with id %s", acc.ID)
-	a.db.CreateAccount(&acc)
+	if err := a.db.CreateAccount(ctx, &acc); err != nil {
+		a.logger.Error(err)
+		return nil, err
+	}
 	a.logger.Debugf("account with id %s created successfully", acc.ID)
-	return &acc
+	return &acc, nil
 }
 
 // GetAccountByID retrieves an account by its id.
-func (a *account) GetAccountByID(id string) (*models.Account, error) {
+func (a *account) GetAccountByID(ctx context.Context, id string) (*models.Account, error) {
 	a.logger.Debugf("getting account with id %s", id)
-	acc, err := a.db.GetAccountByID(id)
+	acc, err := a.db.GetAccountByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := auth.AuthorizeOwner(ctx, acc.Owner); err != nil {
+		return nil, err
+	}
 	a.logger.Debugf("account with id %s retrieved successfully", id)
 	return acc, nil
 }
 
-// GetAllAccounts retrieves all accounts stored in the database.
-func (a *account) GetAllAccounts() []models.Account {
+// GetAccountByIBAN retrieves an account by its iban.
+func (a *account) GetAccountByIBAN(ctx context.Context, number string) (*models.Account, error) {
+	number = iban.Normalize(number)
+
+	a.logger.Debugf("getting account with iban %s", number)
+	acc, err := a.db.GetAccountByIBAN(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeOwner(ctx, acc.Owner); err != nil {
+		return nil, err
+	}
+	a.logger.Debugf("account with iban %s retrieved successfully", number)
+	return acc, nil
+}
+
+// GetAllAccounts retrieves all accounts stored in the database. Customers only retrieve their own accounts.
+func (a *account) GetAllAccounts(ctx context.Context) ([]models.Account, error) {
 	a.logger.Debugf("getting all accounts")
-	accounts := a.db.GetAllAccounts()
+	accounts, err := a.db.GetAllAccounts(ctx)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, err
+	}
 	a.logger.Debugf("all accounts retrieved successfully")
-	return accounts
+	return ownedAccounts(ctx, accounts), nil
+}
+
+// generateIBAN generates a new IBAN that is not assigned to any account yet.
+func (a *account) generateIBAN(ctx context.Context) (string, error) {
+	for i := 0; i < maxIBANAttempts; i++ {
+		number, err := a.ibans.Generate()
+		if err != nil {
+			return "", err
+		}
+
+		_, err = a.db.GetAccountByIBAN(ctx, number)
+		if err == errors.ErrAccountNotFound {
+			return number, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		a.logger.Debugf("account number %s is already in use, generating a new one", number)
+	}
+	return "", fmt.Errorf("failed to generate a unique account number after %d attempts", maxIBANAttempts)
 }
@@ -2,11 +2,15 @@ package service
 
 import (
 	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
 	"bank_test/internal/db"
 	"bank_test/internal/db/memory"
 	"bank_test/internal/db/models"
 	"bank_test/internal/helpers"
+	"bank_test/internal/iban"
 	"bank_test/internal/transport/http/schemas"
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -24,8 +28,11 @@ type accountSuite struct {
 func (s *accountSuite) SetupTest() {
 	logger := zap.NewExample().Sugar()
 
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+
 	s.db = memory.NewInMemoryDatabase(logger)
-	s.as = NewAccountService(logger, s.db)
+	s.as = NewAccountService(logger, s.db, ibans)
 }
 
 func (s *accountSuite) TestCreateAccount() {
@@ -57,12 +64,13 @@ func (s *accountSuite) TestCreateAccount() {
 		}
 
 		for _, data := range inputData {
-			acc := s.as.CreateAccount(data.in)
+			acc, err := s.as.CreateAccount(context.Background(), data.in)
+			s.Require().NoError(err)
 			s.Equal(data.out.Owner, acc.Owner)
 			s.Equal(data.out.Balance, acc.Balance)
 
 			// check if the account was saved in the database
-			accFromDB, err := s.db.GetAccountByID(acc.ID)
+			accFromDB, err := s.db.GetAccountByID(context.Background(), acc.ID)
 			s.NoError(err)
 			s.Equal(data.out.Owner, accFromDB.Owner)
 			s.Equal(data.out.Balance, accFromDB.Balance)
@@ -76,7 +84,8 @@ func (s *accountSuite) TestGetAccountByID() {
 		Owner:          "Alice",
 		InitialBalance: helpers.PointerValue(float64(20)),
 	}
-	createdAccount := s.as.CreateAccount(&account)
+	createdAccount, err := s.as.CreateAccount(context.Background(), &account)
+	s.Require().NoError(err)
 
 	s.Run("ok", func() {
 		inputData := []struct {
@@ -97,10 +106,56 @@ func (s *accountSuite) TestGetAccountByID() {
 		}
 
 		for _, data := range inputData {
-			acc, err := s.as.GetAccountByID(data.in)
+			acc, err := s.as.GetAccountByID(context.Background(), data.in)
+			s.Equal(data.err, err)
+
+			if data.out != nil {
+				s.Equal(data.out.Owner, acc.Owner)
+				s.Equal(data.out.Balance, acc.Balance)
+			}
+		}
+	})
+}
+
+func (s *accountSuite) TestGetAccountByIBAN() {
+	// create an account
+	account := schemas.CreateAccountRequest{
+		Owner:          "Alice",
+		InitialBalance: helpers.PointerValue(float64(20)),
+	}
+	createdAccount, err := s.as.CreateAccount(context.Background(), &account)
+	s.Require().NoError(err)
+	s.Require().NoError(iban.Validate(createdAccount.IBAN))
+
+	s.Run("ok", func() {
+		inputData := []struct {
+			in  string
+			out *models.Account
+			err error
+		}{
+			{
+				in:  createdAccount.IBAN,
+				out: createdAccount,
+				err: nil,
+			},
+			{
+				in:  strings.ToLower(createdAccount.IBAN[:4]) + " " + createdAccount.IBAN[4:],
+				out: createdAccount,
+				err: nil,
+			},
+			{
+				in:  "GB82WEST12345698765432",
+				out: nil,
+				err: errors.ErrAccountNotFound,
+			},
+		}
+
+		for _, data := range inputData {
+			acc, err := s.as.GetAccountByIBAN(context.Background(), data.in)
 			s.Equal(data.err, err)
 
 			if data.out != nil {
+				s.Equal(data.out.ID, acc.ID)
 				s.Equal(data.out.Owner, acc.Owner)
 				s.Equal(data.out.Balance, acc.Balance)
 			}
@@ -122,11 +177,13 @@ func (s *accountSuite) TestGetAllAccounts() {
 	}
 
 	for _, acc := range accounts {
-		s.as.CreateAccount(&acc)
+		_, err := s.as.CreateAccount(context.Background(), &acc)
+		s.Require().NoError(err)
 	}
 
 	s.Run("ok", func() {
-		accs := s.as.GetAllAccounts()
+		accs, err := s.as.GetAllAccounts(context.Background())
+		s.Require().NoError(err)
 		s.Equal(len(accounts), len(accs))
 
 		responseOwners := goterators.Map(accs, func(acc models.Account) string {
@@ -139,6 +196,29 @@ func (s *accountSuite) TestGetAllAccounts() {
 		includesAllOwners := goterators.Include(responseOwners, expectedOwners)
 		s.True(includesAllOwners)
 	})
+
+	s.Run("ok: customer only retrieves its own accounts", func() {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Method: auth.JWTMethod, ID: "alice", Owner: "Alice"})
+		accs, err := s.as.GetAllAccounts(ctx)
+		s.Require().NoError(err)
+		s.Require().Len(accs, 1)
+		s.Equal("Alice", accs[0].Owner)
+
+		_, err = s.as.GetAccountByID(ctx, accs[0].ID)
+		s.NoError(err)
+	})
+
+	s.Run("error: customer retrieves an account of another owner", func() {
+		accs, err := s.as.GetAllAccounts(context.Background())
+		s.Require().NoError(err)
+		bob := goterators.Filter(accs, func(acc models.Account) bool { return acc.Owner == "Bob" })[0]
+
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Method: auth.JWTMethod, ID: "alice", Owner: "Alice"})
+		_, err = s.as.GetAccountByID(ctx, bob.ID)
+		s.ErrorIs(err, errors.ErrAccountAccessDenied)
+		_, err = s.as.GetAccountByIBAN(ctx, bob.IBAN)
+		s.ErrorIs(err, errors.ErrAccountAccessDenied)
+	})
 }
 
 func TestAccountSuite(t *testing.T) {
@@ -3,18 +3,24 @@ package service
 import (
 	"bank_test/internal/db/models"
 	"bank_test/internal/transport/http/schemas"
+	"context"
+	"time"
 )
 
 // AccountService is the interface for the account service. It defines the business logic for the account service.
 type AccountService interface {
-	CreateAccount(account *schemas.CreateAccountRequest) *models.Account // CreateAccount creates a new account
-	GetAccountByID(id string) (*models.Account, error)                   // GetAccountByID retrieves an account by its ID
-	GetAllAccounts() []models.Account                                    // GetAllAccounts retrieves all accounts
+	CreateAccount(ctx context.Context, account *schemas.CreateAccountRequest) (*models.Account, error) // CreateAccount creates a new account
+	GetAccountByID(ctx context.Context, id string) (*models.Account, error)                            // GetAccountByID retrieves an account by its ID
+	GetAccountByIBAN(ctx context.Context, iban string) (*models.Account, error)                        // GetAccountByIBAN retrieves an account by its IBAN
+	GetAllAccounts(ctx context.Context) ([]models.Account, error)                                      // GetAllAccounts retrieves all accounts
 }
 
 // TransactionService is the interface for the transaction service. It defines the business logic for the transaction service.
 type TransactionService interface {
-	CreateTransaction(accountId string, transaction *schemas.CreateTransactionRequest) (*models.Transaction, error) // CreateTransaction creates a new transaction
-	GetTransactionsByAccountID(accountId string) ([]models.Transaction, error)                                      // GetTransactionsByAccountID retrieves all transactions for an account
-	Transfer(from string, to string, amount float64) error                                                          // Transfer transfers money from one account to another
+	CreateTransaction(ctx context.Context, accountId string, transaction *schemas.CreateTransactionRequest) (*models.Transaction, error) // CreateTransaction creates a new transaction
+	GetTransactionsByAccountID(ctx context.Context, accountId string) ([]models.Transaction, error)                                      // GetTransactionsByAccountID retrieves all transactions for an account
+	GetTransactionsByAccountIDs(ctx context.Context, accountIds []string) (map[string][]models.Transaction, error)                       // GetTransactionsByAccountIDs retrieves the transactions of several accounts at once, keyed by account
+	ScanTransactionsByAccountID(ctx context.Context, accountId string, from, to time.Time, fn func(models.Transaction) error) error      // ScanTransactionsByAccountID calls fn for every transaction of an account in the period [from, to), reading them by pages
+	Transfer(ctx context.Context, from string, to string, amount float64) error                                                          // Transfer transfers money from one account to another. Accounts can be referenced by ID or IBAN
+	TransferBatch(ctx context.Context, transfers []schemas.TransferRequest) error                                                        // TransferBatch executes several transfers atomically, in order: either all are executed or none
 }
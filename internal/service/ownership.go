@@ -0,0 +1,36 @@
+package service
+
+import (
+	"bank_test/internal/auth"
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"context"
+)
+
+// authorizeAccount checks that the caller carried by the context can access the account with the id. The account is
+// only loaded when the caller is restricted to the accounts of an owner.
+func authorizeAccount(ctx context.Context, database db.DatabaseAdapter, id string) error {
+	if _, ok := auth.OwnerFrom(ctx); !ok {
+		return nil
+	}
+	acc, err := database.GetAccountByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return auth.AuthorizeOwner(ctx, acc.Owner)
+}
+
+// ownedAccounts returns the accounts that the caller carried by the context can access.
+func ownedAccounts(ctx context.Context, accounts []models.Account) []models.Account {
+	owner, ok := auth.OwnerFrom(ctx)
+	if !ok {
+		return accounts
+	}
+	owned := make([]models.Account, 0)
+	for _, acc := range accounts {
+		if acc.Owner == owner {
+			owned = append(owned, acc)
+		}
+	}
+	return owned
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	errors "bank_test/internal/api_errors"
 	"bank_test/internal/db"
 	"bank_test/internal/db/models"
 	"bank_test/internal/enum"
+	"bank_test/internal/iban"
 	"bank_test/internal/transport/http/schemas"
+	"context"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,9 +24,12 @@ func NewTransactionService(logger *zap.SugaredLogger, db db.DatabaseAdapter) Tra
 	return &transaction{logger: logger, db: db}
 }
 
-// CreateTransaction creates a new transaction for the account.
-func (s *transaction) CreateTransaction(id string, transaction *schemas.CreateTransactionRequest) (*models.Transaction, error) {
+// CreateTransaction creates a new transaction for the account. Customers can only operate their own accounts.
+func (s *transaction) CreateTransaction(ctx context.Context, id string, transaction *schemas.CreateTransactionRequest) (*models.Transaction, error) {
 	s.logger.Debugf("creating transaction for account with id %s", id)
+	if err := authorizeAccount(ctx, s.db, id); err != nil {
+		return nil, s.wrapError(err)
+	}
 
 	// convert transaction type to enum. It is not necessary to validate the transaction type since it has already
 	// been validated in the handler when decoding the request body by using the tag 'oneof'.
@@ -40,7 +46,7 @@ func (s *transaction) CreateTransaction(id string, transaction *schemas.CreateTr
 		Amount:    *transaction.Amount,
 		Timestamp: time.Now(),
 	}
-	if err := s.db.CreateTransaction(&tx); err != nil {
+	if err := s.db.CreateTransaction(ctx, &tx); err != nil {
 		return nil, s.wrapError(err)
 	}
 	s.logger.Debugf("transaction with id %s created successfully", tx.ID)
@@ -49,9 +55,12 @@ func (s *transaction) CreateTransaction(id string, transaction *schemas.CreateTr
 }
 
 // GetTransactionsByAccountID retrieves all transactions for the account.
-func (s *transaction) GetTransactionsByAccountID(accountID string) ([]models.Transaction, error) {
+func (s *transaction) GetTransactionsByAccountID(ctx context.Context, accountID string) ([]models.Transaction, error) {
 	s.logger.Debugf("getting all transactions for account with id %s", accountID)
-	txs, err := s.db.GetTransactionsByAccountID(accountID)
+	if err := authorizeAccount(ctx, s.db, accountID); err != nil {
+		return nil, s.wrapError(err)
+	}
+	txs, err := s.db.GetTransactionsByAccountID(ctx, accountID)
 	if err != nil {
 		return nil, s.wrapError(err)
 	}
@@ -59,37 +68,132 @@ func (s *transaction) GetTransactionsByAccountID(accountID string) ([]models.Tra
 	return txs, nil
 }
 
-// Transfer transfer money from one account to another.
-func (s *transaction) Transfer(from string, to string, amount float64) error {
+// ScanTransactionsByAccountID calls fn for every transaction of the account in the period [from, to), which are read by
+// pages instead of being held in memory. A zero from or to leaves the period open.
+func (s *transaction) ScanTransactionsByAccountID(ctx context.Context, accountID string, from, to time.Time, fn func(models.Transaction) error) error {
+	s.logger.Debugf("scanning the transactions of account with id %s", accountID)
+	if err := authorizeAccount(ctx, s.db, accountID); err != nil {
+		return s.wrapError(err)
+	}
+	if err := s.db.ScanTransactions(ctx, accountID, from, to, fn); err != nil {
+		return s.wrapError(err)
+	}
+	s.logger.Debugf("transactions of account with id %s scanned successfully", accountID)
+	return nil
+}
+
+// GetTransactionsByAccountIDs retrieves the transactions of several accounts with a single database call. Unknown
+// accounts are left out of the result, and customers are denied access if any of the accounts is not theirs.
+func (s *transaction) GetTransactionsByAccountIDs(ctx context.Context, accountIDs []string) (map[string][]models.Transaction, error) {
+	s.logger.Debugf("getting all transactions for %d accounts", len(accountIDs))
+	for _, id := range accountIDs {
+		if err := authorizeAccount(ctx, s.db, id); err != nil && err != errors.ErrAccountNotFound {
+			return nil, s.wrapError(err)
+		}
+	}
+	txs, err := s.db.GetTransactionsByAccountIDs(ctx, accountIDs)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	s.logger.Debugf("all transactions for %d accounts retrieved successfully", len(accountIDs))
+	return txs, nil
+}
+
+// Transfer transfer money from one account to another. Customers can only transfer money from their own accounts, to
+// any account.
+func (s *transaction) Transfer(ctx context.Context, from string, to string, amount float64) error {
 	s.logger.Debugf("transferring %.5f from account %s to account %s", amount, from, to)
 
-	// create a new transaction for the withdrawal
-	withdrawalFrom := &models.Transaction{
-		ID:        uuid.New().String(),
-		AccountID: from,
-		Type:      enum.Withdrawal,
-		Amount:    amount,
-		Timestamp: time.Now(),
+	// accounts can be referenced either by their id or by their iban
+	from, err := s.resolveAccountID(ctx, from)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if err := authorizeAccount(ctx, s.db, from); err != nil {
+		return s.wrapError(err)
 	}
-	if err := s.db.CreateTransaction(withdrawalFrom); err != nil {
+	to, err = s.resolveAccountID(ctx, to)
+	if err != nil {
 		return s.wrapError(err)
 	}
 
-	// create a new transaction for the deposit
+	// create a new transaction for the withdrawal and another one for the deposit, which share the id of the transfer.
+	// Both are stored atomically
+	now := time.Now()
+	transferID := uuid.New().String()
+	withdrawalFrom := &models.Transaction{
+		ID:         uuid.New().String(),
+		AccountID:  from,
+		Type:       enum.Withdrawal,
+		Amount:     amount,
+		Timestamp:  now,
+		TransferID: transferID,
+	}
 	depositTo := &models.Transaction{
-		ID:        uuid.New().String(),
-		AccountID: to,
-		Type:      enum.Deposit,
-		Amount:    amount,
-		Timestamp: time.Now(),
+		ID:         uuid.New().String(),
+		AccountID:  to,
+		Type:       enum.Deposit,
+		Amount:     amount,
+		Timestamp:  now,
+		TransferID: transferID,
 	}
-	if err := s.db.CreateTransaction(depositTo); err != nil {
+	if err := s.db.Transfer(ctx, withdrawalFrom, depositTo); err != nil {
 		return s.wrapError(err)
 	}
 	s.logger.Debugf("transfer completed successfully")
 	return nil
 }
 
+// TransferBatch executes the transfers atomically, in order: either all of them are executed or none. Customers can
+// only transfer money from their own accounts, to any account. A failed transfer is reported with an
+// *errors.BatchError that gives its position.
+func (s *transaction) TransferBatch(ctx context.Context, transfers []schemas.TransferRequest) error {
+	s.logger.Debugf("transferring a batch of %d transfers", len(transfers))
+
+	now := time.Now()
+	batch := make([]models.Transfer, 0, len(transfers))
+	for i, t := range transfers {
+		// accounts can be referenced either by their id or by their iban
+		from, err := s.resolveAccountID(ctx, t.FromAccountId)
+		if err != nil {
+			return s.wrapError(&errors.BatchError{Index: i, Err: err})
+		}
+		if err := authorizeAccount(ctx, s.db, from); err != nil {
+			return s.wrapError(&errors.BatchError{Index: i, Err: err})
+		}
+		to, err := s.resolveAccountID(ctx, t.ToAccountId)
+		if err != nil {
+			return s.wrapError(&errors.BatchError{Index: i, Err: err})
+		}
+
+		transferID := uuid.New().String()
+		batch = append(batch, models.Transfer{
+			Withdrawal: models.Transaction{ID: uuid.New().String(), AccountID: from, Type: enum.Withdrawal, Amount: *t.Amount, Timestamp: now, TransferID: transferID},
+			Deposit:    models.Transaction{ID: uuid.New().String(), AccountID: to, Type: enum.Deposit, Amount: *t.Amount, Timestamp: now, TransferID: transferID},
+		})
+	}
+	if err := s.db.TransferBatch(ctx, batch); err != nil {
+		return s.wrapError(err)
+	}
+	s.logger.Debugf("batch of %d transfers completed successfully", len(transfers))
+	return nil
+}
+
+// resolveAccountID returns the id of the account referenced by ref, which can be either an account id or an iban.
+func (s *transaction) resolveAccountID(ctx context.Context, ref string) (string, error) {
+	if uuid.Validate(ref) == nil {
+		return ref, nil
+	}
+
+	s.logger.Debugf("resolving account id for iban %s", ref)
+	acc, err := s.db.GetAccountByIBAN(ctx, iban.Normalize(ref))
+	if err != nil {
+		return "", err
+	}
+	s.logger.Debugf("iban %s resolved to account %s", ref, acc.ID)
+	return acc.ID, nil
+}
+
 // wrapError logs the error and returns it.
 func (s *transaction) wrapError(err error) error {
 	s.logger.Error(err)
@@ -2,11 +2,14 @@ package service
 
 import (
 	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
 	"bank_test/internal/db"
 	"bank_test/internal/db/memory"
 	"bank_test/internal/db/models"
 	"bank_test/internal/helpers"
+	"bank_test/internal/iban"
 	"bank_test/internal/transport/http/schemas"
+	"context"
 	"sync"
 	"testing"
 
@@ -26,8 +29,11 @@ type transactionSuite struct {
 func (s *transactionSuite) SetupTest() {
 	logger := zap.NewExample().Sugar()
 
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+
 	s.db = memory.NewInMemoryDatabase(logger)
-	s.as = NewAccountService(logger, s.db)
+	s.as = NewAccountService(logger, s.db, ibans)
 	s.ts = NewTransactionService(logger, s.db)
 }
 
@@ -51,7 +57,9 @@ func (s *transactionSuite) TestCreateTransaction() {
 	storedAccounts := make([]*models.Account, 3)
 
 	for i, acc := range account {
-		storedAccounts[i] = s.as.CreateAccount(&acc)
+		created, err := s.as.CreateAccount(context.Background(), &acc)
+		s.Require().NoError(err)
+		storedAccounts[i] = created
 	}
 
 	s.Run("ok: withdrawal and deposit", func() {
@@ -81,7 +89,7 @@ func (s *transactionSuite) TestCreateTransaction() {
 
 		// Execute transactions and verify results
 		for _, input := range inputs {
-			tx, err := s.ts.CreateTransaction(input.accountId, input.transaction)
+			tx, err := s.ts.CreateTransaction(context.Background(), input.accountId, input.transaction)
 			s.NoError(err)
 			s.NotNil(tx)
 
@@ -91,14 +99,14 @@ func (s *transactionSuite) TestCreateTransaction() {
 			s.Equal(input.accountId, tx.AccountID)
 
 			// Verify updated account balance
-			account, err := s.as.GetAccountByID(input.accountId)
+			account, err := s.as.GetAccountByID(context.Background(), input.accountId)
 			s.NoError(err)
 			s.Equal(input.expectedBal, account.Balance)
 		}
 	})
 
 	s.Run("not ok: account not found", func() {
-		tx, err := s.ts.CreateTransaction(uuid.NewString(), &schemas.CreateTransactionRequest{
+		tx, err := s.ts.CreateTransaction(context.Background(), uuid.NewString(), &schemas.CreateTransactionRequest{
 			Type:   "deposit",
 			Amount: helpers.PointerValue(float64(10)),
 		})
@@ -110,7 +118,7 @@ func (s *transactionSuite) TestCreateTransaction() {
 	})
 
 	s.Run("not ok: insufficient balance", func() {
-		tx, err := s.ts.CreateTransaction(storedAccounts[1].ID, &schemas.CreateTransactionRequest{
+		tx, err := s.ts.CreateTransaction(context.Background(), storedAccounts[1].ID, &schemas.CreateTransactionRequest{
 			Type:   "withdrawal",
 			Amount: helpers.PointerValue(float64(10)),
 		})
@@ -131,7 +139,8 @@ func (s *transactionSuite) TestConcurrentTransactions() {
 		Owner:          "TestUser",
 		InitialBalance: &initialBalance,
 	}
-	account := s.as.CreateAccount(accountReq)
+	account, err := s.as.CreateAccount(context.Background(), accountReq)
+	s.Require().NoError(err)
 	s.Require().NotNil(account)
 
 	// Define transactions to process concurrently
@@ -151,7 +160,7 @@ func (s *transactionSuite) TestConcurrentTransactions() {
 		go func(transaction schemas.CreateTransactionRequest) {
 			defer wg.Done()
 			// Create transaction and update balance in sequence
-			_, err := s.ts.CreateTransaction(account.ID, &transaction)
+			_, err := s.ts.CreateTransaction(context.Background(), account.ID, &transaction)
 			results <- err
 		}(tx)
 	}
@@ -170,7 +179,7 @@ func (s *transactionSuite) TestConcurrentTransactions() {
 	}
 
 	// Verify the final balance
-	finalAccount, err := s.as.GetAccountByID(account.ID)
+	finalAccount, err := s.as.GetAccountByID(context.Background(), account.ID)
 	s.Require().NoError(err)
 
 	expectedBalance := float64(100) // Adjust based on successful transactions
@@ -198,7 +207,9 @@ func (s *transactionSuite) TestGetTransactionsByAccountID() {
 	storedAccounts := make([]*models.Account, 3)
 
 	for i, acc := range account {
-		storedAccounts[i] = s.as.CreateAccount(&acc)
+		created, err := s.as.CreateAccount(context.Background(), &acc)
+		s.Require().NoError(err)
+		storedAccounts[i] = created
 	}
 
 	// Define input data
@@ -225,14 +236,14 @@ func (s *transactionSuite) TestGetTransactionsByAccountID() {
 	// Execute transactions
 	for _, input := range inputs {
 		for _, tx := range input.transactions {
-			_, err := s.ts.CreateTransaction(input.accountId, &tx)
+			_, err := s.ts.CreateTransaction(context.Background(), input.accountId, &tx)
 			s.Require().NoError(err)
 		}
 	}
 
 	// Get transactions for each account
 	for _, input := range inputs {
-		txs, err := s.ts.GetTransactionsByAccountID(input.accountId)
+		txs, err := s.ts.GetTransactionsByAccountID(context.Background(), input.accountId)
 		s.Require().NoError(err)
 		s.Len(txs, len(input.transactions))
 
@@ -243,10 +254,34 @@ func (s *transactionSuite) TestGetTransactionsByAccountID() {
 			s.Equal(input.accountId, tx.AccountID)
 		}
 	}
+
+	// Get the transactions of every account at once
+	byAccount, err := s.ts.GetTransactionsByAccountIDs(context.Background(), []string{storedAccounts[0].ID, storedAccounts[1].ID, storedAccounts[2].ID})
+	s.Require().NoError(err)
+	s.Len(byAccount, 3)
+	for _, input := range inputs {
+		s.Len(byAccount[input.accountId], len(input.transactions))
+	}
+	s.Empty(byAccount[storedAccounts[2].ID])
 }
 
 // TestTransfer tests the transfer of funds between accounts.
 func (s *transactionSuite) TestTransfer() {
+	s.Run("error: customer transfers from an account of another owner", func() {
+		alice, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{Owner: "Alice", InitialBalance: helpers.PointerValue(float64(100))})
+		s.Require().NoError(err)
+		bob, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{Owner: "Bob", InitialBalance: helpers.PointerValue(float64(100))})
+		s.Require().NoError(err)
+
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Method: auth.JWTMethod, ID: "alice", Owner: "Alice"})
+		s.ErrorIs(s.ts.Transfer(ctx, bob.ID, alice.ID, 10), errors.ErrAccountAccessDenied)
+		s.NoError(s.ts.Transfer(ctx, alice.ID, bob.IBAN, 10))
+
+		acc, err := s.as.GetAccountByID(context.Background(), bob.ID)
+		s.Require().NoError(err)
+		s.Equal(float64(110), acc.Balance)
+	})
+
 	s.Run("ok: successful transfer", func() {
 		accounts := []schemas.CreateAccountRequest{
 			{Owner: "Alice", InitialBalance: helpers.PointerValue(float64(100))},
@@ -256,7 +291,8 @@ func (s *transactionSuite) TestTransfer() {
 		storedAccounts := make(map[string]*models.Account)
 
 		for _, acc := range accounts {
-			account := s.as.CreateAccount(&acc)
+			account, err := s.as.CreateAccount(context.Background(), &acc)
+			s.Require().NoError(err)
 			s.Require().NotNil(account)
 			storedAccounts[account.Owner] = account
 		}
@@ -265,15 +301,44 @@ func (s *transactionSuite) TestTransfer() {
 		to := storedAccounts["Bob"]
 		amount := float64(30)
 
-		err := s.ts.Transfer(from.ID, to.ID, amount)
+		err := s.ts.Transfer(context.Background(), from.ID, to.ID, amount)
+		s.NoError(err)
+
+		// Validate balances
+		fromAccount, err := s.as.GetAccountByID(context.Background(), from.ID)
+		s.Require().NoError(err)
+		s.Equal(float64(70), fromAccount.Balance) // 100 - 30 = 70
+
+		toAccount, err := s.as.GetAccountByID(context.Background(), to.ID)
+		s.Require().NoError(err)
+		s.Equal(float64(80), toAccount.Balance) // 50 + 30 = 80
+
+		// both legs share the id of the transfer
+		withdrawals, err := s.ts.GetTransactionsByAccountID(context.Background(), from.ID)
+		s.Require().NoError(err)
+		deposits, err := s.ts.GetTransactionsByAccountID(context.Background(), to.ID)
+		s.Require().NoError(err)
+		s.Require().Len(withdrawals, 1)
+		s.Require().Len(deposits, 1)
+		s.NotEmpty(withdrawals[0].TransferID)
+		s.Equal(withdrawals[0].TransferID, deposits[0].TransferID)
+	})
+
+	s.Run("ok: transfer by iban", func() {
+		from, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{Owner: "Alice", InitialBalance: helpers.PointerValue(float64(100))})
+		s.Require().NoError(err)
+		to, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{Owner: "Bob", InitialBalance: helpers.PointerValue(float64(50))})
+		s.Require().NoError(err)
+
+		err = s.ts.Transfer(context.Background(), from.IBAN, to.ID, float64(30))
 		s.NoError(err)
 
 		// Validate balances
-		fromAccount, err := s.as.GetAccountByID(from.ID)
+		fromAccount, err := s.as.GetAccountByID(context.Background(), from.ID)
 		s.Require().NoError(err)
 		s.Equal(float64(70), fromAccount.Balance) // 100 - 30 = 70
 
-		toAccount, err := s.as.GetAccountByID(to.ID)
+		toAccount, err := s.as.GetAccountByID(context.Background(), to.ID)
 		s.Require().NoError(err)
 		s.Equal(float64(80), toAccount.Balance) // 50 + 30 = 80
 	})
@@ -287,7 +352,8 @@ func (s *transactionSuite) TestTransfer() {
 		storedAccounts := make(map[string]*models.Account)
 
 		for _, acc := range accounts {
-			account := s.as.CreateAccount(&acc)
+			account, err := s.as.CreateAccount(context.Background(), &acc)
+			s.Require().NoError(err)
 			s.Require().NotNil(account)
 			storedAccounts[account.Owner] = account
 		}
@@ -296,24 +362,32 @@ func (s *transactionSuite) TestTransfer() {
 		to := storedAccounts["Bob"]
 		amount := float64(200)
 
-		err := s.ts.Transfer(from.ID, to.ID, amount)
+		err := s.ts.Transfer(context.Background(), from.ID, to.ID, amount)
 		s.Error(err)
 		apiError, ok := err.(*errors.APIError)
 		s.Require().True(ok)
 		s.Equal(apiError, errors.ErrInsufficientBalance)
 
 		// Validate balances
-		fromAccount, err := s.as.GetAccountByID(from.ID)
+		fromAccount, err := s.as.GetAccountByID(context.Background(), from.ID)
 		s.Require().NoError(err)
 		s.Equal(float64(100), fromAccount.Balance) // No change
 
-		toAccount, err := s.as.GetAccountByID(to.ID)
+		toAccount, err := s.as.GetAccountByID(context.Background(), to.ID)
 		s.Require().NoError(err)
 		s.Equal(float64(50), toAccount.Balance) // No change
 	})
 
 	s.Run("not ok: account not found", func() {
-		err := s.ts.Transfer(uuid.NewString(), uuid.NewString(), float64(10))
+		err := s.ts.Transfer(context.Background(), uuid.NewString(), uuid.NewString(), float64(10))
+		s.Error(err)
+		apiError, ok := err.(*errors.APIError)
+		s.Require().True(ok)
+		s.Equal(apiError, errors.ErrAccountNotFound)
+	})
+
+	s.Run("not ok: iban not found", func() {
+		err := s.ts.Transfer(context.Background(), "GB82WEST12345698765432", uuid.NewString(), float64(10))
 		s.Error(err)
 		apiError, ok := err.(*errors.APIError)
 		s.Require().True(ok)
@@ -324,14 +398,16 @@ func (s *transactionSuite) TestTransfer() {
 // TestConcurrentTransfers tests the concurrent execution of transfers.
 func (s *transactionSuite) TestConcurrentTransfers() {
 	// Setup: create accounts
-	alice := s.as.CreateAccount(&schemas.CreateAccountRequest{
+	alice, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{
 		Owner:          "Alice",
 		InitialBalance: helpers.PointerValue(float64(1000)),
 	})
-	bob := s.as.CreateAccount(&schemas.CreateAccountRequest{
+	s.Require().NoError(err)
+	bob, err := s.as.CreateAccount(context.Background(), &schemas.CreateAccountRequest{
 		Owner:          "Bob",
 		InitialBalance: helpers.PointerValue(float64(100)),
 	})
+	s.Require().NoError(err)
 	s.Require().NotNil(alice)
 	s.Require().NotNil(bob)
 
@@ -347,7 +423,7 @@ func (s *transactionSuite) TestConcurrentTransfers() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := s.ts.Transfer(alice.ID, bob.ID, transferAmount)
+			err := s.ts.Transfer(context.Background(), alice.ID, bob.ID, transferAmount)
 			errs <- err
 		}()
 	}
@@ -362,9 +438,9 @@ func (s *transactionSuite) TestConcurrentTransfers() {
 	}
 
 	// Verify final balances
-	aliceAccount, err := s.as.GetAccountByID(alice.ID)
+	aliceAccount, err := s.as.GetAccountByID(context.Background(), alice.ID)
 	s.Require().NoError(err)
-	bobAccount, err := s.as.GetAccountByID(bob.ID)
+	bobAccount, err := s.as.GetAccountByID(context.Background(), bob.ID)
 	s.Require().NoError(err)
 
 	expectedAliceBalance := 1000 - float64(concurrentTransfers)*transferAmount
@@ -0,0 +1,120 @@
+package statement
+
+import (
+	"bank_test/internal/db/models"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// camt053Namespace is the namespace of the version of camt.053 written by writeCAMT053.
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+type camtGroupHeader struct {
+	MessageID string `xml:"MsgId"`
+	CreatedAt string `xml:"CreDtTm"`
+}
+
+type camtPeriod struct {
+	From string `xml:"FrDtTm"`
+	To   string `xml:"ToDtTm"`
+}
+
+type camtAccount struct {
+	IBAN     string `xml:"Id>IBAN"`
+	Currency string `xml:"Ccy"`
+	Owner    string `xml:"Ownr>Nm"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type   string     `xml:"Tp>CdOrPrtry>Cd"` // OPBD or CLBD
+	Amount camtAmount `xml:"Amt"`
+	Sign   string     `xml:"CdtDbtInd"` // CRDT or DBIT
+	Date   string     `xml:"Dt>DtTm"`
+}
+
+type camtTotals struct {
+	Entries       int    `xml:"TtlNtries>NbOfNtries"`
+	Credits       int    `xml:"TtlCdtNtries>NbOfNtries"`
+	CreditsAmount string `xml:"TtlCdtNtries>Sum"`
+	Debits        int    `xml:"TtlDbtNtries>NbOfNtries"`
+	DebitsAmount  string `xml:"TtlDbtNtries>Sum"`
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	Sign        string     `xml:"CdtDbtInd"`
+	Status      string     `xml:"Sts"`
+	Booked      string     `xml:"BookgDt>DtTm"`
+	Value       string     `xml:"ValDt>DtTm"`
+	ServicerRef string     `xml:"AcctSvcrRef"`
+	Code        string     `xml:"BkTxCd>Prtry>Cd"` // deposit or withdrawal
+}
+
+// writeCAMT053 writes the statement as an ISO 20022 camt.053.001.02 bank-to-customer statement. The balances and
+// the totals precede the entries, as required by the schema, which is why they are computed when the statement is
+// built.
+func writeCAMT053(w io.Writer, s *Statement) error {
+	x := newXMLWriter(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	x.start("Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace})
+	x.start("BkToCstmrStmt")
+	x.element("GrpHdr", camtGroupHeader{MessageID: s.ID, CreatedAt: camtTime(s.GeneratedAt)})
+
+	x.start("Stmt")
+	x.element("Id", s.ID)
+	x.element("CreDtTm", camtTime(s.GeneratedAt))
+	x.element("FrToDt", camtPeriod{From: camtTime(s.From), To: camtTime(s.To)})
+	x.element("Acct", camtAccount{IBAN: s.Account.IBAN, Currency: s.Currency, Owner: s.Account.Owner})
+	x.element("Bal", camtBalance{Type: "OPBD", Amount: camtAmount{Currency: s.Currency, Value: formatAmount(s.OpeningBalance)}, Sign: camtSign(s.OpeningBalance), Date: camtTime(s.From)})
+	x.element("Bal", camtBalance{Type: "CLBD", Amount: camtAmount{Currency: s.Currency, Value: formatAmount(s.ClosingBalance)}, Sign: camtSign(s.ClosingBalance), Date: camtTime(s.To)})
+	x.element("TxsSummry", camtTotals{
+		Entries:       s.Entries,
+		Credits:       s.Credits,
+		CreditsAmount: formatAmount(s.CreditsAmount),
+		Debits:        s.Debits,
+		DebitsAmount:  formatAmount(s.DebitsAmount),
+	})
+
+	err := s.eachTransaction(func(t models.Transaction) error {
+		amount := signedAmount(t)
+		booked := camtTime(t.Timestamp)
+		x.element("Ntry", camtEntry{
+			Reference:   t.ID,
+			Amount:      camtAmount{Currency: s.Currency, Value: formatAmount(amount)},
+			Sign:        camtSign(amount),
+			Status:      "BOOK",
+			Booked:      booked,
+			Value:       booked,
+			ServicerRef: t.ID,
+			Code:        t.Type.String(),
+		})
+		return x.err
+	})
+	if err != nil {
+		return err
+	}
+
+	x.end("Stmt")
+	x.end("BkToCstmrStmt")
+	x.end("Document")
+	return x.close()
+}
+
+// camtTime formats a time as an ISO 20022 date and time, in UTC.
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// camtSign returns the credit or debit indicator of an amount.
+func camtSign(v float64) string {
+	if v < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
@@ -0,0 +1,48 @@
+package statement
+
+import (
+	"bank_test/internal/db/models"
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+// csvColumns are the columns of a CSV statement. The first and last rows are the opening and closing balances, and
+// every transaction row carries the balance after it.
+var csvColumns = []string{"date", "transaction_id", "type", "description", "amount", "currency", "balance"}
+
+// writeCSV writes the statement as CSV. Withdrawals have negative amounts.
+func writeCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	if err := cw.Write([]string{s.From.Format(time.RFC3339), "", "opening_balance", "Opening balance", "", s.Currency, signed(s.OpeningBalance)}); err != nil {
+		return err
+	}
+
+	balance := s.OpeningBalance
+	err := s.eachTransaction(func(t models.Transaction) error {
+		amount := signedAmount(t)
+		balance += amount
+		row := []string{t.Timestamp.UTC().Format(time.RFC3339), t.ID, t.Type.String(), description(t), signed(amount), s.Currency, signed(balance)}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := cw.Write([]string{s.To.Format(time.RFC3339), "", "closing_balance", "Closing balance", "", s.Currency, signed(s.ClosingBalance)}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// signed formats an amount with two decimals and its sign. Amounts rounded to zero have no sign.
+func signed(v float64) string {
+	if formatted := formatAmount(v); v < 0 && formatted != "0.00" {
+		return "-" + formatted
+	}
+	return formatAmount(v)
+}
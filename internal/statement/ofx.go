@@ -0,0 +1,101 @@
+package statement
+
+import (
+	"bank_test/internal/db/models"
+	"io"
+	"time"
+)
+
+// ofxTimeFormat is the format of the dates of OFX, always in UTC.
+const ofxTimeFormat = "20060102150405.000[0:GMT]"
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	Status   ofxStatus `xml:"STATUS"`
+	Server   string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxAccount struct {
+	BankID string `xml:"BANKID"`
+	ID     string `xml:"ACCTID"`
+	Type   string `xml:"ACCTTYPE"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"` // CREDIT or DEBIT
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"` // negative for debits
+	ID     string `xml:"FITID"`
+	Name   string `xml:"NAME"`
+}
+
+type ofxLedgerBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+type ofxBalance struct {
+	Name  string `xml:"NAME"`
+	Desc  string `xml:"DESC"`
+	Type  string `xml:"BALTYPE"`
+	Value string `xml:"VALUE"`
+	AsOf  string `xml:"DTASOF"`
+}
+
+// writeOFX writes the statement as an OFX 2.2 bank statement response. OFX has no opening balance: the closing
+// balance is the ledger balance, and the opening balance is added to the list of balances of the statement.
+func writeOFX(w io.Writer, s *Statement) error {
+	x := newXMLWriter(w,
+		`<?xml version="1.0" encoding="UTF-8" standalone="no"?>`,
+		`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`)
+	x.start("OFX")
+
+	x.start("SIGNONMSGSRSV1")
+	x.element("SONRS", ofxSignOn{Status: ofxStatus{Code: 0, Severity: "INFO"}, Server: ofxTime(s.GeneratedAt), Language: "ENG"})
+	x.end("SIGNONMSGSRSV1")
+
+	x.start("BANKMSGSRSV1")
+	x.start("STMTTRNRS")
+	x.element("TRNUID", s.ID)
+	x.element("STATUS", ofxStatus{Code: 0, Severity: "INFO"})
+	x.start("STMTRS")
+	x.element("CURDEF", s.Currency)
+	x.element("BANKACCTFROM", ofxAccount{BankID: s.BankID, ID: s.Account.IBAN, Type: "CHECKING"})
+
+	x.start("BANKTRANLIST")
+	x.element("DTSTART", ofxTime(s.From))
+	x.element("DTEND", ofxTime(s.To))
+	err := s.eachTransaction(func(t models.Transaction) error {
+		trnType := "CREDIT"
+		if signedAmount(t) < 0 {
+			trnType = "DEBIT"
+		}
+		x.element("STMTTRN", ofxTransaction{Type: trnType, Posted: ofxTime(t.Timestamp), Amount: signed(signedAmount(t)), ID: t.ID, Name: description(t)})
+		return x.err
+	})
+	if err != nil {
+		return err
+	}
+	x.end("BANKTRANLIST")
+
+	x.element("LEDGERBAL", ofxLedgerBalance{Amount: signed(s.ClosingBalance), AsOf: ofxTime(s.To)})
+	x.start("BALLIST")
+	x.element("BAL", ofxBalance{Name: "Opening balance", Desc: "Balance at the start of the statement", Type: "DOLLAR", Value: signed(s.OpeningBalance), AsOf: ofxTime(s.From)})
+	x.end("BALLIST")
+
+	x.end("STMTRS")
+	x.end("STMTTRNRS")
+	x.end("BANKMSGSRSV1")
+	x.end("OFX")
+	return x.close()
+}
+
+// ofxTime formats a time as an OFX date.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format(ofxTimeFormat)
+}
@@ -0,0 +1,172 @@
+// Package statement renders the statements of the accounts in the formats imported by accounting software: CSV,
+// OFX and the ISO 20022 camt.053 bank-to-customer statement.
+package statement
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	stderrors "errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scan calls fn for every transaction of an account in the period [from, to), in the order they were stored, and
+// stops at the first error. A zero from or to leaves the period open.
+type Scan func(from, to time.Time, fn func(models.Transaction) error) error
+
+// errEnoughEntries stops the scan of the transactions once every entry of the statement was written.
+var errEnoughEntries = stderrors.New("every entry of the statement was written")
+
+// Statement is the statement of an account over a period. Its transactions are not held in memory: they are read
+// again with its scan when the statement is written.
+type Statement struct {
+	ID             string
+	Account        models.Account
+	Currency       string
+	BankID         string
+	From           time.Time // start of the period, included
+	To             time.Time // end of the period, excluded
+	OpeningBalance float64   // balance at the start of the period
+	ClosingBalance float64   // balance at the end of the period
+	Entries        int       // number of transactions of the period
+	Credits        int       // number of deposits of the period
+	CreditsAmount  float64   // sum of the deposits of the period
+	Debits         int       // number of withdrawals of the period
+	DebitsAmount   float64   // sum of the withdrawals of the period
+	GeneratedAt    time.Time
+
+	scan Scan
+}
+
+// Renderer renders the statements of the accounts of the bank.
+type Renderer struct {
+	currency string
+	bankID   string
+
+	now   func() time.Time
+	newID func() string
+}
+
+// NewRenderer creates a new renderer of the statements of the accounts held in the currency by the bank.
+func NewRenderer(currency string, bankID string) *Renderer {
+	return &Renderer{
+		currency: currency,
+		bankID:   bankID,
+		now:      func() time.Time { return time.Now().UTC() },
+		newID:    uuid.NewString,
+	}
+}
+
+// Build computes the balances and the totals of the statement of the account for the period [from, to), reading its
+// transactions with scan. A zero from starts the period at the first transaction of the account and a zero to ends it
+// now. The transactions before the period are read to compute the opening balance.
+//
+// The transactions of the period are read again when the statement is written, so that large histories are streamed
+// instead of being held in memory.
+func (r *Renderer) Build(account models.Account, scan Scan, from, to time.Time) (*Statement, error) {
+	s := &Statement{
+		ID:             r.newID(),
+		Account:        account,
+		Currency:       r.currency,
+		BankID:         r.bankID,
+		From:           from.UTC(),
+		To:             to.UTC(),
+		OpeningBalance: account.InitialBalance,
+		GeneratedAt:    r.now(),
+		scan:           scan,
+	}
+	if to.IsZero() {
+		s.To = s.GeneratedAt
+	}
+
+	if !from.IsZero() {
+		err := scan(time.Time{}, s.From, func(t models.Transaction) error {
+			s.OpeningBalance += signedAmount(t)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	first := s.To
+	err := scan(from, s.To, func(t models.Transaction) error {
+		if t.Timestamp.Before(first) {
+			first = t.Timestamp.UTC()
+		}
+		s.Entries++
+		if amount := signedAmount(t); amount < 0 {
+			s.Debits++
+			s.DebitsAmount -= amount
+		} else {
+			s.Credits++
+			s.CreditsAmount += amount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		s.From = first
+	}
+	s.ClosingBalance = s.OpeningBalance + s.CreditsAmount - s.DebitsAmount
+	return s, nil
+}
+
+// Write writes the statement in the format. The statement is written as its transactions are read.
+func (s *Statement) Write(w io.Writer, format enum.StatementFormat) error {
+	switch format {
+	case enum.CSVStatement:
+		return writeCSV(w, s)
+	case enum.OFXStatement:
+		return writeOFX(w, s)
+	case enum.CAMT053Statement:
+		return writeCAMT053(w, s)
+	default:
+		return errors.ErrInvalidStatementFormat
+	}
+}
+
+// eachTransaction calls fn for every transaction of the period, in the order they were stored. Only the transactions
+// counted when the statement was built are read, so that the transactions stored since are left out.
+func (s *Statement) eachTransaction(fn func(models.Transaction) error) error {
+	written := 0
+	err := s.scan(s.From, s.To, func(t models.Transaction) error {
+		if written == s.Entries {
+			return errEnoughEntries
+		}
+		written++
+		return fn(t)
+	})
+	if stderrors.Is(err, errEnoughEntries) {
+		return nil
+	}
+	return err
+}
+
+// signedAmount returns the amount of the transaction, negative for withdrawals.
+func signedAmount(t models.Transaction) float64 {
+	if t.Type == enum.Withdrawal {
+		return -t.Amount
+	}
+	return t.Amount
+}
+
+// formatAmount formats an amount with two decimals. The sign is left to the caller.
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(math.Abs(v), 'f', 2, 64)
+}
+
+// description is the human-readable description of a transaction.
+func description(t models.Transaction) string {
+	if t.Type == enum.Withdrawal {
+		return "Withdrawal"
+	}
+	return "Deposit"
+}
@@ -0,0 +1,176 @@
+package statement
+
+import (
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// update rewrites the golden files with the output of the tests: go test ./internal/statement -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// Define the test suite
+type StatementTestSuite struct {
+	suite.Suite
+	renderer     *Renderer
+	account      models.Account
+	transactions []models.Transaction
+}
+
+func (s *StatementTestSuite) SetupTest() {
+	s.renderer = NewRenderer("EUR", "01820001")
+	s.renderer.now = func() time.Time { return time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC) }
+	s.renderer.newID = func() string { return "5c0f6a8e-7f4d-4a8b-9d43-2f1f3c1e8b21" }
+
+	s.account = models.Account{
+		ID:             "0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11",
+		IBAN:           "ES9101820001690123456789",
+		Owner:          "Alice & Co",
+		Balance:        171.65,
+		InitialBalance: 100,
+	}
+	day := func(d, h int) time.Time { return time.Date(2026, 1, d, h, 0, 0, 0, time.UTC) }
+	s.transactions = []models.Transaction{
+		{ID: "7a3e6a52-0b7c-4a8e-8a3b-5b1b7c0e9d01", AccountID: s.account.ID, Type: enum.Deposit, Amount: 50.25, Timestamp: day(5, 10)},
+		{ID: "2f9c1b3d-4e5f-4a6b-8c7d-9e0f1a2b3c02", AccountID: s.account.ID, Type: enum.Withdrawal, Amount: 20.1, Timestamp: day(12, 16)},
+		{ID: "b4c5d6e7-f8a9-4b0c-9d1e-2f3a4b5c6d03", AccountID: s.account.ID, Type: enum.Deposit, Amount: 100, Timestamp: day(20, 8)},
+		{ID: "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e04", AccountID: s.account.ID, Type: enum.Withdrawal, Amount: 58.5, Timestamp: day(31, 23)},
+	}
+}
+
+// assertGolden compares the output with the golden file, or rewrites the file if -update is set.
+func (s *StatementTestSuite) assertGolden(name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		s.Require().NoError(os.WriteFile(path, got, 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	s.Require().NoError(err)
+	s.Equal(string(expected), string(got))
+}
+
+// scan reads the transactions of the suite.
+func (s *StatementTestSuite) scan(from, to time.Time, fn func(models.Transaction) error) error {
+	for _, t := range s.transactions {
+		if !t.InPeriod(from, to) {
+			continue
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render builds the statement of the period and writes it in the format.
+func (s *StatementTestSuite) render(w io.Writer, format enum.StatementFormat, from, to time.Time) error {
+	st, err := s.renderer.Build(s.account, s.scan, from, to)
+	if err != nil {
+		return err
+	}
+	return st.Write(w, format)
+}
+
+// TestRender tests rendering a statement in every format.
+func (s *StatementTestSuite) TestRender() {
+	from := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	for format, golden := range map[enum.StatementFormat]string{
+		enum.CSVStatement:     "statement.csv",
+		enum.OFXStatement:     "statement.ofx",
+		enum.CAMT053Statement: "statement.camt053.xml",
+	} {
+		s.Run("ok: "+format.String(), func() {
+			var buf bytes.Buffer
+			s.Require().NoError(s.render(&buf, format, from, to))
+			s.assertGolden(golden, buf.Bytes())
+		})
+	}
+
+	s.Run("ok: empty period", func() {
+		var buf bytes.Buffer
+		s.Require().NoError(s.render(&buf, enum.CAMT053Statement, to, to.Add(time.Hour)))
+		s.assertGolden("empty.camt053.xml", buf.Bytes())
+	})
+
+	s.Run("error: invalid format", func() {
+		err := s.render(&bytes.Buffer{}, "pdf", from, to)
+		s.ErrorContains(err, "invalid statement format")
+	})
+
+	s.Run("error: write failed", func() {
+		err := s.render(failingWriter{}, enum.OFXStatement, from, to)
+		s.Error(err)
+	})
+
+	s.Run("error: read failed", func() {
+		scan := func(from, to time.Time, fn func(models.Transaction) error) error {
+			return errors.New("database closed")
+		}
+		_, err := s.renderer.Build(s.account, scan, from, to)
+		s.ErrorContains(err, "database closed")
+	})
+
+	s.Run("ok: transactions stored after the statement was built", func() {
+		st, err := s.renderer.Build(s.account, s.scan, time.Time{}, to)
+		s.Require().NoError(err)
+		s.transactions = append(s.transactions, models.Transaction{ID: "late", AccountID: s.account.ID, Type: enum.Deposit, Amount: 1, Timestamp: to.Add(-time.Hour)})
+		defer func() { s.transactions = s.transactions[:len(s.transactions)-1] }()
+
+		var buf bytes.Buffer
+		s.Require().NoError(st.Write(&buf, enum.CSVStatement))
+		s.NotContains(buf.String(), "late")
+	})
+}
+
+// TestBalances tests the opening and closing balances of the statements.
+func (s *StatementTestSuite) TestBalances() {
+	tests := []struct {
+		name         string
+		from, to     time.Time
+		opening      float64
+		closing      float64
+		transactions int
+	}{
+		{name: "ok: whole history", opening: 100, closing: 171.65, transactions: 4},
+		{name: "ok: from", from: time.Date(2026, 1, 12, 16, 0, 0, 0, time.UTC), opening: 150.25, closing: 171.65, transactions: 3},
+		{name: "ok: to is excluded", to: time.Date(2026, 1, 20, 8, 0, 0, 0, time.UTC), opening: 100, closing: 130.15, transactions: 2},
+		{name: "ok: before the first transaction", from: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), opening: 100, closing: 100},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			st, err := s.renderer.Build(s.account, s.scan, tt.from, tt.to)
+			s.Require().NoError(err)
+			s.InDelta(tt.opening, st.OpeningBalance, 1e-9)
+			s.InDelta(tt.closing, st.ClosingBalance, 1e-9)
+			s.Equal(tt.transactions, st.Entries)
+			if tt.from.IsZero() {
+				s.Equal(s.transactions[0].Timestamp, st.From)
+			}
+			if tt.to.IsZero() {
+				s.Equal(s.renderer.now(), st.To)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection closed")
+}
+
+func TestStatementTestSuite(t *testing.T) {
+	suite.Run(t, new(StatementTestSuite))
+}
@@ -0,0 +1,63 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// xmlWriter writes an XML document element by element, so that it is streamed. The first error is kept and every
+// later call is ignored.
+type xmlWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+	err error
+}
+
+// newXMLWriter creates a writer of an indented XML document that starts with the prolog, one declaration per line.
+func newXMLWriter(w io.Writer, prolog ...string) *xmlWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	x := &xmlWriter{w: w, enc: enc}
+	for _, line := range prolog {
+		if x.err == nil {
+			_, x.err = io.WriteString(w, line+"\n")
+		}
+	}
+	return x
+}
+
+// token writes a token.
+func (x *xmlWriter) token(t xml.Token) {
+	if x.err == nil {
+		x.err = x.enc.EncodeToken(t)
+	}
+}
+
+// start opens an element.
+func (x *xmlWriter) start(name string, attrs ...xml.Attr) {
+	x.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+// end closes an element.
+func (x *xmlWriter) end(name string) {
+	x.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// element writes an element with the value as its content.
+func (x *xmlWriter) element(name string, v any) {
+	if x.err == nil {
+		x.err = x.enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+// close flushes the document and returns the first error.
+func (x *xmlWriter) close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if err := x.enc.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
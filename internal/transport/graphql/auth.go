@@ -0,0 +1,69 @@
+package graphql
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// authenticate is a middleware that authenticates the api key or the jwt sent in the Authorization header of the
+// request, and stores its principal in the context. The principal replaces the X-Actor header in the audit metadata.
+// Requests without credentials continue anonymously, and their operations are rejected by the resolvers; requests
+// with invalid credentials are rejected. Subscriptions are authenticated once, when the connection is upgraded. It does
+// nothing when authentication is disabled.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if !conf.GlobalConfig.AuthEnabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := audit.MetadataFrom(r.Context())
+		meta.Actor = ""
+
+		ctx := r.Context()
+		if token, ok := bearerToken(r); ok {
+			p, err := s.auth.Authenticate(token)
+			if err != nil {
+				apiError, ok := err.(*errors.APIError)
+				if !ok {
+					apiError = errors.ErrUnauthenticated
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="bank"`)
+				render.Status(r, apiError.HTTPStatus)
+				render.JSON(w, r, errorResponse(apiError))
+				return
+			}
+			ctx = auth.WithPrincipal(ctx, p)
+			meta.Actor = p.Actor()
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithMetadata(ctx, meta)))
+	})
+}
+
+// authorize checks that the caller of the operation is authorized to perform op, following the same policy as the
+// HTTP routes. When authentication is enabled, anonymous callers are rejected.
+func authorize(ctx context.Context, op auth.Operation) error {
+	if !conf.GlobalConfig.AuthEnabled {
+		return nil
+	}
+	if _, ok := auth.PrincipalFrom(ctx); !ok {
+		return errors.ErrUnauthenticated
+	}
+	return auth.Authorize(ctx, op)
+}
+
+// bearerToken returns the token of the Authorization header of the request, if it uses the Bearer scheme.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,254 @@
+package graphql
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/auth/authtest"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+const adminKey = "admin-key-of-the-configuration-0123456789"
+
+// Define the test suite
+type AuthTestSuite struct {
+	suite.Suite
+	auth     *auth.Authenticator
+	auditLog *audit.Log
+	issuer   *authtest.Issuer
+	server   *httptest.Server
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	s.T().Setenv("AUTH_ENABLED", "true")
+	s.T().Setenv("ADMIN_API_KEY", adminKey)
+	s.T().Setenv("API_KEYS_PATH", "")
+
+	var err error
+	s.issuer, err = authtest.NewIssuer()
+	s.Require().NoError(err)
+	jwks := filepath.Join(s.T().TempDir(), "jwks.json")
+	s.Require().NoError(os.WriteFile(jwks, s.issuer.JWKS(), 0o600))
+	s.T().Setenv("JWKS", jwks)
+	s.Require().NoError(conf.SetupConfig())
+
+	s.auth, err = auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	s.auditLog, err = audit.NewLog(logger, "")
+	s.Require().NoError(err)
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	database := activity.NewDatabase(logger, audit.NewDatabase(logger, memory.NewInMemoryDatabase(logger), s.auditLog), feed)
+
+	srv, err := newServer(logger, service.NewAccountService(logger, database, ibans), service.NewTransactionService(logger, database), feed, s.auth, time.Second, nil)
+	s.Require().NoError(err)
+	s.server = httptest.NewServer(srv.routes())
+}
+
+func (s *AuthTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// exec sends a GraphQL request to the test server with the token, if any, and returns its status and response.
+func (s *AuthTestSuite) exec(token, query string, variables map[string]any) (int, response) {
+	body, err := json.Marshal(request{Query: query, Variables: variables})
+	s.Require().NoError(err)
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+"/graphql", bytes.NewReader(body))
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(actorHeader, "spoofed")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+
+	var r response
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	return resp.StatusCode, r
+}
+
+// assertError checks that the response failed with the API error.
+func (s *AuthTestSuite) assertError(r response, apiError *errors.APIError) {
+	s.Require().Len(r.Errors, 1)
+	s.Equal(apiError.Code, r.Errors[0].Extensions["code"])
+}
+
+// createKey creates an api key of the role granted the scopes.
+func (s *AuthTestSuite) createKey(role enum.Role, scopes ...enum.Scope) (*auth.APIKey, string) {
+	key, token, err := s.auth.Keys().Create("test", role, scopes)
+	s.Require().NoError(err)
+	return key, token
+}
+
+// createAccount creates an account of the owner with the token.
+func (s *AuthTestSuite) createAccount(token, owner string) account {
+	_, r := s.exec(token, `mutation($owner: String!, $balance: Float!) {
+		createAccount(input: {owner: $owner, initialBalance: $balance}) { id iban owner balance }
+	}`, map[string]any{"owner": owner, "balance": 0})
+	s.Require().Empty(r.Errors)
+	var acc account
+	s.Require().NoError(json.Unmarshal(r.Data["createAccount"], &acc))
+	return acc
+}
+
+// TestAuthentication tests rejecting the requests without valid credentials.
+func (s *AuthTestSuite) TestAuthentication() {
+	s.Run("error: no key", func() {
+		status, r := s.exec("", `{ accounts { totalCount } }`, nil)
+		s.Equal(http.StatusOK, status)
+		s.assertError(r, errors.ErrUnauthenticated)
+	})
+
+	s.Run("error: unknown key", func() {
+		status, r := s.exec("bank_unknown", `{ accounts { totalCount } }`, nil)
+		s.Equal(http.StatusUnauthorized, status)
+		s.assertError(r, errors.ErrUnauthenticated)
+	})
+
+	s.Run("error: subscription without key", func() {
+		dialer := websocket.Dialer{Subprotocols: []string{subprotocol}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(s.server.URL, "http")+"/graphql", nil)
+		s.Require().NoError(err)
+		defer conn.Close()
+		s.Require().NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		s.Require().NoError(conn.WriteJSON(message{Type: connectionInitMessage}))
+		var msg message
+		s.Require().NoError(conn.ReadJSON(&msg))
+		payload, err := json.Marshal(request{Query: `subscription { transactionCreated(accountIds: ["7b1b3ba8-9d4f-4b1a-9d9e-111111111111"]) { id } }`})
+		s.Require().NoError(err)
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: subscribeMessage, Payload: payload}))
+		s.Require().NoError(conn.ReadJSON(&msg))
+		s.Equal(errorMessage, msg.Type)
+		s.Contains(string(msg.Payload), errors.ErrUnauthenticated.Code)
+	})
+}
+
+// TestAuthorization tests following the policy of the role and the scopes of the key.
+func (s *AuthTestSuite) TestAuthorization() {
+	acc := s.createAccount(adminKey, "Alice")
+	_, teller := s.createKey(enum.TellerRole, enum.AccountsRead, enum.AccountsWrite, enum.TransactionsWrite)
+	_, auditor := s.createKey(enum.AuditorRole, enum.AccountsRead, enum.AccountsWrite)
+
+	transaction := `mutation($id: ID!, $type: TransactionType!, $amount: Float!) {
+		createTransaction(input: {accountId: $id, type: $type, amount: $amount}) { id }
+	}`
+
+	s.Run("ok: teller deposits", func() {
+		_, r := s.exec(teller, transaction, map[string]any{"id": acc.ID, "type": "DEPOSIT", "amount": 10})
+		s.Empty(r.Errors)
+	})
+
+	s.Run("error: teller withdraws", func() {
+		_, r := s.exec(teller, transaction, map[string]any{"id": acc.ID, "type": "WITHDRAWAL", "amount": 10})
+		s.assertError(r, errors.ErrForbidden)
+	})
+
+	s.Run("error: teller transfers", func() {
+		_, r := s.exec(teller, `mutation($from: String!, $to: String!, $amount: Float!) {
+			transfer(input: {from: $from, to: $to, amount: $amount}) { from { id } }
+		}`, map[string]any{"from": acc.ID, "to": acc.Iban, "amount": 1})
+		s.assertError(r, errors.ErrForbidden)
+	})
+
+	s.Run("error: auditor opens an account", func() {
+		_, r := s.exec(auditor, `mutation($balance: Float!) { createAccount(input: {owner: "Bob", initialBalance: $balance}) { id } }`, map[string]any{"balance": 0})
+		s.assertError(r, errors.ErrForbidden)
+	})
+
+	s.Run("error: insufficient scope", func() {
+		_, r := s.exec(teller, `query($id: ID!) { transactions(accountId: $id) { totalCount } }`, map[string]any{"id": acc.ID})
+		s.assertError(r, errors.ErrInsufficientScope)
+	})
+}
+
+// TestCustomers tests restricting the customers authenticated with a jwt to the accounts of their owner.
+func (s *AuthTestSuite) TestCustomers() {
+	own := s.createAccount(adminKey, "Alice")
+	other := s.createAccount(adminKey, "Bob")
+	token, err := s.issuer.CustomerToken("Alice")
+	s.Require().NoError(err)
+	_, r := s.exec(adminKey, `mutation($id: ID!, $amount: Float!) {
+		createTransaction(input: {accountId: $id, type: DEPOSIT, amount: $amount}) { id }
+	}`, map[string]any{"id": own.ID, "amount": 100})
+	s.Require().Empty(r.Errors)
+
+	s.Run("ok: list own accounts", func() {
+		_, r := s.exec(token, `{ accounts { nodes { id } } }`, nil)
+		s.Require().Empty(r.Errors)
+		s.JSONEq(`{"nodes": [{"id": "`+own.ID+`"}]}`, string(r.Data["accounts"]))
+	})
+
+	s.Run("error: account of another owner", func() {
+		_, r := s.exec(token, `query($iban: String!) { accountByIban(iban: $iban) { id } }`, map[string]any{"iban": other.Iban})
+		s.assertError(r, errors.ErrAccountAccessDenied)
+	})
+
+	s.Run("ok: transfer to another owner", func() {
+		_, r := s.exec(token, `mutation($from: String!, $to: String!, $amount: Float!) {
+			transfer(input: {from: $from, to: $to, amount: $amount}) { from { balance } to { balance } }
+		}`, map[string]any{"from": own.ID, "to": other.Iban, "amount": 10})
+		s.Require().Empty(r.Errors)
+		s.JSONEq(`{"from": {"balance": 90}, "to": null}`, string(r.Data["transfer"]))
+	})
+
+	s.Run("error: subscription to another owner", func() {
+		dialer := websocket.Dialer{Subprotocols: []string{subprotocol}}
+		header := http.Header{"Authorization": {"Bearer " + token}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(s.server.URL, "http")+"/graphql", header)
+		s.Require().NoError(err)
+		defer conn.Close()
+		s.Require().NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		s.Require().NoError(conn.WriteJSON(message{Type: connectionInitMessage}))
+		var msg message
+		s.Require().NoError(conn.ReadJSON(&msg))
+		payload, err := json.Marshal(request{
+			Query:     `subscription($ids: [ID!]!) { transactionCreated(accountIds: $ids) { id } }`,
+			Variables: map[string]any{"ids": []string{own.ID, other.ID}},
+		})
+		s.Require().NoError(err)
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: subscribeMessage, Payload: payload}))
+		s.Require().NoError(conn.ReadJSON(&msg))
+		s.Equal(errorMessage, msg.Type)
+		s.Contains(string(msg.Payload), errors.ErrAccountAccessDenied.Code)
+	})
+}
+
+// TestActor tests recording the api key, rather than the X-Actor header, as the actor of the audited mutations.
+func (s *AuthTestSuite) TestActor() {
+	key, token := s.createKey(enum.AdminRole, enum.AccountsWrite)
+	s.createAccount(token, "Alice")
+
+	records := s.auditLog.List(audit.Filter{Action: audit.CreateAccount})
+	s.Require().Len(records, 1)
+	s.Equal("apikey:"+key.ID, records[0].Actor)
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
@@ -0,0 +1,39 @@
+package graphql
+
+import (
+	errors "bank_test/internal/api_errors"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// queryError is an API error returned by a resolver. Its code is reported in the extensions of the GraphQL error, so
+// that clients can tell the errors apart.
+type queryError struct {
+	*errors.APIError
+}
+
+// Extensions returns the extensions of the GraphQL error.
+func (e *queryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// toQueryError converts an error returned by the services into a GraphQL error. Errors caused by the context are
+// reported as timeouts or cancellations, and other errors that are not API errors are reported as unknown errors.
+func toQueryError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	apiError, ok := errors.FromContext(err).(*errors.APIError)
+	if !ok {
+		apiError = errors.ErrUnknown
+	}
+	return &queryError{APIError: apiError}
+}
+
+// errorResponse creates the response of a request that fails before its operation is executed.
+func errorResponse(err *errors.APIError) *graphql.Response {
+	e := &queryError{APIError: err}
+	return &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: e.Message, Extensions: e.Extensions()}}}
+}
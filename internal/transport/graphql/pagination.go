@@ -0,0 +1,184 @@
+package graphql
+
+import (
+	errors "bank_test/internal/api_errors"
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// maxPageSize is the maximum number of items of a page. It is also the size of the pages when neither first nor
+	// last are given.
+	maxPageSize = 100
+
+	// cursorPrefix is prepended to the keys before encoding them as cursors.
+	cursorPrefix = "cursor:"
+)
+
+// pageArgs are the arguments of the paginated fields, as defined by the Relay cursor connections specification.
+type pageArgs struct {
+	First  *int32
+	After  *string
+	Last   *int32
+	Before *string
+}
+
+// page holds the bounds of a page in a list: the items from start (inclusive) to end (exclusive).
+type page struct {
+	start, end, total int
+}
+
+// paginate computes the page of a list selected by the arguments. keys holds the key of every item of the list, in
+// order, which is encoded in its cursor. The items after the 'after' cursor and before the 'before' cursor are
+// selected first, and then the first or the last ones of them are kept.
+func paginate(keys []string, args pageArgs) (page, error) {
+	p := page{start: 0, end: len(keys), total: len(keys)}
+
+	if args.After != nil {
+		i, err := indexOfCursor(keys, *args.After)
+		if err != nil {
+			return page{}, err
+		}
+		p.start = i + 1
+	}
+	if args.Before != nil {
+		i, err := indexOfCursor(keys, *args.Before)
+		if err != nil {
+			return page{}, err
+		}
+		p.end = i
+	}
+	if p.end < p.start {
+		p.end = p.start
+	}
+
+	for _, size := range []*int32{args.First, args.Last} {
+		if size != nil && (*size < 0 || *size > maxPageSize) {
+			return page{}, errors.ErrInvalidPageSize
+		}
+	}
+	if args.First != nil && p.end-p.start > int(*args.First) {
+		p.end = p.start + int(*args.First)
+	}
+	if args.Last != nil && p.end-p.start > int(*args.Last) {
+		p.start = p.end - int(*args.Last)
+	}
+	if args.First == nil && args.Last == nil && p.end-p.start > maxPageSize {
+		p.end = p.start + maxPageSize
+	}
+	return p, nil
+}
+
+// indexOfCursor returns the index of the item the cursor points to.
+func indexOfCursor(keys []string, cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, errors.ErrInvalidCursor
+	}
+
+	key := strings.TrimPrefix(string(decoded), cursorPrefix)
+	for i := range keys {
+		if keys[i] == key {
+			return i, nil
+		}
+	}
+	return 0, errors.ErrInvalidCursor
+}
+
+// encodeCursor encodes the key of an item as an opaque cursor.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + key))
+}
+
+// connection is a page of a list, as defined by the Relay cursor connections specification.
+type connection[T any] struct {
+	edges    []*edge[T]
+	pageInfo *pageInfo
+	total    int
+}
+
+// newConnection creates the connection of a page. nodes holds the items of the page, and keys the keys of every item
+// of the list.
+func newConnection[T any](p page, keys []string, nodes []T) *connection[T] {
+	c := &connection[T]{
+		edges:    make([]*edge[T], 0, len(nodes)),
+		pageInfo: &pageInfo{hasPreviousPage: p.start > 0, hasNextPage: p.end < p.total},
+		total:    p.total,
+	}
+	for i, node := range nodes {
+		c.edges = append(c.edges, &edge[T]{cursor: encodeCursor(keys[p.start+i]), node: node})
+	}
+	if len(c.edges) > 0 {
+		c.pageInfo.startCursor = &c.edges[0].cursor
+		c.pageInfo.endCursor = &c.edges[len(c.edges)-1].cursor
+	}
+	return c
+}
+
+// Edges returns the items of the page with their cursors.
+func (c *connection[T]) Edges() []*edge[T] {
+	return c.edges
+}
+
+// Nodes returns the items of the page.
+func (c *connection[T]) Nodes() []T {
+	nodes := make([]T, 0, len(c.edges))
+	for _, e := range c.edges {
+		nodes = append(nodes, e.node)
+	}
+	return nodes
+}
+
+// PageInfo returns the position of the page in the list.
+func (c *connection[T]) PageInfo() *pageInfo {
+	return c.pageInfo
+}
+
+// TotalCount returns the number of items of the list.
+func (c *connection[T]) TotalCount() int32 {
+	return int32(c.total)
+}
+
+// edge is an item of a page with its cursor.
+type edge[T any] struct {
+	cursor string
+	node   T
+}
+
+// Cursor returns the cursor of the item.
+func (e *edge[T]) Cursor() string {
+	return e.cursor
+}
+
+// Node returns the item.
+func (e *edge[T]) Node() T {
+	return e.node
+}
+
+// pageInfo is the position of a page in a list.
+type pageInfo struct {
+	hasNextPage     bool
+	hasPreviousPage bool
+	startCursor     *string
+	endCursor       *string
+}
+
+// HasNextPage reports whether there are items after the page.
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+// HasPreviousPage reports whether there are items before the page.
+func (p *pageInfo) HasPreviousPage() bool {
+	return p.hasPreviousPage
+}
+
+// StartCursor returns the cursor of the first item of the page, or nil if the page is empty.
+func (p *pageInfo) StartCursor() *string {
+	return p.startCursor
+}
+
+// EndCursor returns the cursor of the last item of the page, or nil if the page is empty.
+func (p *pageInfo) EndCursor() *string {
+	return p.endCursor
+}
@@ -0,0 +1,406 @@
+package graphql
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+// resolver is the root resolver of the schema. It resolves the queries and the mutations with the account and
+// transaction services, and the subscriptions with the activity feed.
+type resolver struct {
+	logger *zap.SugaredLogger
+	as     service.AccountService
+	ts     service.TransactionService
+	feed   *activity.Feed
+}
+
+// Account retrieves an account by its id.
+func (r *resolver) Account(ctx context.Context, args struct{ ID graphql.ID }) (*accountResolver, error) {
+	if err := authorize(ctx, auth.ReadAccounts); err != nil {
+		return nil, toQueryError(err)
+	}
+	if err := validateAccountID(string(args.ID)); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	acc, err := r.as.GetAccountByID(ctx, string(args.ID))
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return r.newAccountResolvers(*acc)[0], nil
+}
+
+// AccountByIban retrieves an account by its IBAN.
+func (r *resolver) AccountByIban(ctx context.Context, args struct{ Iban string }) (*accountResolver, error) {
+	if err := authorize(ctx, auth.ReadAccounts); err != nil {
+		return nil, toQueryError(err)
+	}
+	if err := iban.Validate(args.Iban); err != nil {
+		return nil, toQueryError(errors.ErrInvalidIBAN)
+	}
+
+	acc, err := r.as.GetAccountByIBAN(ctx, args.Iban)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return r.newAccountResolvers(*acc)[0], nil
+}
+
+// Accounts retrieves a page of the accounts, ordered by id.
+func (r *resolver) Accounts(ctx context.Context, args pageArgs) (*connection[*accountResolver], error) {
+	if err := authorize(ctx, auth.ReadAccounts); err != nil {
+		return nil, toQueryError(err)
+	}
+	accs, err := r.as.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	sort.Slice(accs, func(i, j int) bool { return accs[i].ID < accs[j].ID })
+
+	keys := make([]string, len(accs))
+	for i := range accs {
+		keys[i] = accs[i].ID
+	}
+	p, err := paginate(keys, args)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newConnection(p, keys, r.newAccountResolvers(accs[p.start:p.end]...)), nil
+}
+
+// transactionsArgs are the arguments of the transactions query.
+type transactionsArgs struct {
+	AccountID graphql.ID
+	First     *int32
+	After     *string
+	Last      *int32
+	Before    *string
+}
+
+// Transactions retrieves a page of the transactions of an account.
+func (r *resolver) Transactions(ctx context.Context, args transactionsArgs) (*connection[*transactionResolver], error) {
+	if err := authorize(ctx, auth.ReadTransactions); err != nil {
+		return nil, toQueryError(err)
+	}
+	if err := validateAccountID(string(args.AccountID)); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	txs, err := r.ts.GetTransactionsByAccountID(ctx, string(args.AccountID))
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newTransactionConnection(txs, pageArgs{First: args.First, After: args.After, Last: args.Last, Before: args.Before})
+}
+
+// CreateAccount opens a new account with an initial balance.
+func (r *resolver) CreateAccount(ctx context.Context, args struct {
+	Input struct {
+		Owner          string
+		InitialBalance float64
+	}
+}) (*accountResolver, error) {
+	if err := authorize(ctx, auth.OpenAccount); err != nil {
+		return nil, toQueryError(err)
+	}
+	body := schemas.CreateAccountRequest{Owner: args.Input.Owner, InitialBalance: &args.Input.InitialBalance}
+	if err := binding.Validate(&body); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	acc, err := r.as.CreateAccount(ctx, &body)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return r.newAccountResolvers(*acc)[0], nil
+}
+
+// CreateTransaction deposits money into or withdraws it from an account.
+func (r *resolver) CreateTransaction(ctx context.Context, args struct {
+	Input struct {
+		AccountID graphql.ID
+		Type      string
+		Amount    float64
+	}
+}) (*transactionResolver, error) {
+	if err := validateAccountID(string(args.Input.AccountID)); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	body := schemas.CreateTransactionRequest{Type: strings.ToLower(args.Input.Type), Amount: &args.Input.Amount}
+	if err := binding.Validate(&body); err != nil {
+		return nil, toQueryError(err)
+	}
+	op := auth.Withdraw
+	if body.Type == enum.Deposit.String() {
+		op = auth.Deposit
+	}
+	if err := authorize(ctx, op); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	tx, err := r.ts.CreateTransaction(ctx, string(args.Input.AccountID), &body)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return &transactionResolver{tx: *tx}, nil
+}
+
+// Transfer transfers money from one account to another, and returns both accounts once the transfer is stored.
+// Accounts can be referenced by their id or by their IBAN. The account credited is left out when the caller cannot
+// read it.
+func (r *resolver) Transfer(ctx context.Context, args struct {
+	Input struct {
+		From   string
+		To     string
+		Amount float64
+	}
+}) (*transferResolver, error) {
+	if err := authorize(ctx, auth.Transfer); err != nil {
+		return nil, toQueryError(err)
+	}
+	body := schemas.TransferRequest{FromAccountId: args.Input.From, ToAccountId: args.Input.To, Amount: &args.Input.Amount}
+	if err := binding.Validate(&body); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	if err := r.ts.Transfer(ctx, body.FromAccountId, body.ToAccountId, *body.Amount); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	from, err := r.getAccount(ctx, body.FromAccountId)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	// customers can transfer money to the accounts of other owners, but cannot read them
+	to, err := r.getAccount(ctx, body.ToAccountId)
+	if err == errors.ErrAccountAccessDenied {
+		return &transferResolver{from: r.newAccountResolvers(*from)[0]}, nil
+	}
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	accounts := r.newAccountResolvers(*from, *to)
+	return &transferResolver{from: accounts[0], to: accounts[1]}, nil
+}
+
+// TransactionCreated streams the transactions of the accounts as they are committed, until the client stops the
+// subscription. If the client falls behind and the feed drops one of its subscriptions, the stream is completed, so
+// that the client subscribes again and reloads the transactions it missed.
+func (r *resolver) TransactionCreated(ctx context.Context, args struct{ AccountIDs []graphql.ID }) (<-chan *transactionResolver, error) {
+	if err := authorize(ctx, auth.ReadTransactions); err != nil {
+		return nil, toQueryError(err)
+	}
+	if len(args.AccountIDs) == 0 {
+		return nil, toQueryError(errors.ErrAccountIdIsMissing)
+	}
+	for _, id := range args.AccountIDs {
+		if err := validateAccountID(string(id)); err != nil {
+			return nil, toQueryError(err)
+		}
+	}
+
+	// customers can only subscribe to their own accounts
+	for _, id := range args.AccountIDs {
+		if _, err := r.as.GetAccountByID(ctx, string(id)); err != nil {
+			return nil, toQueryError(err)
+		}
+	}
+
+	subs := make([]*activity.Subscription, 0, len(args.AccountIDs))
+	for _, id := range args.AccountIDs {
+		subs = append(subs, r.feed.Subscribe(string(id), 0))
+	}
+	out := make(chan *transactionResolver)
+	go r.stream(ctx, subs, out)
+	return out, nil
+}
+
+// stream forwards the transactions of the subscriptions to out, and closes it once the context is done or the feed
+// drops one of the subscriptions.
+func (r *resolver) stream(ctx context.Context, subs []*activity.Subscription, out chan<- *transactionResolver) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *activity.Subscription) {
+			defer wg.Done()
+			defer cancel()
+			r.forward(ctx, sub, out)
+		}(sub)
+	}
+	wg.Wait()
+
+	for _, sub := range subs {
+		sub.Cancel()
+	}
+	close(out)
+}
+
+// forward sends the transactions of the subscription to out until the context is done or the feed drops the
+// subscription.
+func (r *resolver) forward(ctx context.Context, sub *activity.Subscription, out chan<- *transactionResolver) {
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				r.logger.Warn("transaction subscription fell behind the feed")
+				return
+			}
+			if e.Type != enum.TransactionActivity {
+				continue
+			}
+			var tx models.Transaction
+			if err := json.Unmarshal(e.Data, &tx); err != nil {
+				r.logger.Errorf("failed to decode transaction event: %v", err)
+				continue
+			}
+			select {
+			case out <- &transactionResolver{tx: tx}:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// getAccount retrieves an account by its id or by its IBAN.
+func (r *resolver) getAccount(ctx context.Context, ref string) (*models.Account, error) {
+	if uuid.Validate(ref) == nil {
+		return r.as.GetAccountByID(ctx, ref)
+	}
+	return r.as.GetAccountByIBAN(ctx, ref)
+}
+
+// newAccountResolvers creates the resolvers of sibling accounts, which share a loader for their transactions.
+func (r *resolver) newAccountResolvers(accs ...models.Account) []*accountResolver {
+	loader := &transactionLoader{ts: r.ts, ids: make([]string, 0, len(accs))}
+	resolvers := make([]*accountResolver, 0, len(accs))
+	for _, acc := range accs {
+		loader.ids = append(loader.ids, acc.ID)
+		resolvers = append(resolvers, &accountResolver{acc: acc, loader: loader})
+	}
+	return resolvers
+}
+
+// transactionLoader loads the transactions of sibling accounts with a single call to the transaction service the
+// first time the transactions of any of them are resolved, so that a page of accounts with their transactions does
+// not query the database once per account.
+type transactionLoader struct {
+	ts  service.TransactionService
+	ids []string
+
+	once sync.Once
+	txs  map[string][]models.Transaction
+	err  error
+}
+
+// load returns the transactions of one of the accounts of the loader.
+func (l *transactionLoader) load(ctx context.Context, id string) ([]models.Transaction, error) {
+	l.once.Do(func() {
+		l.txs, l.err = l.ts.GetTransactionsByAccountIDs(ctx, l.ids)
+	})
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	txs, ok := l.txs[id]
+	if !ok {
+		return nil, errors.ErrAccountNotFound
+	}
+	return txs, nil
+}
+
+// accountResolver resolves the fields of an account.
+type accountResolver struct {
+	acc    models.Account
+	loader *transactionLoader
+}
+
+func (a *accountResolver) ID() graphql.ID          { return graphql.ID(a.acc.ID) }
+func (a *accountResolver) Iban() string            { return a.acc.IBAN }
+func (a *accountResolver) Owner() string           { return a.acc.Owner }
+func (a *accountResolver) Balance() float64        { return a.acc.Balance }
+func (a *accountResolver) InitialBalance() float64 { return a.acc.InitialBalance }
+
+// Transactions retrieves a page of the transactions of the account.
+func (a *accountResolver) Transactions(ctx context.Context, args pageArgs) (*connection[*transactionResolver], error) {
+	if err := authorize(ctx, auth.ReadTransactions); err != nil {
+		return nil, toQueryError(err)
+	}
+	txs, err := a.loader.load(ctx, a.acc.ID)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newTransactionConnection(txs, args)
+}
+
+// transactionResolver resolves the fields of a transaction.
+type transactionResolver struct {
+	tx models.Transaction
+}
+
+func (t *transactionResolver) ID() graphql.ID        { return graphql.ID(t.tx.ID) }
+func (t *transactionResolver) AccountID() graphql.ID { return graphql.ID(t.tx.AccountID) }
+func (t *transactionResolver) Type() string          { return strings.ToUpper(t.tx.Type.String()) }
+func (t *transactionResolver) Amount() float64       { return t.tx.Amount }
+func (t *transactionResolver) Timestamp() graphql.Time {
+	return graphql.Time{Time: t.tx.Timestamp}
+}
+
+// transferResolver resolves the accounts of a transfer.
+type transferResolver struct {
+	from, to *accountResolver
+}
+
+func (t *transferResolver) From() *accountResolver { return t.from }
+func (t *transferResolver) To() *accountResolver   { return t.to }
+
+// newTransactionConnection creates the connection of the page of the transactions selected by the arguments.
+func newTransactionConnection(txs []models.Transaction, args pageArgs) (*connection[*transactionResolver], error) {
+	keys := make([]string, len(txs))
+	for i := range txs {
+		keys[i] = txs[i].ID
+	}
+	p, err := paginate(keys, args)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+
+	nodes := make([]*transactionResolver, 0, p.end-p.start)
+	for _, tx := range txs[p.start:p.end] {
+		nodes = append(nodes, &transactionResolver{tx: tx})
+	}
+	return newConnection(p, keys, nodes), nil
+}
+
+// validateAccountID checks that the account id is present and is a valid uuid.
+func validateAccountID(id string) error {
+	if id == "" {
+		return errors.ErrAccountIdIsMissing
+	}
+	if err := uuid.Validate(id); err != nil {
+		return errors.ErrInvalidAccountID
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package graphql
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/transport/ws"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// actorHeader is the header used by the clients to identify who is performing a request. It is recorded in the
+	// audit log.
+	actorHeader = "X-Actor"
+
+	// maxDepth is the maximum nesting depth of the queries.
+	maxDepth = 10
+)
+
+// schemaDefinition is the GraphQL schema served by the transport.
+//
+//go:embed schema.graphql
+var schemaDefinition string
+
+type graphqlTransport struct {
+	logger *zap.SugaredLogger
+	db     db.DatabaseAdapter
+	feed   *activity.Feed
+	auth   *auth.Authenticator
+	server *http.Server
+	ready  atomic.Bool
+}
+
+func NewGraphqlTransport(logger *zap.SugaredLogger, db db.DatabaseAdapter, feed *activity.Feed, authenticator *auth.Authenticator) *graphqlTransport {
+	return &graphqlTransport{logger: logger, db: db, feed: feed, auth: authenticator}
+}
+
+// Serve is a function that sets up the GraphQL server. It listens on the GraphQL port specified in the configuration.
+// Queries and mutations are sent to POST /graphql, and subscriptions are served over WebSocket on GET /graphql.
+func (g *graphqlTransport) Serve() error {
+	g.logger.Debugf("setting up graphql server")
+	ibans, err := iban.NewGenerator(conf.GlobalConfig.IBANCountryCode, conf.GlobalConfig.IBANBankCode)
+	if err != nil {
+		return g.wrapError(err)
+	}
+
+	as := service.NewAccountService(g.logger, g.db, ibans)
+	ts := service.NewTransactionService(g.logger, g.db)
+	s, err := newServer(g.logger, as, ts, g.feed, g.auth, conf.GlobalConfig.RequestTimeout, conf.GlobalConfig.AllowedOrigins())
+	if err != nil {
+		return g.wrapError(err)
+	}
+	r := s.routes()
+	r.Get("/health", g.health)
+
+	g.server = &http.Server{Addr: fmt.Sprintf(":%s", conf.GlobalConfig.GraphQLPort), Handler: r}
+	g.logger.Infof("graphql server listening on port %s", conf.GlobalConfig.GraphQLPort)
+	if err := g.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return g.wrapError(err)
+	}
+
+	return nil
+}
+
+// HealthCheck marks the server as ready. The health check endpoint is served on the GraphQL port itself, in
+// GET /health, and reports the server as unavailable until it is ready.
+func (g *graphqlTransport) HealthCheck() error {
+	g.logger.Debugf("setting up graphql health check")
+	g.ready.Store(true)
+	return nil
+}
+
+// Close marks the server as not ready and stops it once the pending requests are finished.
+func (g *graphqlTransport) Close() error {
+	g.ready.Store(false)
+	if g.server != nil {
+		return g.server.Shutdown(context.Background())
+	}
+	return nil
+}
+
+// health is the health check endpoint of the GraphQL server.
+func (g *graphqlTransport) health(w http.ResponseWriter, r *http.Request) {
+	g.logger.Debug("health check endpoint called")
+	if !g.ready.Load() {
+		render.Status(r, http.StatusServiceUnavailable)
+		render.JSON(w, r, schemas.HealthResponse{Message: "NOT READY"})
+		return
+	}
+	render.JSON(w, r, schemas.HealthResponse{Message: "OK"})
+}
+
+// wrapError is a helper function that logs the error and returns it
+func (g *graphqlTransport) wrapError(err error) error {
+	g.logger.Error(err)
+	return err
+}
+
+// request is a GraphQL request.
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// server executes the GraphQL requests.
+type server struct {
+	logger   *zap.SugaredLogger
+	schema   *graphql.Schema
+	auth     *auth.Authenticator
+	timeout  time.Duration
+	upgrader websocket.Upgrader
+}
+
+// newServer creates the GraphQL server. When authentication is enabled, the requests are authenticated by the
+// authenticator. Queries and mutations are cancelled once the timeout has elapsed, and subscriptions are accepted from
+// the host itself or from one of the allowed origins.
+func newServer(logger *zap.SugaredLogger, as service.AccountService, ts service.TransactionService, feed *activity.Feed, authenticator *auth.Authenticator, timeout time.Duration, allowedOrigins []string) (*server, error) {
+	schema, err := graphql.ParseSchema(schemaDefinition, &resolver{logger: logger, as: as, ts: ts, feed: feed},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxDepth),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the graphql schema: %v", err)
+	}
+
+	return &server{
+		logger:  logger,
+		schema:  schema,
+		auth:    authenticator,
+		timeout: timeout,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{subprotocol},
+			CheckOrigin:  ws.CheckOrigin(allowedOrigins),
+		},
+	}, nil
+}
+
+// routes returns the router of the GraphQL endpoints.
+func (s *server) routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(auditMetadata)
+	r.Use(s.authenticate)
+
+	r.Post("/graphql", s.query)
+	r.Get("/graphql", s.subscribe)
+	return r
+}
+
+// query is the endpoint that executes queries and mutations. As usual in GraphQL, the errors of the operation are
+// returned in the response body with a 200 status.
+func (s *server) query(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("graphql endpoint called")
+
+	var params request
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		e := *errors.ErrInvalidBody
+		e.Message = fmt.Sprintf("failed to decode request body: %v", err)
+		render.Status(r, e.HTTPStatus)
+		render.JSON(w, r, errorResponse(&e))
+		return
+	}
+
+	ctx := r.Context()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	resp := s.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	render.JSON(w, r, resp)
+}
+
+// subscribe is the endpoint that upgrades the request to a WebSocket connection speaking the graphql-transport-ws
+// protocol, and serves it until it is closed. The connection of an authenticated caller is closed when its
+// credentials expire or are revoked.
+func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("graphql subscription endpoint called")
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered the request
+		s.logger.Errorf("failed to upgrade the connection: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	if p, ok := auth.PrincipalFrom(ctx); ok && s.auth != nil {
+		ctx, cancel = s.auth.Watch(r.Context(), p)
+	}
+	c := &subscriptionConn{s: s, ws: conn, ctx: ctx, operations: make(map[string]context.CancelFunc)}
+	go c.closeOnExpiry()
+	if conn.Subprotocol() != subprotocol {
+		c.close(closeSubprotocolNotAcceptable, "subprotocol not acceptable")
+	} else {
+		c.serve()
+	}
+
+	cancel()
+	c.stopAll()
+	conn.Close()
+	s.logger.Info("graphql subscription connection closed")
+}
+
+// auditMetadata is a middleware that stores the audit metadata of the request (actor and request id) in its
+// context, so that it is recorded with every mutation performed while handling the request.
+func auditMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithMetadata(r.Context(), audit.Metadata{
+			Actor:     r.Header.Get(actorHeader),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,463 @@
+package graphql
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/conf"
+	"bank_test/internal/db"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// countingDatabase counts the transaction lookups made against the database.
+type countingDatabase struct {
+	db.DatabaseAdapter
+	single atomic.Int32
+	batch  atomic.Int32
+}
+
+func (d *countingDatabase) GetTransactionsByAccountID(ctx context.Context, id string) ([]models.Transaction, error) {
+	d.single.Add(1)
+	return d.DatabaseAdapter.GetTransactionsByAccountID(ctx, id)
+}
+
+func (d *countingDatabase) GetTransactionsByAccountIDs(ctx context.Context, ids []string) (map[string][]models.Transaction, error) {
+	d.batch.Add(1)
+	return d.DatabaseAdapter.GetTransactionsByAccountIDs(ctx, ids)
+}
+
+// response is the body of a GraphQL response.
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// Define the test suite
+type ServerTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+
+	db     *countingDatabase
+	server *httptest.Server
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.Require().NoError(conf.SetupConfig())
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(s.logger, 16)
+	s.db = &countingDatabase{DatabaseAdapter: activity.NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), feed)}
+
+	srv, err := newServer(s.logger, service.NewAccountService(s.logger, s.db, ibans), service.NewTransactionService(s.logger, s.db), feed, nil, time.Second, nil)
+	s.Require().NoError(err)
+	s.server = httptest.NewServer(srv.routes())
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// exec sends a GraphQL request to the test server.
+func (s *ServerTestSuite) exec(query string, variables map[string]any) response {
+	body, err := json.Marshal(request{Query: query, Variables: variables})
+	s.Require().NoError(err)
+	resp, err := http.Post(s.server.URL+"/graphql", "application/json", bytes.NewReader(body))
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var r response
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	return r
+}
+
+// decode decodes a field of the data of a response.
+func (s *ServerTestSuite) decode(r response, field string, v any) {
+	s.Require().Empty(r.Errors)
+	s.Require().NoError(json.Unmarshal(r.Data[field], v))
+}
+
+// assertError checks that the response failed with the API error.
+func (s *ServerTestSuite) assertError(r response, apiError *errors.APIError) {
+	s.Require().Len(r.Errors, 1)
+	s.Equal(apiError.Code, r.Errors[0].Extensions["code"])
+}
+
+// account is an account of a response.
+type account struct {
+	ID           string  `json:"id"`
+	Iban         string  `json:"iban"`
+	Owner        string  `json:"owner"`
+	Balance      float64 `json:"balance"`
+	Transactions struct {
+		Nodes []struct {
+			Type   string  `json:"type"`
+			Amount float64 `json:"amount"`
+		} `json:"nodes"`
+		TotalCount int `json:"totalCount"`
+	} `json:"transactions"`
+}
+
+// createAccount creates an account with the given balance.
+func (s *ServerTestSuite) createAccount(owner string, balance float64) account {
+	r := s.exec(`mutation($owner: String!, $balance: Float!) {
+		createAccount(input: {owner: $owner, initialBalance: $balance}) { id iban owner balance }
+	}`, map[string]any{"owner": owner, "balance": balance})
+	var acc account
+	s.decode(r, "createAccount", &acc)
+	return acc
+}
+
+// deposit deposits the amount into the account.
+func (s *ServerTestSuite) deposit(accountID string, amount float64) {
+	r := s.exec(`mutation($id: ID!, $amount: Float!) {
+		createTransaction(input: {accountId: $id, type: DEPOSIT, amount: $amount}) { id }
+	}`, map[string]any{"id": accountID, "amount": amount})
+	s.Require().Empty(r.Errors)
+}
+
+// TestQueries tests fetching accounts with their transactions.
+func (s *ServerTestSuite) TestQueries() {
+	alice := s.createAccount("Alice", 100)
+	bob := s.createAccount("Bob", 0)
+	for i := 1; i <= 3; i++ {
+		s.deposit(alice.ID, float64(i))
+		s.deposit(bob.ID, float64(10*i))
+	}
+
+	s.Run("ok: account with its last transactions", func() {
+		r := s.exec(`query($id: ID!) {
+			account(id: $id) { owner balance transactions(last: 2) { nodes { type amount } totalCount } }
+		}`, map[string]any{"id": alice.ID})
+		var acc account
+		s.decode(r, "account", &acc)
+		s.Equal("Alice", acc.Owner)
+		s.Equal(106.0, acc.Balance)
+		s.Equal(3, acc.Transactions.TotalCount)
+		s.Require().Len(acc.Transactions.Nodes, 2)
+		s.Equal("DEPOSIT", acc.Transactions.Nodes[0].Type)
+		s.Equal(2.0, acc.Transactions.Nodes[0].Amount)
+		s.Equal(3.0, acc.Transactions.Nodes[1].Amount)
+	})
+
+	s.Run("ok: account by iban", func() {
+		r := s.exec(`query($iban: String!) { accountByIban(iban: $iban) { id } }`, map[string]any{"iban": bob.Iban})
+		var acc account
+		s.decode(r, "accountByIban", &acc)
+		s.Equal(bob.ID, acc.ID)
+	})
+
+	s.Run("ok: transactions of the accounts are loaded at once", func() {
+		s.db.single.Store(0)
+		s.db.batch.Store(0)
+
+		r := s.exec(`{ accounts { nodes { owner transactions(first: 1) { nodes { amount } } } } }`, nil)
+		var accs struct{ Nodes []account }
+		s.decode(r, "accounts", &accs)
+		s.Require().Len(accs.Nodes, 2)
+		for _, acc := range accs.Nodes {
+			s.Len(acc.Transactions.Nodes, 1)
+		}
+		s.Equal(int32(1), s.db.batch.Load())
+		s.Equal(int32(0), s.db.single.Load())
+	})
+
+	s.Run("ok: transactions of an account", func() {
+		r := s.exec(`query($id: ID!) { transactions(accountId: $id) { totalCount } }`, map[string]any{"id": bob.ID})
+		var txs struct{ TotalCount int }
+		s.decode(r, "transactions", &txs)
+		s.Equal(3, txs.TotalCount)
+	})
+
+	s.Run("error: account not found", func() {
+		r := s.exec(`{ account(id: "7b1b3ba8-9d4f-4b1a-9d9e-111111111111") { id } }`, nil)
+		s.assertError(r, errors.ErrAccountNotFound)
+		s.Equal("null", string(r.Data["account"]))
+	})
+
+	s.Run("error: invalid account id", func() {
+		r := s.exec(`{ transactions(accountId: "1") { totalCount } }`, nil)
+		s.assertError(r, errors.ErrInvalidAccountID)
+	})
+
+	s.Run("error: invalid iban", func() {
+		r := s.exec(`{ accountByIban(iban: "ES00") { id } }`, nil)
+		s.assertError(r, errors.ErrInvalidIBAN)
+	})
+
+	s.Run("error: unknown field", func() {
+		r := s.exec(`{ accounts { unknown } }`, nil)
+		s.Require().Len(r.Errors, 1)
+		s.Nil(r.Data)
+	})
+}
+
+// TestPagination tests paging through the accounts with cursors.
+func (s *ServerTestSuite) TestPagination() {
+	for i := 0; i < 5; i++ {
+		s.createAccount("Alice", 0)
+	}
+
+	type page struct {
+		Edges []struct {
+			Cursor string
+			Node   account
+		}
+		PageInfo struct {
+			HasNextPage     bool
+			HasPreviousPage bool
+			EndCursor       *string
+		}
+		TotalCount int
+	}
+	query := `query($first: Int, $after: String) {
+		accounts(first: $first, after: $after) {
+			edges { cursor node { id } }
+			pageInfo { hasNextPage hasPreviousPage endCursor }
+			totalCount
+		}
+	}`
+
+	s.Run("ok: page through the accounts", func() {
+		seen := make(map[string]bool)
+		var after *string
+		for i := 0; i < 3; i++ {
+			var p page
+			s.decode(s.exec(query, map[string]any{"first": 2, "after": after}), "accounts", &p)
+			s.Equal(5, p.TotalCount)
+			s.Equal(i > 0, p.PageInfo.HasPreviousPage)
+			s.Equal(i < 2, p.PageInfo.HasNextPage)
+			for _, e := range p.Edges {
+				s.False(seen[e.Node.ID])
+				seen[e.Node.ID] = true
+			}
+			after = p.PageInfo.EndCursor
+		}
+		s.Len(seen, 5)
+	})
+
+	s.Run("ok: empty page", func() {
+		var p page
+		s.decode(s.exec(query, map[string]any{"first": 0}), "accounts", &p)
+		s.Empty(p.Edges)
+		s.Nil(p.PageInfo.EndCursor)
+		s.True(p.PageInfo.HasNextPage)
+	})
+
+	s.Run("error: invalid cursor", func() {
+		s.assertError(s.exec(query, map[string]any{"after": "invalid"}), errors.ErrInvalidCursor)
+	})
+
+	s.Run("error: invalid page size", func() {
+		s.assertError(s.exec(query, map[string]any{"first": maxPageSize + 1}), errors.ErrInvalidPageSize)
+	})
+}
+
+// TestMutations tests creating transactions and transfers.
+func (s *ServerTestSuite) TestMutations() {
+	from := s.createAccount("Alice", 100)
+	to := s.createAccount("Bob", 0)
+
+	s.Run("ok: transfer by iban", func() {
+		r := s.exec(`mutation($from: String!, $to: String!) {
+			transfer(input: {from: $from, to: $to, amount: 60}) {
+				from { balance transactions { nodes { type amount } } }
+				to { balance }
+			}
+		}`, map[string]any{"from": from.Iban, "to": to.ID})
+		var result struct{ From, To account }
+		s.decode(r, "transfer", &result)
+		s.Equal(40.0, result.From.Balance)
+		s.Equal(60.0, result.To.Balance)
+		s.Require().Len(result.From.Transactions.Nodes, 1)
+		s.Equal("WITHDRAWAL", result.From.Transactions.Nodes[0].Type)
+	})
+
+	s.Run("error: insufficient balance", func() {
+		r := s.exec(`mutation($id: ID!) {
+			createTransaction(input: {accountId: $id, type: WITHDRAWAL, amount: 1000}) { id }
+		}`, map[string]any{"id": from.ID})
+		s.assertError(r, errors.ErrInsufficientBalance)
+	})
+
+	s.Run("error: invalid amount", func() {
+		r := s.exec(`mutation($id: ID!, $amount: Float!) {
+			createTransaction(input: {accountId: $id, type: DEPOSIT, amount: $amount}) { id }
+		}`, map[string]any{"id": from.ID, "amount": -1})
+		s.assertError(r, errors.ErrInvalidBody)
+	})
+
+	s.Run("error: missing owner", func() {
+		r := s.exec(`mutation($input: CreateAccountInput!) { createAccount(input: $input) { id } }`, map[string]any{"input": map[string]any{"owner": "", "initialBalance": 0}})
+		s.assertError(r, errors.ErrInvalidBody)
+	})
+}
+
+// TestInvalidBody tests that requests that are not JSON are rejected.
+func (s *ServerTestSuite) TestInvalidBody() {
+	resp, err := http.Post(s.server.URL+"/graphql", "application/json", strings.NewReader("{"))
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	var r response
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	s.assertError(r, errors.ErrInvalidBody)
+}
+
+// dial opens a subscription connection to the test server with the given subprotocols.
+func (s *ServerTestSuite) dial(subprotocols ...string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(s.server.URL, "http")+"/graphql", nil)
+	s.Require().NoError(err)
+	s.Require().NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	return conn
+}
+
+// TestSubscriptions tests streaming the new transactions of an account.
+func (s *ServerTestSuite) TestSubscriptions() {
+	acc := s.createAccount("Alice", 0)
+	other := s.createAccount("Bob", 0)
+
+	s.Run("ok: new transactions", func() {
+		conn := s.dial(subprotocol)
+		defer conn.Close()
+
+		s.Require().NoError(conn.WriteJSON(message{Type: connectionInitMessage}))
+		var msg message
+		s.Require().NoError(conn.ReadJSON(&msg))
+		s.Equal(connectionAckMessage, msg.Type)
+
+		payload, err := json.Marshal(request{
+			Query:     `subscription($ids: [ID!]!) { transactionCreated(accountIds: $ids) { accountId type amount } }`,
+			Variables: map[string]any{"ids": []string{acc.ID}},
+		})
+		s.Require().NoError(err)
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: subscribeMessage, Payload: payload}))
+
+		// deposit until the subscription is registered in the feed and the first transaction is received
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(10 * time.Millisecond):
+					s.deposit(other.ID, 1)
+					s.deposit(acc.ID, 42)
+				}
+			}
+		}()
+		err = conn.ReadJSON(&msg)
+		close(done)
+		s.Require().NoError(err)
+		s.Equal(nextMessage, msg.Type)
+
+		var next struct {
+			Data struct {
+				TransactionCreated struct {
+					AccountID string  `json:"accountId"`
+					Type      string  `json:"type"`
+					Amount    float64 `json:"amount"`
+				} `json:"transactionCreated"`
+			} `json:"data"`
+		}
+		s.Equal("1", msg.ID)
+		s.Require().NoError(json.Unmarshal(msg.Payload, &next))
+		s.Equal(acc.ID, next.Data.TransactionCreated.AccountID)
+		s.Equal(42.0, next.Data.TransactionCreated.Amount)
+
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: completeMessage}))
+	})
+
+	s.Run("error: invalid subscription", func() {
+		conn := s.dial(subprotocol)
+		defer conn.Close()
+		s.Require().NoError(conn.WriteJSON(message{Type: connectionInitMessage}))
+		var msg message
+		s.Require().NoError(conn.ReadJSON(&msg))
+
+		payload, err := json.Marshal(request{Query: `subscription { transactionCreated(accountIds: ["1"]) { id } }`})
+		s.Require().NoError(err)
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: subscribeMessage, Payload: payload}))
+		s.Require().NoError(conn.ReadJSON(&msg))
+		s.Equal(errorMessage, msg.Type)
+		s.Contains(string(msg.Payload), errors.ErrInvalidAccountID.Code)
+	})
+
+	s.Run("error: subscribe before init", func() {
+		conn := s.dial(subprotocol)
+		defer conn.Close()
+		s.Require().NoError(conn.WriteJSON(message{ID: "1", Type: subscribeMessage, Payload: json.RawMessage(`{"query":"{ accounts { totalCount } }"}`)}))
+		_, _, err := conn.ReadMessage()
+		s.True(websocket.IsCloseError(err, closeUnauthorized))
+	})
+
+	s.Run("error: missing subprotocol", func() {
+		conn := s.dial()
+		defer conn.Close()
+		_, _, err := conn.ReadMessage()
+		s.True(websocket.IsCloseError(err, closeSubprotocolNotAcceptable))
+	})
+}
+
+// TestPaginate tests the selection of the pages.
+func (s *ServerTestSuite) TestPaginate() {
+	keys := []string{"a", "b", "c", "d", "e"}
+	size := func(n int32) *int32 { return &n }
+	cursor := func(key string) *string { c := encodeCursor(key); return &c }
+
+	for _, tc := range []struct {
+		name       string
+		args       pageArgs
+		start, end int
+	}{
+		{name: "ok: everything", args: pageArgs{}, start: 0, end: 5},
+		{name: "ok: first", args: pageArgs{First: size(2)}, start: 0, end: 2},
+		{name: "ok: last", args: pageArgs{Last: size(2)}, start: 3, end: 5},
+		{name: "ok: first after", args: pageArgs{First: size(2), After: cursor("b")}, start: 2, end: 4},
+		{name: "ok: last before", args: pageArgs{Last: size(2), Before: cursor("d")}, start: 1, end: 3},
+		{name: "ok: between", args: pageArgs{After: cursor("a"), Before: cursor("e")}, start: 1, end: 4},
+		{name: "ok: after the last item", args: pageArgs{After: cursor("e")}, start: 5, end: 5},
+	} {
+		s.Run(tc.name, func() {
+			p, err := paginate(keys, tc.args)
+			s.Require().NoError(err)
+			s.Equal(page{start: tc.start, end: tc.end, total: 5}, p)
+		})
+	}
+
+	s.Run("error: unknown key", func() {
+		_, err := paginate(keys, pageArgs{After: cursor("z")})
+		s.Equal(errors.ErrInvalidCursor, err)
+	})
+
+	s.Run("error: negative size", func() {
+		_, err := paginate(keys, pageArgs{Last: size(-1)})
+		s.Equal(errors.ErrInvalidPageSize, err)
+	})
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
@@ -0,0 +1,237 @@
+package graphql
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// subprotocol is the WebSocket subprotocol used for the subscriptions.
+// See https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md.
+const subprotocol = "graphql-transport-ws"
+
+// Message types of the graphql-transport-ws protocol.
+const (
+	connectionInitMessage = "connection_init"
+	connectionAckMessage  = "connection_ack"
+	pingMessage           = "ping"
+	pongMessage           = "pong"
+	subscribeMessage      = "subscribe"
+	nextMessage           = "next"
+	errorMessage          = "error"
+	completeMessage       = "complete"
+)
+
+// Close codes of the graphql-transport-ws protocol.
+const (
+	closeInvalidMessage           = 4400
+	closeUnauthorized             = 4401
+	closeSubprotocolNotAcceptable = 4406
+	closeSubscriberExists         = 4409
+	closeTooManyInitRequests      = 4429
+)
+
+// maxMessageSize is the maximum size of a message sent by the client, in bytes.
+const maxMessageSize = 64 << 10
+
+// message is a message of the graphql-transport-ws protocol.
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscriptionConn is a WebSocket connection that runs the operations sent by the client, usually subscriptions.
+// Every operation runs until it completes or the client stops it.
+type subscriptionConn struct {
+	s      *server
+	ws     *websocket.Conn
+	ctx    context.Context // done when the connection is closing
+	writes sync.Mutex      // the connection does not support concurrent writers
+
+	mu          sync.Mutex
+	initialized bool
+	operations  map[string]context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// serve reads the messages of the client until the connection is closed.
+func (c *subscriptionConn) serve() {
+	c.ws.SetReadLimit(maxMessageSize)
+	for {
+		var msg message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				c.close(closeInvalidMessage, "invalid message")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case connectionInitMessage:
+			c.mu.Lock()
+			initialized := c.initialized
+			c.initialized = true
+			c.mu.Unlock()
+			if initialized {
+				c.close(closeTooManyInitRequests, "too many initialisation requests")
+				return
+			}
+			c.write(message{Type: connectionAckMessage})
+		case pingMessage:
+			c.write(message{Type: pongMessage})
+		case pongMessage:
+		case subscribeMessage:
+			if !c.start(msg) {
+				return
+			}
+		case completeMessage:
+			c.stop(msg.ID)
+		default:
+			c.close(closeInvalidMessage, fmt.Sprintf("invalid message type '%s'", msg.Type))
+			return
+		}
+	}
+}
+
+// start runs the operation of a subscribe message. It returns false if the connection must be closed.
+func (c *subscriptionConn) start(msg message) bool {
+	var params request
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &params) != nil {
+		c.close(closeInvalidMessage, "invalid subscribe message")
+		return false
+	}
+
+	c.mu.Lock()
+	if !c.initialized {
+		c.mu.Unlock()
+		c.close(closeUnauthorized, "unauthorized")
+		return false
+	}
+	if _, ok := c.operations[msg.ID]; ok {
+		c.mu.Unlock()
+		c.close(closeSubscriberExists, fmt.Sprintf("subscriber for %s already exists", msg.ID))
+		return false
+	}
+	ctx, cancel := context.WithCancel(withOperationID(c.ctx, msg.ID))
+	c.operations[msg.ID] = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.stop(msg.ID)
+		c.run(ctx, msg.ID, params)
+	}()
+	return true
+}
+
+// run executes the operation and sends its results until it completes or its context is done.
+func (c *subscriptionConn) run(ctx context.Context, id string, params request) {
+	responses, err := c.s.schema.Subscribe(ctx, params.Query, params.OperationName, params.Variables)
+	if err != nil {
+		c.s.logger.Errorf("failed to subscribe: %v", err)
+		c.writePayload(id, errorMessage, errorResponse(errors.ErrUnknown).Errors)
+		return
+	}
+
+	first := true
+	for r := range responses {
+		resp := r.(*graphql.Response)
+		// errors of the operation itself, such as validation errors, are reported with an error message
+		withExtensions(resp.Errors)
+		if first && resp.Data == nil && len(resp.Errors) > 0 {
+			c.writePayload(id, errorMessage, resp.Errors)
+			return
+		}
+		first = false
+		c.writePayload(id, nextMessage, resp)
+	}
+	if ctx.Err() == nil {
+		c.write(message{ID: id, Type: completeMessage})
+	}
+}
+
+// stop cancels the operation, if it is still running.
+func (c *subscriptionConn) stop(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.operations[id]; ok {
+		cancel()
+		delete(c.operations, id)
+	}
+}
+
+// stopAll cancels every running operation and waits for them to return.
+func (c *subscriptionConn) stopAll() {
+	c.mu.Lock()
+	for id, cancel := range c.operations {
+		cancel()
+		delete(c.operations, id)
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// writePayload sends a message with the payload to the client.
+func (c *subscriptionConn) writePayload(id, msgType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.s.logger.Errorf("failed to encode %s message: %v", msgType, err)
+		return
+	}
+	c.write(message{ID: id, Type: msgType, Payload: data})
+}
+
+// write sends a message to the client. Failed writes close the connection, which stops the read loop.
+func (c *subscriptionConn) write(msg message) {
+	c.writes.Lock()
+	defer c.writes.Unlock()
+	if err := c.ws.WriteJSON(msg); err != nil {
+		c.s.logger.Errorf("failed to write %s message: %v", msg.Type, err)
+		c.ws.Close()
+	}
+}
+
+// close closes the connection with the given close code.
+func (c *subscriptionConn) close(code int, reason string) {
+	c.writes.Lock()
+	defer c.writes.Unlock()
+	c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+	c.ws.Close()
+}
+
+// closeOnExpiry closes the connection with the unauthorized close code when the credentials of its caller expire or
+// are revoked. It returns when the connection is closing.
+func (c *subscriptionConn) closeOnExpiry() {
+	<-c.ctx.Done()
+	var apiError *errors.APIError
+	if stderrors.As(context.Cause(c.ctx), &apiError) {
+		c.close(closeUnauthorized, apiError.Message)
+	}
+}
+
+// withOperationID returns a copy of the context whose request id identifies the operation within the connection.
+func withOperationID(ctx context.Context, id string) context.Context {
+	meta := audit.MetadataFrom(ctx)
+	meta.RequestID = fmt.Sprintf("%s/%s", meta.RequestID, id)
+	return audit.WithMetadata(ctx, meta)
+}
+
+// withExtensions sets the extensions of the errors returned by the resolvers, which the library leaves out of the
+// responses of the subscriptions.
+func withExtensions(errs []*gqlerrors.QueryError) {
+	for _, e := range errs {
+		if qe, ok := e.ResolverError.(*queryError); ok && e.Extensions == nil {
+			e.Extensions = qe.Extensions()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/grpc/pb"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// accountServer implements the account service of the gRPC API on top of the account service.
+type accountServer struct {
+	pb.UnimplementedAccountServiceServer
+
+	logger *zap.SugaredLogger
+	as     service.AccountService
+}
+
+// CreateAccount opens a new account with an initial balance.
+func (s *accountServer) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.Account, error) {
+	body := schemas.CreateAccountRequest{Owner: req.GetOwner(), InitialBalance: &req.InitialBalance}
+	if err := binding.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.as.CreateAccount(ctx, &body)
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(acc), nil
+}
+
+// GetAccount retrieves an account by its id.
+func (s *accountServer) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.Account, error) {
+	if err := validateAccountID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	acc, err := s.as.GetAccountByID(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(acc), nil
+}
+
+// GetAccountByIBAN retrieves an account by its account number.
+func (s *accountServer) GetAccountByIBAN(ctx context.Context, req *pb.GetAccountByIBANRequest) (*pb.Account, error) {
+	if err := iban.Validate(req.GetIban()); err != nil {
+		return nil, errors.ErrInvalidIBAN
+	}
+
+	acc, err := s.as.GetAccountByIBAN(ctx, req.GetIban())
+	if err != nil {
+		return nil, err
+	}
+	return toAccount(acc), nil
+}
+
+// ListAccounts retrieves all accounts.
+func (s *accountServer) ListAccounts(ctx context.Context, _ *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	accs, err := s.as.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListAccountsResponse{Accounts: make([]*pb.Account, 0, len(accs))}
+	for i := range accs {
+		resp.Accounts = append(resp.Accounts, toAccount(&accs[i]))
+	}
+	return resp, nil
+}
+
+// validateAccountID checks that the account id is present and is a valid uuid.
+func validateAccountID(id string) error {
+	if id == "" {
+		return errors.ErrAccountIdIsMissing
+	}
+	if err := uuid.Validate(id); err != nil {
+		return errors.ErrInvalidAccountID
+	}
+	return nil
+}
+
+// toAccount converts an account into its protobuf message.
+func toAccount(acc *models.Account) *pb.Account {
+	return &pb.Account{
+		Id:             acc.ID,
+		Iban:           acc.IBAN,
+		Owner:          acc.Owner,
+		Balance:        acc.Balance,
+		InitialBalance: acc.InitialBalance,
+	}
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/transport/grpc/pb"
+	"context"
+	"fmt"
+	"strings"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// authorizationKey is the metadata key of the credentials of the calls, the gRPC counterpart of the Authorization
+// header.
+const authorizationKey = "authorization"
+
+const (
+	// public is the operation of the methods that can be called without credentials.
+	public auth.Operation = ""
+
+	// authenticated is the operation of the methods whose operation depends on the request: they only require a
+	// caller, and their handler authorizes the operation once the request is validated.
+	authenticated auth.Operation = "authenticated"
+)
+
+// operations are the operations performed by the methods of the API, which follow the policy of the HTTP routes.
+// Methods missing from the map are rejected when authentication is enabled.
+var operations = map[string]auth.Operation{
+	pb.AccountService_CreateAccount_FullMethodName:          auth.OpenAccount,
+	pb.AccountService_GetAccount_FullMethodName:             auth.ReadAccounts,
+	pb.AccountService_GetAccountByIBAN_FullMethodName:       auth.ReadAccounts,
+	pb.AccountService_ListAccounts_FullMethodName:           auth.ReadAccounts,
+	pb.TransactionService_CreateTransaction_FullMethodName:  authenticated,
+	pb.TransactionService_StreamTransactions_FullMethodName: auth.ReadTransactions,
+	pb.TransactionService_Transfer_FullMethodName:           auth.Transfer,
+	healthpb.Health_Check_FullMethodName:                    public,
+	healthpb.Health_Watch_FullMethodName:                    public,
+}
+
+// authenticate authenticates the api key or the jwt sent in the authorization metadata of the call, stores its
+// principal in the context and authorizes the operation of the method. The principal replaces the x-actor metadata
+// in the audit metadata. It does nothing when authentication is disabled.
+func authenticate(ctx context.Context, authenticator *auth.Authenticator, method string) (context.Context, error) {
+	if !conf.GlobalConfig.AuthEnabled {
+		return ctx, nil
+	}
+
+	op, ok := operations[method]
+	if !ok {
+		e := *errors.ErrForbidden
+		e.Message = fmt.Sprintf("the method %s is not allowed", method)
+		return nil, &e
+	}
+
+	meta := audit.MetadataFrom(ctx)
+	meta.Actor = ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if token, ok := bearerToken(first(md.Get(authorizationKey))); ok {
+		p, err := authenticator.Authenticate(token)
+		if err != nil {
+			return nil, err
+		}
+		ctx = auth.WithPrincipal(ctx, p)
+		meta.Actor = p.Actor()
+	}
+	ctx = audit.WithMetadata(ctx, meta)
+
+	if op == public {
+		return ctx, nil
+	}
+	if _, ok := auth.PrincipalFrom(ctx); !ok {
+		return nil, errors.ErrUnauthenticated
+	}
+	if op != authenticated {
+		if err := auth.Authorize(ctx, op); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// bearerToken returns the token of the value of the authorization metadata, if it uses the Bearer scheme.
+func bearerToken(value string) (string, bool) {
+	scheme, token, ok := strings.Cut(value, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,248 @@
+package grpc
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/auth/authtest"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/enum"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/grpc/pb"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const adminKey = "admin-key-of-the-configuration-0123456789"
+
+// Define the test suite
+type AuthTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	ctx    context.Context
+
+	server       *grpc.Server
+	conn         *grpc.ClientConn
+	health       *health.Server
+	accounts     pb.AccountServiceClient
+	transactions pb.TransactionServiceClient
+	auth         *auth.Authenticator
+	auditLog     *audit.Log
+	issuer       *authtest.Issuer
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.ctx = context.Background()
+
+	s.T().Setenv("AUTH_ENABLED", "true")
+	s.T().Setenv("ADMIN_API_KEY", adminKey)
+	s.T().Setenv("API_KEYS_PATH", "")
+
+	var err error
+	s.issuer, err = authtest.NewIssuer()
+	s.Require().NoError(err)
+	jwks := filepath.Join(s.T().TempDir(), "jwks.json")
+	s.Require().NoError(os.WriteFile(jwks, s.issuer.JWKS(), 0o600))
+	s.T().Setenv("JWKS", jwks)
+	s.Require().NoError(conf.SetupConfig())
+
+	s.auth, err = auth.NewAuthenticator(s.logger)
+	s.Require().NoError(err)
+	s.auditLog, err = audit.NewLog(s.logger, "")
+	s.Require().NoError(err)
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(s.logger, 16)
+	database := activity.NewDatabase(s.logger, audit.NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), s.auditLog), feed)
+
+	s.health = health.NewServer()
+	s.server = newServer(s.logger, service.NewAccountService(s.logger, database, ibans), service.NewTransactionService(s.logger, database), feed, s.health, s.auth, time.Second)
+	lis := bufconn.Listen(1 << 20)
+	go s.server.Serve(lis)
+
+	s.conn, err = grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.accounts = pb.NewAccountServiceClient(s.conn)
+	s.transactions = pb.NewTransactionServiceClient(s.conn)
+}
+
+func (s *AuthTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.server.Stop()
+}
+
+// as returns a context whose calls are authenticated with the token, if any.
+func (s *AuthTestSuite) as(token string) context.Context {
+	if token == "" {
+		return s.ctx
+	}
+	return metadata.AppendToOutgoingContext(s.ctx, authorizationKey, "Bearer "+token)
+}
+
+// createKey creates an api key of the role granted the scopes.
+func (s *AuthTestSuite) createKey(role enum.Role, scopes ...enum.Scope) (*auth.APIKey, string) {
+	key, token, err := s.auth.Keys().Create("test", role, scopes)
+	s.Require().NoError(err)
+	return key, token
+}
+
+// createAccount creates an account with the given balance with the admin key.
+func (s *AuthTestSuite) createAccount(balance float64) *pb.Account {
+	acc, err := s.accounts.CreateAccount(s.as(adminKey), &pb.CreateAccountRequest{Owner: "Alice", InitialBalance: balance})
+	s.Require().NoError(err)
+	return acc
+}
+
+// assertStatus checks the gRPC code of the error and the API error code attached to it.
+func (s *AuthTestSuite) assertStatus(err error, code codes.Code, apiError *errors.APIError) {
+	st, ok := status.FromError(err)
+	s.Require().True(ok)
+	s.Equal(code, st.Code())
+	s.Require().Len(st.Details(), 1)
+	s.Equal(apiError.Code, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+}
+
+// TestAuthentication tests rejecting the calls without valid credentials.
+func (s *AuthTestSuite) TestAuthentication() {
+	acc := s.createAccount(100)
+
+	for name, token := range map[string]string{"no key": "", "unknown key": "bank_unknown"} {
+		s.Run("error: "+name, func() {
+			_, err := s.accounts.ListAccounts(s.as(token), &pb.ListAccountsRequest{})
+			s.assertStatus(err, codes.Unauthenticated, errors.ErrUnauthenticated)
+		})
+
+		s.Run("error: "+name+" on a stream", func() {
+			stream, err := s.transactions.StreamTransactions(s.as(token), &pb.StreamTransactionsRequest{AccountId: acc.Id})
+			s.Require().NoError(err)
+			_, err = stream.Recv()
+			s.assertStatus(err, codes.Unauthenticated, errors.ErrUnauthenticated)
+		})
+	}
+
+	s.Run("ok: health check without credentials", func() {
+		resp, err := healthpb.NewHealthClient(s.conn).Check(s.ctx, &healthpb.HealthCheckRequest{})
+		s.Require().NoError(err)
+		s.Equal(healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+
+	s.Run("ok: stream with a key", func() {
+		stream, err := s.transactions.StreamTransactions(s.as(adminKey), &pb.StreamTransactionsRequest{AccountId: acc.Id})
+		s.Require().NoError(err)
+		_, err = stream.Recv()
+		s.Equal(io.EOF, err)
+	})
+}
+
+// TestAuthorization tests following the policy of the role and the scopes of the key.
+func (s *AuthTestSuite) TestAuthorization() {
+	acc := s.createAccount(100)
+	_, teller := s.createKey(enum.TellerRole, enum.AccountsRead, enum.AccountsWrite, enum.TransactionsWrite)
+	_, auditor := s.createKey(enum.AuditorRole, enum.AccountsRead, enum.AccountsWrite)
+
+	s.Run("ok: teller deposits", func() {
+		_, err := s.transactions.CreateTransaction(s.as(teller), &pb.CreateTransactionRequest{AccountId: acc.Id, Type: pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: 10})
+		s.Require().NoError(err)
+	})
+
+	s.Run("error: teller withdraws", func() {
+		_, err := s.transactions.CreateTransaction(s.as(teller), &pb.CreateTransactionRequest{AccountId: acc.Id, Type: pb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL, Amount: 10})
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrForbidden)
+	})
+
+	s.Run("error: teller transfers", func() {
+		_, err := s.transactions.Transfer(s.as(teller), &pb.TransferRequest{FromAccountId: acc.Id, ToAccountId: acc.Iban, Amount: 10})
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrForbidden)
+	})
+
+	s.Run("error: auditor opens an account", func() {
+		_, err := s.accounts.CreateAccount(s.as(auditor), &pb.CreateAccountRequest{Owner: "Bob"})
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrForbidden)
+	})
+
+	s.Run("error: insufficient scope", func() {
+		stream, err := s.transactions.StreamTransactions(s.as(teller), &pb.StreamTransactionsRequest{AccountId: acc.Id})
+		s.Require().NoError(err)
+		_, err = stream.Recv()
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrInsufficientScope)
+	})
+}
+
+// TestCustomers tests restricting the customers authenticated with a jwt to the accounts of their owner.
+func (s *AuthTestSuite) TestCustomers() {
+	own := s.createAccount(100)
+	other, err := s.accounts.CreateAccount(s.as(adminKey), &pb.CreateAccountRequest{Owner: "Bob", InitialBalance: 100})
+	s.Require().NoError(err)
+	token, err := s.issuer.CustomerToken("Alice")
+	s.Require().NoError(err)
+
+	s.Run("ok: list own accounts", func() {
+		resp, err := s.accounts.ListAccounts(s.as(token), &pb.ListAccountsRequest{})
+		s.Require().NoError(err)
+		s.Require().Len(resp.Accounts, 1)
+		s.Equal(own.Id, resp.Accounts[0].Id)
+	})
+
+	s.Run("error: account of another owner", func() {
+		_, err := s.accounts.GetAccountByIBAN(s.as(token), &pb.GetAccountByIBANRequest{Iban: other.Iban})
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrAccountAccessDenied)
+	})
+
+	s.Run("error: transactions of another owner", func() {
+		stream, err := s.transactions.StreamTransactions(s.as(token), &pb.StreamTransactionsRequest{AccountId: other.Id, Follow: true})
+		s.Require().NoError(err)
+		_, err = stream.Recv()
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrAccountAccessDenied)
+	})
+
+	s.Run("ok: transfer to another owner", func() {
+		_, err := s.transactions.Transfer(s.as(token), &pb.TransferRequest{FromAccountId: own.Id, ToAccountId: other.Id, Amount: 10})
+		s.Require().NoError(err)
+	})
+
+	s.Run("error: transfer from another owner", func() {
+		_, err := s.transactions.Transfer(s.as(token), &pb.TransferRequest{FromAccountId: other.Id, ToAccountId: own.Id, Amount: 10})
+		s.assertStatus(err, codes.PermissionDenied, errors.ErrAccountAccessDenied)
+	})
+}
+
+// TestActor tests recording the api key, rather than the x-actor metadata, as the actor of the audited mutations.
+func (s *AuthTestSuite) TestActor() {
+	key, token := s.createKey(enum.AdminRole, enum.AccountsWrite)
+	ctx := metadata.AppendToOutgoingContext(s.as(token), actorKey, "spoofed")
+	_, err := s.accounts.CreateAccount(ctx, &pb.CreateAccountRequest{Owner: "Alice"})
+	s.Require().NoError(err)
+
+	records := s.auditLog.List(audit.Filter{Action: audit.CreateAccount})
+	s.Require().Len(records, 1)
+	s.Equal("apikey:"+key.ID, records[0].Actor)
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
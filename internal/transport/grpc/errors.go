@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	errors "bank_test/internal/api_errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the error details attached to the statuses.
+const errorDomain = "bank"
+
+// codesByAPIError maps the API errors whose HTTP status does not match the meaning of the error in gRPC.
+var codesByAPIError = map[string]codes.Code{
+	errors.ErrAccountNotFound.Code:     codes.NotFound,
+	errors.ErrInsufficientBalance.Code: codes.FailedPrecondition,
+	errors.ErrRequestCanceled.Code:     codes.Canceled,
+}
+
+// codesByHTTPStatus maps the HTTP statuses of the API errors to gRPC codes.
+var codesByHTTPStatus = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// toStatus converts an error into a gRPC status error. API errors keep their message, and their code is attached as
+// the reason of an ErrorInfo detail so that clients can tell them apart. Other errors are internal errors.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	apiError, ok := err.(*errors.APIError)
+	if !ok {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	code, ok := codesByAPIError[apiError.Code]
+	if !ok {
+		if code, ok = codesByHTTPStatus[apiError.HTTPStatus]; !ok {
+			code = codes.Unknown
+		}
+	}
+
+	st := status.New(code, apiError.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: apiError.Code, Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys read from the incoming calls. They are the gRPC counterparts of the X-Actor and X-Request-Id headers.
+const (
+	actorKey     = "x-actor"
+	requestIDKey = "x-request-id"
+)
+
+// unaryInterceptor stores the audit metadata of the call in its context, authenticates and authorizes its caller,
+// cancels it once the timeout has elapsed and converts the errors of the handler into gRPC statuses. A zero timeout
+// disables it.
+func unaryInterceptor(logger *zap.SugaredLogger, authenticator *auth.Authenticator, timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		logger.Infof("%s called", info.FullMethod)
+
+		ctx, err := authenticate(withAuditMetadata(ctx), authenticator, info.FullMethod)
+		if err != nil {
+			logger.Error(err)
+			return nil, toStatus(err)
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			logger.Error(err)
+		}
+		return resp, toStatus(err)
+	}
+}
+
+// streamInterceptor stores the audit metadata of the call in the context of the stream, authenticates and authorizes
+// its caller and converts the errors of the handler into gRPC statuses. Streams are not bounded by the request timeout,
+// since they may stay open, but are closed when the credentials of the caller expire or are revoked.
+func streamInterceptor(logger *zap.SugaredLogger, authenticator *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		logger.Infof("%s called", info.FullMethod)
+
+		ctx, err := authenticate(withAuditMetadata(ss.Context()), authenticator, info.FullMethod)
+		if err != nil {
+			logger.Error(err)
+			return toStatus(err)
+		}
+
+		if p, ok := auth.PrincipalFrom(ctx); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = authenticator.Watch(ctx, p)
+			defer cancel()
+		}
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		var apiError *errors.APIError
+		if err != nil && stderrors.As(context.Cause(ctx), &apiError) {
+			err = apiError
+		}
+		if err != nil {
+			logger.Error(err)
+		}
+		return toStatus(err)
+	}
+}
+
+// contextStream is a server stream whose context is replaced.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the replaced context of the stream.
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// withAuditMetadata returns a copy of the context that carries the actor and the request id of the call. Calls without
+// a request id get a new one.
+func withAuditMetadata(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	meta := audit.Metadata{Actor: first(md.Get(actorKey)), RequestID: first(md.Get(requestIDKey))}
+	if meta.RequestID == "" {
+		meta.RequestID = uuid.NewString()
+	}
+	return audit.WithMetadata(ctx, meta)
+}
+
+// first returns the first value of a metadata key, or an empty string if there is none.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/grpc/pb"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type grpcTransport struct {
+	logger *zap.SugaredLogger
+	db     db.DatabaseAdapter
+	feed   *activity.Feed
+	auth   *auth.Authenticator
+	server *grpc.Server
+	health *health.Server
+}
+
+func NewGrpcTransport(logger *zap.SugaredLogger, db db.DatabaseAdapter, feed *activity.Feed, authenticator *auth.Authenticator) *grpcTransport {
+	return &grpcTransport{logger: logger, db: db, feed: feed, auth: authenticator, health: health.NewServer()}
+}
+
+// Serve is a function that sets up the gRPC server. It listens on the gRPC port specified in the configuration.
+func (g *grpcTransport) Serve() error {
+	g.logger.Debugf("setting up grpc server")
+	ibans, err := iban.NewGenerator(conf.GlobalConfig.IBANCountryCode, conf.GlobalConfig.IBANBankCode)
+	if err != nil {
+		return g.wrapError(err)
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", conf.GlobalConfig.GRPCPort))
+	if err != nil {
+		return g.wrapError(err)
+	}
+
+	as := service.NewAccountService(g.logger, g.db, ibans)
+	ts := service.NewTransactionService(g.logger, g.db)
+	g.server = newServer(g.logger, as, ts, g.feed, g.health, g.auth, conf.GlobalConfig.RequestTimeout)
+	g.logger.Infof("grpc server listening on port %s", conf.GlobalConfig.GRPCPort)
+	if err := g.server.Serve(lis); err != nil {
+		return g.wrapError(err)
+	}
+
+	return nil
+}
+
+// HealthCheck marks the services as serving in the standard gRPC health service, which is served on the gRPC port
+// itself, as gRPC clients and load balancers expect.
+func (g *grpcTransport) HealthCheck() error {
+	g.logger.Debugf("setting up grpc health service")
+	for _, name := range []string{"", pb.AccountService_ServiceDesc.ServiceName, pb.TransactionService_ServiceDesc.ServiceName} {
+		g.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	return nil
+}
+
+// Close marks the services as not serving and stops the server once the pending calls are finished.
+func (g *grpcTransport) Close() error {
+	g.health.Shutdown()
+	if g.server != nil {
+		g.server.GracefulStop()
+	}
+	return nil
+}
+
+// wrapError is a helper function that logs the error and returns it
+func (g *grpcTransport) wrapError(err error) error {
+	g.logger.Error(err)
+	return err
+}
+
+// newServer creates the gRPC server with the account, transaction and health services. When authentication is
+// enabled, the calls are authenticated by the authenticator. Unary calls are cancelled once the timeout has elapsed.
+func newServer(logger *zap.SugaredLogger, as service.AccountService, ts service.TransactionService, feed *activity.Feed, healthServer *health.Server, authenticator *auth.Authenticator, timeout time.Duration) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor(logger, authenticator, timeout)),
+		grpc.StreamInterceptor(streamInterceptor(logger, authenticator)),
+	)
+	pb.RegisterAccountServiceServer(server, &accountServer{logger: logger, as: as})
+	pb.RegisterTransactionServiceServer(server, &transactionServer{logger: logger, ts: ts, feed: feed})
+	healthpb.RegisterHealthServer(server, healthServer)
+	return server
+}
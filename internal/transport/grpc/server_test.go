@@ -0,0 +1,238 @@
+package grpc
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/grpc/pb"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Define the test suite
+type ServerTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	ctx    context.Context
+
+	server       *grpc.Server
+	conn         *grpc.ClientConn
+	health       *health.Server
+	accounts     pb.AccountServiceClient
+	transactions pb.TransactionServiceClient
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.ctx = context.Background()
+	s.Require().NoError(conf.SetupConfig())
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(s.logger, 16)
+	database := activity.NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), feed)
+
+	s.health = health.NewServer()
+	s.server = newServer(s.logger, service.NewAccountService(s.logger, database, ibans), service.NewTransactionService(s.logger, database), feed, s.health, nil, time.Second)
+	lis := bufconn.Listen(1 << 20)
+	go s.server.Serve(lis)
+
+	s.conn, err = grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.accounts = pb.NewAccountServiceClient(s.conn)
+	s.transactions = pb.NewTransactionServiceClient(s.conn)
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.server.Stop()
+}
+
+// createAccount creates an account with the given balance.
+func (s *ServerTestSuite) createAccount(balance float64) *pb.Account {
+	acc, err := s.accounts.CreateAccount(s.ctx, &pb.CreateAccountRequest{Owner: "Alice", InitialBalance: balance})
+	s.Require().NoError(err)
+	return acc
+}
+
+// assertStatus checks the gRPC code of the error and the API error code attached to it.
+func (s *ServerTestSuite) assertStatus(err error, code codes.Code, apiError *errors.APIError) {
+	st, ok := status.FromError(err)
+	s.Require().True(ok)
+	s.Equal(code, st.Code())
+	s.Require().Len(st.Details(), 1)
+	s.Equal(apiError.Code, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+}
+
+// TestAccounts tests the account service.
+func (s *ServerTestSuite) TestAccounts() {
+	acc := s.createAccount(100)
+
+	s.Run("ok: get account", func() {
+		got, err := s.accounts.GetAccount(s.ctx, &pb.GetAccountRequest{Id: acc.Id})
+		s.Require().NoError(err)
+		s.Equal(100.0, got.Balance)
+		s.Equal(acc.Iban, got.Iban)
+	})
+
+	s.Run("ok: get account by iban", func() {
+		got, err := s.accounts.GetAccountByIBAN(s.ctx, &pb.GetAccountByIBANRequest{Iban: acc.Iban})
+		s.Require().NoError(err)
+		s.Equal(acc.Id, got.Id)
+	})
+
+	s.Run("ok: list accounts", func() {
+		resp, err := s.accounts.ListAccounts(s.ctx, &pb.ListAccountsRequest{})
+		s.Require().NoError(err)
+		s.Len(resp.Accounts, 1)
+	})
+
+	s.Run("error: account not found", func() {
+		_, err := s.accounts.GetAccount(s.ctx, &pb.GetAccountRequest{Id: "7b1b3ba8-9d4f-4b1a-9d9e-111111111111"})
+		s.assertStatus(err, codes.NotFound, errors.ErrAccountNotFound)
+	})
+
+	s.Run("error: invalid account id", func() {
+		_, err := s.accounts.GetAccount(s.ctx, &pb.GetAccountRequest{Id: "1"})
+		s.assertStatus(err, codes.InvalidArgument, errors.ErrInvalidAccountID)
+	})
+
+	s.Run("error: missing owner", func() {
+		_, err := s.accounts.CreateAccount(s.ctx, &pb.CreateAccountRequest{})
+		s.assertStatus(err, codes.InvalidArgument, errors.ErrInvalidBody)
+	})
+}
+
+// TestTransactions tests creating transactions and transfers.
+func (s *ServerTestSuite) TestTransactions() {
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+
+	s.Run("ok: deposit", func() {
+		tx, err := s.transactions.CreateTransaction(s.ctx, &pb.CreateTransactionRequest{AccountId: from.Id, Type: pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: 10})
+		s.Require().NoError(err)
+		s.Equal(pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, tx.Type)
+		s.Equal(10.0, tx.Amount)
+	})
+
+	s.Run("ok: transfer by iban", func() {
+		_, err := s.transactions.Transfer(s.ctx, &pb.TransferRequest{FromAccountId: from.Iban, ToAccountId: to.Id, Amount: 60})
+		s.Require().NoError(err)
+		got, err := s.accounts.GetAccount(s.ctx, &pb.GetAccountRequest{Id: to.Id})
+		s.Require().NoError(err)
+		s.Equal(60.0, got.Balance)
+	})
+
+	s.Run("error: insufficient balance", func() {
+		_, err := s.transactions.CreateTransaction(s.ctx, &pb.CreateTransactionRequest{AccountId: from.Id, Type: pb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL, Amount: 1000})
+		s.assertStatus(err, codes.FailedPrecondition, errors.ErrInsufficientBalance)
+	})
+
+	s.Run("error: unspecified type", func() {
+		_, err := s.transactions.CreateTransaction(s.ctx, &pb.CreateTransactionRequest{AccountId: from.Id, Amount: 10})
+		s.assertStatus(err, codes.InvalidArgument, errors.ErrInvalidBody)
+	})
+
+	s.Run("error: timeout", func() {
+		ctx, cancel := context.WithTimeout(s.ctx, time.Nanosecond)
+		defer cancel()
+		time.Sleep(time.Millisecond)
+		_, err := s.transactions.CreateTransaction(ctx, &pb.CreateTransactionRequest{AccountId: from.Id, Type: pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: 10})
+		s.Equal(codes.DeadlineExceeded, status.Code(err))
+	})
+}
+
+// TestStreamTransactions tests streaming the history of an account, and following its new transactions.
+func (s *ServerTestSuite) TestStreamTransactions() {
+	acc := s.createAccount(0)
+	for i := 0; i < 3; i++ {
+		_, err := s.transactions.CreateTransaction(s.ctx, &pb.CreateTransactionRequest{AccountId: acc.Id, Type: pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: float64(i + 1)})
+		s.Require().NoError(err)
+	}
+
+	s.Run("ok: history", func() {
+		stream, err := s.transactions.StreamTransactions(s.ctx, &pb.StreamTransactionsRequest{AccountId: acc.Id})
+		s.Require().NoError(err)
+		for i := 0; i < 3; i++ {
+			tx, err := stream.Recv()
+			s.Require().NoError(err)
+			s.Equal(float64(i+1), tx.Amount)
+		}
+		_, err = stream.Recv()
+		s.Equal(io.EOF, err)
+	})
+
+	s.Run("ok: follow", func() {
+		ctx, cancel := context.WithTimeout(s.ctx, time.Second)
+		defer cancel()
+		stream, err := s.transactions.StreamTransactions(ctx, &pb.StreamTransactionsRequest{AccountId: acc.Id, Follow: true})
+		s.Require().NoError(err)
+		for i := 0; i < 3; i++ {
+			_, err := stream.Recv()
+			s.Require().NoError(err)
+		}
+
+		_, err = s.transactions.CreateTransaction(s.ctx, &pb.CreateTransactionRequest{AccountId: acc.Id, Type: pb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: 42})
+		s.Require().NoError(err)
+		tx, err := stream.Recv()
+		s.Require().NoError(err)
+		s.Equal(42.0, tx.Amount)
+	})
+
+	s.Run("error: account not found", func() {
+		stream, err := s.transactions.StreamTransactions(s.ctx, &pb.StreamTransactionsRequest{AccountId: "7b1b3ba8-9d4f-4b1a-9d9e-111111111111"})
+		s.Require().NoError(err)
+		_, err = stream.Recv()
+		s.assertStatus(err, codes.NotFound, errors.ErrAccountNotFound)
+	})
+}
+
+// TestHealth tests that the standard health service reports the services once they are serving.
+func (s *ServerTestSuite) TestHealth() {
+	client := healthpb.NewHealthClient(s.conn)
+	transport := &grpcTransport{logger: s.logger, health: s.health}
+	s.Require().NoError(transport.HealthCheck())
+
+	resp, err := client.Check(s.ctx, &healthpb.HealthCheckRequest{Service: pb.TransactionService_ServiceDesc.ServiceName})
+	s.Require().NoError(err)
+	s.Equal(healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(s.ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+// TestToStatus tests the mapping of the errors to gRPC statuses.
+func (s *ServerTestSuite) TestToStatus() {
+	s.Nil(toStatus(nil))
+	s.Equal(codes.DeadlineExceeded, status.Code(toStatus(errors.ErrTimeout)))
+	s.Equal(codes.Canceled, status.Code(toStatus(errors.ErrRequestCanceled)))
+	s.Equal(codes.Unimplemented, status.Code(toStatus(errors.ErrBackupNotSupported)))
+	s.Equal(codes.Internal, status.Code(toStatus(io.ErrUnexpectedEOF)))
+
+	st := status.Error(codes.Aborted, "aborted")
+	s.Equal(st, toStatus(st))
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/grpc/pb"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// transactionServer implements the transaction service of the gRPC API on top of the transaction service. The new
+// transactions of the followed streams are taken from the activity feed.
+type transactionServer struct {
+	pb.UnimplementedTransactionServiceServer
+
+	logger *zap.SugaredLogger
+	ts     service.TransactionService
+	feed   *activity.Feed
+}
+
+// CreateTransaction deposits money into or withdraws it from an account.
+func (s *transactionServer) CreateTransaction(ctx context.Context, req *pb.CreateTransactionRequest) (*pb.Transaction, error) {
+	if err := validateAccountID(req.GetAccountId()); err != nil {
+		return nil, err
+	}
+
+	body := schemas.CreateTransactionRequest{Type: fromTransactionType(req.GetType()).String(), Amount: &req.Amount}
+	if err := binding.Validate(&body); err != nil {
+		return nil, err
+	}
+	op := auth.Withdraw
+	if body.Type == enum.Deposit.String() {
+		op = auth.Deposit
+	}
+	if err := auth.Authorize(ctx, op); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.ts.CreateTransaction(ctx, req.GetAccountId(), &body)
+	if err != nil {
+		return nil, err
+	}
+	return toTransaction(tx), nil
+}
+
+// StreamTransactions streams the transaction history of an account. If the request follows the account, the stream
+// stays open until the client cancels it, and the new transactions are sent as they are committed. The subscription
+// to the feed is created before reading the history, so no transaction is missed in between, and the ones already
+// sent with the history are skipped.
+func (s *transactionServer) StreamTransactions(req *pb.StreamTransactionsRequest, stream pb.TransactionService_StreamTransactionsServer) error {
+	ctx := stream.Context()
+	if err := validateAccountID(req.GetAccountId()); err != nil {
+		return err
+	}
+
+	var sub *activity.Subscription
+	if req.GetFollow() {
+		sub = s.feed.Subscribe(req.GetAccountId(), 0)
+		defer sub.Cancel()
+	}
+
+	txs, err := s.ts.GetTransactionsByAccountID(ctx, req.GetAccountId())
+	if err != nil {
+		return err
+	}
+	sent := make(map[string]bool, len(txs))
+	for i := range txs {
+		if err := stream.Send(toTransaction(&txs[i])); err != nil {
+			return err
+		}
+		sent[txs[i].ID] = true
+	}
+	if sub == nil {
+		return nil
+	}
+
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "the stream fell behind the new transactions")
+			}
+			if e.Type != enum.TransactionActivity {
+				continue
+			}
+			var tx models.Transaction
+			if err := json.Unmarshal(e.Data, &tx); err != nil {
+				return err
+			}
+			if sent[tx.ID] {
+				continue
+			}
+			if err := stream.Send(toTransaction(&tx)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return errors.FromContext(ctx.Err())
+		}
+	}
+}
+
+// Transfer transfers money from one account to another. Accounts can be referenced by their id or by their IBAN.
+func (s *transactionServer) Transfer(ctx context.Context, req *pb.TransferRequest) (*pb.TransferResponse, error) {
+	body := schemas.TransferRequest{FromAccountId: req.GetFromAccountId(), ToAccountId: req.GetToAccountId(), Amount: &req.Amount}
+	if err := binding.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	if err := s.ts.Transfer(ctx, body.FromAccountId, body.ToAccountId, *body.Amount); err != nil {
+		return nil, err
+	}
+	return &pb.TransferResponse{}, nil
+}
+
+// fromTransactionType converts a protobuf transaction type. Unspecified types are converted to an empty type, which
+// is rejected by the validation.
+func fromTransactionType(t pb.TransactionType) enum.TransactionType {
+	switch t {
+	case pb.TransactionType_TRANSACTION_TYPE_DEPOSIT:
+		return enum.Deposit
+	case pb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL:
+		return enum.Withdrawal
+	default:
+		return ""
+	}
+}
+
+// toTransaction converts a transaction into its protobuf message.
+func toTransaction(tx *models.Transaction) *pb.Transaction {
+	txType := pb.TransactionType_TRANSACTION_TYPE_UNSPECIFIED
+	switch tx.Type {
+	case enum.Deposit:
+		txType = pb.TransactionType_TRANSACTION_TYPE_DEPOSIT
+	case enum.Withdrawal:
+		txType = pb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL
+	}
+
+	return &pb.Transaction{
+		Id:        tx.ID,
+		AccountId: tx.AccountID,
+		Type:      txType,
+		Amount:    tx.Amount,
+		Timestamp: timestamppb.New(tx.Timestamp),
+	}
+}
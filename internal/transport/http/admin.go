@@ -0,0 +1,177 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/db"
+	"bank_test/internal/helpers"
+	"bank_test/internal/reconciliation"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// createReconciliation is an endpoint that runs a reconciliation of the ledger on demand.
+func (h *handler) createReconciliation(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("create reconciliation endpoint called")
+
+	report := h.reconciler.Reconcile(r.Context(), reconciliation.Manual)
+	h.logger.Info("reconciliation finished successfully")
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, report)
+}
+
+// getReconciliation is an endpoint that retrieves a reconciliation report by its id.
+func (h *handler) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get reconciliation endpoint called")
+
+	// decode the report id from the request
+	h.logger.Debugf("decoding report id from the request")
+	reportID := chi.URLParam(r, "id")
+	if err := uuid.Validate(reportID); err != nil {
+		h.wrapError(w, r, errors.ErrReportNotFound)
+		return
+	}
+	h.logger.Debugf("report id decoded successfully: %s", reportID)
+
+	report, err := h.reconciler.GetReport(reportID)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Info("reconciliation report retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, report)
+}
+
+// getAuditRecords is an endpoint that retrieves the audit records that match the filters defined in the query parameters.
+func (h *handler) getAuditRecords(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get audit records endpoint called")
+
+	h.logger.Debugf("decoding audit filters from the request")
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:     query.Get("actor"),
+		RequestID: query.Get("request_id"),
+		Action:    audit.Action(query.Get("action")),
+		EntityID:  query.Get("entity_id"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidTimeRange)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidTimeRange)
+		return
+	}
+	h.logger.Debugf("audit filters decoded successfully: %s", helpers.PrettyPrintStructResponse(filter))
+
+	records := h.auditLog.List(filter)
+	h.logger.Info("audit records retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, records)
+}
+
+// verifyAuditLog is an endpoint that recomputes the hash chain of the audit log and reports the first broken link.
+func (h *handler) verifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("verify audit log endpoint called")
+
+	verification := h.auditLog.Verify()
+	if !verification.Valid {
+		h.logger.Errorf("audit log is broken at record %d: %s", verification.BrokenSequence, verification.Reason)
+	}
+	h.logger.Info("audit log verified successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, verification)
+}
+
+// backupDatabase is an endpoint that streams a consistent copy of the database while it keeps serving requests.
+// It is only supported by the databases stored in a single file, such as bolt.
+func (h *handler) backupDatabase(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("backup database endpoint called")
+
+	backuper, ok := h.db.(db.Backuper)
+	if !ok {
+		h.wrapError(w, r, errors.ErrBackupNotSupported)
+		return
+	}
+
+	filename := fmt.Sprintf("bank-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	n, err := backuper.Backup(r.Context(), w)
+	if err != nil {
+		if n == 0 {
+			// nothing has been written yet, so the error can still be reported to the client
+			w.Header().Del("Content-Disposition")
+			h.wrapError(w, r, err)
+			return
+		}
+		h.logger.Errorf("database backup interrupted after %d bytes: %v", n, err)
+		return
+	}
+	h.logger.Infof("database backup finished successfully: %d bytes written", n)
+}
+
+// freezeAccount is an endpoint that freezes an account: its transactions and transfers are rejected until it is
+// unfrozen.
+func (h *handler) freezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("freeze account endpoint called")
+	h.setFrozen(w, r, true)
+}
+
+// unfreezeAccount is an endpoint that unfreezes an account.
+func (h *handler) unfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("unfreeze account endpoint called")
+	h.setFrozen(w, r, false)
+}
+
+// setFrozen freezes or unfreezes the account of the request, and responds with the account.
+func (h *handler) setFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+	freezer, ok := h.db.(db.Freezer)
+	if !ok {
+		h.wrapError(w, r, errors.ErrFreezeNotSupported)
+		return
+	}
+
+	accID := chi.URLParam(r, "id")
+	if err := uuid.Validate(accID); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidAccountID)
+		return
+	}
+
+	var err error
+	if frozen {
+		err = freezer.FreezeAccount(r.Context(), accID)
+	} else {
+		err = freezer.UnfreezeAccount(r.Context(), accID)
+	}
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+
+	acc, err := h.db.GetAccountByID(r.Context(), accID)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Infof("account %s frozen: %t", accID, acc.Frozen)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, versioned(r, acc))
+}
+
+// parseTimeParam parses a query parameter in RFC3339 format. Empty parameters are returned as the zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/enum"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+const (
+	// public is the operation of the routes that can be called without credentials.
+	public auth.Operation = ""
+
+	// authenticated is the operation of the routes whose operation depends on the body of the request: they only
+	// require a caller, and their handler authorizes the operation once the body is decoded.
+	authenticated auth.Operation = "authenticated"
+)
+
+// authenticate is a middleware that authenticates the api key or the jwt sent in the Authorization header of the
+// request, and stores its principal in the context. The principal replaces the X-Actor header in the audit metadata.
+// Requests without credentials continue anonymously, and are rejected by the routes that require a scope; requests
+// with invalid credentials are rejected. It does nothing when authentication is disabled.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	if !conf.GlobalConfig.AuthEnabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := audit.MetadataFrom(r.Context())
+		meta.Actor = ""
+
+		ctx := r.Context()
+		if token, ok := bearerToken(r); ok {
+			p, err := h.auth.Authenticate(token)
+			if err != nil {
+				h.unauthenticated(w, r, err)
+				return
+			}
+			ctx = auth.WithPrincipal(ctx, p)
+			meta.Actor = p.Actor()
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithMetadata(ctx, meta)))
+	})
+}
+
+// authorize is a middleware that rejects the requests whose caller is not authorized to perform the operation of the
+// route. It does nothing on public routes and when authentication is disabled.
+func (h *handler) authorize(op auth.Operation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !conf.GlobalConfig.AuthEnabled || op == public {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.PrincipalFrom(r.Context()); !ok {
+				h.unauthenticated(w, r, errors.ErrUnauthenticated)
+				return
+			}
+			if op != authenticated && !h.authorized(w, r, op) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorized checks that the caller of the request is authorized to perform the operation, following the policy of
+// its role and the scopes of its credentials. If it is not, the request is rejected and false is returned. It is the
+// single authorization check of the routes: handlers whose operation depends on the request call it themselves.
+func (h *handler) authorized(w http.ResponseWriter, r *http.Request, op auth.Operation) bool {
+	err := auth.Authorize(r.Context(), op)
+	if err == nil {
+		return true
+	}
+	if stderrors.Is(err, errors.ErrInsufficientScope) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, op.Scope()))
+	}
+	h.wrapError(w, r, err)
+	return false
+}
+
+// unauthenticated rejects a request without valid credentials, telling the client how to authenticate (RFC 6750).
+func (h *handler) unauthenticated(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="bank"`)
+	h.wrapError(w, r, err)
+}
+
+// bearerToken returns the token of the Authorization header of the request, if it uses the Bearer scheme.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// createAPIKey is an endpoint that creates an api key of the role granted the scopes of the request. The key is only returned in
+// this response.
+func (h *handler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("create api key endpoint called")
+
+	h.logger.Debugf("decoding request body")
+	var body schemas.CreateAPIKeyRequest
+	if err := binding.DecodeJSONBody(r, &body); err != nil {
+		bodyErr := *errors.ErrInvalidBody
+		bodyErr.Message = fmt.Sprintf("failed to decode request body: %v", err)
+		h.wrapError(w, r, &bodyErr)
+		return
+	}
+	h.logger.Debugf("request body decoded successfully: %s", body.Name)
+
+	scopes := make([]enum.Scope, 0, len(body.Scopes))
+	for _, scope := range body.Scopes {
+		scopes = append(scopes, enum.Scope(scope))
+	}
+	key, token, err := h.auth.Keys().Create(body.Name, enum.Role(body.Role), scopes)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Infof("api key %s created successfully", key.ID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, schemas.CreateAPIKeyResponse{APIKey: *key, Key: token})
+}
+
+// getAPIKeys is an endpoint that retrieves every api key, revoked ones included. The keys themselves are never
+// returned.
+func (h *handler) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get api keys endpoint called")
+
+	keys := h.auth.Keys().List()
+	h.logger.Info("api keys retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, keys)
+}
+
+// revokeAPIKey is an endpoint that revokes an api key. It is kept in the list of keys, but no longer authenticates.
+func (h *handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("revoke api key endpoint called")
+
+	key, err := h.auth.Keys().Revoke(chi.URLParam(r, "id"))
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Infof("api key %s revoked successfully", key.ID)
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, key)
+}
@@ -0,0 +1,442 @@
+package http
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/auth/authtest"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/freeze"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/transport/ws"
+	"bank_test/internal/webhook"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+const adminKey = "admin-key-of-the-configuration-0123456789"
+
+// Define the test suite
+type AuthTestSuite struct {
+	suite.Suite
+	router   http.Handler
+	auditLog *audit.Log
+	issuer   *authtest.Issuer
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	s.T().Setenv("AUTH_ENABLED", "true")
+	s.T().Setenv("ADMIN_API_KEY", adminKey)
+	s.T().Setenv("API_KEYS_PATH", "")
+
+	issuer, err := authtest.NewIssuer()
+	s.Require().NoError(err)
+	s.issuer = issuer
+	jwks := filepath.Join(s.T().TempDir(), "jwks.json")
+	s.Require().NoError(os.WriteFile(jwks, issuer.JWKS(), 0o600))
+	s.T().Setenv("JWKS", jwks)
+	s.Require().NoError(conf.SetupConfig())
+	conf.GlobalConfig.OpenAPIValidation = true
+
+	s.auditLog, err = audit.NewLog(logger, "")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	frozen, err := freeze.NewStore(logger, "")
+	s.Require().NoError(err)
+	db := audit.NewDatabase(logger, freeze.NewDatabase(logger, memory.NewInMemoryDatabase(logger), frozen), s.auditLog)
+	webhooks, err := webhook.NewStore(logger, "")
+	s.Require().NoError(err)
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{})
+
+	authenticator, err := auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	s.router, err = NewHttpTransport(logger, db, reconciliation.NewReconciler(logger, db), s.auditLog, webhooks, dispatcher, feed, authenticator).Handler()
+	s.Require().NoError(err)
+}
+
+// do sends a request to the router with the api key, if any.
+func (s *AuthTestSuite) do(method, target, key, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	req.Header.Set(actorHeader, "spoofed")
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// createKey creates an api key of the role with the admin key.
+func (s *AuthTestSuite) createKey(role string, scopes ...string) schemas.CreateAPIKeyResponse {
+	body, err := json.Marshal(map[string]any{"name": "test", "role": role, "scopes": scopes})
+	s.Require().NoError(err)
+	rec := s.do(http.MethodPost, "/admin/api-keys", adminKey, string(body))
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created schemas.CreateAPIKeyResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+	return created
+}
+
+// TestAuthentication tests rejecting the requests without a valid api key.
+func (s *AuthTestSuite) TestAuthentication() {
+	for name, key := range map[string]string{"no key": "", "unknown key": "bank_unknown"} {
+		s.Run("error: "+name, func() {
+			rec := s.do(http.MethodGet, "/accounts", key, "")
+			s.Equal(http.StatusUnauthorized, rec.Code, rec.Body.String())
+			s.Contains(rec.Body.String(), "UNAUTHENTICATED")
+			s.Equal(`Bearer realm="bank"`, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+
+	s.Run("ok: public routes", func() {
+		rec := s.do(http.MethodGet, "/openapi.json", "", "")
+		s.Equal(http.StatusOK, rec.Code)
+	})
+
+	s.Run("error: revoked key", func() {
+		created := s.createKey("admin", "accounts:read")
+		rec := s.do(http.MethodGet, "/accounts", created.Key, "")
+		s.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = s.do(http.MethodDelete, "/admin/api-keys/"+created.ID, adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "revoked_at")
+
+		rec = s.do(http.MethodGet, "/accounts", created.Key, "")
+		s.Equal(http.StatusUnauthorized, rec.Code, rec.Body.String())
+	})
+
+	s.Run("error: revoke unknown key", func() {
+		rec := s.do(http.MethodDelete, "/admin/api-keys/unknown", adminKey, "")
+		s.Equal(http.StatusNotFound, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "API_KEY_NOT_FOUND")
+	})
+}
+
+// TestScopes tests enforcing the scopes of the routes.
+func (s *AuthTestSuite) TestScopes() {
+	reader := s.createKey("admin", "accounts:read")
+	writer := s.createKey("admin", "accounts:write", "transactions:write")
+
+	s.Run("ok: granted scope", func() {
+		rec := s.do(http.MethodPost, "/accounts", writer.Key, `{"owner":"Jane","initial_balance":10}`)
+		s.Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	s.Run("error: missing scope", func() {
+		rec := s.do(http.MethodPost, "/accounts", reader.Key, `{"owner":"Jane","initial_balance":10}`)
+		s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "INSUFFICIENT_SCOPE")
+		s.Equal(`Bearer error="insufficient_scope", scope="accounts:write"`, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	s.Run("error: admin routes", func() {
+		rec := s.do(http.MethodGet, "/admin/api-keys", writer.Key, "")
+		s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+	})
+
+	s.Run("ok: list keys", func() {
+		rec := s.do(http.MethodGet, "/admin/api-keys", adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.NotContains(rec.Body.String(), reader.Key)
+		s.NotContains(rec.Body.String(), "hash")
+		s.Contains(rec.Body.String(), reader.ID)
+	})
+
+	s.Run("error: unknown scope", func() {
+		rec := s.do(http.MethodPost, "/admin/api-keys", adminKey, `{"name":"test","role":"admin","scopes":["accounts:delete"]}`)
+		s.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+	})
+}
+
+// TestActor tests recording the api key, rather than the X-Actor header, as the actor of the audited mutations.
+func (s *AuthTestSuite) TestActor() {
+	created := s.createKey("admin", "accounts:write")
+
+	rec := s.do(http.MethodPost, "/accounts", created.Key, `{"owner":"Jane","initial_balance":10}`)
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	records := s.auditLog.List(audit.Filter{Action: audit.CreateAccount})
+	s.Require().Len(records, 1)
+	s.Equal("apikey:"+created.ID, records[0].Actor)
+}
+
+// createAccount creates an account of the owner with the admin key.
+func (s *AuthTestSuite) createAccount(owner string) models.Account {
+	rec := s.do(http.MethodPost, "/accounts", adminKey, fmt.Sprintf(`{"owner":%q,"initial_balance":100}`, owner))
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var acc models.Account
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &acc))
+	return acc
+}
+
+// TestOwnership tests restricting the customers authenticated with a jwt to their own accounts.
+func (s *AuthTestSuite) TestOwnership() {
+	alice := s.createAccount("Alice")
+	bob := s.createAccount("Bob")
+	token, err := s.issuer.CustomerToken("Alice")
+	s.Require().NoError(err)
+
+	s.Run("ok: list own accounts", func() {
+		rec := s.do(http.MethodGet, "/accounts", token, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var accs []models.Account
+		s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &accs))
+		s.Require().Len(accs, 1)
+		s.Equal(alice.ID, accs[0].ID)
+	})
+
+	s.Run("ok: staff list every account", func() {
+		rec := s.do(http.MethodGet, "/accounts", adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var accs []models.Account
+		s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &accs))
+		s.Len(accs, 2)
+	})
+
+	s.Run("ok: own account", func() {
+		rec := s.do(http.MethodGet, "/accounts/"+alice.ID, token, "")
+		s.Equal(http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	for name, data := range map[string]struct{ method, target, body string }{
+		"other account":          {http.MethodGet, "/accounts/" + bob.ID, ""},
+		"other account by iban":  {http.MethodGet, "/accounts/by-number/" + bob.IBAN, ""},
+		"other transactions":     {http.MethodGet, "/accounts/" + bob.ID + "/transactions", ""},
+		"other statement":        {http.MethodGet, "/accounts/" + bob.ID + "/statements/export", ""},
+		"withdraw from other":    {http.MethodPost, "/accounts/" + bob.ID + "/transactions", `{"type":"withdrawal","amount":10}`},
+		"transfer from other":    {http.MethodPost, "/transfer", fmt.Sprintf(`{"from_account_id":%q,"to_account_id":%q,"amount":10}`, bob.ID, alice.ID)},
+		"open account for other": {http.MethodPost, "/accounts", `{"owner":"Bob","initial_balance":10}`},
+	} {
+		s.Run("error: "+name, func() {
+			rec := s.do(data.method, data.target, token, data.body)
+			s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+			s.Contains(rec.Body.String(), "ACCOUNT_ACCESS_DENIED")
+		})
+	}
+
+	s.Run("ok: transfer to other", func() {
+		rec := s.do(http.MethodPost, "/transfer", token, fmt.Sprintf(`{"from_account_id":%q,"to_account_id":%q,"amount":10}`, alice.ID, bob.IBAN))
+		s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		records := s.auditLog.List(audit.Filter{Actor: "jwt:Alice"})
+		s.NotEmpty(records)
+	})
+
+	s.Run("error: invalid jwt", func() {
+		rec := s.do(http.MethodGet, "/accounts", token[:len(token)-4]+"AAAA", "")
+		s.Equal(http.StatusUnauthorized, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "invalid jwt")
+	})
+}
+
+// TestRoles tests enforcing the policies of the roles of the staff, whether they authenticate with an api key or a jwt.
+func (s *AuthTestSuite) TestRoles() {
+	alice := s.createAccount("Alice")
+	bob := s.createAccount("Bob")
+	teller := s.createKey("teller", "accounts:read", "accounts:write", "transactions:read", "transactions:write", "transfers:write", "admin").Key
+	auditor, err := s.issuer.StaffToken("audrey", enum.AuditorRole)
+	s.Require().NoError(err)
+	customer, err := s.issuer.CustomerToken("Alice")
+	s.Require().NoError(err)
+
+	transactions := "/accounts/" + alice.ID + "/transactions"
+	transfer := fmt.Sprintf(`{"from_account_id":%q,"to_account_id":%q,"amount":10}`, alice.ID, bob.ID)
+	for name, data := range map[string]struct {
+		key, method, target, body string
+		status                    int
+	}{
+		"teller opens an account":             {teller, http.MethodPost, "/accounts", `{"owner":"Carol","initial_balance":10}`, http.StatusCreated},
+		"teller deposits into any account":    {teller, http.MethodPost, "/accounts/" + bob.ID + "/transactions", `{"type":"deposit","amount":10}`, http.StatusCreated},
+		"teller reads the transactions":       {teller, http.MethodGet, transactions, "", http.StatusOK},
+		"auditor reads every account":         {auditor, http.MethodGet, "/accounts/" + bob.ID, "", http.StatusOK},
+		"auditor reads the transactions":      {auditor, http.MethodGet, transactions, "", http.StatusOK},
+		"auditor reads the audit log":         {auditor, http.MethodGet, "/admin/audit", "", http.StatusOK},
+		"auditor verifies the audit log":      {auditor, http.MethodGet, "/admin/audit/verify", "", http.StatusOK},
+		"auditor lists the api keys":          {auditor, http.MethodGet, "/admin/api-keys", "", http.StatusOK},
+		"auditor lists the webhooks":          {auditor, http.MethodGet, "/webhooks", "", http.StatusOK},
+		"teller withdraws":                    {teller, http.MethodPost, transactions, `{"type":"withdrawal","amount":10}`, http.StatusForbidden},
+		"teller transfers":                    {teller, http.MethodPost, "/transfer", transfer, http.StatusForbidden},
+		"teller reads the audit log":          {teller, http.MethodGet, "/admin/audit", "", http.StatusForbidden},
+		"teller freezes an account":           {teller, http.MethodPost, "/admin/accounts/" + alice.ID + "/freeze", "", http.StatusForbidden},
+		"auditor opens an account":            {auditor, http.MethodPost, "/accounts", `{"owner":"Carol","initial_balance":10}`, http.StatusForbidden},
+		"auditor deposits":                    {auditor, http.MethodPost, transactions, `{"type":"deposit","amount":10}`, http.StatusForbidden},
+		"auditor runs a reconciliation":       {auditor, http.MethodPost, "/admin/reconciliations", "", http.StatusForbidden},
+		"auditor creates an api key":          {auditor, http.MethodPost, "/admin/api-keys", `{"name":"test","scopes":["admin"]}`, http.StatusForbidden},
+		"auditor subscribes a webhook":        {auditor, http.MethodPost, "/webhooks", `{"url":"https://example.com"}`, http.StatusForbidden},
+		"auditor freezes an account":          {auditor, http.MethodPost, "/admin/accounts/" + alice.ID + "/freeze", "", http.StatusForbidden},
+		"customer reads the audit log":        {customer, http.MethodGet, "/admin/audit", "", http.StatusForbidden},
+		"customer freezes its own account":    {customer, http.MethodPost, "/admin/accounts/" + alice.ID + "/freeze", "", http.StatusForbidden},
+		"customer withdraws from its account": {customer, http.MethodPost, transactions, `{"type":"withdrawal","amount":10}`, http.StatusCreated},
+		"customer deposits into its account":  {customer, http.MethodPost, transactions, `{"type":"deposit","amount":10}`, http.StatusForbidden},
+		"customer opens an empty account":     {customer, http.MethodPost, "/accounts", `{"owner":"Alice","initial_balance":0}`, http.StatusCreated},
+		"customer opens a funded account":     {customer, http.MethodPost, "/accounts", `{"owner":"Alice","initial_balance":1000000}`, http.StatusForbidden},
+	} {
+		prefix := "ok: "
+		if data.status == http.StatusForbidden {
+			prefix = "error: "
+		}
+		s.Run(prefix+name, func() {
+			rec := s.do(data.method, data.target, data.key, data.body)
+			s.Equal(data.status, rec.Code, rec.Body.String())
+			if data.status == http.StatusForbidden {
+				s.Contains(rec.Body.String(), "FORBIDDEN")
+				s.Empty(rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	s.Run("error: missing role", func() {
+		rec := s.do(http.MethodPost, "/admin/api-keys", adminKey, `{"name":"test","scopes":["accounts:read"]}`)
+		s.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "role")
+	})
+
+	s.Run("error: unknown role", func() {
+		rec := s.do(http.MethodPost, "/admin/api-keys", adminKey, `{"name":"test","role":"customer","scopes":["admin"]}`)
+		s.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+	})
+
+	s.Run("error: role allowed but scope missing", func() {
+		key := s.createKey("teller", "accounts:read").Key
+		rec := s.do(http.MethodPost, transactions, key, `{"type":"deposit","amount":10}`)
+		s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), "INSUFFICIENT_SCOPE")
+		s.Equal(`Bearer error="insufficient_scope", scope="transactions:write"`, rec.Header().Get("WWW-Authenticate"))
+	})
+}
+
+// TestFreeze tests freezing accounts, whose money cannot be moved until they are unfrozen.
+func (s *AuthTestSuite) TestFreeze() {
+	alice := s.createAccount("Alice")
+	bob := s.createAccount("Bob")
+	admin, err := s.issuer.StaffToken("ada", enum.AdminRole)
+	s.Require().NoError(err)
+	freezePath := "/admin/accounts/" + alice.ID + "/freeze"
+
+	s.Run("ok: freeze", func() {
+		rec := s.do(http.MethodPost, freezePath, admin, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		var acc models.Account
+		s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &acc))
+		s.True(acc.Frozen)
+
+		records := s.auditLog.List(audit.Filter{Action: audit.FreezeAccount})
+		s.Require().Len(records, 1)
+		s.Equal("jwt:ada", records[0].Actor)
+		s.Equal(alice.ID, records[0].EntityID)
+	})
+
+	for name, data := range map[string]struct{ target, body string }{
+		"deposit":       {"/accounts/" + alice.ID + "/transactions", `{"type":"deposit","amount":10}`},
+		"transfer from": {"/transfer", fmt.Sprintf(`{"from_account_id":%q,"to_account_id":%q,"amount":10}`, alice.ID, bob.ID)},
+		"transfer to":   {"/transfer", fmt.Sprintf(`{"from_account_id":%q,"to_account_id":%q,"amount":10}`, bob.ID, alice.ID)},
+	} {
+		s.Run("error: "+name+" frozen account", func() {
+			rec := s.do(http.MethodPost, data.target, adminKey, data.body)
+			s.Equal(http.StatusConflict, rec.Code, rec.Body.String())
+			s.Contains(rec.Body.String(), "ACCOUNT_FROZEN")
+		})
+	}
+
+	s.Run("ok: read frozen account", func() {
+		rec := s.do(http.MethodGet, "/v2/accounts/"+alice.ID, adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), `"frozen":true`)
+	})
+
+	s.Run("ok: unfreeze", func() {
+		rec := s.do(http.MethodDelete, freezePath, adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.NotContains(rec.Body.String(), "frozen")
+
+		rec = s.do(http.MethodPost, "/accounts/"+alice.ID+"/transactions", adminKey, `{"type":"deposit","amount":10}`)
+		s.Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	s.Run("error: unknown account", func() {
+		rec := s.do(http.MethodPost, "/admin/accounts/"+uuid.NewString()+"/freeze", adminKey, "")
+		s.Contains(rec.Body.String(), "ACCOUNT_NOT_FOUND")
+	})
+}
+
+// TestLongLivedConnections tests closing the websocket connections whose credentials are revoked or expire.
+func (s *AuthTestSuite) TestLongLivedConnections() {
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	account := s.createAccount("Alice")
+
+	dial := func(credentials string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + credentials}})
+		s.Require().NoError(err)
+		return conn
+	}
+	// closeCode reads the messages of the connection until it is closed and returns the code of the close frame.
+	closeCode := func(conn *websocket.Conn) int {
+		s.Require().NoError(conn.SetReadDeadline(time.Now().Add(3 * time.Second)))
+		for {
+			var m ws.Message
+			err := conn.ReadJSON(&m)
+			var closeError *websocket.CloseError
+			if stderrors.As(err, &closeError) {
+				return closeError.Code
+			}
+			s.Require().NoError(err)
+		}
+	}
+
+	s.Run("error: revoked key", func() {
+		created := s.createKey("admin", "accounts:read")
+		conn := dial(created.Key)
+		defer conn.Close()
+		s.Require().NoError(conn.WriteJSON(ws.Command{ID: "1", Type: ws.Subscribe, Payload: json.RawMessage(fmt.Sprintf(`{"account_ids":[%q]}`, account.ID))}))
+		var ack ws.Message
+		s.Require().NoError(conn.ReadJSON(&ack))
+		s.Equal(ws.Ack, ack.Type)
+
+		rec := s.do(http.MethodDelete, "/admin/api-keys/"+created.ID, adminKey, "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.Require().NoError(conn.WriteJSON(ws.Command{ID: "2", Type: ws.Subscribe, Payload: json.RawMessage(fmt.Sprintf(`{"account_ids":[%q]}`, account.ID))}))
+		s.Equal(websocket.ClosePolicyViolation, closeCode(conn))
+	})
+
+	s.Run("error: expired token", func() {
+		// the token is accepted within the clock skew of a minute, so it expires a second after the connection is opened
+		token, err := s.issuer.Token(auth.RS256, map[string]any{"sub": "Alice", "exp": time.Now().Add(-59 * time.Second).Unix()})
+		s.Require().NoError(err)
+		conn := dial(token)
+		defer conn.Close()
+		s.Equal(websocket.ClosePolicyViolation, closeCode(conn))
+	})
+}
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
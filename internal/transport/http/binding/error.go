@@ -2,6 +2,7 @@ package binding
 
 import (
 	errors "bank_test/internal/api_errors"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -13,16 +14,30 @@ func handleBindingErrors(err error) error {
 
 	fieldName := validationErr.Field()
 
-	apiError := errors.ErrInvalidBody
+	apiError := *errors.ErrInvalidBody
 
 	switch validationErr.Tag() {
 	case "required":
 		apiError.Message = fieldName + " is required and must be a " + validationErr.Type().String()
 	case "oneof":
 		apiError.Message = fieldName + " must be one of: " + strings.Join(strings.Split(validationErr.Param(), " "), ", ")
+	case "http_url":
+		apiError.Message = fieldName + " must be a valid http or https URL"
+	case "min":
+		if validationErr.Kind() == reflect.Slice {
+			apiError.Message = fieldName + " must have at least " + validationErr.Param() + " items"
+		} else {
+			apiError.Message = fieldName + " must be at least " + validationErr.Param() + " characters long"
+		}
+	case "numeric":
+		apiError.Message = fieldName + " must be a decimal number, such as \"10.50\""
+	case "uuid":
+		apiError.Message = fieldName + " must be a valid UUID"
+	case "uuid|iban":
+		apiError.Message = fieldName + " must be a valid account id (UUID) or IBAN"
 	default:
 		apiError.Message = err.Error()
 	}
 
-	return apiError
+	return &apiError
 }
@@ -1,6 +1,7 @@
 package binding
 
 import (
+	"bank_test/internal/iban"
 	"encoding/json"
 	"net/http"
 	"reflect"
@@ -16,6 +17,12 @@ func DecodeJSONBody(r *http.Request, v interface{}) error {
 	}
 
 	// validate the decode body
+	return Validate(v)
+}
+
+// Validate validates the given struct with the rules of its 'validate' tags. Fields are named after their json tags
+// in the errors.
+func Validate(v interface{}) error {
 	validate := validator.New()
 
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
@@ -28,6 +35,11 @@ func DecodeJSONBody(r *http.Request, v interface{}) error {
 		return name
 	})
 
+	// iban validates that a string field is a valid IBAN
+	validate.RegisterValidation("iban", func(fl validator.FieldLevel) bool {
+		return iban.Validate(fl.Field().String()) == nil
+	})
+
 	err := validate.Struct(v)
 	if err != nil {
 		return handleBindingErrors(err)
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"bank_test/internal/transport/http/openapi"
+	"net/http"
+)
+
+// getSpecification is an endpoint that serves the OpenAPI specification of the version of the API of the request.
+func (h *handler) getSpecification(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get specification endpoint called")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Document(apiVersion(r.Context())))
+}
+
+// getDocs is an endpoint that serves a Swagger UI page to browse the OpenAPI specification of the API.
+func (h *handler) getDocs(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get docs endpoint called")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.SwaggerUI())
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/enum"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/transport/http/openapi"
+	"bank_test/internal/webhook"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type DocsTestSuite struct {
+	suite.Suite
+	router http.Handler
+	specs  map[enum.APIVersion]*openapi.Spec
+}
+
+func (s *DocsTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	conf.NewConfig()
+	conf.GlobalConfig.IBANCountryCode = "ES"
+	conf.GlobalConfig.IBANBankCode = "01820001"
+	conf.GlobalConfig.Currency = "EUR"
+	conf.GlobalConfig.ActivityHeartbeatInterval = time.Second
+	conf.GlobalConfig.OpenAPIValidation = true
+
+	auditLog, err := audit.NewLog(logger, "")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	db := memory.NewInMemoryDatabase(logger)
+	webhooks, err := webhook.NewStore(logger, "")
+	s.Require().NoError(err)
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{})
+
+	authenticator, err := auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	s.router, err = NewHttpTransport(logger, db, reconciliation.NewReconciler(logger, db), auditLog, webhooks, dispatcher, feed, authenticator).Handler()
+	s.Require().NoError(err)
+	s.specs = make(map[enum.APIVersion]*openapi.Spec)
+	for _, version := range apiVersions {
+		s.specs[version], err = openapi.Load(version)
+		s.Require().NoError(err)
+	}
+}
+
+// TestRoutesAreDocumented tests that every route of the router is documented in every version, and that every
+// documented operation is routed under the prefix of its version and without prefix.
+func (s *DocsTestSuite) TestRoutesAreDocumented() {
+	routed := make(map[string]bool)
+	err := chi.Walk(s.router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed[method+" "+route] = true
+		for version, spec := range s.specs {
+			s.NotNil(spec.Operation(method, unversionedPath(route)), "%s %s is not documented in %s", method, route, version)
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	for _, version := range apiVersions {
+		var doc struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		s.Require().NoError(json.Unmarshal(openapi.Document(version), &doc))
+		for path, operations := range doc.Paths {
+			for method := range operations {
+				for _, route := range []string{path, "/" + version.String() + path} {
+					s.True(routed[strings.ToUpper(method)+" "+route], "%s %s is documented but not routed", method, route)
+				}
+			}
+		}
+	}
+}
+
+// TestErrorCodesAreDocumented tests that the codes of the errors declared in the api_errors package are the ones of
+// the ErrorCode schema.
+func (s *DocsTestSuite) TestErrorCodesAreDocumented() {
+	file, err := parser.ParseFile(token.NewFileSet(), "../../api_errors/errors.go", nil, 0)
+	s.Require().NoError(err)
+
+	declared := make([]string, 0)
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if fn, ok := call.Fun.(*ast.Ident); ok && fn.Name == "NewAPIError" {
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok {
+				code, err := strconv.Unquote(lit.Value)
+				s.Require().NoError(err)
+				declared = append(declared, code)
+			}
+		}
+		return true
+	})
+
+	sort.Strings(declared)
+
+	for version, spec := range s.specs {
+		documented := make([]string, 0)
+		for _, code := range spec.Schema("ErrorCode").Enum {
+			documented = append(documented, code.(string))
+		}
+		sort.Strings(documented)
+		s.Equal(declared, documented, version)
+	}
+}
+
+// TestDocs tests serving the specification and the Swagger UI.
+func (s *DocsTestSuite) TestDocs() {
+	for target, data := range map[string]struct {
+		accept   string
+		expected enum.APIVersion
+	}{
+		"/openapi.json":    {"", enum.V1},
+		"/v1/openapi.json": {"", enum.V1},
+		"/v2/openapi.json": {"", enum.V2},
+		"/openapi.json v2": {"application/vnd.bank.v2+json", enum.V2},
+	} {
+		s.Run("ok: specification "+target, func() {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, strings.Fields(target)[0], nil)
+			req.Header.Set("Accept", data.accept)
+			s.router.ServeHTTP(rec, req)
+			s.Equal(http.StatusOK, rec.Code)
+			s.Equal("application/json", rec.Header().Get("Content-Type"))
+			s.JSONEq(string(openapi.Document(data.expected)), rec.Body.String())
+		})
+	}
+
+	s.Run("ok: swagger ui", func() {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		s.Equal(http.StatusOK, rec.Code)
+		s.Contains(rec.Body.String(), `url: "openapi.json"`)
+	})
+}
+
+// TestValidateSpec tests the validation of the requests and the responses against the specification.
+func (s *DocsTestSuite) TestValidateSpec() {
+	s.Run("ok: valid request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`{"owner":"Alice","initial_balance":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(rec, req)
+		s.Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	s.Run("ok: rejected invalid request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`{"owner":""}`))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(rec, req)
+		s.Equal(http.StatusBadRequest, rec.Code)
+		s.Contains(rec.Body.String(), "INVALID_BODY")
+	})
+
+	s.Run("error: accepted invalid request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit?action=account.delete", nil)
+		s.router.ServeHTTP(rec, req)
+		s.Equal(http.StatusInternalServerError, rec.Code)
+		s.Contains(rec.Body.String(), "SPEC_VIOLATION")
+		s.Contains(rec.Body.String(), "query parameter 'action'")
+	})
+}
+
+// TestDocsSuite runs the test suite.
+func TestDocsSuite(t *testing.T) {
+	suite.Run(t, new(DocsTestSuite))
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// lastEventIDHeader is the header sent by the clients when they reconnect to an event stream, with the id of the
+	// last event they received.
+	lastEventIDHeader = "Last-Event-ID"
+
+	// resetEvent tells the client that the events after its Last-Event-ID are no longer buffered, so it must reload the
+	// state of the account.
+	resetEvent = "reset"
+)
+
+// streamAccountEvents is an endpoint that streams the activity of an account as Server-Sent Events: every committed
+// transaction and the resulting balance. Clients that reconnect with the Last-Event-ID header receive the buffered
+// events they missed. The stream of an authenticated caller ends when its credentials expire or are revoked.
+func (h *handler) streamAccountEvents(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("stream account events endpoint called")
+
+	// decode the account id from the request
+	h.logger.Debugf("decoding account id from the request")
+	accID := chi.URLParam(r, "id")
+	if accID == "" {
+		h.wrapError(w, r, errors.ErrAccountIdIsMissing)
+		return
+	}
+
+	// check if the account id is valid uuid
+	if err := uuid.Validate(accID); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidAccountID)
+		return
+	}
+	h.logger.Debugf("account id decoded successfully: %s", accID)
+
+	var lastID uint64
+	if value := r.Header.Get(lastEventIDHeader); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			h.wrapError(w, r, errors.ErrInvalidLastEventID)
+			return
+		}
+		lastID = id
+	}
+
+	if _, err := h.as.GetAccountByID(r.Context(), accID); err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.wrapError(w, r, errors.ErrStreamingNotSupported)
+		return
+	}
+
+	sub := h.feed.Subscribe(accID, lastID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// the stream is closed when the credentials of the caller expire or are revoked
+	ctx := r.Context()
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = h.auth.Watch(ctx, p)
+		defer cancel()
+	}
+
+	h.logger.Debugf("streaming events of account %s from event %d", accID, lastID)
+	err := streamEvents(ctx, w, flusher, sub, conf.GlobalConfig.ActivityHeartbeatInterval)
+	if ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+	h.logger.Infof("event stream of account %s closed: %v", accID, err)
+}
+
+// streamEvents writes the backlog of the subscription and then its new events until the context is done or the
+// subscription is dropped. A comment is written every heartbeat interval so that proxies keep the connection open.
+func streamEvents(ctx context.Context, w io.Writer, flusher http.Flusher, sub *activity.Subscription, heartbeat time.Duration) error {
+	if sub.Reset {
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: {}\n\n", resetEvent); err != nil {
+			return err
+		}
+	}
+	for _, e := range sub.Backlog {
+		if err := writeEvent(w, e); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				// the subscriber fell behind. The client reconnects and resumes from the buffer
+				return fmt.Errorf("subscriber dropped")
+			}
+			if err := writeEvent(w, e); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event in the Server-Sent Events format. The data is JSON, so it never spans several lines.
+func writeEvent(w io.Writer, e activity.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
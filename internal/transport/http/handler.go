@@ -1,37 +1,76 @@
 package http
 
 import (
+	"bank_test/internal/activity"
 	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
 	"bank_test/internal/db"
+	"bank_test/internal/enum"
 	"bank_test/internal/helpers"
+	"bank_test/internal/iban"
+	"bank_test/internal/payments"
+	"bank_test/internal/reconciliation"
 	"bank_test/internal/service"
-	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/statement"
 	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/webhook"
 	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// actorHeader is the header used by the clients to identify who is performing a request. It is recorded in the audit
+// log, unless authentication is enabled: the actor is then the api key or the jwt of the request.
+const actorHeader = "X-Actor"
+
 type handler struct {
 	logger *zap.SugaredLogger
 	db     db.DatabaseAdapter
+	ibans  *iban.Generator
 
 	// services
 	as service.AccountService
 	ts service.TransactionService
+
+	reconciler *reconciliation.Reconciler
+	auditLog   *audit.Log
+	webhooks   *webhook.Store
+	dispatcher *webhook.Dispatcher
+	feed       *activity.Feed
+	statements *statement.Renderer
+	payments   *payments.Processor
+	auth       *auth.Authenticator
 }
 
 // newHandler creates a new handler.
-func newHandler(logger *zap.SugaredLogger, db db.DatabaseAdapter) *handler {
+func newHandler(logger *zap.SugaredLogger, db db.DatabaseAdapter, ibans *iban.Generator, messages *payments.MessageStore, reconciler *reconciliation.Reconciler, auditLog *audit.Log, webhooks *webhook.Store, dispatcher *webhook.Dispatcher, feed *activity.Feed, authenticator *auth.Authenticator) *handler {
 	// initiate services
-	as := service.NewAccountService(logger, db)
+	as := service.NewAccountService(logger, db, ibans)
 	ts := service.NewTransactionService(logger, db)
 
-	return &handler{logger: logger, db: db, as: as, ts: ts}
+	statements := statement.NewRenderer(conf.GlobalConfig.Currency, conf.GlobalConfig.IBANBankCode)
+	processor := payments.NewProcessor(logger, as, ts, conf.GlobalConfig.Currency, messages)
+
+	return &handler{logger: logger, db: db, ibans: ibans, as: as, ts: ts, reconciler: reconciler, auditLog: auditLog, webhooks: webhooks, dispatcher: dispatcher, feed: feed, statements: statements, payments: processor, auth: authenticator}
+}
+
+// auditMetadata is a middleware that stores the audit metadata of the request (actor and request id) in its
+// context, so that it is recorded with every mutation performed while handling the request.
+func (h *handler) auditMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithMetadata(r.Context(), audit.Metadata{
+			Actor:     r.Header.Get(actorHeader),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // createAccount is an endpoint that creates a new account.
@@ -40,19 +79,23 @@ func (h *handler) createAccount(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debugf("decoding request body")
 	var body schemas.CreateAccountRequest
-	if err := binding.DecodeJSONBody(r, &body); err != nil {
-		e := errors.ErrInvalidBody
+	if err := decodeBody(r, &body); err != nil {
+		e := *errors.ErrInvalidBody
 		e.Message = fmt.Sprintf("failed to decode request body: %v", err)
-		h.wrapError(w, r, e)
+		h.wrapError(w, r, &e)
 		return
 	}
 	h.logger.Debugf("request body decoded successfully: %s", helpers.PrettyPrintStructResponse(body))
 
 	h.logger.Debugf("creating account for owner %s", body.Owner)
-	acc := h.as.CreateAccount(&body)
+	acc, err := h.as.CreateAccount(r.Context(), &body)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
 	h.logger.Info("account created successfully")
 	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, acc)
+	render.JSON(w, r, versioned(r, acc))
 }
 
 // getAccount is an endpoint that retrieves an account by its id.
@@ -76,14 +119,45 @@ func (h *handler) getAccount(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debugf("account id decoded successfully: %s", accID)
 
 	h.logger.Debugf("getting account with id %s", accID)
-	acc, err := h.as.GetAccountByID(accID)
+	acc, err := h.as.GetAccountByID(r.Context(), accID)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Info("account retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, versioned(r, acc))
+}
+
+// getAccountByIBAN is an endpoint that retrieves an account by its iban.
+func (h *handler) getAccountByIBAN(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get account by iban endpoint called")
+
+	// decode the account number from the request
+	h.logger.Debugf("decoding account number from the request")
+	number := chi.URLParam(r, "iban")
+	if number == "" {
+		h.wrapError(w, r, errors.ErrAccountIdIsMissing)
+		return
+	}
+
+	// check if the account number is a valid iban
+	if err := iban.Validate(number); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidIBAN)
+		return
+	}
+
+	h.logger.Debugf("account number decoded successfully: %s", number)
+
+	h.logger.Debugf("getting account with iban %s", number)
+	acc, err := h.as.GetAccountByIBAN(r.Context(), number)
 	if err != nil {
 		h.wrapError(w, r, err)
 		return
 	}
 	h.logger.Info("account retrieved successfully")
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, acc)
+	render.JSON(w, r, versioned(r, acc))
 }
 
 // getAllAccounts is an endpoint that retrieves all accounts.
@@ -91,10 +165,14 @@ func (h *handler) getAllAccounts(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("get all accounts endpoint called")
 
 	h.logger.Info("getting all accounts")
-	accs := h.as.GetAllAccounts()
+	accs, err := h.as.GetAllAccounts(r.Context())
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
 	h.logger.Info("all accounts retrieved successfully")
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, accs)
+	render.JSON(w, r, versioned(r, accs))
 }
 
 // createTransaction creates a new transaction by either depositing money or withdrawing it.
@@ -118,23 +196,32 @@ func (h *handler) createTransaction(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debugf("decoding request body")
 	var body schemas.CreateTransactionRequest
-	if err := binding.DecodeJSONBody(r, &body); err != nil {
-		bodyErr := errors.ErrInvalidBody
+	if err := decodeBody(r, &body); err != nil {
+		bodyErr := *errors.ErrInvalidBody
 		bodyErr.Message = fmt.Sprintf("failed to decode request body: %v", err)
-		h.wrapError(w, r, bodyErr)
+		h.wrapError(w, r, &bodyErr)
 		return
 	}
 	h.logger.Debugf("request body decoded successfully: %s", helpers.PrettyPrintStructResponse(body))
 
+	// the operation of the route depends on the type of the transaction: tellers can deposit but not withdraw
+	op := auth.Withdraw
+	if body.Type == enum.Deposit.String() {
+		op = auth.Deposit
+	}
+	if !h.authorized(w, r, op) {
+		return
+	}
+
 	h.logger.Debugf("creating transaction for account %s", accID)
-	acc, err := h.ts.CreateTransaction(accID, &body)
+	acc, err := h.ts.CreateTransaction(r.Context(), accID, &body)
 	if err != nil {
 		h.wrapError(w, r, err)
 		return
 	}
 	h.logger.Info("transaction created successfully")
 	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, acc)
+	render.JSON(w, r, versioned(r, acc))
 }
 
 // getTransactionsByAccountID retrieves all transactions for the account.
@@ -158,14 +245,14 @@ func (h *handler) getTransactionsByAccountID(w http.ResponseWriter, r *http.Requ
 	h.logger.Debugf("account id decoded successfully: %s", accID)
 
 	h.logger.Debugf("getting all transactions for account with id %s", accID)
-	txs, err := h.ts.GetTransactionsByAccountID(accID)
+	txs, err := h.ts.GetTransactionsByAccountID(r.Context(), accID)
 	if err != nil {
 		h.wrapError(w, r, err)
 		return
 	}
 	h.logger.Info("all transactions retrieved successfully")
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, txs)
+	render.JSON(w, r, versioned(r, txs))
 }
 
 // transfer is an endpoint that transfers money from one account to another.
@@ -175,16 +262,16 @@ func (h *handler) transfer(w http.ResponseWriter, r *http.Request) {
 	// decode the request body
 	h.logger.Debugf("decoding request body")
 	var body schemas.TransferRequest
-	if err := binding.DecodeJSONBody(r, &body); err != nil {
-		bodyErr := errors.ErrInvalidBody
+	if err := decodeBody(r, &body); err != nil {
+		bodyErr := *errors.ErrInvalidBody
 		bodyErr.Message = fmt.Sprintf("failed to decode request body: %v", err)
-		h.wrapError(w, r, bodyErr)
+		h.wrapError(w, r, &bodyErr)
 		return
 	}
 	h.logger.Debugf("request body decoded successfully: %s", helpers.PrettyPrintStructResponse(body))
 
 	h.logger.Debugf("transferring money from account %s to account %s", body.FromAccountId, body.ToAccountId)
-	if err := h.ts.Transfer(body.FromAccountId, body.ToAccountId, *body.Amount); err != nil {
+	if err := h.ts.Transfer(r.Context(), body.FromAccountId, body.ToAccountId, *body.Amount); err != nil {
 		h.wrapError(w, r, err)
 		return
 	}
@@ -197,10 +284,10 @@ func (h *handler) transfer(w http.ResponseWriter, r *http.Request) {
 func (h *handler) wrapError(w http.ResponseWriter, r *http.Request, err error) {
 	apiError, ok := err.(*errors.APIError)
 	if !ok {
-		unknownError := errors.ErrUnknown
+		unknownError := *errors.ErrUnknown
 		unknownError.Message = err.Error()
-		h.logger.Error(unknownError)
-		render.JSON(w, r, unknownError)
+		h.logger.Error(&unknownError)
+		render.JSON(w, r, &unknownError)
 		return
 	}
 
@@ -0,0 +1,206 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/enum"
+	"bank_test/internal/idempotency"
+	"bank_test/internal/transport/http/openapi"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// idempotencyKeyHeader is the header used by the clients to make a request safe to retry. Retries sent with the
+	// same key get the response of the first attempt instead of performing it again.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader is set on the responses replayed from the idempotency store.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key.
+	maxIdempotencyKeyLength = 255
+)
+
+// requestTimeout is a middleware that cancels the context of the request once the timeout has elapsed, so that the
+// services and the database stop working on it. A zero timeout disables it.
+func requestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// idempotent is a middleware that records the responses of the requests sent with an idempotency key, and replays
+// them to the retries of the request. Keys are scoped to the actor of the request, and a key can only be reused with
+// the same method, path and body. Server errors are not recorded, so that the request can be retried.
+func (h *handler) idempotent(store *idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				h.wrapError(w, r, errors.ErrInvalidIdempotencyKey)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				e := *errors.ErrInvalidBody
+				e.Message = "failed to read request body: " + err.Error()
+				h.wrapError(w, r, &e)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key = audit.MetadataFrom(r.Context()).Actor + "\x00" + key
+			sum := sha256.Sum256(body)
+			fingerprint := r.Method + " " + r.URL.Path + " " + hex.EncodeToString(sum[:])
+
+			replay, err := store.Begin(key, fingerprint)
+			if err != nil {
+				h.wrapError(w, r, err)
+				return
+			}
+			if replay != nil {
+				for name, values := range replay.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(replay.Status)
+				w.Write(replay.Body)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			finished := false
+			defer func() {
+				// panics, canceled requests and server errors may not have been processed, so they can be retried
+				// with the key
+				if !finished || rec.status >= errors.ErrRequestCanceled.HTTPStatus {
+					store.Abort(key)
+					return
+				}
+				store.Complete(key, &idempotency.Response{Status: rec.status, Header: w.Header().Clone(), Body: rec.body.Bytes()})
+			}()
+			next.ServeHTTP(rec, r)
+			finished = true
+		})
+	}
+}
+
+// responseRecorder writes the response to the client and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// validateSpec is a middleware that validates the requests and the responses of the documented operations against the
+// OpenAPI specification of their version, so that the specifications cannot drift from the API. Responses are buffered
+// to be validated, except the streamed ones. A response that does not match the specification, or a successful
+// response to a request that does not match it, is replaced with ErrSpecViolation.
+func (h *handler) validateSpec(specs map[enum.APIVersion]*openapi.Spec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, params := specs[apiVersion(r.Context())].Find(r.Method, unversionedPath(r.URL.Path))
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				e := *errors.ErrInvalidBody
+				e.Message = "failed to read request body: " + err.Error()
+				h.wrapError(w, r, &e)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			requestErr := op.ValidateRequest(r, params, body)
+
+			if op.Streaming() {
+				if requestErr != nil {
+					h.logger.Errorf("request %s %s does not match the openapi specification: %v", r.Method, r.URL.Path, requestErr)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buf := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			violation := op.ValidateResponse(buf.status, buf.header, buf.body.Bytes())
+			if violation == nil && requestErr != nil && buf.status < http.StatusBadRequest {
+				violation = fmt.Errorf("invalid request was accepted: %w", requestErr)
+			}
+			if violation != nil {
+				e := *errors.ErrSpecViolation
+				e.Message = fmt.Sprintf("%s %s does not match the openapi specification: %v", r.Method, r.URL.Path, violation)
+				h.wrapError(w, r, &e)
+				return
+			}
+
+			for name, values := range buf.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+		})
+	}
+}
+
+// bufferedResponse keeps a response in memory instead of writing it to the client.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,342 @@
+// Package openapi holds the OpenAPI 3.1 specifications of the versions of the HTTP API, and validates the requests and
+// the responses of the API against them. The specifications are maintained by hand in v1.json and v2.json and embedded
+// in the binary, together with a Swagger UI page to browse them.
+package openapi
+
+import (
+	"bank_test/internal/enum"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//go:embed v1.json
+var v1 []byte
+
+//go:embed v2.json
+var v2 []byte
+
+//go:embed swagger.html
+var swaggerUI []byte
+
+// Document returns the specification of a version of the API in JSON. Unknown versions get the one of the version 1.
+func Document(version enum.APIVersion) []byte {
+	if version == enum.V2 {
+		return v2
+	}
+	return v1
+}
+
+// SwaggerUI returns the HTML page that renders the specification served at openapi.json, relative to the page, with
+// Swagger UI.
+func SwaggerUI() []byte {
+	return swaggerUI
+}
+
+// Spec is the parsed specification.
+type Spec struct {
+	paths   map[string]map[string]*Operation // operations by path template and method
+	schemas map[string]*Schema
+}
+
+// Operation is an operation of the specification: a method on a path.
+type Operation struct {
+	ID          string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter of an operation.
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body of the requests of an operation, by content type.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation, by content type. Responses without content have an empty body.
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+// MediaType describes a content type of a body. Only the JSON bodies have a schema.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the embedded specification of a version of the API and resolves its references.
+func Load(version enum.APIVersion) (*Spec, error) {
+	var doc struct {
+		OpenAPI    string                           `json:"openapi"`
+		Paths      map[string]map[string]*Operation `json:"paths"`
+		Components struct {
+			Schemas    map[string]*Schema    `json:"schemas"`
+			Parameters map[string]*Parameter `json:"parameters"`
+			Responses  map[string]*Response  `json:"responses"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(Document(version), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the openapi specification of %s: %w", version, err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.1.") {
+		return nil, fmt.Errorf("unsupported openapi version '%s'", doc.OpenAPI)
+	}
+
+	schemas := doc.Components.Schemas
+	for _, schema := range schemas {
+		if err := schema.resolve(schemas); err != nil {
+			return nil, err
+		}
+	}
+
+	spec := &Spec{paths: make(map[string]map[string]*Operation), schemas: schemas}
+	for template, operations := range doc.Paths {
+		spec.paths[template] = make(map[string]*Operation)
+		for method, op := range operations {
+			for i, param := range op.Parameters {
+				if param.Ref != "" {
+					ref, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: unknown parameter reference '%s'", method, template, param.Ref)
+					}
+					op.Parameters[i], param = ref, ref
+				}
+			}
+			for status, resp := range op.Responses {
+				if resp.Ref != "" {
+					ref, ok := doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: unknown response reference '%s'", method, template, resp.Ref)
+					}
+					op.Responses[status] = ref
+				}
+			}
+
+			for _, param := range op.Parameters {
+				if err := param.Schema.resolve(schemas); err != nil {
+					return nil, err
+				}
+			}
+			for _, media := range op.content() {
+				if err := media.Schema.resolve(schemas); err != nil {
+					return nil, err
+				}
+			}
+			spec.paths[template][strings.ToUpper(method)] = op
+		}
+	}
+	for _, resp := range doc.Components.Responses {
+		for _, media := range resp.Content {
+			if err := media.Schema.resolve(schemas); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return spec, nil
+}
+
+// content returns the media types of the request body and of the responses of the operation.
+func (o *Operation) content() []*MediaType {
+	media := make([]*MediaType, 0)
+	if o.RequestBody != nil {
+		for _, m := range o.RequestBody.Content {
+			media = append(media, m)
+		}
+	}
+	for _, resp := range o.Responses {
+		for _, m := range resp.Content {
+			media = append(media, m)
+		}
+	}
+	return media
+}
+
+// Operation returns the operation of the method on the path template, such as /accounts/{id}, or nil if it is not
+// documented.
+func (s *Spec) Operation(method, template string) *Operation {
+	return s.paths[template][method]
+}
+
+// Schema returns the schema of the components with the name, or nil if there is none.
+func (s *Spec) Schema(name string) *Schema {
+	return s.schemas[name]
+}
+
+// Find returns the operation that serves the method on the path, and the values of the parameters of its path, or nil
+// if it is not documented. Literal segments take precedence over parameters, as they do in the router.
+func (s *Spec) Find(method, path string) (*Operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var found *Operation
+	var foundParams map[string]string
+	for template, operations := range s.paths {
+		op, ok := operations[method]
+		if !ok {
+			continue
+		}
+		params, ok := match(strings.Split(strings.Trim(template, "/"), "/"), segments)
+		if ok && (found == nil || len(params) < len(foundParams)) {
+			found, foundParams = op, params
+		}
+	}
+	return found, foundParams
+}
+
+// match matches the segments of a path with the ones of a template, and returns the values of its parameters.
+func match(template, segments []string) (map[string]string, bool) {
+	if len(template) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[t[1:len(t)-1]] = segments[i]
+			continue
+		}
+		if t != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Streaming reports whether the responses of the operation are streamed, such as event streams and WebSocket
+// connections, which cannot be validated.
+func (o *Operation) Streaming() bool {
+	for status, resp := range o.Responses {
+		if status == strconv.Itoa(http.StatusSwitchingProtocols) {
+			return true
+		}
+		if _, ok := resp.Content["text/event-stream"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRequest checks the parameters and the body of a request against the operation. The path parameters are the
+// ones returned by Find.
+func (o *Operation) ValidateRequest(r *http.Request, pathParams map[string]string, body []byte) error {
+	query := r.URL.Query()
+	for _, param := range o.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = pathParams[param.Name]
+		case "query":
+			present = query.Has(param.Name)
+			value = query.Get(param.Name)
+		case "header":
+			value = r.Header.Get(param.Name)
+			present = value != ""
+		}
+		location := fmt.Sprintf("%s parameter '%s'", param.In, param.Name)
+		if !present {
+			if param.Required {
+				return fmt.Errorf("%s: missing", location)
+			}
+			continue
+		}
+		if err := param.Schema.Validate(parseParameter(value, param.Schema), location); err != nil {
+			return err
+		}
+	}
+
+	if o.RequestBody == nil {
+		return nil
+	}
+	if len(body) == 0 {
+		if o.RequestBody.Required {
+			return fmt.Errorf("request body: missing")
+		}
+		return nil
+	}
+	return validateContent(o.RequestBody.Content, r.Header.Get("Content-Type"), body, "request body")
+}
+
+// ValidateResponse checks the status, the content type and the body of a response against the operation.
+func (o *Operation) ValidateResponse(status int, header http.Header, body []byte) error {
+	code := strconv.Itoa(status)
+	resp, ok := o.Responses[code]
+	if !ok {
+		resp, ok = o.Responses[code[:1]+"XX"]
+	}
+	if !ok {
+		resp, ok = o.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("response: undocumented status %d", status)
+	}
+
+	location := fmt.Sprintf("response %d", status)
+	if len(resp.Content) == 0 {
+		if len(strings.TrimSpace(string(body))) > 0 {
+			return fmt.Errorf("%s: unexpected body", location)
+		}
+		return nil
+	}
+	return validateContent(resp.Content, header.Get("Content-Type"), body, location)
+}
+
+// validateContent checks that the content type of a body is one of the documented ones, and validates the JSON bodies
+// against their schema.
+func validateContent(content map[string]*MediaType, contentType string, body []byte, location string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s: invalid content type '%s'", location, contentType)
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s: undocumented content type '%s'", location, mediaType)
+	}
+	if mediaType != "application/json" || media.Schema == nil {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s: invalid json: %w", location, err)
+	}
+	return media.Schema.Validate(value, location)
+}
+
+// parseParameter converts the value of a parameter to the type of its schema, so that it can be validated. Values
+// that cannot be converted are left as strings, which fails the validation.
+func parseParameter(value string, schema *Schema) any {
+	for schema != nil && schema.resolved != nil {
+		schema = schema.resolved
+	}
+	if schema == nil {
+		return value
+	}
+	for _, t := range schema.Type {
+		switch t {
+		case "integer", "number":
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				return v
+			}
+		case "boolean":
+			if v, err := strconv.ParseBool(value); err == nil {
+				return v
+			}
+		}
+	}
+	return value
+}
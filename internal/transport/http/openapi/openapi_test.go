@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"bank_test/internal/enum"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the test suite
+type OpenAPITestSuite struct {
+	suite.Suite
+	spec *Spec
+}
+
+func (s *OpenAPITestSuite) SetupTest() {
+	spec, err := Load(enum.V1)
+	s.Require().NoError(err)
+	s.spec = spec
+}
+
+// TestFind tests finding the operation of a request.
+func (s *OpenAPITestSuite) TestFind() {
+	s.Run("ok: path parameters", func() {
+		op, params := s.spec.Find(http.MethodGet, "/accounts/0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11/transactions")
+		s.Require().NotNil(op)
+		s.Equal("listTransactions", op.ID)
+		s.Equal(map[string]string{"id": "0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11"}, params)
+	})
+
+	s.Run("ok: literal segments first", func() {
+		op, params := s.spec.Find(http.MethodGet, "/webhooks/dead-letters")
+		s.Require().NotNil(op)
+		s.Equal("listDeadLetters", op.ID)
+		s.Empty(params)
+	})
+
+	s.Run("error: undocumented", func() {
+		op, _ := s.spec.Find(http.MethodPatch, "/accounts")
+		s.Nil(op)
+		op, _ = s.spec.Find(http.MethodGet, "/accounts/1/unknown")
+		s.Nil(op)
+	})
+}
+
+// TestValidateRequest tests validating requests against their operation.
+func (s *OpenAPITestSuite) TestValidateRequest() {
+	validate := func(method, target, body string) error {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		op, params := s.spec.Find(method, r.URL.Path)
+		s.Require().NotNil(op)
+		return op.ValidateRequest(r, params, []byte(body))
+	}
+
+	s.Run("ok", func() {
+		s.NoError(validate(http.MethodPost, "/accounts", `{"owner":"Alice","initial_balance":100}`))
+		s.NoError(validate(http.MethodPost, "/transfer", `{"from_account_id":"ES7201820001000000000001","to_account_id":"ES4501820001000000000002","amount":0.5}`))
+		s.NoError(validate(http.MethodGet, "/admin/audit?action=account.create&from=2026-01-01T00:00:00Z", ""))
+		s.NoError(validate(http.MethodGet, "/accounts/0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11/statements/export?format=ofx", ""))
+	})
+
+	for name, data := range map[string]struct {
+		method, target, body string
+		expected             string
+	}{
+		"missing body":      {http.MethodPost, "/accounts", "", "request body: missing"},
+		"missing property":  {http.MethodPost, "/accounts", `{"owner":"Alice"}`, "missing property 'initial_balance'"},
+		"wrong type":        {http.MethodPost, "/accounts", `{"owner":1,"initial_balance":100}`, "request body.owner: expected string, got integer"},
+		"invalid json":      {http.MethodPost, "/accounts", `{"owner"`, "invalid json"},
+		"exclusive minimum": {http.MethodPost, "/transfer", `{"from_account_id":"a","to_account_id":"b","amount":0}`, "request body.amount: 0 is not greater than 0"},
+		"enum":              {http.MethodPost, "/accounts/0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11/transactions", `{"type":"refund","amount":1}`, "request body.type: refund is not one of"},
+		"path parameter":    {http.MethodGet, "/accounts/1", "", "path parameter 'id': '1' is not a uuid"},
+		"query parameter":   {http.MethodGet, "/admin/audit?from=yesterday", "", "query parameter 'from': 'yesterday' is not a RFC3339 date-time"},
+	} {
+		s.Run("error: "+name, func() {
+			err := validate(data.method, data.target, data.body)
+			s.Require().Error(err)
+			s.Contains(err.Error(), data.expected)
+		})
+	}
+
+	s.Run("error: content type", func() {
+		r := httptest.NewRequest(http.MethodPost, "/payments/pain001", strings.NewReader("{}"))
+		r.Header.Set("Content-Type", "application/json")
+		op, params := s.spec.Find(r.Method, r.URL.Path)
+		err := op.ValidateRequest(r, params, []byte("{}"))
+		s.Require().Error(err)
+		s.Contains(err.Error(), "undocumented content type 'application/json'")
+	})
+}
+
+// TestValidateResponse tests validating responses against their operation.
+func (s *OpenAPITestSuite) TestValidateResponse() {
+	op := s.spec.Operation(http.MethodGet, "/accounts/{id}")
+	s.Require().NotNil(op)
+	header := http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}
+
+	s.Run("ok", func() {
+		s.NoError(op.ValidateResponse(http.StatusOK, header, []byte(`{"id":"0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11","iban":"ES7201820001000000000001","owner":"Alice","balance":10.5,"initial_balance":0}`)))
+		s.NoError(op.ValidateResponse(http.StatusBadRequest, header, []byte(`{"code":"ACCOUNT_NOT_FOUND","message":"account not found"}`)))
+	})
+
+	for name, data := range map[string]struct {
+		status   int
+		header   http.Header
+		body     string
+		expected string
+	}{
+		"undocumented status":       {http.StatusCreated, header, `{}`, "undocumented status 201"},
+		"undocumented content type": {http.StatusOK, http.Header{"Content-Type": []string{"text/plain"}}, `{}`, "undocumented content type 'text/plain'"},
+		"unexpected property":       {http.StatusOK, header, `{"id":"0d3b1c6a-53a4-4f27-9e57-0c2a3b5a7e11","iban":"ES7201820001000000000001","owner":"Alice","balance":1,"initial_balance":0,"currency":"EUR"}`, "unexpected property 'currency'"},
+		"undocumented error code":   {http.StatusBadRequest, header, `{"code":"TEAPOT","message":"i'm a teapot"}`, "response 400.code: TEAPOT is not one of"},
+	} {
+		s.Run("error: "+name, func() {
+			err := op.ValidateResponse(data.status, data.header, []byte(data.body))
+			s.Require().Error(err)
+			s.Contains(err.Error(), data.expected)
+		})
+	}
+}
+
+// TestVersions tests that the amounts are decimal strings in the specification of the version 2.
+func (s *OpenAPITestSuite) TestVersions() {
+	spec, err := Load(enum.V2)
+	s.Require().NoError(err)
+	op := spec.Operation(http.MethodPost, "/transfer")
+	s.Require().NotNil(op)
+
+	validate := func(body string) error {
+		r := httptest.NewRequest(http.MethodPost, "/transfer", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return op.ValidateRequest(r, nil, []byte(body))
+	}
+
+	s.Run("ok: string amount", func() {
+		s.NoError(validate(`{"from_account_id":"a","to_account_id":"b","amount":"10.50"}`))
+	})
+
+	s.Run("error: number amount", func() {
+		err := validate(`{"from_account_id":"a","to_account_id":"b","amount":10.5}`)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "request body.amount: expected string, got number")
+	})
+
+	s.Run("error: not a decimal", func() {
+		err := validate(`{"from_account_id":"a","to_account_id":"b","amount":"1e3"}`)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "does not match the pattern")
+	})
+}
+
+// TestOpenAPISuite runs the test suite.
+func TestOpenAPISuite(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema of the specification. Only the keywords used by the specification are supported.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 types              `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"` // false rejects the properties that are not listed
+	Items                *Schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+
+	resolved *Schema        // schema referenced by Ref
+	pattern  *regexp.Regexp // compiled Pattern
+}
+
+// types is the type keyword, which is either a type or a list of types.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// resolve links the references of the schema and of its subschemas to the components, and compiles its patterns.
+func (s *Schema) resolve(components map[string]*Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		target, ok := components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("unknown schema reference '%s'", s.Ref)
+		}
+		s.resolved = target
+		return nil
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	for _, sub := range s.Properties {
+		if err := sub.resolve(components); err != nil {
+			return err
+		}
+	}
+	return s.Items.resolve(components)
+}
+
+// Validate checks a value decoded from JSON against the schema. The location is used in the errors to tell where the
+// value comes from.
+func (s *Schema) Validate(value any, location string) error {
+	if s == nil {
+		return nil
+	}
+	if s.resolved != nil {
+		return s.resolved.Validate(value, location)
+	}
+
+	if len(s.Type) > 0 && !s.hasType(value) {
+		return fmt.Errorf("%s: expected %s, got %s", location, strings.Join(s.Type, " or "), typeOf(value))
+	}
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		return fmt.Errorf("%s: %v is not one of %v", location, value, s.Enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return s.validateString(v, location)
+	case float64:
+		return s.validateNumber(v, location)
+	case []any:
+		for i, item := range v {
+			if err := s.Items.Validate(item, fmt.Sprintf("%s[%d]", location, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		return s.validateObject(v, location)
+	}
+	return nil
+}
+
+// hasType reports whether the value is of one of the types of the schema.
+func (s *Schema) hasType(value any) bool {
+	actual := typeOf(value)
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// inEnum reports whether the value is one of the values of the enum.
+func (s *Schema) inEnum(value any) bool {
+	for _, v := range s.Enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateString(v string, location string) error {
+	if s.MinLength != nil && len(v) < *s.MinLength {
+		return fmt.Errorf("%s: shorter than %d characters", location, *s.MinLength)
+	}
+	if s.MaxLength != nil && len(v) > *s.MaxLength {
+		return fmt.Errorf("%s: longer than %d characters", location, *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		return fmt.Errorf("%s: '%s' does not match the pattern %s", location, v, s.Pattern)
+	}
+	switch s.Format {
+	case "uuid":
+		if uuid.Validate(v) != nil {
+			return fmt.Errorf("%s: '%s' is not a uuid", location, v)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("%s: '%s' is not a RFC3339 date-time", location, v)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v float64, location string) error {
+	if s.Minimum != nil && v < *s.Minimum {
+		return fmt.Errorf("%s: %v is less than %v", location, v, *s.Minimum)
+	}
+	if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+		return fmt.Errorf("%s: %v is not greater than %v", location, v, *s.ExclusiveMinimum)
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		return fmt.Errorf("%s: %v is greater than %v", location, v, *s.Maximum)
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(v map[string]any, location string) error {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			return fmt.Errorf("%s: missing property '%s'", location, name)
+		}
+	}
+
+	// properties are checked in order, so that the errors are stable
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("%s: unexpected property '%s'", location, name)
+			}
+			continue
+		}
+		if err := sub.Validate(v[name], location+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeOf returns the JSON Schema type of a value decoded from JSON.
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"bank_test/internal/payments"
+	"net/http"
+)
+
+// maxPaymentDocumentSize is the maximum size of the pain.001 documents accepted by the API.
+const maxPaymentDocumentSize = 10 << 20
+
+// submitPayments is an endpoint that executes the credit transfers of the pain.001 document of the request body, and
+// responds with a pain.002 status report. The query parameter execution selects whether the document is executed as a
+// whole (atomic, the default) or transfer by transfer (per_item).
+func (h *handler) submitPayments(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("submit payments endpoint called")
+
+	mode := enum.ExecutionMode(r.URL.Query().Get("execution"))
+	if mode == "" {
+		mode = enum.AtomicExecution
+	}
+	if !mode.IsValid() {
+		h.wrapError(w, r, errors.ErrInvalidExecutionMode)
+		return
+	}
+
+	h.logger.Debugf("decoding payment document from the request")
+	doc, err := payments.Parse(http.MaxBytesReader(w, r.Body, maxPaymentDocumentSize))
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Debugf("payment document '%s' decoded successfully", doc.GroupHeader.MessageID)
+
+	report := h.payments.Process(r.Context(), doc, mode)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+
+	// the report is written directly to the client, so errors can no longer be reported to it
+	if err := report.WriteXML(w); err != nil {
+		h.logger.Errorf("status report of payment document '%s' interrupted: %v", doc.GroupHeader.MessageID, err)
+		return
+	}
+	h.logger.Infof("payment document processed successfully with status %s", report.Status)
+}
@@ -1,10 +1,21 @@
 package http
 
 import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
 	"bank_test/internal/conf"
 	"bank_test/internal/db"
+	"bank_test/internal/enum"
 	"bank_test/internal/helpers"
+	"bank_test/internal/iban"
+	"bank_test/internal/idempotency"
+	"bank_test/internal/payments"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/transport/http/openapi"
 	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/transport/ws"
+	"bank_test/internal/webhook"
 	"fmt"
 	"net/http"
 
@@ -15,38 +26,154 @@ import (
 )
 
 type httpTransport struct {
-	logger *zap.SugaredLogger
-	db     db.DatabaseAdapter
+	logger     *zap.SugaredLogger
+	db         db.DatabaseAdapter
+	reconciler *reconciliation.Reconciler
+	auditLog   *audit.Log
+	webhooks   *webhook.Store
+	dispatcher *webhook.Dispatcher
+	feed       *activity.Feed
+	auth       *auth.Authenticator
 }
 
-func NewHttpTransport(logger *zap.SugaredLogger, db db.DatabaseAdapter) *httpTransport {
-	return &httpTransport{logger: logger, db: db}
+func NewHttpTransport(logger *zap.SugaredLogger, db db.DatabaseAdapter, reconciler *reconciliation.Reconciler, auditLog *audit.Log, webhooks *webhook.Store, dispatcher *webhook.Dispatcher, feed *activity.Feed, authenticator *auth.Authenticator) *httpTransport {
+	return &httpTransport{logger: logger, db: db, reconciler: reconciler, auditLog: auditLog, webhooks: webhooks, dispatcher: dispatcher, feed: feed, auth: authenticator}
 }
 
 // Serve is a function that sets up the http server. It listens on the port specified in the configuration.
 func (h httpTransport) Serve() error {
 	h.logger.Debugf("setting up http server")
+	r, err := h.Handler()
+	if err != nil {
+		return h.wrapError(err)
+	}
+
+	port := fmt.Sprintf(":%s", conf.GlobalConfig.Port)
+	h.logger.Infof("http server listening on port %s", port)
+	if err := http.ListenAndServe(port, r); err != nil {
+		return h.wrapError(err)
+	}
+
+	return nil
+}
+
+// Handler builds the router that serves the API, with its middlewares and routes.
+func (h httpTransport) Handler() (http.Handler, error) {
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
+	ibans, err := iban.NewGenerator(conf.GlobalConfig.IBANCountryCode, conf.GlobalConfig.IBANBankCode)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := payments.NewMessageStore(h.logger, conf.GlobalConfig.PaymentMessagesPath, conf.GlobalConfig.PaymentMessageTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	// setup the routes here
-	handler := newHandler(h.logger, h.db)
+	handler := newHandler(h.logger, h.db, ibans, messages, h.reconciler, h.auditLog, h.webhooks, h.dispatcher, h.feed, h.auth)
+	r.Use(handler.auditMetadata)
+	r.Use(handler.authenticate)
+
+	// in tests, every request and response is checked against the OpenAPI specification of its version
+	var specs map[enum.APIVersion]*openapi.Spec
+	if conf.GlobalConfig.OpenAPIValidation {
+		specs = make(map[enum.APIVersion]*openapi.Spec)
+		for _, version := range apiVersions {
+			spec, err := openapi.Load(version)
+			if err != nil {
+				return nil, err
+			}
+			specs[version] = spec
+		}
+	}
 
-	r.Post("/accounts", handler.createAccount)
-	r.Get("/accounts/{id}", handler.getAccount)
-	r.Get("/accounts", handler.getAllAccounts)
-	r.Post("/accounts/{id}/transactions", handler.createTransaction)
-	r.Get("/accounts/{id}/transactions", handler.getTransactionsByAccountID)
-	r.Post("/transfer", handler.transfer)
+	// every route is authorized for the operation it is registered with, gets the timeout defined for it in the configuration, and
+	// POST routes can be retried safely with an idempotency key
+	var keys *idempotency.Store
+	if conf.GlobalConfig.IdempotencyTTL > 0 {
+		keys = idempotency.NewStore(h.logger, conf.GlobalConfig.IdempotencyTTL)
+	}
 
-	port := fmt.Sprintf(":%s", conf.GlobalConfig.Port)
-	h.logger.Infof("http server listening on port %s", port)
-	if err := http.ListenAndServe(port, r); err != nil {
-		return h.wrapError(err)
+	// websocket handler. Balance updates and commands share a single connection
+	websocket := ws.NewHandler(h.logger, handler.as, handler.ts, h.feed, h.auth, ws.Options{
+		PingInterval:   conf.GlobalConfig.ActivityHeartbeatInterval,
+		CommandTimeout: conf.GlobalConfig.RequestTimeout,
+		AllowedOrigins: conf.GlobalConfig.AllowedOrigins(),
+	})
+
+	// the routes are served under the prefix of every version, and without prefix in the version negotiated with the
+	// Accept header. Every version shares the handlers, which convert the bodies that differ between versions
+	routes := func(r chi.Router) {
+		if specs != nil {
+			r.Use(handler.validateSpec(specs))
+		}
+
+		route := func(method, pattern string, op auth.Operation, fn http.HandlerFunc) {
+			middlewares := chi.Middlewares{deprecated(method, pattern), handler.authorize(op)}
+			if method == http.MethodPost && keys != nil {
+				middlewares = append(middlewares, handler.idempotent(keys))
+			}
+			middlewares = append(middlewares, requestTimeout(conf.GlobalConfig.RouteTimeout(method, pattern)))
+			r.With(middlewares...).Method(method, pattern, fn)
+		}
+
+		route(http.MethodPost, "/accounts", auth.OpenAccount, handler.createAccount)
+		route(http.MethodGet, "/accounts/by-number/{iban}", auth.ReadAccounts, handler.getAccountByIBAN)
+		route(http.MethodGet, "/accounts/{id}", auth.ReadAccounts, handler.getAccount)
+		route(http.MethodGet, "/accounts", auth.ReadAccounts, handler.getAllAccounts)
+		route(http.MethodPost, "/accounts/{id}/transactions", authenticated, handler.createTransaction)
+		route(http.MethodGet, "/accounts/{id}/transactions", auth.ReadTransactions, handler.getTransactionsByAccountID)
+		route(http.MethodGet, "/accounts/{id}/events", auth.ReadTransactions, handler.streamAccountEvents)
+		route(http.MethodGet, "/accounts/{id}/statements/export", auth.ReadTransactions, handler.exportStatement)
+		route(http.MethodPost, "/transfer", auth.Transfer, handler.transfer)
+		route(http.MethodPost, "/payments/pain001", auth.Transfer, handler.submitPayments)
+
+		// websocket route
+		route(http.MethodGet, "/ws", auth.ReadAccounts, websocket.ServeHTTP)
+
+		// admin routes
+		route(http.MethodPost, "/admin/reconciliations", auth.Reconcile, handler.createReconciliation)
+		route(http.MethodGet, "/admin/reconciliations/{id}", auth.ReadReconciliations, handler.getReconciliation)
+		route(http.MethodGet, "/admin/audit", auth.ReadAuditLog, handler.getAuditRecords)
+		route(http.MethodGet, "/admin/audit/verify", auth.ReadAuditLog, handler.verifyAuditLog)
+		route(http.MethodGet, "/admin/backup", auth.Backup, handler.backupDatabase)
+		route(http.MethodPost, "/admin/api-keys", auth.ManageAPIKeys, handler.createAPIKey)
+		route(http.MethodGet, "/admin/api-keys", auth.ReadAPIKeys, handler.getAPIKeys)
+		route(http.MethodDelete, "/admin/api-keys/{id}", auth.ManageAPIKeys, handler.revokeAPIKey)
+		route(http.MethodPost, "/admin/accounts/{id}/freeze", auth.FreezeAccount, handler.freezeAccount)
+		route(http.MethodDelete, "/admin/accounts/{id}/freeze", auth.FreezeAccount, handler.unfreezeAccount)
+
+		// webhook routes
+		route(http.MethodPost, "/webhooks", auth.ManageWebhooks, handler.createWebhook)
+		route(http.MethodGet, "/webhooks", auth.ReadWebhooks, handler.getWebhooks)
+		route(http.MethodGet, "/webhooks/dead-letters", auth.ReadWebhooks, handler.getDeadLetters)
+		route(http.MethodPost, "/webhooks/dead-letters/{id}/redeliver", auth.ManageWebhooks, handler.redeliverDeadLetter)
+		route(http.MethodGet, "/webhooks/{id}", auth.ReadWebhooks, handler.getWebhook)
+		route(http.MethodPut, "/webhooks/{id}", auth.ManageWebhooks, handler.updateWebhook)
+		route(http.MethodDelete, "/webhooks/{id}", auth.ManageWebhooks, handler.deleteWebhook)
+
+		// documentation routes
+		route(http.MethodGet, "/openapi.json", public, handler.getSpecification)
+		route(http.MethodGet, "/docs", public, handler.getDocs)
 	}
 
-	return nil
+	for _, version := range apiVersions {
+		r.Route("/"+version.String(), func(r chi.Router) {
+			r.Use(pinVersion(version))
+			routes(r)
+		})
+	}
+	r.Group(func(r chi.Router) {
+		r.Use(handler.negotiateVersion)
+		routes(r)
+	})
+
+	return r, nil
 }
 
 // HealthCheck is a function that sets up the health check endpoint. It listens on the health port specified in the configuration,
@@ -1,5 +1,10 @@
 package schemas
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // CreateAccountRequest is the request schema for the CreateAccount endpoint.
 // It is used to create a new account.
 type CreateAccountRequest struct {
@@ -15,9 +20,91 @@ type CreateTransactionRequest struct {
 }
 
 // TransferRequest is the request schema for the Transfer endpoint.
-// It is used to transfer money from one account to another.
+// It is used to transfer money from one account to another. Accounts can be referenced either by their ID or by their IBAN.
 type TransferRequest struct {
-	FromAccountId string   `json:"from_account_id" validate:"required"`
-	ToAccountId   string   `json:"to_account_id" validate:"required"`
+	FromAccountId string   `json:"from_account_id" validate:"required,uuid|iban"`
+	ToAccountId   string   `json:"to_account_id" validate:"required,uuid|iban"`
 	Amount        *float64 `json:"amount" validate:"required,gt=0"`
 }
+
+// CreateWebhookRequest is the request schema for the CreateWebhook endpoint.
+// It is used to subscribe an endpoint to the events of the bank. If the secret is empty, a random one is generated.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=account.created transaction.created transfer.completed"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16"`
+}
+
+// UpdateWebhookRequest is the request schema for the UpdateWebhook endpoint.
+// It is used to replace the endpoint and the events of a subscription, and to pause or resume its deliveries.
+type UpdateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=account.created transaction.created transfer.completed"`
+	Active     *bool    `json:"active" validate:"required"`
+}
+
+// CreateAccountRequestV2 is the request schema for the CreateAccount endpoint in the version 2 of the API, in which
+// the amounts are decimal strings.
+type CreateAccountRequestV2 struct {
+	Owner          string  `json:"owner" validate:"required"`
+	InitialBalance *string `json:"initial_balance" validate:"required,numeric"`
+}
+
+// ToV1 converts the request to the schema of the version 1, which is the one handled by the services.
+func (r *CreateAccountRequestV2) ToV1() (*CreateAccountRequest, error) {
+	balance, err := parseAmount("initial_balance", *r.InitialBalance)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateAccountRequest{Owner: r.Owner, InitialBalance: &balance}, nil
+}
+
+// CreateTransactionRequestV2 is the request schema for the CreateTransaction endpoint in the version 2 of the API, in
+// which the amounts are decimal strings.
+type CreateTransactionRequestV2 struct {
+	Type   string  `json:"type" validate:"required,oneof=deposit withdrawal"`
+	Amount *string `json:"amount" validate:"required,numeric"`
+}
+
+// ToV1 converts the request to the schema of the version 1, which is the one handled by the services.
+func (r *CreateTransactionRequestV2) ToV1() (*CreateTransactionRequest, error) {
+	amount, err := parseAmount("amount", *r.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateTransactionRequest{Type: r.Type, Amount: &amount}, nil
+}
+
+// TransferRequestV2 is the request schema for the Transfer endpoint in the version 2 of the API, in which the amounts
+// are decimal strings.
+type TransferRequestV2 struct {
+	FromAccountId string  `json:"from_account_id" validate:"required,uuid|iban"`
+	ToAccountId   string  `json:"to_account_id" validate:"required,uuid|iban"`
+	Amount        *string `json:"amount" validate:"required,numeric"`
+}
+
+// ToV1 converts the request to the schema of the version 1, which is the one handled by the services.
+func (r *TransferRequestV2) ToV1() (*TransferRequest, error) {
+	amount, err := parseAmount("amount", *r.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferRequest{FromAccountId: r.FromAccountId, ToAccountId: r.ToAccountId, Amount: &amount}, nil
+}
+
+// parseAmount parses a decimal string amount. Amounts that do not fit in a float64 are rejected.
+func parseAmount(field, value string) (float64, error) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a decimal number within range", field)
+	}
+	return amount, nil
+}
+
+// CreateAPIKeyRequest is the request schema for the CreateAPIKey endpoint.
+// It is used to create an api key of a staff role granted the scopes.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Role   string   `json:"role" validate:"required,oneof=teller auditor admin"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=accounts:read accounts:write transactions:read transactions:write transfers:write webhooks:read webhooks:write admin"`
+}
@@ -1,5 +1,13 @@
 package schemas
 
+import (
+	"bank_test/internal/auth"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"strconv"
+	"time"
+)
+
 // HealthResponse is the response for the health check endpoint
 type HealthResponse struct {
 	Message string `json:"message"`
@@ -9,3 +17,61 @@ type HealthResponse struct {
 type OkResponse struct {
 	Message string `json:"message"`
 }
+
+// AccountV2 is the representation of an account in the version 2 of the API, in which the amounts are decimal strings.
+type AccountV2 struct {
+	ID             string `json:"id"`
+	IBAN           string `json:"iban"`
+	Owner          string `json:"owner"`
+	Balance        string `json:"balance"`
+	InitialBalance string `json:"initial_balance"`
+	Frozen         bool   `json:"frozen,omitempty"`
+}
+
+// NewAccountV2 converts an account to its representation in the version 2 of the API.
+func NewAccountV2(acc *models.Account) *AccountV2 {
+	return &AccountV2{
+		ID:             acc.ID,
+		IBAN:           acc.IBAN,
+		Owner:          acc.Owner,
+		Balance:        formatAmount(acc.Balance),
+		InitialBalance: formatAmount(acc.InitialBalance),
+		Frozen:         acc.Frozen,
+	}
+}
+
+// TransactionV2 is the representation of a transaction in the version 2 of the API, in which the amounts are decimal
+// strings.
+type TransactionV2 struct {
+	ID        string               `json:"id"`
+	AccountID string               `json:"account_id"`
+	Type      enum.TransactionType `json:"type"`
+	Amount    string               `json:"amount"`
+	Timestamp time.Time            `json:"timestamp"`
+
+	TransferID string `json:"transfer_id,omitempty"`
+}
+
+// NewTransactionV2 converts a transaction to its representation in the version 2 of the API.
+func NewTransactionV2(tx *models.Transaction) *TransactionV2 {
+	return &TransactionV2{
+		ID:        tx.ID,
+		AccountID: tx.AccountID,
+		Type:      tx.Type,
+		Amount:    formatAmount(tx.Amount),
+		Timestamp: tx.Timestamp,
+
+		TransferID: tx.TransferID,
+	}
+}
+
+// formatAmount formats an amount as the shortest decimal string that parses back to it, without exponent.
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// CreateAPIKeyResponse is the response for the CreateAPIKey endpoint. The key is only returned in this response.
+type CreateAPIKeyResponse struct {
+	auth.APIKey
+	Key string `json:"key"`
+}
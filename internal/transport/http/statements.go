@@ -0,0 +1,91 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// statementFiles are the content types and file extensions of the statement formats.
+var statementFiles = map[enum.StatementFormat]struct{ contentType, extension string }{
+	enum.CSVStatement:     {contentType: "text/csv; charset=utf-8", extension: "csv"},
+	enum.OFXStatement:     {contentType: "application/x-ofx", extension: "ofx"},
+	enum.CAMT053Statement: {contentType: "application/xml", extension: "xml"},
+}
+
+// exportStatement is an endpoint that streams the statement of an account for the period [from, to) in the format of
+// the query parameter format: csv (the default), ofx or camt053.
+func (h *handler) exportStatement(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("export statement endpoint called")
+
+	// decode the account id from the request
+	h.logger.Debugf("decoding account id from the request")
+	accID := chi.URLParam(r, "id")
+	if accID == "" {
+		h.wrapError(w, r, errors.ErrAccountIdIsMissing)
+		return
+	}
+
+	// check if the account id is valid uuid
+	if err := uuid.Validate(accID); err != nil {
+		h.wrapError(w, r, errors.ErrInvalidAccountID)
+		return
+	}
+	h.logger.Debugf("account id decoded successfully: %s", accID)
+
+	h.logger.Debugf("decoding statement parameters from the request")
+	query := r.URL.Query()
+	format := enum.StatementFormat(query.Get("format"))
+	if format == "" {
+		format = enum.CSVStatement
+	}
+	if !format.IsValid() {
+		h.wrapError(w, r, errors.ErrInvalidStatementFormat)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		h.wrapError(w, r, errors.ErrInvalidTimeRange)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil || (!from.IsZero() && !to.IsZero() && !from.Before(to)) {
+		h.wrapError(w, r, errors.ErrInvalidTimeRange)
+		return
+	}
+	h.logger.Debugf("statement parameters decoded successfully: format %s, from '%s', to '%s'", format, query.Get("from"), query.Get("to"))
+
+	acc, err := h.as.GetAccountByID(r.Context(), accID)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	// the transactions are read by pages: once to compute the balances and the totals, which precede them in some
+	// formats, and again while the statement is written
+	scan := func(from, to time.Time, fn func(models.Transaction) error) error {
+		return h.ts.ScanTransactionsByAccountID(r.Context(), accID, from, to, fn)
+	}
+	st, err := h.statements.Build(*acc, scan, from, to)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+
+	file := statementFiles[format]
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("statement-%s.%s", acc.IBAN, file.extension)))
+	w.WriteHeader(http.StatusOK)
+
+	// the statement is streamed, so errors can no longer be reported to the client
+	if err := st.Write(w, format); err != nil {
+		h.logger.Errorf("statement of account '%s' interrupted: %v", accID, err)
+		return
+	}
+	h.logger.Info("statement exported successfully")
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// apiVersionHeader is set on every response to tell the version of the API that served it.
+	apiVersionHeader = "API-Version"
+
+	// versionMediaTypePrefix and versionMediaTypeSuffix surround the version in the media type used in the Accept
+	// header to request a version of the API on the routes that are not prefixed with it: application/vnd.bank.v2+json.
+	versionMediaTypePrefix = "application/vnd.bank."
+	versionMediaTypeSuffix = "+json"
+
+	// deprecationHeader and sunsetHeader announce that a route is deprecated (RFC 9745) and when it will stop
+	// responding (RFC 8594).
+	deprecationHeader = "Deprecation"
+	sunsetHeader      = "Sunset"
+)
+
+// apiVersions are the versions of the API, each one served under its own prefix.
+var apiVersions = []enum.APIVersion{enum.V1, enum.V2}
+
+// apiVersionKey is the key of the version of the API in the context of the requests.
+type apiVersionKey struct{}
+
+// apiVersion returns the version of the API that serves the request. The version 1 is the default one.
+func apiVersion(ctx context.Context) enum.APIVersion {
+	if version, ok := ctx.Value(apiVersionKey{}).(enum.APIVersion); ok {
+		return version
+	}
+	return enum.V1
+}
+
+// withAPIVersion stores the version of the API in the context of the request, and tells it in the response.
+func withAPIVersion(w http.ResponseWriter, r *http.Request, version enum.APIVersion) *http.Request {
+	w.Header().Set(apiVersionHeader, version.String())
+	return r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version))
+}
+
+// pinVersion is a middleware that serves the requests with a version of the API. It is used by the routes prefixed
+// with the version, which ignore the Accept header.
+func pinVersion(version enum.APIVersion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, withAPIVersion(w, r, version))
+		})
+	}
+}
+
+// negotiateVersion is a middleware that serves the requests with the version of the API asked for in their Accept
+// header, or with the version 1 when they do not ask for one. It is used by the routes that are not prefixed with a
+// version.
+func (h *handler) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		version, err := acceptedVersion(r.Header.Values("Accept"))
+		if err != nil {
+			h.wrapError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, withAPIVersion(w, r, version))
+	})
+}
+
+// acceptedVersion returns the version of the API of the first vendor media type of the Accept header, or the version
+// 1 if there is none.
+func acceptedVersion(accept []string) (enum.APIVersion, error) {
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			name, ok := strings.CutPrefix(mediaType, versionMediaTypePrefix)
+			if !ok {
+				continue
+			}
+			version := enum.APIVersion(strings.TrimSuffix(name, versionMediaTypeSuffix))
+			if !strings.HasSuffix(name, versionMediaTypeSuffix) || !version.IsValid() {
+				e := *errors.ErrUnsupportedAPIVersion
+				e.Message = fmt.Sprintf("unsupported api version '%s'. Must be %s+json or %s+json", mediaType, versionMediaTypePrefix+enum.V1.String(), versionMediaTypePrefix+enum.V2.String())
+				return "", &e
+			}
+			return version, nil
+		}
+	}
+	return enum.V1, nil
+}
+
+// deprecated is a middleware that sends the Deprecation and Sunset headers of the route registered with the method and
+// pattern, in the versions of the API in which the route is deprecated.
+func deprecated(method, pattern string) func(http.Handler) http.Handler {
+	deprecations := make(map[enum.APIVersion]conf.Deprecation)
+	for _, version := range apiVersions {
+		if deprecation, ok := conf.GlobalConfig.RouteDeprecation(version, method, pattern); ok {
+			deprecations[version] = deprecation
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(deprecations) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if deprecation, ok := deprecations[apiVersion(r.Context())]; ok {
+				w.Header().Set(deprecationHeader, fmt.Sprintf("@%d", deprecation.Date.Unix()))
+				if !deprecation.Sunset.IsZero() {
+					w.Header().Set(sunsetHeader, deprecation.Sunset.UTC().Format(http.TimeFormat))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unversionedPath removes the version prefix from the path of a request, if it has one.
+func unversionedPath(path string) string {
+	for _, version := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, "/"+version.String()); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return path
+}
+
+// v2Request is the request schema of an endpoint in the version 2 of the API, which is converted to the schema T of
+// the version 1 handled by the services.
+type v2Request[T any] interface {
+	ToV1() (*T, error)
+}
+
+// decodeBody decodes the body of the request into the schema of the version 1 of the endpoint, and validates it. The
+// bodies of the version 2 are decoded into their own schema and converted.
+func decodeBody(r *http.Request, body any) error {
+	if apiVersion(r.Context()) != enum.V2 {
+		return binding.DecodeJSONBody(r, body)
+	}
+
+	switch body := body.(type) {
+	case *schemas.CreateAccountRequest:
+		return decodeV2(r, &schemas.CreateAccountRequestV2{}, body)
+	case *schemas.CreateTransactionRequest:
+		return decodeV2(r, &schemas.CreateTransactionRequestV2{}, body)
+	case *schemas.TransferRequest:
+		return decodeV2(r, &schemas.TransferRequestV2{}, body)
+	default:
+		return binding.DecodeJSONBody(r, body)
+	}
+}
+
+// decodeV2 decodes the body of the request into the schema of the version 2, and converts it to the one of the
+// version 1, which is validated too.
+func decodeV2[T any, R v2Request[T]](r *http.Request, v2 R, body *T) error {
+	if err := binding.DecodeJSONBody(r, v2); err != nil {
+		return err
+	}
+	v1, err := v2.ToV1()
+	if err != nil {
+		return err
+	}
+	*body = *v1
+	return binding.Validate(body)
+}
+
+// versioned converts the accounts and transactions of a response to their representation in the version of the API
+// that serves the request. Other values are the same in every version.
+func versioned(r *http.Request, v any) any {
+	if apiVersion(r.Context()) != enum.V2 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case *models.Account:
+		return schemas.NewAccountV2(v)
+	case []models.Account:
+		accs := make([]*schemas.AccountV2, len(v))
+		for i := range v {
+			accs[i] = schemas.NewAccountV2(&v[i])
+		}
+		return accs
+	case *models.Transaction:
+		return schemas.NewTransactionV2(v)
+	case []models.Transaction:
+		if v == nil {
+			return v
+		}
+		txs := make([]*schemas.TransactionV2, len(v))
+		for i := range v {
+			txs[i] = schemas.NewTransactionV2(&v[i])
+		}
+		return txs
+	default:
+		return v
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/webhook"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type VersionsTestSuite struct {
+	suite.Suite
+	router http.Handler
+}
+
+func (s *VersionsTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	s.T().Setenv("DEPRECATED_ROUTES", "v1=2026-01-01/2027-01-01,GET /v2/accounts=2026-06-01")
+	s.Require().NoError(conf.SetupConfig())
+	conf.GlobalConfig.OpenAPIValidation = true
+
+	auditLog, err := audit.NewLog(logger, "")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	db := memory.NewInMemoryDatabase(logger)
+	webhooks, err := webhook.NewStore(logger, "")
+	s.Require().NoError(err)
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{})
+
+	authenticator, err := auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	s.router, err = NewHttpTransport(logger, db, reconciliation.NewReconciler(logger, db), auditLog, webhooks, dispatcher, feed, authenticator).Handler()
+	s.Require().NoError(err)
+}
+
+// do sends a request to the router.
+func (s *VersionsTestSuite) do(method, target, accept, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestNegotiation tests selecting the version of the API with the path or the Accept header.
+func (s *VersionsTestSuite) TestNegotiation() {
+	for name, data := range map[string]struct {
+		target, accept string
+		expected       string
+	}{
+		"default":          {"/accounts", "", "v1"},
+		"generic accept":   {"/accounts", "application/json, */*", "v1"},
+		"path v1":          {"/v1/accounts", "", "v1"},
+		"path v2":          {"/v2/accounts", "", "v2"},
+		"accept v2":        {"/accounts", "text/html, application/vnd.bank.v2+json; q=0.9", "v2"},
+		"path over accept": {"/v1/accounts", "application/vnd.bank.v2+json", "v1"},
+	} {
+		s.Run("ok: "+name, func() {
+			rec := s.do(http.MethodGet, data.target, data.accept, "")
+			s.Equal(http.StatusOK, rec.Code, rec.Body.String())
+			s.Equal(data.expected, rec.Header().Get(apiVersionHeader))
+		})
+	}
+
+	for _, accept := range []string{"application/vnd.bank.v3+json", "application/vnd.bank.v2+xml"} {
+		s.Run("error: "+accept, func() {
+			rec := s.do(http.MethodGet, "/accounts", accept, "")
+			s.Equal(http.StatusNotAcceptable, rec.Code)
+			s.Contains(rec.Body.String(), "UNSUPPORTED_API_VERSION")
+		})
+	}
+
+	s.Run("error: unknown version prefix", func() {
+		rec := s.do(http.MethodGet, "/v3/accounts", "", "")
+		s.Equal(http.StatusNotFound, rec.Code)
+	})
+}
+
+// TestAmounts tests that the amounts are numbers in the version 1 and decimal strings in the version 2.
+func (s *VersionsTestSuite) TestAmounts() {
+	var acc map[string]any
+
+	s.Run("ok: v2 create account", func() {
+		rec := s.do(http.MethodPost, "/v2/accounts", "", `{"owner":"Alice","initial_balance":"100.25"}`)
+		s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+		s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &acc))
+		s.Equal("100.25", acc["balance"])
+		s.Equal("100.25", acc["initial_balance"])
+	})
+
+	s.Run("ok: v2 deposit", func() {
+		rec := s.do(http.MethodPost, "/accounts/"+acc["id"].(string)+"/transactions", "application/vnd.bank.v2+json", `{"type":"deposit","amount":"0.1"}`)
+		s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), `"amount":"0.1"`)
+	})
+
+	s.Run("ok: v1 reads the same account", func() {
+		rec := s.do(http.MethodGet, "/v1/accounts/"+acc["id"].(string), "", "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), `"balance":100.35`)
+	})
+
+	s.Run("ok: v2 transactions", func() {
+		rec := s.do(http.MethodGet, "/v2/accounts/"+acc["id"].(string)+"/transactions", "", "")
+		s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		s.Contains(rec.Body.String(), `"amount":"0.1"`)
+	})
+
+	for name, data := range map[string]struct {
+		target, body string
+		expected     string
+	}{
+		"v2 number amount":  {"/v2/accounts", `{"owner":"Bob","initial_balance":100}`, "INVALID_BODY"},
+		"v2 not a decimal":  {"/v2/accounts", `{"owner":"Bob","initial_balance":"1e3"}`, "initial_balance must be a decimal number"},
+		"v2 zero amount":    {"/v2/transfer", `{"from_account_id":"ES7201820001000000000001","to_account_id":"ES4501820001000000000002","amount":"0"}`, "'gt' tag"},
+		"v1 string amount":  {"/v1/accounts", `{"owner":"Bob","initial_balance":"100"}`, "INVALID_BODY"},
+		"v2 missing amount": {"/v2/accounts", `{"owner":"Bob"}`, "initial_balance is required"},
+	} {
+		s.Run("error: "+name, func() {
+			rec := s.do(http.MethodPost, data.target, "", data.body)
+			s.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+			s.Contains(rec.Body.String(), data.expected)
+		})
+	}
+}
+
+// TestDeprecation tests the Deprecation and Sunset headers of the deprecated versions and routes.
+func (s *VersionsTestSuite) TestDeprecation() {
+	for name, data := range map[string]struct {
+		target, accept      string
+		deprecation, sunset string
+	}{
+		"deprecated version":     {"/v1/webhooks", "", "@1767225600", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		"negotiated version":     {"/webhooks", "", "@1767225600", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		"deprecated route":       {"/v2/accounts", "", "@1780272000", ""},
+		"negotiated route":       {"/accounts", "application/vnd.bank.v2+json", "@1780272000", ""},
+		"route not deprecated":   {"/v2/webhooks", "", "", ""},
+		"version not deprecated": {"/webhooks", "application/vnd.bank.v2+json", "", ""},
+	} {
+		s.Run("ok: "+name, func() {
+			rec := s.do(http.MethodGet, data.target, data.accept, "")
+			s.Equal(http.StatusOK, rec.Code, rec.Body.String())
+			s.Equal(data.deprecation, rec.Header().Get(deprecationHeader))
+			s.Equal(data.sunset, rec.Header().Get(sunsetHeader))
+		})
+	}
+}
+
+// TestVersionsSuite runs the test suite.
+func TestVersionsSuite(t *testing.T) {
+	suite.Run(t, new(VersionsTestSuite))
+}
@@ -0,0 +1,188 @@
+package http
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/enum"
+	"bank_test/internal/helpers"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/webhook"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// createWebhook is an endpoint that subscribes an endpoint to the events of the bank. The secret used to sign the
+// deliveries is only returned in this response.
+func (h *handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("create webhook endpoint called")
+
+	h.logger.Debugf("decoding request body")
+	var body schemas.CreateWebhookRequest
+	if err := binding.DecodeJSONBody(r, &body); err != nil {
+		bodyErr := *errors.ErrInvalidBody
+		bodyErr.Message = fmt.Sprintf("failed to decode request body: %v", err)
+		h.wrapError(w, r, &bodyErr)
+		return
+	}
+	h.logger.Debugf("request body decoded successfully: %s", body.URL)
+
+	secret := body.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			h.wrapError(w, r, err)
+			return
+		}
+	}
+
+	sub := &webhook.Subscription{
+		ID:         uuid.NewString(),
+		URL:        body.URL,
+		Secret:     secret,
+		EventTypes: toEventTypes(body.EventTypes),
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := h.webhooks.CreateSubscription(sub); err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Info("webhook created successfully")
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, sub)
+}
+
+// getWebhooks is an endpoint that retrieves all webhook subscriptions.
+func (h *handler) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get webhooks endpoint called")
+
+	subs := h.webhooks.ListSubscriptions()
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	h.logger.Info("webhooks retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, subs)
+}
+
+// getWebhook is an endpoint that retrieves a webhook subscription by its id.
+func (h *handler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get webhook endpoint called")
+
+	sub, err := h.webhookFromRequest(r)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	sub.Secret = ""
+	h.logger.Info("webhook retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, sub)
+}
+
+// updateWebhook is an endpoint that replaces the endpoint and the events of a webhook subscription, and pauses or
+// resumes its deliveries. The secret is kept.
+func (h *handler) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("update webhook endpoint called")
+
+	sub, err := h.webhookFromRequest(r)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+
+	h.logger.Debugf("decoding request body")
+	var body schemas.UpdateWebhookRequest
+	if err := binding.DecodeJSONBody(r, &body); err != nil {
+		bodyErr := *errors.ErrInvalidBody
+		bodyErr.Message = fmt.Sprintf("failed to decode request body: %v", err)
+		h.wrapError(w, r, &bodyErr)
+		return
+	}
+	h.logger.Debugf("request body decoded successfully: %s", helpers.PrettyPrintStructResponse(body))
+
+	sub.URL = body.URL
+	sub.EventTypes = toEventTypes(body.EventTypes)
+	sub.Active = *body.Active
+	if err := h.webhooks.UpdateSubscription(sub); err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	sub.Secret = ""
+	h.logger.Info("webhook updated successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, sub)
+}
+
+// deleteWebhook is an endpoint that removes a webhook subscription. Its pending deliveries are dropped.
+func (h *handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("delete webhook endpoint called")
+
+	sub, err := h.webhookFromRequest(r)
+	if err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	if err := h.webhooks.DeleteSubscription(sub.ID); err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Info("webhook deleted successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, schemas.OkResponse{Message: "webhook deleted successfully"})
+}
+
+// getDeadLetters is an endpoint that retrieves the deliveries that failed after every retry.
+func (h *handler) getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("get dead letters endpoint called")
+
+	letters := h.webhooks.ListDeadLetters()
+	h.logger.Info("dead letters retrieved successfully")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, letters)
+}
+
+// redeliverDeadLetter is an endpoint that moves a dead letter back to the deliveries. It is attempted in the next
+// round of the dispatcher.
+func (h *handler) redeliverDeadLetter(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("redeliver dead letter endpoint called")
+
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		h.wrapError(w, r, errors.ErrDeadLetterNotFound)
+		return
+	}
+
+	if err := h.dispatcher.Redeliver(id); err != nil {
+		h.wrapError(w, r, err)
+		return
+	}
+	h.logger.Info("dead letter scheduled for redelivery successfully")
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, schemas.OkResponse{Message: "dead letter scheduled for redelivery"})
+}
+
+// webhookFromRequest retrieves the webhook subscription whose id is in the path of the request.
+func (h *handler) webhookFromRequest(r *http.Request) (*webhook.Subscription, error) {
+	h.logger.Debugf("decoding webhook id from the request")
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	h.logger.Debugf("webhook id decoded successfully: %s", id)
+	return h.webhooks.GetSubscription(id)
+}
+
+// toEventTypes converts the event types of a request, which are already validated.
+func toEventTypes(values []string) []enum.EventType {
+	types := make([]enum.EventType, 0, len(values))
+	for _, v := range values {
+		types = append(types, enum.EventType(v))
+	}
+	return types
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/webhook"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type WebhooksTestSuite struct {
+	suite.Suite
+	router http.Handler
+}
+
+func (s *WebhooksTestSuite) SetupTest() {
+	logger := zap.NewExample().Sugar()
+
+	s.Require().NoError(conf.SetupConfig())
+	conf.GlobalConfig.OpenAPIValidation = false // the bodies are validated by the handlers
+
+	auditLog, err := audit.NewLog(logger, "")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	db := memory.NewInMemoryDatabase(logger)
+	webhooks, err := webhook.NewStore(logger, "")
+	s.Require().NoError(err)
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{})
+
+	authenticator, err := auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	s.router, err = NewHttpTransport(logger, db, reconciliation.NewReconciler(logger, db), auditLog, webhooks, dispatcher, feed, authenticator).Handler()
+	s.Require().NoError(err)
+}
+
+// TestInvalidBody tests that the invalid bodies of concurrent requests are reported with their own messages.
+func (s *WebhooksTestSuite) TestInvalidBody() {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"url":"https://example.com"}`)))
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	var sub struct{ ID string }
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &sub))
+
+	message := errors.ErrInvalidBody.Message
+	var wg sync.WaitGroup
+	for name, data := range map[string]struct{ method, target, body, message string }{
+		"create without url":    {http.MethodPost, "/webhooks", `{"event_types":[]}`, "url is required"},
+		"create short secret":   {http.MethodPost, "/webhooks", `{"url":"https://example.com","secret":"short"}`, "secret must be at least 16 characters long"},
+		"update without active": {http.MethodPut, "/webhooks/" + sub.ID, `{"url":"https://example.com"}`, "active is required"},
+		"malformed":             {http.MethodPost, "/webhooks", `{`, "failed to decode request body"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(data.method, data.target, strings.NewReader(data.body))
+				req.Header.Set("Content-Type", "application/json")
+				s.router.ServeHTTP(rec, req)
+
+				var apiError errors.APIError
+				s.NoError(json.Unmarshal(rec.Body.Bytes(), &apiError), name)
+				s.Equal(http.StatusBadRequest, rec.Code, name)
+				s.Contains(apiError.Message, data.message, name)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Equal(message, errors.ErrInvalidBody.Message)
+}
+
+func TestWebhooksSuite(t *testing.T) {
+	suite.Run(t, new(WebhooksTestSuite))
+}
@@ -1,8 +1,18 @@
 package transport
 
 import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
 	"bank_test/internal/db"
+	"bank_test/internal/enum"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/transport/graphql"
+	"bank_test/internal/transport/grpc"
 	"bank_test/internal/transport/http"
+	"bank_test/internal/webhook"
+	stderrors "errors"
 
 	"go.uber.org/zap"
 )
@@ -15,7 +25,60 @@ type Transporter interface {
 	Close() error       // handles the graceful shutdown of the transport layer
 }
 
-// NewTransporter creates a new transport layer based on the provided type.
-func NewTransporter(logger *zap.SugaredLogger, db db.DatabaseAdapter) Transporter {
-	return http.NewHttpTransport(logger, db)
+// NewTransporter creates the transport layers enabled in the configuration. If several are enabled, they run
+// alongside each other, and share the authenticator of their callers.
+func NewTransporter(logger *zap.SugaredLogger, db db.DatabaseAdapter, reconciler *reconciliation.Reconciler, auditLog *audit.Log, webhooks *webhook.Store, dispatcher *webhook.Dispatcher, feed *activity.Feed, authenticator *auth.Authenticator) Transporter {
+	transports := make(multiTransport, 0)
+	for _, t := range conf.GlobalConfig.EnabledTransports() {
+		switch t {
+		case enum.HTTPTransport:
+			transports = append(transports, http.NewHttpTransport(logger, db, reconciler, auditLog, webhooks, dispatcher, feed, authenticator))
+		case enum.GRPCTransport:
+			transports = append(transports, grpc.NewGrpcTransport(logger, db, feed, authenticator))
+		case enum.GraphQLTransport:
+			transports = append(transports, graphql.NewGraphqlTransport(logger, db, feed, authenticator))
+		}
+	}
+
+	if len(transports) == 1 {
+		return transports[0]
+	}
+	return transports
+}
+
+// multiTransport runs several transport layers alongside each other.
+type multiTransport []Transporter
+
+// Serve starts every transport layer and returns as soon as one of them stops.
+func (m multiTransport) Serve() error {
+	return m.run(Transporter.Serve)
+}
+
+// HealthCheck starts the health check of every transport layer and returns as soon as one of them stops.
+func (m multiTransport) HealthCheck() error {
+	return m.run(Transporter.HealthCheck)
+}
+
+// Close shuts down every transport layer and returns all their errors.
+func (m multiTransport) Close() error {
+	errs := make([]error, 0)
+	for _, t := range m {
+		errs = append(errs, t.Close())
+	}
+	return stderrors.Join(errs...)
+}
+
+// run calls fn on every transport layer concurrently and returns the result of the first call that returns with an
+// error, or nil once every call has returned successfully. Health checks may return immediately, while servers block.
+func (m multiTransport) run(fn func(Transporter) error) error {
+	errs := make(chan error, len(m))
+	for _, t := range m {
+		go func(t Transporter) { errs <- fn(t) }(t)
+	}
+	for range m {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
 }
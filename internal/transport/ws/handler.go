@@ -0,0 +1,439 @@
+package ws
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/enum"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/http/binding"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxMessageSize is the maximum size of a command, in bytes.
+	maxMessageSize = 64 << 10
+
+	// writeWait is the maximum time to write a message to the client.
+	writeWait = 10 * time.Second
+
+	// outBuffer is the number of messages that can wait to be written to the client.
+	outBuffer = 64
+)
+
+// Options configures the WebSocket connections.
+type Options struct {
+	PingInterval   time.Duration // interval between two pings. Connections that do not answer within two intervals are closed
+	CommandTimeout time.Duration // maximum time to process a command. 0 disables it
+	AllowedOrigins []string      // origins allowed to open a connection besides the host itself. '*' allows any origin
+}
+
+// Handler upgrades the requests to WebSocket connections on which clients subscribe to the balance updates of
+// several accounts and submit deposits, withdrawals and transfers.
+type Handler struct {
+	logger   *zap.SugaredLogger
+	as       service.AccountService
+	ts       service.TransactionService
+	feed     *activity.Feed
+	auth     *auth.Authenticator // checks the credentials of the connections. Nil if they are not checked
+	opts     Options
+	upgrader websocket.Upgrader
+}
+
+// NewHandler creates a new WebSocket handler. Balance updates are taken from the activity feed. The connections of
+// an authenticated caller are closed when its credentials expire or are revoked, and they are checked again before
+// every command.
+func NewHandler(logger *zap.SugaredLogger, as service.AccountService, ts service.TransactionService, feed *activity.Feed, authenticator *auth.Authenticator, opts Options) *Handler {
+	h := &Handler{logger: logger, as: as, ts: ts, feed: feed, auth: authenticator, opts: opts}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	return h
+}
+
+// ServeHTTP upgrades the request and serves the connection until it is closed. The audit metadata of the request is
+// recorded with every mutation performed by the commands of the connection.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("websocket endpoint called")
+
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered the request
+		h.logger.Errorf("failed to upgrade the connection: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	if p, ok := auth.PrincipalFrom(ctx); ok && h.auth != nil {
+		var stop context.CancelFunc
+		ctx, stop = h.auth.Watch(ctx, p)
+		defer stop()
+	}
+	c := &conn{
+		h:      h,
+		ws:     ws,
+		ctx:    ctx,
+		cancel: cancel,
+		out:    make(chan Message, outBuffer),
+		subs:   make(map[string]*activity.Subscription),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.writeLoop()
+	}()
+	c.readLoop()
+
+	cancel(nil)
+	c.unsubscribeAll()
+	c.wg.Wait()
+	<-done
+	ws.Close()
+	h.logger.Info("websocket connection closed")
+}
+
+// checkOrigin accepts the requests without an origin, from the host itself or from one of the allowed origins.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	return CheckOrigin(h.opts.AllowedOrigins)(r)
+}
+
+// CheckOrigin returns an origin check for WebSocket upgrades that accepts the requests without an origin, from the
+// host itself or from one of the allowed origins. '*' allows any origin.
+func CheckOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err == nil && u.Host == r.Host {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// conn is a WebSocket connection. Commands are processed in order by the read loop, and every message is written by
+// the write loop, since the connection does not support concurrent writers.
+type conn struct {
+	h      *Handler
+	ws     *websocket.Conn
+	ctx    context.Context // done when the connection is closing, or when the credentials of its caller are no longer valid
+	cancel context.CancelCauseFunc
+	out    chan Message
+
+	mu   sync.Mutex
+	subs map[string]*activity.Subscription // subscriptions to the activity of every account
+	wg   sync.WaitGroup                    // forwarders of the subscriptions
+}
+
+// readLoop reads and processes the commands until the connection is closed or stops answering the pings.
+func (c *conn) readLoop() {
+	pongWait := 2 * c.h.opts.PingInterval
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.h.logger.Warnf("websocket connection closed unexpectedly: %v", err)
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(pongWait))
+		c.send(c.handle(data))
+	}
+}
+
+// writeLoop writes the messages and the pings until the connection is closing. If a write fails, the connection is
+// closed so that the read loop stops too.
+func (c *conn) writeLoop() {
+	ticker := time.NewTicker(c.h.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-c.out:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteJSON(m); err != nil {
+				c.h.logger.Errorf("failed to write websocket message: %v", err)
+				c.ws.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.ws.Close()
+				return
+			}
+		case <-c.ctx.Done():
+			var apiError *errors.APIError
+			if !stderrors.As(context.Cause(c.ctx), &apiError) {
+				c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+				return
+			}
+			// the credentials of the caller expired or were revoked, so the connection is closed without waiting for
+			// the client to answer
+			c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, apiError.Message), time.Now().Add(writeWait))
+			c.ws.Close()
+			return
+		}
+	}
+}
+
+// send queues a message to be written to the client. It gives up if the connection is closing.
+func (c *conn) send(m Message) {
+	select {
+	case c.out <- m:
+	case <-c.ctx.Done():
+	}
+}
+
+// handle processes a command and returns its ack or its error.
+func (c *conn) handle(data []byte) Message {
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		bodyErr := *errors.ErrInvalidBody
+		bodyErr.Message = fmt.Sprintf("failed to decode command: %v", err)
+		return errorMessage("", &bodyErr)
+	}
+	if err := binding.Validate(&cmd); err != nil {
+		return errorMessage(cmd.ID, err)
+	}
+	c.h.logger.Debugf("websocket command '%s' received: %s", cmd.ID, cmd.Type)
+
+	if err := c.checkCredentials(); err != nil {
+		return errorMessage(cmd.ID, err)
+	}
+	if err := auth.Authorize(c.ctx, commandOperations[cmd.Type]); err != nil {
+		return errorMessage(cmd.ID, err)
+	}
+
+	ctx, cancel := c.commandContext(cmd.ID)
+	defer cancel()
+
+	var (
+		result any
+		err    error
+	)
+	switch cmd.Type {
+	case Subscribe:
+		result, err = c.subscribe(ctx, cmd.Payload)
+	case Unsubscribe:
+		err = c.unsubscribe(cmd.Payload)
+	case Deposit, Withdrawal:
+		result, err = c.createTransaction(ctx, cmd.Type, cmd.Payload)
+	case Transfer:
+		err = c.transfer(ctx, cmd.Payload)
+	}
+	if err != nil {
+		return errorMessage(cmd.ID, err)
+	}
+	return Message{Type: Ack, ID: cmd.ID, Result: result}
+}
+
+// checkCredentials checks that the credentials of the caller of the connection are still valid. If they are not, the
+// connection is closed.
+func (c *conn) checkCredentials() error {
+	p, ok := auth.PrincipalFrom(c.ctx)
+	if !ok || c.h.auth == nil {
+		return nil
+	}
+	if err := c.h.auth.Check(p); err != nil {
+		c.cancel(err)
+		return err
+	}
+	return nil
+}
+
+// commandContext returns the context in which a command is processed. Its request id is the one of the connection
+// followed by the id of the command, so that the audit records of every command can be told apart.
+func (c *conn) commandContext(id string) (context.Context, context.CancelFunc) {
+	meta := audit.MetadataFrom(c.ctx)
+	meta.RequestID = fmt.Sprintf("%s/%s", meta.RequestID, id)
+	ctx := audit.WithMetadata(c.ctx, meta)
+
+	if c.h.opts.CommandTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.h.opts.CommandTimeout)
+}
+
+// subscribe subscribes to the balance updates of the accounts and returns their current balances. Every account is
+// checked before subscribing to any of them, and accounts already subscribed are kept.
+func (c *conn) subscribe(ctx context.Context, payload json.RawMessage) ([]activity.Balance, error) {
+	var p SubscriptionPayload
+	if err := decode(payload, &p); err != nil {
+		return nil, err
+	}
+	for _, id := range p.AccountIDs {
+		if _, err := c.h.as.GetAccountByID(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	balances := make([]activity.Balance, 0, len(p.AccountIDs))
+	for _, id := range p.AccountIDs {
+		c.mu.Lock()
+		if _, ok := c.subs[id]; !ok {
+			// the subscription is created before reading the balance, so no update is missed in between
+			sub := c.h.feed.Subscribe(id, 0)
+			c.subs[id] = sub
+			c.wg.Add(1)
+			go c.forward(id, sub)
+		}
+		c.mu.Unlock()
+
+		acc, err := c.h.as.GetAccountByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, activity.Balance{AccountID: acc.ID, Balance: acc.Balance})
+	}
+	return balances, nil
+}
+
+// unsubscribe stops the balance updates of the accounts. Accounts that are not subscribed are ignored.
+func (c *conn) unsubscribe(payload json.RawMessage) error {
+	var p SubscriptionPayload
+	if err := decode(payload, &p); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range p.AccountIDs {
+		if sub, ok := c.subs[id]; ok {
+			delete(c.subs, id)
+			sub.Cancel()
+		}
+	}
+	return nil
+}
+
+// unsubscribeAll stops the balance updates of every account.
+func (c *conn) unsubscribeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, sub := range c.subs {
+		delete(c.subs, id)
+		sub.Cancel()
+	}
+}
+
+// createTransaction deposits money into or withdraws it from an account and returns the transaction.
+func (c *conn) createTransaction(ctx context.Context, cmdType CommandType, payload json.RawMessage) (any, error) {
+	var p TransactionPayload
+	if err := decode(payload, &p); err != nil {
+		return nil, err
+	}
+
+	txType := enum.Deposit
+	if cmdType == Withdrawal {
+		txType = enum.Withdrawal
+	}
+	return c.h.ts.CreateTransaction(ctx, p.AccountID, &schemas.CreateTransactionRequest{Type: txType.String(), Amount: p.Amount})
+}
+
+// transfer transfers money from one account to another. Accounts can be referenced by their id or by their IBAN.
+func (c *conn) transfer(ctx context.Context, payload json.RawMessage) error {
+	var p schemas.TransferRequest
+	if err := decode(payload, &p); err != nil {
+		return err
+	}
+	return c.h.ts.Transfer(ctx, p.FromAccountId, p.ToAccountId, *p.Amount)
+}
+
+// forward sends the balance updates of the subscription to the client. The feed drops the subscriptions of the
+// connections that fall behind; in that case the account is subscribed again, resuming after the last update sent, or
+// with its current balance if the missed updates are no longer buffered.
+func (c *conn) forward(accountID string, sub *activity.Subscription) {
+	defer c.wg.Done()
+
+	var lastID uint64
+	for {
+		for e := range sub.Events {
+			lastID = e.ID
+			c.sendBalance(e)
+		}
+
+		// the subscription was dropped by the feed only if it is still registered
+		c.mu.Lock()
+		if c.ctx.Err() != nil || c.subs[accountID] != sub {
+			c.mu.Unlock()
+			return
+		}
+		sub = c.h.feed.Subscribe(accountID, lastID)
+		c.subs[accountID] = sub
+		c.mu.Unlock()
+
+		c.h.logger.Warnf("websocket subscription to account '%s' fell behind, resuming after event %d", accountID, lastID)
+		if sub.Reset || lastID == 0 {
+			acc, err := c.h.as.GetAccountByID(c.ctx, accountID)
+			if err == nil {
+				c.send(Message{Type: Balance, AccountID: accountID, Balance: &acc.Balance})
+			}
+		}
+		for _, e := range sub.Backlog {
+			lastID = e.ID
+			c.sendBalance(e)
+		}
+	}
+}
+
+// sendBalance sends the balance update carried by an activity event. Other events are ignored.
+func (c *conn) sendBalance(e activity.Event) {
+	if e.Type != enum.BalanceActivity {
+		return
+	}
+	var b activity.Balance
+	if err := json.Unmarshal(e.Data, &b); err != nil {
+		c.h.logger.Errorf("failed to decode balance event %d: %v", e.ID, err)
+		return
+	}
+	c.send(Message{Type: Balance, EventID: e.ID, AccountID: b.AccountID, Balance: &b.Balance})
+}
+
+// decode decodes the payload of a command into the given struct and validates it. A missing payload is decoded as
+// an empty object, so that the required fields are reported.
+func decode(payload json.RawMessage, v any) error {
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		bodyErr := *errors.ErrInvalidBody
+		bodyErr.Message = fmt.Sprintf("failed to decode command payload: %v", err)
+		return &bodyErr
+	}
+	return binding.Validate(v)
+}
+
+// errorMessage builds the error of a command. Errors that are not API errors are reported as unknown errors.
+func errorMessage(id string, err error) Message {
+	apiError, ok := err.(*errors.APIError)
+	if !ok {
+		unknownError := *errors.ErrUnknown
+		unknownError.Message = err.Error()
+		apiError = &unknownError
+	}
+	return Message{Type: Error, ID: id, Error: apiError}
+}
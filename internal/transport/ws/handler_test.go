@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"bank_test/internal/activity"
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/iban"
+	"bank_test/internal/service"
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite
+type HandlerTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	ctx    context.Context
+
+	as     service.AccountService
+	server *httptest.Server
+	client *websocket.Conn
+}
+
+func (s *HandlerTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.ctx = context.Background()
+
+	ibans, err := iban.NewGenerator("ES", "01820001")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(s.logger, 16)
+	database := activity.NewDatabase(s.logger, memory.NewInMemoryDatabase(s.logger), feed)
+	s.as = service.NewAccountService(s.logger, database, ibans)
+	ts := service.NewTransactionService(s.logger, database)
+
+	s.server = httptest.NewServer(NewHandler(s.logger, s.as, ts, feed, nil, Options{PingInterval: time.Second, CommandTimeout: time.Second}))
+	s.client = s.dial(nil)
+}
+
+func (s *HandlerTestSuite) TearDownTest() {
+	s.client.Close()
+	s.server.Close()
+}
+
+// dial opens a connection to the test server with the given headers.
+func (s *HandlerTestSuite) dial(header http.Header) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.server.URL, "http"), header)
+	s.Require().NoError(err)
+	return conn
+}
+
+// createAccount creates an account with the given balance and returns its id.
+func (s *HandlerTestSuite) createAccount(balance float64) string {
+	acc, err := s.as.CreateAccount(s.ctx, &schemas.CreateAccountRequest{Owner: "Alice", InitialBalance: &balance})
+	s.Require().NoError(err)
+	return acc.ID
+}
+
+// command sends a command to the server.
+func (s *HandlerTestSuite) command(id string, cmdType CommandType, payload any) {
+	data, err := json.Marshal(payload)
+	s.Require().NoError(err)
+	s.Require().NoError(s.client.WriteJSON(Command{ID: id, Type: cmdType, Payload: data}))
+}
+
+// receive reads the next message of the given type, skipping the others.
+func (s *HandlerTestSuite) receive(msgType MessageType) Message {
+	s.Require().NoError(s.client.SetReadDeadline(time.Now().Add(time.Second)))
+	for {
+		var m Message
+		s.Require().NoError(s.client.ReadJSON(&m))
+		if m.Type == msgType {
+			return m
+		}
+	}
+}
+
+// TestSubscribe tests that subscribed accounts receive their current balance and their balance updates.
+func (s *HandlerTestSuite) TestSubscribe() {
+	first := s.createAccount(100)
+	second := s.createAccount(50)
+
+	s.command("1", Subscribe, SubscriptionPayload{AccountIDs: []string{first, second}})
+	ack := s.receive(Ack)
+	s.Equal("1", ack.ID)
+	s.Len(ack.Result, 2)
+
+	s.command("2", Deposit, TransactionPayload{AccountID: second, Amount: ptr(25.0)})
+	update := s.receive(Balance)
+	s.Equal(second, update.AccountID)
+	s.Equal(75.0, *update.Balance)
+	s.NotZero(update.EventID)
+
+	s.Run("ok: unsubscribe", func() {
+		s.command("3", Unsubscribe, SubscriptionPayload{AccountIDs: []string{second}})
+		s.Equal("3", s.receive(Ack).ID)
+		s.command("4", Deposit, TransactionPayload{AccountID: second, Amount: ptr(25.0)})
+		s.command("5", Deposit, TransactionPayload{AccountID: first, Amount: ptr(25.0)})
+		update := s.receive(Balance)
+		s.Equal(first, update.AccountID)
+	})
+
+	s.Run("error: unknown account", func() {
+		s.command("6", Subscribe, SubscriptionPayload{AccountIDs: []string{"7b1b3ba8-9d4f-4b1a-9d9e-111111111111"}})
+		msg := s.receive(Error)
+		s.Equal("6", msg.ID)
+		s.Equal(errors.ErrAccountNotFound.Code, msg.Error.Code)
+	})
+}
+
+// TestCommands tests that every command is acknowledged with its correlation id, or answered with an API error.
+func (s *HandlerTestSuite) TestCommands() {
+	from := s.createAccount(100)
+	to := s.createAccount(0)
+
+	s.Run("ok: deposit", func() {
+		s.command("deposit", Deposit, TransactionPayload{AccountID: from, Amount: ptr(10.0)})
+		ack := s.receive(Ack)
+		s.Equal("deposit", ack.ID)
+		s.Equal(10.0, ack.Result.(map[string]any)["amount"])
+	})
+
+	s.Run("ok: transfer", func() {
+		s.command("transfer", Transfer, schemas.TransferRequest{FromAccountId: from, ToAccountId: to, Amount: ptr(60.0)})
+		s.Equal("transfer", s.receive(Ack).ID)
+		acc, err := s.as.GetAccountByID(s.ctx, to)
+		s.Require().NoError(err)
+		s.Equal(60.0, acc.Balance)
+	})
+
+	s.Run("error: insufficient balance", func() {
+		s.command("withdrawal", Withdrawal, TransactionPayload{AccountID: from, Amount: ptr(1000.0)})
+		msg := s.receive(Error)
+		s.Equal("withdrawal", msg.ID)
+		s.Equal(errors.ErrInsufficientBalance.Code, msg.Error.Code)
+	})
+
+	s.Run("error: invalid payload", func() {
+		s.command("invalid", Deposit, map[string]any{"account_id": from})
+		msg := s.receive(Error)
+		s.Equal("invalid", msg.ID)
+		s.Equal(errors.ErrInvalidBody.Code, msg.Error.Code)
+	})
+
+	s.Run("error: unknown command", func() {
+		s.command("unknown", "close", nil)
+		msg := s.receive(Error)
+		s.Equal("unknown", msg.ID)
+		s.Equal(errors.ErrInvalidBody.Code, msg.Error.Code)
+	})
+
+	s.Run("error: malformed command", func() {
+		s.Require().NoError(s.client.WriteMessage(websocket.TextMessage, []byte("{")))
+		s.Equal(errors.ErrInvalidBody.Code, s.receive(Error).Error.Code)
+	})
+}
+
+// TestCheckOrigin tests that connections from other origins are rejected unless they are allowed.
+func (s *HandlerTestSuite) TestCheckOrigin() {
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.server.URL, "http"), http.Header{"Origin": {"https://evil.example"}})
+	s.Error(err)
+	s.Equal(http.StatusForbidden, resp.StatusCode)
+
+	h := NewHandler(s.logger, nil, nil, nil, nil, Options{AllowedOrigins: []string{"https://app.example"}})
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Origin", "https://app.example")
+	s.True(h.checkOrigin(r))
+	r.Header.Set("Origin", "https://evil.example")
+	s.False(h.checkOrigin(r))
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(HandlerTestSuite))
+}
@@ -0,0 +1,70 @@
+package ws
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/auth"
+	"encoding/json"
+)
+
+// CommandType is the type of a command sent by the client.
+type CommandType string
+
+// Command types
+const (
+	Subscribe   CommandType = "subscribe"   // receive the balance updates of the accounts
+	Unsubscribe CommandType = "unsubscribe" // stop receiving the balance updates of the accounts
+	Deposit     CommandType = "deposit"     // deposit money into an account
+	Withdrawal  CommandType = "withdrawal"  // withdraw money from an account
+	Transfer    CommandType = "transfer"    // transfer money from one account to another
+)
+
+// commandOperations are the operations that the caller of the connection must be authorized to perform to send every
+// command.
+var commandOperations = map[CommandType]auth.Operation{
+	Subscribe:   auth.ReadAccounts,
+	Unsubscribe: auth.ReadAccounts,
+	Deposit:     auth.Deposit,
+	Withdrawal:  auth.Withdraw,
+	Transfer:    auth.Transfer,
+}
+
+// MessageType is the type of a message sent by the server.
+type MessageType string
+
+// Message types
+const (
+	Ack     MessageType = "ack"     // the command succeeded
+	Error   MessageType = "error"   // the command failed
+	Balance MessageType = "balance" // the balance of a subscribed account changed
+)
+
+// Command is a message sent by the client. Its id is echoed in the ack or the error of the command, so clients can
+// correlate them when several commands are in flight.
+type Command struct {
+	ID      string          `json:"id" validate:"required"`
+	Type    CommandType     `json:"type" validate:"required,oneof=subscribe unsubscribe deposit withdrawal transfer"`
+	Payload json.RawMessage `json:"payload"` // arguments of the command, which depend on its type
+}
+
+// SubscriptionPayload is the payload of the subscribe and unsubscribe commands.
+type SubscriptionPayload struct {
+	AccountIDs []string `json:"account_ids" validate:"required,dive,uuid"`
+}
+
+// TransactionPayload is the payload of the deposit and withdrawal commands.
+type TransactionPayload struct {
+	AccountID string   `json:"account_id" validate:"required,uuid"`
+	Amount    *float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// Message is a message sent by the server: the ack or the error of a command, or a balance update of a subscribed
+// account.
+type Message struct {
+	Type      MessageType      `json:"type"`
+	ID        string           `json:"id,omitempty"`         // id of the command, in acks and errors
+	Result    any              `json:"result,omitempty"`     // result of the command, in acks
+	Error     *errors.APIError `json:"error,omitempty"`      // reason why the command failed, in errors
+	EventID   uint64           `json:"event_id,omitempty"`   // id of the activity event, in balance updates
+	AccountID string           `json:"account_id,omitempty"` // account whose balance changed, in balance updates
+	Balance   *float64         `json:"balance,omitempty"`    // new balance, in balance updates
+}
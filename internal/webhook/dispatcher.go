@@ -0,0 +1,388 @@
+package webhook
+
+import (
+	"bank_test/internal/db"
+	"bank_test/internal/db/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// batchSize is the maximum number of new events read from the outbox in every round.
+	batchSize = 100
+
+	// workers is the maximum number of deliveries attempted concurrently.
+	workers = 8
+
+	// maxResponseSize is the maximum number of bytes read from the response of a delivery before discarding it.
+	maxResponseSize = 64 << 10
+)
+
+// Options configures the delivery of the events.
+type Options struct {
+	PollInterval time.Duration // interval between two rounds of deliveries. 0 disables the dispatcher
+	Timeout      time.Duration // maximum time to wait for the response of an attempt
+	MaxAttempts  int           // number of attempts before a delivery is moved to the dead-letter queue
+	Backoff      time.Duration // delay before the first retry. It is doubled after every failed attempt
+	MaxBackoff   time.Duration // maximum delay between two attempts
+}
+
+// delivery is an event waiting to be delivered to a subscription.
+type delivery struct {
+	id             string
+	subscriptionID string
+	event          models.OutboxEvent
+	attempts       int
+	next           time.Time // time of the next attempt
+	lastError      string
+	fromOutbox     bool // whether the event must be removed from the outbox once the delivery is finished
+}
+
+// Dispatcher delivers the events stored in the outbox of the database to the subscriptions. Every event is delivered
+// at least once to every subscription that accepts it: events are only removed from the outbox once every delivery
+// has either succeeded or been moved to the dead-letter queue, so the events that were being delivered when the
+// process stopped are delivered again after a restart. Receivers can discard duplicates with the delivery id.
+type Dispatcher struct {
+	logger *zap.SugaredLogger
+	outbox db.Outbox // nil if the database does not support the outbox
+	store  *Store
+	client *http.Client
+	opts   Options
+
+	round sync.Mutex // serializes the rounds of deliveries
+
+	mu         sync.Mutex
+	pending    []*delivery
+	inFlight   map[string]int // unfinished deliveries of every event read from the outbox
+	dispatched []string       // events whose deliveries are finished, waiting to be removed from the outbox
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewDispatcher creates a new dispatcher for the events of the database. If the database does not implement
+// db.Outbox, only the dead letters that are redelivered are dispatched.
+func NewDispatcher(logger *zap.SugaredLogger, database db.DatabaseAdapter, store *Store, opts Options) *Dispatcher {
+	outbox, ok := database.(db.Outbox)
+	if !ok {
+		logger.Warn("the database does not support the outbox, its events will not be delivered to the webhooks")
+	}
+
+	return &Dispatcher{
+		logger:   logger,
+		outbox:   outbox,
+		store:    store,
+		client:   &http.Client{},
+		opts:     opts,
+		pending:  make([]*delivery, 0),
+		inFlight: make(map[string]int),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs a round of deliveries every poll interval in the background until Stop is called.
+func (d *Dispatcher) Start() {
+	if d.opts.PollInterval <= 0 {
+		d.logger.Infof("webhook dispatcher is disabled")
+		close(d.done)
+		return
+	}
+
+	d.logger.Infof("dispatching webhooks every %s", d.opts.PollInterval)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-d.stop
+		cancel()
+	}()
+
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.Dispatch(ctx); err != nil {
+					d.logger.Errorf("failed to dispatch webhooks: %v", err)
+				}
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background deliveries and waits for the current round to finish. The deliveries that were
+// interrupted are attempted again after a restart.
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// Dispatch runs a round of deliveries: the new events of the outbox are scheduled for delivery to every subscription
+// that accepts them, the deliveries that are due are attempted, and the events whose deliveries are finished are
+// removed from the outbox.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	d.round.Lock()
+	defer d.round.Unlock()
+
+	if err := d.poll(ctx); err != nil {
+		return err
+	}
+
+	due := d.due(time.Now())
+	g := new(errgroup.Group)
+	g.SetLimit(workers)
+	for _, dl := range due {
+		dl := dl
+		g.Go(func() error {
+			d.deliver(ctx, dl)
+			return nil
+		})
+	}
+	g.Wait()
+
+	return d.acknowledge(ctx)
+}
+
+// Redeliver moves a dead letter back to the deliveries, with its attempts reset. It is attempted in the next round.
+func (d *Dispatcher) Redeliver(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dl, err := d.store.takeDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	d.logger.Infof("redelivering event '%s' to subscription '%s'", dl.Event.ID, dl.SubscriptionID)
+	d.pending = append(d.pending, &delivery{
+		id:             dl.ID,
+		subscriptionID: dl.SubscriptionID,
+		event:          dl.Event,
+		next:           time.Now(),
+	})
+	return nil
+}
+
+// poll reads the new events of the outbox and schedules their deliveries. The events that are already being delivered
+// are read again, so the limit is raised by their number.
+func (d *Dispatcher) poll(ctx context.Context) error {
+	if d.outbox == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	limit := batchSize + len(d.inFlight)
+	d.mu.Unlock()
+
+	events, err := d.outbox.PendingEvents(ctx, limit)
+	if err != nil {
+		return err
+	}
+	subs := d.store.ListSubscriptions()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range events {
+		if _, ok := d.inFlight[e.ID]; ok {
+			continue
+		}
+
+		deliveries := 0
+		for _, sub := range subs {
+			if !sub.Accepts(e.Type) {
+				continue
+			}
+			d.pending = append(d.pending, &delivery{id: uuid.NewString(), subscriptionID: sub.ID, event: e, next: now, fromOutbox: true})
+			deliveries++
+		}
+
+		d.inFlight[e.ID] = deliveries
+		if deliveries == 0 {
+			d.dispatched = append(d.dispatched, e.ID)
+		}
+	}
+	return nil
+}
+
+// due removes the deliveries whose next attempt is due from the pending deliveries and returns them.
+func (d *Dispatcher) due(now time.Time) []*delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	due := make([]*delivery, 0)
+	waiting := d.pending[:0]
+	for _, dl := range d.pending {
+		if dl.next.After(now) {
+			waiting = append(waiting, dl)
+		} else {
+			due = append(due, dl)
+		}
+	}
+	d.pending = waiting
+	return due
+}
+
+// deliver attempts the delivery and schedules a retry, moves it to the dead-letter queue or finishes it depending on
+// the result.
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery) {
+	sub, err := d.store.GetSubscription(dl.subscriptionID)
+	if err != nil || !sub.Active {
+		d.logger.Debugf("dropping delivery '%s': subscription '%s' was removed or deactivated", dl.id, dl.subscriptionID)
+		d.finish(dl)
+		return
+	}
+
+	dl.attempts++
+	err = d.attempt(ctx, dl, sub)
+	if err == nil {
+		d.logger.Debugf("event '%s' delivered to subscription '%s' after %d attempts", dl.event.ID, sub.ID, dl.attempts)
+		d.finish(dl)
+		return
+	}
+	if ctx.Err() != nil {
+		// the dispatcher is stopping, so the attempt does not count
+		dl.attempts--
+		d.retry(dl, time.Now())
+		return
+	}
+
+	dl.lastError = err.Error()
+	if dl.attempts < d.opts.MaxAttempts {
+		delay := d.backoff(dl.attempts)
+		d.logger.Warnf("failed to deliver event '%s' to subscription '%s' (attempt %d), retrying in %s: %v", dl.event.ID, sub.ID, dl.attempts, delay, err)
+		d.retry(dl, time.Now().Add(delay))
+		return
+	}
+
+	d.logger.Errorf("failed to deliver event '%s' to subscription '%s' after %d attempts, moving it to the dead-letter queue: %v", dl.event.ID, sub.ID, dl.attempts, err)
+	if err := d.store.addDeadLetter(DeadLetter{
+		ID:             dl.id,
+		SubscriptionID: dl.subscriptionID,
+		Event:          dl.event,
+		Attempts:       dl.attempts,
+		LastError:      dl.lastError,
+		FailedAt:       time.Now().UTC(),
+	}); err != nil {
+		// the event is kept in the outbox, so it is delivered again after a restart
+		d.logger.Errorf("failed to store dead letter '%s': %v", dl.id, err)
+		d.retry(dl, time.Now().Add(d.opts.MaxBackoff))
+		return
+	}
+	d.finish(dl)
+}
+
+// attempt sends the event to the subscription. Any response other than 2xx is a failure.
+func (d *Dispatcher) attempt(ctx context.Context, dl *delivery, sub *Subscription) error {
+	body, err := json.Marshal(dl.event)
+	if err != nil {
+		return err
+	}
+
+	if d.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, dl.id)
+	req.Header.Set(EventHeader, dl.event.Type.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// retry schedules the next attempt of the delivery.
+func (d *Dispatcher) retry(dl *delivery, next time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dl.next = next
+	d.pending = append(d.pending, dl)
+}
+
+// finish marks the delivery as finished. Once every delivery of an event read from the outbox is finished, the event
+// is removed from the outbox.
+func (d *Dispatcher) finish(dl *delivery) {
+	if !dl.fromOutbox {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inFlight[dl.event.ID]--
+	if d.inFlight[dl.event.ID] == 0 {
+		d.dispatched = append(d.dispatched, dl.event.ID)
+	}
+}
+
+// acknowledge removes the dispatched events from the outbox. If they cannot be removed, it is retried in the next round.
+func (d *Dispatcher) acknowledge(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.dispatched) == 0 {
+		return nil
+	}
+	if err := d.outbox.DeleteEvents(ctx, d.dispatched...); err != nil {
+		return err
+	}
+	for _, id := range d.dispatched {
+		delete(d.inFlight, id)
+	}
+	d.dispatched = nil
+	return nil
+}
+
+// backoff returns the delay after the given number of failed attempts: the initial backoff doubled after every
+// attempt, up to the maximum backoff, which must not be lower than the initial backoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	if d.opts.Backoff <= 0 {
+		return 0
+	}
+
+	delay := d.opts.Backoff
+	for i := 1; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		return d.opts.MaxBackoff
+	}
+	return delay
+}
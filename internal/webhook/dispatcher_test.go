@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// received is a request received by the test server.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// Define the test suite
+type DispatcherTestSuite struct {
+	suite.Suite
+	logger *zap.SugaredLogger
+	ctx    context.Context
+
+	db         memoryDatabase
+	store      *Store
+	dispatcher *Dispatcher
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	requests []received
+	failures int // number of requests that are answered with an error before succeeding
+}
+
+// memoryDatabase is the part of the memory database used by the tests.
+type memoryDatabase interface {
+	CreateAccount(ctx context.Context, account *models.Account) error
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+}
+
+func (s *DispatcherTestSuite) SetupTest() {
+	s.logger = zap.NewExample().Sugar()
+	s.ctx = context.Background()
+	s.requests = nil
+	s.failures = 0
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, received{header: r.Header, body: body})
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	var err error
+	s.store, err = NewStore(s.logger, filepath.Join(s.T().TempDir(), "webhooks.json"))
+	s.Require().NoError(err)
+
+	database := memory.NewInMemoryDatabase(s.logger)
+	s.db = database
+	s.dispatcher = NewDispatcher(s.logger, database, s.store, Options{Timeout: time.Second, MaxAttempts: 3})
+}
+
+func (s *DispatcherTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// subscribe creates an active subscription to the test server.
+func (s *DispatcherTestSuite) subscribe(eventTypes ...enum.EventType) *Subscription {
+	sub := &Subscription{ID: uuid.NewString(), URL: s.server.URL, Secret: "secret", EventTypes: eventTypes, Active: true, CreatedAt: time.Now()}
+	s.Require().NoError(s.store.CreateSubscription(sub))
+	return sub
+}
+
+// createAccount creates an account, which stores an account.created event in the outbox.
+func (s *DispatcherTestSuite) createAccount() {
+	s.Require().NoError(s.db.CreateAccount(s.ctx, &models.Account{ID: uuid.NewString(), Owner: "Alice"}))
+}
+
+// assertOutboxLen checks the number of events waiting in the outbox.
+func (s *DispatcherTestSuite) assertOutboxLen(expected int) {
+	events, err := s.db.PendingEvents(s.ctx, 0)
+	s.Require().NoError(err)
+	s.Len(events, expected)
+}
+
+// TestDeliver tests that events are delivered with a valid signature and removed from the outbox.
+func (s *DispatcherTestSuite) TestDeliver() {
+	sub := s.subscribe()
+	s.createAccount()
+
+	s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+	s.Require().Len(s.requests, 1)
+	s.assertOutboxLen(0)
+
+	req := s.requests[0]
+	timestamp, err := strconv.ParseInt(req.header.Get(TimestampHeader), 10, 64)
+	s.Require().NoError(err)
+	s.True(Verify(sub.Secret, req.header.Get(SignatureHeader), timestamp, req.body))
+	s.False(Verify("other", req.header.Get(SignatureHeader), timestamp, req.body))
+	s.False(Verify(sub.Secret, req.header.Get(SignatureHeader), timestamp+1, req.body))
+	s.Equal(enum.AccountCreated.String(), req.header.Get(EventHeader))
+	s.NotEmpty(req.header.Get(IDHeader))
+
+	var event models.OutboxEvent
+	s.Require().NoError(json.Unmarshal(req.body, &event))
+	s.Equal(enum.AccountCreated, event.Type)
+
+	// nothing is delivered twice
+	s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+	s.Len(s.requests, 1)
+}
+
+// TestRetry tests that failed deliveries are retried with the same delivery id until they succeed.
+func (s *DispatcherTestSuite) TestRetry() {
+	s.subscribe()
+	s.createAccount()
+	s.failures = 2
+
+	for i := 0; i < 3; i++ {
+		s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+	}
+	s.Require().Len(s.requests, 3)
+	s.Equal(s.requests[0].header.Get(IDHeader), s.requests[2].header.Get(IDHeader))
+	s.Empty(s.store.ListDeadLetters())
+	s.assertOutboxLen(0)
+}
+
+// TestDeadLetter tests that deliveries are moved to the dead-letter queue after every attempt fails, and that they
+// can be redelivered.
+func (s *DispatcherTestSuite) TestDeadLetter() {
+	s.subscribe()
+	s.createAccount()
+	s.failures = 3
+
+	for i := 0; i < 3; i++ {
+		s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+	}
+	s.assertOutboxLen(0)
+	letters := s.store.ListDeadLetters()
+	s.Require().Len(letters, 1)
+	s.Equal(3, letters[0].Attempts)
+	s.Equal(s.requests[0].header.Get(IDHeader), letters[0].ID)
+
+	s.Run("ok: redeliver", func() {
+		s.Require().NoError(s.dispatcher.Redeliver(letters[0].ID))
+		s.Empty(s.store.ListDeadLetters())
+		s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+		s.Len(s.requests, 4)
+	})
+
+	s.Run("error: unknown dead letter", func() {
+		s.Equal(errors.ErrDeadLetterNotFound, s.dispatcher.Redeliver(letters[0].ID))
+	})
+}
+
+// TestFilters tests that events are only delivered to the active subscriptions that accept them, and that events
+// without subscriptions are removed from the outbox.
+func (s *DispatcherTestSuite) TestFilters() {
+	s.subscribe(enum.TransferCompleted)
+	inactive := s.subscribe()
+	inactive.Active = false
+	s.Require().NoError(s.store.UpdateSubscription(inactive))
+	s.createAccount()
+
+	s.Require().NoError(s.dispatcher.Dispatch(s.ctx))
+	s.Empty(s.requests)
+	s.assertOutboxLen(0)
+}
+
+// TestBackoff tests that the delay between attempts is doubled up to the maximum backoff.
+func (s *DispatcherTestSuite) TestBackoff() {
+	d := NewDispatcher(s.logger, nil, s.store, Options{Backoff: time.Second, MaxBackoff: 10 * time.Second})
+	s.Equal(time.Second, d.backoff(1))
+	s.Equal(2*time.Second, d.backoff(2))
+	s.Equal(8*time.Second, d.backoff(4))
+	s.Equal(10*time.Second, d.backoff(5))
+	s.Equal(10*time.Second, d.backoff(100))
+}
+
+// TestStorePersistence tests that subscriptions and dead letters survive reopening the store.
+func (s *DispatcherTestSuite) TestStorePersistence() {
+	sub := s.subscribe()
+	s.Require().NoError(s.store.addDeadLetter(DeadLetter{ID: "1", SubscriptionID: sub.ID, Attempts: 3}))
+
+	reopened, err := NewStore(s.logger, s.store.path)
+	s.Require().NoError(err)
+	stored, err := reopened.GetSubscription(sub.ID)
+	s.Require().NoError(err)
+	s.Equal(sub.URL, stored.URL)
+	s.Equal(sub.Secret, stored.Secret)
+	s.Len(reopened.ListDeadLetters(), 1)
+
+	s.Require().NoError(reopened.DeleteSubscription(sub.ID))
+	_, err = reopened.GetSubscription(sub.ID)
+	s.Equal(errors.ErrSubscriptionNotFound, err)
+}
+
+func TestDispatcherTestSuite(t *testing.T) {
+	suite.Run(t, new(DispatcherTestSuite))
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Headers sent with every delivery.
+const (
+	IDHeader        = "X-Webhook-Id"        // id of the delivery. It is the same in every attempt, so receivers can discard duplicates
+	EventHeader     = "X-Webhook-Event"     // type of the event
+	TimestampHeader = "X-Webhook-Timestamp" // unix time of the attempt, in seconds
+	SignatureHeader = "X-Webhook-Signature" // HMAC-SHA256 of the timestamp and the body, keyed with the secret of the subscription
+)
+
+// signaturePrefix identifies the algorithm used to compute the signature.
+const signaturePrefix = "sha256="
+
+// Sign computes the signature of a delivery: the hex-encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed
+// with the secret of the subscription. Including the timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery in constant time.
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// NewSecret generates a random secret to sign the deliveries of a subscription.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
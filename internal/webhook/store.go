@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Subscription is an endpoint registered to receive the events of the bank.
+type Subscription struct {
+	ID         string           `json:"id"`
+	URL        string           `json:"url"`
+	Secret     string           `json:"secret,omitempty"` // key used to sign the deliveries. It is only returned when the subscription is created
+	EventTypes []enum.EventType `json:"event_types"`      // events delivered to the subscription. Empty delivers every event
+	Active     bool             `json:"active"`           // inactive subscriptions do not receive deliveries
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// Accepts checks whether the subscription receives the events of the given type.
+func (s Subscription) Accepts(eventType enum.EventType) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetter is a delivery that failed after every retry. It is kept until it is redelivered.
+type DeadLetter struct {
+	ID             string             `json:"id"` // id of the delivery, sent in every attempt
+	SubscriptionID string             `json:"subscription_id"`
+	Event          models.OutboxEvent `json:"event"`
+	Attempts       int                `json:"attempts"`
+	LastError      string             `json:"last_error"`
+	FailedAt       time.Time          `json:"failed_at"`
+}
+
+// state is the content of the store file.
+type state struct {
+	Subscriptions map[string]Subscription `json:"subscriptions"`
+	DeadLetters   map[string]DeadLetter   `json:"dead_letters"`
+}
+
+// Store keeps the webhook subscriptions and the dead-letter queue. If it has a file, it is rewritten after every
+// change, so that both survive restarts.
+type Store struct {
+	mu     sync.RWMutex
+	logger *zap.SugaredLogger
+
+	path  string
+	state state
+}
+
+// NewStore creates a new store. If path is not empty, the subscriptions and dead letters stored in the file are
+// loaded and every change is written to it.
+func NewStore(logger *zap.SugaredLogger, path string) (*Store, error) {
+	s := &Store{
+		logger: logger,
+		path:   path,
+		state: state{
+			Subscriptions: make(map[string]Subscription),
+			DeadLetters:   make(map[string]DeadLetter),
+		},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks file: %v", err)
+	}
+	logger.Infof("webhooks loaded: %d subscriptions, %d dead letters", len(s.state.Subscriptions), len(s.state.DeadLetters))
+	return s, nil
+}
+
+// CreateSubscription stores a new subscription.
+func (s *Store) CreateSubscription(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Subscriptions[sub.ID] = *sub
+	return s.save()
+}
+
+// GetSubscription retrieves a subscription by its id.
+func (s *Store) GetSubscription(id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.state.Subscriptions[id]
+	if !ok {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions retrieves all subscriptions, sorted by creation time.
+func (s *Store) ListSubscriptions() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]Subscription, 0, len(s.state.Subscriptions))
+	for _, sub := range s.state.Subscriptions {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	return subs
+}
+
+// UpdateSubscription replaces an existing subscription.
+func (s *Store) UpdateSubscription(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.state.Subscriptions[sub.ID]; !ok {
+		return errors.ErrSubscriptionNotFound
+	}
+	s.state.Subscriptions[sub.ID] = *sub
+	return s.save()
+}
+
+// DeleteSubscription removes a subscription. Its pending deliveries are dropped, but its dead letters are kept.
+func (s *Store) DeleteSubscription(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.state.Subscriptions[id]; !ok {
+		return errors.ErrSubscriptionNotFound
+	}
+	delete(s.state.Subscriptions, id)
+	return s.save()
+}
+
+// ListDeadLetters retrieves all dead letters, sorted by the time they failed.
+func (s *Store) ListDeadLetters() []DeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	letters := make([]DeadLetter, 0, len(s.state.DeadLetters))
+	for _, dl := range s.state.DeadLetters {
+		letters = append(letters, dl)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].FailedAt.Before(letters[j].FailedAt) })
+	return letters
+}
+
+// addDeadLetter stores a delivery that failed after every retry.
+func (s *Store) addDeadLetter(dl DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.DeadLetters[dl.ID] = dl
+	return s.save()
+}
+
+// takeDeadLetter removes a dead letter from the queue and returns it.
+func (s *Store) takeDeadLetter(id string) (*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dl, ok := s.state.DeadLetters[id]
+	if !ok {
+		return nil, errors.ErrDeadLetterNotFound
+	}
+	delete(s.state.DeadLetters, id)
+	if err := s.save(); err != nil {
+		s.state.DeadLetters[id] = dl
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// save writes the state to the file, if any. It is written to a temporary file first, so a crash while writing it
+// never corrupts the previous state. It must be called with the lock held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhooks: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write webhooks file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to store webhooks file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CreateAccount opens an account for the owner with the initial balance.
+func (c *Client) CreateAccount(ctx context.Context, owner string, initialBalance float64) (*Account, error) {
+	var acc Account
+	body := schemas.CreateAccountRequest{Owner: owner, InitialBalance: &initialBalance}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/accounts", body: body}, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// GetAccount retrieves an account by its id.
+func (c *Client) GetAccount(ctx context.Context, id string) (*Account, error) {
+	var acc Account
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/accounts/" + url.PathEscape(id)}, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// GetAccountByIBAN retrieves an account by its IBAN.
+func (c *Client) GetAccountByIBAN(ctx context.Context, iban string) (*Account, error) {
+	var acc Account
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/accounts/by-number/" + url.PathEscape(iban)}, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// ListAccounts retrieves all accounts.
+func (c *Client) ListAccounts(ctx context.Context) ([]Account, error) {
+	accs := make([]Account, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/accounts"}, &accs); err != nil {
+		return nil, err
+	}
+	return accs, nil
+}
+
+// CreateTransaction deposits money into or withdraws it from an account.
+func (c *Client) CreateTransaction(ctx context.Context, accountID string, txType TransactionType, amount float64) (*Transaction, error) {
+	var tx Transaction
+	body := schemas.CreateTransactionRequest{Type: txType.String(), Amount: &amount}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/accounts/" + url.PathEscape(accountID) + "/transactions", body: body}, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// Deposit deposits money into an account.
+func (c *Client) Deposit(ctx context.Context, accountID string, amount float64) (*Transaction, error) {
+	return c.CreateTransaction(ctx, accountID, Deposit, amount)
+}
+
+// Withdraw withdraws money from an account.
+func (c *Client) Withdraw(ctx context.Context, accountID string, amount float64) (*Transaction, error) {
+	return c.CreateTransaction(ctx, accountID, Withdrawal, amount)
+}
+
+// GetTransactions retrieves all transactions of an account.
+func (c *Client) GetTransactions(ctx context.Context, accountID string) ([]Transaction, error) {
+	txs := make([]Transaction, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/accounts/" + url.PathEscape(accountID) + "/transactions"}, &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// ExportStatement streams the statement of an account for the period [from, to) in the format into w, and returns the
+// number of bytes written. A zero from starts the statement at the first transaction of the account and a zero to ends
+// it now. Only the request is retried: a statement interrupted while it is being streamed returns an error, and w must
+// be discarded.
+func (c *Client) ExportStatement(ctx context.Context, accountID string, format StatementFormat, from, to time.Time, w io.Writer) (int64, error) {
+	query := url.Values{}
+	setQuery(query, "format", format.String())
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/accounts/" + url.PathEscape(accountID) + "/statements/export", query: query})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// Transfer transfers money from one account to another. Accounts can be referenced by their id or by their IBAN.
+func (c *Client) Transfer(ctx context.Context, from, to string, amount float64) error {
+	body := schemas.TransferRequest{FromAccountId: from, ToAccountId: to, Amount: &amount}
+	return c.do(ctx, request{method: http.MethodPost, path: "/transfer", body: body}, nil)
+}
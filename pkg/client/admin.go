@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bank_test/internal/transport/http/schemas"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Reconcile runs a reconciliation of the ledger and returns its report.
+func (c *Client) Reconcile(ctx context.Context) (*Report, error) {
+	var report Report
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/admin/reconciliations"}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetReconciliation retrieves the report of a reconciliation by its id.
+func (c *Client) GetReconciliation(ctx context.Context, id string) (*Report, error) {
+	var report Report
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/admin/reconciliations/" + url.PathEscape(id)}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListAuditRecords retrieves the records of the audit log that match the filter.
+func (c *Client) ListAuditRecords(ctx context.Context, filter AuditFilter) ([]AuditRecord, error) {
+	query := make(url.Values)
+	setQuery(query, "actor", filter.Actor)
+	setQuery(query, "request_id", filter.RequestID)
+	setQuery(query, "action", string(filter.Action))
+	setQuery(query, "entity_id", filter.EntityID)
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+
+	records := make([]AuditRecord, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/admin/audit", query: query}, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// VerifyAuditLog verifies the hash chain of the audit log.
+func (c *Client) VerifyAuditLog(ctx context.Context) (*Verification, error) {
+	var verification Verification
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/admin/audit/verify"}, &verification); err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// Backup streams an online backup of the database into w and returns the number of bytes written. Only the request is
+// retried: a backup interrupted while it is being streamed returns an error, and w must be discarded.
+func (c *Client) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/admin/backup"})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// CreateAPIKey creates an api key of the role granted the scopes. The returned key is the only one that carries the key
+// itself.
+func (c *Client) CreateAPIKey(ctx context.Context, name string, role Role, scopes ...Scope) (*CreatedAPIKey, error) {
+	body := schemas.CreateAPIKeyRequest{Name: name, Role: role.String(), Scopes: make([]string, 0, len(scopes))}
+	for _, scope := range scopes {
+		body.Scopes = append(body.Scopes, scope.String())
+	}
+
+	var key CreatedAPIKey
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/admin/api-keys", body: body}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys retrieves every api key, revoked ones included.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/admin/api-keys"}, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an api key, which no longer authenticates.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/api-keys/" + url.PathEscape(id)}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FreezeAccount freezes an account: its transactions and transfers are rejected with ErrAccountFrozen until it is
+// unfrozen.
+func (c *Client) FreezeAccount(ctx context.Context, id string) (*Account, error) {
+	var acc Account
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/admin/accounts/" + url.PathEscape(id) + "/freeze"}, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// UnfreezeAccount unfreezes an account.
+func (c *Client) UnfreezeAccount(ctx context.Context, id string) (*Account, error) {
+	var acc Account
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/accounts/" + url.PathEscape(id) + "/freeze"}, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// setQuery sets the query parameter if the value is not empty.
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,506 @@
+package client
+
+import (
+	"bank_test/internal/activity"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/conf"
+	"bank_test/internal/db/memory"
+	"bank_test/internal/freeze"
+	"bank_test/internal/reconciliation"
+	httptransport "bank_test/internal/transport/http"
+	"bank_test/internal/webhook"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+// Define the test suite. It runs the client against the HTTP API served in process.
+type APITestSuite struct {
+	suite.Suite
+	ctx context.Context
+
+	server *httptest.Server
+	client *Client
+}
+
+func (s *APITestSuite) SetupTest() {
+	s.ctx = context.Background()
+
+	conf.NewConfig()
+	conf.GlobalConfig.IBANCountryCode = "ES"
+	conf.GlobalConfig.IBANBankCode = "01820001"
+	conf.GlobalConfig.Currency = "EUR"
+	conf.GlobalConfig.ActivityHeartbeatInterval = time.Second
+	conf.GlobalConfig.RequestTimeout = 5 * time.Second
+	conf.GlobalConfig.IdempotencyTTL = time.Hour
+	conf.GlobalConfig.OpenAPIValidation = true
+	s.serve(Options{Actor: "alice"})
+}
+
+// serve starts the HTTP API with the current configuration, and a client of it with the options.
+func (s *APITestSuite) serve(opts Options) {
+	logger := zap.NewExample().Sugar()
+
+	auditLog, err := audit.NewLog(logger, "")
+	s.Require().NoError(err)
+	feed := activity.NewFeed(logger, 16)
+	frozen, err := freeze.NewStore(logger, "")
+	s.Require().NoError(err)
+	db := freeze.NewDatabase(logger, memory.NewInMemoryDatabase(logger), frozen)
+	db = activity.NewDatabase(logger, audit.NewDatabase(logger, db, auditLog), feed)
+	webhooks, err := webhook.NewStore(logger, "")
+	s.Require().NoError(err)
+	dispatcher := webhook.NewDispatcher(logger, db, webhooks, webhook.Options{})
+
+	authenticator, err := auth.NewAuthenticator(logger)
+	s.Require().NoError(err)
+	handler, err := httptransport.NewHttpTransport(logger, db, reconciliation.NewReconciler(logger, db), auditLog, webhooks, dispatcher, feed, authenticator).Handler()
+	s.Require().NoError(err)
+	s.server = httptest.NewServer(handler)
+	opts.HTTPClient = s.server.Client()
+	s.client, err = New(s.server.URL, opts)
+	s.Require().NoError(err)
+}
+
+func (s *APITestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// TestAccounts tests the account endpoints.
+func (s *APITestSuite) TestAccounts() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+
+	s.Run("ok: get account", func() {
+		got, err := s.client.GetAccount(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.Equal(acc, got)
+
+		got, err = s.client.GetAccountByIBAN(s.ctx, acc.IBAN)
+		s.Require().NoError(err)
+		s.Equal(acc, got)
+	})
+
+	s.Run("ok: list accounts", func() {
+		accs, err := s.client.ListAccounts(s.ctx)
+		s.Require().NoError(err)
+		s.Equal([]Account{*acc}, accs)
+	})
+
+	s.Run("error: account not found", func() {
+		_, err := s.client.GetAccount(s.ctx, uuid.NewString())
+		s.ErrorIs(err, ErrAccountNotFound)
+
+		_, err = s.client.GetAccount(s.ctx, "invalid_id")
+		s.ErrorIs(err, ErrInvalidAccountID)
+
+		_, err = s.client.GetAccountByIBAN(s.ctx, "GB82WEST12345698765433")
+		s.ErrorIs(err, ErrInvalidIBAN)
+	})
+
+	s.Run("error: invalid body", func() {
+		_, err := s.client.CreateAccount(s.ctx, "", 100)
+		s.ErrorIs(err, ErrInvalidBody)
+	})
+}
+
+// TestTransactions tests the transaction and transfer endpoints.
+func (s *APITestSuite) TestTransactions() {
+	from, err := s.client.CreateAccount(s.ctx, "from", 100)
+	s.Require().NoError(err)
+	to, err := s.client.CreateAccount(s.ctx, "to", 0)
+	s.Require().NoError(err)
+
+	s.Run("ok: deposit and withdraw", func() {
+		tx, err := s.client.Deposit(s.ctx, from.ID, 50)
+		s.Require().NoError(err)
+		s.Equal(Deposit, tx.Type)
+		_, err = s.client.Withdraw(s.ctx, from.ID, 30)
+		s.Require().NoError(err)
+
+		txs, err := s.client.GetTransactions(s.ctx, from.ID)
+		s.Require().NoError(err)
+		s.Len(txs, 2)
+		s.Equal(tx.ID, txs[0].ID)
+	})
+
+	s.Run("ok: transfer", func() {
+		s.Require().NoError(s.client.Transfer(s.ctx, from.ID, to.IBAN, 20))
+
+		acc, err := s.client.GetAccount(s.ctx, to.ID)
+		s.Require().NoError(err)
+		s.Equal(20.0, acc.Balance)
+		acc, err = s.client.GetAccount(s.ctx, from.ID)
+		s.Require().NoError(err)
+		s.Equal(100.0, acc.Balance)
+	})
+
+	s.Run("error: insufficient balance", func() {
+		_, err := s.client.Withdraw(s.ctx, to.ID, 1000)
+		s.ErrorIs(err, ErrInsufficientBalance)
+		s.ErrorIs(s.client.Transfer(s.ctx, to.ID, from.ID, 1000), ErrInsufficientBalance)
+	})
+
+	s.Run("error: invalid amount", func() {
+		_, err := s.client.Deposit(s.ctx, from.ID, -1)
+		s.ErrorIs(err, ErrInvalidBody)
+	})
+}
+
+// TestStatements tests exporting the statements of an account.
+func (s *APITestSuite) TestStatements() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+	_, err = s.client.Deposit(s.ctx, acc.ID, 25.5)
+	s.Require().NoError(err)
+	_, err = s.client.Withdraw(s.ctx, acc.ID, 10)
+	s.Require().NoError(err)
+
+	s.Run("ok: csv", func() {
+		var buf bytes.Buffer
+		_, err := s.client.ExportStatement(s.ctx, acc.ID, "", time.Time{}, time.Time{}, &buf)
+		s.Require().NoError(err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		s.Require().Len(lines, 5)
+		s.True(strings.HasSuffix(lines[1], ",opening_balance,Opening balance,,EUR,100.00"))
+		s.True(strings.HasSuffix(lines[3], ",withdrawal,Withdrawal,-10.00,EUR,115.50"))
+		s.True(strings.HasSuffix(lines[4], ",closing_balance,Closing balance,,EUR,115.50"))
+	})
+
+	s.Run("ok: camt053 of a period", func() {
+		var buf bytes.Buffer
+		from := time.Now().Add(time.Hour)
+		_, err := s.client.ExportStatement(s.ctx, acc.ID, StatementCAMT053, from, from.Add(time.Hour), &buf)
+		s.Require().NoError(err)
+		s.Contains(buf.String(), "<IBAN>"+acc.IBAN+"</IBAN>")
+		s.Contains(buf.String(), `<Amt Ccy="EUR">115.50</Amt>`)
+		s.NotContains(buf.String(), "<Ntry>")
+	})
+
+	s.Run("error: invalid parameters", func() {
+		_, err := s.client.ExportStatement(s.ctx, acc.ID, "pdf", time.Time{}, time.Time{}, &bytes.Buffer{})
+		s.ErrorIs(err, ErrInvalidStatementFormat)
+
+		now := time.Now()
+		_, err = s.client.ExportStatement(s.ctx, acc.ID, StatementOFX, now, now.Add(-time.Hour), &bytes.Buffer{})
+		s.ErrorIs(err, ErrInvalidTimeRange)
+
+		_, err = s.client.ExportStatement(s.ctx, uuid.NewString(), StatementOFX, time.Time{}, time.Time{}, &bytes.Buffer{})
+		s.ErrorIs(err, ErrAccountNotFound)
+	})
+}
+
+// TestPayments tests the submission of pain.001 documents.
+func (s *APITestSuite) TestPayments() {
+	alice, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+	bob, err := s.client.CreateAccount(s.ctx, "Bob", 0)
+	s.Require().NoError(err)
+
+	document := func(amounts ...string) []byte {
+		var transfers strings.Builder
+		for i, amount := range amounts {
+			fmt.Fprintf(&transfers, `<CdtTrfTxInf><PmtId><EndToEndId>E2E-%d</EndToEndId></PmtId><Amt><InstdAmt Ccy="EUR">%s</InstdAmt></Amt><CdtrAcct><Id><IBAN>%s</IBAN></Id></CdtrAcct></CdtTrfTxInf>`, i, amount, bob.IBAN)
+		}
+		return []byte(fmt.Sprintf(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn><GrpHdr><MsgId>%s</MsgId><NbOfTxs>%d</NbOfTxs></GrpHdr><PmtInf><PmtInfId>PMT-1</PmtInfId><PmtMtd>TRF</PmtMtd><DbtrAcct><Id><IBAN>%s</IBAN></Id></DbtrAcct>%s</PmtInf></CstmrCdtTrfInitn></Document>`, uuid.NewString(), len(amounts), alice.IBAN, transfers.String()))
+	}
+
+	s.Run("ok: atomic", func() {
+		var buf bytes.Buffer
+		_, err := s.client.SubmitPain001(s.ctx, document("30", "80"), "", &buf)
+		s.Require().NoError(err)
+		s.Contains(buf.String(), "<GrpSts>RJCT</GrpSts>")
+		s.Contains(buf.String(), "<Cd>AM04</Cd>")
+
+		got, err := s.client.GetAccount(s.ctx, alice.ID)
+		s.Require().NoError(err)
+		s.Equal(100.0, got.Balance)
+	})
+
+	s.Run("ok: per item", func() {
+		var buf bytes.Buffer
+		_, err := s.client.SubmitPain001(s.ctx, document("30", "80"), PerItemExecution, &buf)
+		s.Require().NoError(err)
+		s.Contains(buf.String(), "<GrpSts>PART</GrpSts>")
+
+		got, err := s.client.GetAccount(s.ctx, bob.ID)
+		s.Require().NoError(err)
+		s.Equal(30.0, got.Balance)
+	})
+
+	s.Run("error: invalid request", func() {
+		_, err := s.client.SubmitPain001(s.ctx, []byte("<Document>"), AtomicExecution, &bytes.Buffer{})
+		s.ErrorIs(err, ErrInvalidPaymentDocument)
+
+		_, err = s.client.SubmitPain001(s.ctx, document("30"), "all_or_nothing", &bytes.Buffer{})
+		s.ErrorIs(err, ErrInvalidExecutionMode)
+	})
+}
+
+// TestIdempotency tests that the requests sent with the same idempotency key are performed once.
+func (s *APITestSuite) TestIdempotency() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+	ctx := WithIdempotencyKey(s.ctx, uuid.NewString())
+
+	s.Run("ok: replayed", func() {
+		first, err := s.client.Deposit(ctx, acc.ID, 10)
+		s.Require().NoError(err)
+		second, err := s.client.Deposit(ctx, acc.ID, 10)
+		s.Require().NoError(err)
+		s.Equal(first, second)
+
+		got, err := s.client.GetAccount(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.Equal(110.0, got.Balance)
+	})
+
+	s.Run("ok: errors are replayed", func() {
+		ctx := WithIdempotencyKey(s.ctx, uuid.NewString())
+		_, err := s.client.Withdraw(ctx, acc.ID, 1000)
+		s.ErrorIs(err, ErrInsufficientBalance)
+
+		_, err = s.client.Deposit(s.ctx, acc.ID, 1000)
+		s.Require().NoError(err)
+		_, err = s.client.Withdraw(ctx, acc.ID, 1000)
+		s.ErrorIs(err, ErrInsufficientBalance)
+	})
+
+	s.Run("ok: keys are scoped to the actor", func() {
+		other, err := New(s.server.URL, Options{HTTPClient: s.server.Client(), Actor: "bob"})
+		s.Require().NoError(err)
+		tx, err := other.Deposit(ctx, acc.ID, 5)
+		s.Require().NoError(err)
+		s.Equal(5.0, tx.Amount)
+	})
+
+	s.Run("error: key reused", func() {
+		_, err := s.client.Deposit(ctx, acc.ID, 20)
+		s.ErrorIs(err, ErrIdempotencyKeyReused)
+
+		_, err = s.client.Deposit(WithIdempotencyKey(s.ctx, string(bytes.Repeat([]byte("k"), 256))), acc.ID, 20)
+		s.ErrorIs(err, ErrInvalidIdempotencyKey)
+	})
+}
+
+// TestEvents tests streaming the events of an account.
+func (s *APITestSuite) TestEvents() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+	tx, err := s.client.Deposit(s.ctx, acc.ID, 10)
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	s.Run("ok: backlog and new events", func() {
+		stream, err := s.client.StreamAccountEvents(ctx, acc.ID, 0)
+		s.Require().NoError(err)
+		defer stream.Close()
+
+		_, err = s.client.Withdraw(s.ctx, acc.ID, 5)
+		s.Require().NoError(err)
+
+		e, err := stream.Next()
+		s.Require().NoError(err)
+		s.Equal(TransactionActivity, e.Type)
+		var got Transaction
+		s.Require().NoError(json.Unmarshal(e.Data, &got))
+		s.NotEqual(tx.ID, got.ID)
+		s.Equal(Withdrawal, got.Type)
+
+		e, err = stream.Next()
+		s.Require().NoError(err)
+		s.Equal(BalanceActivity, e.Type)
+		s.Equal(e.ID, stream.LastEventID())
+	})
+
+	s.Run("ok: resumed", func() {
+		stream, err := s.client.StreamAccountEvents(ctx, acc.ID, 1)
+		s.Require().NoError(err)
+		defer stream.Close()
+
+		e, err := stream.Next()
+		s.Require().NoError(err)
+		s.Greater(e.ID, uint64(1))
+	})
+
+	s.Run("error: account not found", func() {
+		_, err := s.client.StreamAccountEvents(ctx, uuid.NewString(), 0)
+		s.ErrorIs(err, ErrAccountNotFound)
+	})
+}
+
+// TestAdmin tests the admin endpoints.
+func (s *APITestSuite) TestAdmin() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+
+	s.Run("ok: reconciliation", func() {
+		report, err := s.client.Reconcile(s.ctx)
+		s.Require().NoError(err)
+		got, err := s.client.GetReconciliation(s.ctx, report.ID)
+		s.Require().NoError(err)
+		s.Equal(report.ID, got.ID)
+
+		_, err = s.client.GetReconciliation(s.ctx, uuid.NewString())
+		s.ErrorIs(err, ErrReportNotFound)
+	})
+
+	s.Run("ok: audit log", func() {
+		records, err := s.client.ListAuditRecords(s.ctx, AuditFilter{Actor: "alice", EntityID: acc.ID, From: time.Now().Add(-time.Hour)})
+		s.Require().NoError(err)
+		s.Len(records, 1)
+
+		verification, err := s.client.VerifyAuditLog(s.ctx)
+		s.Require().NoError(err)
+		s.True(verification.Valid)
+	})
+
+	s.Run("error: backup not supported", func() {
+		_, err := s.client.Backup(s.ctx, &bytes.Buffer{})
+		s.ErrorIs(err, ErrBackupNotSupported)
+	})
+}
+
+// TestWebhooks tests the webhook endpoints.
+func (s *APITestSuite) TestWebhooks() {
+	sub, err := s.client.CreateWebhook(s.ctx, CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: []string{string(AccountCreated)}})
+	s.Require().NoError(err)
+	s.NotEmpty(sub.Secret)
+
+	s.Run("ok: get and list", func() {
+		got, err := s.client.GetWebhook(s.ctx, sub.ID)
+		s.Require().NoError(err)
+		s.Equal(sub.URL, got.URL)
+		s.Empty(got.Secret)
+
+		subs, err := s.client.ListWebhooks(s.ctx)
+		s.Require().NoError(err)
+		s.Len(subs, 1)
+	})
+
+	s.Run("ok: update", func() {
+		active := false
+		got, err := s.client.UpdateWebhook(s.ctx, sub.ID, UpdateWebhookRequest{URL: "https://example.com/other", Active: &active})
+		s.Require().NoError(err)
+		s.Equal("https://example.com/other", got.URL)
+		s.False(got.Active)
+	})
+
+	s.Run("ok: dead letters", func() {
+		letters, err := s.client.ListDeadLetters(s.ctx)
+		s.Require().NoError(err)
+		s.Empty(letters)
+
+		s.ErrorIs(s.client.RedeliverDeadLetter(s.ctx, uuid.NewString()), ErrDeadLetterNotFound)
+	})
+
+	s.Run("ok: delete", func() {
+		s.Require().NoError(s.client.DeleteWebhook(s.ctx, sub.ID))
+		_, err := s.client.GetWebhook(s.ctx, sub.ID)
+		s.ErrorIs(err, ErrSubscriptionNotFound)
+	})
+}
+
+// TestFreeze tests freezing and unfreezing accounts.
+func (s *APITestSuite) TestFreeze() {
+	acc, err := s.client.CreateAccount(s.ctx, "Alice", 100)
+	s.Require().NoError(err)
+	other, err := s.client.CreateAccount(s.ctx, "Bob", 100)
+	s.Require().NoError(err)
+
+	s.Run("ok: freeze", func() {
+		frozen, err := s.client.FreezeAccount(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.True(frozen.Frozen)
+
+		got, err := s.client.GetAccount(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.True(got.Frozen)
+	})
+
+	s.Run("error: frozen account", func() {
+		_, err := s.client.Deposit(s.ctx, acc.ID, 10)
+		s.ErrorIs(err, ErrAccountFrozen)
+		err = s.client.Transfer(s.ctx, other.ID, acc.ID, 10)
+		s.ErrorIs(err, ErrAccountFrozen)
+	})
+
+	s.Run("ok: unfreeze", func() {
+		unfrozen, err := s.client.UnfreezeAccount(s.ctx, acc.ID)
+		s.Require().NoError(err)
+		s.False(unfrozen.Frozen)
+
+		_, err = s.client.Deposit(s.ctx, acc.ID, 10)
+		s.NoError(err)
+	})
+
+	s.Run("error: unknown account", func() {
+		_, err := s.client.FreezeAccount(s.ctx, uuid.NewString())
+		s.ErrorIs(err, ErrAccountNotFound)
+	})
+}
+
+// TestAPIKeys tests managing api keys and authenticating with them.
+func (s *APITestSuite) TestAPIKeys() {
+	s.server.Close()
+	conf.GlobalConfig.AuthEnabled = true
+	conf.GlobalConfig.AdminAPIKey = "admin-key-of-the-configuration-0123456789"
+	s.serve(Options{Token: conf.GlobalConfig.AdminAPIKey})
+
+	created, err := s.client.CreateAPIKey(s.ctx, "reader", TellerRole, AccountsRead)
+	s.Require().NoError(err)
+	reader, err := New(s.server.URL, Options{HTTPClient: s.server.Client(), Token: created.Key})
+	s.Require().NoError(err)
+
+	s.Run("ok: granted scope", func() {
+		_, err := reader.ListAccounts(s.ctx)
+		s.NoError(err)
+	})
+
+	s.Run("error: missing scope", func() {
+		_, err := reader.CreateAccount(s.ctx, "Alice", 100)
+		s.ErrorIs(err, ErrInsufficientScope)
+	})
+
+	s.Run("error: operation not allowed to the role", func() {
+		_, err := reader.FreezeAccount(s.ctx, uuid.NewString())
+		s.ErrorIs(err, ErrForbidden)
+	})
+
+	s.Run("ok: list keys", func() {
+		keys, err := s.client.ListAPIKeys(s.ctx)
+		s.Require().NoError(err)
+		s.Require().Len(keys, 1)
+		s.Equal(created.APIKey, keys[0])
+	})
+
+	s.Run("error: revoked key", func() {
+		revoked, err := s.client.RevokeAPIKey(s.ctx, created.ID)
+		s.Require().NoError(err)
+		s.NotNil(revoked.RevokedAt)
+
+		_, err = reader.ListAccounts(s.ctx)
+		s.ErrorIs(err, ErrUnauthenticated)
+	})
+
+	s.Run("error: unknown key", func() {
+		_, err := s.client.RevokeAPIKey(s.ctx, "unknown")
+		s.ErrorIs(err, ErrAPIKeyNotFound)
+	})
+}
+
+func TestAPITestSuite(t *testing.T) {
+	suite.Run(t, new(APITestSuite))
+}
@@ -0,0 +1,266 @@
+// Package client is a typed Go client of the HTTP API of the bank.
+//
+// Every endpoint of the API has a method. Errors returned by the API are decoded into *Error values that can be
+// compared with the sentinel errors of this package using errors.Is:
+//
+//	acc, err := c.GetAccount(ctx, id)
+//	if errors.Is(err, client.ErrAccountNotFound) {
+//		...
+//	}
+//
+// Requests that fail because the API could not be reached or was temporarily unavailable are retried with an
+// exponential backoff. POST requests are sent with an Idempotency-Key header, generated for every call unless one is
+// given with WithIdempotencyKey, so the API performs them once no matter how many times they are retried. This only
+// holds when the API keeps the idempotency keys (IDEMPOTENCY_TTL > 0): otherwise a POST whose response was lost may be
+// performed again, and Options.NoPostRetries should be set to send POST requests once.
+package client
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries of a failed request when the options do not set one.
+	DefaultMaxRetries = 3
+
+	// DefaultMinBackoff is the delay before the first retry of a failed request when the options do not set one.
+	DefaultMinBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff is the maximum delay between two attempts of a request when the options do not set one.
+	DefaultMaxBackoff = 5 * time.Second
+
+	actorHeader          = "X-Actor"
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// Options configures a client. The zero value is valid.
+type Options struct {
+	HTTPClient *http.Client  // client used to send the requests. Defaults to a client that waits 30 seconds for the responses
+	Actor      string        // sent in the X-Actor header and recorded in the audit log
+	Token      string        // sent as a bearer token in the Authorization header
+	MaxRetries int           // number of retries of a failed request. Defaults to DefaultMaxRetries; negative disables them
+	MinBackoff time.Duration // delay before the first retry, doubled after every failed attempt. Defaults to DefaultMinBackoff
+	MaxBackoff time.Duration // maximum delay between two attempts. Defaults to DefaultMaxBackoff
+
+	// NoPostRetries disables the retries of POST requests, which are only safe when the API keeps the idempotency
+	// keys (IDEMPOTENCY_TTL > 0). The other requests are still retried.
+	NoPostRetries bool
+}
+
+// Client calls the HTTP API of the bank. It is safe for concurrent use.
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	actor   string
+	token   string
+	retry   retryPolicy
+	noPost  bool // the POST requests are not retried
+}
+
+// New creates a client of the API served at baseURL.
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url '%s': the scheme must be http or https", baseURL)
+	}
+
+	// the default client bounds the wait for the headers of the response instead of the whole request, so that
+	// backups and event streams are not cut
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = 30 * time.Second
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	retry := retryPolicy{maxRetries: opts.MaxRetries, minBackoff: opts.MinBackoff, maxBackoff: opts.MaxBackoff}
+	if retry.maxRetries == 0 {
+		retry.maxRetries = DefaultMaxRetries
+	}
+	if retry.minBackoff <= 0 {
+		retry.minBackoff = DefaultMinBackoff
+	}
+	if retry.maxBackoff <= 0 {
+		retry.maxBackoff = DefaultMaxBackoff
+	}
+	if retry.maxBackoff < retry.minBackoff {
+		retry.maxBackoff = retry.minBackoff
+	}
+
+	return &Client{baseURL: u, http: httpClient, actor: opts.Actor, token: opts.Token, retry: retry,
+		noPost: opts.NoPostRetries}, nil
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context that sends the key in the Idempotency-Key header of the POST requests made with
+// it, instead of a generated one. Callers that retry an operation on their own, for example after a restart, can
+// reuse the key of the first attempt so that the API performs it once.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// request is a request to the API.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	raw    []byte // body sent as is instead of body, with the Content-Type of header
+	header http.Header
+}
+
+// do sends the request with the JSON encoding of its body, if any, retrying it while it fails with a retryable error,
+// and decodes the response into out. Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends the request, retrying it while it fails with a retryable error, and returns the successful response.
+// The caller must close its body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	body := req.raw
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	header := req.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if header.Get("Accept") == "" {
+		header.Set("Accept", "application/json")
+	}
+	if body != nil && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+	if c.actor != "" {
+		header.Set(actorHeader, c.actor)
+	}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	if req.method == http.MethodPost {
+		key, _ := ctx.Value(idempotencyKey{}).(string)
+		if key == "" {
+			key = uuid.NewString()
+		}
+		header.Set(idempotencyKeyHeader, key)
+	}
+
+	retry := c.retry
+	if req.method == http.MethodPost && c.noPost {
+		retry.maxRetries = -1
+	}
+
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header = header.Clone()
+
+		resp, err := c.http.Do(httpReq)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+		if err == nil {
+			err = decodeError(resp)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		delay, ok := retry.next(attempt, resp, err)
+		if !ok {
+			return nil, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// decodeError reads the error of the response and closes its body. Responses that are not an API error are returned
+// as a *ResponseError.
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return &ResponseError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	apiError := &Error{HTTPStatus: resp.StatusCode}
+	if err := json.Unmarshal(data, apiError); err != nil || apiError.Code == "" {
+		return &ResponseError{StatusCode: resp.StatusCode, Status: resp.Status, Body: data}
+	}
+	return apiError
+}
+
+// ResponseError is returned when the API answers with an error that is not an API error, for example when a proxy in
+// front of it fails.
+type ResponseError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("unexpected response: %s", e.Status)
+}
+
+// IsRetryable reports whether the error is temporary, so that the request may succeed if it is sent again.
+func IsRetryable(err error) bool {
+	var apiError *Error
+	if stderrors.As(err, &apiError) {
+		return apiError.Code == errors.ErrTimeout.Code || apiError.Code == errors.ErrIdempotencyKeyInUse.Code ||
+			apiError.HTTPStatus == http.StatusTooManyRequests || apiError.HTTPStatus == http.StatusServiceUnavailable
+	}
+	var respError *ResponseError
+	if stderrors.As(err, &respError) {
+		switch respError.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// failures to reach the API or to read its response
+	return !stderrors.Is(err, context.Canceled) && !stderrors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the test suite
+type ClientTestSuite struct {
+	suite.Suite
+
+	mu        sync.Mutex
+	server    *httptest.Server
+	responses []func(w http.ResponseWriter) // responses of the next requests, in order. Then 200 with an account
+	requests  []*http.Request
+	bodies    []string
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.responses = nil
+	s.requests = nil
+	s.bodies = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		var respond func(w http.ResponseWriter)
+		if len(s.responses) > 0 {
+			respond, s.responses = s.responses[0], s.responses[1:]
+		}
+		s.mu.Unlock()
+
+		if respond == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Account{ID: "id", Owner: "Alice", Balance: 10})
+			return
+		}
+		respond(w)
+	}))
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// client creates a client of the test server, with short backoffs unless the options set them.
+func (s *ClientTestSuite) client(opts Options) *Client {
+	opts.HTTPClient = s.server.Client()
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff, opts.MaxBackoff = time.Millisecond, 5*time.Millisecond
+	}
+	c, err := New(s.server.URL+"/", opts)
+	s.Require().NoError(err)
+	return c
+}
+
+// respondWith queues responses with the status and body.
+func (s *ClientTestSuite) respondWith(status int, body any, n int) {
+	for i := 0; i < n; i++ {
+		s.responses = append(s.responses, func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(body)
+		})
+	}
+}
+
+// TestNew tests creating clients.
+func (s *ClientTestSuite) TestNew() {
+	s.Run("ok: defaults", func() {
+		c, err := New("https://bank.example.com/api/", Options{})
+		s.Require().NoError(err)
+		s.Equal("https://bank.example.com/api", c.baseURL.String())
+		s.NotNil(c.http)
+		s.Equal(retryPolicy{maxRetries: DefaultMaxRetries, minBackoff: DefaultMinBackoff, maxBackoff: DefaultMaxBackoff}, c.retry)
+	})
+
+	s.Run("error: invalid base url", func() {
+		for _, baseURL := range []string{"bank.example.com", "ftp://bank.example.com", "http://[::1"} {
+			_, err := New(baseURL, Options{})
+			s.Error(err, baseURL)
+		}
+	})
+}
+
+// TestHeaders tests the headers sent with the requests.
+func (s *ClientTestSuite) TestHeaders() {
+	c := s.client(Options{Actor: "alice", Token: "secret"})
+
+	s.Run("ok: credentials", func() {
+		_, err := c.GetAccount(context.Background(), "id")
+		s.Require().NoError(err)
+		s.Equal("alice", s.requests[0].Header.Get("X-Actor"))
+		s.Equal("Bearer secret", s.requests[0].Header.Get("Authorization"))
+		s.Empty(s.requests[0].Header.Get(idempotencyKeyHeader))
+		s.Equal("/accounts/id", s.requests[0].URL.Path)
+	})
+
+	s.Run("ok: generated idempotency keys", func() {
+		s.requests, s.bodies = nil, nil
+		_, err := c.CreateAccount(context.Background(), "Alice", 10)
+		s.Require().NoError(err)
+		_, err = c.CreateAccount(context.Background(), "Alice", 10)
+		s.Require().NoError(err)
+
+		first, second := s.requests[0].Header.Get(idempotencyKeyHeader), s.requests[1].Header.Get(idempotencyKeyHeader)
+		s.NotEmpty(first)
+		s.NotEqual(first, second)
+		s.JSONEq(`{"owner":"Alice","initial_balance":10}`, s.bodies[0])
+	})
+
+	s.Run("ok: given idempotency key", func() {
+		s.requests, s.bodies = nil, nil
+		_, err := c.Deposit(WithIdempotencyKey(context.Background(), "key"), "id", 10)
+		s.Require().NoError(err)
+		s.Equal("key", s.requests[0].Header.Get(idempotencyKeyHeader))
+	})
+}
+
+// TestErrors tests decoding the errors of the API.
+func (s *ClientTestSuite) TestErrors() {
+	c := s.client(Options{})
+
+	s.Run("ok: api error", func() {
+		s.respondWith(http.StatusBadRequest, Error{Code: "ACCOUNT_NOT_FOUND", Message: "account not found"}, 1)
+		_, err := c.GetAccount(context.Background(), "id")
+		s.ErrorIs(err, ErrAccountNotFound)
+		s.NotErrorIs(err, ErrInvalidAccountID)
+
+		var apiError *Error
+		s.Require().True(stderrors.As(err, &apiError))
+		s.Equal(http.StatusBadRequest, apiError.HTTPStatus)
+		s.Len(s.requests, 1)
+	})
+
+	s.Run("ok: unexpected response", func() {
+		s.requests, s.bodies = nil, nil
+		s.responses = append(s.responses, func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "forbidden")
+		})
+		_, err := c.ListAccounts(context.Background())
+
+		var respError *ResponseError
+		s.Require().True(stderrors.As(err, &respError))
+		s.Equal(http.StatusForbidden, respError.StatusCode)
+		s.Equal("forbidden", string(respError.Body))
+		s.Len(s.requests, 1)
+	})
+
+	s.Run("error: invalid response", func() {
+		s.responses = append(s.responses, func(w http.ResponseWriter) { io.WriteString(w, "{") })
+		_, err := c.ListAccounts(context.Background())
+		s.ErrorContains(err, "failed to decode response")
+	})
+}
+
+// TestRetries tests retrying the requests that fail with a retryable error.
+func (s *ClientTestSuite) TestRetries() {
+	s.Run("ok: retried until success", func() {
+		s.requests, s.bodies = nil, nil
+		c := s.client(Options{})
+		s.respondWith(http.StatusServiceUnavailable, "unavailable", 1)
+		s.respondWith(ErrTimeout.HTTPStatus, ErrTimeout, 1)
+		s.respondWith(ErrIdempotencyKeyInUse.HTTPStatus, ErrIdempotencyKeyInUse, 1)
+
+		tx, err := c.Withdraw(context.Background(), "id", 5)
+		s.Require().NoError(err)
+		s.NotNil(tx)
+		s.Require().Len(s.requests, 4)
+		key := s.requests[0].Header.Get(idempotencyKeyHeader)
+		for i, r := range s.requests {
+			s.Equal(key, r.Header.Get(idempotencyKeyHeader))
+			s.JSONEq(`{"type":"withdrawal","amount":5}`, s.bodies[i])
+		}
+	})
+
+	s.Run("ok: retries exhausted", func() {
+		s.requests, s.bodies = nil, nil
+		c := s.client(Options{MaxRetries: 2})
+		s.respondWith(http.StatusBadGateway, "bad gateway", 3)
+
+		err := c.Transfer(context.Background(), "a", "b", 5)
+		var respError *ResponseError
+		s.Require().True(stderrors.As(err, &respError))
+		s.Equal(http.StatusBadGateway, respError.StatusCode)
+		s.Len(s.requests, 3)
+	})
+
+	s.Run("ok: retries disabled", func() {
+		s.requests, s.bodies = nil, nil
+		c := s.client(Options{MaxRetries: -1})
+		s.respondWith(http.StatusServiceUnavailable, "unavailable", 1)
+
+		_, err := c.ListWebhooks(context.Background())
+		s.Error(err)
+		s.Len(s.requests, 1)
+	})
+
+	s.Run("ok: post retries disabled", func() {
+		s.requests, s.bodies = nil, nil
+		c := s.client(Options{MaxRetries: 2, NoPostRetries: true})
+		s.respondWith(http.StatusServiceUnavailable, "unavailable", 1)
+
+		_, err := c.Deposit(context.Background(), "id", 5)
+		s.Error(err)
+		s.Len(s.requests, 1)
+
+		s.requests, s.bodies = nil, nil
+		s.respondWith(http.StatusServiceUnavailable, "unavailable", 1)
+		_, err = c.GetAccount(context.Background(), "id")
+		s.NoError(err)
+		s.Len(s.requests, 2)
+	})
+
+	s.Run("ok: connection errors", func() {
+		c, err := New("http://127.0.0.1:1", Options{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+		s.Require().NoError(err)
+		_, err = c.ListAccounts(context.Background())
+		s.Error(err)
+		s.True(IsRetryable(err))
+	})
+
+	s.Run("ok: canceled context", func() {
+		s.requests, s.bodies = nil, nil
+		c := s.client(Options{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+		s.respondWith(http.StatusServiceUnavailable, "unavailable", 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := c.ListAccounts(ctx)
+		s.ErrorIs(err, context.DeadlineExceeded)
+		s.Len(s.requests, 1)
+	})
+}
+
+// TestBackoff tests the delays between the attempts of a request.
+func (s *ClientTestSuite) TestBackoff() {
+	p := retryPolicy{maxRetries: 40, minBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	unavailable := &ResponseError{StatusCode: http.StatusServiceUnavailable}
+
+	s.Run("ok: exponential", func() {
+		for attempt, backoff := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+			delay, ok := p.next(attempt, nil, unavailable)
+			s.True(ok)
+			s.GreaterOrEqual(delay, backoff*time.Millisecond/2)
+			s.LessOrEqual(delay, backoff*time.Millisecond)
+		}
+		delay, ok := p.next(39, nil, unavailable)
+		s.True(ok)
+		s.LessOrEqual(delay, time.Second)
+	})
+
+	s.Run("ok: retry after", func() {
+		resp := &http.Response{Header: http.Header{"Retry-After": {"0"}}}
+		delay, ok := p.next(0, resp, unavailable)
+		s.True(ok)
+		s.Zero(delay)
+
+		resp.Header.Set("Retry-After", "60")
+		delay, _ = p.next(0, resp, unavailable)
+		s.Equal(time.Second, delay)
+	})
+
+	s.Run("ok: not retried", func() {
+		_, ok := p.next(40, nil, unavailable)
+		s.False(ok)
+		_, ok = p.next(0, nil, ErrInsufficientBalance)
+		s.False(ok)
+		_, ok = p.next(0, nil, &ResponseError{StatusCode: http.StatusInternalServerError})
+		s.False(ok)
+		_, ok = p.next(0, nil, context.Canceled)
+		s.False(ok)
+	})
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Event is an event of the activity stream of an account. Its data is the transaction or the balance of the account,
+// depending on its type.
+type Event struct {
+	ID   uint64 // position of the event in the feed. Zero for reset events
+	Type ActivityType
+	Data json.RawMessage
+}
+
+// EventStream reads the events of an account as they are published. It is not safe for concurrent use.
+type EventStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	lastID uint64
+}
+
+// StreamAccountEvents opens the activity stream of an account. If lastEventID is not zero, the stream resumes after
+// that event: the missed events are sent first, or a reset event if they are no longer buffered. The stream is closed
+// when the context is done or Close is called.
+func (c *Client) StreamAccountEvents(ctx context.Context, accountID string, lastEventID uint64) (*EventStream, error) {
+	header := make(http.Header)
+	if lastEventID != 0 {
+		header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/accounts/" + url.PathEscape(accountID) + "/events", header: header})
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: resp.Body, reader: bufio.NewReader(resp.Body), lastID: lastEventID}, nil
+}
+
+// Next blocks until the next event is received. It returns io.EOF when the server closes the stream; it can then be
+// reopened from LastEventID.
+func (s *EventStream) Next() (*Event, error) {
+	e := &Event{}
+	data := make([]string, 0, 1)
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line != "" {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// a blank line ends the event. Heartbeats and other comments have no fields
+			if e.Type == "" && len(data) == 0 {
+				continue
+			}
+			e.Data = json.RawMessage(strings.Join(data, "\n"))
+			if e.ID != 0 {
+				s.lastID = e.ID
+			}
+			return e, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+				e.ID = id
+			}
+		case "event":
+			e.Type = ActivityType(value)
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+// LastEventID returns the id of the last event received, to resume the stream from it.
+func (s *EventStream) LastEventID() uint64 {
+	return s.lastID
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// SubmitPain001 executes the credit transfers of a pain.001 document in the mode, and writes the pain.002 status report
+// of the document into w. The mode defaults to AtomicExecution when it is empty. The report tells which transfers were
+// executed, so a document must not be submitted again because some of its transfers were rejected.
+func (c *Client) SubmitPain001(ctx context.Context, document []byte, mode ExecutionMode, w io.Writer) (int64, error) {
+	query := url.Values{}
+	setQuery(query, "execution", mode.String())
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml")
+	header.Set("Accept", "application/xml, application/json")
+
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/payments/pain001", query: query, raw: document, header: header})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
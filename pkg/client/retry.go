@@ -0,0 +1,37 @@
+package client
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy decides whether a failed request is retried and how long to wait before it.
+type retryPolicy struct {
+	maxRetries int // negative disables the retries
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// next returns the delay before retrying a request whose attempt failed with err, and whether it must be retried.
+// The delay doubles after every attempt, with a random jitter so that clients failing together do not retry together,
+// unless the response asks for a specific one with the Retry-After header.
+func (p retryPolicy) next(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.maxRetries || !IsRetryable(err) {
+		return 0, false
+	}
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, p.maxBackoff), true
+		}
+	}
+
+	backoff := p.maxBackoff
+	if attempt < 32 && p.minBackoff <= p.maxBackoff>>attempt {
+		backoff = p.minBackoff << attempt
+	}
+	// wait between half and all of the backoff
+	return backoff/2 + rand.N(backoff/2+1), true
+}
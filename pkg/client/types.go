@@ -0,0 +1,130 @@
+package client
+
+import (
+	errors "bank_test/internal/api_errors"
+	"bank_test/internal/audit"
+	"bank_test/internal/auth"
+	"bank_test/internal/db/models"
+	"bank_test/internal/enum"
+	"bank_test/internal/reconciliation"
+	"bank_test/internal/transport/http/schemas"
+	"bank_test/internal/webhook"
+)
+
+// The types of the API. They are the ones used by the server, so they never drift from it.
+type (
+	Account         = models.Account
+	Transaction     = models.Transaction
+	TransactionType = enum.TransactionType
+	StatementFormat = enum.StatementFormat
+	ExecutionMode   = enum.ExecutionMode
+
+	Report       = reconciliation.Report
+	AuditRecord  = audit.Record
+	AuditAction  = audit.Action
+	AuditFilter  = audit.Filter
+	Verification = audit.Verification
+
+	Webhook              = webhook.Subscription
+	DeadLetter           = webhook.DeadLetter
+	EventType            = enum.EventType
+	CreateWebhookRequest = schemas.CreateWebhookRequest
+	UpdateWebhookRequest = schemas.UpdateWebhookRequest
+
+	ActivityType = enum.ActivityType
+
+	APIKey        = auth.APIKey
+	CreatedAPIKey = schemas.CreateAPIKeyResponse
+	Scope         = enum.Scope
+	Role          = enum.Role
+)
+
+// The types of transactions.
+const (
+	Deposit    = enum.Deposit
+	Withdrawal = enum.Withdrawal
+)
+
+// The formats of the statements of an account.
+const (
+	StatementCSV     = enum.CSVStatement
+	StatementOFX     = enum.OFXStatement
+	StatementCAMT053 = enum.CAMT053Statement
+)
+
+// The ways in which the credit transfers of a payment file are executed.
+const (
+	AtomicExecution  = enum.AtomicExecution
+	PerItemExecution = enum.PerItemExecution
+)
+
+// The types of the events delivered to webhooks.
+const (
+	AccountCreated     = enum.AccountCreated
+	TransactionCreated = enum.TransactionCreated
+	TransferCompleted  = enum.TransferCompleted
+)
+
+// The types of the events of an account stream. ResetActivity is sent when the events after the one the stream resumed
+// from are no longer buffered, so the account must be reloaded.
+const (
+	TransactionActivity = enum.TransactionActivity
+	BalanceActivity     = enum.BalanceActivity
+	ResetActivity       = ActivityType("reset")
+)
+
+// The scopes granted to api keys.
+const (
+	AccountsRead      = enum.AccountsRead
+	AccountsWrite     = enum.AccountsWrite
+	TransactionsRead  = enum.TransactionsRead
+	TransactionsWrite = enum.TransactionsWrite
+	TransfersWrite    = enum.TransfersWrite
+	WebhooksRead      = enum.WebhooksRead
+	WebhooksWrite     = enum.WebhooksWrite
+	AdminScope        = enum.AdminScope
+)
+
+// The roles of the api keys.
+const (
+	TellerRole  = enum.TellerRole
+	AuditorRole = enum.AuditorRole
+	AdminRole   = enum.AdminRole
+)
+
+// Error is an error returned by the API. Errors with the same code are equal for errors.Is.
+type Error = errors.APIError
+
+// The errors returned by the API. Compare them with errors.Is.
+var (
+	ErrInvalidBody            = errors.ErrInvalidBody
+	ErrAccountIdIsMissing     = errors.ErrAccountIdIsMissing
+	ErrAccountNotFound        = errors.ErrAccountNotFound
+	ErrInvalidAccountID       = errors.ErrInvalidAccountID
+	ErrInvalidIBAN            = errors.ErrInvalidIBAN
+	ErrInsufficientBalance    = errors.ErrInsufficientBalance
+	ErrInvalidAmount          = errors.INVALID_AMOUNT
+	ErrReportNotFound         = errors.ErrReportNotFound
+	ErrInvalidTimeRange       = errors.ErrInvalidTimeRange
+	ErrBackupNotSupported     = errors.ErrBackupNotSupported
+	ErrOutboxNotSupported     = errors.ErrOutboxNotSupported
+	ErrSubscriptionNotFound   = errors.ErrSubscriptionNotFound
+	ErrDeadLetterNotFound     = errors.ErrDeadLetterNotFound
+	ErrInvalidLastEventID     = errors.ErrInvalidLastEventID
+	ErrStreamingNotSupported  = errors.ErrStreamingNotSupported
+	ErrInvalidIdempotencyKey  = errors.ErrInvalidIdempotencyKey
+	ErrIdempotencyKeyReused   = errors.ErrIdempotencyKeyReused
+	ErrIdempotencyKeyInUse    = errors.ErrIdempotencyKeyInUse
+	ErrTimeout                = errors.ErrTimeout
+	ErrInvalidStatementFormat = errors.ErrInvalidStatementFormat
+	ErrInvalidPaymentDocument = errors.ErrInvalidPaymentDocument
+	ErrInvalidExecutionMode   = errors.ErrInvalidExecutionMode
+	ErrUnauthenticated        = errors.ErrUnauthenticated
+	ErrInsufficientScope      = errors.ErrInsufficientScope
+	ErrAPIKeyNotFound         = errors.ErrAPIKeyNotFound
+	ErrAccountAccessDenied    = errors.ErrAccountAccessDenied
+	ErrForbidden              = errors.ErrForbidden
+	ErrAccountFrozen          = errors.ErrAccountFrozen
+	ErrFreezeNotSupported     = errors.ErrFreezeNotSupported
+	ErrUnknown                = errors.ErrUnknown
+)
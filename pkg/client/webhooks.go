@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateWebhook subscribes an endpoint to the events of the bank. The returned webhook is the only one that carries
+// its secret.
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	var sub Webhook
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks", body: req}, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListWebhooks retrieves all webhooks.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	subs := make([]Webhook, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks"}, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// GetWebhook retrieves a webhook by its id.
+func (c *Client) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var sub Webhook
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks/" + url.PathEscape(id)}, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// UpdateWebhook replaces the endpoint and the events of a webhook, and pauses or resumes its deliveries.
+func (c *Client) UpdateWebhook(ctx context.Context, id string, req UpdateWebhookRequest) (*Webhook, error) {
+	var sub Webhook
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/webhooks/" + url.PathEscape(id), body: req}, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// DeleteWebhook deletes a webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/webhooks/" + url.PathEscape(id)}, nil)
+}
+
+// ListDeadLetters retrieves the webhook deliveries that failed every attempt.
+func (c *Client) ListDeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	letters := make([]DeadLetter, 0)
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks/dead-letters"}, &letters); err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// RedeliverDeadLetter schedules a failed webhook delivery to be attempted again.
+func (c *Client) RedeliverDeadLetter(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/webhooks/dead-letters/" + url.PathEscape(id) + "/redeliver"}, nil)
+}
@@ -1,16 +1,9 @@
 package tests
 
 import (
-	errors "bank_test/internal/api_errors"
-	"bank_test/internal/db/models"
-	"bank_test/internal/helpers"
-	"bank_test/internal/transport/http/schemas"
-	"bytes"
+	"bank_test/pkg/client"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"testing"
 	"time"
 
@@ -23,8 +16,8 @@ import (
 )
 
 type integrationSuite struct {
-	baseURL string
-	c       *testcontainers.Container
+	client *client.Client
+	c      *testcontainers.Container
 	suite.Suite
 }
 
@@ -36,9 +29,10 @@ func (s *integrationSuite) SetupSuite() {
 	exposedPorts := []string{fmt.Sprintf("%s/tcp", port), fmt.Sprintf("%s/tcp", health_port)}
 
 	env := map[string]string{
-		"PORT":        port,
-		"HEALTH_PORT": health_port,
-		"LOG_LEVEL":   "info",
+		"PORT":               port,
+		"HEALTH_PORT":        health_port,
+		"LOG_LEVEL":          "info",
+		"OPENAPI_VALIDATION": "true",
 	}
 
 	natHealthPort, err := nat.NewPort("tcp", health_port)
@@ -69,7 +63,8 @@ func (s *integrationSuite) SetupSuite() {
 
 	mappedPort, err := container.MappedPort(ctx, nat.Port(port))
 	s.Require().NoError(err)
-	s.baseURL = fmt.Sprintf("http://localhost:%s", mappedPort.Port())
+	s.client, err = client.New(fmt.Sprintf("http://localhost:%s", mappedPort.Port()), client.Options{Actor: "integration-test"})
+	s.Require().NoError(err)
 }
 
 func (s *integrationSuite) TearDownSuite() {
@@ -78,374 +73,186 @@ func (s *integrationSuite) TearDownSuite() {
 }
 
 func (s *integrationSuite) TestCreateAccount() {
-	endpoint := "/accounts"
+	ctx := context.Background()
 
 	s.Run("ok: create account", func() {
-		input := []struct {
-			in                 *schemas.CreateAccountRequest
-			err                error
-			expectedStatusCode int
-		}{
-			{
-				in: &schemas.CreateAccountRequest{
-					Owner:          "John Doe",
-					InitialBalance: helpers.PointerValue(100.0),
-				},
-				expectedStatusCode: 201,
-			},
-		}
-
-		for _, tt := range input {
-			jsonData, err := json.Marshal(tt.in)
-			s.Require().NoError(err)
-
-			resp, err := http.Post(s.baseURL+endpoint, "application/json", bytes.NewBuffer(jsonData))
-			s.Require().NoError(err)
-			s.Require().Equal(tt.expectedStatusCode, resp.StatusCode)
-		}
+		acc, err := s.client.CreateAccount(ctx, "John Doe", 100)
+		s.Require().NoError(err)
+		s.NotEmpty(acc.ID)
+		s.NotEmpty(acc.IBAN)
+		s.Equal("John Doe", acc.Owner)
+		s.Equal(100.0, acc.Balance)
 	})
 
 	s.Run("fail: create account with invalid body", func() {
-		input := []struct {
-			in                 *schemas.CreateAccountRequest
-			out                *errors.APIError
-			err                error
-			expectedStatusCode int
-		}{
-			{
-				in: &schemas.CreateAccountRequest{
-					Owner: "John Doe",
-				},
-				out:                errors.ErrInvalidBody,
-				expectedStatusCode: errors.ErrInvalidBody.HTTPStatus,
-			},
-			{
-				in: &schemas.CreateAccountRequest{
-					InitialBalance: helpers.PointerValue(100.0),
-				},
-				out:                errors.ErrInvalidBody,
-				expectedStatusCode: errors.ErrInvalidBody.HTTPStatus,
-			},
-			{
-				in:                 &schemas.CreateAccountRequest{},
-				out:                errors.ErrInvalidBody,
-				expectedStatusCode: errors.ErrInvalidBody.HTTPStatus,
-			},
-		}
-
-		for _, tt := range input {
-			jsonData, err := json.Marshal(tt.in)
-			s.Require().NoError(err)
-
-			resp, err := http.Post(s.baseURL+endpoint, "application/json", bytes.NewBuffer(jsonData))
-			s.Require().NoError(err)
-
-			defer resp.Body.Close()
-
-			responseJSON, _ := io.ReadAll(resp.Body)
-
-			var body errors.APIError
-			err = json.Unmarshal(responseJSON, &body)
-			s.Require().NoError(err)
-
-			s.Equal(tt.expectedStatusCode, resp.StatusCode)
-			s.Equal(tt.out.Code, body.Code)
-		}
-
+		_, err := s.client.CreateAccount(ctx, "", 100)
+		s.ErrorIs(err, client.ErrInvalidBody)
 	})
 }
 
 func (s *integrationSuite) TestGetAccount() {
-	endpoint := "/accounts"
+	ctx := context.Background()
 
 	// create account
-	input := &schemas.CreateAccountRequest{
-		Owner:          "John Doe",
-		InitialBalance: helpers.PointerValue(100.0),
-	}
-
-	jsonData, err := json.Marshal(input)
-	s.Require().NoError(err)
-	resp, err := http.Post(s.baseURL+endpoint, "application/json", bytes.NewBuffer(jsonData))
-	s.Require().NoError(err)
-	defer resp.Body.Close()
-
-	var account models.Account
-	err = json.NewDecoder(resp.Body).Decode(&account)
+	account, err := s.client.CreateAccount(ctx, "John Doe", 100)
 	s.Require().NoError(err)
 
 	s.Run("ok: get account", func() {
-		method := fmt.Sprintf("%s/%s", endpoint, account.ID)
-		resp, err := http.Get(s.baseURL + method)
-		s.Require().NoError(err)
-		s.Require().Equal(200, resp.StatusCode)
-		defer resp.Body.Close()
-
-		var body models.Account
-		err = json.NewDecoder(resp.Body).Decode(&body)
+		acc, err := s.client.GetAccount(ctx, account.ID)
 		s.Require().NoError(err)
 
-		s.Equal(account.ID, body.ID)
-		s.Equal(account.Owner, body.Owner)
-		s.Equal(account.Balance, body.Balance)
+		s.Equal(account.ID, acc.ID)
+		s.Equal(account.Owner, acc.Owner)
+		s.Equal(account.Balance, acc.Balance)
 	})
 
 	s.Run("fail: get account with invalid account id", func() {
-		method := fmt.Sprintf("%s/%s", endpoint, "invalid_id")
-		resp, err := http.Get(s.baseURL + method)
-		s.Require().NoError(err)
-		s.Require().Equal(400, resp.StatusCode)
-		defer resp.Body.Close()
-
-		var body errors.APIError
-		err = json.NewDecoder(resp.Body).Decode(&body)
-		s.Require().NoError(err)
-
-		s.Equal(errors.ErrInvalidAccountID.Code, body.Code)
+		_, err := s.client.GetAccount(ctx, "invalid_id")
+		s.ErrorIs(err, client.ErrInvalidAccountID)
 	})
 
 	s.Run("fail: get account with account not found", func() {
-		method := fmt.Sprintf("%s/%s", endpoint, uuid.NewString())
-		resp, err := http.Get(s.baseURL + method)
-		s.Require().NoError(err)
-		s.Require().Equal(400, resp.StatusCode)
-		defer resp.Body.Close()
+		_, err := s.client.GetAccount(ctx, uuid.NewString())
+		s.ErrorIs(err, client.ErrAccountNotFound)
+	})
+}
+
+func (s *integrationSuite) TestGetAccountByIBAN() {
+	ctx := context.Background()
 
-		var body errors.APIError
-		err = json.NewDecoder(resp.Body).Decode(&body)
+	// create account
+	account, err := s.client.CreateAccount(ctx, "John Doe", 100)
+	s.Require().NoError(err)
+
+	s.Run("ok: get account by iban", func() {
+		acc, err := s.client.GetAccountByIBAN(ctx, account.IBAN)
 		s.Require().NoError(err)
 
-		s.Equal(errors.ErrAccountNotFound.Code, body.Code)
+		s.Equal(account.ID, acc.ID)
+		s.Equal(account.IBAN, acc.IBAN)
+	})
+
+	s.Run("fail: get account with invalid iban", func() {
+		_, err := s.client.GetAccountByIBAN(ctx, "GB82WEST12345698765433")
+		s.ErrorIs(err, client.ErrInvalidIBAN)
 	})
 }
 
 func (s *integrationSuite) TestGetAllAccounts() {
-	endpoint := "/accounts"
+	ctx := context.Background()
 
 	// create 3 accounts
-	accounts := []schemas.CreateAccountRequest{
-		{
-			Owner:          "John Doe",
-			InitialBalance: helpers.PointerValue(100.0),
-		},
-		{
-			Owner:          "Jane Doe",
-			InitialBalance: helpers.PointerValue(200.0),
-		},
-		{
-			Owner:          "Alice",
-			InitialBalance: helpers.PointerValue(300.0),
-		},
-	}
-	for _, account := range accounts {
-		jsonData, err := json.Marshal(account)
-		s.Require().NoError(err)
-
-		resp, err := http.Post(s.baseURL+endpoint, "application/json", bytes.NewBuffer(jsonData))
+	for _, owner := range []string{"John Doe", "Jane Doe", "Alice"} {
+		_, err := s.client.CreateAccount(ctx, owner, 100)
 		s.Require().NoError(err)
-		defer resp.Body.Close()
 	}
 
 	s.Run("ok: get all accounts", func() {
-		resp, err := http.Get(s.baseURL + endpoint)
+		accounts, err := s.client.ListAccounts(ctx)
 		s.Require().NoError(err)
-		s.Require().Equal(200, resp.StatusCode)
-		defer resp.Body.Close()
 
-		var body []models.Account
-		err = json.NewDecoder(resp.Body).Decode(&body)
-		s.Require().NoError(err)
-
-		s.NotEmpty(body)
-		s.NotZero(len(body)) // in this case we cannot exactly know the length since we are performing multiple tests in parallel
+		s.NotEmpty(accounts)
+		s.GreaterOrEqual(len(accounts), 3) // in this case we cannot exactly know the length since we are performing multiple tests in parallel
 	})
 }
 
 func (s *integrationSuite) TestCreateTransaction() {
-	endpoint := "/accounts"
+	ctx := context.Background()
 
 	// create 1 account
-	account := schemas.CreateAccountRequest{
-		Owner:          "transaction account",
-		InitialBalance: helpers.PointerValue(100.0),
-	}
-	jsonData, err := json.Marshal(account)
-	s.Require().NoError(err)
-
-	resp, err := http.Post(s.baseURL+"/accounts", "application/json", bytes.NewBuffer(jsonData))
-	s.Require().NoError(err)
-	defer resp.Body.Close()
-
-	var body models.Account
-	err = json.NewDecoder(resp.Body).Decode(&body)
+	account, err := s.client.CreateAccount(ctx, "transaction account", 100)
 	s.Require().NoError(err)
 
 	s.Run("ok: create transaction", func() {
-		path := fmt.Sprintf("%s/%s/transactions", endpoint, body.ID)
-
 		input := []struct {
-			in                 *schemas.CreateTransactionRequest
-			expectedStatusCode int
+			txType          client.TransactionType
+			amount          float64
+			expectedBalance float64
 		}{
-			{
-				in: &schemas.CreateTransactionRequest{
-					Type:   "deposit",
-					Amount: helpers.PointerValue(100.0),
-				},
-				expectedStatusCode: http.StatusCreated,
-			},
-			{
-				in: &schemas.CreateTransactionRequest{
-					Type:   "withdrawal",
-					Amount: helpers.PointerValue(50.0),
-				},
-				expectedStatusCode: http.StatusCreated,
-			},
+			{txType: client.Deposit, amount: 100, expectedBalance: 200},
+			{txType: client.Withdrawal, amount: 50, expectedBalance: 150},
 		}
 
 		for _, tt := range input {
-			jsonData, err := json.Marshal(tt.in)
-			s.Require().NoError(err)
-
-			resp, err := http.Post(s.baseURL+path, "application/json", bytes.NewBuffer(jsonData))
+			tx, err := s.client.CreateTransaction(ctx, account.ID, tt.txType, tt.amount)
 			s.Require().NoError(err)
-			s.Require().Equal(tt.expectedStatusCode, resp.StatusCode)
+			s.Equal(tt.txType, tx.Type)
 
 			// get account
-			resp, err = http.Get(s.baseURL + fmt.Sprintf("%s/%s", endpoint, body.ID))
+			acc, err := s.client.GetAccount(ctx, account.ID)
 			s.Require().NoError(err)
-			s.Require().Equal(200, resp.StatusCode)
-			defer resp.Body.Close()
+			s.Equal(tt.expectedBalance, acc.Balance)
+		}
+	})
 
-			var account models.Account
-			err = json.NewDecoder(resp.Body).Decode(&account)
-			s.Require().NoError(err)
+	s.Run("fail: create transaction with insufficient balance", func() {
+		_, err := s.client.Withdraw(ctx, account.ID, 1000)
+		s.ErrorIs(err, client.ErrInsufficientBalance)
+	})
 
-			expectedBalance := body.Balance
-			if *tt.in.Amount > 0 {
-				expectedBalance += *tt.in.Amount
-			} else {
-				expectedBalance -= *tt.in.Amount
-			}
+	s.Run("ok: retried transaction is performed once", func() {
+		keyCtx := client.WithIdempotencyKey(ctx, uuid.NewString())
+		first, err := s.client.Deposit(keyCtx, account.ID, 10)
+		s.Require().NoError(err)
+		second, err := s.client.Deposit(keyCtx, account.ID, 10)
+		s.Require().NoError(err)
+		s.Equal(first.ID, second.ID)
 
-			s.Equal(expectedBalance, account.Balance)
-		}
+		acc, err := s.client.GetAccount(ctx, account.ID)
+		s.Require().NoError(err)
+		s.Equal(160.0, acc.Balance)
 	})
 }
 
 func (s *integrationSuite) TestGetTransactionByAccountID() {
-	endpoint := "/accounts"
+	ctx := context.Background()
 
 	// create 1 account
-	account := schemas.CreateAccountRequest{
-		Owner:          "get by account id test",
-		InitialBalance: helpers.PointerValue(100.0),
-	}
-	jsonData, err := json.Marshal(account)
+	account, err := s.client.CreateAccount(ctx, "get by account id test", 100)
 	s.Require().NoError(err)
 
-	resp, err := http.Post(s.baseURL+"/accounts", "application/json", bytes.NewBuffer(jsonData))
-	s.Require().NoError(err)
-
-	var body models.Account
-	err = json.NewDecoder(resp.Body).Decode(&body)
-	s.Require().NoError(err)
-	defer resp.Body.Close()
-
 	// create 1 transaction
-	path := fmt.Sprintf("%s/%s/transactions", endpoint, body.ID)
-	transaction := schemas.CreateTransactionRequest{
-		Type:   "deposit",
-		Amount: helpers.PointerValue(100.0),
-	}
-	jsonData, err = json.Marshal(transaction)
-	s.Require().NoError(err)
-
-	_, err = http.Post(s.baseURL+path, "application/json", bytes.NewBuffer(jsonData))
+	transaction, err := s.client.Deposit(ctx, account.ID, 100)
 	s.Require().NoError(err)
 
 	s.Run("ok: get transactions by account id", func() {
-		resp, err := http.Get(s.baseURL + fmt.Sprintf("%s/%s/transactions", endpoint, body.ID))
+		transactions, err := s.client.GetTransactions(ctx, account.ID)
 		s.Require().NoError(err)
-		s.Require().Equal(200, resp.StatusCode)
-		defer resp.Body.Close()
-
-		var transactions []models.Transaction
-		err = json.NewDecoder(resp.Body).Decode(&transactions)
-		s.Require().NoError(err)
-
-		s.NotEmpty(transactions)
-		s.NotZero(len(transactions))
-
-		s.Equal(transaction.Type, transactions[0].Type.String())
-		s.Equal(*transaction.Amount, transactions[0].Amount)
-		s.Equal(body.ID, transactions[0].AccountID)
 
+		s.Require().NotEmpty(transactions)
+		s.Equal(transaction.ID, transactions[0].ID)
+		s.Equal(client.Deposit, transactions[0].Type)
+		s.Equal(100.0, transactions[0].Amount)
+		s.Equal(account.ID, transactions[0].AccountID)
 	})
 }
 
 func (s *integrationSuite) TestTransfer() {
-	accountsEndpoint := "/accounts"
+	ctx := context.Background()
 
 	// create 2 accounts
-	accounts := []schemas.CreateAccountRequest{
-		{
-			Owner:          "from",
-			InitialBalance: helpers.PointerValue(100.0),
-		},
-		{
-			Owner:          "to",
-			InitialBalance: helpers.PointerValue(0.0),
-		},
-	}
-
-	accountStored := make(map[string]*models.Account)
-	for _, account := range accounts {
-		jsonData, err := json.Marshal(account)
-		s.Require().NoError(err)
-
-		resp, err := http.Post(s.baseURL+accountsEndpoint, "application/json", bytes.NewBuffer(jsonData))
-		s.Require().NoError(err)
-		defer resp.Body.Close()
-
-		var body models.Account
-		err = json.NewDecoder(resp.Body).Decode(&body)
-		s.Require().NoError(err)
-
-		accountStored[body.Owner] = &body
-	}
+	from, err := s.client.CreateAccount(ctx, "from", 100)
+	s.Require().NoError(err)
+	to, err := s.client.CreateAccount(ctx, "to", 0)
+	s.Require().NoError(err)
 
 	s.Run("ok: transfer", func() {
-		body := schemas.TransferRequest{
-			FromAccountId: accountStored["from"].ID,
-			ToAccountId:   accountStored["to"].ID,
-			Amount:        helpers.PointerValue(50.0),
-		}
-
-		jsonData, err := json.Marshal(body)
-		s.Require().NoError(err)
-
-		resp, err := http.Post(s.baseURL+"/transfer", "application/json", bytes.NewBuffer(jsonData))
-		s.Require().NoError(err)
-		s.Require().Equal(201, resp.StatusCode)
+		s.Require().NoError(s.client.Transfer(ctx, from.ID, to.ID, 50))
 
 		// get accounts
-		for _, account := range accounts {
-			resp, err := http.Get(s.baseURL + fmt.Sprintf("%s/%s", accountsEndpoint, accountStored[account.Owner].ID))
-			s.Require().NoError(err)
-			s.Require().Equal(200, resp.StatusCode)
-			defer resp.Body.Close()
-
-			var acc models.Account
-			err = json.NewDecoder(resp.Body).Decode(&acc)
+		for _, id := range []string{from.ID, to.ID} {
+			acc, err := s.client.GetAccount(ctx, id)
 			s.Require().NoError(err)
 
 			expectedBalance := 50.0 // 100 - 50 for one user and 0 + 50 for the other
 			s.Equal(expectedBalance, acc.Balance)
 		}
-
 	})
 
+	s.Run("fail: transfer with insufficient balance", func() {
+		err := s.client.Transfer(ctx, from.IBAN, to.IBAN, 1000)
+		s.ErrorIs(err, client.ErrInsufficientBalance)
+	})
 }
 
 // TestIntegrationSuite runs the integration test suite.